@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,65 +13,421 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/subhammahanty235/url-shortener/internal/audit"
 	"github.com/subhammahanty235/url-shortener/internal/config"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/events"
 	"github.com/subhammahanty235/url-shortener/internal/handler"
+	"github.com/subhammahanty235/url-shortener/internal/metadata"
 	"github.com/subhammahanty235/url-shortener/internal/middleware"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/breaker"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/chaos"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/geo"
 	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
 	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
 	"github.com/subhammahanty235/url-shortener/internal/repository"
 	"github.com/subhammahanty235/url-shortener/internal/repository/cache"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"github.com/subhammahanty235/url-shortener/internal/repository/sqlite"
 	"github.com/subhammahanty235/url-shortener/internal/service"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Build metadata, overridden at build time with -ldflags "-X main.version=..."
+var (
+	version   = "dev"
+	gitCommit = "unknown"
+	buildTime = "unknown"
 )
 
 func main() {
-	logger := initLogger()
+	startTime := time.Now()
+	logger, logLevel := initLogger()
 	defer logger.Sync()
-	logger.Info("starting URL shortener service")
+	logger.Info("starting URL shortener service",
+		zap.String("version", version),
+		zap.String("git_commit", gitCommit),
+	)
 	cfg, err := config.Load()
 	if err != nil {
 		logger.Fatal("failed to load configuration", zap.Error(err))
 	}
+	// Same level a SIGHUP reload would apply, so a reload without edits
+	// changes nothing
+	if level, err := zapcore.ParseLevel(cfg.Logging.Level); err == nil {
+		logLevel.SetLevel(level)
+	} else {
+		logger.Warn("invalid LOG_LEVEL, keeping info", zap.String("level", cfg.Logging.Level))
+	}
 
 	// Initialize metrics
 	// Learning: Create metrics early so all components can use them
 	m := metrics.NewMetrics()
 	logger.Info("metrics initialized - Prometheus endpoint will be available at /metrics")
 
-	db, err := repository.NewPostgresConnection(cfg.Database, logger)
-	if err != nil {
-		logger.Fatal("failed to connect to database", zap.Error(err))
-	}
-	defer repository.Close(db, logger)
-	if err := repository.RunMigrations(db, logger); err != nil {
-		logger.Fatal("failed to run migrations", zap.Error(err))
+	// Background workers (samplers, flushers) stop when this context is cancelled
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	defer bgCancel()
+
+	var urlRepo domain.URLRepository
+	var cacheRepo domain.CacheRepository
+	var scanCounter middleware.ScanCounter
+	var clickCounter domain.ClickCounter
+	var clickEvents domain.ClickEventRepository
+	var clickLimiter domain.ClickLimiter
+	// localClicks is set when clicks are buffered in process memory
+	var localClicks *repository.LocalClickCounter
+	// auditTable is the storage backend's audit table, used for AUDIT_SINK=table
+	var auditTable domain.AuditLog
+	machineID := getMachineID()
+
+	// Fault injection for resilience testing, a no-op unless built with -tags chaos
+	cacheFaults := chaos.Fault{Latency: cfg.Chaos.CacheLatency, ErrorRate: cfg.Chaos.CacheErrorRate}
+	dbFaults := chaos.Fault{Latency: cfg.Chaos.DBLatency, ErrorRate: cfg.Chaos.DBErrorRate}
+	if cacheFaults.Configured() || dbFaults.Configured() {
+		switch {
+		case !chaos.Enabled:
+			logger.Warn("CHAOS_* settings ignored, this binary was built without -tags chaos")
+		case cfg.Storage.Backend != config.StoragePostgres:
+			logger.Warn("CHAOS_* settings only apply to the postgres storage backend", zap.String("backend", cfg.Storage.Backend))
+		default:
+			logger.Warn("fault injection enabled",
+				zap.Duration("cache_latency", cacheFaults.Latency),
+				zap.Float64("cache_error_rate", cacheFaults.ErrorRate),
+				zap.Duration("db_latency", dbFaults.Latency),
+				zap.Float64("db_error_rate", dbFaults.ErrorRate),
+			)
+		}
 	}
-	redisClient, err := cache.NewRedisClient(cfg.Redis, logger)
+
+	// The scope decides the schema's unique index on short codes
+	aliasScope, err := service.ParseAliasScope(cfg.URL.AliasScope)
 	if err != nil {
-		logger.Fatal("failed to connect to Redis", zap.Error(err))
+		logger.Fatal("invalid alias scope", zap.Error(err))
+	}
+	schema := repository.SchemaOptions{CodesPerPrefix: aliasScope == service.AliasScopePrefix}
+
+	switch cfg.Storage.Backend {
+	case config.StorageMemory:
+		// No Postgres or Redis needed - handy for local hacking, data is lost on restart
+		logger.Warn("using in-memory storage, links will not survive a restart")
+		memoryURLs := memory.NewURLRepository()
+		if schema.CodesPerPrefix {
+			memoryURLs = memory.NewPrefixScopedURLRepository()
+		}
+		urlRepo = memoryURLs
+		clickCounter = memoryURLs
+		clickEvents = memory.NewClickEventRepository()
+		auditTable = memory.NewAuditLog()
+		cacheRepo = memory.NewCacheRepository(24 * time.Hour)
+		scanCounter = memory.NewScanCounter()
+		clickLimiter = memory.NewClickLimiter()
+
+	case config.StorageSQLite:
+		// A single binary and a file: no Postgres or Redis, so caches, rate
+		// limits and click buffering live in process memory
+		db, err := sqlite.Open(cfg.Storage.SQLitePath, logger)
+		if err != nil {
+			logger.Fatal("failed to open database", zap.Error(err))
+		}
+		defer repository.Close(db, logger)
+		if err := sqlite.RunMigrations(db, schema, logger); err != nil {
+			logger.Fatal("failed to run migrations", zap.Error(err))
+		}
+
+		sqliteURLs := sqlite.NewURLRepository(db, m)
+		urlRepo = sqliteURLs
+		clickEvents = sqliteURLs
+		auditTable = sqliteURLs
+		cacheRepo = memory.NewCacheRepository(24 * time.Hour)
+		scanCounter = memory.NewScanCounter()
+		clickLimiter = memory.NewClickLimiter()
+
+		// One writer at a time: batching clicks keeps redirects from
+		// queueing behind each other's UPDATEs
+		localClicks = repository.NewLocalClickCounter(sqliteURLs, repository.LocalClickCounterConfig{
+			Interval: cfg.Analytics.ClickFlushInterval,
+			Shards:   cfg.Analytics.ClickShards,
+		}, m, logger)
+		go localClicks.Run(bgCtx)
+		clickCounter = localClicks
+
+	case config.StoragePostgres:
+		db, err := repository.NewPostgresConnection(cfg.Database, logger)
+		if err != nil {
+			logger.Fatal("failed to connect to database", zap.Error(err))
+		}
+		defer repository.Close(db, logger)
+		if err := repository.RunMigrations(db, schema, logger); err != nil {
+			logger.Fatal("failed to run migrations", zap.Error(err))
+		}
+
+		go repository.RunDBStatsSampler(bgCtx, db, cfg.Database.StatsInterval, m)
+		redisClient, err := cache.NewRedisClient(cfg.Redis, logger)
+		if err != nil {
+			logger.Fatal("failed to connect to Redis", zap.Error(err))
+		}
+		defer cache.Close(redisClient, logger)
+
+		go repository.RunRedisPoolStatsSampler(bgCtx, redisClient, cfg.Redis.StatsInterval, m)
+
+		// Refuse to run with a machine ID another instance is using, its
+		// snowflake codes would collide with ours
+		if cfg.URL.MachineIDLock && usesSnowflake(cfg.URL) {
+			lease, err := repository.AcquireMachineID(bgCtx, redisClient, machineID, repository.MachineLeaseConfig{
+				TTL:        cfg.URL.MachineIDLockTTL,
+				AutoAssign: cfg.URL.MachineIDAutoAssign,
+				MaxID:      keygen.MaxMachineID,
+				KeyPrefix:  cfg.Redis.KeyPrefix,
+			}, logger)
+			if err != nil {
+				logger.Fatal("failed to lease machine id", zap.Error(err))
+			}
+			machineID = lease.ID()
+			go lease.Run(bgCtx)
+			defer func() {
+				if err := lease.Release(context.Background()); err != nil {
+					logger.Warn("failed to release machine id lease", zap.Error(err))
+				}
+			}()
+		}
+
+		// Pass metrics to repositories
+		// Learning: Metrics flow from top (main.go) to bottom (repositories)
+		dbBreaker := breaker.New("postgres", breaker.Config{
+			MaxFailures:      uint32(cfg.Database.BreakerMaxFailures),
+			OpenTimeout:      cfg.Database.BreakerOpenTimeout,
+			HalfOpenRequests: uint32(cfg.Database.BreakerHalfOpenRequests),
+		}, logger, m, repository.IsDBBreakerSuccess)
+		postgresURLs := repository.NewPostgresURLRepository(db, m, dbBreaker, repository.RetryPolicy{
+			MaxAttempts: cfg.Database.RetryMaxAttempts,
+			BaseDelay:   cfg.Database.RetryBaseDelay,
+			MaxDelay:    cfg.Database.RetryMaxDelay,
+		}, repository.SlowQueryLog{
+			Threshold: cfg.Database.SlowQueryThreshold,
+			Logger:    logger,
+		})
+		if len(cfg.Database.ReadReplicaDSNs) > 0 {
+			replicas, err := repository.NewReadReplicaConnections(cfg.Database, logger)
+			if err != nil {
+				logger.Fatal("failed to connect to read replicas", zap.Error(err))
+			}
+			for _, replica := range replicas {
+				defer repository.Close(replica, logger)
+			}
+			postgresURLs.UseReadReplicas(replicas, cfg.Database.ReplicaLagWindow)
+		}
+		postgresURLs.InjectFaults(dbFaults)
+		urlRepo = postgresURLs
+		clickEvents = postgresURLs
+		auditTable = postgresURLs
+		cacheSerializer, err := repository.NewCacheSerializer(cfg.Redis.CacheFormat)
+		if err != nil {
+			logger.Fatal("invalid cache format", zap.Error(err))
+		}
+		switch cfg.Storage.CacheBackend {
+		case config.CacheRedis:
+			cacheBreaker := breaker.New("redis", breaker.Config{
+				MaxFailures: uint32(cfg.Redis.BreakerMaxFailures),
+				OpenTimeout: cfg.Redis.BreakerOpenTimeout,
+			}, logger, m, repository.IsCacheBreakerSuccess)
+			cacheRepo = repository.NewRedisCacheRepository(redisClient, 24*time.Hour, m, cacheBreaker, repository.RedisCacheOptions{
+				Serializer: cacheSerializer,
+				KeyPrefix:  cfg.Redis.KeyPrefix,
+				GetTimeout: cfg.Redis.GetTimeout,
+				Faults:     cacheFaults,
+				HotKeys: repository.HotKeyConfig{
+					Shards:    cfg.Redis.HotKeyShards,
+					Threshold: cfg.Redis.HotKeyThreshold,
+					Window:    cfg.Redis.HotKeyWindow,
+				},
+			})
+
+		case config.CacheMemcached:
+			// Links are cached in Memcached; click counters, rate limits and
+			// the machine ID lease still need Redis (REDIS_HOST)
+			memcachedClient, err := cache.NewMemcachedClient(cfg.Memcached, logger)
+			if err != nil {
+				logger.Fatal("failed to connect to Memcached", zap.Error(err))
+			}
+			defer cache.CloseMemcached(memcachedClient, logger)
+			memcachedReads, err := cache.NewMemcachedReader(cfg.Memcached)
+			if err != nil {
+				logger.Fatal("failed to connect to Memcached", zap.Error(err))
+			}
+			defer cache.CloseMemcached(memcachedReads, logger)
+
+			cacheBreaker := breaker.New("memcached", breaker.Config{
+				MaxFailures: uint32(cfg.Memcached.BreakerMaxFailures),
+				OpenTimeout: cfg.Memcached.BreakerOpenTimeout,
+			}, logger, m, repository.IsMemcachedBreakerSuccess)
+			cacheRepo = repository.NewMemcachedCacheRepository(memcachedClient, 24*time.Hour, m, cacheBreaker, repository.MemcachedCacheOptions{
+				Serializer: cacheSerializer,
+				KeyPrefix:  cfg.Memcached.KeyPrefix,
+				Reads:      memcachedReads,
+				Faults:     cacheFaults,
+			})
+
+		default:
+			logger.Fatal("unknown cache backend", zap.String("backend", cfg.Storage.CacheBackend))
+		}
+		if cfg.Redis.L1Size > 0 {
+			cacheRepo = repository.NewTieredCache(cacheRepo, repository.TieredCacheConfig{
+				Size: cfg.Redis.L1Size,
+				TTL:  cfg.Redis.L1TTL,
+			}, m)
+			logger.Info("L1 cache enabled", zap.Int("size", cfg.Redis.L1Size), zap.Duration("ttl", cfg.Redis.L1TTL))
+		}
+		scanCounter = repository.NewRedisScanCounter(redisClient, cfg.Redis.KeyPrefix)
+		clickLimiter = repository.NewRedisClickLimiter(redisClient, cfg.Redis.KeyPrefix)
+
+		// Clicks are counted in Redis on the redirect path and reconciled into
+		// urls.click_count in the background
+		clickCounter = repository.NewRedisClickCounter(redisClient, cfg.Redis.KeyPrefix)
+		if cfg.Analytics.ClickLocalBuffer {
+			// Counted in memory and flushed straight to Postgres; the Redis
+			// flusher below still drains counters left from before the switch
+			localClicks = repository.NewLocalClickCounter(postgresURLs, repository.LocalClickCounterConfig{
+				Interval: cfg.Analytics.ClickFlushInterval,
+				Shards:   cfg.Analytics.ClickShards,
+			}, m, logger)
+			go localClicks.Run(bgCtx)
+			clickCounter = localClicks
+		}
+		clickFlusher := repository.NewClickFlusher(redisClient, postgresURLs, repository.ClickFlusherConfig{
+			Interval:  cfg.Analytics.ClickFlushInterval,
+			BatchSize: cfg.Analytics.ClickFlushBatchSize,
+			KeyPrefix: cfg.Redis.KeyPrefix,
+		}, m, logger)
+		go clickFlusher.Run(bgCtx)
+
+	default:
+		logger.Fatal("unknown storage backend", zap.String("backend", cfg.Storage.Backend))
 	}
-	defer cache.Close(redisClient, logger)
 
-	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{
-		MachineID: getMachineID(),
-		MinLength: cfg.URL.MinCodeLength,
-		MaxLength: cfg.URL.MaxCodeLength,
-	})
+	keyGen, err := newKeyGenerator(cfg.URL, machineID)
 	if err != nil {
 		logger.Fatal("failed to initialize key generator", zap.Error(err))
 	}
+	if usesSnowflake(cfg.URL) && cfg.URL.CodeBufferSize > 0 {
+		// Bursts past 4096 codes/ms drain the buffer instead of waiting
+		// for the next millisecond under the generator lock
+		buffered := keygen.NewBufferedGenerator(keyGen, keygen.BufferConfig{Size: cfg.URL.CodeBufferSize})
+		go buffered.Run(bgCtx)
+		keyGen = buffered
+	}
+	logger.Info("key generator initialized",
+		zap.String("generator", cfg.URL.CodeGenerator),
+		zap.Int("buffer_size", cfg.URL.CodeBufferSize),
+	)
+
+	// Lifecycle events are only published when a webhook receiver is configured
+	var eventPublisher domain.EventPublisher
+	if cfg.Webhook.URL != "" {
+		webhook := events.NewWebhookPublisher(events.WebhookConfig{
+			URL:          cfg.Webhook.URL,
+			Secret:       cfg.Webhook.Secret,
+			Timeout:      cfg.Webhook.Timeout,
+			MaxRetries:   cfg.Webhook.MaxRetries,
+			RetryBackoff: cfg.Webhook.RetryBackoff,
+			QueueSize:    cfg.Webhook.QueueSize,
+		}, logger, m)
+		go webhook.Run(bgCtx)
+		eventPublisher = webhook
+		logger.Info("webhook events enabled", zap.String("url", cfg.Webhook.URL))
+	}
 
-	// Pass metrics to repositories
-	// Learning: Metrics flow from top (main.go) to bottom (repositories)
-	urlRepo := repository.NewPostgresURLRepository(db, m)
-	cacheRepo := repository.NewRedisCacheRepository(redisClient, 24*time.Hour, m)
+	// Redirects are forwarded to an external analytics pipeline only when
+	// an endpoint is configured
+	var analyticsSink domain.AnalyticsSink
+	if cfg.Analytics.SinkURL != "" {
+		sink := events.NewHTTPAnalyticsSink(events.AnalyticsConfig{
+			URL:           cfg.Analytics.SinkURL,
+			APIKey:        cfg.Analytics.SinkAPIKey,
+			BatchSize:     cfg.Analytics.SinkBatchSize,
+			FlushInterval: cfg.Analytics.SinkFlushInterval,
+			QueueSize:     cfg.Analytics.SinkQueueSize,
+			Timeout:       cfg.Analytics.SinkTimeout,
+		}, logger, m)
+		go sink.Run(bgCtx)
+		analyticsSink = sink
+		logger.Info("analytics sink enabled", zap.String("url", cfg.Analytics.SinkURL))
+	}
+
+	// Country routing has a resolver as soon as there is any way to place a
+	// visitor; without one every visitor gets the default destination
+	var countries domain.CountryResolver
+	var enricher *geo.Enricher
+	var countryLookup geo.CountryLookup
+	if cfg.Analytics.GeoIPCIDRFile != "" {
+		table, err := geo.LoadCIDRTable(cfg.Analytics.GeoIPCIDRFile)
+		if err != nil {
+			logger.Fatal("Failed to load GeoIP table", zap.Error(err))
+		}
+		countryLookup = table
+	}
+	if countryLookup != nil || cfg.Analytics.InferCountryFromLanguage {
+		enricher = geo.NewEnricher(countryLookup, geo.EnricherConfig{
+			InferFromAcceptLanguage: cfg.Analytics.InferCountryFromLanguage,
+		})
+		countries = enricher
+	}
+
+	// Alias checks are limited even when redirects aren't
+	aliasCheckLimiter := clickLimiter
+	if !cfg.ClickRate.Enabled {
+		clickLimiter = nil
+	}
 
+	var auditLog domain.AuditLog
+	switch cfg.Audit.Sink {
+	case config.AuditSinkLog:
+		auditLog = audit.NewLogger(logger)
+	case config.AuditSinkTable:
+		auditLog = auditTable
+	case config.AuditSinkOff:
+		logger.Warn("audit logging is disabled")
+	default:
+		logger.Fatal("unknown audit sink", zap.String("sink", cfg.Audit.Sink))
+	}
+
+	var metadataQueue domain.MetadataQueue
+	if cfg.Metadata.Enabled {
+		metadataWorker := metadata.NewWorker(metadata.NewFetcher(metadata.FetcherConfig{
+			Timeout:   cfg.Metadata.Timeout,
+			MaxBytes:  cfg.Metadata.MaxBytes,
+			UserAgent: cfg.Metadata.UserAgent,
+		}), urlRepo, cacheRepo, cfg.Metadata.QueueSize, logger, m)
+		go metadataWorker.Run(bgCtx)
+		metadataQueue = metadataWorker
+	}
+
+	cacheWritePolicy, err := service.ParseCacheWritePolicy(cfg.Redis.CacheWritePolicy)
+	if err != nil {
+		logger.Fatal("invalid cache write policy", zap.Error(err))
+	}
+	queryPrecedence, err := service.ParseQueryPrecedence(cfg.URL.PassthroughPrecedence)
+	if err != nil {
+		logger.Fatal("invalid passthrough precedence", zap.Error(err))
+	}
+	expiredBehavior, err := service.ParseExpiredBehavior(cfg.URL.ExpiredBehavior, cfg.URL.ExpiredRedirectURL)
+	if err != nil {
+		logger.Fatal("invalid expired link behavior", zap.Error(err))
+	}
+	oldCodeBehavior, err := service.ParseOldCodeBehavior(cfg.URL.RegenerateOldCode)
+	if err != nil {
+		logger.Fatal("invalid regenerated code behavior", zap.Error(err))
+	}
 	// Pass metrics to service
 	urlService := service.NewURLService(
 		urlRepo,
 		cacheRepo,
 		keyGen,
+		eventPublisher,
+		clickCounter,
 		logger,
 		m,
 		service.URLServiceConfig{
@@ -77,19 +435,152 @@ func main() {
 			DefaultTTL:  cfg.URL.DefaultTTL,
 			MaxTTL:      cfg.URL.MaxTTL,
 			AllowCustom: cfg.URL.AllowCustom,
-			CacheTTL:    24 * time.Hour,
+			CacheTTL:    cfg.Redis.CacheTTL,
+
+			CacheWritePolicy: cacheWritePolicy,
+			QueryPrecedence:  queryPrecedence,
+
+			ConsistencyCheckRate: cfg.Redis.ConsistencyCheckRate,
+
+			ExpiredBehavior:    expiredBehavior,
+			ExpiredRedirectURL: cfg.URL.ExpiredRedirectURL,
+
+			AllowPermanent: cfg.URL.AllowPermanent,
+			MaxURLLength:   cfg.URL.MaxURLLength,
+			MinCodeLength:  cfg.URL.MinCodeLength,
+			MaxCodeLength:  cfg.URL.MaxCodeLength,
+
+			AllowedDestinationDomains: cfg.URL.AllowedDestinationDomains,
+			BlockedDestinationDomains: cfg.URL.BlockedDestinationDomains,
+			AllowedSchemes:            cfg.URL.AllowedSchemes,
+
+			ReservationTTL:    cfg.URL.ReservationTTL,
+			MaxReservationTTL: cfg.URL.MaxReservationTTL,
+
+			DeleteGracePeriod: cfg.URL.DeleteGracePeriod,
+
+			AliasClaimTTL: cfg.URL.AliasClaimTTL,
+
+			AliasCheckLimiter:   aliasCheckLimiter,
+			AliasCheckRateLimit: cfg.URL.AliasCheckRateLimit,
+
+			AllowedPrefixes: cfg.URL.AllowedPrefixes,
+			AllowedSources:  cfg.URL.AllowedSources,
+
+			OldCodeBehavior: oldCodeBehavior,
+			AliasScope:      aliasScope,
+
+			CanonicalLinkHeader: cfg.URL.CanonicalLinkHeader,
+
+			AuditLog: auditLog,
+
+			MaxLinksPerUser: cfg.URL.MaxLinksPerUser,
+			UserLinkQuotas:  cfg.URL.UserLinkQuotas,
+
+			StatsCacheTTL: cfg.URL.StatsCacheTTL,
+
+			CaseInsensitiveCodes: cfg.URL.CaseInsensitiveCodes,
+			TrailingChars:        cfg.URL.TrailingChars,
+
+			SigningKey: []byte(cfg.URL.SigningKey),
+
+			CompactRedirectCache: cfg.Redis.CompactRedirectCache,
+
+			ClickLimiter:        clickLimiter,
+			ClickRateLimit:      cfg.ClickRate.Limit,
+			ClickRateWindow:     cfg.ClickRate.Window,
+			ClickRateLimitPerIP: cfg.ClickRate.PerIP,
+
+			MetadataQueue: metadataQueue,
+
+			AnalyticsSink: analyticsSink,
+
+			Countries: countries,
 		},
 	)
 
-	urlHandler := handler.NewURLHandler(urlService, logger)
-	router := setupRouter(cfg, urlHandler, m, logger)
+	go service.NewCleanupWorker(urlRepo, cfg.URL.CleanupInterval, logger).Run(bgCtx)
+	if eventPublisher != nil && cfg.Webhook.ExpiryReminder > 0 {
+		go service.NewExpiryReminderWorker(urlRepo, eventPublisher, cfg.Webhook.ExpiryReminder,
+			cfg.Webhook.ExpiryReminderInterval, logger).Run(bgCtx)
+	}
 
-	srv := &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler:      router,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
-		IdleTimeout:  120 * time.Second,
+	handler.RegisterValidators()
+	urlHandler := handler.NewURLHandler(urlService, logger, m)
+	readiness := handler.NewReadiness()
+	router := setupRouter(cfg, urlHandler, readiness, scanCounter, m, logger)
+	router.GET("/version", handler.Version(handler.BuildInfo{
+		Version:   version,
+		GitCommit: gitCommit,
+		BuildTime: buildTime,
+	}, startTime))
+
+	// Click events are written in batches off the request path
+	clickEventOverflow, err := repository.ParseOverflowPolicy(cfg.Analytics.ClickEventOverflow)
+	if err != nil {
+		logger.Fatal("invalid click event config", zap.Error(err))
+	}
+	clickEventWriter, ok := clickEvents.(repository.ClickEventWriter)
+	if !ok {
+		logger.Fatal("storage backend can't batch click events")
+	}
+	clickEventPipeline := repository.NewClickEventPipeline(clickEventWriter, repository.ClickEventPipelineConfig{
+		QueueSize:     cfg.Analytics.ClickEventQueueSize,
+		Workers:       cfg.Analytics.ClickEventWorkers,
+		BatchSize:     cfg.Analytics.ClickEventBatchSize,
+		FlushInterval: cfg.Analytics.ClickEventFlushInterval,
+		Overflow:      clickEventOverflow,
+	}, m, logger)
+	clickEvents = clickEventPipeline
+
+	// Email open tracking, outside the redirect group so scan detection and
+	// redirect metrics never see it
+	tracking := service.NewTrackingService(urlService, clickEvents, enricher, logger, m)
+	router.GET("/p/:file", handler.NewPixelHandler(tracking, logger).Pixel)
+
+	// Load hints for autoscalers that can't scrape /metrics; only the
+	// Postgres backend has pools to report
+	loadCfg := handler.LoadConfig{}
+	if cfg.Storage.Backend == config.StoragePostgres {
+		loadCfg.DBMaxOpenConns = cfg.Database.MaxOpenConns
+		loadCfg.RedisPoolSize = cfg.Redis.PoolSize
+	}
+	load := handler.NewLoad(m, loadCfg)
+	go load.Run(bgCtx)
+	router.GET("/api/v1/load", load.Load)
+
+	// Redirect chain preview for external links, a read so maintenance mode
+	// leaves it alone
+	if cfg.Expand.Enabled {
+		expander := metadata.NewExpander(metadata.ExpanderConfig{
+			Timeout:   cfg.Expand.Timeout,
+			MaxHops:   cfg.Expand.MaxHops,
+			MaxBytes:  cfg.URL.MaxFetchBytes,
+			UserAgent: cfg.Expand.UserAgent,
+		})
+		router.GET("/api/v1/expand", handler.NewExpandHandler(expander, logger).Expand)
+	}
+
+	if localClicks != nil && cfg.Server.AdminToken != "" {
+		router.GET("/admin/clicks/buffered", middleware.AdminAuth(cfg.Server.AdminToken), handler.BufferedClicks(localClicks))
+	}
+
+	// Every route is mounted by now, none of their names can become a code
+	urlService.ReserveCodes(handler.RouteSegments(router.Routes())...)
+
+	srv := newHTTPServer(cfg.Server, router)
+
+	pprofSrv, err := newPprofServer(cfg.Server)
+	if err != nil {
+		logger.Fatal("invalid pprof configuration", zap.Error(err))
+	}
+	if pprofSrv != nil {
+		go func() {
+			logger.Warn("pprof endpoints enabled", zap.String("address", pprofSrv.Addr))
+			if err := pprofSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("pprof server failed", zap.Error(err))
+			}
+		}()
 	}
 
 	// -----> rev todo
@@ -97,6 +588,7 @@ func main() {
 		logger.Info("server starting",
 			zap.String("address", srv.Addr),
 			zap.String("base_url", cfg.Server.BaseURL),
+			zap.Bool("h2c", cfg.Server.H2CEnabled && !cfg.Server.TLSEnabled),
 		)
 
 		var err error
@@ -111,9 +603,22 @@ func main() {
 		}
 	}()
 
+	// SIGHUP re-reads the environment and applies what can change live
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadConfig(cfg, urlService, logLevel, logger)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+	signal.Stop(hup)
+
+	drain(readiness, cfg.Server.PreShutdownDelay, logger)
+	bgCancel()
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer shutdownCancel()
@@ -121,14 +626,151 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Fatal("server forced to shutdown", zap.Error(err))
 	}
+	if pprofSrv != nil {
+		// A profile in progress is only diagnostics, no need to wait for it
+		_ = pprofSrv.Close()
+	}
+
+	// No more redirects can arrive, so this flush is the last one
+	if localClicks != nil {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := localClicks.Flush(flushCtx); err != nil {
+			logger.Error("final click flush failed, buffered clicks are lost", zap.Error(err))
+		}
+		flushCancel()
+	}
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := clickEventPipeline.Close(closeCtx); err != nil {
+		logger.Error("click event pipeline did not drain, queued events are lost", zap.Error(err))
+	}
+	closeCancel()
 
 	logger.Info("server exited properly")
 
 }
 
+// reloadConfig loads the configuration again and applies its reloadable
+// settings (config.ReloadableSettings); an invalid configuration changes
+// nothing. Other changed settings are logged and wait for a restart, which
+// is why running is the startup config and never updated.
+func reloadConfig(running *config.Config, urlService *service.URLService, logLevel zap.AtomicLevel, logger *zap.Logger) {
+	next, err := config.Load()
+	if err != nil {
+		logger.Error("config reload failed, keeping the running config", zap.Error(err))
+		return
+	}
+	level, err := zapcore.ParseLevel(next.Logging.Level)
+	if err != nil {
+		logger.Error("config reload failed, keeping the running config", zap.Error(err))
+		return
+	}
+	settings := urlService.CurrentSettings()
+	settings.DefaultTTL = next.URL.DefaultTTL
+	settings.CacheTTL = next.Redis.CacheTTL
+	settings.ClickRateLimit = next.ClickRate.Limit
+	settings.ClickRateWindow = next.ClickRate.Window
+	settings.ClickRateLimitPerIP = next.ClickRate.PerIP
+	if err := urlService.UpdateSettings(settings); err != nil {
+		logger.Error("config reload failed, keeping the running config", zap.Error(err))
+		return
+	}
+	logLevel.SetLevel(level)
+
+	if changed := config.UnreloadableChanges(running, next); len(changed) > 0 {
+		logger.Warn("config reload ignored settings that need a restart", zap.Strings("settings", changed))
+	}
+	logger.Info("config reloaded",
+		zap.String("log_level", level.String()),
+		zap.Int("click_rate_limit", settings.ClickRateLimit),
+		zap.Duration("click_rate_window", settings.ClickRateWindow),
+		zap.Duration("default_ttl", settings.DefaultTTL),
+		zap.Duration("cache_ttl", settings.CacheTTL),
+	)
+}
+
+// drain fails readiness and keeps serving for delay, so load balancers take
+// this instance out of rotation before Shutdown stops accepting connections
+func drain(readiness *handler.Readiness, delay time.Duration, logger *zap.Logger) {
+	readiness.StartDraining()
+	if delay <= 0 {
+		return
+	}
+	logger.Info("draining before shutdown", zap.Duration("delay", delay))
+	time.Sleep(delay)
+}
+
+// newPprofServer builds the profiling server on PprofAddr, nil when pprof
+// is disabled
+//
+// Learning: profiling gets its own listener instead of routes on the main
+// router. Profile requests then never reach the metrics middleware (a 30s
+// CPU profile would show up as a very slow request), the write timeout of
+// the main server can't cut a profile short, and the port can be kept off
+// the public load balancer.
+func newPprofServer(cfg config.ServerConfig) (*http.Server, error) {
+	if !cfg.PprofEnabled {
+		return nil, nil
+	}
+	if cfg.AdminToken == "" {
+		// Heap profiles and cmdline expose memory contents and secrets
+		return nil, errors.New("SERVER_PPROF_ENABLED needs ADMIN_TOKEN")
+	}
+	return &http.Server{
+		Addr:              cfg.PprofAddr,
+		Handler:           newPprofRouter(cfg.AdminToken),
+		ReadHeaderTimeout: 10 * time.Second,
+	}, nil
+}
+
+// newPprofRouter serves net/http/pprof under /debug/pprof, behind the
+// admin token
+func newPprofRouter(adminToken string) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(middleware.AdminAuth(adminToken))
+
+	debug := router.Group("/debug/pprof")
+	debug.GET("/", gin.WrapF(pprof.Index))
+	debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	debug.GET("/profile", gin.WrapF(pprof.Profile))
+	debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+	debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/trace", gin.WrapF(pprof.Trace))
+	// heap, goroutine, allocs, block, mutex, threadcreate; Index serves a
+	// named profile from the path
+	debug.GET("/:profile", gin.WrapF(pprof.Index))
+	return router
+}
+
+// newHTTPServer builds the http.Server with the configured connection tuning
+// With TLS, HTTP/2 is negotiated via ALPN automatically. Without TLS, clients
+// can only speak HTTP/2 if we wrap the handler in h2c (prior knowledge or
+// Upgrade: h2c); the gin router and middleware run unchanged behind it.
+func newHTTPServer(cfg config.ServerConfig, handler http.Handler) *http.Server {
+	if cfg.H2CEnabled && !cfg.TLSEnabled {
+		handler = h2c.NewHandler(handler, &http2.Server{
+			IdleTimeout: cfg.IdleTimeout,
+		})
+	}
+
+	srv := &http.Server{
+		Addr:           fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Handler:        handler,
+		ReadTimeout:    cfg.ReadTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		IdleTimeout:    cfg.IdleTimeout,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+	}
+	srv.SetKeepAlivesEnabled(cfg.KeepAlivesEnabled)
+
+	return srv
+}
+
 func setupRouter(
 	cfg *config.Config,
 	urlHandler *handler.URLHandler,
+	readiness *handler.Readiness,
+	scanCounter middleware.ScanCounter,
 	m *metrics.Metrics,
 	logger *zap.Logger,
 ) *gin.Engine {
@@ -138,31 +780,120 @@ func setupRouter(
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.New()
+	if err := configureTrustedProxies(router, cfg.Server.TrustedProxies); err != nil {
+		logger.Fatal("invalid trusted proxy list", zap.Error(err))
+	}
 
 	// Add middleware in the correct order
 	// Learning: Order matters! Recovery -> Logging -> Metrics -> Your handlers
-	router.Use(gin.Recovery()) // Panic recovery
+	router.Use(gin.Recovery())                  // Panic recovery
 	router.Use(middleware.MetricsMiddleware(m)) // Metrics tracking
+	router.Use(middleware.SecurityHeaders(middleware.SecurityHeadersConfig{
+		HSTS:                  cfg.Security.HSTSEnabled,
+		HSTSMaxAge:            cfg.Security.HSTSMaxAge,
+		HSTSIncludeSubdomains: cfg.Security.HSTSIncludeSubdomains,
+		NoSniff:               cfg.Security.NoSniff,
+		FrameOptions:          cfg.Security.FrameOptions,
+		ReferrerPolicy:        cfg.Security.ReferrerPolicy,
+		ContentSecurityPolicy: cfg.Security.ContentSecurityPolicy,
+	}))
+	router.Use(middleware.APIKeyAuth(cfg.Auth.APIKeys, cfg.Server.AdminToken))
 
 	// Prometheus metrics endpoint
 	// Learning: This exposes metrics in Prometheus format for scraping
 	// Example: http://localhost:8080/metrics
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
-	// Health check endpoint (no metrics needed for this)
+	// Health check endpoints (no metrics needed for these)
+	// /health is liveness and stays green while draining, /health/ready is readiness
 	router.GET("/health", urlHandler.HealthCheck)
+	router.GET("/health/ready", readiness.Ready)
+
+	// The bare domain, outside the redirect group: it is no code lookup
+	rootBehavior, err := handler.ParseRootBehavior(cfg.Server.RootBehavior, cfg.Server.RootRedirectURL)
+	if err != nil {
+		logger.Fatal("invalid root behavior", zap.Error(err))
+	}
+	router.GET("/", handler.Root(rootBehavior, cfg.Server.RootRedirectURL))
 
 	// URL shortener endpoints
 	redirectGroup := router.Group("/")
+	// Only redirects are guarded: that's the path an enumeration script walks
+	if cfg.ScanDetection.Enabled {
+		redirectGroup.Use(middleware.ScanDetector(scanCounter, middleware.ScanDetectorConfig{
+			Threshold:  int64(cfg.ScanDetection.Threshold),
+			Window:     cfg.ScanDetection.Window,
+			Action:     cfg.ScanDetection.Action,
+			DecoyDelay: cfg.ScanDetection.DecoyDelay,
+		}, m, logger))
+	}
 	redirectGroup.GET("/:shortCode", urlHandler.RedirectURL)
+	for _, prefix := range cfg.URL.AllowedPrefixes {
+		redirectGroup.GET("/"+prefix+"/:shortCode", urlHandler.RedirectPrefixed(prefix))
+	}
+
+	maintenance := middleware.NewMaintenance(cfg.Server.MaintenanceMode, cfg.Server.MaintenanceRetryAfter)
+	if maintenance.Enabled() {
+		logger.Warn("starting in maintenance mode, writes are disabled")
+	}
 
 	api := router.Group("/api/v1")
+	api.Use(maintenance.BlockWrites())
 	api.POST("/shorten", urlHandler.CreateURL)
+	api.POST("/aliases/reserve", urlHandler.ReserveAlias)
+	api.GET("/aliases/:alias/available", urlHandler.AliasAvailable)
+	api.POST("/urls/:shortCode/enable", urlHandler.EnableURL)
+	api.POST("/urls/:shortCode/disable", urlHandler.DisableURL)
+	api.POST("/urls/:shortCode/restore", urlHandler.RestoreURL)
+	api.DELETE("/urls/:shortCode", urlHandler.DeleteURL)
+	api.PUT("/urls/:shortCode/destination", urlHandler.UpdateURLDestination)
+	api.GET("/urls/:shortCode/history", urlHandler.GetURLHistory)
+	api.GET("/urls/:shortCode/aliases", urlHandler.GetURLAliases)
+	api.POST("/urls/:shortCode/aliases", urlHandler.AddURLAlias)
+	api.DELETE("/urls/:shortCode/aliases/:alias", urlHandler.RemoveURLAlias)
+	api.POST("/urls/:shortCode/regenerate", urlHandler.RegenerateURLCode)
+	api.POST("/import", urlHandler.ImportURLs)
+	api.POST("/bulk/enable", urlHandler.BulkEnableURLs)
+	api.POST("/bulk/disable", urlHandler.BulkDisableURLs)
+	api.GET("/stats", urlHandler.GetStats)
+	api.GET("/urls", urlHandler.ListURLs)
+	api.GET("/urls/by-destination", urlHandler.ListURLsByDestination)
+	api.GET("/urls/:shortCode", urlHandler.GetURLInfo)
+
+	// Operator endpoints, only mounted when an admin token is configured
+	if cfg.Server.AdminToken != "" {
+		admin := router.Group("/admin")
+		admin.Use(middleware.AdminAuth(cfg.Server.AdminToken))
+		admin.GET("/maintenance", maintenance.Status)
+		admin.PUT("/maintenance", maintenance.Toggle)
+
+		// Link administration lives under the API so maintenance mode
+		// pauses it like any other write
+		adminAPI := api.Group("/admin")
+		adminAPI.Use(middleware.AdminAuth(cfg.Server.AdminToken))
+		adminAPI.GET("/urls/:shortCode/expiry", urlHandler.GetURLExpiry)
+		adminAPI.PATCH("/urls/:shortCode/expiry", urlHandler.UpdateURLExpiry)
+
+		// A backup of every link, so it takes the admin token, not an API key
+		api.GET("/export", middleware.AdminAuth(cfg.Server.AdminToken), urlHandler.ExportURLs)
+	}
 
 	return router
 }
 
-func initLogger() *zap.Logger {
+// configureTrustedProxies limits which peers may set the client IP through
+// X-Forwarded-For / X-Real-IP. Gin trusts every peer by default, which lets any
+// client spoof its IP past rate limiting and scan detection, so an empty list
+// means trust nobody and use the connection's remote address.
+func configureTrustedProxies(router *gin.Engine, proxies []string) error {
+	if len(proxies) == 0 {
+		proxies = nil
+	}
+	return router.SetTrustedProxies(proxies)
+}
+
+// initLogger also returns the logger's level, which a config reload changes
+func initLogger() (*zap.Logger, zap.AtomicLevel) {
 	config := zap.Config{
 		Level:       zap.NewAtomicLevelAt(zapcore.InfoLevel),
 		Development: false,
@@ -194,7 +925,37 @@ func initLogger() *zap.Logger {
 		panic(fmt.Sprintf("failed to initialize logger: %v", err))
 	}
 
-	return logger
+	return logger, config.Level
+}
+
+func newKeyGenerator(cfg config.URLConfig, machineID int64) (keygen.Generator, error) {
+	switch cfg.CodeGenerator {
+	case "random":
+		return keygen.NewRandomGenerator(cfg.RandomCodeLength)
+	case "snowflake", "":
+		gen, err := keygen.NewSnowflakeGenerator(keygen.Config{
+			MachineID: machineID,
+			MinLength: cfg.MinCodeLength,
+			MaxLength: cfg.MaxCodeLength,
+			Lowercase: cfg.CaseInsensitiveCodes,
+		})
+		if errors.Is(err, keygen.ErrMaxLengthTooShort) {
+			// Case-insensitive codes are base36, 12 characters today
+			return nil, fmt.Errorf("%w, raise URL_MAX_CODE_LENGTH", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return gen, nil
+	default:
+		return nil, fmt.Errorf("unknown code generator %q", cfg.CodeGenerator)
+	}
+}
+
+// usesSnowflake reports whether codes come from the snowflake generator,
+// the only one that depends on a unique machine ID
+func usesSnowflake(cfg config.URLConfig) bool {
+	return cfg.CodeGenerator == "snowflake" || cfg.CodeGenerator == ""
 }
 
 func getMachineID() int64 {
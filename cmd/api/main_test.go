@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/config"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/handler"
+	"github.com/subhammahanty235/url-shortener/internal/middleware"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+)
+
+func TestH2CClientCompletesRedirect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+
+	router := gin.New()
+	router.Use(middleware.MetricsMiddleware(m))
+	router.GET("/:shortCode", func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, "https://example.com/"+c.Param("shortCode"))
+	})
+
+	srv := newHTTPServer(config.ServerConfig{
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      5 * time.Second,
+		IdleTimeout:       time.Minute,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		KeepAlivesEnabled: true,
+		H2CEnabled:        true,
+	}, router)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	// HTTP/2 with prior knowledge over a plain TCP connection
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get("http://" + ln.Addr().String() + "/abc123")
+	if err != nil {
+		t.Fatalf("h2c request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.ProtoMajor != 2 {
+		t.Errorf("response protocol = %s, want HTTP/2", resp.Proto)
+	}
+	if resp.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusMovedPermanently)
+	}
+	if got := resp.Header.Get("Location"); got != "https://example.com/abc123" {
+		t.Errorf("Location = %q, want %q", got, "https://example.com/abc123")
+	}
+
+	if got := testutil.ToFloat64(m.HTTPRequestsTotal.WithLabelValues("/:shortCode", "GET", "301")); got != 1 {
+		t.Errorf("http_requests_total for h2c redirect = %v, want 1", got)
+	}
+}
+
+func TestClientIPHonoursOnlyTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		want       string
+	}{
+		{"no proxies configured", nil, "198.51.100.9:4000", "198.51.100.9"},
+		{"untrusted peer", []string{"10.0.0.0/8"}, "198.51.100.9:4000", "198.51.100.9"},
+		{"trusted proxy", []string{"10.0.0.0/8"}, "10.1.2.3:4000", "203.0.113.50"},
+		{"trusted single IP", []string{"10.1.2.3"}, "10.1.2.3:4000", "203.0.113.50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			if err := configureTrustedProxies(router, tt.proxies); err != nil {
+				t.Fatalf("configureTrustedProxies() error = %v", err)
+			}
+			router.GET("/ip", func(c *gin.Context) {
+				c.String(http.StatusOK, c.ClientIP())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "203.0.113.50")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureTrustedProxiesRejectsGarbage(t *testing.T) {
+	if err := configureTrustedProxies(gin.New(), []string{"not-an-ip"}); err == nil {
+		t.Error("configureTrustedProxies() error = nil, want an error for an invalid entry")
+	}
+}
+
+func TestDrainFailsReadinessButNotLiveness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	readiness := handler.NewReadiness()
+	router := gin.New()
+	router.GET("/health", handler.NewURLHandler(nil, zap.NewNop(), nil).HealthCheck)
+	router.GET("/health/ready", readiness.Ready)
+
+	status := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if got := status("/health/ready"); got != http.StatusOK {
+		t.Fatalf("ready before drain = %d, want 200", got)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		drain(readiness, 200*time.Millisecond, zap.NewNop())
+		close(done)
+	}()
+
+	// Wait for the drain to start, then check both probes inside the window
+	deadline := time.Now().Add(time.Second)
+	for status("/health/ready") != http.StatusServiceUnavailable {
+		if time.Now().After(deadline) {
+			t.Fatal("readiness never flipped to 503")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := status("/health"); got != http.StatusOK {
+		t.Errorf("liveness during drain = %d, want 200", got)
+	}
+	select {
+	case <-done:
+		t.Error("drain returned before the pre-shutdown delay elapsed")
+	default:
+	}
+
+	<-done
+}
+
+func TestPprofServerOnlyWhenEnabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	if srv, err := newPprofServer(config.ServerConfig{AdminToken: "secret"}); srv != nil || err != nil {
+		t.Fatalf("disabled pprof = %v, %v, want no server", srv, err)
+	}
+	if _, err := newPprofServer(config.ServerConfig{PprofEnabled: true}); err == nil {
+		t.Fatal("pprof without an admin token was accepted")
+	}
+
+	srv, err := newPprofServer(config.ServerConfig{PprofEnabled: true, PprofAddr: "127.0.0.1:6060", AdminToken: "secret"})
+	if err != nil || srv == nil {
+		t.Fatalf("enabled pprof = %v, %v", srv, err)
+	}
+	get := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/goroutine?debug=1", "/debug/pprof/heap"} {
+		if got := get(path, "secret"); got != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", path, got)
+		}
+	}
+	if got := get("/debug/pprof/", ""); got != http.StatusUnauthorized {
+		t.Errorf("GET /debug/pprof/ without the token = %d, want 401", got)
+	}
+}
+
+func TestAdminRoutesAcceptTheAdminToken(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+	urlRepo := memory.NewURLRepository()
+	if err := urlRepo.Create(context.Background(), &domain.URL{ShortURL: "abc123", OriginalURL: "https://example.com"}); err != nil {
+		t.Fatalf("failed to seed: %v", err)
+	}
+	svc := service.NewURLService(urlRepo, memory.NewCacheRepository(time.Hour), keyGen, nil, urlRepo, zap.NewNop(), m, service.URLServiceConfig{})
+
+	cfg := &config.Config{}
+	cfg.Server.AdminToken = "admin-secret"
+	cfg.Auth.APIKeys = map[string]string{"k-alice": "alice"}
+	router := setupRouter(cfg, handler.NewURLHandler(svc, zap.NewNop(), m), handler.NewReadiness(), memory.NewScanCounter(), m, zap.NewNop())
+
+	status := func(path, header, value string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The global API key check must not turn the admin token away first
+	for _, path := range []string{"/admin/maintenance", "/api/v1/admin/urls/abc123/expiry"} {
+		if got := status(path, "Authorization", "Bearer admin-secret"); got != http.StatusOK {
+			t.Errorf("GET %s with the admin token = %d, want 200", path, got)
+		}
+		if got := status(path, "", ""); got != http.StatusUnauthorized {
+			t.Errorf("GET %s anonymously = %d, want 401", path, got)
+		}
+		if got := status(path, middleware.APIKeyHeader, "k-alice"); got != http.StatusUnauthorized {
+			t.Errorf("GET %s with an API key = %d, want 401", path, got)
+		}
+	}
+}
+
+func TestReloadKeepsDefaultTTLWithinTheRunningMaxTTL(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+	urlRepo := memory.NewURLRepository()
+	svc := service.NewURLService(urlRepo, memory.NewCacheRepository(time.Hour), keyGen, nil, urlRepo, zap.NewNop(), m, service.URLServiceConfig{
+		DefaultTTL: 12 * time.Hour,
+		MaxTTL:     24 * time.Hour,
+	})
+
+	// The new config is valid on its own, but MaxTTL only changes on restart
+	t.Setenv("URL_DEFAULT_TTL", "48h")
+	t.Setenv("URL_MAX_TTL", "72h")
+	reloadConfig(&config.Config{}, svc, zap.NewAtomicLevel(), zap.NewNop())
+	if got := svc.CurrentSettings().DefaultTTL; got != 12*time.Hour {
+		t.Errorf("DefaultTTL after reload = %s, want the running 12h", got)
+	}
+
+	t.Setenv("URL_DEFAULT_TTL", "24h")
+	reloadConfig(&config.Config{}, svc, zap.NewAtomicLevel(), zap.NewNop())
+	if got := svc.CurrentSettings().DefaultTTL; got != 24*time.Hour {
+		t.Errorf("DefaultTTL after reload = %s, want 24h", got)
+	}
+}
@@ -2,18 +2,33 @@ package config
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Server    ServerConfig
-	Database  DatabaseConfig
-	Redis     RedisConfig
-	RateLimit RateLimitConfig
-	URL       URLConfig
-	Logging   LoggingConfig
+	Server        ServerConfig
+	Storage       StorageConfig
+	Database      DatabaseConfig
+	Redis         RedisConfig
+	Memcached     MemcachedConfig
+	RateLimit     RateLimitConfig
+	ScanDetection ScanDetectionConfig
+	Security      SecurityHeadersConfig
+	ClickRate     ClickRateConfig
+	URL           URLConfig
+	Logging       LoggingConfig
+	Webhook       WebhookConfig
+	Analytics     AnalyticsConfig
+	Metadata      MetadataConfig
+	Auth          AuthConfig
+
+	Expand ExpandConfig
+	Audit  AuditConfig
+	Chaos  ChaosConfig
 }
 
 type ServerConfig struct {
@@ -26,6 +41,86 @@ type ServerConfig struct {
 	TLSEnabled      bool
 	TLSCertFile     string
 	TLSKeyFile      string
+
+	// Connection tuning for high-throughput redirect workloads
+	MaxHeaderBytes    int
+	IdleTimeout       time.Duration
+	KeepAlivesEnabled bool
+	H2CEnabled        bool // HTTP/2 over cleartext, only used when TLS is off
+
+	// IPs/CIDRs of load balancers allowed to set X-Forwarded-For
+	// Empty means no proxy is trusted and the client IP is the socket peer
+	TrustedProxies []string
+
+	// How long to keep serving with readiness failing before shutting down,
+	// so load balancers notice and stop routing here first
+	PreShutdownDelay time.Duration
+
+	// Maintenance mode rejects writes with 503 while redirects keep working
+	// Can be toggled at runtime through /admin/maintenance
+	MaintenanceMode       bool
+	MaintenanceRetryAfter time.Duration
+
+	// Bearer token for /admin endpoints, which are not mounted when empty
+	AdminToken string
+
+	// net/http/pprof on its own listener for profiling in staging, off by
+	// default; it requires the admin token like the other operator endpoints
+	PprofEnabled bool
+	PprofAddr    string
+
+	// What GET / answers: "not_found", "landing" (a small status JSON) or
+	// "redirect" to RootRedirectURL, e.g. the marketing site
+	RootBehavior    string
+	RootRedirectURL string
+}
+
+// Storage backends selectable with STORAGE_BACKEND
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory" // no persistence, for local dev and tests
+	StorageSQLite   = "sqlite" // one file, no Postgres or Redis, for single-node deploys
+)
+
+// Cache backends selectable with CACHE_BACKEND, for the postgres storage
+// backend; memory and sqlite always cache in process.
+// CacheMemcached moves only the link cache: click counters, click and scan
+// rate limits and the machine ID lease live in Redis either way, so the
+// postgres backend always needs REDIS_HOST.
+const (
+	CacheRedis     = "redis"
+	CacheMemcached = "memcached"
+)
+
+type StorageConfig struct {
+	Backend string
+
+	// SQLitePath is the database file for the sqlite backend
+	SQLitePath string
+
+	// CacheBackend holds cached links, CacheRedis or CacheMemcached
+	CacheBackend string
+}
+
+// Audit sinks selectable with AUDIT_SINK
+const (
+	AuditSinkLog   = "log"   // structured lines from the "audit" logger
+	AuditSinkTable = "table" // rows in the storage backend's audit_log table
+	AuditSinkOff   = "off"
+)
+
+// AuditConfig decides where the record of mutating operations goes
+type AuditConfig struct {
+	Sink string
+}
+
+// ChaosConfig injects faults into the cache and database calls, for
+// resilience testing in staging. Only binaries built with -tags chaos act on it.
+type ChaosConfig struct {
+	CacheLatency   time.Duration
+	CacheErrorRate float64
+	DBLatency      time.Duration
+	DBErrorRate    float64
 }
 
 type DatabaseConfig struct {
@@ -39,6 +134,26 @@ type DatabaseConfig struct {
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
+	StatsInterval   time.Duration
+
+	// Circuit breaker around repository calls
+	BreakerMaxFailures      int
+	BreakerOpenTimeout      time.Duration
+	BreakerHalfOpenRequests int
+
+	// Retries for transient errors on idempotent reads and rolled-back inserts
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+
+	// Queries slower than this are logged with their operation, 0 disables
+	SlowQueryThreshold time.Duration
+
+	// Read replicas for redirect lookups, as full DSNs; writes and every
+	// other query stay on the primary. A code written by this instance is
+	// read from the primary for ReplicaLagWindow afterwards.
+	ReadReplicaDSNs  []string
+	ReplicaLagWindow time.Duration
 }
 
 type RedisConfig struct {
@@ -52,6 +167,76 @@ type RedisConfig struct {
 	DialTimeout  time.Duration
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+
+	// GetTimeout bounds each cache read on its own, shorter than ReadTimeout
+	// so a slow Redis sends redirects to the DB quickly; 0 disables it
+	GetTimeout time.Duration
+
+	// How often pool stats are published to Prometheus
+	StatsInterval time.Duration
+
+	// Circuit breaker around cache calls
+	BreakerMaxFailures int
+	BreakerOpenTimeout time.Duration
+
+	// How cached URLs are encoded: "json" (readable), "gob" or "msgpack" (smallest)
+	CacheFormat string
+
+	// Redirects read a "original_url|expires_at" string instead of the full
+	// cached URL; the full entry is cached only when metadata is requested
+	CompactRedirectCache bool
+
+	// Namespace for every Redis key: cache entries, click counters, rate
+	// limits, scan counts and machine ID leases ("tenant-a:" ->
+	// "tenant-a:url:abc123"), empty keeps the bare keys
+	KeyPrefix string
+
+	// Entries read HotKeyThreshold times per HotKeyWindow are copied to
+	// HotKeyShards keys on different cluster slots; 0 shards keeps one key each
+	HotKeyShards    int
+	HotKeyThreshold int
+	HotKeyWindow    time.Duration
+
+	// When created links are cached: "write-through", "write-around" or "lazy"
+	CacheWritePolicy string
+
+	// Process-local LRU in front of Redis, off when L1Size is 0
+	// L1TTL bounds how long other instances serve a link after it changes
+	L1Size int
+	L1TTL  time.Duration
+
+	// How long links stay cached after a miss or a create, reloadable
+	CacheTTL time.Duration
+
+	// Fraction of cache hits compared with the database in the background,
+	// e.g. 0.001; a stale entry is counted in cache_divergence_total and
+	// evicted. 0 disables the check.
+	ConsistencyCheckRate float64
+}
+
+// MemcachedConfig is used when CACHE_BACKEND=memcached
+// Entry format, TTLs, write policy and the L1 cache are shared with Redis
+// (REDIS_CACHE_FORMAT, REDIS_CACHE_TTL, CACHE_L1_SIZE, ...), and Redis is
+// still required for everything but the link cache, see CacheMemcached
+type MemcachedConfig struct {
+	// "host:port" of every server, keys are spread over them by hash
+	Servers []string
+
+	// Timeout bounds dialing and each call; GetTimeout, if shorter, bounds
+	// cache reads so redirects fall back to the DB quickly. Reads use their
+	// own connections for that, gomemcache timeouts are per client
+	Timeout    time.Duration
+	GetTimeout time.Duration
+
+	// Connections kept open per server between calls
+	MaxIdleConns int
+
+	// Namespace for cache keys, as REDIS_KEY_PREFIX
+	KeyPrefix string
+
+	// Circuit breaker around cache calls
+	BreakerMaxFailures int
+	BreakerOpenTimeout time.Duration
 }
 
 type RateLimitConfig struct {
@@ -61,12 +246,219 @@ type RateLimitConfig struct {
 	CleanupInterval time.Duration
 }
 
+// ClickRateConfig limits redirects per short code to blunt click fraud
+type ClickRateConfig struct {
+	Enabled bool          // off also ignores per-link limits
+	Limit   int           // default redirects per link per window, 0 = only per-link limits
+	Window  time.Duration // fixed counting window
+	PerIP   bool          // count every visitor IP separately
+}
+
+// ScanDetectionConfig flags clients producing many 404s on redirects as scanners
+type ScanDetectionConfig struct {
+	Enabled    bool
+	Threshold  int           // 404s per window before a client is flagged
+	Window     time.Duration // counting window, also how long a flag lasts
+	Action     string        // "block" (429) or "delay" (tarpit)
+	DecoyDelay time.Duration // delay applied by the "delay" action
+}
+
+// SecurityHeadersConfig toggles the hardening headers added to every response
+// Empty strings turn a header off; HSTS is only sent on TLS connections
+type SecurityHeadersConfig struct {
+	HSTSEnabled           bool
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	NoSniff               bool
+	FrameOptions          string
+	ReferrerPolicy        string
+	ContentSecurityPolicy string
+}
+
 type URLConfig struct {
+	// DefaultTTL applies to links created without expires_in; 0 (e.g.
+	// URL_DEFAULT_TTL=0s) makes them permanent by default. It must not be
+	// longer than MaxTTL, which caps explicit expires_in
 	DefaultTTL    time.Duration
 	MaxTTL        time.Duration
 	MinCodeLength int
 	MaxCodeLength int
 	AllowCustom   bool
+
+	// Whether clients may opt out of expiry with never_expires / expires_in: -1
+	AllowPermanent bool
+
+	// Longest original_url accepted, in bytes
+	MaxURLLength int
+
+	// Most of a response body read when following someone else's links
+	// (GET /api/v1/expand); larger responses end the chain
+	MaxFetchBytes int64
+
+	// Code generator: "snowflake" (ordered, never collides) or "random"
+	// (crypto/rand, hides creation order, collisions retried against the DB)
+	CodeGenerator    string
+	RandomCodeLength int
+
+	// Snowflake codes generated ahead of time to absorb bursts, 0 disables
+	// the buffer (codes are then no longer issued in creation order)
+	CodeBufferSize int
+
+	// Snowflake machine IDs are leased in Redis so two instances can't share
+	// one; with auto-assign a taken ID is swapped for a free one instead of
+	// refusing to start
+	MachineIDLock       bool
+	MachineIDAutoAssign bool
+	MachineIDLockTTL    time.Duration
+
+	// Lowercase codes on store and lookup so "AbC" and "abc" are the same link
+	// Snowflake codes are then base36, 12 characters today, so startup fails
+	// unless URL_MAX_CODE_LENGTH is raised to fit them
+	CaseInsensitiveCodes bool
+
+	// Characters stripped from the end of a code on redirect, e.g. ".,)"
+	// from auto-linked chat messages; empty (the default) disables it
+	TrailingChars string
+
+	// HMAC key for signed links, which are refused when it is empty
+	SigningKey string
+
+	// How long /api/v1/stats results are reused before re-querying
+	StatsCacheTTL time.Duration
+
+	// Alias reservations: default and maximum hold time, and how often
+	// lapsed ones are deleted
+	ReservationTTL    time.Duration
+	MaxReservationTTL time.Duration
+	CleanupInterval   time.Duration
+
+	// How long a deleted link can be restored before it is purged
+	DeleteGracePeriod time.Duration
+
+	// AliasClaimTTL guards custom alias inserts with a cache claim, 0 disables it
+	AliasClaimTTL time.Duration
+
+	// Alias availability checks allowed per client IP per minute, 0 = unlimited
+	AliasCheckRateLimit int
+
+	// Live links allowed per API key owner (0 = unlimited) and per-user
+	// overrides, from URL_USER_LINK_QUOTAS="alice:1000,bob:0"
+	MaxLinksPerUser int
+	UserLinkQuotas  map[string]int
+
+	// Destination domain filtering, entries may use "*.acme.com" wildcards
+	// The blocklist wins; an empty allowlist allows every domain
+	AllowedDestinationDomains []string
+	BlockedDestinationDomains []string
+
+	// Destination URL schemes accepted on create, e.g. "http,https,mailto,tel"
+	// javascript, vbscript, data and file are dangerous and logged loudly
+	AllowedSchemes []string
+
+	// Which value wins when a passthrough_query link's destination and the
+	// click both set a parameter: "stored" or "incoming"
+	PassthroughPrecedence string
+
+	// What a visitor of an expired link gets: "gone" (410), "not_found"
+	// (404, hides that the link existed) or "redirect" to
+	// ExpiredRedirectURL, e.g. a "this link has expired" page
+	ExpiredBehavior    string
+	ExpiredRedirectURL string
+
+	// Path segments links may be created under ("/news/abc123"), e.g.
+	// URL_ALLOWED_PREFIXES="news,docs"; empty disables prefixed links
+	AllowedPrefixes []string
+
+	// Surfaces a create may name in X-Client-Source, e.g. "web,cli,api,extension"
+	AllowedSources []string
+
+	// What a link's old code does after POST /urls/:code/regenerate:
+	// "retire" (410 Gone, never reused) or "alias" (keeps redirecting)
+	RegenerateOldCode string
+
+	// What a short code must be unique among: "global", or "prefix" to let
+	// /news/sale and /sports/sale be different links
+	AliasScope string
+
+	// Send Link: <destination>; rel="canonical" on redirects, so crawlers
+	// credit the destination rather than the short link
+	CanonicalLinkHeader bool
+}
+
+// AuthConfig holds the API keys accepted by the optional auth middleware
+type AuthConfig struct {
+	// APIKeys maps key -> owning user ID, from AUTH_API_KEYS="key1:alice,key2:bob"
+	APIKeys map[string]string
+}
+
+// AnalyticsConfig controls click event enrichment
+type AnalyticsConfig struct {
+	// Best-effort country from Accept-Language when GeoIP has no answer
+	InferCountryFromLanguage bool
+	// GeoIPCIDRFile is a "cidr,country" table for locating visitors, used by
+	// links with country destinations; empty leaves only the inference above
+	GeoIPCIDRFile string
+
+	// Redirect clicks are buffered in Redis and reconciled into Postgres
+	ClickFlushInterval  time.Duration
+	ClickFlushBatchSize int
+
+	// ClickLocalBuffer counts clicks in process memory instead of Redis and
+	// flushes them straight to Postgres every ClickFlushInterval; counts
+	// survive a graceful shutdown but not a crash
+	ClickLocalBuffer bool
+	ClickShards      int
+
+	// Click events (analytics rows) are queued and inserted in batches of
+	// ClickEventBatchSize, or every ClickEventFlushInterval; a full queue
+	// drops its oldest event (drop_oldest) or makes the request wait (block)
+	ClickEventQueueSize     int
+	ClickEventWorkers       int
+	ClickEventBatchSize     int
+	ClickEventFlushInterval time.Duration
+	ClickEventOverflow      string
+
+	// Every redirect is also POSTed, in batches, to an external analytics
+	// endpoint (Segment/PostHog-style); disabled when SinkURL is empty
+	SinkURL           string
+	SinkAPIKey        string
+	SinkBatchSize     int
+	SinkFlushInterval time.Duration
+	SinkQueueSize     int
+	SinkTimeout       time.Duration
+}
+
+// MetadataConfig controls fetching link previews (title, OpenGraph tags)
+type MetadataConfig struct {
+	Enabled   bool
+	Timeout   time.Duration // per fetch, robots.txt included
+	MaxBytes  int64         // most of a page read
+	UserAgent string
+	QueueSize int
+}
+
+// ExpandConfig controls GET /api/v1/expand, which follows other sites'
+// redirect chains on a caller's behalf
+type ExpandConfig struct {
+	Enabled   bool
+	Timeout   time.Duration // whole chain, every hop included
+	MaxHops   int
+	UserAgent string
+}
+
+// WebhookConfig controls outbound link lifecycle events, disabled when URL is empty
+type WebhookConfig struct {
+	URL          string
+	Secret       string
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+	QueueSize    int
+
+	// How long before a link expires its url.expiring reminder goes out,
+	// 0 sends none; expiries are looked for every ExpiryReminderInterval
+	ExpiryReminder         time.Duration
+	ExpiryReminderInterval time.Duration
 }
 
 type LoggingConfig struct {
@@ -88,7 +480,7 @@ func (r RedisConfig) GetRedisAddr() string {
 }
 
 func Load() (*Config, error) {
-	return &Config{
+	cfg := &Config{
 		Server: ServerConfig{
 			Host:            getEnv("SERVER_HOST", "0.0.0.0"),
 			Port:            getEnvAsInt("SERVER_PORT", 8080),
@@ -99,6 +491,33 @@ func Load() (*Config, error) {
 			TLSEnabled:      getEnvAsBool("TLS_ENABLED", false),
 			TLSCertFile:     getEnv("TLS_CERT_FILE", ""),
 			TLSKeyFile:      getEnv("TLS_KEY_FILE", ""),
+
+			MaxHeaderBytes:    getEnvAsInt("SERVER_MAX_HEADER_BYTES", http.DefaultMaxHeaderBytes),
+			IdleTimeout:       getEnvAsDuration("SERVER_IDLE_TIMEOUT", 120*time.Second),
+			KeepAlivesEnabled: getEnvAsBool("SERVER_KEEP_ALIVES_ENABLED", true),
+			H2CEnabled:        getEnvAsBool("SERVER_H2C_ENABLED", false),
+
+			TrustedProxies: getEnvAsSlice("SERVER_TRUSTED_PROXIES", nil),
+
+			PreShutdownDelay: getEnvAsDuration("SERVER_PRE_SHUTDOWN_DELAY", 5*time.Second),
+
+			MaintenanceMode:       getEnvAsBool("MAINTENANCE_MODE", false),
+			MaintenanceRetryAfter: getEnvAsDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute),
+
+			AdminToken: getEnv("ADMIN_TOKEN", ""),
+
+			PprofEnabled: getEnvAsBool("SERVER_PPROF_ENABLED", false),
+			PprofAddr:    getEnv("SERVER_PPROF_ADDR", "127.0.0.1:6060"),
+
+			RootBehavior:    getEnv("SERVER_ROOT_BEHAVIOR", "not_found"),
+			RootRedirectURL: getEnv("SERVER_ROOT_REDIRECT_URL", ""),
+		},
+		Storage: StorageConfig{
+			Backend: getEnv("STORAGE_BACKEND", StoragePostgres),
+
+			SQLitePath: getEnv("SQLITE_PATH", "url-shortener.db"),
+
+			CacheBackend: getEnv("CACHE_BACKEND", CacheRedis),
 		},
 		Database: DatabaseConfig{
 			Host:            getEnv("DB_HOST", "localhost"),
@@ -111,6 +530,20 @@ func Load() (*Config, error) {
 			MaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
 			ConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
 			ConnMaxIdleTime: getEnvAsDuration("DB_CONN_MAX_IDLE_TIME", 1*time.Minute),
+			StatsInterval:   getEnvAsDuration("DB_STATS_INTERVAL", 15*time.Second),
+
+			BreakerMaxFailures:      getEnvAsInt("DB_BREAKER_MAX_FAILURES", 5),
+			BreakerOpenTimeout:      getEnvAsDuration("DB_BREAKER_OPEN_TIMEOUT", 10*time.Second),
+			BreakerHalfOpenRequests: getEnvAsInt("DB_BREAKER_HALF_OPEN_REQUESTS", 1),
+
+			RetryMaxAttempts: getEnvAsInt("DB_RETRY_MAX_ATTEMPTS", 3),
+			RetryBaseDelay:   getEnvAsDuration("DB_RETRY_BASE_DELAY", 20*time.Millisecond),
+			RetryMaxDelay:    getEnvAsDuration("DB_RETRY_MAX_DELAY", 200*time.Millisecond),
+
+			SlowQueryThreshold: getEnvAsDuration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+
+			ReadReplicaDSNs:  getEnvAsSlice("DB_READ_REPLICA_DSNS", nil),
+			ReplicaLagWindow: getEnvAsDuration("DB_REPLICA_LAG_WINDOW", 5*time.Second),
 		},
 		Redis: RedisConfig{
 			Host:         getEnv("REDIS_HOST", "localhost"),
@@ -123,6 +556,42 @@ func Load() (*Config, error) {
 			DialTimeout:  getEnvAsDuration("REDIS_DIAL_TIMEOUT", 5*time.Second),
 			ReadTimeout:  getEnvAsDuration("REDIS_READ_TIMEOUT", 3*time.Second),
 			WriteTimeout: getEnvAsDuration("REDIS_WRITE_TIMEOUT", 3*time.Second),
+
+			GetTimeout: getEnvAsDuration("REDIS_GET_TIMEOUT", 100*time.Millisecond),
+
+			ConsistencyCheckRate: getEnvAsFloat("REDIS_CONSISTENCY_CHECK_RATE", 0),
+
+			StatsInterval: getEnvAsDuration("REDIS_STATS_INTERVAL", 15*time.Second),
+
+			BreakerMaxFailures: getEnvAsInt("REDIS_BREAKER_MAX_FAILURES", 5),
+			BreakerOpenTimeout: getEnvAsDuration("REDIS_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+
+			CacheFormat: getEnv("REDIS_CACHE_FORMAT", "json"),
+
+			CompactRedirectCache: getEnvAsBool("REDIS_COMPACT_REDIRECT_CACHE", false),
+
+			KeyPrefix: getEnv("REDIS_KEY_PREFIX", ""),
+
+			HotKeyShards:    getEnvAsInt("REDIS_HOT_KEY_SHARDS", 0),
+			HotKeyThreshold: getEnvAsInt("REDIS_HOT_KEY_THRESHOLD", 1000),
+			HotKeyWindow:    getEnvAsDuration("REDIS_HOT_KEY_WINDOW", 10*time.Second),
+
+			CacheWritePolicy: getEnv("REDIS_CACHE_WRITE_POLICY", "write-through"),
+
+			L1Size: getEnvAsInt("CACHE_L1_SIZE", 0),
+			L1TTL:  getEnvAsDuration("CACHE_L1_TTL", 5*time.Second),
+
+			CacheTTL: getEnvAsDuration("REDIS_CACHE_TTL", 24*time.Hour),
+		},
+		Memcached: MemcachedConfig{
+			Servers:      getEnvAsSlice("MEMCACHED_SERVERS", []string{"localhost:11211"}),
+			Timeout:      getEnvAsDuration("MEMCACHED_TIMEOUT", 500*time.Millisecond),
+			GetTimeout:   getEnvAsDuration("MEMCACHED_GET_TIMEOUT", 100*time.Millisecond),
+			MaxIdleConns: getEnvAsInt("MEMCACHED_MAX_IDLE_CONNS", 10),
+			KeyPrefix:    getEnv("MEMCACHED_KEY_PREFIX", ""),
+
+			BreakerMaxFailures: getEnvAsInt("MEMCACHED_BREAKER_MAX_FAILURES", 5),
+			BreakerOpenTimeout: getEnvAsDuration("MEMCACHED_BREAKER_OPEN_TIMEOUT", 30*time.Second),
 		},
 		RateLimit: RateLimitConfig{
 			Enabled:         getEnvAsBool("RATE_LIMIT_ENABLED", true),
@@ -130,19 +599,230 @@ func Load() (*Config, error) {
 			BurstSize:       getEnvAsInt("RATE_LIMIT_BURST_SIZE", 10),
 			CleanupInterval: getEnvAsDuration("RATE_LIMIT_CLEANUP_INTERVAL", 1*time.Minute),
 		},
+		ScanDetection: ScanDetectionConfig{
+			Enabled:    getEnvAsBool("SCAN_DETECTION_ENABLED", true),
+			Threshold:  getEnvAsInt("SCAN_DETECTION_THRESHOLD", 20),
+			Window:     getEnvAsDuration("SCAN_DETECTION_WINDOW", 1*time.Minute),
+			Action:     getEnv("SCAN_DETECTION_ACTION", "block"),
+			DecoyDelay: getEnvAsDuration("SCAN_DETECTION_DECOY_DELAY", 2*time.Second),
+		},
+		Security: SecurityHeadersConfig{
+			HSTSEnabled:           getEnvAsBool("SECURITY_HSTS_ENABLED", true),
+			HSTSMaxAge:            getEnvAsDuration("SECURITY_HSTS_MAX_AGE", 180*24*time.Hour),
+			HSTSIncludeSubdomains: getEnvAsBool("SECURITY_HSTS_INCLUDE_SUBDOMAINS", false),
+			NoSniff:               getEnvAsBool("SECURITY_NOSNIFF", true),
+			FrameOptions:          getEnv("SECURITY_FRAME_OPTIONS", "DENY"),
+			ReferrerPolicy:        getEnv("SECURITY_REFERRER_POLICY", "strict-origin-when-cross-origin"),
+			ContentSecurityPolicy: getEnv("SECURITY_CONTENT_SECURITY_POLICY", "default-src 'none'; frame-ancestors 'none'"),
+		},
+		ClickRate: ClickRateConfig{
+			Enabled: getEnvAsBool("CLICK_RATE_LIMIT_ENABLED", true),
+			Limit:   getEnvAsInt("CLICK_RATE_LIMIT", 0),
+			Window:  getEnvAsDuration("CLICK_RATE_LIMIT_WINDOW", 1*time.Minute),
+			PerIP:   getEnvAsBool("CLICK_RATE_LIMIT_PER_IP", false),
+		},
 		URL: URLConfig{
 			DefaultTTL:    getEnvAsDuration("URL_DEFAULT_TTL", 24*time.Hour*365), // 1 year
 			MaxTTL:        getEnvAsDuration("URL_MAX_TTL", 24*time.Hour*365*5),   // 5 years
 			MinCodeLength: getEnvAsInt("URL_MIN_CODE_LENGTH", 6),
 			MaxCodeLength: getEnvAsInt("URL_MAX_CODE_LENGTH", 10),
 			AllowCustom:   getEnvAsBool("URL_ALLOW_CUSTOM", true),
+
+			AllowPermanent: getEnvAsBool("URL_ALLOW_PERMANENT", false),
+			MaxURLLength:   getEnvAsInt("URL_MAX_URL_LENGTH", 2048),
+
+			MaxFetchBytes: int64(getEnvAsInt("URL_MAX_FETCH_BYTES", 64*1024)),
+
+			CodeGenerator:    getEnv("URL_CODE_GENERATOR", "snowflake"),
+			RandomCodeLength: getEnvAsInt("URL_RANDOM_CODE_LENGTH", 8),
+
+			CodeBufferSize: getEnvAsInt("URL_CODE_BUFFER_SIZE", 0),
+
+			MachineIDLock:       getEnvAsBool("MACHINE_ID_LOCK", true),
+			MachineIDAutoAssign: getEnvAsBool("MACHINE_ID_AUTO_ASSIGN", false),
+			MachineIDLockTTL:    getEnvAsDuration("MACHINE_ID_LOCK_TTL", 30*time.Second),
+
+			CaseInsensitiveCodes: getEnvAsBool("URL_CASE_INSENSITIVE_CODES", false),
+
+			TrailingChars: getEnv("URL_TRAILING_CHARS", ""),
+
+			SigningKey: getEnv("URL_SIGNING_KEY", ""),
+
+			StatsCacheTTL: getEnvAsDuration("URL_STATS_CACHE_TTL", 30*time.Second),
+
+			ReservationTTL:    getEnvAsDuration("URL_RESERVATION_TTL", 24*time.Hour),
+			MaxReservationTTL: getEnvAsDuration("URL_MAX_RESERVATION_TTL", 30*24*time.Hour),
+			CleanupInterval:   getEnvAsDuration("URL_CLEANUP_INTERVAL", 5*time.Minute),
+
+			DeleteGracePeriod: getEnvAsDuration("URL_DELETE_GRACE_PERIOD", 24*time.Hour),
+
+			AliasClaimTTL: getEnvAsDuration("URL_ALIAS_CLAIM_TTL", 10*time.Second),
+
+			AliasCheckRateLimit: getEnvAsInt("URL_ALIAS_CHECK_RATE_LIMIT", 30),
+
+			MaxLinksPerUser: getEnvAsInt("URL_MAX_LINKS_PER_USER", 0),
+
+			AllowedDestinationDomains: getEnvAsSlice("URL_ALLOWED_DESTINATION_DOMAINS", nil),
+			BlockedDestinationDomains: getEnvAsSlice("URL_BLOCKED_DESTINATION_DOMAINS", nil),
+
+			AllowedSchemes: getEnvAsSlice("URL_ALLOWED_SCHEMES", []string{"http", "https"}),
+
+			PassthroughPrecedence: getEnv("URL_PASSTHROUGH_PRECEDENCE", "stored"),
+
+			ExpiredBehavior:    getEnv("URL_EXPIRED_BEHAVIOR", "gone"),
+			ExpiredRedirectURL: getEnv("URL_EXPIRED_REDIRECT_URL", ""),
+
+			AllowedPrefixes: getEnvAsSlice("URL_ALLOWED_PREFIXES", nil),
+
+			AllowedSources: getEnvAsSlice("URL_ALLOWED_SOURCES", []string{"web", "cli", "api", "extension"}),
+
+			RegenerateOldCode: getEnv("URL_REGENERATE_OLD_CODE", "retire"),
+			AliasScope:        getEnv("URL_ALIAS_SCOPE", "global"),
+
+			CanonicalLinkHeader: getEnvAsBool("URL_CANONICAL_LINK_HEADER", false),
 		},
 		Logging: LoggingConfig{
 			Level:      getEnv("LOG_LEVEL", "info"),
 			Format:     getEnv("LOG_FORMAT", "json"),
 			OutputPath: getEnv("LOG_OUTPUT", "stdout"),
 		},
-	}, nil
+		Analytics: AnalyticsConfig{
+			InferCountryFromLanguage: getEnvAsBool("ANALYTICS_INFER_COUNTRY_FROM_LANGUAGE", false),
+			GeoIPCIDRFile:            getEnv("ANALYTICS_GEOIP_CIDR_FILE", ""),
+
+			ClickFlushInterval:  getEnvAsDuration("ANALYTICS_CLICK_FLUSH_INTERVAL", 10*time.Second),
+			ClickFlushBatchSize: getEnvAsInt("ANALYTICS_CLICK_FLUSH_BATCH_SIZE", 500),
+
+			ClickLocalBuffer: getEnvAsBool("ANALYTICS_CLICK_LOCAL_BUFFER", false),
+			ClickShards:      getEnvAsInt("ANALYTICS_CLICK_SHARDS", 32),
+
+			ClickEventQueueSize:     getEnvAsInt("ANALYTICS_CLICK_EVENT_QUEUE_SIZE", 10000),
+			ClickEventWorkers:       getEnvAsInt("ANALYTICS_CLICK_EVENT_WORKERS", 2),
+			ClickEventBatchSize:     getEnvAsInt("ANALYTICS_CLICK_EVENT_BATCH_SIZE", 100),
+			ClickEventFlushInterval: getEnvAsDuration("ANALYTICS_CLICK_EVENT_FLUSH_INTERVAL", 200*time.Millisecond),
+			ClickEventOverflow:      getEnv("ANALYTICS_CLICK_EVENT_OVERFLOW", "drop_oldest"),
+
+			SinkURL:           getEnv("ANALYTICS_SINK_URL", ""),
+			SinkAPIKey:        getEnv("ANALYTICS_SINK_API_KEY", ""),
+			SinkBatchSize:     getEnvAsInt("ANALYTICS_SINK_BATCH_SIZE", 100),
+			SinkFlushInterval: getEnvAsDuration("ANALYTICS_SINK_FLUSH_INTERVAL", 5*time.Second),
+			SinkQueueSize:     getEnvAsInt("ANALYTICS_SINK_QUEUE_SIZE", 10000),
+			SinkTimeout:       getEnvAsDuration("ANALYTICS_SINK_TIMEOUT", 5*time.Second),
+		},
+		Metadata: MetadataConfig{
+			Enabled:   getEnvAsBool("LINK_METADATA_ENABLED", true),
+			Timeout:   getEnvAsDuration("LINK_METADATA_TIMEOUT", 5*time.Second),
+			MaxBytes:  int64(getEnvAsInt("LINK_METADATA_MAX_BYTES", 512*1024)),
+			UserAgent: getEnv("LINK_METADATA_USER_AGENT", "url-shortener-preview/1.0"),
+			QueueSize: getEnvAsInt("LINK_METADATA_QUEUE_SIZE", 1000),
+		},
+		Audit: AuditConfig{
+			Sink: getEnv("AUDIT_SINK", AuditSinkLog),
+		},
+		Chaos: ChaosConfig{
+			CacheLatency:   time.Duration(getEnvAsInt("CHAOS_CACHE_LATENCY_MS", 0)) * time.Millisecond,
+			CacheErrorRate: getEnvAsFloat("CHAOS_CACHE_ERROR_RATE", 0),
+			DBLatency:      time.Duration(getEnvAsInt("CHAOS_DB_LATENCY_MS", 0)) * time.Millisecond,
+			DBErrorRate:    getEnvAsFloat("CHAOS_DB_ERROR_RATE", 0),
+		},
+		Expand: ExpandConfig{
+			Enabled:   getEnvAsBool("EXPAND_ENABLED", true),
+			Timeout:   getEnvAsDuration("EXPAND_TIMEOUT", 10*time.Second),
+			MaxHops:   getEnvAsInt("EXPAND_MAX_HOPS", 10),
+			UserAgent: getEnv("EXPAND_USER_AGENT", "url-shortener-expand/1.0"),
+		},
+		Webhook: WebhookConfig{
+			URL:          getEnv("WEBHOOK_URL", ""),
+			Secret:       getEnv("WEBHOOK_SECRET", ""),
+			Timeout:      getEnvAsDuration("WEBHOOK_TIMEOUT", 5*time.Second),
+			MaxRetries:   getEnvAsInt("WEBHOOK_MAX_RETRIES", 3),
+			RetryBackoff: getEnvAsDuration("WEBHOOK_RETRY_BACKOFF", 500*time.Millisecond),
+			QueueSize:    getEnvAsInt("WEBHOOK_QUEUE_SIZE", 1000),
+
+			ExpiryReminder:         getEnvAsDuration("WEBHOOK_EXPIRY_REMINDER", 24*time.Hour),
+			ExpiryReminderInterval: getEnvAsDuration("WEBHOOK_EXPIRY_REMINDER_INTERVAL", time.Minute),
+		},
+	}
+
+	apiKeys, err := parseAPIKeys(getEnvAsSlice("AUTH_API_KEYS", nil))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Auth.APIKeys = apiKeys
+
+	quotas, err := parseUserQuotas(getEnvAsSlice("URL_USER_LINK_QUOTAS", nil))
+	if err != nil {
+		return nil, err
+	}
+	cfg.URL.UserLinkQuotas = quotas
+
+	if err := validateNames("URL_ALLOWED_PREFIXES", cfg.URL.AllowedPrefixes); err != nil {
+		return nil, err
+	}
+	if err := validateNames("URL_ALLOWED_SOURCES", cfg.URL.AllowedSources); err != nil {
+		return nil, err
+	}
+	if cfg.URL.MaxTTL > 0 && cfg.URL.DefaultTTL > cfg.URL.MaxTTL {
+		return nil, fmt.Errorf("invalid URL_DEFAULT_TTL %s, longer than URL_MAX_TTL %s", cfg.URL.DefaultTTL, cfg.URL.MaxTTL)
+	}
+	if rate := cfg.Redis.ConsistencyCheckRate; rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("invalid REDIS_CONSISTENCY_CHECK_RATE %v, want a fraction between 0 and 1", rate)
+	}
+	if rate := cfg.Chaos.CacheErrorRate; rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("invalid CHAOS_CACHE_ERROR_RATE %v, want a fraction between 0 and 1", rate)
+	}
+	if rate := cfg.Chaos.DBErrorRate; rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("invalid CHAOS_DB_ERROR_RATE %v, want a fraction between 0 and 1", rate)
+	}
+
+	return cfg, nil
+}
+
+// validateNames checks each entry of a name list, link prefixes or create
+// sources, is a short plain word
+// Lowercase letters, digits and hyphens keep prefixes one unambiguous path
+// segment, and sources fit their column.
+func validateNames(env string, names []string) error {
+	for _, name := range names {
+		if len(name) > 32 {
+			return fmt.Errorf("invalid %s entry %q, want at most 32 characters", env, name)
+		}
+		for _, r := range name {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return fmt.Errorf("invalid %s entry %q, want lowercase letters, digits and hyphens", env, name)
+			}
+		}
+	}
+	return nil
+}
+
+// parseUserQuotas turns "user:limit" entries into a user -> limit map
+func parseUserQuotas(entries []string) (map[string]int, error) {
+	quotas := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		user, limit, ok := strings.Cut(entry, ":")
+		user = strings.TrimSpace(user)
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		if !ok || user == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid URL_USER_LINK_QUOTAS entry %q, want user:limit", entry)
+		}
+		quotas[user] = n
+	}
+	return quotas, nil
+}
+
+// parseAPIKeys turns "key:user" entries into a key -> user map
+func parseAPIKeys(entries []string) (map[string]string, error) {
+	keys := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		key, user, ok := strings.Cut(entry, ":")
+		key, user = strings.TrimSpace(key), strings.TrimSpace(user)
+		if !ok || key == "" || user == "" {
+			return nil, fmt.Errorf("invalid AUTH_API_KEYS entry %q, want key:user", entry)
+		}
+		keys[key] = user
+	}
+	return keys, nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -173,6 +853,16 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	if value, exists := os.LookupEnv(key); exists {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -181,3 +871,19 @@ func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// getEnvAsSlice reads a comma-separated list, dropping empty entries
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
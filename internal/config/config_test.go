@@ -0,0 +1,19 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadRejectsDefaultTTLOverMaxTTL(t *testing.T) {
+	t.Setenv("URL_DEFAULT_TTL", "48h")
+	t.Setenv("URL_MAX_TTL", "24h")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "URL_DEFAULT_TTL") {
+		t.Fatalf("Load() error = %v, want URL_DEFAULT_TTL rejected", err)
+	}
+
+	t.Setenv("URL_DEFAULT_TTL", "24h")
+	if _, err := Load(); err != nil {
+		t.Errorf("Load() with DefaultTTL equal to MaxTTL returned error: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+)
+
+// ReloadableSettings are the only settings a SIGHUP reload applies to the
+// running server; anything else needs a restart
+var ReloadableSettings = []string{
+	"ClickRate.Limit",
+	"ClickRate.Window",
+	"ClickRate.PerIP",
+	"Logging.Level",
+	"URL.DefaultTTL",
+	"Redis.CacheTTL",
+}
+
+// UnreloadableChanges lists the settings that differ between running and
+// next but can't be applied without a restart, e.g. "Server.Port"
+//
+// Learning: a reload re-reads the whole environment, so an edited port or DSN
+// shows up here too. Reporting it beats ignoring it silently: the operator
+// learns the change is pending instead of believing it took effect.
+func UnreloadableChanges(running, next *Config) []string {
+	reloadable := make(map[string]struct{}, len(ReloadableSettings))
+	for _, name := range ReloadableSettings {
+		reloadable[name] = struct{}{}
+	}
+
+	var changed []string
+	a, b := reflect.ValueOf(running).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < a.NumField(); i++ {
+		section := a.Type().Field(i).Name
+		sa, sb := a.Field(i), b.Field(i)
+		if sa.Kind() != reflect.Struct {
+			if !reflect.DeepEqual(sa.Interface(), sb.Interface()) {
+				changed = append(changed, section)
+			}
+			continue
+		}
+		for j := 0; j < sa.NumField(); j++ {
+			name := section + "." + sa.Type().Field(j).Name
+			if _, ok := reloadable[name]; ok {
+				continue
+			}
+			if !reflect.DeepEqual(sa.Field(j).Interface(), sb.Field(j).Interface()) {
+				changed = append(changed, name)
+			}
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// AuditAction names a mutating operation in the audit log
+type AuditAction string
+
+const (
+	AuditURLCreate      AuditAction = "url.create"
+	AuditAliasReserve   AuditAction = "alias.reserve"
+	AuditURLEnable      AuditAction = "url.enable"
+	AuditURLDisable     AuditAction = "url.disable"
+	AuditURLExpiry      AuditAction = "url.expiry"
+	AuditURLDelete      AuditAction = "url.delete"
+	AuditURLRestore     AuditAction = "url.restore"
+	AuditURLDestination AuditAction = "url.destination"
+	AuditURLAliasAdd    AuditAction = "url.alias_add"
+	AuditURLAliasRemove AuditAction = "url.alias_remove"
+	AuditURLRegenerate  AuditAction = "url.regenerate"
+)
+
+// Who performed an audited operation
+const (
+	ActorUser      = "user"      // an API key owner, Actor is their user ID
+	ActorAdmin     = "admin"     // the operator admin token
+	ActorAnonymous = "anonymous" // no credentials, only ActorIP identifies them
+)
+
+// AuditEntry records who did what to which links, and when
+// One entry per request: bulk operations list every code they changed.
+type AuditEntry struct {
+	ID         int64       `json:"id" db:"id"`
+	Action     AuditAction `json:"action" db:"action"`
+	ShortCodes []string    `json:"short_codes" db:"short_codes"`
+	Actor      string      `json:"actor,omitempty" db:"actor"`
+	ActorType  string      `json:"actor_type" db:"actor_type"`
+	ActorIP    string      `json:"actor_ip,omitempty" db:"actor_ip"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+}
+
+// NewAuditEntry fills in the actor from what the auth middleware attached to ctx
+func NewAuditEntry(ctx context.Context, action AuditAction, shortCodes []string) *AuditEntry {
+	entry := &AuditEntry{
+		Action:     action,
+		ShortCodes: shortCodes,
+		ActorType:  ActorAnonymous,
+		ActorIP:    ClientIPFrom(ctx),
+		CreatedAt:  time.Now(),
+	}
+	if caller, ok := CallerFrom(ctx); ok {
+		entry.Actor, entry.ActorType = caller, ActorUser
+	}
+	// The admin token wins, it is what authorized the operation
+	if IsAdmin(ctx) {
+		entry.ActorType = ActorAdmin
+	}
+	return entry
+}
+
+// AuditLog is where audit entries are written: a log stream or a table
+type AuditLog interface {
+	RecordAudit(ctx context.Context, entry *AuditEntry) error
+}
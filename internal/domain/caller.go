@@ -0,0 +1,92 @@
+package domain
+
+import "context"
+
+type callerKey struct{}
+
+type clientIPKey struct{}
+
+type adminKey struct{}
+
+type visitorKey struct{}
+
+type sourceKey struct{}
+
+type codePrefixKey struct{}
+
+// Visitor is what a redirect request says about who followed the link
+type Visitor struct {
+	UserAgent      string
+	Referrer       string
+	AcceptLanguage string
+}
+
+// WithCaller returns a context carrying the authenticated user ID
+func WithCaller(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, callerKey{}, userID)
+}
+
+// CallerFrom returns the authenticated user ID, ok is false for anonymous requests
+func CallerFrom(ctx context.Context) (userID string, ok bool) {
+	userID, ok = ctx.Value(callerKey{}).(string)
+	return userID, ok && userID != ""
+}
+
+// WithClientIP returns a context carrying the visitor's IP address
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFrom returns the visitor's IP address, empty when unknown
+func ClientIPFrom(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// WithVisitor returns a context carrying the visitor's request details
+func WithVisitor(ctx context.Context, visitor Visitor) context.Context {
+	return context.WithValue(ctx, visitorKey{}, visitor)
+}
+
+// VisitorFrom returns the visitor's request details, zero when unknown
+func VisitorFrom(ctx context.Context) Visitor {
+	visitor, _ := ctx.Value(visitorKey{}).(Visitor)
+	return visitor
+}
+
+// WithAdmin marks a request as authorized by the operator admin token
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey{}, true)
+}
+
+// IsAdmin reports whether the request came with the admin token
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
+
+// WithSource returns a context carrying the surface a request says it comes
+// from (web, cli, ...), as sent by the client
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFrom returns the client's stated source, empty when it sent none
+func SourceFrom(ctx context.Context) string {
+	source, _ := ctx.Value(sourceKey{}).(string)
+	return source
+}
+
+// WithCodePrefix narrows lookups by short code in ctx to the link under
+// prefix ("" for an unprefixed link). Without it a code matches under any
+// prefix, which only ever names one link while codes are unique globally.
+func WithCodePrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, codePrefixKey{}, prefix)
+}
+
+// CodePrefixFrom returns the prefix set by WithCodePrefix, ok is false when
+// lookups aren't narrowed
+func CodePrefixFrom(ctx context.Context) (prefix string, ok bool) {
+	prefix, ok = ctx.Value(codePrefixKey{}).(string)
+	return prefix, ok
+}
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// CountryDestinations maps an ISO 3166-1 alpha-2 country code ("DE") to a
+// localized destination; visitors from anywhere else get OriginalURL
+type CountryDestinations map[string]string
+
+func (c CountryDestinations) Value() (driver.Value, error) {
+	return destinationsValue(c)
+}
+
+func (c *CountryDestinations) Scan(src interface{}) error {
+	decoded, err := scanDestinations(src)
+	if err != nil {
+		return fmt.Errorf("cannot scan into CountryDestinations: %w", err)
+	}
+	*c = decoded
+	return nil
+}
+
+// CountryResolver locates a redirect's visitor for geographic routing,
+// returning "" when it can't tell; see geo.Enricher
+type CountryResolver interface {
+	Resolve(ip, acceptLanguage string) (country, source string)
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+)
+
+// URLHistoryEntry records one change of a link's destination
+type URLHistoryEntry struct {
+	ID          int64     `json:"id" db:"id"`
+	ShortCode   string    `json:"short_code" db:"short_code"`
+	PreviousURL string    `json:"previous_url" db:"previous_url"`
+	OriginalURL string    `json:"original_url" db:"original_url"`
+	Actor       string    `json:"actor,omitempty" db:"actor"`
+	ActorType   string    `json:"actor_type" db:"actor_type"`
+	ChangedAt   time.Time `json:"changed_at" db:"changed_at"`
+}
+
+// HistoryCursorOf is the pagination cursor for a history entry
+func HistoryCursorOf(e URLHistoryEntry) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: e.ChangedAt, ID: e.ID}
+}
+
+// UpdateDestinationRequest points an existing link somewhere else
+type UpdateDestinationRequest struct {
+	OriginalURL string `json:"original_url" binding:"required,url"`
+}
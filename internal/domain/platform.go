@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Visitor platforms a link can route to its own destination
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformDesktop = "desktop"
+)
+
+// PlatformDestinations maps a visitor platform to the destination it gets
+// instead of the link's OriginalURL, e.g. the App Store for "ios"
+// Stored as a JSON object in a single column.
+type PlatformDestinations map[string]string
+
+// Value stores an empty map as "{}", matching the column default
+func (p PlatformDestinations) Value() (driver.Value, error) {
+	return destinationsValue(p)
+}
+
+// Scan reads the JSON column; Postgres hands back bytes, SQLite a string
+func (p *PlatformDestinations) Scan(src interface{}) error {
+	decoded, err := scanDestinations(src)
+	if err != nil {
+		return fmt.Errorf("cannot scan into PlatformDestinations: %w", err)
+	}
+	*p = decoded
+	return nil
+}
+
+func destinationsValue(destinations map[string]string) (driver.Value, error) {
+	if len(destinations) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(destinations)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// scanDestinations decodes a JSON object column, nil when it is empty
+func scanDestinations(src interface{}) (map[string]string, error) {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil, fmt.Errorf("unsupported type %T", src)
+	}
+
+	var decoded map[string]string
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 {
+		return nil, nil
+	}
+	return decoded, nil
+}
+
+// DestinationFor returns where a visitor on platform in country is sent:
+// a platform destination first, since it can open the app, then a country
+// destination, then OriginalURL. Either may be "" when unknown.
+func (u *URL) DestinationFor(platform, country string) string {
+	if dest, ok := u.PlatformDestinations[platform]; ok && platform != "" {
+		return dest
+	}
+	if dest, ok := u.CountryDestinations[country]; ok && country != "" {
+		return dest
+	}
+	return u.OriginalURL
+}
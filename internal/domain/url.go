@@ -3,29 +3,171 @@ package domain
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
 )
 
 // common errors
 var (
-	ErrURLNotFound       = errors.New("url not found")
-	ErrURLExpired        = errors.New("url has expired")
-	ErrInvalidURL        = errors.New("invalid url format")
-	ErrShortCodeExists   = errors.New("short code already exists")
-	ErrRateLimitExceeded = errors.New("rate limit exceeded")
-	ErrInvalidShortCode  = errors.New("invalid short code")
+	ErrURLNotFound        = errors.New("url not found")
+	ErrURLExpired         = errors.New("url has expired")
+	ErrURLDisabled        = errors.New("url has been disabled")
+	ErrInvalidURL         = errors.New("invalid url format")
+	ErrShortCodeExists    = errors.New("short code already exists")
+	ErrRateLimitExceeded  = errors.New("rate limit exceeded")
+	ErrInvalidShortCode   = errors.New("invalid short code")
+	ErrServiceUnavailable = errors.New("service temporarily unavailable")
+	ErrForbiddenDomain    = errors.New("destination domain is not allowed")
+	ErrPermanentDisabled  = errors.New("permanent links are not allowed")
+	ErrUnauthorized       = errors.New("authentication required")
+	ErrForbidden          = errors.New("access to this url is forbidden")
+	ErrSigningDisabled    = errors.New("signed links are not enabled")
+	ErrQuotaExceeded      = errors.New("link quota exceeded")
+	ErrSchemeNotAllowed   = errors.New("url scheme is not allowed")
+	ErrInvalidExpiry      = errors.New("invalid expiry")
+	ErrPrefixNotAllowed   = errors.New("link prefix is not allowed")
+	ErrURLDeleted         = errors.New("url has been deleted")
+	ErrShortCodeReserved  = errors.New("short code is reserved")
+	ErrSourceNotAllowed   = errors.New("client source is not allowed")
+	ErrCodeRetired        = errors.New("short code has been retired")
 )
 
 type URL struct {
-	ID          int64      `json:"id"`
-	ShortURL    string     `json:"short_url"`
-	OriginalURL string     `json:"original_url"`
-	UserID      *string    `json:"user_id,omitempty"`
+	ID          int64      `json:"id" db:"id"`
+	ShortURL    string     `json:"short_url" db:"short_code"`
+	OriginalURL string     `json:"original_url" db:"original_url"`
+	UserID      *string    `json:"user_id,omitempty" db:"user_id"`
 	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
 	ClickCount  int64      `json:"click_count" db:"click_count"`
 	IsActive    bool       `json:"is_active" db:"is_active"`
+
+	// Visibility is VisibilityPublic or VisibilityPrivate, empty means public
+	Visibility string `json:"visibility,omitempty" db:"visibility"`
+
+	// Signed links only resolve as "code.tag" with a valid HMAC tag
+	Signed bool `json:"signed,omitempty" db:"signed"`
+
+	// ReservedUntil marks an alias reservation: a placeholder row with no
+	// destination that holds the code for its owner until then
+	ReservedUntil *time.Time `json:"reserved_until,omitempty" db:"reserved_until"`
+
+	// ClickRateLimit caps redirects of this link per rate limit window,
+	// nil falls back to the server-wide limit
+	ClickRateLimit *int `json:"click_rate_limit,omitempty" db:"click_rate_limit"`
+
+	// PassthroughQuery appends the query string of each click to the
+	// destination, so e.g. ?ref=twitter reaches the landing page
+	PassthroughQuery bool `json:"passthrough_query,omitempty" db:"passthrough_query"`
+
+	// Prefix is a path segment the link must be visited under
+	// ("/news/abc123"), empty for links served at the bare code
+	Prefix string `json:"prefix,omitempty" db:"prefix"`
+
+	// PlatformDestinations sends iOS, Android or desktop visitors somewhere
+	// other than OriginalURL (deep links, app stores); OriginalURL is the
+	// fallback for platforms without an entry and unrecognized agents
+	PlatformDestinations PlatformDestinations `json:"platform_destinations,omitempty" db:"platform_destinations"`
+
+	// CountryDestinations sends visitors from a country to a localized
+	// destination, OriginalURL is the default
+	CountryDestinations CountryDestinations `json:"country_destinations,omitempty" db:"country_destinations"`
+
+	// FallbackURL is where visitors go once the link has expired, e.g. a
+	// newer campaign; empty leaves it to the server's expired behavior
+	FallbackURL string `json:"fallback_url,omitempty" db:"fallback_url"`
+
+	// Source is the surface the link was created from (web, cli, ...),
+	// empty for links created before it was recorded
+	Source string `json:"source,omitempty" db:"source"`
+
+	// PurgeAfter marks a deleted link: it resolves as gone and is purged
+	// at this time unless restored first
+	PurgeAfter *time.Time `json:"purge_after,omitempty" db:"purge_after"`
+
+	// Preview metadata read from the destination page, empty until fetched
+	Title       string `json:"title,omitempty" db:"title"`
+	Description string `json:"description,omitempty" db:"description"`
+	ImageURL    string `json:"image_url,omitempty" db:"image_url"`
+}
+
+// PublicURL is what anyone may read about a link: no owner and none of the
+// owner's settings (rate limit, routing, fallback, source)
+type PublicURL struct {
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	Prefix      string     `json:"prefix,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ClickCount  int64      `json:"click_count"`
+	IsActive    bool       `json:"is_active"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	ImageURL    string     `json:"image_url,omitempty"`
+}
+
+// Public returns the link's public view
+func (u *URL) Public() *PublicURL {
+	return &PublicURL{
+		ShortURL:    u.ShortURL,
+		OriginalURL: u.OriginalURL,
+		Prefix:      u.Prefix,
+		CreatedAt:   u.CreatedAt,
+		ExpiresAt:   u.ExpiresAt,
+		ClickCount:  u.ClickCount,
+		IsActive:    u.IsActive,
+		Title:       u.Title,
+		Description: u.Description,
+		ImageURL:    u.ImageURL,
+	}
+}
+
+// LinkMetadata is what a destination page says about itself (<title> and
+// OpenGraph tags), used to render rich previews of a link
+type LinkMetadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+}
+
+// Link visibility: private links only resolve for their creator's API key
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// IsPrivate reports whether only the owner may resolve the link
+func (u *URL) IsPrivate() bool {
+	return u.Visibility == VisibilityPrivate
+}
+
+// ListScope is which links a listing may return
+// The zero value matches nothing, an anonymous caller may not list links.
+type ListScope struct {
+	// All returns every link, for the admin and exports
+	All bool
+	// UserID returns that user's own links, public and private
+	UserID string
+}
+
+// ListScopeFor is the scope of the caller attached to ctx
+func ListScopeFor(ctx context.Context) ListScope {
+	if IsAdmin(ctx) {
+		return ListScope{All: true}
+	}
+	userID, _ := CallerFrom(ctx)
+	return ListScope{UserID: userID}
+}
+
+// Includes reports whether url may be listed in the scope
+func (s ListScope) Includes(url *URL) bool {
+	if s.All {
+		return true
+	}
+	return s.UserID != "" && url.UserID != nil && *url.UserID == s.UserID
 }
 
 func (u *URL) IsExpired() bool {
@@ -35,11 +177,219 @@ func (u *URL) IsExpired() bool {
 	return time.Now().After(*u.ExpiresAt)
 }
 
+// LinkKey names the link shortCode under prefix in a single string, its path
+// without the leading slash ("news/sale", or "sale" with no prefix)
+// Use case: keys of click counters and background jobs, which outlive the
+// request that knew the link's prefix
+func LinkKey(prefix, shortCode string) string {
+	if prefix == "" {
+		return shortCode
+	}
+	return prefix + "/" + shortCode
+}
+
+// SplitLinkKey undoes LinkKey
+func SplitLinkKey(key string) (prefix, shortCode string) {
+	if prefix, shortCode, ok := strings.Cut(key, "/"); ok {
+		return prefix, shortCode
+	}
+	return "", key
+}
+
+// ExpiredError is ErrURLExpired for a link with a fallback URL, so the
+// redirect can send the visitor there; errors.Is(err, ErrURLExpired) holds
+type ExpiredError struct {
+	FallbackURL string
+}
+
+func (e *ExpiredError) Error() string { return ErrURLExpired.Error() }
+func (e *ExpiredError) Unwrap() error { return ErrURLExpired }
+
+// Expired is the error for a visit to u after it expired
+func Expired(u *URL) error {
+	if u.FallbackURL == "" {
+		return ErrURLExpired
+	}
+	return &ExpiredError{FallbackURL: u.FallbackURL}
+}
+
 type CreateURLRequest struct {
 	OriginalURL string  `json:"original_url" binding:"required,url"`
-	CustomAlias *string `json:"custom_alias,omitempty"`
-	ExpiresIn   *int64  `json:"expires_in,omitempty"`
+	CustomAlias *string `json:"custom_alias,omitempty" binding:"omitempty,min=3,max=20,shortcode"`
+	ExpiresIn   *int64  `json:"expires_in,omitempty" binding:"omitempty,min=-1"`
 	UserID      *string `json:"user_id,omitempty"`
+
+	// Visibility "private" requires an API key to create and limits
+	// resolving the link to the same key owner
+	Visibility string `json:"visibility,omitempty" binding:"omitempty,oneof=public private"`
+
+	// CodeLength asks for a generated code of exactly this many characters,
+	// within the server's min/max code length. Ignored with a custom alias.
+	CodeLength *int `json:"code_length,omitempty"`
+
+	// NeverExpires (or expires_in: -1) creates a link with no expiry,
+	// overriding the default TTL
+	NeverExpires bool `json:"never_expires,omitempty"`
+
+	// Signed appends an HMAC tag to the code; the bare code won't resolve
+	Signed bool `json:"signed,omitempty"`
+
+	// ClickRateLimit overrides the server-wide redirect limit for this link
+	ClickRateLimit *int `json:"click_rate_limit,omitempty" binding:"omitempty,min=1"`
+
+	// PassthroughQuery forwards click-time query parameters to the destination
+	PassthroughQuery bool `json:"passthrough_query,omitempty"`
+
+	// Prefix serves the link at /prefix/code instead of /code, e.g. a
+	// category hinting at the destination; must be on the server's list
+	Prefix string `json:"prefix,omitempty"`
+
+	// PlatformDestinations overrides the destination per visitor platform:
+	// keys are "ios", "android" and "desktop", original_url is the fallback
+	PlatformDestinations map[string]string `json:"platform_destinations,omitempty" binding:"omitempty,max=3,dive,keys,oneof=ios android desktop,endkeys,required,url"`
+
+	// CountryDestinations overrides the destination by the visitor's country,
+	// keyed by upper-case ISO 3166-1 alpha-2 code; platform destinations win
+	CountryDestinations map[string]string `json:"country_destinations,omitempty" binding:"omitempty,max=250,dive,keys,iso3166_1_alpha2,endkeys,required,url"`
+
+	// FallbackURL receives visitors once the link expires, instead of the
+	// server's expired response
+	FallbackURL string `json:"fallback_url,omitempty" binding:"omitempty,url"`
+
+	// FetchMetadata reads the destination's title and OpenGraph tags in the
+	// background; the link is usable right away and gains them later
+	FetchMetadata bool `json:"fetch_metadata,omitempty"`
+
+	// ReturnExisting turns a taken custom_alias into a success when the
+	// existing link already points at the same destination
+	ReturnExisting bool `json:"return_existing,omitempty"`
+}
+
+// NeverExpiresSentinel is the expires_in value that requests a permanent link
+const NeverExpiresSentinel = -1
+
+// WantsPermanent reports whether the caller asked for a non-expiring link
+func (r *CreateURLRequest) WantsPermanent() bool {
+	return r.NeverExpires || (r.ExpiresIn != nil && *r.ExpiresIn == NeverExpiresSentinel)
+}
+
+// ExpiryUpdateRequest changes a link's expiry; exactly one field must be set
+// A negative extend_by_seconds shortens the link's life
+type ExpiryUpdateRequest struct {
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	ExtendBySeconds *int64     `json:"extend_by_seconds,omitempty"`
+	NeverExpires    bool       `json:"never_expires,omitempty"`
+}
+
+// ExpiryResponse reports a link's expiry as stored
+type ExpiryResponse struct {
+	ShortCode    string     `json:"short_code"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	NeverExpires bool       `json:"never_expires"`
+	Expired      bool       `json:"expired"`
+}
+
+// DeleteResponse reports a link's deletion state after a delete or restore
+// PurgeAfter is the end of the undo window while the deletion is pending
+type DeleteResponse struct {
+	ShortCode  string     `json:"short_code"`
+	Deleted    bool       `json:"deleted"`
+	PurgeAfter *time.Time `json:"purge_after,omitempty"`
+}
+
+// ReserveAliasRequest holds a custom alias before its destination is known
+// A later create with the same custom_alias and API key claims it
+type ReserveAliasRequest struct {
+	Alias      string `json:"alias" binding:"required,min=3,max=20,shortcode"`
+	ReserveFor *int64 `json:"reserve_for,omitempty" binding:"omitempty,min=1"` // seconds
+}
+
+// AddAliasRequest gives an existing link one more code
+type AddAliasRequest struct {
+	Alias string `json:"alias" binding:"required,min=3,max=20,shortcode"`
+}
+
+// AliasResponse is one alias and the link it leads to
+type AliasResponse struct {
+	Alias     string `json:"alias"`
+	ShortCode string `json:"short_code"`
+}
+
+// RegenerateResponse is a link's new code and what became of the old one
+type RegenerateResponse struct {
+	ShortCode string `json:"short_code"`
+
+	// ShortURL is empty while the link doesn't resolve (disabled, expired)
+	ShortURL     string `json:"short_url,omitempty"`
+	PreviousCode string `json:"previous_code"`
+
+	// PreviousCodeStatus is "alias" when the old code still redirects,
+	// "retire" when it answers 410 Gone
+	PreviousCodeStatus string `json:"previous_code_status"`
+}
+
+// LinkAliases lists the extra codes of a link
+type LinkAliases struct {
+	ShortCode string   `json:"short_code"`
+	Aliases   []string `json:"aliases"`
+}
+
+type ReserveAliasResponse struct {
+	Alias         string    `json:"alias"`
+	ShortURL      string    `json:"short_url"`
+	ReservedUntil time.Time `json:"reserved_until"`
+}
+
+// AliasAvailabilityResponse answers whether a custom alias can be created
+// Reason says why not: "taken" or "reserved" (a server route)
+type AliasAvailabilityResponse struct {
+	Alias     string `json:"alias"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Values for AliasAvailabilityResponse.Reason
+const (
+	AliasTaken    = "taken"
+	AliasReserved = "reserved"
+)
+
+// BulkStatusRequest enables or disables many links at once
+type BulkStatusRequest struct {
+	ShortCodes []string `json:"short_codes" binding:"required,min=1,max=1000,dive,required"`
+}
+
+type BulkStatusResponse struct {
+	Updated  []string `json:"updated"`
+	NotFound []string `json:"not_found"`
+	// Forbidden lists the codes of links the caller doesn't own
+	Forbidden []string `json:"forbidden"`
+	IsActive  bool     `json:"is_active"`
+}
+
+// ImportReport is the outcome of a CSV import, one result per data row
+type ImportReport struct {
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+	// Error is set when the upload couldn't be read to the end; the rows
+	// before it were still imported
+	Error string `json:"error,omitempty"`
+}
+
+// Values for ImportRowResult.Status
+const (
+	ImportRowCreated = "created"
+	ImportRowFailed  = "failed"
+)
+
+type ImportRowResult struct {
+	Line      int    `json:"line"` // in the uploaded file, the header is line 1
+	ShortCode string `json:"short_code,omitempty"`
+	ShortURL  string `json:"short_url,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Warning   string `json:"warning,omitempty"`
 }
 
 type CreateURLResponse struct {
@@ -48,17 +398,50 @@ type CreateURLResponse struct {
 	OriginalURL string     `json:"original_url"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
+
+	// Existing is set when return_existing matched a link created earlier
+	Existing bool `json:"existing,omitempty"`
+
+	// Only filled in when asked for with ?include=qr,pixel
+	QRCode   string `json:"qr_code,omitempty"`
+	PixelURL string `json:"pixel_url,omitempty"`
 }
 type URLStats struct {
-	ShortCode   string     `json:"short_code"`
-	ClickCount  int64      `json:"click_count"`
-	LastClicked *time.Time `json:"last_clicked,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
+	ShortCode   string     `json:"short_code" db:"short_code"`
+	ClickCount  int64      `json:"click_count" db:"click_count"`
+	LastClicked *time.Time `json:"last_clicked,omitempty" db:"last_clicked"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// SourceStats counts the links created from one surface; links from before
+// sources were recorded count as SourceUnknown
+type SourceStats struct {
+	Source string `json:"source" db:"source"`
+	Links  int64  `json:"links" db:"links"`
+}
+
+const (
+	// SourceAPI is recorded for API key callers that don't name a source
+	SourceAPI = "api"
+
+	// SourceUnknown is the stats bucket of links without a recorded source
+	SourceUnknown = "unknown"
+)
+
+// AggregateStats is the service-wide summary served by /api/v1/stats
+type AggregateStats struct {
+	TotalURLs     int64         `json:"total_urls" db:"total_urls"`
+	ActiveURLs    int64         `json:"active_urls" db:"active_urls"`
+	TotalClicks   int64         `json:"total_clicks" db:"total_clicks"`
+	TopURLs       []URLStats    `json:"top_urls"`
+	LinksBySource []SourceStats `json:"links_by_source"`
+	GeneratedAt   time.Time     `json:"generated_at"`
 }
 
 type ClickEvent struct {
 	ID        int64     `json:"id" db:"id"`
 	ShortCode string    `json:"short_code" db:"short_code"`
+	Prefix    string    `json:"prefix,omitempty" db:"prefix"`
 	IPAddress string    `json:"ip_address" db:"ip_address"`
 	UserAgent string    `json:"user_agent" db:"user_agent"`
 	Referrer  string    `json:"referrer" db:"referrer"`
@@ -68,14 +451,176 @@ type ClickEvent struct {
 	Browser   string    `json:"browser" db:"browser"`
 	OS        string    `json:"os" db:"os"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// CountrySource says how Country was derived, so analytics can tell
+	// IP-based locations apart from locale guesses
+	CountrySource string `json:"country_source,omitempty" db:"country_source"`
+
+	// Type separates redirects from other tracked events such as pixel opens
+	Type string `json:"type" db:"event_type"`
 }
 
+// Values for ClickEvent.Type
+const (
+	ClickEventRedirect = "redirect"
+	ClickEventOpen     = "open" // tracking pixel load, e.g. an email was opened
+)
+
+// Values for ClickEvent.CountrySource
+const (
+	CountrySourceGeoIP          = "geoip"
+	CountrySourceAcceptLanguage = "accept_language" // inferred, best-effort
+)
+
+// URLRepository stores links
+// Methods taking a short code act on the link under the prefix set with
+// WithCodePrefix, or on the code under any prefix without one.
 type URLRepository interface {
 	// Create stores a new URL mapping
 	Create(ctx context.Context, url *URL) error
 
-	// GetByShortCode retrieves a URL by its short code
+	// GetByShortCode retrieves a URL by its short code, including disabled ones
 	GetByShortCode(ctx context.Context, shortCode string) (*URL, error)
+
+	// SetActive enables or disables a URL, returns ErrURLNotFound for unknown codes
+	SetActive(ctx context.Context, shortCode string, active bool) error
+
+	// GetAggregateStats returns service-wide totals and the topN most clicked active URLs
+	GetAggregateStats(ctx context.Context, topN int) (*AggregateStats, error)
+
+	// List returns the URLs in scope newest first (created_at DESC, id DESC),
+	// up to page.Limit+1 rows so callers can tell whether another page exists
+	List(ctx context.Context, scope ListScope, page pagination.Request) ([]URL, error)
+
+	// ListByDestination pages through the live links (active, unexpired) in
+	// scope pointing at originalURL, in the same order as List
+	ListByDestination(ctx context.Context, originalURL string, scope ListScope, page pagination.Request) ([]URL, error)
+
+	// Reserve stores a placeholder holding url.ShortURL until url.ReservedUntil
+	// A lapsed reservation of the same code is replaced, any other existing
+	// row is ErrShortCodeExists
+	Reserve(ctx context.Context, url *URL) error
+
+	// ClaimReservation turns a reservation into a live link with url's fields
+	// Live reservations are claimable by their owner (url.UserID) only, lapsed
+	// ones by anyone. ErrURLNotFound means there was nothing to claim.
+	ClaimReservation(ctx context.Context, url *URL) error
+
+	// DeleteExpiredReservations removes reservations that lapsed before now
+	DeleteExpiredReservations(ctx context.Context, now time.Time) (int64, error)
+
+	// MarkDeleted schedules a link for purging at purgeAfter and returns the
+	// purge time in effect: deleting an already deleted link keeps its first
+	// window. ErrURLNotFound for unknown codes.
+	MarkDeleted(ctx context.Context, shortCode string, purgeAfter time.Time) (time.Time, error)
+
+	// RestoreDeleted cancels a pending deletion whose window is still open at
+	// now; ErrURLNotFound when there is none (unknown, live, or past its window)
+	RestoreDeleted(ctx context.Context, shortCode string, now time.Time) error
+
+	// PurgeDeleted removes deleted links whose window closed before now
+	PurgeDeleted(ctx context.Context, now time.Time) (int64, error)
+
+	// CountActiveByUser counts userID's live links (active and unexpired),
+	// the number held against their link quota
+	CountActiveByUser(ctx context.Context, userID string) (int64, error)
+
+	// SetMetadata stores preview metadata, returns ErrURLNotFound for unknown codes
+	SetMetadata(ctx context.Context, shortCode string, meta LinkMetadata) error
+
+	// GetExpiry returns a link's expiry (nil = never) whether or not the link
+	// is active or already expired, ErrURLNotFound for unknown codes
+	GetExpiry(ctx context.Context, shortCode string) (*time.Time, error)
+
+	// GetOwner returns the user_id a link was created under (nil for
+	// anonymous links) whatever the link's state, ErrURLNotFound for unknown codes
+	GetOwner(ctx context.Context, shortCode string) (*string, error)
+
+	// SetExpiry replaces a link's expiry, nil removes it
+	SetExpiry(ctx context.Context, shortCode string, expiresAt *time.Time) error
+
+	// IsShortCodeTaken reports whether a create of shortCode would collide:
+	// with any link (disabled, expired or pending deletion included) or a
+	// live reservation. A lapsed reservation doesn't count, it is replaced.
+	IsShortCodeTaken(ctx context.Context, shortCode string) (bool, error)
+
+	// UpdateDestination points a live link at entry.OriginalURL and records
+	// entry in its history in the same transaction, filling in ID and
+	// PreviousURL; ErrURLNotFound for unknown, reserved or deleted codes
+	UpdateDestination(ctx context.Context, entry *URLHistoryEntry) error
+
+	// ListHistory returns a link's destination changes, newest first, with
+	// the same Limit+1 convention as List
+	ListHistory(ctx context.Context, shortCode string, page pagination.Request) ([]URLHistoryEntry, error)
+
+	// AddAlias gives the live link shortCode the extra code alias
+	// ErrURLNotFound when shortCode isn't a live link, ErrShortCodeExists
+	// when alias is already a link, a reservation (lapsed ones included,
+	// until they are cleaned up) or another alias
+	AddAlias(ctx context.Context, shortCode, alias string) error
+
+	// RemoveAlias drops alias from the link shortCode, ErrURLNotFound when
+	// it isn't one of that link's aliases
+	RemoveAlias(ctx context.Context, shortCode, alias string) error
+
+	// ListAliases returns the aliases of the link shortCode in code order,
+	// ErrURLNotFound for unknown codes
+	ListAliases(ctx context.Context, shortCode string) ([]string, error)
+
+	// ResolveAlias returns the code of the link alias belongs to,
+	// ErrURLNotFound when alias isn't an alias and ErrCodeRetired when it
+	// is the retired former code of a link
+	ResolveAlias(ctx context.Context, alias string) (string, error)
+
+	// ClaimExpiredEvent records that the expiry of the link shortCode, past
+	// at now, is being announced and returns the link with ShortURL, Prefix,
+	// OriginalURL and ExpiresAt filled in; nil when there is nothing to
+	// announce or it already was. One event per expiry whatever the number
+	// of instances and visits; a link given a new expiry is announced again.
+	ClaimExpiredEvent(ctx context.Context, shortCode string, now time.Time) (*URL, error)
+
+	// ClaimExpiryReminders does the same for the coming expiry of up to limit
+	// live links expiring after now and no later than before
+	ClaimExpiryReminders(ctx context.Context, now, before time.Time, limit int) ([]URL, error)
+
+	// RenameShortCode moves the live link oldCode to newCode. The row keeps
+	// its id, so clicks, history and aliases stay with the link, and stored
+	// click events are moved to newCode. oldCode becomes an alias of the
+	// link with keepAlias, a retired code otherwise: it resolves to
+	// ErrCodeRetired and is never handed out again.
+	// ErrURLNotFound for unknown, reserved or deleted codes,
+	// ErrShortCodeExists when newCode is taken.
+	RenameShortCode(ctx context.Context, oldCode, newCode string, keepAlias bool) error
+}
+
+// MetadataQueue schedules fetching a link's preview metadata
+// Enqueue must not block; requests that can't be queued are dropped. link
+// is the LinkKey of the link.
+type MetadataQueue interface {
+	Enqueue(link, destination string)
+}
+
+// CursorOf returns the pagination cursor pointing at u
+func CursorOf(u URL) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+}
+
+// ClickEventRepository stores individual click events for analytics
+type ClickEventRepository interface {
+	RecordClickEvent(ctx context.Context, event *ClickEvent) error
+}
+
+// ClickLimiter counts redirects per key in fixed windows
+// Allow records one hit and reports whether key is still within limit
+type ClickLimiter interface {
+	Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, error)
+}
+
+// ClickCounter records redirects against a link's click_count
+// Implementations may buffer, so counts are eventually consistent. Clicks
+// are counted by the link's LinkKey.
+type ClickCounter interface {
+	Incr(ctx context.Context, link string) error
 }
 
 type CacheRepository interface {
@@ -88,6 +633,81 @@ type CacheRepository interface {
 	// Delete removes a URL from cache
 	Delete(ctx context.Context, shortCode string) error
 
+	// DeleteMany removes several URLs in one round trip, for bulk operations
+	// Every key is attempted; the error reports the ones that failed
+	DeleteMany(ctx context.Context, shortCodes []string) error
+
 	// Exists checks if a key exists in cache
 	Exists(ctx context.Context, shortCode string) (bool, error)
+
+	// ClaimAlias atomically claims code for ttl, false if someone holds it
+	// The claim only serializes concurrent creates of one alias, the
+	// database stays the source of truth for who owns it
+	ClaimAlias(ctx context.Context, code string, ttl time.Duration) (bool, error)
+
+	// ReleaseAlias drops a claim early, e.g. when the insert it guarded failed
+	ReleaseAlias(ctx context.Context, code string) error
+}
+
+// Destination is the minimum a redirect needs, cached apart from the full URL
+type Destination struct {
+	OriginalURL string
+	ExpiresAt   *time.Time
+}
+
+// DestinationCache is an optional CacheRepository extension for the redirect
+// hot path. Only public, unsigned, active links may be stored this way, since
+// the entry carries nothing to check access against.
+// Delete and DeleteMany on the CacheRepository must evict these entries too.
+type DestinationCache interface {
+	GetDestination(ctx context.Context, shortCode string) (*Destination, error)
+	SetDestination(ctx context.Context, shortCode string, dest Destination, ttl time.Duration) error
+}
+
+// EventType identifies a link lifecycle event
+type EventType string
+
+const (
+	EventURLCreated  EventType = "url.created"
+	EventURLExpiring EventType = "url.expiring" // reminder ahead of ExpiresAt
+	EventURLExpired  EventType = "url.expired"
+	EventURLDeleted  EventType = "url.deleted"
+)
+
+// LinkEvent is emitted to integrations when a link changes state
+type LinkEvent struct {
+	Type        EventType  `json:"type"`
+	ShortCode   string     `json:"short_code"`
+	Prefix      string     `json:"prefix,omitempty"`
+	OriginalURL string     `json:"original_url"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	OccurredAt  time.Time  `json:"occurred_at"`
+}
+
+// AnalyticsEvent is one redirect forwarded to an external analytics pipeline
+type AnalyticsEvent struct {
+	Event       string    `json:"event"`
+	ShortCode   string    `json:"short_code"`
+	Prefix      string    `json:"prefix,omitempty"`
+	Destination string    `json:"destination"`
+	IPAddress   string    `json:"ip,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	Referrer    string    `json:"referrer,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// AnalyticsEventRedirect is AnalyticsEvent.Event for a followed link
+const AnalyticsEventRedirect = "link_redirected"
+
+// AnalyticsSink forwards redirects to an external analytics pipeline
+type AnalyticsSink interface {
+	// Track hands an event off without blocking the redirect; delivery is
+	// best-effort and never fails the request
+	Track(ctx context.Context, event AnalyticsEvent)
+}
+
+type EventPublisher interface {
+	// Publish hands an event off for delivery without blocking the caller
+	// Implementations must be safe for concurrent use and must not fail the request
+	Publish(ctx context.Context, event LinkEvent)
 }
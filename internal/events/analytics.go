@@ -0,0 +1,161 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+type AnalyticsConfig struct {
+	URL           string
+	APIKey        string // sent as "Authorization: Bearer <key>" when set
+	BatchSize     int
+	FlushInterval time.Duration
+	QueueSize     int
+	Timeout       time.Duration
+}
+
+// AnalyticsBatch is the body POSTed to the analytics endpoint
+type AnalyticsBatch struct {
+	Batch  []domain.AnalyticsEvent `json:"batch"`
+	SentAt time.Time               `json:"sent_at"`
+}
+
+// HTTPAnalyticsSink forwards redirects to a Segment/PostHog-style batch
+// endpoint, in addition to the click counts kept locally
+//
+// Like WebhookPublisher, Track only enqueues and Run does the HTTP work, so a
+// slow endpoint never delays a redirect. Unlike webhooks, a failed batch is
+// not retried: analytics is fail-soft, the events are counted as failed and
+// the local click counts remain the source of truth.
+type HTTPAnalyticsSink struct {
+	cfg     AnalyticsConfig
+	client  *http.Client
+	queue   chan domain.AnalyticsEvent
+	logger  *zap.Logger
+	metrics *metrics.Metrics
+}
+
+func NewHTTPAnalyticsSink(cfg AnalyticsConfig, logger *zap.Logger, m *metrics.Metrics) *HTTPAnalyticsSink {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	return &HTTPAnalyticsSink{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		queue:   make(chan domain.AnalyticsEvent, cfg.QueueSize),
+		logger:  logger,
+		metrics: m,
+	}
+}
+
+var _ domain.AnalyticsSink = (*HTTPAnalyticsSink)(nil)
+
+func (s *HTTPAnalyticsSink) Track(ctx context.Context, event domain.AnalyticsEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	select {
+	case s.queue <- event:
+	default:
+		// Queue full - the endpoint is down or slow, drop rather than block
+		s.metrics.AnalyticsEventsTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Run sends a batch once BatchSize events are queued or FlushInterval has
+// passed, until ctx is cancelled; what is queued then gets one last send
+func (s *HTTPAnalyticsSink) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]domain.AnalyticsEvent, 0, s.cfg.BatchSize)
+	add := func(event domain.AnalyticsEvent) {
+		batch = append(batch, event)
+		if len(batch) >= s.cfg.BatchSize {
+			s.flush(&batch)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-s.queue:
+					add(event)
+				default:
+					s.flush(&batch)
+					return
+				}
+			}
+		case event := <-s.queue:
+			add(event)
+		case <-ticker.C:
+			s.flush(&batch)
+		}
+	}
+}
+
+// flush sends and empties batch
+// Each send has its own deadline rather than Run's context, so a shutdown
+// doesn't abort the batch already on its way.
+func (s *HTTPAnalyticsSink) flush(batch *[]domain.AnalyticsEvent) {
+	if len(*batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	if err := s.send(ctx, *batch); err != nil {
+		s.metrics.AnalyticsEventsTotal.WithLabelValues("failed").Add(float64(len(*batch)))
+		s.logger.Warn("analytics delivery failed, dropping batch", zap.Error(err), zap.Int("events", len(*batch)))
+	} else {
+		s.metrics.AnalyticsEventsTotal.WithLabelValues("sent").Add(float64(len(*batch)))
+	}
+	*batch = make([]domain.AnalyticsEvent, 0, s.cfg.BatchSize)
+}
+
+func (s *HTTPAnalyticsSink) send(ctx context.Context, batch []domain.AnalyticsEvent) error {
+	body, err := json.Marshal(AnalyticsBatch{Batch: batch, SentAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("analytics endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
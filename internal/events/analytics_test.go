@@ -0,0 +1,150 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// captureBatches starts an endpoint that hands every decoded batch to the test
+func captureBatches(t *testing.T, status int) (*httptest.Server, chan AnalyticsBatch) {
+	t.Helper()
+	batches := make(chan AnalyticsBatch, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer key-1" {
+			t.Errorf("Authorization = %q, want the API key", got)
+		}
+		var batch AnalyticsBatch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+		}
+		batches <- batch
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, batches
+}
+
+func nextBatch(t *testing.T, batches chan AnalyticsBatch) AnalyticsBatch {
+	t.Helper()
+	select {
+	case batch := <-batches:
+		return batch
+	case <-time.After(2 * time.Second):
+		t.Fatal("no batch was delivered")
+		return AnalyticsBatch{}
+	}
+}
+
+func TestAnalyticsSinkDeliversBatches(t *testing.T) {
+	server, batches := captureBatches(t, http.StatusOK)
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	sink := NewHTTPAnalyticsSink(AnalyticsConfig{
+		URL:           server.URL,
+		APIKey:        "key-1",
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+	}, zap.NewNop(), m)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sink.Run(ctx)
+		close(done)
+	}()
+
+	for _, code := range []string{"a", "b", "c", "d"} {
+		sink.Track(ctx, domain.AnalyticsEvent{
+			Event:       domain.AnalyticsEventRedirect,
+			ShortCode:   code,
+			Destination: "https://example.com/" + code,
+		})
+	}
+
+	// A full batch goes out in one request
+	batch := nextBatch(t, batches)
+	if len(batch.Batch) != 3 || batch.Batch[0].ShortCode != "a" || batch.Batch[2].ShortCode != "c" {
+		t.Fatalf("first batch = %+v, want a, b, c", batch.Batch)
+	}
+	if batch.Batch[0].Destination != "https://example.com/a" || batch.Batch[0].Timestamp.IsZero() {
+		t.Errorf("event = %+v, want destination and timestamp", batch.Batch[0])
+	}
+
+	// The rest is sent on shutdown
+	cancel()
+	<-done
+	if batch := nextBatch(t, batches); len(batch.Batch) != 1 || batch.Batch[0].ShortCode != "d" {
+		t.Errorf("final batch = %+v, want d", batch.Batch)
+	}
+	if got := testutil.ToFloat64(m.AnalyticsEventsTotal.WithLabelValues("sent")); got != 4 {
+		t.Errorf("sent events = %v, want 4", got)
+	}
+}
+
+func TestAnalyticsSinkFlushesOnInterval(t *testing.T) {
+	server, batches := captureBatches(t, http.StatusOK)
+	sink := NewHTTPAnalyticsSink(AnalyticsConfig{
+		URL:           server.URL,
+		APIKey:        "key-1",
+		BatchSize:     100,
+		FlushInterval: 20 * time.Millisecond,
+	}, zap.NewNop(), metrics.NewMetricsWithRegistry(prometheus.NewRegistry()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sink.Run(ctx)
+
+	sink.Track(ctx, domain.AnalyticsEvent{ShortCode: "a"})
+	sink.Track(ctx, domain.AnalyticsEvent{ShortCode: "b"})
+	if batch := nextBatch(t, batches); len(batch.Batch) != 2 {
+		t.Errorf("batch = %d events, want 2", len(batch.Batch))
+	}
+}
+
+func TestAnalyticsSinkIsFailSoft(t *testing.T) {
+	server, batches := captureBatches(t, http.StatusServiceUnavailable)
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	sink := NewHTTPAnalyticsSink(AnalyticsConfig{
+		URL:           server.URL,
+		APIKey:        "key-1",
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		QueueSize:     2,
+	}, zap.NewNop(), m)
+	ctx := context.Background()
+
+	// Nothing drains the queue yet: the third event is dropped, not waited on
+	for i := 0; i < 3; i++ {
+		sink.Track(ctx, domain.AnalyticsEvent{ShortCode: "a"})
+	}
+	if got := testutil.ToFloat64(m.AnalyticsEventsTotal.WithLabelValues("dropped")); got != 1 {
+		t.Errorf("dropped events = %v, want 1", got)
+	}
+
+	// A rejected batch is counted and given up on, never retried
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go sink.Run(runCtx)
+	nextBatch(t, batches)
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(m.AnalyticsEventsTotal.WithLabelValues("failed")) != 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(m.AnalyticsEventsTotal.WithLabelValues("failed")); got != 2 {
+		t.Errorf("failed events = %v, want 2", got)
+	}
+	select {
+	case <-batches:
+		t.Error("a failed batch was retried")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
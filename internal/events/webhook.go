@@ -0,0 +1,157 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body
+// Receivers recompute it with the shared secret to verify the sender
+const SignatureHeader = "X-Webhook-Signature"
+
+type WebhookConfig struct {
+	URL          string
+	Secret       string
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+	QueueSize    int
+}
+
+// WebhookPublisher delivers link events to an HTTP endpoint asynchronously
+//
+// Publish only enqueues; a single worker (Run) drains the queue so slow or
+// failing receivers never add latency to create/redirect requests. Events
+// that can't be queued or that exhaust their retries are counted as dead
+// letters rather than retried forever.
+type WebhookPublisher struct {
+	cfg     WebhookConfig
+	client  *http.Client
+	queue   chan domain.LinkEvent
+	logger  *zap.Logger
+	metrics *metrics.Metrics
+}
+
+func NewWebhookPublisher(cfg WebhookConfig, logger *zap.Logger, m *metrics.Metrics) *WebhookPublisher {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.RetryBackoff == 0 {
+		cfg.RetryBackoff = 500 * time.Millisecond
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+
+	return &WebhookPublisher{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		queue:   make(chan domain.LinkEvent, cfg.QueueSize),
+		logger:  logger,
+		metrics: m,
+	}
+}
+
+var _ domain.EventPublisher = (*WebhookPublisher)(nil)
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event domain.LinkEvent) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	select {
+	case p.queue <- event:
+	default:
+		// Queue full - drop rather than block the request path
+		p.metrics.WebhookDeadLetterTotal.WithLabelValues(string(event.Type)).Inc()
+		p.logger.Warn("webhook queue full, dropping event",
+			zap.String("type", string(event.Type)),
+			zap.String("short_code", event.ShortCode),
+		)
+	}
+}
+
+// Run delivers queued events until ctx is cancelled
+func (p *WebhookPublisher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-p.queue:
+			p.deliver(ctx, event)
+		}
+	}
+}
+
+func (p *WebhookPublisher) deliver(ctx context.Context, event domain.LinkEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		p.metrics.WebhookDeadLetterTotal.WithLabelValues(string(event.Type)).Inc()
+		return
+	}
+
+	backoff := p.cfg.RetryBackoff
+	for attempt := 0; attempt <= p.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				p.metrics.WebhookDeadLetterTotal.WithLabelValues(string(event.Type)).Inc()
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		if err = p.send(ctx, body); err == nil {
+			p.metrics.WebhookDeliveriesTotal.WithLabelValues("success").Inc()
+			return
+		}
+		p.metrics.WebhookDeliveriesTotal.WithLabelValues("failure").Inc()
+	}
+
+	p.metrics.WebhookDeadLetterTotal.WithLabelValues(string(event.Type)).Inc()
+	p.logger.Error("webhook delivery failed, giving up",
+		zap.Error(err),
+		zap.String("type", string(event.Type)),
+		zap.String("short_code", event.ShortCode),
+		zap.Int("attempts", p.cfg.MaxRetries+1),
+	)
+}
+
+func (p *WebhookPublisher) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(p.cfg.Secret, body))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature header value for body, "sha256=<hex hmac>"
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+func TestWebhookDeliversSignedPayloadAfterRetry(t *testing.T) {
+	const secret = "s3cret"
+
+	var attempts atomic.Int32
+	received := make(chan domain.LinkEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// First attempt fails so the dispatcher has to retry
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(SignatureHeader), Sign(secret, body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+
+		var event domain.LinkEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		received <- event
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	publisher := NewWebhookPublisher(WebhookConfig{
+		URL:          server.URL,
+		Secret:       secret,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	}, zap.NewNop(), m)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go publisher.Run(ctx)
+
+	publisher.Publish(ctx, domain.LinkEvent{
+		Type:        domain.EventURLCreated,
+		ShortCode:   "abc123",
+		OriginalURL: "https://example.com",
+	})
+
+	select {
+	case event := <-received:
+		if event.Type != domain.EventURLCreated || event.ShortCode != "abc123" || event.OriginalURL != "https://example.com" {
+			t.Errorf("received event = %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was never delivered")
+	}
+
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("attempts = %d, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.WebhookDeliveriesTotal.WithLabelValues("failure")); got != 1 {
+		t.Errorf("failed deliveries = %v, want 1", got)
+	}
+}
+
+func TestWebhookDeadLettersAfterExhaustingRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	publisher := NewWebhookPublisher(WebhookConfig{
+		URL:          server.URL,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	}, zap.NewNop(), m)
+
+	publisher.deliver(context.Background(), domain.LinkEvent{Type: domain.EventURLExpired, ShortCode: "abc123"})
+
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+	if got := testutil.ToFloat64(m.WebhookDeadLetterTotal.WithLabelValues(string(domain.EventURLExpired))); got != 1 {
+		t.Errorf("dead letters = %v, want 1", got)
+	}
+}
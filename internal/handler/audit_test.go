@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/middleware"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"go.uber.org/zap"
+)
+
+func TestMutatingEndpointsWriteOneAuditRecord(t *testing.T) {
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+	urlRepo := memory.NewURLRepository()
+	auditLog := memory.NewAuditLog()
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	svc := service.NewURLService(urlRepo, memory.NewCacheRepository(time.Hour), keyGen, nil, urlRepo, zap.NewNop(), m, service.URLServiceConfig{
+		BaseURL:     "http://short.test",
+		AllowCustom: true,
+		MaxTTL:      24 * time.Hour,
+		AuditLog:    auditLog,
+	})
+	h := NewURLHandler(svc, zap.NewNop(), m)
+
+	// Wired like main: API keys for everyone, the admin token for /admin
+	router := gin.New()
+	router.Use(middleware.APIKeyAuth(map[string]string{"key-alice": "alice"}, "admin-secret"))
+	api := router.Group("/api/v1")
+	api.POST("/shorten", h.CreateURL)
+	api.POST("/aliases/reserve", h.ReserveAlias)
+	api.POST("/urls/:shortCode/enable", h.EnableURL)
+	api.POST("/urls/:shortCode/disable", h.DisableURL)
+	api.POST("/bulk/enable", h.BulkEnableURLs)
+	api.POST("/bulk/disable", h.BulkDisableURLs)
+	api.DELETE("/urls/:shortCode", h.DeleteURL)
+	api.POST("/urls/:shortCode/restore", h.RestoreURL)
+	admin := api.Group("/admin", middleware.AdminAuth("admin-secret"))
+	admin.PATCH("/urls/:shortCode/expiry", h.UpdateURLExpiry)
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		body      string
+		header    [2]string
+		action    domain.AuditAction
+		codes     []string
+		actor     string
+		actorType string
+	}{
+		{"create", http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com","custom_alias":"first"}`,
+			[2]string{middleware.APIKeyHeader, "key-alice"}, domain.AuditURLCreate, []string{"first"}, "alice", domain.ActorUser},
+		{"create anonymously", http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com","custom_alias":"second"}`,
+			[2]string{}, domain.AuditURLCreate, []string{"second"}, "", domain.ActorAnonymous},
+		{"reserve", http.MethodPost, "/api/v1/aliases/reserve", `{"alias":"later"}`,
+			[2]string{middleware.APIKeyHeader, "key-alice"}, domain.AuditAliasReserve, []string{"later"}, "alice", domain.ActorUser},
+		{"disable", http.MethodPost, "/api/v1/urls/first/disable", "",
+			[2]string{middleware.APIKeyHeader, "key-alice"}, domain.AuditURLDisable, []string{"first"}, "alice", domain.ActorUser},
+		{"enable", http.MethodPost, "/api/v1/urls/first/enable", "",
+			[2]string{middleware.APIKeyHeader, "key-alice"}, domain.AuditURLEnable, []string{"first"}, "alice", domain.ActorUser},
+		{"bulk disable", http.MethodPost, "/api/v1/bulk/disable", `{"short_codes":["first","second","missing"]}`,
+			[2]string{middleware.APIKeyHeader, "key-alice"}, domain.AuditURLDisable, []string{"first"}, "alice", domain.ActorUser},
+		{"bulk enable", http.MethodPost, "/api/v1/bulk/enable", `{"short_codes":["first","second"]}`,
+			[2]string{middleware.APIKeyHeader, "key-alice"}, domain.AuditURLEnable, []string{"first"}, "alice", domain.ActorUser},
+		{"expiry", http.MethodPatch, "/api/v1/admin/urls/first/expiry", `{"expires_at":"` + future + `"}`,
+			[2]string{"Authorization", "Bearer admin-secret"}, domain.AuditURLExpiry, []string{"first"}, "", domain.ActorAdmin},
+		{"delete", http.MethodDelete, "/api/v1/urls/first", "",
+			[2]string{middleware.APIKeyHeader, "key-alice"}, domain.AuditURLDelete, []string{"first"}, "alice", domain.ActorUser},
+		{"restore", http.MethodPost, "/api/v1/urls/first/restore", "",
+			[2]string{middleware.APIKeyHeader, "key-alice"}, domain.AuditURLRestore, []string{"first"}, "alice", domain.ActorUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(auditLog.Entries())
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.header[0] != "" {
+				req.Header.Set(tt.header[0], tt.header[1])
+			}
+			req.RemoteAddr = "203.0.113.7:40000"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code >= 300 {
+				t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+			}
+
+			entries := auditLog.Entries()
+			if len(entries) != before+1 {
+				t.Fatalf("audit records written = %d, want exactly 1", len(entries)-before)
+			}
+			got := entries[len(entries)-1]
+			if got.Action != tt.action || !slices.Equal(got.ShortCodes, tt.codes) {
+				t.Errorf("record = %s %v, want %s %v", got.Action, got.ShortCodes, tt.action, tt.codes)
+			}
+			if got.Actor != tt.actor || got.ActorType != tt.actorType {
+				t.Errorf("actor = %q (%s), want %q (%s)", got.Actor, got.ActorType, tt.actor, tt.actorType)
+			}
+			if got.ActorIP != "203.0.113.7" {
+				t.Errorf("actor_ip = %q, want 203.0.113.7", got.ActorIP)
+			}
+			if time.Since(got.CreatedAt) > time.Minute {
+				t.Errorf("created_at = %v, want now", got.CreatedAt)
+			}
+		})
+	}
+
+	// Refused changes leave no trace
+	before := len(auditLog.Entries())
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/urls/missing/disable", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if n := len(auditLog.Entries()); n != before {
+		t.Errorf("failed disable wrote %d audit records", n-before)
+	}
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClickBuffer is an in-process click buffer not yet flushed to the database
+type ClickBuffer interface {
+	Buffered() map[string]int64
+}
+
+// BufferedClick is one code's unflushed clicks
+type BufferedClick struct {
+	ShortCode string `json:"short_code"`
+	Clicks    int64  `json:"clicks"`
+}
+
+// BufferedClicksReport is served by GET /admin/clicks/buffered
+type BufferedClicksReport struct {
+	TotalClicks int64           `json:"total_clicks"`
+	Codes       []BufferedClick `json:"codes"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
+
+// BufferedClicks lists this instance's unflushed clicks, busiest code first
+// Use case: debugging a click_count that lags url_redirects_total
+func BufferedClicks(buffer ClickBuffer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := BufferedClicksReport{Codes: []BufferedClick{}, GeneratedAt: time.Now()}
+		for code, n := range buffer.Buffered() {
+			report.Codes = append(report.Codes, BufferedClick{ShortCode: code, Clicks: n})
+			report.TotalClicks += n
+		}
+		sort.Slice(report.Codes, func(i, j int) bool {
+			if report.Codes[i].Clicks != report.Codes[j].Clicks {
+				return report.Codes[i].Clicks > report.Codes[j].Clicks
+			}
+			return report.Codes[i].ShortCode < report.Codes[j].ShortCode
+		})
+		c.JSON(http.StatusOK, report)
+	}
+}
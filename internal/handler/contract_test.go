@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+)
+
+// The wire contract: clients are written against these, so a field rename
+// must show up as a failing test and a deliberate schema change
+//
+//go:embed testdata/schemas/*.json
+var schemaFS embed.FS
+
+// loadSchema compiles testdata/schemas/<name>.json as draft 2020-12, with
+// format asserted so a malformed date-time or uri is a violation
+func loadSchema(t *testing.T, name string) *jsonschema.Schema {
+	t.Helper()
+	data, err := schemaFS.ReadFile("testdata/schemas/" + name + ".json")
+	if err != nil {
+		t.Fatalf("read schema %s: %v", name, err)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	url := "testdata/schemas/" + name + ".json"
+	if err := compiler.AddResource(url, bytes.NewReader(data)); err != nil {
+		t.Fatalf("parse schema %s: %v", name, err)
+	}
+	s, err := compiler.Compile(url)
+	if err != nil {
+		t.Fatalf("compile schema %s: %v", name, err)
+	}
+	return s
+}
+
+// violations returns every violation of s in the JSON document body, as
+// "<instance location>: <message>"
+func violations(s *jsonschema.Schema, body []byte) []string {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return []string{"invalid JSON: " + err.Error()}
+	}
+	err := s.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return []string{err.Error()}
+	}
+	var out []string
+	for _, e := range verr.BasicOutput().Errors {
+		// The outer entries only say "doesn't validate with ..."
+		if e.InstanceLocation == "" && strings.HasPrefix(e.Error, "doesn't validate with") {
+			continue
+		}
+		location := e.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		out = append(out, location+": "+e.Error)
+	}
+	return out
+}
+
+func TestResponsesMatchContract(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{AllowCustom: true})
+	env.seed(t, "seeded", "https://example.com/seeded")
+	env.do(http.MethodGet, "/seeded", "")
+
+	tests := []struct {
+		name       string
+		schema     string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+	}{
+		{"create", "create_url_response", http.MethodPost, "/api/v1/shorten",
+			`{"original_url":"https://example.com/a"}`, http.StatusCreated},
+		{"create with extras", "create_url_response", http.MethodPost, "/api/v1/shorten?include=qr,pixel",
+			`{"original_url":"https://example.com/b","custom_alias":"extras","expires_in":3600}`, http.StatusCreated},
+		{"stats", "aggregate_stats", http.MethodGet, "/api/v1/stats", "", http.StatusOK},
+		{"not found", "error_response", http.MethodGet, "/nope12", "", http.StatusNotFound},
+		{"bad request", "error_response", http.MethodPost, "/api/v1/shorten", `{"original_url":"not a url"}`, http.StatusBadRequest},
+		{"conflict", "error_response", http.MethodPost, "/api/v1/shorten",
+			`{"original_url":"https://example.com/c","custom_alias":"seeded"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.do(tt.method, tt.target, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			for _, violation := range violations(loadSchema(t, tt.schema), w.Body.Bytes()) {
+				t.Errorf("%s: %s", tt.schema, violation)
+			}
+		})
+	}
+}
+
+// The harness itself must reject drifted responses, or the test above
+// proves nothing
+func TestContractRejectsDriftedResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		body   string
+		want   string
+	}{
+		{"renamed field", "create_url_response",
+			`{"code":"abc123","short_url":"http://short.test/abc123","original_url":"https://example.com","created_at":"2025-01-02T03:04:05Z"}`,
+			"/: missing properties: 'short_code'"},
+		{"extra field", "error_response", `{"error":"not_found","message":"URL not found","detail":"x"}`,
+			"additionalProperties 'detail' not allowed"},
+		{"wrong type", "aggregate_stats",
+			`{"total_urls":"3","active_urls":1,"total_clicks":0,"top_urls":[],"generated_at":"2025-01-02T03:04:05Z"}`,
+			"/total_urls: expected integer, but got string"},
+		{"bad timestamp", "create_url_response",
+			`{"short_code":"abc123","short_url":"http://short.test/abc123","original_url":"https://example.com","created_at":"yesterday"}`,
+			"/created_at: 'yesterday' is not valid 'date-time'"},
+		{"bad nested item", "aggregate_stats",
+			`{"total_urls":1,"active_urls":1,"total_clicks":1,"top_urls":[{"short_code":"abc","click_count":-1,"created_at":"2025-01-02T03:04:05Z"}],"generated_at":"2025-01-02T03:04:05Z"}`,
+			"/top_urls/0/click_count: must be >= 0"},
+		{"error code format", "error_response", `{"error":"Not Found","message":"URL not found"}`,
+			"/error: does not match pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := violations(loadSchema(t, tt.schema), []byte(tt.body))
+			if !slices.ContainsFunc(got, func(v string) bool { return strings.Contains(v, tt.want) }) {
+				t.Errorf("violations = %q, want one mentioning %q", got, tt.want)
+			}
+		})
+	}
+}
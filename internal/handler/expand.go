@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/url-shortener/internal/metadata"
+	"go.uber.org/zap"
+)
+
+type ExpandHandler struct {
+	expander *metadata.Expander
+	logger   *zap.Logger
+}
+
+func NewExpandHandler(expander *metadata.Expander, logger *zap.Logger) *ExpandHandler {
+	return &ExpandHandler{
+		expander: expander,
+		logger:   logger,
+	}
+}
+
+// Expand serves GET /api/v1/expand?url=... with the URL's redirect chain
+// A chain that loops, runs too long or heads somewhere private still answers
+// 200 with truncated set; only a URL that can't be fetched at all fails.
+func (h *ExpandHandler) Expand(c *gin.Context) {
+	result, err := h.expander.Expand(c.Request.Context(), c.Query("url"))
+	switch {
+	case err == nil:
+		respond(c, http.StatusOK, result)
+	case errors.Is(err, metadata.ErrInvalidExpandURL):
+		respond(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_url",
+			Message: "url must be an absolute http or https URL",
+		})
+	case errors.Is(err, metadata.ErrExpandBlocked):
+		respond(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "forbidden_destination",
+			Message: "url points at a private or reserved address",
+		})
+	case errors.Is(err, metadata.ErrResponseTooLarge):
+		respond(c, http.StatusBadGateway, ErrorResponse{
+			Error:   "response_too_large",
+			Message: "url answered with a response larger than the fetch limit",
+		})
+	default:
+		h.logger.Debug("expand failed", zap.Error(err))
+		respond(c, http.StatusBadGateway, ErrorResponse{
+			Error:   "unreachable",
+			Message: "url could not be fetched",
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/url-shortener/internal/metadata"
+	"go.uber.org/zap"
+)
+
+func TestExpandEndpoint(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/s/abc" {
+			http.Redirect(w, r, "/landing", http.StatusMovedPermanently)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(upstream.Close)
+
+	newRouter := func(allowPrivate bool) *gin.Engine {
+		h := NewExpandHandler(metadata.NewExpander(metadata.ExpanderConfig{
+			Timeout:              time.Second,
+			AllowPrivateNetworks: allowPrivate,
+		}), zap.NewNop())
+		router := gin.New()
+		router.GET("/api/v1/expand", h.Expand)
+		return router
+	}
+	get := func(router *gin.Engine, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/expand?url="+url.QueryEscape(target), nil))
+		return w
+	}
+
+	w := get(newRouter(true), upstream.URL+"/s/abc")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var got metadata.Expansion
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.FinalURL != upstream.URL+"/landing" || len(got.Hops) != 2 || got.Truncated {
+		t.Errorf("got %+v, want two hops ending at /landing", got)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		code   string
+	}{
+		{"missing url", "", http.StatusBadRequest, "invalid_url"},
+		{"not http", "ftp://example.com/file", http.StatusBadRequest, "invalid_url"},
+		{"private address", upstream.URL + "/s/abc", http.StatusBadRequest, "forbidden_destination"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(newRouter(false), tt.target)
+			var body ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if w.Code != tt.status || body.Error != tt.code {
+				t.Errorf("status = %d, error = %q, want %d %q", w.Code, body.Error, tt.status, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"go.uber.org/zap"
+)
+
+// exportColumns is the CSV header of an export; its first columns are the
+// ones ImportURLs reads, so a CSV export imports back as it is
+var exportColumns = []string{
+	"short_code", "original_url", "expires_at", "user_id", "created_at", "updated_at",
+	"click_count", "is_active", "visibility", "signed", "click_rate_limit",
+	"passthrough_query", "prefix", "platform_destinations", "country_destinations",
+	"fallback_url", "source", "title", "description", "image_url",
+}
+
+// ndjsonContentType is the Accept value that makes GET /urls a stream
+const ndjsonContentType = "application/x-ndjson"
+
+// ndjsonFlushEvery is how many lines a stream writes between flushes, so
+// the client sees rows arrive instead of waiting on the server's buffer
+const ndjsonFlushEvery = 100
+
+// exportWriter writes the links of one export in its format
+type exportWriter interface {
+	start() error
+	write(link domain.ExportedURL) error
+	finish() error
+}
+
+// ExportURLs serves GET /api/v1/export?format=csv|json, a download of every
+// live link for backup or migration; ?include_inactive=true adds paused,
+// expired and deleted links
+//
+// Learning: rows are written as they are read, so the response starts
+// before the export is complete. Nothing is sent until the first page has
+// been read, which keeps a failing database a proper error response; a
+// failure after that can only cut the download short, and is logged.
+func (h *URLHandler) ExportURLs(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		respond(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "format must be csv or json",
+		})
+		return
+	}
+	includeInactive, _ := strconv.ParseBool(c.Query("include_inactive"))
+
+	var out exportWriter = &jsonExport{w: c.Writer}
+	contentType := "application/json"
+	if format == "csv" {
+		out = &csvExport{w: csv.NewWriter(c.Writer)}
+		contentType = "text/csv; charset=utf-8"
+	}
+
+	started := false
+	begin := func() error {
+		started = true
+		filename := fmt.Sprintf("links-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Status(http.StatusOK)
+		return out.start()
+	}
+
+	exported := 0
+	err := h.urlService.ExportURLs(c.Request.Context(), includeInactive, func(link domain.ExportedURL) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		exported++
+		return out.write(link)
+	})
+	if err == nil && !started {
+		err = begin()
+	}
+	if err == nil {
+		err = out.finish()
+	}
+
+	if err != nil {
+		if !started {
+			h.handleError(c, err)
+			return
+		}
+		h.logger.Error("export cut short", zap.Error(err), zap.Int("exported", exported))
+		return
+	}
+	h.logger.Info("links exported", zap.String("format", format), zap.Int("exported", exported))
+}
+
+// streamURLs answers GET /urls with Accept: application/x-ndjson: every link
+// from the cursor on, one JSON object per line, written as pages are read.
+// It is for the admin only, see URLService.StreamURLs.
+// Errors behave as in ExportURLs: before the first row they are a proper
+// error response, after it they cut the stream short and are logged.
+func (h *URLHandler) streamURLs(c *gin.Context, after *pagination.Cursor) {
+	started := false
+	begin := func() {
+		started = true
+		c.Header("Content-Type", ndjsonContentType)
+		c.Status(http.StatusOK)
+	}
+
+	enc := json.NewEncoder(c.Writer)
+	streamed := 0
+	err := h.urlService.StreamURLs(c.Request.Context(), after, func(url domain.URL) error {
+		if !started {
+			begin()
+		}
+		// Encode ends every object with the newline NDJSON needs
+		if err := enc.Encode(url); err != nil {
+			return err
+		}
+		streamed++
+		if streamed%ndjsonFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			h.handleError(c, err)
+			return
+		}
+		h.logger.Error("link stream cut short", zap.Error(err), zap.Int("streamed", streamed))
+		return
+	}
+	if !started {
+		begin()
+	}
+	c.Writer.Flush()
+}
+
+// jsonExport writes an export as one JSON array, an element at a time
+type jsonExport struct {
+	w     http.ResponseWriter
+	count int
+}
+
+func (e *jsonExport) start() error {
+	_, err := e.w.Write([]byte("["))
+	return err
+}
+
+func (e *jsonExport) write(link domain.ExportedURL) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	if e.count > 0 {
+		data = append([]byte(","), data...)
+	}
+	e.count++
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonExport) finish() error {
+	_, err := e.w.Write([]byte("]\n"))
+	return err
+}
+
+// csvExport writes an export as CSV with an exportColumns header
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e *csvExport) start() error {
+	return e.w.Write(exportColumns)
+}
+
+func (e *csvExport) write(link domain.ExportedURL) error {
+	destinations := func(m map[string]string) string {
+		if len(m) == 0 {
+			return ""
+		}
+		data, _ := json.Marshal(m)
+		return string(data)
+	}
+	timestamp := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	userID, rateLimit := "", ""
+	if link.UserID != nil {
+		userID = *link.UserID
+	}
+	if link.ClickRateLimit != nil {
+		rateLimit = strconv.Itoa(*link.ClickRateLimit)
+	}
+
+	if err := e.w.Write([]string{
+		link.ShortCode, link.OriginalURL, timestamp(link.ExpiresAt), userID,
+		timestamp(&link.CreatedAt), timestamp(&link.UpdatedAt),
+		strconv.FormatInt(link.ClickCount, 10), strconv.FormatBool(link.IsActive),
+		link.Visibility, strconv.FormatBool(link.Signed), rateLimit,
+		strconv.FormatBool(link.PassthroughQuery), link.Prefix,
+		destinations(link.PlatformDestinations), destinations(link.CountryDestinations),
+		link.FallbackURL, link.Source, link.Title, link.Description, link.ImageURL,
+	}); err != nil {
+		return err
+	}
+	return e.w.Error()
+}
+
+func (e *csvExport) finish() error {
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/subhammahanty235/url-shortener/internal/service"
+)
+
+// seedExport stores n links, more than one export page, and pauses
+// the first few of them
+func seedExport(t *testing.T, env *testEnv, n, paused int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		env.seed(t, fmt.Sprintf("exp%03d", i), fmt.Sprintf("https://example.com/%d", i))
+	}
+	for i := 0; i < paused; i++ {
+		if err := env.urlRepo.SetActive(context.Background(), fmt.Sprintf("exp%03d", i), false); err != nil {
+			t.Fatalf("failed to pause exp%03d: %v", i, err)
+		}
+	}
+}
+
+func TestExportURLsJSONSkipsInactive(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	seedExport(t, env, 130, 3)
+
+	w := env.do(http.MethodGet, "/api/v1/export", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment; filename=") || !strings.HasSuffix(cd, `.json"`) {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+
+	var links []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &links); err != nil {
+		t.Fatalf("export is not a JSON array: %v", err)
+	}
+	if len(links) != 127 {
+		t.Fatalf("expected the 127 active links, got %d", len(links))
+	}
+	seen := make(map[string]bool, len(links))
+	for _, link := range links {
+		code, _ := link["short_code"].(string)
+		if link["is_active"] != true {
+			t.Errorf("inactive link %s exported", code)
+		}
+		if _, ok := link["id"]; ok {
+			t.Errorf("link %s exported its internal id", code)
+		}
+		if seen[code] {
+			t.Errorf("link %s exported twice", code)
+		}
+		seen[code] = true
+	}
+
+	w = env.do(http.MethodGet, "/api/v1/export?include_inactive=true", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &links); err != nil {
+		t.Fatalf("export is not a JSON array: %v", err)
+	}
+	if len(links) != 130 {
+		t.Errorf("expected all 130 links with include_inactive, got %d", len(links))
+	}
+}
+
+func TestExportURLsCSV(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	seedExport(t, env, 105, 1)
+
+	w := env.do(http.MethodGet, "/api/v1/export?format=csv", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected a CSV content type, got %q", ct)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if got := strings.Join(records[0], ","); got != strings.Join(exportColumns, ",") {
+		t.Errorf("unexpected header %q", got)
+	}
+	if len(records)-1 != 104 {
+		t.Errorf("expected the 104 active links, got %d rows", len(records)-1)
+	}
+
+	// An export must import back: original_url and short_code are columns
+	columns, err := importHeader(records[0])
+	if err != nil {
+		t.Fatalf("export header doesn't import: %v", err)
+	}
+	if _, ok := columns["short_code"]; !ok {
+		t.Error("export header has no short_code column")
+	}
+
+	if w := env.do(http.MethodGet, "/api/v1/export?format=xml", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown format, got %d", w.Code)
+	}
+}
+
+func TestListURLsStreamsNDJSON(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	seedExport(t, env, 230, 3)
+
+	w := env.do(http.MethodGet, "/api/v1/urls", "", append([]string{"Accept", "application/x-ndjson"}, asAdmin...)...)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+	if !w.Flushed {
+		t.Error("expected the stream to be flushed")
+	}
+	body := w.Body.String()
+	if !strings.HasSuffix(body, "}\n") {
+		t.Fatalf("stream doesn't end with a complete line: %q", body[max(0, len(body)-20):])
+	}
+
+	// Unlike an export, the stream lists paused links too, as ListURLs does
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if len(lines) != 230 {
+		t.Fatalf("expected 230 lines, got %d", len(lines))
+	}
+	seen := make(map[string]bool, len(lines))
+	for i, line := range lines {
+		var link map[string]any
+		if err := json.Unmarshal([]byte(line), &link); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v", i+1, err)
+		}
+		code, _ := link["short_url"].(string)
+		if seen[code] {
+			t.Errorf("link %s streamed twice", code)
+		}
+		seen[code] = true
+	}
+
+	if w := env.do(http.MethodGet, "/api/v1/urls?offset=10", "", append([]string{"Accept", "application/x-ndjson"}, asAdmin...)...); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an offset stream, got %d", w.Code)
+	}
+	if w := env.do(http.MethodGet, "/api/v1/urls", "", asAdmin...); !strings.HasPrefix(w.Body.String(), `{"items":`) {
+		t.Errorf("expected the paged envelope without the NDJSON Accept, got %.40q", w.Body.String())
+	}
+}
+
+func TestListURLsStreamIsAdminOnly(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seedOwned(t, "mine", "https://example.com/a", "alice")
+
+	for _, caller := range []struct {
+		name   string
+		header []string
+		want   int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"user", asAlice, http.StatusForbidden},
+	} {
+		w := env.do(http.MethodGet, "/api/v1/urls", "", append([]string{"Accept", "application/x-ndjson"}, caller.header...)...)
+		if w.Code != caller.want {
+			t.Errorf("%s stream status = %d, want %d", caller.name, w.Code, caller.want)
+		}
+		if strings.Contains(w.Body.String(), "example.com") {
+			t.Errorf("%s stream leaked a link: %s", caller.name, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+// maxImportRows bounds one import, and with it the size of the report
+const maxImportRows = 10000
+
+// importColumns are the CSV columns an import understands; only
+// original_url is required and the header may list them in any order
+var importColumns = []string{"short_code", "original_url", "expires_at", "tags"}
+
+// importRowErrors are the create failures a row is reported with as they
+// are; anything else is an internal error and not shown to the caller
+var importRowErrors = []error{
+	domain.ErrInvalidURL,
+	domain.ErrInvalidShortCode,
+	domain.ErrShortCodeExists,
+	domain.ErrShortCodeReserved,
+	domain.ErrForbiddenDomain,
+	domain.ErrSchemeNotAllowed,
+	domain.ErrInvalidExpiry,
+	domain.ErrPermanentDisabled,
+	domain.ErrQuotaExceeded,
+	domain.ErrSourceNotAllowed,
+}
+
+// ImportURLs serves POST /api/v1/import: a CSV file, as the "file" part of a
+// multipart form or as the raw body, with a header row naming its columns
+//
+// Learning: the CSV is decoded straight from the request stream, a row at a
+// time, so the upload is never held in memory. Every row goes through the
+// same validation and Create path as POST /shorten; a bad row is reported and
+// skipped, it doesn't abort the rows after it.
+func (h *URLHandler) ImportURLs(c *gin.Context) {
+	body, err := importBody(c.Request)
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1 // short rows are reported per row, not fatal
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	columns, headerErr := importHeader(header)
+	if err != nil || headerErr != nil {
+		if headerErr == nil {
+			headerErr = fmt.Errorf("could not read the CSV header: %w", err)
+		}
+		respond(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: headerErr.Error(),
+		})
+		return
+	}
+
+	report := &domain.ImportReport{Rows: []domain.ImportRowResult{}}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			addImportRow(report, domain.ImportRowResult{Line: parseErr.Line, Status: domain.ImportRowFailed, Error: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			report.Error = "upload ended early: " + err.Error()
+			break
+		}
+		if len(report.Rows) == maxImportRows {
+			report.Error = fmt.Sprintf("only the first %d rows are imported", maxImportRows)
+			break
+		}
+
+		line, _ := reader.FieldPos(0)
+		addImportRow(report, h.importRow(c, line, columns, record))
+	}
+
+	h.logger.Info("CSV import finished", zap.Int("created", report.Created), zap.Int("failed", report.Failed))
+	respond(c, http.StatusOK, report)
+}
+
+func addImportRow(report *domain.ImportReport, result domain.ImportRowResult) {
+	if result.Status == domain.ImportRowCreated {
+		report.Created++
+	} else {
+		report.Failed++
+	}
+	report.Rows = append(report.Rows, result)
+}
+
+// importBody returns the CSV stream of an upload
+func importBody(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	parts, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := parts.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New(`the form has no "file" part`)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+// importHeader maps each known column to its index in the header row
+func importHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(importColumns))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for _, known := range importColumns {
+			if name == known {
+				columns[name] = i
+			}
+		}
+	}
+	if _, ok := columns["original_url"]; !ok {
+		return nil, fmt.Errorf("the header row must name the columns, at least original_url (known: %s)", strings.Join(importColumns, ","))
+	}
+	return columns, nil
+}
+
+// importRow validates and creates the link of one record
+func (h *URLHandler) importRow(c *gin.Context, line int, columns map[string]int, record []string) domain.ImportRowResult {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	result := domain.ImportRowResult{Line: line, ShortCode: field("short_code"), Status: domain.ImportRowFailed}
+
+	req := &domain.CreateURLRequest{OriginalURL: field("original_url")}
+	if result.ShortCode != "" {
+		alias := result.ShortCode
+		req.CustomAlias = &alias
+	}
+	if raw := field("expires_at"); raw != "" {
+		expiresAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			result.Error = "expires_at must be an RFC 3339 timestamp"
+			return result
+		}
+		seconds := int64(math.Ceil(time.Until(expiresAt).Seconds()))
+		if seconds <= 0 {
+			result.Error = "expires_at is in the past"
+			return result
+		}
+		req.ExpiresIn = &seconds
+	}
+	// Links have no tags to store them in; say so rather than drop them quietly
+	if field("tags") != "" {
+		result.Warning = "tags are not stored and were ignored"
+	}
+
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		result.Error = importValidationError(err)
+		return result
+	}
+
+	resp, err := h.urlService.Create(sourceContext(c), req)
+	if err != nil {
+		result.Error = "internal error"
+		for _, known := range importRowErrors {
+			if errors.Is(err, known) {
+				result.Error = known.Error()
+				break
+			}
+		}
+		if result.Error == "internal error" {
+			h.logger.Error("CSV import row failed", zap.Int("line", line), zap.Error(err))
+		}
+		return result
+	}
+
+	result.Status = domain.ImportRowCreated
+	result.ShortCode = resp.ShortCode
+	result.ShortURL = resp.ShortURL
+	return result
+}
+
+// importValidationError lists the invalid fields of a row, e.g.
+// "invalid original_url (url)"
+func importValidationError(err error) string {
+	errs, ok := fieldErrors(err)
+	if !ok {
+		return err.Error()
+	}
+	parts := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		parts = append(parts, fmt.Sprintf("%s (%s)", fe.Field, fe.Reason))
+	}
+	return "invalid " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+)
+
+func TestImportURLsReportsEachRow(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seed(t, "taken1", "https://example.com/existing")
+
+	future := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	csv := strings.Join([]string{
+		"original_url,short_code,expires_at,tags",
+		"https://example.com/a,import1,,",
+		"https://example.com/b,," + future + ",", // no short_code: a generated one
+		"not a url,import2,,",
+		"https://example.com/c,taken1,,",
+		"https://example.com/d,import3,yesterday,",
+		`https://example.com/e,import4,,"news,launch"`,
+		`https://example.com/"f,import5,,`,
+		"https://example.com/g,import6,2001-01-01T00:00:00Z,",
+	}, "\n")
+
+	w := env.do(http.MethodPost, "/api/v1/import", csv, "Content-Type", "text/csv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	var report domain.ImportReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+
+	want := []struct {
+		line   int
+		status string
+		error  string
+	}{
+		{2, domain.ImportRowCreated, ""},
+		{3, domain.ImportRowCreated, ""},
+		{4, domain.ImportRowFailed, "invalid original_url (url)"},
+		{5, domain.ImportRowFailed, domain.ErrShortCodeExists.Error()},
+		{6, domain.ImportRowFailed, "expires_at must be an RFC 3339 timestamp"},
+		{7, domain.ImportRowCreated, ""},
+		{8, domain.ImportRowFailed, `bare " in non-quoted-field`},
+		{9, domain.ImportRowFailed, "expires_at is in the past"},
+	}
+	if len(report.Rows) != len(want) || report.Created != 3 || report.Failed != 5 {
+		t.Fatalf("report = %d rows, %d created, %d failed; want 8, 3, 5: %+v", len(report.Rows), report.Created, report.Failed, report.Rows)
+	}
+	for i, row := range report.Rows {
+		if row.Line != want[i].line || row.Status != want[i].status || row.Error != want[i].error {
+			t.Errorf("row %d = %+v, want line %d %s %q", i, row, want[i].line, want[i].status, want[i].error)
+		}
+	}
+	if report.Rows[5].Warning == "" {
+		t.Error("tags were dropped without a warning")
+	}
+
+	// Created rows are real links
+	if w := env.do(http.MethodGet, "/import1", ""); w.Header().Get("Location") != "https://example.com/a" {
+		t.Errorf("redirect of an imported link = %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := env.do(http.MethodGet, "/"+report.Rows[1].ShortCode, ""); w.Header().Get("Location") != "https://example.com/b" {
+		t.Errorf("redirect of an imported generated code = %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestImportURLsAcceptsMultipartUpload(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("note", "fields before the file are skipped")
+	file, _ := form.CreateFormFile("file", "links.csv")
+	file.Write([]byte("short_code,original_url\nupload1,https://example.com/a\n"))
+	form.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	env.router.ServeHTTP(w, req)
+
+	var report domain.ImportReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if w.Code != http.StatusOK || report.Created != 1 {
+		t.Fatalf("status = %d, report %s", w.Code, w.Body.String())
+	}
+
+	// Without a header naming original_url nothing can be imported
+	if w := env.do(http.MethodPost, "/api/v1/import", "https://example.com/a,abc123\n", "Content-Type", "text/csv"); w.Code != http.StatusBadRequest {
+		t.Errorf("headerless CSV status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// createIncludes are the optional extras a client can ask for on create
+// with ?include=qr,pixel
+type createIncludes struct {
+	qr    bool
+	pixel bool
+}
+
+// qrCodeSize is the rendered QR code width and height in pixels
+const qrCodeSize = 256
+
+// parseIncludes reads a comma-separated include list, rejecting unknown values
+// so a typo doesn't silently return a response without the field
+func parseIncludes(raw string) (createIncludes, error) {
+	var inc createIncludes
+	if raw == "" {
+		return inc, nil
+	}
+
+	for _, part := range strings.Split(raw, ",") {
+		switch strings.TrimSpace(part) {
+		case "qr":
+			inc.qr = true
+		case "pixel":
+			inc.pixel = true
+		case "":
+		default:
+			return inc, fmt.Errorf("unknown include %q", part)
+		}
+	}
+	return inc, nil
+}
+
+// qrDataURI renders content as a PNG QR code embedded in a data URI
+func qrDataURI(content string) (string, error) {
+	png, err := qrcode.Encode(content, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png), nil
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+)
+
+// LoadConfig sizes the request rate window and describes the pools
+// A pool size of 0 leaves that pool out of the report (e.g. memory backend)
+type LoadConfig struct {
+	Window         time.Duration // request rate is averaged over this
+	CacheFor       time.Duration // a report is reused for this long
+	DBMaxOpenConns int
+	RedisPoolSize  int
+}
+
+// LoadReport is the autoscaling hint served by GET /api/v1/load
+type LoadReport struct {
+	InFlightRequests  int64     `json:"in_flight_requests"`
+	RequestsPerSecond float64   `json:"requests_per_second"`
+	RateWindowSeconds float64   `json:"rate_window_seconds"`
+	Database          *PoolLoad `json:"database,omitempty"`
+	Redis             *PoolLoad `json:"redis,omitempty"`
+	GeneratedAt       time.Time `json:"generated_at"`
+}
+
+// PoolLoad is how much of a connection pool is in use
+// Saturation is InUse/Max: near 1 new work waits for a connection
+type PoolLoad struct {
+	InUse      int64   `json:"in_use"`
+	Max        int     `json:"max"`
+	Saturation float64 `json:"saturation"`
+}
+
+type loadSample struct {
+	at       time.Time
+	requests float64
+}
+
+// Load reports current load from the Prometheus metrics, for autoscalers
+// that can't scrape Prometheus
+//
+// Learning: the request rate comes from http_requests_total sampled once a
+// second by Run; the rate is the counter's increase across the window, the
+// same thing rate() computes in PromQL. Pool numbers are only as fresh as
+// the pool stats samplers (DB_STATS_INTERVAL, REDIS_STATS_INTERVAL).
+type Load struct {
+	metrics *metrics.Metrics
+	cfg     LoadConfig
+	now     func() time.Time
+
+	mu       sync.Mutex
+	samples  []loadSample // oldest first, spanning at most cfg.Window
+	report   *LoadReport
+	reportAt time.Time
+}
+
+func NewLoad(m *metrics.Metrics, cfg LoadConfig) *Load {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.CacheFor <= 0 {
+		cfg.CacheFor = time.Second
+	}
+	return &Load{
+		metrics: m,
+		cfg:     cfg,
+		now:     time.Now,
+	}
+}
+
+// Run samples the request counter every second until ctx is cancelled
+func (l *Load) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	l.sample()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.sample()
+		}
+	}
+}
+
+func (l *Load) sample() {
+	requests := metrics.Sum(l.metrics.HTTPRequestsTotal)
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.samples = append(l.samples, loadSample{at: now, requests: requests})
+	// Keep one sample at or before the window start so the rate covers it all
+	cutoff := now.Add(-l.cfg.Window)
+	drop := 0
+	for drop+1 < len(l.samples) && !l.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	l.samples = append(l.samples[:0], l.samples[drop:]...)
+}
+
+// Load serves GET /api/v1/load
+func (l *Load) Load(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	respond(c, http.StatusOK, l.Report())
+}
+
+// Report returns the current load, reusing one computed within CacheFor
+func (l *Load) Report() *LoadReport {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.report != nil && now.Sub(l.reportAt) < l.cfg.CacheFor {
+		return l.report
+	}
+
+	report := &LoadReport{
+		InFlightRequests: int64(metrics.Sum(l.metrics.HTTPRequestsActive)),
+		GeneratedAt:      now,
+	}
+	if n := len(l.samples); n >= 2 {
+		first, last := l.samples[0], l.samples[n-1]
+		if elapsed := last.at.Sub(first.at).Seconds(); elapsed > 0 {
+			// A counter only drops on restart, which can't happen in-process
+			report.RequestsPerSecond = (last.requests - first.requests) / elapsed
+			report.RateWindowSeconds = elapsed
+		}
+	}
+	if l.cfg.DBMaxOpenConns > 0 {
+		report.Database = poolLoad(metrics.Sum(l.metrics.DBConnectionsActive), l.cfg.DBMaxOpenConns)
+	}
+	if l.cfg.RedisPoolSize > 0 {
+		inUse := metrics.Sum(l.metrics.RedisPoolTotalConns) - metrics.Sum(l.metrics.RedisPoolIdleConns)
+		report.Redis = poolLoad(inUse, l.cfg.RedisPoolSize)
+	}
+
+	l.report, l.reportAt = report, now
+	return report
+}
+
+func poolLoad(inUse float64, max int) *PoolLoad {
+	if inUse < 0 {
+		inUse = 0
+	}
+	return &PoolLoad{
+		InUse:      int64(inUse),
+		Max:        max,
+		Saturation: inUse / float64(max),
+	}
+}
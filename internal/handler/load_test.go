@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+)
+
+func TestLoadReport(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	load := NewLoad(m, LoadConfig{Window: 10 * time.Second, DBMaxOpenConns: 20, RedisPoolSize: 10})
+	now := time.Unix(1_700_000_000, 0)
+	load.now = func() time.Time { return now }
+
+	// 30 requests a second across two routes, sampled once a second
+	for i := 0; i < 15; i++ {
+		load.sample()
+		m.HTTPRequestsTotal.WithLabelValues("/:shortCode", "GET", "301").Add(20)
+		m.HTTPRequestsTotal.WithLabelValues("/api/v1/shorten", "POST", "201").Add(10)
+		now = now.Add(time.Second)
+	}
+	load.sample()
+	m.HTTPRequestsActive.Set(7)
+	m.DBConnectionsActive.Set(15)
+	m.RedisPoolTotalConns.Set(6)
+	m.RedisPoolIdleConns.Set(4)
+
+	router := gin.New()
+	router.GET("/api/v1/load", load.Load)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/load", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, key := range []string{"in_flight_requests", "requests_per_second", "rate_window_seconds", "database", "redis", "generated_at"} {
+		if _, ok := body[key]; !ok {
+			t.Errorf("response is missing %q: %s", key, w.Body)
+		}
+	}
+
+	var report LoadReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if report.InFlightRequests != 7 {
+		t.Errorf("in_flight_requests = %d, want the gauge's 7", report.InFlightRequests)
+	}
+	if report.RequestsPerSecond != 30 || report.RateWindowSeconds != 10 {
+		t.Errorf("rate = %v/s over %vs, want 30/s over the 10s window", report.RequestsPerSecond, report.RateWindowSeconds)
+	}
+	if report.Database == nil || report.Database.InUse != 15 || report.Database.Saturation != 0.75 {
+		t.Errorf("database = %+v, want 15 of 20 in use", report.Database)
+	}
+	if report.Redis == nil || report.Redis.InUse != 2 || report.Redis.Saturation != 0.2 {
+		t.Errorf("redis = %+v, want 2 of 10 in use", report.Redis)
+	}
+
+	// Within CacheFor the same report is served
+	m.HTTPRequestsActive.Set(50)
+	if got := load.Report().InFlightRequests; got != 7 {
+		t.Errorf("cached in_flight_requests = %d, want 7", got)
+	}
+	now = now.Add(time.Second)
+	if got := load.Report().InFlightRequests; got != 50 {
+		t.Errorf("fresh in_flight_requests = %d, want 50", got)
+	}
+}
+
+func TestLoadReportOmitsUnconfiguredPools(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	report := NewLoad(m, LoadConfig{}).Report()
+
+	if report.Database != nil || report.Redis != nil {
+		t.Errorf("pools = %+v / %+v, want none without pool sizes", report.Database, report.Redis)
+	}
+	if report.RequestsPerSecond != 0 {
+		t.Errorf("requests_per_second = %v before any samples, want 0", report.RequestsPerSecond)
+	}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"go.uber.org/zap"
+)
+
+// transparentGIF is the smallest valid 1x1 transparent GIF89a (43 bytes)
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+type PixelHandler struct {
+	tracking *service.TrackingService
+	logger   *zap.Logger
+}
+
+func NewPixelHandler(tracking *service.TrackingService, logger *zap.Logger) *PixelHandler {
+	return &PixelHandler{
+		tracking: tracking,
+		logger:   logger,
+	}
+}
+
+// Pixel serves GET /p/:file where file is "<shortCode>.gif" and records an open
+// ?prefix= picks the link like on the management API, see
+// URLService.PixelURL.
+// It fails open: whatever happens to the event, the client gets the pixel, so
+// a broken image never shows up in someone's email
+func (h *PixelHandler) Pixel(c *gin.Context) {
+	shortCode, ok := strings.CutSuffix(c.Param("file"), ".gif")
+	if ok && shortCode != "" {
+		ctx := h.tracking.LinkContext(c.Request.Context(), c.Query("prefix"))
+		ctx = domain.WithVisitor(ctx, domain.Visitor{
+			UserAgent:      c.Request.UserAgent(),
+			Referrer:       c.Request.Referer(),
+			AcceptLanguage: c.GetHeader("Accept-Language"),
+		})
+		err := h.tracking.RecordOpen(ctx, shortCode, domain.ClickEvent{
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			Referrer:  c.Request.Referer(),
+		})
+		if err != nil && !isExpectedLookupError(err) {
+			h.logger.Warn("failed to record pixel open", zap.Error(err), zap.String("short_code", shortCode))
+		}
+	}
+
+	// Opens are only counted if the client asks every time
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	c.Data(http.StatusOK, "image/gif", transparentGIF)
+}
+
+// isExpectedLookupError covers links that simply don't resolve, which aren't
+// worth a warning on a public endpoint
+func isExpectedLookupError(err error) bool {
+	return errors.Is(err, domain.ErrURLNotFound) ||
+		errors.Is(err, domain.ErrURLExpired) ||
+		errors.Is(err, domain.ErrURLDisabled) ||
+		errors.Is(err, domain.ErrUnauthorized) ||
+		errors.Is(err, domain.ErrForbidden)
+}
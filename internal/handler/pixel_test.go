@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/geo"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"go.uber.org/zap"
+)
+
+type failingClickEvents struct{}
+
+func (failingClickEvents) RecordClickEvent(ctx context.Context, event *domain.ClickEvent) error {
+	return errors.New("database is down")
+}
+
+func newPixelRouter(t *testing.T, events domain.ClickEventRepository, enricher *geo.Enricher) (*gin.Engine, *metrics.Metrics) {
+	t.Helper()
+
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	urlRepo := memory.NewURLRepository()
+	if err := urlRepo.Create(context.Background(), &domain.URL{ShortURL: "news01", OriginalURL: "https://example.com/"}); err != nil {
+		t.Fatalf("failed to seed: %v", err)
+	}
+	svc := service.NewURLService(urlRepo, memory.NewCacheRepository(time.Hour), keyGen, nil, urlRepo, zap.NewNop(), m,
+		service.URLServiceConfig{BaseURL: "http://short.test"})
+
+	router := gin.New()
+	router.GET("/p/:file", NewPixelHandler(service.NewTrackingService(svc, events, enricher, zap.NewNop(), m), zap.NewNop()).Pixel)
+	return router, m
+}
+
+func getPixel(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("User-Agent", "MailClient/1.0")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func assertPixel(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/gif" {
+		t.Errorf("Content-Type = %q, want image/gif", ct)
+	}
+	if !bytes.Equal(w.Body.Bytes(), transparentGIF) {
+		t.Errorf("body is not the tracking pixel")
+	}
+	img, err := gif.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("pixel is not a valid GIF: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 1 || b.Dy() != 1 {
+		t.Errorf("pixel size = %dx%d, want 1x1", b.Dx(), b.Dy())
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("pixel alpha = %d, want transparent", a)
+	}
+}
+
+func TestPixelRecordsOpenEvent(t *testing.T) {
+	events := memory.NewClickEventRepository()
+	router, m := newPixelRouter(t, events, nil)
+
+	assertPixel(t, getPixel(router, "/p/news01.gif"))
+
+	recorded := events.Events()
+	if len(recorded) != 1 {
+		t.Fatalf("recorded %d events, want 1", len(recorded))
+	}
+	if got := recorded[0]; got.ShortCode != "news01" || got.Type != domain.ClickEventOpen || got.UserAgent != "MailClient/1.0" {
+		t.Errorf("event = %+v, want an open for news01 with the request's user agent", got)
+	}
+	if got := testutil.ToFloat64(m.TrackingEventsTotal.WithLabelValues(domain.ClickEventOpen)); got != 1 {
+		t.Errorf("tracking_events_total{type=open} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.URLRedirectsTotal); got != 0 {
+		t.Errorf("url_redirects_total = %v, pixel opens must not count as redirects", got)
+	}
+}
+
+func TestPixelOpenCountryFromAcceptLanguage(t *testing.T) {
+	events := memory.NewClickEventRepository()
+	router, _ := newPixelRouter(t, events, geo.NewEnricher(nil, geo.EnricherConfig{InferFromAcceptLanguage: true}))
+
+	req := httptest.NewRequest(http.MethodGet, "/p/news01.gif", nil)
+	req.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.8")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assertPixel(t, w)
+
+	recorded := events.Events()
+	if len(recorded) != 1 {
+		t.Fatalf("recorded %d events, want 1", len(recorded))
+	}
+	if got := recorded[0]; got.Country != "DE" || got.CountrySource != domain.CountrySourceAcceptLanguage {
+		t.Errorf("country = %q, country_source = %q; want DE from accept_language", got.Country, got.CountrySource)
+	}
+}
+
+func TestPixelFailsOpen(t *testing.T) {
+	router, _ := newPixelRouter(t, failingClickEvents{}, nil)
+	assertPixel(t, getPixel(router, "/p/news01.gif"))
+
+	events := memory.NewClickEventRepository()
+	router, _ = newPixelRouter(t, events, nil)
+	for _, path := range []string{"/p/unknown.gif", "/p/news01.png"} {
+		assertPixel(t, getPixel(router, path))
+	}
+	if n := len(events.Events()); n != 0 {
+		t.Errorf("recorded %d events for unknown codes, want 0", n)
+	}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/problem"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"go.uber.org/zap"
+)
+
+func TestHandleErrorAsProblemDetails(t *testing.T) {
+	h := NewURLHandler(nil, zap.NewNop(), nil)
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{domain.ErrURLNotFound, http.StatusNotFound, "not_found"},
+		{domain.ErrURLExpired, http.StatusGone, "expired"},
+		{domain.ErrURLDisabled, http.StatusGone, "disabled"},
+		{domain.ErrURLDeleted, http.StatusGone, "deleted"},
+		{domain.ErrCodeRetired, http.StatusGone, "retired"},
+		{domain.ErrInvalidURL, http.StatusBadRequest, "invalid_url"},
+		{domain.ErrSchemeNotAllowed, http.StatusBadRequest, "scheme_not_allowed"},
+		{domain.ErrForbiddenDomain, http.StatusBadRequest, "forbidden_domain"},
+		{domain.ErrPermanentDisabled, http.StatusBadRequest, "permanent_not_allowed"},
+		{domain.ErrInvalidExpiry, http.StatusBadRequest, "invalid_expiry"},
+		{domain.ErrPrefixNotAllowed, http.StatusBadRequest, "prefix_not_allowed"},
+		{domain.ErrSourceNotAllowed, http.StatusBadRequest, "source_not_allowed"},
+		{domain.ErrSigningDisabled, http.StatusBadRequest, "signing_not_enabled"},
+		{domain.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+		{domain.ErrQuotaExceeded, http.StatusForbidden, "quota_exceeded"},
+		{domain.ErrForbidden, http.StatusForbidden, "forbidden"},
+		{domain.ErrShortCodeExists, http.StatusConflict, "conflict"},
+		{domain.ErrShortCodeReserved, http.StatusConflict, "reserved_short_code"},
+		{domain.ErrInvalidShortCode, http.StatusBadRequest, "invalid_short_code"},
+		{domain.ErrRateLimitExceeded, http.StatusTooManyRequests, "rate_limit_exceeded"},
+		{domain.ErrServiceUnavailable, http.StatusServiceUnavailable, "service_unavailable"},
+		{errors.New("boom"), http.StatusInternalServerError, "internal_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/urls/abc123", nil)
+			c.Request.Header.Set("Accept", problem.ContentType)
+			h.handleError(c, tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, problem.ContentType)
+			}
+			var got problem.Details
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode %s: %v", w.Body.String(), err)
+			}
+			want := problem.Details{
+				Type:     "urn:url-shortener:problem:" + tt.code,
+				Title:    http.StatusText(tt.status),
+				Status:   tt.status,
+				Instance: "/api/v1/urls/abc123",
+			}
+			if got.Detail == "" {
+				t.Error("detail is empty")
+			}
+			got.Detail = ""
+			if got != want {
+				t.Errorf("problem = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestErrorsKeepTheLegacyShapeByDefault(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+
+	for _, accept := range []string{"", "*/*", "application/json"} {
+		w := env.do(http.MethodGet, "/nosuch1", "", "Accept", accept)
+		if ct := w.Header().Get("Content-Type"); ct == problem.ContentType {
+			t.Errorf("Accept %q: Content-Type = %q, want the legacy JSON", accept, ct)
+		}
+		var body ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error != "not_found" {
+			t.Errorf("Accept %q: body = %s, want an ErrorResponse", accept, w.Body.String())
+		}
+	}
+
+	// Errors outside handleError, e.g. a malformed body, negotiate the same way
+	w := env.do(http.MethodPost, "/api/v1/shorten", "{", "Accept", problem.ContentType)
+	var got problem.Details
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Status != http.StatusBadRequest || got.Type == "" {
+		t.Errorf("bad request body = %s, want problem details", w.Body.String())
+	}
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Readiness backs /health/ready, which tells the load balancer whether to
+// send new traffic here. It is separate from /health (liveness): during a
+// shutdown drain the process is alive but should stop receiving requests.
+type Readiness struct {
+	draining atomic.Bool
+}
+
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// StartDraining flips readiness to 503 for the rest of the process lifetime
+func (r *Readiness) StartDraining() {
+	r.draining.Store(true)
+}
+
+func (r *Readiness) Ready(c *gin.Context) {
+	if r.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "draining",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ready",
+	})
+}
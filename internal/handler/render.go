@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/negotiate"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/problem"
+)
+
+// respond writes obj in the format the client negotiated via Accept
+// JSON is the default; high-volume internal callers can send
+// Accept: application/msgpack for a smaller, faster-to-parse body.
+// An ErrorResponse is sent as RFC 7807 problem details to clients accepting
+// application/problem+json.
+func respond(c *gin.Context, status int, obj any) {
+	if resp, ok := obj.(ErrorResponse); ok && problem.Write(c, status, resp.Error, resp.Message) {
+		return
+	}
+	negotiate.Render(c, status, obj)
+}
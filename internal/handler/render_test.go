@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"github.com/ugorji/go/codec"
+)
+
+func decodeMsgpack(t *testing.T, body []byte, v any) {
+	t.Helper()
+	var mh codec.MsgpackHandle
+	if err := codec.NewDecoderBytes(body, &mh).Decode(v); err != nil {
+		t.Fatalf("failed to decode msgpack body: %v", err)
+	}
+}
+
+func TestCreateURLResponseEncodings(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	body := `{"original_url":"https://example.com/landing","custom_alias":"promo1"}`
+
+	w := env.do(http.MethodPost, "/api/v1/shorten", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("json: status = %d, want 201 (body %s)", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("json: Content-Type = %q", ct)
+	}
+	var fromJSON domain.CreateURLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &fromJSON); err != nil {
+		t.Fatalf("json: failed to decode: %v", err)
+	}
+
+	w = env.do(http.MethodPost, "/api/v1/shorten",
+		`{"original_url":"https://example.com/landing","custom_alias":"promo2"}`,
+		"Accept", "application/msgpack")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("msgpack: status = %d, want 201", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/msgpack") {
+		t.Errorf("msgpack: Content-Type = %q", ct)
+	}
+	var fromMsgpack domain.CreateURLResponse
+	decodeMsgpack(t, w.Body.Bytes(), &fromMsgpack)
+
+	if fromJSON.ShortCode != "promo1" || fromMsgpack.ShortCode != "promo2" {
+		t.Errorf("short codes = %q / %q, want promo1 / promo2", fromJSON.ShortCode, fromMsgpack.ShortCode)
+	}
+	if fromMsgpack.OriginalURL != fromJSON.OriginalURL || fromMsgpack.ShortURL != "http://short.test/promo2" {
+		t.Errorf("msgpack response = %+v, want the same fields as JSON %+v", fromMsgpack, fromJSON)
+	}
+	if fromMsgpack.CreatedAt.IsZero() {
+		t.Error("msgpack created_at is zero, want the creation time")
+	}
+}
+
+func TestErrorResponsesFollowNegotiation(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+
+	w := env.do(http.MethodPost, "/api/v1/urls/missing/disable", "", "Accept", "application/x-msgpack")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", w.Code)
+	}
+	var resp ErrorResponse
+	decodeMsgpack(t, w.Body.Bytes(), &resp)
+	if resp.Error != "not_found" {
+		t.Errorf("error = %q, want not_found", resp.Error)
+	}
+
+	// Browsers and curl get JSON
+	w = env.do(http.MethodPost, "/api/v1/urls/missing/disable", "", "Accept", "text/html,*/*")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type for Accept: text/html = %q, want JSON", ct)
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RootBehavior is what a GET of "/" gets: there is no short code to look up
+type RootBehavior string
+
+const (
+	// RootNotFound answers 404 like an unknown code
+	RootNotFound RootBehavior = "not_found"
+
+	// RootLanding answers a small JSON document saying the service is up,
+	// for people (and uptime checks) that type in the bare domain
+	RootLanding RootBehavior = "landing"
+
+	// RootRedirect sends the visitor to a configured page, e.g. the
+	// marketing site
+	RootRedirect RootBehavior = "redirect"
+)
+
+// ParseRootBehavior validates a configured behavior, empty is not_found
+// The redirect mode needs an absolute http(s) URL.
+func ParseRootBehavior(s, redirectURL string) (RootBehavior, error) {
+	switch b := RootBehavior(s); b {
+	case "":
+		return RootNotFound, nil
+	case RootNotFound, RootLanding:
+		return b, nil
+	case RootRedirect:
+		u, err := url.Parse(redirectURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("root behavior redirect needs an absolute http(s) URL, got %q", redirectURL)
+		}
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown root behavior %q, want not_found, landing or redirect", s)
+	}
+}
+
+type RootResponse struct {
+	Status  string `json:"status"`
+	Service string `json:"service"`
+}
+
+// Root serves GET /
+// Learning: gin matches the static "/" before trying "/:shortCode", and an
+// empty segment never binds to the parameter, so the two can't collide.
+func Root(behavior RootBehavior, redirectURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch behavior {
+		case RootLanding:
+			respond(c, http.StatusOK, RootResponse{Status: "ok", Service: "url-shortener"})
+		case RootRedirect:
+			// Temporary: the page it points at is a marketing decision
+			c.Redirect(http.StatusFound, redirectURL)
+		default:
+			respond(c, http.StatusNotFound, ErrorResponse{
+				Error:   "not_found",
+				Message: "Nothing here, short links look like /abc123",
+			})
+		}
+	}
+}
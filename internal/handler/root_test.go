@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/subhammahanty235/url-shortener/internal/service"
+)
+
+func TestParseRootBehavior(t *testing.T) {
+	if b, err := ParseRootBehavior("", ""); err != nil || b != RootNotFound {
+		t.Errorf("ParseRootBehavior(\"\") = %q, %v; want not_found", b, err)
+	}
+	if _, err := ParseRootBehavior("redirect", "/relative"); err == nil {
+		t.Error("ParseRootBehavior(redirect) accepted a relative URL")
+	}
+	if _, err := ParseRootBehavior("teapot", ""); err == nil {
+		t.Error("ParseRootBehavior(teapot) accepted an unknown behavior")
+	}
+}
+
+func TestRootBehaviors(t *testing.T) {
+	newEnv := func(behavior RootBehavior, redirectURL string) *testEnv {
+		env := newTestEnv(t, service.URLServiceConfig{})
+		env.router.GET("/", Root(behavior, redirectURL))
+		env.seed(t, "abc123", "https://example.com/page")
+		return env
+	}
+
+	t.Run("not_found", func(t *testing.T) {
+		env := newEnv(RootNotFound, "")
+		w := env.do(http.MethodGet, "/", "")
+		var body ErrorResponse
+		if w.Code != http.StatusNotFound || json.Unmarshal(w.Body.Bytes(), &body) != nil || body.Error != "not_found" {
+			t.Errorf("GET / = %d %s, want a JSON 404", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("landing", func(t *testing.T) {
+		env := newEnv(RootLanding, "")
+		w := env.do(http.MethodGet, "/", "")
+		var body RootResponse
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &body) != nil || body.Status != "ok" {
+			t.Errorf("GET / = %d %s, want the landing document", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		env := newEnv(RootRedirect, "https://marketing.example.com/")
+		w := env.do(http.MethodGet, "/", "")
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "https://marketing.example.com/" {
+			t.Errorf("GET / = %d Location %q, want 302 to the marketing page", w.Code, w.Header().Get("Location"))
+		}
+
+		// Short codes still resolve next to the root route
+		w = env.do(http.MethodGet, "/abc123", "")
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://example.com/page" {
+			t.Errorf("GET /abc123 = %d Location %q, want the link's redirect", w.Code, w.Header().Get("Location"))
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteSegments returns the first path segment of every static route, the
+// names a root-level short code would collide with
+// "/api/v1/shorten" gives "api"; "/:shortCode" and "/" give nothing.
+func RouteSegments(routes gin.RoutesInfo) []string {
+	seen := make(map[string]bool)
+	var segments []string
+	for _, route := range routes {
+		segment, _, _ := strings.Cut(strings.TrimPrefix(route.Path, "/"), "/")
+		if segment == "" || strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") || seen[segment] {
+			continue
+		}
+		seen[segment] = true
+		segments = append(segments, segment)
+	}
+	return segments
+}
@@ -1,11 +1,18 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/useragent"
 	"github.com/subhammahanty235/url-shortener/internal/service"
 	"go.uber.org/zap"
 )
@@ -13,90 +20,628 @@ import (
 type URLHandler struct {
 	urlService *service.URLService
 	logger     *zap.Logger
+	metrics    *metrics.Metrics
 }
 
 func NewURLHandler(
 	urlService *service.URLService,
 	logger *zap.Logger,
+	m *metrics.Metrics,
 ) *URLHandler {
 	return &URLHandler{
 		urlService: urlService,
 		logger:     logger,
+		metrics:    m,
 	}
 }
 
+// ClientSourceHeader names the surface a create comes from (web, cli, ...)
+const ClientSourceHeader = "X-Client-Source"
+
+// sourceContext attaches the client's stated source to the request context
+func sourceContext(c *gin.Context) context.Context {
+	return domain.WithSource(c.Request.Context(), c.GetHeader(ClientSourceHeader))
+}
+
+// linkContext is the request context for a call naming a link by code,
+// narrowed to the link under ?prefix= when codes are unique per prefix
+func (h *URLHandler) linkContext(c *gin.Context) context.Context {
+	return h.urlService.LinkContext(c.Request.Context(), c.Query("prefix"))
+}
+
 func (h *URLHandler) CreateURL(c *gin.Context) {
 	var req *domain.CreateURLRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.Debug("invalid request body", zap.Error(err))
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "invalid_request",
-			Message: "Invalid request body: " + err.Error(),
+		h.bindError(c, err)
+		return
+	}
+	includes, err := parseIncludes(c.Query("include"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_include",
+			Message: "include must be a comma-separated list of: qr, pixel",
 		})
 		return
 	}
 
-	resp, err := h.urlService.Create(c.Request.Context(), req)
+	resp, err := h.urlService.Create(sourceContext(c), req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	if includes.qr {
+		// The link already exists, so a QR failure only drops the extra field
+		if resp.QRCode, err = qrDataURI(resp.ShortURL); err != nil {
+			h.logger.Warn("failed to render qr code", zap.Error(err), zap.String("short_code", resp.ShortCode))
+		}
+	}
+	if includes.pixel {
+		resp.PixelURL = h.urlService.PixelURL(req.Prefix, resp.ShortCode)
+	}
+	if resp.Existing {
+		// Nothing was created, the caller gets the link they asked for
+		respond(c, http.StatusOK, resp)
+		return
+	}
+	respond(c, http.StatusCreated, resp)
+}
+
+// ReserveAlias holds a custom alias for the caller's API key
+func (h *URLHandler) ReserveAlias(c *gin.Context) {
+	var req domain.ReserveAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.bindError(c, err)
+		return
+	}
+
+	resp, err := h.urlService.ReserveAlias(c.Request.Context(), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, resp)
+}
+
+// AliasAvailable serves GET /api/v1/aliases/:alias/available
+// Malformed aliases are refused before any lookup, with the same rules a
+// custom_alias is validated against.
+func (h *URLHandler) AliasAvailable(c *gin.Context) {
+	alias := c.Param("alias")
+	if !validAlias(alias) {
+		h.handleError(c, domain.ErrInvalidShortCode)
+		return
+	}
+
+	ctx := domain.WithClientIP(h.linkContext(c), c.ClientIP())
+	resp, err := h.urlService.CheckAlias(ctx, alias)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
-	c.JSON(http.StatusCreated, resp)
+	respond(c, http.StatusOK, resp)
+}
+
+// bindError answers a request whose body failed to bind or validate
+func (h *URLHandler) bindError(c *gin.Context, err error) {
+	h.logger.Debug("invalid request body", zap.Error(err))
+	if errs, ok := fieldErrors(err); ok {
+		respond(c, http.StatusBadRequest, ValidationErrorResponse{
+			Error:   "validation_failed",
+			Message: "One or more fields are invalid",
+			Errors:  errs,
+		})
+		return
+	}
+	respond(c, http.StatusBadRequest, ErrorResponse{
+		Error:   "invalid_request",
+		Message: "Invalid request body: " + err.Error(),
+	})
 }
 
 func (h *URLHandler) RedirectURL(c *gin.Context) {
+	h.redirect(c, "")
+}
+
+// RedirectPrefixed serves GET /<prefix>/:shortCode for one allowed prefix
+// Learning: gin can't route "/:prefix/:shortCode" next to "/:shortCode" (two
+// wildcards on one segment), so each prefix is mounted as a static segment.
+func (h *URLHandler) RedirectPrefixed(prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.redirect(c, prefix)
+	}
+}
+
+func (h *URLHandler) redirect(c *gin.Context, prefix string) {
+	// Timed from arrival when MetricsMiddleware is mounted, otherwise from here
+	start := c.GetTime(metrics.RequestStartKey)
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	shortCode := h.urlService.TrimTrailing(c.Param("shortCode"))
+	// Garbage (overlong, symbols, a mangled tag) can't match any link, so it
+	// is turned away before it costs a cache and database lookup
+	if !keygen.ValidShortCode(shortCode) {
+		h.handleError(c, domain.ErrInvalidShortCode)
+		return
+	}
+	// The client IP lets per-visitor click rate limits tell visitors apart,
+	// and like the rest of the visitor it is forwarded to analytics
+	ctx := domain.WithClientIP(c.Request.Context(), c.ClientIP())
+	ctx = domain.WithVisitor(ctx, domain.Visitor{
+		UserAgent:      c.Request.UserAgent(),
+		Referrer:       c.Request.Referer(),
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+	})
+	url, err := h.urlService.VisitPrefixed(ctx, prefix, shortCode)
+	if errors.Is(err, domain.ErrURLExpired) {
+		h.expired(c, err)
+		return
+	}
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// Links with per-platform destinations answer differently per device, so
+	// shared caches must key the redirect on the User-Agent too
+	platform := useragent.Platform(c.Request.UserAgent())
+	if len(url.PlatformDestinations) > 0 {
+		c.Header("Vary", "User-Agent")
+	}
+	// No header says where a visitor is, so an answer that depends on the
+	// country mustn't be cached by anyone but the visitor
+	country := h.urlService.VisitorCountry(ctx, url)
+	if len(url.CountryDestinations) > 0 {
+		c.Header("Cache-Control", "private")
+	}
+
+	// The merged target is validated too, not just the stored destination
+	target, err := safeRedirectTarget(h.urlService.RedirectTargetFor(url, platform, country, c.Request.URL.RawQuery), h.urlService.AllowsScheme)
+	if err != nil {
+		// A poisoned row is a server-side data problem: refuse to redirect and
+		// log it loudly so it can be cleaned up
+		h.logger.Warn("refusing to redirect to unsafe destination",
+			zap.String("short_code", shortCode),
+			zap.String("destination", strconv.Quote(url.OriginalURL)),
+		)
+		respond(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "unsafe_destination",
+			Message: "The destination of this link cannot be redirected to",
+		})
+		return
+	}
+
+	if h.urlService.CanonicalLinkHeader() {
+		// The link's destination without the click's passthrough query:
+		// tracking parameters of one visit don't belong in the canonical URL
+		canonical, err := safeRedirectTarget(url.DestinationFor(platform, country), h.urlService.AllowsScheme)
+		if err == nil {
+			c.Header("Link", canonicalLink(canonical))
+		}
+	}
+	c.Redirect(http.StatusMovedPermanently, target)
+	if h.metrics != nil {
+		// The header sits in the response buffer now; the write to the client
+		// happens after the handler returns and isn't counted
+		h.metrics.RedirectTTFB.Observe(time.Since(start).Seconds())
+	}
+}
+
+// expired answers a redirect of an expired link: to the link's own fallback
+// URL when it has one, otherwise the way the server is configured to, see
+// service.ExpiredBehavior
+func (h *URLHandler) expired(c *gin.Context, err error) {
+	behavior, fallback := h.urlService.ExpiredResponse()
+	var linkFallback *domain.ExpiredError
+	if errors.As(err, &linkFallback) {
+		behavior, fallback = service.ExpiredRedirect, linkFallback.FallbackURL
+	}
+	switch behavior {
+	case service.ExpiredNotFound:
+		h.handleError(c, domain.ErrURLNotFound)
+	case service.ExpiredRedirect:
+		if h.metrics != nil {
+			h.metrics.BusinessErrorTotal.WithLabelValues("expired").Inc()
+		}
+		// Temporary and uncached: the fallback page may change, and the
+		// code may be taken again by a new link
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, fallback)
+	default:
+		h.handleError(c, domain.ErrURLExpired)
+	}
+}
+
+func (h *URLHandler) EnableURL(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+func (h *URLHandler) DisableURL(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+func (h *URLHandler) setActive(c *gin.Context, active bool) {
 	shortCode := c.Param("shortCode")
-	url, err := h.urlService.GetURL(c.Request.Context(), shortCode)
+	if err := h.urlService.SetActive(h.linkContext(c), shortCode, active); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, URLStatusResponse{
+		ShortCode: shortCode,
+		IsActive:  active,
+	})
+}
+
+// DeleteURL serves DELETE /api/v1/urls/:shortCode
+// The link is gone for visitors at once but can be restored until purge_after
+func (h *URLHandler) DeleteURL(c *gin.Context) {
+	resp, err := h.urlService.DeleteURL(h.linkContext(c), c.Param("shortCode"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, resp)
+}
+
+// RestoreURL serves POST /api/v1/urls/:shortCode/restore
+func (h *URLHandler) RestoreURL(c *gin.Context) {
+	resp, err := h.urlService.RestoreURL(h.linkContext(c), c.Param("shortCode"))
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
+	respond(c, http.StatusOK, resp)
+}
 
-	c.Redirect(http.StatusMovedPermanently, url.OriginalURL)
+func (h *URLHandler) BulkEnableURLs(c *gin.Context) {
+	h.setActiveMany(c, true)
+}
+
+func (h *URLHandler) BulkDisableURLs(c *gin.Context) {
+	h.setActiveMany(c, false)
+}
 
+func (h *URLHandler) setActiveMany(c *gin.Context, active bool) {
+	var req domain.BulkStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.bindError(c, err)
+		return
+	}
+
+	resp, err := h.urlService.SetActiveMany(h.linkContext(c), req.ShortCodes, active)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, resp)
 }
 
+func (h *URLHandler) GetStats(c *gin.Context) {
+	stats, err := h.urlService.GetStats(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, stats)
+}
+
+// handleError maps domain errors to responses
+// Each handled error is also counted in business_error_total by reason, since
+// several share a status code; unexpected errors are only logged
 func (h *URLHandler) handleError(c *gin.Context, err error) {
 	switch {
 	case errors.Is(err, domain.ErrURLNotFound):
-		c.JSON(http.StatusNotFound, ErrorResponse{
+		h.businessError(c, http.StatusNotFound, "not_found", ErrorResponse{
 			Error:   "not_found",
 			Message: "URL not found",
 		})
 	case errors.Is(err, domain.ErrURLExpired):
-		c.JSON(http.StatusGone, ErrorResponse{
+		h.businessError(c, http.StatusGone, "expired", ErrorResponse{
 			Error:   "expired",
 			Message: "URL has expired",
 		})
+	case errors.Is(err, domain.ErrURLDisabled):
+		h.businessError(c, http.StatusGone, "disabled", ErrorResponse{
+			Error:   "disabled",
+			Message: "URL has been disabled by its owner",
+		})
+	case errors.Is(err, domain.ErrURLDeleted):
+		h.businessError(c, http.StatusGone, "deleted", ErrorResponse{
+			Error:   "deleted",
+			Message: "URL has been deleted",
+		})
+	case errors.Is(err, domain.ErrCodeRetired):
+		h.businessError(c, http.StatusGone, "retired", ErrorResponse{
+			Error:   "retired",
+			Message: "This short code is no longer in use",
+		})
 	case errors.Is(err, domain.ErrInvalidURL):
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		h.businessError(c, http.StatusBadRequest, "invalid_url", ErrorResponse{
 			Error:   "invalid_url",
 			Message: "Invalid URL format",
 		})
+	case errors.Is(err, domain.ErrSchemeNotAllowed):
+		h.businessError(c, http.StatusBadRequest, "scheme_not_allowed", ErrorResponse{
+			Error:   "scheme_not_allowed",
+			Message: "Destination URL scheme is not enabled on this server",
+		})
+	case errors.Is(err, domain.ErrForbiddenDomain):
+		h.businessError(c, http.StatusBadRequest, "forbidden_domain", ErrorResponse{
+			Error:   "forbidden_domain",
+			Message: "Destination domain is not allowed",
+		})
+	case errors.Is(err, domain.ErrPermanentDisabled):
+		h.businessError(c, http.StatusBadRequest, "permanent_not_allowed", ErrorResponse{
+			Error:   "permanent_not_allowed",
+			Message: "Links without expiry are not enabled on this server",
+		})
+	case errors.Is(err, domain.ErrInvalidExpiry):
+		// The service says which rule was broken
+		h.businessError(c, http.StatusBadRequest, "invalid_expiry", ErrorResponse{
+			Error:   "invalid_expiry",
+			Message: err.Error(),
+		})
+	case errors.Is(err, domain.ErrPrefixNotAllowed):
+		h.businessError(c, http.StatusBadRequest, "prefix_not_allowed", ErrorResponse{
+			Error:   "prefix_not_allowed",
+			Message: "Link prefix is not enabled on this server",
+		})
+	case errors.Is(err, domain.ErrSourceNotAllowed):
+		h.businessError(c, http.StatusBadRequest, "source_not_allowed", ErrorResponse{
+			Error:   "source_not_allowed",
+			Message: "The " + ClientSourceHeader + " header names a source this server doesn't accept",
+		})
+	case errors.Is(err, domain.ErrSigningDisabled):
+		h.businessError(c, http.StatusBadRequest, "signing_not_enabled", ErrorResponse{
+			Error:   "signing_not_enabled",
+			Message: "Signed links are not enabled on this server",
+		})
+	case errors.Is(err, domain.ErrUnauthorized):
+		h.businessError(c, http.StatusUnauthorized, "unauthorized", ErrorResponse{
+			Error:   "unauthorized",
+			Message: "An API key is required",
+		})
+	case errors.Is(err, domain.ErrQuotaExceeded):
+		h.businessError(c, http.StatusForbidden, "quota_exceeded", ErrorResponse{
+			Error:   "quota_exceeded",
+			Message: "Link quota reached, disable or let some links expire first",
+		})
+	case errors.Is(err, domain.ErrForbidden):
+		h.businessError(c, http.StatusForbidden, "forbidden", ErrorResponse{
+			Error:   "forbidden",
+			Message: "This API key is not allowed to do that",
+		})
 	case errors.Is(err, domain.ErrShortCodeExists):
-		c.JSON(http.StatusConflict, ErrorResponse{
+		h.businessError(c, http.StatusConflict, "conflict", ErrorResponse{
 			Error:   "conflict",
 			Message: "Short code already exists",
 		})
+	case errors.Is(err, domain.ErrShortCodeReserved):
+		h.businessError(c, http.StatusConflict, "reserved_short_code", ErrorResponse{
+			Error:   "reserved_short_code",
+			Message: "Short code is reserved for a server route",
+		})
 	case errors.Is(err, domain.ErrInvalidShortCode):
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		h.businessError(c, http.StatusBadRequest, "invalid_short_code", ErrorResponse{
 			Error:   "invalid_short_code",
 			Message: "Invalid short code format",
 		})
 	case errors.Is(err, domain.ErrRateLimitExceeded):
-		c.JSON(http.StatusTooManyRequests, ErrorResponse{
+		h.businessError(c, http.StatusTooManyRequests, "rate_limited", ErrorResponse{
 			Error:   "rate_limit_exceeded",
 			Message: "Rate limit exceeded",
 		})
+	case errors.Is(err, domain.ErrServiceUnavailable):
+		h.businessError(c, http.StatusServiceUnavailable, "service_unavailable", ErrorResponse{
+			Error:   "service_unavailable",
+			Message: "Service temporarily unavailable, please retry later",
+		})
 	default:
 		h.logger.Error("unhandled error", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respond(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "internal_error",
 			Message: "An internal error occurred",
 		})
 	}
 }
 
+// businessError responds with a handled domain error and counts it
+func (h *URLHandler) businessError(c *gin.Context, status int, reason string, resp ErrorResponse) {
+	if h.metrics != nil {
+		h.metrics.BusinessErrorTotal.WithLabelValues(reason).Inc()
+	}
+	respond(c, status, resp)
+}
+
+// ListURLs pages through links newest first
+// ?limit=N with either ?offset=N or ?cursor=<next_cursor from the previous page>
+func (h *URLHandler) ListURLs(c *gin.Context) {
+	page, err := pagination.ParseRequest(c.Query("limit"), c.Query("offset"), c.Query("cursor"))
+	if err != nil {
+		invalidPagination(c)
+		return
+	}
+
+	if c.NegotiateFormat(gin.MIMEJSON, ndjsonContentType) == ndjsonContentType {
+		// A stream is one cursor walk to the end, there is no page to skip to
+		if page.Offset > 0 {
+			invalidPagination(c)
+			return
+		}
+		h.streamURLs(c, page.After)
+		return
+	}
+
+	result, err := h.urlService.ListURLs(c.Request.Context(), page)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, result)
+}
+
+// ListURLsByDestination pages through the live links pointing at ?url=,
+// with the same paging parameters as ListURLs
+func (h *URLHandler) ListURLsByDestination(c *gin.Context) {
+	destination := c.Query("url")
+	if destination == "" {
+		respond(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "invalid_request",
+			Message: "The url query parameter is required",
+		})
+		return
+	}
+
+	page, err := pagination.ParseRequest(c.Query("limit"), c.Query("offset"), c.Query("cursor"))
+	if err != nil {
+		invalidPagination(c)
+		return
+	}
+
+	result, err := h.urlService.ListByDestination(c.Request.Context(), destination, page)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, result)
+}
+
+// UpdateURLDestination serves PUT /api/v1/urls/:shortCode/destination and
+// returns the history entry it recorded
+func (h *URLHandler) UpdateURLDestination(c *gin.Context) {
+	var req domain.UpdateDestinationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.bindError(c, err)
+		return
+	}
+
+	entry, err := h.urlService.UpdateDestination(h.linkContext(c), c.Param("shortCode"), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, entry)
+}
+
+// GetURLHistory pages through a link's destination changes, newest first,
+// with the same paging parameters as ListURLs
+func (h *URLHandler) GetURLHistory(c *gin.Context) {
+	page, err := pagination.ParseRequest(c.Query("limit"), c.Query("offset"), c.Query("cursor"))
+	if err != nil {
+		invalidPagination(c)
+		return
+	}
+
+	result, err := h.urlService.History(h.linkContext(c), c.Param("shortCode"), page)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, result)
+}
+
+// AddURLAlias gives a link one more code; 409 when the code is taken
+func (h *URLHandler) AddURLAlias(c *gin.Context) {
+	var req domain.AddAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.bindError(c, err)
+		return
+	}
+
+	resp, err := h.urlService.AddAlias(h.linkContext(c), c.Param("shortCode"), req.Alias)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, resp)
+}
+
+// RemoveURLAlias drops one of a link's aliases
+func (h *URLHandler) RemoveURLAlias(c *gin.Context) {
+	resp, err := h.urlService.RemoveAlias(h.linkContext(c), c.Param("shortCode"), c.Param("alias"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, resp)
+}
+
+// RegenerateURLCode moves a link to a new generated code; the old code
+// becomes an alias or answers 410, per URL_REGENERATE_OLD_CODE
+func (h *URLHandler) RegenerateURLCode(c *gin.Context) {
+	resp, err := h.urlService.RegenerateCode(h.linkContext(c), c.Param("shortCode"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, resp)
+}
+
+// GetURLAliases lists a link's aliases
+func (h *URLHandler) GetURLAliases(c *gin.Context) {
+	resp, err := h.urlService.Aliases(h.linkContext(c), c.Param("shortCode"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, resp)
+}
+
+func invalidPagination(c *gin.Context) {
+	respond(c, http.StatusBadRequest, ErrorResponse{
+		Error:   "invalid_pagination",
+		Message: "limit and offset must be positive integers, offset and cursor can't be combined, cursor must come from a previous page",
+	})
+}
+
+// GetURLInfo returns a link's metadata without redirecting or counting a click
+// Only the owner and the admin see the full record, anyone else gets the
+// public view without the owner and the owner's settings.
+func (h *URLHandler) GetURLInfo(c *gin.Context) {
+	ctx := h.linkContext(c)
+	url, err := h.urlService.GetURL(ctx, c.Param("shortCode"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	if !domain.ListScopeFor(ctx).Includes(url) {
+		respond(c, http.StatusOK, url.Public())
+		return
+	}
+	respond(c, http.StatusOK, url)
+}
+
+// GetURLExpiry shows a link's expiry, also for disabled and expired links
+func (h *URLHandler) GetURLExpiry(c *gin.Context) {
+	resp, err := h.urlService.GetExpiry(h.linkContext(c), c.Param("shortCode"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, resp)
+}
+
+// UpdateURLExpiry takes {"expires_at": ...}, {"extend_by_seconds": N} or
+// {"never_expires": true}
+func (h *URLHandler) UpdateURLExpiry(c *gin.Context) {
+	var req domain.ExpiryUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.bindError(c, err)
+		return
+	}
+
+	resp, err := h.urlService.UpdateExpiry(h.linkContext(c), c.Param("shortCode"), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, resp)
+}
+
 func (h *URLHandler) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status": "ok",
@@ -107,3 +652,8 @@ type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
 }
+
+type URLStatusResponse struct {
+	ShortCode string `json:"short_code"`
+	IsActive  bool   `json:"is_active"`
+}
@@ -0,0 +1,1960 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/middleware"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/geo"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"go.uber.org/zap"
+)
+
+// Credentials the test router accepts, header name then value for env.do
+var (
+	asAlice = []string{middleware.APIKeyHeader, "key-alice"}
+	asBob   = []string{middleware.APIKeyHeader, "key-bob"}
+	asAdmin = []string{"Authorization", "Bearer admin-secret"}
+)
+
+// testEnv wires a real service over in-memory storage behind the handler
+type testEnv struct {
+	router  *gin.Engine
+	svc     *service.URLService
+	urlRepo *memory.URLRepository
+	cache   *memory.CacheRepository
+	metrics *metrics.Metrics
+}
+
+func newTestEnv(t *testing.T, cfg service.URLServiceConfig) *testEnv {
+	t.Helper()
+
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://short.test"
+	}
+
+	env := &testEnv{
+		urlRepo: memory.NewURLRepository(),
+		cache:   memory.NewCacheRepository(time.Hour),
+		metrics: metrics.NewMetricsWithRegistry(prometheus.NewRegistry()),
+	}
+	if cfg.AliasScope == service.AliasScopePrefix {
+		env.urlRepo = memory.NewPrefixScopedURLRepository()
+	}
+	env.svc = service.NewURLService(env.urlRepo, env.cache, keyGen, nil, env.urlRepo, zap.NewNop(), env.metrics, cfg)
+	h := NewURLHandler(env.svc, zap.NewNop(), env.metrics)
+
+	env.router = gin.New()
+	env.router.Use(middleware.APIKeyAuth(map[string]string{"key-alice": "alice", "key-bob": "bob"}, "admin-secret"))
+	env.router.GET("/:shortCode", h.RedirectURL)
+	for _, prefix := range cfg.AllowedPrefixes {
+		env.router.GET("/"+prefix+"/:shortCode", h.RedirectPrefixed(prefix))
+	}
+	api := env.router.Group("/api/v1")
+	api.POST("/shorten", h.CreateURL)
+	api.POST("/aliases/reserve", h.ReserveAlias)
+	api.GET("/aliases/:alias/available", h.AliasAvailable)
+	api.POST("/urls/:shortCode/enable", h.EnableURL)
+	api.POST("/urls/:shortCode/disable", h.DisableURL)
+	api.POST("/urls/:shortCode/restore", h.RestoreURL)
+	api.DELETE("/urls/:shortCode", h.DeleteURL)
+	api.PUT("/urls/:shortCode/destination", h.UpdateURLDestination)
+	api.GET("/urls/:shortCode/history", h.GetURLHistory)
+	api.GET("/urls/:shortCode/aliases", h.GetURLAliases)
+	api.POST("/urls/:shortCode/aliases", h.AddURLAlias)
+	api.DELETE("/urls/:shortCode/aliases/:alias", h.RemoveURLAlias)
+	api.POST("/urls/:shortCode/regenerate", h.RegenerateURLCode)
+	api.POST("/import", h.ImportURLs)
+	api.GET("/export", h.ExportURLs)
+	api.POST("/bulk/enable", h.BulkEnableURLs)
+	api.POST("/bulk/disable", h.BulkDisableURLs)
+	api.GET("/stats", h.GetStats)
+	api.GET("/urls", h.ListURLs)
+	api.GET("/urls/by-destination", h.ListURLsByDestination)
+	api.GET("/urls/:shortCode", h.GetURLInfo)
+	api.GET("/admin/urls/:shortCode/expiry", h.GetURLExpiry)
+	api.PATCH("/admin/urls/:shortCode/expiry", h.UpdateURLExpiry)
+	env.svc.ReserveCodes(RouteSegments(env.router.Routes())...)
+	return env
+}
+
+// seed stores a row directly, bypassing create-time validation
+func (e *testEnv) seed(t *testing.T, shortCode, originalURL string) {
+	t.Helper()
+	if err := e.urlRepo.Create(context.Background(), &domain.URL{ShortURL: shortCode, OriginalURL: originalURL}); err != nil {
+		t.Fatalf("failed to seed %s: %v", shortCode, err)
+	}
+}
+
+// seedOwned is seed for a link created under owner's key
+func (e *testEnv) seedOwned(t *testing.T, shortCode, originalURL, owner string) {
+	t.Helper()
+	if err := e.urlRepo.Create(context.Background(), &domain.URL{ShortURL: shortCode, OriginalURL: originalURL, UserID: &owner}); err != nil {
+		t.Fatalf("failed to seed %s: %v", shortCode, err)
+	}
+}
+
+func (e *testEnv) do(method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	RegisterValidators()
+}
+
+func TestCreateURLReturnsFieldErrors(t *testing.T) {
+	// Validation fails before the service is touched, so no service is needed
+	h := NewURLHandler(nil, zap.NewNop(), nil)
+	router := gin.New()
+	router.POST("/api/v1/shorten", h.CreateURL)
+
+	tests := []struct {
+		name string
+		body string
+		want []FieldError
+	}{
+		{
+			name: "missing url",
+			body: `{}`,
+			want: []FieldError{{Field: "original_url", Reason: "required"}},
+		},
+		{
+			name: "invalid url",
+			body: `{"original_url":"not a url"}`,
+			want: []FieldError{{Field: "original_url", Reason: "url"}},
+		},
+		{
+			name: "invalid custom alias",
+			body: `{"original_url":"https://example.com","custom_alias":"bad alias!"}`,
+			want: []FieldError{{Field: "custom_alias", Reason: "shortcode"}},
+		},
+		{
+			name: "expires_in below -1",
+			body: `{"original_url":"https://example.com","expires_in":-5}`,
+			want: []FieldError{{Field: "expires_in", Reason: "min"}},
+		},
+		{
+			name: "multiple fields",
+			body: `{"custom_alias":"ab"}`,
+			want: []FieldError{
+				{Field: "original_url", Reason: "required"},
+				{Field: "custom_alias", Reason: "min"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+
+			var resp ValidationErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+			}
+			if len(resp.Errors) != len(tt.want) {
+				t.Fatalf("errors = %+v, want %+v", resp.Errors, tt.want)
+			}
+			for i := range tt.want {
+				if resp.Errors[i] != tt.want[i] {
+					t.Errorf("errors[%d] = %+v, want %+v", i, resp.Errors[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCreateURLMalformedJSONKeepsPlainError(t *testing.T) {
+	h := NewURLHandler(nil, zap.NewNop(), nil)
+	router := gin.New()
+	router.POST("/api/v1/shorten", h.CreateURL)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(`{"original_url":`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusBadRequest || resp.Error != "invalid_request" {
+		t.Errorf("got (%d, %q), want (400, %q)", w.Code, resp.Error, "invalid_request")
+	}
+}
+
+func TestRedirectRefusesUnsafeDestinations(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+
+	unsafe := map[string]string{
+		"crlf":       "https://example.com/\r\nSet-Cookie: session=evil",
+		"lf":         "https://example.com/\nLocation: https://evil.test",
+		"nul":        "https://example.com/\x00",
+		"tab":        "https://exa\tmple.com/",
+		"javascript": "javascript:alert(document.cookie)",
+		"data":       "data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==",
+		"backslash":  "https:\\\\evil.test",
+		"no-host":    "https:///path-only",
+		"relative":   "//evil.test/phish",
+	}
+	for code, destination := range unsafe {
+		env.seed(t, code, destination)
+	}
+	env.seed(t, "safe", "https://example.com/path?q=1#frag")
+
+	for code := range unsafe {
+		t.Run(code, func(t *testing.T) {
+			w := env.do(http.MethodGet, "/"+code, "")
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+			}
+			if loc := w.Header().Get("Location"); loc != "" {
+				t.Errorf("Location header set to %q on refused redirect", loc)
+			}
+		})
+	}
+
+	w := env.do(http.MethodGet, "/safe", "")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://example.com/path?q=1#frag" {
+		t.Errorf("safe redirect = (%d, %q)", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestCreatePermanentURL(t *testing.T) {
+	body := `{"original_url":"https://example.com","never_expires":true}`
+
+	env := newTestEnv(t, service.URLServiceConfig{DefaultTTL: time.Hour})
+	w := env.do(http.MethodPost, "/api/v1/shorten", body)
+	var errResp ErrorResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusBadRequest || errResp.Error != "permanent_not_allowed" {
+		t.Errorf("disallowed: got (%d, %q), want (400, permanent_not_allowed)", w.Code, errResp.Error)
+	}
+
+	env = newTestEnv(t, service.URLServiceConfig{DefaultTTL: time.Hour, AllowPermanent: true})
+	w = env.do(http.MethodPost, "/api/v1/shorten", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("allowed: status = %d, want 201 (body %s)", w.Code, w.Body.String())
+	}
+	var resp domain.CreateURLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ExpiresAt != nil {
+		t.Errorf("expires_at = %v, want omitted for a permanent link", resp.ExpiresAt)
+	}
+}
+
+func TestListURLsPaginates(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	for _, code := range []string{"link01", "link02", "link03"} {
+		env.seed(t, code, "https://example.com/"+code)
+	}
+
+	w := env.do(http.MethodGet, "/api/v1/urls?limit=2", "", asAdmin...)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %s)", w.Code, w.Body.String())
+	}
+	var first struct {
+		Items      []domain.URL `json:"items"`
+		NextCursor string       `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &first); err != nil {
+		t.Fatalf("failed to decode page: %v", err)
+	}
+	if len(first.Items) != 2 || first.NextCursor == "" {
+		t.Fatalf("first page = %d items, next_cursor %q; want 2 items and a cursor", len(first.Items), first.NextCursor)
+	}
+
+	w = env.do(http.MethodGet, "/api/v1/urls?limit=2&cursor="+first.NextCursor, "", asAdmin...)
+	var second struct {
+		Items      []domain.URL `json:"items"`
+		NextCursor string       `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &second); err != nil {
+		t.Fatalf("failed to decode page: %v", err)
+	}
+	if len(second.Items) != 1 || second.Items[0].ShortURL != "link01" || second.NextCursor != "" {
+		t.Errorf("second page = %+v, want only link01 and no cursor", second)
+	}
+
+	for _, query := range []string{"limit=abc", "offset=1&cursor=" + first.NextCursor, "cursor=garbage"} {
+		if w := env.do(http.MethodGet, "/api/v1/urls?"+query, "", asAdmin...); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/v1/urls?%s status = %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestListingsAreScopedToTheCaller(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	for code, owner := range map[string]string{"alice1": "alice", "bob001": "bob"} {
+		if err := env.urlRepo.Create(context.Background(), &domain.URL{
+			ShortURL:    code,
+			OriginalURL: "https://example.com/shared",
+			UserID:      &owner,
+			Visibility:  domain.VisibilityPrivate,
+		}); err != nil {
+			t.Fatalf("failed to seed %s: %v", code, err)
+		}
+	}
+	env.seed(t, "public", "https://example.com/shared")
+
+	tests := []struct {
+		name   string
+		header []string
+		want   string
+	}{
+		{"owner", asAlice, "alice1"},
+		{"admin", asAdmin, "alice1,bob001,public"},
+	}
+	targets := []string{"/api/v1/urls", "/api/v1/urls/by-destination?url=" + neturl.QueryEscape("https://example.com/shared")}
+	// Anonymous listings would let anyone enumerate every public code
+	for _, target := range targets {
+		if w := env.do(http.MethodGet, target, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous GET %s status = %d, want 401", target, w.Code)
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, target := range targets {
+				w := env.do(http.MethodGet, target, "", tt.header...)
+				var page pagination.Page[domain.URL]
+				if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || w.Code != http.StatusOK {
+					t.Fatalf("GET %s status = %d, body %s", target, w.Code, w.Body.String())
+				}
+				codes := make([]string, 0, len(page.Items))
+				for _, url := range page.Items {
+					codes = append(codes, url.ShortURL)
+				}
+				sort.Strings(codes)
+				if got := strings.Join(codes, ","); got != tt.want {
+					t.Errorf("GET %s listed %s, want %s", target, got, tt.want)
+				}
+			}
+		})
+	}
+
+	// History follows the link's visibility too
+	for _, caller := range intruders {
+		if w := env.do(http.MethodGet, "/api/v1/urls/alice1/history", "", caller.header...); w.Code != caller.want {
+			t.Errorf("%s history status = %d, want %d", caller.name, w.Code, caller.want)
+		}
+	}
+	if w := env.do(http.MethodGet, "/api/v1/urls/alice1/history", "", asAlice...); w.Code != http.StatusOK {
+		t.Errorf("owner history status = %d, want 200", w.Code)
+	}
+}
+
+func TestRedirectPrivateLinkStatusCodes(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	owner := "alice"
+	if err := env.urlRepo.Create(context.Background(), &domain.URL{
+		ShortURL:    "secret1",
+		OriginalURL: "https://example.com/secret",
+		UserID:      &owner,
+		Visibility:  domain.VisibilityPrivate,
+	}); err != nil {
+		t.Fatalf("failed to seed private link: %v", err)
+	}
+
+	w := env.do(http.MethodGet, "/secret1", "")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous redirect status = %d, want 401", w.Code)
+	}
+}
+
+func TestCreateURLIncludes(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	body := `{"original_url":"https://example.com/landing"}`
+
+	t.Run("absent", func(t *testing.T) {
+		w := env.do(http.MethodPost, "/api/v1/shorten", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+		}
+		var raw map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &raw)
+		for _, field := range []string{"qr_code", "pixel_url"} {
+			if _, ok := raw[field]; ok {
+				t.Errorf("response has %q without include", field)
+			}
+		}
+	})
+
+	t.Run("qr and pixel", func(t *testing.T) {
+		w := env.do(http.MethodPost, "/api/v1/shorten?include=qr,pixel", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+		}
+		var resp domain.CreateURLResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("bad response: %v", err)
+		}
+
+		const prefix = "data:image/png;base64,"
+		if !strings.HasPrefix(resp.QRCode, prefix) {
+			t.Fatalf("qr_code = %.40q..., want a PNG data URI", resp.QRCode)
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(resp.QRCode, prefix))
+		if err != nil {
+			t.Fatalf("qr_code is not valid base64: %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("qr_code is not a PNG: %v", err)
+		}
+		if b := img.Bounds(); b.Dx() != qrCodeSize || b.Dy() != qrCodeSize {
+			t.Errorf("qr_code size = %dx%d, want %dx%d", b.Dx(), b.Dy(), qrCodeSize, qrCodeSize)
+		}
+
+		if want := "http://short.test/p/" + resp.ShortCode + ".gif"; resp.PixelURL != want {
+			t.Errorf("pixel_url = %q, want %q", resp.PixelURL, want)
+		}
+	})
+
+	t.Run("pixel only", func(t *testing.T) {
+		w := env.do(http.MethodPost, "/api/v1/shorten?include=pixel", body)
+		var resp domain.CreateURLResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.QRCode != "" || resp.PixelURL == "" {
+			t.Errorf("qr_code = %q, pixel_url = %q; want only pixel_url", resp.QRCode, resp.PixelURL)
+		}
+	})
+
+	t.Run("unknown include", func(t *testing.T) {
+		count := func() int64 {
+			stats, _ := env.urlRepo.GetAggregateStats(context.Background(), 0)
+			return stats.TotalURLs
+		}
+		before := count()
+		w := env.do(http.MethodPost, "/api/v1/shorten?include=qr,barcode", body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+		if count() != before {
+			t.Error("a link was created despite the invalid include")
+		}
+	})
+}
+
+func TestReserveAliasEndpoint(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+
+	// The auth middleware isn't mounted here, so only anonymous is reachable
+	if w := env.do(http.MethodPost, "/api/v1/aliases/reserve", `{"alias":"promo"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous reserve status = %d, want 401", w.Code)
+	}
+	if w := env.do(http.MethodPost, "/api/v1/aliases/reserve", `{"alias":"a b"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid alias status = %d, want 400", w.Code)
+	}
+
+	owner := "alice"
+	until := time.Now().Add(time.Hour)
+	if err := env.urlRepo.Reserve(context.Background(), &domain.URL{ShortURL: "promo", UserID: &owner, ReservedUntil: &until}); err != nil {
+		t.Fatalf("failed to seed reservation: %v", err)
+	}
+	if w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com","custom_alias":"promo"}`); w.Code != http.StatusConflict {
+		t.Errorf("create over someone else's reservation status = %d, want 409", w.Code)
+	}
+	if w := env.do(http.MethodGet, "/promo", ""); w.Code != http.StatusNotFound {
+		t.Errorf("redirect of a reservation status = %d, want 404", w.Code)
+	}
+}
+
+func TestAliasAvailableEndpoint(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{
+		AliasCheckLimiter:   memory.NewClickLimiter(),
+		AliasCheckRateLimit: 8,
+	})
+	env.seed(t, "taken", "https://example.com/taken")
+	owner := "alice"
+	until := time.Now().Add(time.Hour)
+	if err := env.urlRepo.Reserve(context.Background(), &domain.URL{ShortURL: "held", UserID: &owner, ReservedUntil: &until}); err != nil {
+		t.Fatalf("failed to seed reservation: %v", err)
+	}
+	// Cached only: answered without asking the database
+	if err := env.cache.Set(context.Background(), &domain.URL{ShortURL: "cached", OriginalURL: "https://example.com", IsActive: true}, time.Hour); err != nil {
+		t.Fatalf("failed to seed cache: %v", err)
+	}
+
+	tests := []struct {
+		alias     string
+		status    int
+		available bool
+		reason    string
+	}{
+		{"free-alias", http.StatusOK, true, ""},
+		{"taken", http.StatusOK, false, domain.AliasTaken},
+		{"held", http.StatusOK, false, domain.AliasTaken},
+		{"cached", http.StatusOK, false, domain.AliasTaken},
+		{"admin", http.StatusOK, false, domain.AliasReserved},
+		{"API", http.StatusOK, false, domain.AliasReserved},
+		{"ab", http.StatusBadRequest, false, ""},
+		{"has.dot", http.StatusBadRequest, false, ""},
+	}
+	for _, tt := range tests {
+		w := env.do(http.MethodGet, "/api/v1/aliases/"+tt.alias+"/available", "")
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d (body %s)", tt.alias, w.Code, tt.status, w.Body.String())
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var resp domain.AliasAvailabilityResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tt.alias, err)
+		}
+		if resp.Available != tt.available || resp.Reason != tt.reason {
+			t.Errorf("%s: available = %v (%q), want %v (%q)", tt.alias, resp.Available, resp.Reason, tt.available, tt.reason)
+		}
+	}
+
+	// Six checks so far count against the limit, invalid formats don't
+	env.do(http.MethodGet, "/api/v1/aliases/another/available", "")
+	env.do(http.MethodGet, "/api/v1/aliases/another/available", "")
+	if w := env.do(http.MethodGet, "/api/v1/aliases/another/available", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("check beyond the rate limit status = %d, want 429", w.Code)
+	}
+}
+
+func TestRedirectClickRateLimit(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{
+		ClickLimiter:        memory.NewClickLimiter(),
+		ClickRateLimit:      5,
+		ClickRateLimitPerIP: true,
+	})
+	env.seed(t, "global", "https://example.com/global")
+
+	w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com/hot","custom_alias":"hotlink","click_rate_limit":2}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	redirect := func(code, ip string) int {
+		return env.do(http.MethodGet, "/"+code, "", "X-Forwarded-For", ip).Code
+	}
+
+	// The link's own limit wins over the server-wide one
+	for i := 0; i < 2; i++ {
+		if got := redirect("hotlink", "203.0.113.1"); got != http.StatusMovedPermanently {
+			t.Fatalf("redirect %d status = %d, want 301", i+1, got)
+		}
+	}
+	if got := redirect("hotlink", "203.0.113.1"); got != http.StatusTooManyRequests {
+		t.Errorf("burst beyond link limit status = %d, want 429", got)
+	}
+	// Other visitors have their own budget
+	if got := redirect("hotlink", "203.0.113.2"); got != http.StatusMovedPermanently {
+		t.Errorf("other visitor status = %d, want 301", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		if got := redirect("global", "203.0.113.1"); got != http.StatusMovedPermanently {
+			t.Fatalf("redirect %d status = %d, want 301", i+1, got)
+		}
+	}
+	if got := redirect("global", "203.0.113.1"); got != http.StatusTooManyRequests {
+		t.Errorf("burst beyond global limit status = %d, want 429", got)
+	}
+
+	// Throttled requests are counted apart from real traffic
+	if got := testutil.ToFloat64(env.metrics.URLRedirectsTotal); got != 8 {
+		t.Errorf("url_redirects_total = %v, want 8", got)
+	}
+	if got := testutil.ToFloat64(env.metrics.RedirectsRateLimitedTotal.WithLabelValues("link")); got != 1 {
+		t.Errorf("rate limited (link) = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(env.metrics.RedirectsRateLimitedTotal.WithLabelValues("global")); got != 1 {
+		t.Errorf("rate limited (global) = %v, want 1", got)
+	}
+	url, err := env.urlRepo.GetByShortCode(context.Background(), "hotlink")
+	if err != nil {
+		t.Fatalf("failed to load link: %v", err)
+	}
+	if url.ClickCount != 3 {
+		t.Errorf("click_count = %d, want 3 (rejected clicks must not count)", url.ClickCount)
+	}
+}
+
+// capturingSink keeps every event tracked, in order
+type capturingSink struct {
+	mu     sync.Mutex
+	events []domain.AnalyticsEvent
+}
+
+func (s *capturingSink) Track(ctx context.Context, event domain.AnalyticsEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func TestRedirectIsForwardedToAnalyticsSink(t *testing.T) {
+	sink := &capturingSink{}
+	env := newTestEnv(t, service.URLServiceConfig{AnalyticsSink: sink})
+	env.seed(t, "tracked", "https://example.com/tracked")
+
+	w := env.do(http.MethodGet, "/tracked", "", "User-Agent", "test-agent/1.0", "Referer", "https://news.example")
+	if w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect status = %d, want 301", w.Code)
+	}
+	// Misses are not redirects and aren't forwarded
+	env.do(http.MethodGet, "/missing", "")
+
+	if len(sink.events) != 1 {
+		t.Fatalf("tracked %d events, want 1", len(sink.events))
+	}
+	event := sink.events[0]
+	if event.Event != domain.AnalyticsEventRedirect || event.ShortCode != "tracked" || event.Destination != "https://example.com/tracked" {
+		t.Errorf("event = %+v, want the tracked redirect", event)
+	}
+	if event.UserAgent != "test-agent/1.0" || event.Referrer != "https://news.example" || event.IPAddress == "" {
+		t.Errorf("event visitor = %q, %q, %q; want user agent, referrer and IP", event.UserAgent, event.Referrer, event.IPAddress)
+	}
+}
+
+func TestClickRateLimitPerPrefixedLink(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{
+		ClickLimiter:    memory.NewClickLimiter(),
+		ClickRateLimit:  2,
+		AllowedPrefixes: []string{"news", "sports"},
+		AliasScope:      service.AliasScopePrefix,
+	})
+	for _, prefix := range []string{"news", "sports"} {
+		body := `{"original_url":"https://example.com/` + prefix + `","custom_alias":"sale","prefix":"` + prefix + `"}`
+		if w := env.do(http.MethodPost, "/api/v1/shorten", body); w.Code != http.StatusCreated {
+			t.Fatalf("create under %s status = %d, body %s", prefix, w.Code, w.Body.String())
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if got := env.do(http.MethodGet, "/news/sale", "").Code; got != http.StatusMovedPermanently {
+			t.Fatalf("redirect %d status = %d, want 301", i+1, got)
+		}
+	}
+	if got := env.do(http.MethodGet, "/news/sale", "").Code; got != http.StatusTooManyRequests {
+		t.Errorf("burst beyond the limit status = %d, want 429", got)
+	}
+	// The same code under another prefix is another link with its own budget
+	if got := env.do(http.MethodGet, "/sports/sale", "").Code; got != http.StatusMovedPermanently {
+		t.Errorf("sports/sale status = %d, want 301", got)
+	}
+}
+
+func TestReloadedClickRateLimitTakesEffect(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{
+		ClickLimiter:   memory.NewClickLimiter(),
+		ClickRateLimit: 2,
+	})
+	env.seed(t, "reload", "https://example.com/reload")
+
+	redirects := func(n int) (allowed int) {
+		for i := 0; i < n; i++ {
+			if env.do(http.MethodGet, "/reload", "").Code == http.StatusMovedPermanently {
+				allowed++
+			}
+		}
+		return allowed
+	}
+	if got := redirects(3); got != 2 {
+		t.Fatalf("redirects allowed before reload = %d, want 2", got)
+	}
+
+	// Same window, and refused redirects count too: 3 of 5 are used up
+	settings := env.svc.CurrentSettings()
+	settings.ClickRateLimit = 5
+	if err := env.svc.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings() error = %v", err)
+	}
+	if got := redirects(3); got != 2 {
+		t.Errorf("redirects allowed after reload = %d, want 2 more", got)
+	}
+
+	// An invalid reload keeps the settings in effect
+	settings.ClickRateLimit = -1
+	if err := env.svc.UpdateSettings(settings); err == nil {
+		t.Error("UpdateSettings() with a negative limit returned no error")
+	}
+	if got := env.svc.CurrentSettings().ClickRateLimit; got != 5 {
+		t.Errorf("limit after a rejected reload = %d, want 5", got)
+	}
+}
+
+func TestURLInfoHidesOwnerFieldsFromOthers(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	owner, limit := "alice", 5
+	if err := env.urlRepo.Create(context.Background(), &domain.URL{
+		ShortURL:       "shared",
+		OriginalURL:    "https://example.com/",
+		UserID:         &owner,
+		ClickRateLimit: &limit,
+		FallbackURL:    "https://example.com/next",
+	}); err != nil {
+		t.Fatalf("failed to seed shared: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		header []string
+		full   bool
+	}{
+		{"anonymous", nil, false},
+		{"non-owner", asBob, false},
+		{"owner", asAlice, true},
+		{"admin", asAdmin, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.do(http.MethodGet, "/api/v1/urls/shared", "", tt.header...)
+			var info map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+			}
+			if info["original_url"] != "https://example.com/" {
+				t.Errorf("original_url = %v, want the destination", info["original_url"])
+			}
+			for _, field := range []string{"user_id", "click_rate_limit", "fallback_url"} {
+				if _, ok := info[field]; ok != tt.full {
+					t.Errorf("%s present = %v, want %v", field, ok, tt.full)
+				}
+			}
+		})
+	}
+}
+
+func TestListURLsByDestination(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+
+	var codes []string
+	for i := 0; i < 3; i++ {
+		w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://münchen.de/karte"}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create status = %d, body %s", w.Code, w.Body.String())
+		}
+		var created domain.CreateURLResponse
+		json.Unmarshal(w.Body.Bytes(), &created)
+		codes = append(codes, created.ShortCode)
+	}
+	env.seed(t, "other1", "https://example.com/other")
+	// Disabled links are not live and must not be listed
+	env.do(http.MethodPost, "/api/v1/urls/"+codes[0]+"/disable", "", asAdmin...)
+
+	type page struct {
+		Items      []domain.URL `json:"items"`
+		NextCursor string       `json:"next_cursor"`
+	}
+	list := func(query string) page {
+		t.Helper()
+		w := env.do(http.MethodGet, "/api/v1/urls/by-destination?"+query, "", asAdmin...)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET by-destination?%s status = %d, body %s", query, w.Code, w.Body.String())
+		}
+		var p page
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("failed to decode page: %v", err)
+		}
+		return p
+	}
+
+	// The Unicode spelling and the stored punycode form find the same links
+	for _, dest := range []string{"https://münchen.de/karte", "https://xn--mnchen-3ya.de/karte"} {
+		first := list("limit=1&url=" + neturl.QueryEscape(dest))
+		if len(first.Items) != 1 || first.NextCursor == "" {
+			t.Fatalf("first page for %s = %+v, want 1 item and a cursor", dest, first)
+		}
+		second := list("limit=1&url=" + neturl.QueryEscape(dest) + "&cursor=" + first.NextCursor)
+		if len(second.Items) != 1 || second.NextCursor != "" {
+			t.Fatalf("second page for %s = %+v, want the last item", dest, second)
+		}
+
+		got := map[string]bool{first.Items[0].ShortURL: true, second.Items[0].ShortURL: true}
+		if !got[codes[1]] || !got[codes[2]] {
+			t.Errorf("by-destination %s returned %v, want %v", dest, got, codes[1:])
+		}
+	}
+
+	if p := list("url=" + neturl.QueryEscape("https://nowhere.example/")); len(p.Items) != 0 {
+		t.Errorf("unknown destination returned %d items", len(p.Items))
+	}
+	for _, query := range []string{"", "url=not-a-url", "url=https://example.com&limit=abc"} {
+		if w := env.do(http.MethodGet, "/api/v1/urls/by-destination?"+query, "", asAdmin...); w.Code != http.StatusBadRequest {
+			t.Errorf("GET by-destination?%s status = %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestCreateReturnExistingOnSameDestination(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+
+	w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://münchen.de/","custom_alias":"promo"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"same destination", `{"original_url":"https://münchen.de/","custom_alias":"promo","return_existing":true}`, http.StatusOK},
+		{"same destination, other spelling", `{"original_url":"https://xn--mnchen-3ya.de/","custom_alias":"promo","return_existing":true}`, http.StatusOK},
+		{"different destination", `{"original_url":"https://example.com/","custom_alias":"promo","return_existing":true}`, http.StatusConflict},
+		{"option not set", `{"original_url":"https://münchen.de/","custom_alias":"promo"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.do(http.MethodPost, "/api/v1/shorten", tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var resp domain.CreateURLResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.ShortCode != "promo" || !resp.Existing || resp.OriginalURL != "https://xn--mnchen-3ya.de/" {
+				t.Errorf("response = %+v, want the existing promo link", resp)
+			}
+		})
+	}
+
+	// A disabled link is not "the same link" any more
+	env.do(http.MethodPost, "/api/v1/urls/promo/disable", "", asAdmin...)
+	if w := env.do(http.MethodPost, "/api/v1/shorten", tests[0].body); w.Code != http.StatusConflict {
+		t.Errorf("disabled existing link status = %d, want 409", w.Code)
+	}
+}
+
+func TestRedirectNonWebSchemes(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{AllowedSchemes: []string{"https", "mailto", "data"}})
+
+	w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"mailto:sales@acme.com","custom_alias":"card01"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create mailto status = %d, body %s", w.Code, w.Body.String())
+	}
+	w = env.do(http.MethodGet, "/card01", "")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "mailto:sales@acme.com" {
+		t.Errorf("mailto redirect = %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	if w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"tel:+15551234567"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("create tel status = %d, want 400", w.Code)
+	}
+
+	// Explicitly enabled dangerous schemes are followed, others never are
+	env.seed(t, "data01", "data:text/plain,hi")
+	env.seed(t, "js0001", "javascript:alert(1)")
+	if w := env.do(http.MethodGet, "/data01", ""); w.Code != http.StatusMovedPermanently {
+		t.Errorf("enabled data: redirect status = %d, want 301", w.Code)
+	}
+	if w := env.do(http.MethodGet, "/js0001", ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("javascript: redirect status = %d, want 500", w.Code)
+	}
+}
+
+func TestUpdateURLExpiry(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{MaxTTL: 30 * 24 * time.Hour, AllowPermanent: true})
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := env.urlRepo.Create(ctx, &domain.URL{ShortURL: "promo1", OriginalURL: "https://example.com", IsActive: true, ExpiresAt: &expires}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	path := "/api/v1/admin/urls/promo1/expiry"
+
+	stored := func() *time.Time {
+		t.Helper()
+		exp, err := env.urlRepo.GetExpiry(ctx, "promo1")
+		if err != nil {
+			t.Fatalf("GetExpiry: %v", err)
+		}
+		return exp
+	}
+
+	// A redirect caches the link with its current expiry
+	if w := env.do(http.MethodGet, "/promo1", ""); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect status = %d", w.Code)
+	}
+
+	t.Run("extend", func(t *testing.T) {
+		w := env.do(http.MethodPatch, path, `{"extend_by_seconds":86400}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+		}
+		if got := stored(); got == nil || !got.Equal(expires.Add(24*time.Hour)) {
+			t.Errorf("expires_at = %v, want %v", got, expires.Add(24*time.Hour))
+		}
+		if cached, _ := env.cache.Get(ctx, "promo1"); cached != nil {
+			t.Error("cached entry with the old expiry was not dropped")
+		}
+	})
+
+	t.Run("shorten", func(t *testing.T) {
+		w := env.do(http.MethodPatch, path, `{"extend_by_seconds":-82800}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+		}
+		if got := stored(); got == nil || !got.Equal(expires.Add(time.Hour)) {
+			t.Errorf("expires_at = %v, want %v", got, expires.Add(time.Hour))
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		at := time.Now().Add(72 * time.Hour).UTC().Truncate(time.Second)
+		w := env.do(http.MethodPatch, path, `{"expires_at":"`+at.Format(time.RFC3339)+`"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+		}
+		if got := stored(); got == nil || !got.Equal(at) {
+			t.Errorf("expires_at = %v, want %v", got, at)
+		}
+	})
+
+	t.Run("never expire", func(t *testing.T) {
+		w := env.do(http.MethodPatch, path, `{"never_expires":true}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+		}
+		var resp domain.ExpiryResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !resp.NeverExpires || resp.ExpiresAt != nil || stored() != nil {
+			t.Errorf("response = %+v, stored = %v, want no expiry", resp, stored())
+		}
+
+		// A permanent link has nothing to extend
+		if w := env.do(http.MethodPatch, path, `{"extend_by_seconds":60}`); w.Code != http.StatusBadRequest {
+			t.Errorf("extend a permanent link status = %d, want 400", w.Code)
+		}
+	})
+
+	for name, body := range map[string]string{
+		"beyond max ttl": `{"extend_by_seconds":` + strconv.Itoa(60*24*3600) + `}`,
+		"in the past":    `{"expires_at":"2020-01-01T00:00:00Z"}`,
+		"two changes":    `{"never_expires":true,"extend_by_seconds":60}`,
+		"nothing to do":  `{}`,
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			if w := env.do(http.MethodPatch, path, body); w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400, body %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if w := env.do(http.MethodPatch, "/api/v1/admin/urls/nope42/expiry", `{"never_expires":true}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown code status = %d, want 404", w.Code)
+	}
+}
+
+func TestUpdateURLExpiryRevivesExpiredLink(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	if err := env.urlRepo.Create(ctx, &domain.URL{ShortURL: "old001", OriginalURL: "https://example.com", IsActive: true, ExpiresAt: &past}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	w := env.do(http.MethodGet, "/api/v1/admin/urls/old001/expiry", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"expired":true`) {
+		t.Fatalf("inspect expired link = %d %s", w.Code, w.Body.String())
+	}
+	if w := env.do(http.MethodGet, "/old001", ""); w.Code != http.StatusGone {
+		t.Fatalf("redirect before revive status = %d, want 410", w.Code)
+	}
+
+	at := time.Now().Add(time.Hour).Format(time.RFC3339)
+	if w := env.do(http.MethodPatch, "/api/v1/admin/urls/old001/expiry", `{"expires_at":"`+at+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("revive status = %d, body %s", w.Code, w.Body.String())
+	}
+	if w := env.do(http.MethodGet, "/old001", ""); w.Code != http.StatusMovedPermanently {
+		t.Errorf("redirect after revive status = %d, want 301", w.Code)
+	}
+}
+
+func TestRedirectExpiredBehavior(t *testing.T) {
+	tests := []struct {
+		behavior service.ExpiredBehavior
+		status   int
+		location string
+	}{
+		{"", http.StatusGone, ""},
+		{service.ExpiredGone, http.StatusGone, ""},
+		{service.ExpiredNotFound, http.StatusNotFound, ""},
+		{service.ExpiredRedirect, http.StatusFound, "https://example.com/expired"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.behavior), func(t *testing.T) {
+			env := newTestEnv(t, service.URLServiceConfig{
+				ExpiredBehavior:    tt.behavior,
+				ExpiredRedirectURL: "https://example.com/expired",
+			})
+			past := time.Now().Add(-time.Hour)
+			if err := env.urlRepo.Create(context.Background(), &domain.URL{ShortURL: "old001", OriginalURL: "https://example.com", ExpiresAt: &past}); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			w := env.do(http.MethodGet, "/old001", "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+			if tt.behavior == service.ExpiredNotFound {
+				// Indistinguishable from a code that never existed
+				if unknown := env.do(http.MethodGet, "/nope42", ""); unknown.Body.String() != w.Body.String() {
+					t.Errorf("expired body %s differs from unknown body %s", w.Body.String(), unknown.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRedirectExpiredLinkFallback(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{ExpiredBehavior: service.ExpiredNotFound})
+	ctx := context.Background()
+
+	w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com/spring","custom_alias":"promo1","fallback_url":"https://example.com/summer"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", w.Code, w.Body.String())
+	}
+	env.seed(t, "promo2", "https://example.com/autumn")
+	past := time.Now().Add(-time.Minute)
+	for _, code := range []string{"promo1", "promo2"} {
+		if err := env.urlRepo.SetExpiry(ctx, code, &past); err != nil {
+			t.Fatalf("SetExpiry(%s): %v", code, err)
+		}
+		_ = env.cache.Delete(ctx, code)
+	}
+
+	// The link's own fallback wins over the server's not_found
+	w = env.do(http.MethodGet, "/promo1", "")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/summer" {
+		t.Errorf("expired link with fallback = %d to %q, want 302 to its fallback", w.Code, w.Header().Get("Location"))
+	}
+	if w := env.do(http.MethodGet, "/promo2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expired link without fallback = %d, want the global 404", w.Code)
+	}
+
+	w = env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com","fallback_url":"javascript:alert(1)"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unsafe fallback_url status = %d, want 400", w.Code)
+	}
+}
+
+func TestHandleErrorCountsBusinessReason(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		reason string
+	}{
+		{domain.ErrURLNotFound, http.StatusNotFound, "not_found"},
+		{domain.ErrURLExpired, http.StatusGone, "expired"},
+		{domain.ErrURLDisabled, http.StatusGone, "disabled"},
+		{domain.ErrRateLimitExceeded, http.StatusTooManyRequests, "rate_limited"},
+		{domain.ErrInvalidURL, http.StatusBadRequest, "invalid_url"},
+		{domain.ErrSchemeNotAllowed, http.StatusBadRequest, "scheme_not_allowed"},
+		{domain.ErrForbiddenDomain, http.StatusBadRequest, "forbidden_domain"},
+		{domain.ErrPermanentDisabled, http.StatusBadRequest, "permanent_not_allowed"},
+		{fmt.Errorf("%w: in the past", domain.ErrInvalidExpiry), http.StatusBadRequest, "invalid_expiry"},
+		{domain.ErrSigningDisabled, http.StatusBadRequest, "signing_not_enabled"},
+		{domain.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+		{domain.ErrQuotaExceeded, http.StatusForbidden, "quota_exceeded"},
+		{domain.ErrForbidden, http.StatusForbidden, "forbidden"},
+		{domain.ErrShortCodeExists, http.StatusConflict, "conflict"},
+		{domain.ErrShortCodeReserved, http.StatusConflict, "reserved_short_code"},
+		{domain.ErrInvalidShortCode, http.StatusBadRequest, "invalid_short_code"},
+		{domain.ErrServiceUnavailable, http.StatusServiceUnavailable, "service_unavailable"},
+	}
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	h := NewURLHandler(nil, zap.NewNop(), m)
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+		h.handleError(c, tt.err)
+		if w.Code != tt.status {
+			t.Errorf("%v: status = %d, want %d", tt.err, w.Code, tt.status)
+		}
+		if got := testutil.ToFloat64(m.BusinessErrorTotal.WithLabelValues(tt.reason)); got != 1 {
+			t.Errorf("%v: business_error_total{reason=%q} = %v, want 1", tt.err, tt.reason, got)
+		}
+	}
+
+	// Unexpected errors are 500s, not business errors
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	h.handleError(c, errors.New("boom"))
+	if got := testutil.CollectAndCount(m.BusinessErrorTotal); got != len(tests) {
+		t.Errorf("business_error_total has %d series, want %d", got, len(tests))
+	}
+}
+
+func TestRedirectPassthroughQuery(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+
+	create := func(alias string, passthrough bool) {
+		t.Helper()
+		body := fmt.Sprintf(`{"original_url":"https://example.com/landing?utm_source=mail","custom_alias":%q,"passthrough_query":%t}`, alias, passthrough)
+		if w := env.do(http.MethodPost, "/api/v1/shorten", body); w.Code != http.StatusCreated {
+			t.Fatalf("create %s status = %d, body %s", alias, w.Code, w.Body.String())
+		}
+	}
+	create("passon", true)
+	create("plain1", false)
+
+	w := env.do(http.MethodGet, "/passon?ref=twitter&utm_source=evil", "")
+	if got, want := w.Header().Get("Location"), "https://example.com/landing?utm_source=mail&ref=twitter"; got != want {
+		t.Errorf("passthrough Location = %q, want %q", got, want)
+	}
+
+	w = env.do(http.MethodGet, "/plain1?ref=twitter", "")
+	if got, want := w.Header().Get("Location"), "https://example.com/landing?utm_source=mail"; got != want {
+		t.Errorf("without passthrough Location = %q, want %q", got, want)
+	}
+}
+
+func TestRedirectCanonicalLinkHeader(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{CanonicalLinkHeader: true})
+	env.seed(t, "canon1", "https://example.com/café/a b?q=\"x\"&r=<y>&s=%2F")
+	if w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com/landing","custom_alias":"canon2","passthrough_query":true}`); w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	w := env.do(http.MethodGet, "/canon1", "")
+	if w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect status = %d, want 301", w.Code)
+	}
+	want := `<https://example.com/caf%C3%A9/a%20b?q=%22x%22&r=%3Cy%3E&s=%2F>; rel="canonical"`
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("Link = %q, want %q", got, want)
+	}
+
+	// The click's own query isn't part of the canonical URL
+	w = env.do(http.MethodGet, "/canon2?utm_source=mail", "")
+	if got, want := w.Header().Get("Link"), `<https://example.com/landing>; rel="canonical"`; got != want {
+		t.Errorf("passthrough Link = %q, want %q", got, want)
+	}
+
+	off := newTestEnv(t, service.URLServiceConfig{})
+	off.seed(t, "canon1", "https://example.com/landing")
+	if got := off.do(http.MethodGet, "/canon1", "").Header().Get("Link"); got != "" {
+		t.Errorf("Link with the header disabled = %q, want none", got)
+	}
+}
+
+func TestRedirectRoutesByPlatform(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+
+	body := `{"original_url":"https://example.com/app","custom_alias":"getapp","platform_destinations":{
+		"ios":"https://apps.apple.com/app/id123","android":"https://play.google.com/store/apps/details?id=com.example"}}`
+	if w := env.do(http.MethodPost, "/api/v1/shorten", body); w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{"iphone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15", "https://apps.apple.com/app/id123"},
+		{"android", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36", "https://play.google.com/store/apps/details?id=com.example"},
+		{"desktop without its own destination", "Mozilla/5.0 (Windows NT 10.0; Win64; x64)", "https://example.com/app"},
+		{"unknown agent", "curl/8.4.0", "https://example.com/app"},
+		{"no agent", "", "https://example.com/app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.do(http.MethodGet, "/getapp", "", "User-Agent", tt.userAgent)
+			if got := w.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+			if got := w.Header().Get("Vary"); got != "User-Agent" {
+				t.Errorf("Vary = %q, want User-Agent", got)
+			}
+		})
+	}
+
+	// Unknown platforms and unsafe destinations are refused at creation
+	for _, dests := range []string{`{"windows":"https://example.com/win"}`, `{"ios":"javascript:alert(1)"}`} {
+		body := fmt.Sprintf(`{"original_url":"https://example.com/app","platform_destinations":%s}`, dests)
+		if w := env.do(http.MethodPost, "/api/v1/shorten", body); w.Code != http.StatusBadRequest {
+			t.Errorf("create with %s status = %d, want 400", dests, w.Code)
+		}
+	}
+}
+
+func TestRedirectRoutesByCountry(t *testing.T) {
+	table, err := geo.ParseCIDRTable(strings.NewReader("203.0.113.0/24,DE\n198.51.100.0/24,FR\n"))
+	if err != nil {
+		t.Fatalf("ParseCIDRTable() error = %v", err)
+	}
+	env := newTestEnv(t, service.URLServiceConfig{Countries: geo.NewEnricher(table, geo.EnricherConfig{})})
+
+	body := `{"original_url":"https://example.com/en","custom_alias":"local","country_destinations":{
+		"DE":"https://example.com/de","FR":"https://example.com/fr"}}`
+	if w := env.do(http.MethodPost, "/api/v1/shorten", body); w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.7", "https://example.com/de"},
+		{"198.51.100.20", "https://example.com/fr"},
+		{"192.0.2.1", "https://example.com/en"}, // in no range
+	}
+	for _, tt := range tests {
+		w := env.do(http.MethodGet, "/local", "", "X-Forwarded-For", tt.ip)
+		if got := w.Header().Get("Location"); got != tt.want {
+			t.Errorf("Location for %s = %q, want %q", tt.ip, got, tt.want)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "private" {
+			t.Errorf("Cache-Control for %s = %q, want private", tt.ip, got)
+		}
+	}
+
+	// Country codes must be upper-case ISO 3166-1 alpha-2
+	for _, dests := range []string{`{"de":"https://example.com/de"}`, `{"XX":"https://example.com/xx"}`} {
+		body := fmt.Sprintf(`{"original_url":"https://example.com/en","country_destinations":%s}`, dests)
+		if w := env.do(http.MethodPost, "/api/v1/shorten", body); w.Code != http.StatusBadRequest {
+			t.Errorf("create with %s status = %d, want 400", dests, w.Code)
+		}
+	}
+}
+
+func TestRedirectWithoutCountryResolverUsesDefault(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+
+	body := `{"original_url":"https://example.com/en","custom_alias":"local","country_destinations":{"DE":"https://example.com/de"}}`
+	if w := env.do(http.MethodPost, "/api/v1/shorten", body); w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", w.Code, w.Body.String())
+	}
+	w := env.do(http.MethodGet, "/local", "", "X-Forwarded-For", "203.0.113.7")
+	if got, want := w.Header().Get("Location"), "https://example.com/en"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+}
+
+func TestUpdateDestinationRecordsHistory(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seedOwned(t, "moved", "https://example.com/v1", "alice")
+
+	// Cached before the change, so the redirect below shows the eviction
+	if w := env.do(http.MethodGet, "/moved", ""); w.Header().Get("Location") != "https://example.com/v1" {
+		t.Fatalf("redirect before the change = %q", w.Header().Get("Location"))
+	}
+
+	for _, dest := range []string{"https://example.com/v2", "https://example.com/v3"} {
+		w := env.do(http.MethodPut, "/api/v1/urls/moved/destination", fmt.Sprintf(`{"original_url":%q}`, dest), asAlice...)
+		if w.Code != http.StatusOK {
+			t.Fatalf("update to %s status = %d, body %s", dest, w.Code, w.Body.String())
+		}
+	}
+	if w := env.do(http.MethodGet, "/moved", ""); w.Header().Get("Location") != "https://example.com/v3" {
+		t.Errorf("redirect after the change = %q, want the new destination", w.Header().Get("Location"))
+	}
+
+	var page pagination.Page[domain.URLHistoryEntry]
+	w := env.do(http.MethodGet, "/api/v1/urls/moved/history?limit=1", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("history status = %d, body %s", w.Code, w.Body.String())
+	}
+	if len(page.Items) != 1 || page.Items[0].PreviousURL != "https://example.com/v2" || page.Items[0].OriginalURL != "https://example.com/v3" {
+		t.Fatalf("first page = %+v, want the latest change", page.Items)
+	}
+	if page.NextCursor == "" {
+		t.Fatal("first page has no next_cursor")
+	}
+
+	w = env.do(http.MethodGet, "/api/v1/urls/moved/history?limit=1&cursor="+page.NextCursor, "")
+	page = pagination.Page[domain.URLHistoryEntry]{}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Items) != 1 || page.Items[0].PreviousURL != "https://example.com/v1" || page.NextCursor != "" {
+		t.Errorf("second page = %+v (next %q), want only the first change", page.Items, page.NextCursor)
+	}
+
+	// Destinations are checked like on create, unknown links are 404s
+	if w := env.do(http.MethodPut, "/api/v1/urls/moved/destination", `{"original_url":"javascript:alert(1)"}`, asAlice...); w.Code != http.StatusBadRequest {
+		t.Errorf("unsafe destination status = %d, want 400", w.Code)
+	}
+	if w := env.do(http.MethodPut, "/api/v1/urls/nope/destination", `{"original_url":"https://example.com"}`, asAlice...); w.Code != http.StatusNotFound {
+		t.Errorf("unknown link status = %d, want 404", w.Code)
+	}
+}
+
+func TestUpdateDestinationRequiresOwner(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seedOwned(t, "mine", "https://example.com/v1", "alice")
+	env.seed(t, "nobodys", "https://example.com/v1")
+
+	tests := []struct {
+		name   string
+		code   string
+		header []string
+		want   int
+	}{
+		{"anonymous", "mine", nil, http.StatusUnauthorized},
+		{"non-owner", "mine", asBob, http.StatusForbidden},
+		{"anonymous link", "nobodys", asAlice, http.StatusForbidden},
+		{"admin", "nobodys", asAdmin, http.StatusOK},
+		{"owner", "mine", asAlice, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := env.do(http.MethodPut, "/api/v1/urls/"+tt.code+"/destination", `{"original_url":"https://example.com/v2"}`, tt.header...)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+	if w := env.do(http.MethodGet, "/mine", ""); w.Header().Get("Location") != "https://example.com/v2" {
+		t.Errorf("redirect = %q, want only the owner's change applied", w.Header().Get("Location"))
+	}
+}
+
+func TestRedirectRejectsMalformedCodes(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	// Seeded straight into storage: were any of these looked up they would
+	// redirect, so a 400 shows the repository was never asked
+	codes := map[string]string{
+		"overlong":    strings.Repeat("a", 500),
+		"symbols":     "ab$cd!",
+		"short tag":   "abc123.Xy9",
+		"empty code":  ".Xy9_k2Qm",
+		"two tags":    "abc.Xy9_k2Qm.Xy9_k2Qm",
+		"encoded dot": "abc%2E%2E",
+	}
+	for name, code := range codes {
+		t.Run(name, func(t *testing.T) {
+			decoded, _ := neturl.PathUnescape(code)
+			env.seed(t, decoded, "https://example.com/"+name)
+
+			w := env.do(http.MethodGet, "/"+code, "")
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", w.Code)
+			}
+			var body ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if body.Error != "invalid_short_code" {
+				t.Errorf("error = %q, want invalid_short_code", body.Error)
+			}
+		})
+	}
+	if n := testutil.CollectAndCount(env.metrics.CacheMissesTotal); n != 0 {
+		t.Errorf("cache miss series = %d, want no lookups", n)
+	}
+
+	env.seed(t, "Ok_code-1", "https://example.com/ok")
+	if w := env.do(http.MethodGet, "/Ok_code-1", ""); w.Code != http.StatusMovedPermanently {
+		t.Errorf("valid code status = %d, want 301", w.Code)
+	}
+}
+
+func TestRedirectPrefixedLinks(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{AllowedPrefixes: []string{"news", "docs"}})
+
+	w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com/story","custom_alias":"story1","prefix":"news"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body %s", w.Code, w.Body.String())
+	}
+	var resp domain.CreateURLResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.ShortURL != "http://short.test/news/story1" {
+		t.Errorf("short_url = %q, want the prefixed address", resp.ShortURL)
+	}
+	env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com/plain","custom_alias":"plain1"}`)
+
+	tests := []struct {
+		name, path string
+		wantStatus int
+	}{
+		{"correct prefix", "/news/story1", http.StatusMovedPermanently},
+		{"bare code of a prefixed link", "/story1", http.StatusNotFound},
+		{"another allowed prefix", "/docs/story1", http.StatusNotFound},
+		{"unlisted prefix", "/blog/story1", http.StatusNotFound},
+		{"bare code of a plain link", "/plain1", http.StatusMovedPermanently},
+		{"plain link under a prefix", "/news/plain1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Twice: the second visit is served from the cache
+			for i := 0; i < 2; i++ {
+				if w := env.do(http.MethodGet, tt.path, ""); w.Code != tt.wantStatus {
+					t.Errorf("GET %s #%d status = %d, want %d", tt.path, i, w.Code, tt.wantStatus)
+				}
+			}
+		})
+	}
+
+	w = env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com/x","prefix":"blog"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "prefix_not_allowed") {
+		t.Errorf("unlisted prefix create = %d %s, want 400 prefix_not_allowed", w.Code, w.Body.String())
+	}
+}
+
+func TestAliasScopePerPrefix(t *testing.T) {
+	create := func(env *testEnv, prefix, destination string) (int, domain.CreateURLResponse) {
+		body := `{"original_url":"` + destination + `","custom_alias":"summer-sale","prefix":"` + prefix + `"}`
+		w := env.do(http.MethodPost, "/api/v1/shorten", body)
+		var resp domain.CreateURLResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	t.Run("global", func(t *testing.T) {
+		env := newTestEnv(t, service.URLServiceConfig{AllowedPrefixes: []string{"news", "sports"}})
+		if code, _ := create(env, "news", "https://example.com/news"); code != http.StatusCreated {
+			t.Fatalf("first create status = %d, want 201", code)
+		}
+		if code, _ := create(env, "sports", "https://example.com/sports"); code != http.StatusConflict {
+			t.Errorf("same alias under another prefix = %d, want 409", code)
+		}
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		env := newTestEnv(t, service.URLServiceConfig{
+			AllowedPrefixes: []string{"news", "sports"},
+			AliasScope:      service.AliasScopePrefix,
+		})
+		for _, prefix := range []string{"news", "sports", ""} {
+			code, resp := create(env, prefix, "https://example.com/"+prefix)
+			if code != http.StatusCreated {
+				t.Fatalf("create under %q status = %d, want 201", prefix, code)
+			}
+			if resp.ShortCode != "summer-sale" {
+				t.Errorf("short_code = %q, want the alias as given", resp.ShortCode)
+			}
+			want := "http://short.test/" + prefix + "/summer-sale"
+			if prefix == "" {
+				want = "http://short.test/summer-sale"
+			}
+			if resp.ShortURL != want {
+				t.Errorf("short_url = %q, want %q", resp.ShortURL, want)
+			}
+		}
+		if code, _ := create(env, "news", "https://example.com/other"); code != http.StatusConflict {
+			t.Errorf("same alias under the same prefix = %d, want 409", code)
+		}
+
+		for path, want := range map[string]string{
+			"/news/summer-sale":   "https://example.com/news",
+			"/sports/summer-sale": "https://example.com/sports",
+			"/summer-sale":        "https://example.com/",
+		} {
+			if w := env.do(http.MethodGet, path, ""); w.Header().Get("Location") != want {
+				t.Errorf("GET %s = %d %q, want a redirect to %s", path, w.Code, w.Header().Get("Location"), want)
+			}
+		}
+
+		// Management calls pick the link with ?prefix=, no prefix is the
+		// unprefixed one
+		if w := env.do(http.MethodPost, "/api/v1/urls/summer-sale/disable?prefix=news", "", asAdmin...); w.Code != http.StatusOK {
+			t.Fatalf("disable news/summer-sale status = %d, want 200", w.Code)
+		}
+		if w := env.do(http.MethodGet, "/news/summer-sale", ""); w.Code != http.StatusGone {
+			t.Errorf("GET /news/summer-sale after disabling it = %d, want 410", w.Code)
+		}
+		for _, path := range []string{"/sports/summer-sale", "/summer-sale"} {
+			if w := env.do(http.MethodGet, path, ""); w.Code != http.StatusMovedPermanently {
+				t.Errorf("GET %s after disabling news/summer-sale = %d, want it still redirecting", path, w.Code)
+			}
+		}
+		w := env.do(http.MethodGet, "/api/v1/urls/summer-sale", "", asAdmin...)
+		var info domain.URL
+		json.Unmarshal(w.Body.Bytes(), &info)
+		if info.Prefix != "" || info.OriginalURL != "https://example.com/" {
+			t.Errorf("GET /api/v1/urls/summer-sale = %+v, want the unprefixed link", info)
+		}
+
+		// Regenerating keeps the link under its prefix
+		w = env.do(http.MethodPost, "/api/v1/urls/summer-sale/regenerate?prefix=sports", "", asAdmin...)
+		var regenerated domain.RegenerateResponse
+		json.Unmarshal(w.Body.Bytes(), &regenerated)
+		if want := "http://short.test/sports/" + regenerated.ShortCode; regenerated.ShortURL != want {
+			t.Fatalf("regenerated short_url = %q, want %q", regenerated.ShortURL, want)
+		}
+		if w := env.do(http.MethodGet, "/sports/"+regenerated.ShortCode, ""); w.Header().Get("Location") != "https://example.com/sports" {
+			t.Errorf("regenerated link = %d %q, want a redirect", w.Code, w.Header().Get("Location"))
+		}
+	})
+}
+
+func TestRedirectObservesTTFB(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seed(t, "abc123", "https://example.com")
+
+	if w := env.do(http.MethodGet, "/nope12", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown code status = %d", w.Code)
+	}
+	if got := metrics.Sum(env.metrics.RedirectTTFB); got != 0 {
+		t.Errorf("redirect_ttfb_seconds observations = %v after a 404, want 0", got)
+	}
+
+	if w := env.do(http.MethodGet, "/abc123", ""); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect status = %d", w.Code)
+	}
+	if got := metrics.Sum(env.metrics.RedirectTTFB); got != 1 {
+		t.Errorf("redirect_ttfb_seconds observations = %v, want 1", got)
+	}
+}
+
+func TestDeleteThenRestoreWithinWindow(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seedOwned(t, "keepme", "https://example.com/keep", "alice")
+
+	// Cached before the delete, so the delete must evict it
+	if w := env.do(http.MethodGet, "/keepme", ""); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect before delete = %d", w.Code)
+	}
+
+	w := env.do(http.MethodDelete, "/api/v1/urls/keepme", "", asAlice...)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, body %s", w.Code, w.Body.String())
+	}
+	var resp domain.DeleteResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp.Deleted || resp.PurgeAfter == nil || time.Until(*resp.PurgeAfter) < 23*time.Hour {
+		t.Errorf("delete response = %+v, want a purge_after a day out", resp)
+	}
+
+	w = env.do(http.MethodGet, "/keepme", "")
+	if w.Code != http.StatusGone || !strings.Contains(w.Body.String(), `"deleted"`) {
+		t.Errorf("redirect after delete = %d %s, want 410 deleted", w.Code, w.Body.String())
+	}
+
+	// Deleting again keeps the first window
+	w = env.do(http.MethodDelete, "/api/v1/urls/keepme", "", asAlice...)
+	var again domain.DeleteResponse
+	json.Unmarshal(w.Body.Bytes(), &again)
+	if again.PurgeAfter == nil || !again.PurgeAfter.Equal(*resp.PurgeAfter) {
+		t.Errorf("second delete purge_after = %v, want %v", again.PurgeAfter, resp.PurgeAfter)
+	}
+
+	if w := env.do(http.MethodPost, "/api/v1/urls/keepme/restore", "", asAlice...); w.Code != http.StatusOK {
+		t.Fatalf("restore status = %d, body %s", w.Code, w.Body.String())
+	}
+	if w := env.do(http.MethodGet, "/keepme", ""); w.Code != http.StatusMovedPermanently {
+		t.Errorf("redirect after restore = %d, want 301", w.Code)
+	}
+	if w := env.do(http.MethodPost, "/api/v1/urls/keepme/restore", "", asAlice...); w.Code != http.StatusNotFound {
+		t.Errorf("restoring a live link = %d, want 404", w.Code)
+	}
+	if w := env.do(http.MethodDelete, "/api/v1/urls/nope12", "", asAlice...); w.Code != http.StatusNotFound {
+		t.Errorf("deleting an unknown code = %d, want 404", w.Code)
+	}
+}
+
+// intruders are the callers refused a change to alice's link
+var intruders = []struct {
+	name   string
+	header []string
+	want   int
+}{
+	{"anonymous", nil, http.StatusUnauthorized},
+	{"non-owner", asBob, http.StatusForbidden},
+}
+
+func TestEnableAndDisableRequireOwner(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seedOwned(t, "mine", "https://example.com/keep", "alice")
+
+	for _, caller := range intruders {
+		if w := env.do(http.MethodPost, "/api/v1/urls/mine/disable", "", caller.header...); w.Code != caller.want {
+			t.Errorf("%s disable status = %d, want %d", caller.name, w.Code, caller.want)
+		}
+	}
+	if w := env.do(http.MethodGet, "/mine", ""); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect after refused disables = %d, want the link still live", w.Code)
+	}
+
+	if w := env.do(http.MethodPost, "/api/v1/urls/mine/disable", "", asAlice...); w.Code != http.StatusOK {
+		t.Fatalf("owner disable status = %d", w.Code)
+	}
+	for _, caller := range intruders {
+		if w := env.do(http.MethodPost, "/api/v1/urls/mine/enable", "", caller.header...); w.Code != caller.want {
+			t.Errorf("%s enable status = %d, want %d", caller.name, w.Code, caller.want)
+		}
+	}
+	if w := env.do(http.MethodPost, "/api/v1/urls/mine/enable", "", asAdmin...); w.Code != http.StatusOK {
+		t.Errorf("admin enable status = %d, want 200", w.Code)
+	}
+}
+
+func TestDeleteAndRestoreRequireOwner(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seedOwned(t, "mine", "https://example.com/keep", "alice")
+
+	for _, caller := range intruders {
+		if w := env.do(http.MethodDelete, "/api/v1/urls/mine", "", caller.header...); w.Code != caller.want {
+			t.Errorf("%s delete status = %d, want %d", caller.name, w.Code, caller.want)
+		}
+	}
+	if w := env.do(http.MethodGet, "/mine", ""); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("redirect after refused deletes = %d, want the link still live", w.Code)
+	}
+
+	if w := env.do(http.MethodDelete, "/api/v1/urls/mine", "", asAlice...); w.Code != http.StatusOK {
+		t.Fatalf("owner delete status = %d", w.Code)
+	}
+	for _, caller := range intruders {
+		if w := env.do(http.MethodPost, "/api/v1/urls/mine/restore", "", caller.header...); w.Code != caller.want {
+			t.Errorf("%s restore status = %d, want %d", caller.name, w.Code, caller.want)
+		}
+	}
+	if w := env.do(http.MethodGet, "/mine", ""); w.Code != http.StatusGone {
+		t.Errorf("redirect after refused restores = %d, want the link still deleted", w.Code)
+	}
+	if w := env.do(http.MethodPost, "/api/v1/urls/mine/restore", "", asAdmin...); w.Code != http.StatusOK {
+		t.Errorf("admin restore status = %d, want 200", w.Code)
+	}
+}
+
+func TestDeleteIsPurgedAfterWindow(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{DeleteGracePeriod: 20 * time.Millisecond})
+	env.seed(t, "dropme", "https://example.com/drop")
+	env.seed(t, "stays1", "https://example.com/stay")
+	worker := service.NewCleanupWorker(env.urlRepo, time.Minute, zap.NewNop())
+
+	if w := env.do(http.MethodDelete, "/api/v1/urls/dropme", "", asAdmin...); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d", w.Code)
+	}
+	// Inside the window the worker leaves it alone
+	worker.RunOnce(context.Background())
+	if w := env.do(http.MethodGet, "/dropme", ""); w.Code != http.StatusGone {
+		t.Errorf("redirect inside the window = %d, want 410", w.Code)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	// Too late to restore even before the worker runs
+	if w := env.do(http.MethodPost, "/api/v1/urls/dropme/restore", "", asAdmin...); w.Code != http.StatusNotFound {
+		t.Errorf("restore after the window = %d, want 404", w.Code)
+	}
+
+	worker.RunOnce(context.Background())
+	if w := env.do(http.MethodGet, "/dropme", ""); w.Code != http.StatusNotFound {
+		t.Errorf("redirect after purge = %d, want 404", w.Code)
+	}
+	if w := env.do(http.MethodGet, "/stays1", ""); w.Code != http.StatusMovedPermanently {
+		t.Errorf("untouched link after purge = %d, want 301", w.Code)
+	}
+}
+
+func TestRedirectTrailingCharacters(t *testing.T) {
+	paths := []string{"/abc123.", "/abc123,", "/abc123)"}
+
+	t.Run("lenient", func(t *testing.T) {
+		env := newTestEnv(t, service.URLServiceConfig{TrailingChars: ".,)!?"})
+		env.seed(t, "abc123", "https://example.com/found")
+		for _, path := range paths {
+			w := env.do(http.MethodGet, path, "")
+			if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://example.com/found" {
+				t.Errorf("GET %s = %d %q, want a redirect to the link", path, w.Code, w.Header().Get("Location"))
+			}
+		}
+		if w := env.do(http.MethodGet, "/abc123!?", ""); w.Code != http.StatusMovedPermanently {
+			t.Errorf("several trailing characters = %d, want 301", w.Code)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		env := newTestEnv(t, service.URLServiceConfig{})
+		env.seed(t, "abc123", "https://example.com/found")
+		// Off, these are malformed codes and never reach a lookup
+		for _, path := range paths {
+			if w := env.do(http.MethodGet, path, ""); w.Code != http.StatusBadRequest {
+				t.Errorf("GET %s = %d, want 400", path, w.Code)
+			}
+		}
+	})
+}
+
+func TestReservedCodesDontShadowRoutes(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{AllowCustom: true})
+	// Routes main mounts outside the API, plus one added later: reserving
+	// from the router protects it without listing it anywhere
+	for _, path := range []string{"/health", "/metrics", "/status"} {
+		env.router.GET(path, func(c *gin.Context) { c.String(http.StatusOK, "route "+c.FullPath()) })
+	}
+	env.svc.ReserveCodes(RouteSegments(env.router.Routes())...)
+
+	for _, alias := range []string{"api", "metrics", "health", "status", "Metrics"} {
+		w := env.do(http.MethodPost, "/api/v1/shorten",
+			`{"original_url":"https://example.com/`+alias+`","custom_alias":"`+alias+`"}`)
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "reserved_short_code") {
+			t.Errorf("create %q: status = %d, body %s; want 409 reserved_short_code", alias, w.Code, w.Body)
+		}
+	}
+
+	for _, path := range []string{"/health", "/metrics", "/status"} {
+		w := env.do(http.MethodGet, path, "")
+		if w.Code != http.StatusOK || w.Body.String() != "route "+path {
+			t.Errorf("GET %s = %d %q, want the route, not a redirect", path, w.Code, w.Body)
+		}
+	}
+
+	// Anything else is still fair game
+	if w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com","custom_alias":"apis"}`); w.Code != http.StatusCreated {
+		t.Errorf("create apis: status = %d, body %s; want 201", w.Code, w.Body)
+	}
+}
+
+func TestRouteSegments(t *testing.T) {
+	router := gin.New()
+	noop := func(c *gin.Context) {}
+	router.GET("/:shortCode", noop)
+	router.GET("/news/:shortCode", noop)
+	router.POST("/api/v1/shorten", noop)
+	router.GET("/api/v1/stats", noop)
+	router.GET("/health/ready", noop)
+	router.GET("/", noop)
+
+	got := RouteSegments(router.Routes())
+	sort.Strings(got)
+	if want := []string{"api", "health", "news"}; !slices.Equal(got, want) {
+		t.Errorf("RouteSegments() = %v, want %v", got, want)
+	}
+}
+
+func TestAliasClicksAccumulateOnTheLink(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seedOwned(t, "canon1", "https://example.com/campaign", "alice")
+
+	for _, alias := range []string{"spring", "promo24"} {
+		if w := env.do(http.MethodPost, "/api/v1/urls/canon1/aliases", `{"alias":"`+alias+`"}`, asAlice...); w.Code != http.StatusCreated {
+			t.Fatalf("expected 201 adding %s, got %d: %s", alias, w.Code, w.Body.String())
+		}
+	}
+
+	for _, code := range []string{"canon1", "spring", "promo24", "spring"} {
+		w := env.do(http.MethodGet, "/"+code, "")
+		if w.Header().Get("Location") != "https://example.com/campaign" {
+			t.Fatalf("/%s: expected a redirect to the link, got %d %q", code, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	url, err := env.urlRepo.GetByShortCode(context.Background(), "canon1")
+	if err != nil {
+		t.Fatalf("failed to read the link: %v", err)
+	}
+	if url.ClickCount != 4 {
+		t.Errorf("click_count = %d, want 4 across the link and its aliases", url.ClickCount)
+	}
+
+	w := env.do(http.MethodGet, "/api/v1/urls/canon1/aliases", "")
+	var listed domain.LinkAliases
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("failed to decode aliases: %v", err)
+	}
+	if strings.Join(listed.Aliases, ",") != "promo24,spring" {
+		t.Errorf("aliases = %v, want [promo24 spring]", listed.Aliases)
+	}
+}
+
+func TestAliasChangesRequireOwner(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seedOwned(t, "canon1", "https://example.com/campaign", "alice")
+	if w := env.do(http.MethodPost, "/api/v1/urls/canon1/aliases", `{"alias":"spring"}`, asAlice...); w.Code != http.StatusCreated {
+		t.Fatalf("owner add status = %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, caller := range intruders {
+		if w := env.do(http.MethodPost, "/api/v1/urls/canon1/aliases", `{"alias":"hijack"}`, caller.header...); w.Code != caller.want {
+			t.Errorf("%s add status = %d, want %d", caller.name, w.Code, caller.want)
+		}
+		if w := env.do(http.MethodDelete, "/api/v1/urls/canon1/aliases/spring", "", caller.header...); w.Code != caller.want {
+			t.Errorf("%s remove status = %d, want %d", caller.name, w.Code, caller.want)
+		}
+	}
+	if w := env.do(http.MethodGet, "/hijack", ""); w.Code != http.StatusNotFound {
+		t.Errorf("refused alias resolves with %d, want 404", w.Code)
+	}
+	if w := env.do(http.MethodGet, "/spring", ""); w.Code != http.StatusMovedPermanently {
+		t.Errorf("alias after refused removals = %d, want it still redirecting", w.Code)
+	}
+}
+
+func TestAliasCodesStayUnique(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seed(t, "canon1", "https://example.com/a")
+	env.seed(t, "other1", "https://example.com/b")
+
+	if w := env.do(http.MethodPost, "/api/v1/urls/canon1/aliases", `{"alias":"other1"}`, asAdmin...); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a link's code, got %d", w.Code)
+	}
+	if w := env.do(http.MethodPost, "/api/v1/urls/nope99/aliases", `{"alias":"fresh1"}`, asAdmin...); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown link, got %d", w.Code)
+	}
+	if w := env.do(http.MethodPost, "/api/v1/urls/canon1/aliases", `{"alias":"fresh1"}`, asAdmin...); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := env.do(http.MethodPost, "/api/v1/urls/other1/aliases", `{"alias":"fresh1"}`, asAdmin...); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for another link's alias, got %d", w.Code)
+	}
+	if w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com/c","custom_alias":"fresh1"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 creating a link over an alias, got %d", w.Code)
+	}
+
+	if w := env.do(http.MethodDelete, "/api/v1/urls/other1/aliases/fresh1", "", asAdmin...); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 removing another link's alias, got %d", w.Code)
+	}
+	if w := env.do(http.MethodDelete, "/api/v1/urls/canon1/aliases/fresh1", "", asAdmin...); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 removing the alias, got %d", w.Code)
+	}
+	if w := env.do(http.MethodGet, "/fresh1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected a removed alias to stop resolving, got %d", w.Code)
+	}
+}
+
+func TestCreateSourceIsStoredAndCounted(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seed(t, "legacy", "https://example.com/old")
+
+	w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com/new"}`, ClientSourceHeader, "extension")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created domain.CreateURLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode create response: %v", err)
+	}
+
+	w = env.do(http.MethodGet, "/api/v1/urls/"+created.ShortCode, "", asAdmin...)
+	var info domain.URL
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil || info.Source != "extension" {
+		t.Errorf("link info source = %q (%v), want extension", info.Source, err)
+	}
+
+	w = env.do(http.MethodGet, "/api/v1/stats", "")
+	var stats domain.AggregateStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	want := []domain.SourceStats{{Source: "extension", Links: 1}, {Source: domain.SourceUnknown, Links: 1}}
+	if !slices.Equal(stats.LinksBySource, want) {
+		t.Errorf("links_by_source = %+v, want %+v", stats.LinksBySource, want)
+	}
+
+	w = env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com/x"}`, ClientSourceHeader, "fax")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "source_not_allowed") {
+		t.Errorf("expected 400 source_not_allowed for an unlisted source, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRegenerateRetiresTheOldCode(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{})
+	env.seedOwned(t, "leaked", "https://example.com/private", "alice")
+	env.do(http.MethodGet, "/leaked", "")
+
+	for _, caller := range intruders {
+		if w := env.do(http.MethodPost, "/api/v1/urls/leaked/regenerate", "", caller.header...); w.Code != caller.want {
+			t.Errorf("%s regenerate status = %d, want %d", caller.name, w.Code, caller.want)
+		}
+	}
+
+	w := env.do(http.MethodPost, "/api/v1/urls/leaked/regenerate", "", asAlice...)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp domain.RegenerateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode regenerate response: %v", err)
+	}
+	if resp.ShortCode == "" || resp.ShortCode == "leaked" || resp.PreviousCode != "leaked" || resp.PreviousCodeStatus != "retire" {
+		t.Fatalf("unexpected regenerate response: %+v", resp)
+	}
+
+	if w := env.do(http.MethodGet, "/"+resp.ShortCode, ""); w.Header().Get("Location") != "https://example.com/private" {
+		t.Fatalf("expected the new code to redirect, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	url, err := env.urlRepo.GetByShortCode(context.Background(), resp.ShortCode)
+	if err != nil {
+		t.Fatalf("failed to read the link: %v", err)
+	}
+	if url.ClickCount != 2 {
+		t.Errorf("click_count = %d, want 2 carried over from the old code", url.ClickCount)
+	}
+
+	if w := env.do(http.MethodGet, "/leaked", ""); w.Code != http.StatusGone {
+		t.Errorf("expected 410 for the retired code, got %d", w.Code)
+	}
+	if w := env.do(http.MethodPost, "/api/v1/shorten", `{"original_url":"https://example.com/x","custom_alias":"leaked"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 reusing a retired code, got %d", w.Code)
+	}
+	if w := env.do(http.MethodPost, "/api/v1/urls/nope99/regenerate", "", asAlice...); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown link, got %d", w.Code)
+	}
+}
+
+func TestRegenerateCanKeepTheOldCodeAsAlias(t *testing.T) {
+	env := newTestEnv(t, service.URLServiceConfig{OldCodeBehavior: service.OldCodeAlias})
+	env.seed(t, "printed", "https://example.com/flyer")
+
+	w := env.do(http.MethodPost, "/api/v1/urls/printed/regenerate", "", asAdmin...)
+	var resp domain.RegenerateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.PreviousCodeStatus != "alias" {
+		t.Fatalf("expected the old code kept as an alias, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, code := range []string{"printed", resp.ShortCode} {
+		if w := env.do(http.MethodGet, "/"+code, ""); w.Header().Get("Location") != "https://example.com/flyer" {
+			t.Fatalf("/%s: expected a redirect to the link, got %d %q", code, w.Code, w.Header().Get("Location"))
+		}
+	}
+	url, err := env.urlRepo.GetByShortCode(context.Background(), resp.ShortCode)
+	if err != nil {
+		t.Fatalf("failed to read the link: %v", err)
+	}
+	if url.ClickCount != 2 {
+		t.Errorf("click_count = %d, want 2 across both codes", url.ClickCount)
+	}
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// shortCodePattern is what we accept for custom aliases: letters, digits, '-' and '_'
+var shortCodePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validAlias applies custom_alias's binding rules (min=3,max=20,shortcode)
+// to an alias that arrives outside a request body
+func validAlias(alias string) bool {
+	return len(alias) >= 3 && len(alias) <= 20 && shortCodePattern.MatchString(alias)
+}
+
+var registerOnce sync.Once
+
+// RegisterValidators installs the custom binding tags and reports fields by
+// their JSON name (original_url) instead of the Go name (OriginalURL)
+// Safe to call more than once; main.go and tests both call it
+func RegisterValidators() {
+	registerOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+
+		_ = v.RegisterValidation("shortcode", func(fl validator.FieldLevel) bool {
+			return shortCodePattern.MatchString(fl.Field().String())
+		})
+	})
+}
+
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+type ValidationErrorResponse struct {
+	Error   string       `json:"error"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors"`
+}
+
+// fieldErrors converts validator errors into per-field entries so front-ends
+// can highlight the offending inputs. ok is false for non-validation errors
+// (malformed JSON, wrong types), which keep the plain ErrorResponse shape.
+func fieldErrors(err error) ([]FieldError, bool) {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil, false
+	}
+
+	result := make([]FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		result = append(result, FieldError{
+			Field:  fe.Field(),
+			Reason: fe.Tag(),
+		})
+	}
+	return result, true
+}
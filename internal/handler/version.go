@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BuildInfo identifies the running binary, injected at build time with
+// -ldflags "-X main.version=... -X main.gitCommit=... -X main.buildTime=..."
+type BuildInfo struct {
+	Version   string
+	GitCommit string
+	BuildTime string
+}
+
+type VersionResponse struct {
+	Version       string    `json:"version"`
+	GitCommit     string    `json:"git_commit"`
+	BuildTime     string    `json:"build_time"`
+	GoVersion     string    `json:"go_version"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+}
+
+// Version reports which build is running and for how long
+// Use case: During an incident, confirm a rollout actually reached this instance
+func Version(info BuildInfo, startTime time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, VersionResponse{
+			Version:       info.Version,
+			GitCommit:     info.GitCommit,
+			BuildTime:     info.BuildTime,
+			GoVersion:     runtime.Version(),
+			StartedAt:     startTime.UTC(),
+			UptimeSeconds: time.Since(startTime).Seconds(),
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVersionReportsBuildAndUptime(t *testing.T) {
+	router := gin.New()
+	router.GET("/version", Version(BuildInfo{
+		Version:   "v1.2.3",
+		GitCommit: "abc1234",
+		BuildTime: "2025-01-01T00:00:00Z",
+	}, time.Now()))
+
+	get := func() VersionResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		var resp VersionResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	first := get()
+	if first.Version != "v1.2.3" || first.GitCommit != "abc1234" || first.BuildTime != "2025-01-01T00:00:00Z" {
+		t.Errorf("build info = %+v, want the injected values", first)
+	}
+	if first.GoVersion != runtime.Version() {
+		t.Errorf("go_version = %q, want %q", first.GoVersion, runtime.Version())
+	}
+	if first.StartedAt.IsZero() {
+		t.Error("started_at is zero")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if second := get(); second.UptimeSeconds <= first.UptimeSeconds {
+		t.Errorf("uptime did not increase: %v then %v", first.UptimeSeconds, second.UptimeSeconds)
+	}
+}
@@ -0,0 +1,251 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/pkg/netguard"
+)
+
+// Why an expansion stopped before reaching a page that doesn't redirect
+const (
+	StopLoop        = "redirect_loop"
+	StopMaxHops     = "max_hops"
+	StopBlocked     = "private_address"
+	StopUnreachable = "unreachable"
+	StopTooLarge    = "response_too_large"
+)
+
+var (
+	// ErrInvalidExpandURL means the URL isn't an absolute http(s) URL
+	ErrInvalidExpandURL = errors.New("url must be an absolute http or https url")
+	// ErrExpandBlocked means the URL itself points at a private address
+	ErrExpandBlocked = errors.New("url resolves to a private address")
+	// ErrExpandUnreachable means the first hop couldn't be fetched at all
+	ErrExpandUnreachable = errors.New("url could not be fetched")
+	// ErrResponseTooLarge means a response body went past MaxBytes
+	ErrResponseTooLarge = errors.New("response body exceeds the fetch limit")
+)
+
+type ExpanderConfig struct {
+	Timeout   time.Duration // whole chain, every hop included
+	MaxHops   int           // redirects followed before giving up
+	MaxBytes  int64         // most of one response body read
+	UserAgent string
+
+	// AllowPrivateNetworks lets the expander reach loopback and private
+	// addresses; only tests should need it
+	AllowPrivateNetworks bool
+}
+
+// Hop is one request in a redirect chain
+type Hop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Location   string `json:"location,omitempty"`
+}
+
+// Expansion is where a URL ends up and how it got there
+// FinalURL is the last URL reached; when Truncated is set it isn't the real
+// destination and StopReason says why the chain was cut short.
+type Expansion struct {
+	URL        string `json:"url"`
+	FinalURL   string `json:"final_url"`
+	Hops       []Hop  `json:"hops"`
+	Truncated  bool   `json:"truncated"`
+	StopReason string `json:"stop_reason,omitempty"`
+}
+
+// Expander follows the redirects of a URL, typically someone else's short
+// link, to show where it really goes
+//
+// Use case: previewing a link before clicking it, or un-nesting a short link
+// that points at another shortener. Redirects are followed one at a time
+// rather than by the http.Client so every hop can be recorded, loops spotted
+// and each target checked against the private-address guard before any
+// request is sent.
+type Expander struct {
+	cfg    ExpanderConfig
+	client *http.Client
+}
+
+func NewExpander(cfg ExpanderConfig) *Expander {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxHops <= 0 {
+		cfg.MaxHops = 10
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 10
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "url-shortener-expand/1.0"
+	}
+
+	dialer := netguard.NewDialer(cfg.Timeout, cfg.AllowPrivateNetworks)
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &Expander{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Expand follows rawURL's redirect chain
+// Only an unusable starting URL is an error; anything going wrong further
+// along the chain ends it early with Truncated set, since the hops so far
+// are still the useful part of the answer.
+func (e *Expander) Expand(ctx context.Context, rawURL string) (*Expansion, error) {
+	current, err := parseHTTPURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel()
+
+	result := &Expansion{URL: current.String(), Hops: []Hop{}}
+	visited := make(map[string]bool, e.cfg.MaxHops+1)
+
+	for {
+		result.FinalURL = current.String()
+		if visited[result.FinalURL] {
+			result.Truncated, result.StopReason = true, StopLoop
+			return result, nil
+		}
+		visited[result.FinalURL] = true
+
+		// MaxHops redirects means MaxHops+1 requests
+		if len(result.Hops) > e.cfg.MaxHops {
+			result.Truncated, result.StopReason = true, StopMaxHops
+			return result, nil
+		}
+
+		hop, next, err := e.step(ctx, current)
+		if err != nil {
+			if len(result.Hops) == 0 {
+				switch {
+				case errors.Is(err, netguard.ErrPrivateAddress):
+					return nil, ErrExpandBlocked
+				case errors.Is(err, ErrResponseTooLarge):
+					return nil, err
+				}
+				return nil, fmt.Errorf("%w: %v", ErrExpandUnreachable, err)
+			}
+			result.Truncated, result.StopReason = true, StopUnreachable
+			switch {
+			case errors.Is(err, netguard.ErrPrivateAddress):
+				result.StopReason = StopBlocked
+			case errors.Is(err, ErrResponseTooLarge):
+				result.StopReason = StopTooLarge
+			}
+			return result, nil
+		}
+		result.Hops = append(result.Hops, hop)
+
+		// Not a redirect, or one to something that isn't a web page
+		// (mailto:, an app scheme): this is the destination
+		if next == nil {
+			if hop.Location != "" {
+				result.FinalURL = hop.Location
+			}
+			return result, nil
+		}
+		current = next
+	}
+}
+
+// step requests target once and returns where it redirects to, nil if it
+// doesn't redirect anywhere that can be followed
+func (e *Expander) step(ctx context.Context, target *url.URL) (Hop, *url.URL, error) {
+	hop := Hop{URL: target.String()}
+
+	// GET rather than HEAD, plenty of shorteners answer HEAD with a 405 or a
+	// 200 instead of the redirect; the body is never read
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hop.URL, nil)
+	if err != nil {
+		return hop, nil, err
+	}
+	req.Header.Set("User-Agent", e.cfg.UserAgent)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return hop, nil, err
+	}
+	defer resp.Body.Close()
+	hop.StatusCode = resp.StatusCode
+
+	// The destination's own body is never read, however big it is
+	if !isRedirect(resp.StatusCode) {
+		return hop, nil, nil
+	}
+	// A redirect is drained so the connection can be reused, but only up to
+	// MaxBytes: one streaming an endless body is refusing to be followed
+	if err := e.drain(resp); err != nil {
+		return hop, nil, err
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return hop, nil, nil
+	}
+	next, err := target.Parse(location)
+	if err != nil {
+		return hop, nil, nil
+	}
+	hop.Location = next.String()
+	if next.Scheme != "http" && next.Scheme != "https" {
+		return hop, nil, nil
+	}
+	return hop, next, nil
+}
+
+// drain reads and discards resp's body, failing once it passes MaxBytes
+func (e *Expander) drain(resp *http.Response) error {
+	if resp.ContentLength > e.cfg.MaxBytes {
+		return fmt.Errorf("%w: %d bytes announced, limit %d", ErrResponseTooLarge, resp.ContentLength, e.cfg.MaxBytes)
+	}
+	// A read error here doesn't matter, Location is already in hand
+	n, _ := io.Copy(io.Discard, io.LimitReader(resp.Body, e.cfg.MaxBytes+1))
+	if n > e.cfg.MaxBytes {
+		return fmt.Errorf("%w: limit %d bytes", ErrResponseTooLarge, e.cfg.MaxBytes)
+	}
+	return nil
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// parseHTTPURL accepts absolute http(s) URLs with a host, nothing else is
+// worth sending a request to
+func parseHTTPURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidExpandURL
+	}
+	u.Fragment = ""
+	return u, nil
+}
@@ -0,0 +1,189 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRedirectSite serves /a -> /b -> /c -> /final, a /loop1 <-> /loop2 cycle
+// and an endless /n/<i> -> /n/<i+1> chain
+func newRedirectSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	redirect := func(from, to string, status int) {
+		mux.HandleFunc(from, func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, to, status)
+		})
+	}
+	redirect("/a", "/b", http.StatusMovedPermanently)
+	redirect("/b", "/c?x=1", http.StatusFound)
+	redirect("/c", "/final", http.StatusTemporaryRedirect)
+	redirect("/loop1", "/loop2", http.StatusFound)
+	redirect("/loop2", "/loop1", http.StatusFound)
+	redirect("/app", "myapp://open", http.StatusFound)
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("done"))
+	})
+	mux.HandleFunc("/n/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestExpander(maxHops int) *Expander {
+	return NewExpander(ExpanderConfig{Timeout: 2 * time.Second, MaxHops: maxHops, AllowPrivateNetworks: true})
+}
+
+func TestExpandFollowsChain(t *testing.T) {
+	srv := newRedirectSite(t)
+
+	got, err := newTestExpander(10).Expand(context.Background(), srv.URL+"/a#frag")
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	if got.Truncated {
+		t.Errorf("truncated = true (%s), want complete chain", got.StopReason)
+	}
+	if got.URL != srv.URL+"/a" {
+		t.Errorf("url = %q, want fragment dropped", got.URL)
+	}
+	if got.FinalURL != srv.URL+"/final" {
+		t.Errorf("final_url = %q, want %q", got.FinalURL, srv.URL+"/final")
+	}
+
+	want := []Hop{
+		{URL: srv.URL + "/a", StatusCode: http.StatusMovedPermanently, Location: srv.URL + "/b"},
+		{URL: srv.URL + "/b", StatusCode: http.StatusFound, Location: srv.URL + "/c?x=1"},
+		{URL: srv.URL + "/c?x=1", StatusCode: http.StatusTemporaryRedirect, Location: srv.URL + "/final"},
+		{URL: srv.URL + "/final", StatusCode: http.StatusOK},
+	}
+	if len(got.Hops) != len(want) {
+		t.Fatalf("hops = %+v, want %d", got.Hops, len(want))
+	}
+	for i := range want {
+		if got.Hops[i] != want[i] {
+			t.Errorf("hop %d = %+v, want %+v", i, got.Hops[i], want[i])
+		}
+	}
+}
+
+func TestExpandTruncatesLoops(t *testing.T) {
+	srv := newRedirectSite(t)
+
+	got, err := newTestExpander(10).Expand(context.Background(), srv.URL+"/loop1")
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	if !got.Truncated || got.StopReason != StopLoop {
+		t.Errorf("truncated = %v, stop_reason = %q, want %q", got.Truncated, got.StopReason, StopLoop)
+	}
+	// Each URL in the cycle is requested once
+	if len(got.Hops) != 2 {
+		t.Errorf("hops = %+v, want 2", got.Hops)
+	}
+	if got.FinalURL != srv.URL+"/loop1" {
+		t.Errorf("final_url = %q, want the URL that closed the loop", got.FinalURL)
+	}
+}
+
+func TestExpandStopsAtMaxHops(t *testing.T) {
+	srv := newRedirectSite(t)
+
+	got, err := newTestExpander(3).Expand(context.Background(), srv.URL+"/n/")
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	if !got.Truncated || got.StopReason != StopMaxHops {
+		t.Errorf("truncated = %v, stop_reason = %q, want %q", got.Truncated, got.StopReason, StopMaxHops)
+	}
+	if len(got.Hops) != 4 {
+		t.Errorf("hops = %d, want 4 requests for 3 redirects", len(got.Hops))
+	}
+}
+
+func TestExpandStopsAtNonWebScheme(t *testing.T) {
+	srv := newRedirectSite(t)
+
+	got, err := newTestExpander(10).Expand(context.Background(), srv.URL+"/app")
+	if err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	if got.Truncated || got.FinalURL != "myapp://open" || len(got.Hops) != 1 {
+		t.Errorf("got %+v, want final_url myapp://open after one hop", got)
+	}
+}
+
+func TestExpandRejectsBadInput(t *testing.T) {
+	srv := newRedirectSite(t)
+	e := NewExpander(ExpanderConfig{Timeout: time.Second})
+
+	if _, err := e.Expand(context.Background(), srv.URL+"/a"); !errors.Is(err, ErrExpandBlocked) {
+		t.Errorf("loopback url: err = %v, want ErrExpandBlocked", err)
+	}
+	for _, raw := range []string{"", "example.com/x", "ftp://example.com/x", "javascript:alert(1)", "http:///path"} {
+		if _, err := e.Expand(context.Background(), raw); !errors.Is(err, ErrInvalidExpandURL) {
+			t.Errorf("Expand(%q): err = %v, want ErrInvalidExpandURL", raw, err)
+		}
+	}
+}
+
+func TestExpandCapsResponseBodies(t *testing.T) {
+	// Redirects that keep streaming, with and without announcing a length,
+	// and a destination page far bigger than the limit
+	chunk := strings.Repeat("x", 1024)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/final")
+		w.WriteHeader(http.StatusFound)
+		for i := 0; i < 1024; i++ {
+			if _, err := w.Write([]byte(chunk)); err != nil {
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/announced", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/final")
+		w.Header().Set("Content-Length", "10000000")
+		w.WriteHeader(http.StatusFound)
+	})
+	mux.HandleFunc("/hop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/stream", http.StatusFound)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 1024; i++ {
+			w.Write([]byte(chunk))
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	e := NewExpander(ExpanderConfig{Timeout: 2 * time.Second, MaxBytes: 16 << 10, AllowPrivateNetworks: true})
+	ctx := context.Background()
+
+	for _, path := range []string{"/stream", "/announced"} {
+		if _, err := e.Expand(ctx, srv.URL+path); !errors.Is(err, ErrResponseTooLarge) {
+			t.Errorf("Expand(%s): err = %v, want ErrResponseTooLarge", path, err)
+		}
+	}
+
+	got, err := e.Expand(ctx, srv.URL+"/hop")
+	if err != nil {
+		t.Fatalf("Expand(/hop): %v", err)
+	}
+	if !got.Truncated || got.StopReason != StopTooLarge {
+		t.Errorf("truncated = %v, stop_reason = %q, want %q", got.Truncated, got.StopReason, StopTooLarge)
+	}
+
+	// Past a well-behaved redirect, the big page itself is never read
+	got, err = NewExpander(ExpanderConfig{Timeout: 2 * time.Second, MaxBytes: 16 << 10, AllowPrivateNetworks: true}).Expand(ctx, srv.URL+"/final")
+	if err != nil || got.Truncated || got.FinalURL != srv.URL+"/final" {
+		t.Errorf("Expand(/final) = %+v, %v; want the page as destination", got, err)
+	}
+}
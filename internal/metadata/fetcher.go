@@ -0,0 +1,281 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/netguard"
+	"golang.org/x/net/html"
+)
+
+var (
+	// ErrDisallowed means the site's robots.txt asks us not to fetch the page
+	ErrDisallowed = errors.New("fetch disallowed by robots.txt")
+	// ErrNotHTML means the destination isn't a page there is anything to read from
+	ErrNotHTML = errors.New("destination is not an html page")
+
+	errPrivateAddress = netguard.ErrPrivateAddress
+)
+
+// Stored values are capped, pages can put anything in their tags
+const (
+	maxTitleLen       = 300
+	maxDescriptionLen = 1000
+	maxImageURLLen    = 2048
+	maxRobotsBytes    = 64 << 10
+)
+
+type FetcherConfig struct {
+	Timeout   time.Duration // whole fetch, robots.txt included
+	MaxBytes  int64         // most of the page read, the <head> is near the top
+	UserAgent string
+
+	// AllowPrivateNetworks lets the fetcher reach loopback and private
+	// addresses; only tests should need it
+	AllowPrivateNetworks bool
+}
+
+// Fetcher reads a destination's <title> and OpenGraph tags
+//
+// Destinations are user input, so the fetcher is defensive: it honours
+// robots.txt, stops reading after MaxBytes, only parses text/html and refuses
+// to connect to private addresses so a link can't be used to probe the
+// internal network.
+type Fetcher struct {
+	cfg    FetcherConfig
+	client *http.Client
+}
+
+func NewFetcher(cfg FetcherConfig) *Fetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 512 << 10
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "url-shortener-preview/1.0"
+	}
+
+	dialer := netguard.NewDialer(cfg.Timeout, cfg.AllowPrivateNetworks)
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &Fetcher{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 5 {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Fetch returns the metadata of the page at rawURL
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (domain.LinkMetadata, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.cfg.Timeout)
+	defer cancel()
+
+	page, err := url.Parse(rawURL)
+	if err != nil || (page.Scheme != "http" && page.Scheme != "https") || page.Host == "" {
+		return domain.LinkMetadata{}, fmt.Errorf("unsupported destination %q", rawURL)
+	}
+
+	if !f.robotsAllow(ctx, page) {
+		return domain.LinkMetadata{}, ErrDisallowed
+	}
+
+	resp, err := f.get(ctx, page.String(), "text/html,application/xhtml+xml")
+	if err != nil {
+		return domain.LinkMetadata{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return domain.LinkMetadata{}, fmt.Errorf("destination returned status %d", resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return domain.LinkMetadata{}, ErrNotHTML
+	}
+
+	// Redirects may have moved us, relative image URLs resolve against the final page
+	meta := parseHead(io.LimitReader(resp.Body, f.cfg.MaxBytes), resp.Request.URL)
+	return meta, nil
+}
+
+func (f *Fetcher) get(ctx context.Context, target, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Accept", accept)
+	return f.client.Do(req)
+}
+
+// robotsAllow fetches the site's robots.txt and checks page against it
+// A missing or unreadable robots.txt allows everything, as crawlers do
+func (f *Fetcher) robotsAllow(ctx context.Context, page *url.URL) bool {
+	robotsURL := url.URL{Scheme: page.Scheme, Host: page.Host, Path: "/robots.txt"}
+	resp, err := f.get(ctx, robotsURL.String(), "text/plain")
+	if err != nil {
+		return true
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return true
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBytes))
+	if err != nil {
+		return true
+	}
+	return parseRobots(string(body), f.cfg.UserAgent).allows(page.EscapedPath())
+}
+
+// parseHead reads metadata from the document's <head>
+// OpenGraph tags win over <title> and <meta name="description">, they are
+// written for exactly this purpose
+func parseHead(r io.Reader, base *url.URL) domain.LinkMetadata {
+	var (
+		meta                 domain.LinkMetadata
+		title, description   string
+		ogTitle, ogDesc, img string
+		inTitle              bool
+	)
+
+	z := html.NewTokenizer(r)
+loop:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			// EOF or the size cap, use what we have
+			break loop
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = true
+			case "meta":
+				if !hasAttr {
+					continue
+				}
+				attrs := tagAttrs(z)
+				key := attrs["property"]
+				if key == "" {
+					key = attrs["name"]
+				}
+				content := attrs["content"]
+				switch strings.ToLower(key) {
+				case "og:title":
+					ogTitle = content
+				case "og:description":
+					ogDesc = content
+				case "og:image", "og:image:url":
+					if img == "" {
+						img = content
+					}
+				case "description":
+					description = content
+				}
+			case "body":
+				break loop
+			}
+
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = string(z.Text())
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				break loop
+			}
+		}
+	}
+
+	meta.Title = clean(firstNonEmpty(ogTitle, title), maxTitleLen)
+	meta.Description = clean(firstNonEmpty(ogDesc, description), maxDescriptionLen)
+	meta.ImageURL = resolveImage(img, base)
+	return meta
+}
+
+func tagAttrs(z *html.Tokenizer) map[string]string {
+	attrs := make(map[string]string)
+	for {
+		key, val, more := z.TagAttr()
+		attrs[strings.ToLower(string(key))] = string(val)
+		if !more {
+			return attrs
+		}
+	}
+}
+
+// resolveImage makes a relative og:image absolute and drops anything that
+// isn't a plain http(s) URL
+func resolveImage(raw string, base *url.URL) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	abs := base.ResolveReference(ref)
+	if abs.Scheme != "http" && abs.Scheme != "https" {
+		return ""
+	}
+	if s := abs.String(); len(s) <= maxImageURLLen {
+		return s
+	}
+	return ""
+}
+
+// clean collapses whitespace and truncates to max bytes on a rune boundary
+func clean(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !isRuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
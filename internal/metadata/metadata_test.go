@@ -0,0 +1,196 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"go.uber.org/zap"
+)
+
+const ogPage = `<!doctype html>
+<html><head>
+<title>  Plain   title </title>
+<meta name="description" content="Plain description">
+<meta property="og:title" content="Rich title">
+<meta property="og:description" content="Rich description">
+<meta property="og:image" content="/img/card.png">
+</head><body><meta property="og:title" content="Not in head"></body></html>`
+
+func newSite(t *testing.T, robots string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		if robots == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(robots))
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(ogPage))
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Only a title</title></head></html>`))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><!--" + strings.Repeat("x", 4096) + `--><title>Too far</title></head></html>`))
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestFetcher(maxBytes int64) *Fetcher {
+	return NewFetcher(FetcherConfig{
+		Timeout:              2 * time.Second,
+		MaxBytes:             maxBytes,
+		AllowPrivateNetworks: true,
+	})
+}
+
+func TestFetchReadsOpenGraphTags(t *testing.T) {
+	srv := newSite(t, "")
+	f := newTestFetcher(0)
+
+	meta, err := f.Fetch(context.Background(), srv.URL+"/article")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := domain.LinkMetadata{
+		Title:       "Rich title",
+		Description: "Rich description",
+		ImageURL:    srv.URL + "/img/card.png",
+	}
+	if meta != want {
+		t.Errorf("Fetch = %+v, want %+v", meta, want)
+	}
+
+	meta, err = f.Fetch(context.Background(), srv.URL+"/plain")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if meta.Title != "Only a title" || meta.Description != "" || meta.ImageURL != "" {
+		t.Errorf("Fetch without OpenGraph = %+v, want just the <title>", meta)
+	}
+}
+
+func TestFetchFailsSoft(t *testing.T) {
+	srv := newSite(t, "User-agent: *\nDisallow: /private\n\nUser-agent: url-shortener-preview\nDisallow: /article\n")
+	f := newTestFetcher(1024)
+	ctx := context.Background()
+
+	if _, err := f.Fetch(ctx, srv.URL+"/article"); !errors.Is(err, ErrDisallowed) {
+		t.Errorf("robots.txt disallowed page: err = %v, want ErrDisallowed", err)
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/file.pdf"); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("pdf: err = %v, want ErrNotHTML", err)
+	}
+
+	// Reading stops at MaxBytes, the title past it is never seen
+	meta, err := f.Fetch(ctx, srv.URL+"/big")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if meta.Title != "" {
+		t.Errorf("title past the size cap = %q, want empty", meta.Title)
+	}
+}
+
+func TestFetchRefusesPrivateAddresses(t *testing.T) {
+	srv := newSite(t, "")
+	f := NewFetcher(FetcherConfig{Timeout: time.Second})
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/article"); !errors.Is(err, errPrivateAddress) {
+		t.Errorf("loopback destination: err = %v, want errPrivateAddress", err)
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	body := `# comment
+User-agent: *
+Disallow: /admin
+Allow: /admin/public
+
+User-agent: otherbot
+Disallow: /
+`
+	rules := parseRobots(body, "url-shortener-preview/1.0")
+	for path, want := range map[string]bool{
+		"/":                 true,
+		"/admin":            false,
+		"/admin/users":      false,
+		"/admin/public/faq": true,
+	} {
+		if got := rules.allows(path); got != want {
+			t.Errorf("allows(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	// An empty Disallow in our own group allows everything, whatever "*" says
+	rules = parseRobots("User-agent: *\nDisallow: /\n\nUser-agent: url-shortener-preview\nDisallow:\n", "url-shortener-preview/1.0")
+	if !rules.allows("/anything") {
+		t.Error("specific group with empty Disallow should allow everything")
+	}
+}
+
+func TestWorkerStoresMetadata(t *testing.T) {
+	srv := newSite(t, "")
+	urls := memory.NewURLRepository()
+	cache := memory.NewCacheRepository(time.Hour)
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	link := &domain.URL{ShortURL: "abc123", OriginalURL: srv.URL + "/article", IsActive: true}
+	if err := urls.Create(ctx, link); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	cache.Set(ctx, link, 0)
+
+	w := NewWorker(newTestFetcher(0), urls, cache, 10, zap.NewNop(), m)
+	go w.Run(ctx)
+	w.Enqueue("abc123", link.OriginalURL)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stored, err := urls.GetByShortCode(ctx, "abc123")
+		if err != nil {
+			t.Fatalf("GetByShortCode: %v", err)
+		}
+		if stored.Title != "" {
+			if stored.Title != "Rich title" || stored.ImageURL != srv.URL+"/img/card.png" {
+				t.Errorf("stored metadata = %q / %q", stored.Title, stored.ImageURL)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("metadata was never stored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The stale cached copy is dropped so readers see the preview
+	if cached, _ := cache.Get(ctx, "abc123"); cached != nil {
+		t.Error("cache entry was not invalidated")
+	}
+	if got := testutil.ToFloat64(m.MetadataFetchesTotal.WithLabelValues("success")); got != 1 {
+		t.Errorf("metadata_fetches_total{success} = %v, want 1", got)
+	}
+}
@@ -0,0 +1,98 @@
+package metadata
+
+import (
+	"strings"
+)
+
+// robotsRules are the Allow/Disallow lines of the group that applies to us
+type robotsRules struct {
+	allow    []string
+	disallow []string
+}
+
+// parseRobots picks the rules for userAgent out of a robots.txt body
+// A group naming our product token wins over the "*" group. Only plain path
+// prefixes are supported; wildcard patterns are ignored rather than guessed at.
+func parseRobots(body, userAgent string) robotsRules {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexByte(token, '/'); i >= 0 {
+		token = token[:i]
+	}
+
+	var (
+		specific, generic robotsRules
+		foundSpecific     bool
+		// agents of the group being read; consecutive User-agent lines share a group
+		groupAgents []string
+		inRules     bool
+	)
+
+	for _, line := range strings.Split(body, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+
+		case "allow", "disallow":
+			inRules = true
+			for _, agent := range groupAgents {
+				var rules *robotsRules
+				switch {
+				case agent == "*":
+					rules = &generic
+				case agent != "" && strings.Contains(token, agent):
+					// Even an empty "Disallow:" makes this our group
+					rules = &specific
+					foundSpecific = true
+				default:
+					continue
+				}
+				if value == "" || strings.ContainsAny(value, "*$") {
+					continue
+				}
+				if key == "allow" {
+					rules.allow = append(rules.allow, value)
+				} else {
+					rules.disallow = append(rules.disallow, value)
+				}
+			}
+		}
+	}
+
+	if foundSpecific {
+		return specific
+	}
+	return generic
+}
+
+// allows applies the longest matching rule, Allow winning ties
+func (r robotsRules) allows(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allowLen, disallowLen := longestPrefix(r.allow, path), longestPrefix(r.disallow, path)
+	return disallowLen < 0 || allowLen >= disallowLen
+}
+
+func longestPrefix(prefixes []string, path string) int {
+	longest := -1
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) && len(p) > longest {
+			longest = len(p)
+		}
+	}
+	return longest
+}
@@ -0,0 +1,96 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+type job struct {
+	link        string // domain.LinkKey
+	destination string
+}
+
+// Worker fetches link preview metadata off the request path
+//
+// Enqueue only queues; Run fetches one page at a time and stores what it
+// finds, so a slow destination never adds latency to create. Every failure is
+// soft: the link simply has no preview.
+type Worker struct {
+	fetcher *Fetcher
+	urls    domain.URLRepository
+	cache   domain.CacheRepository
+	queue   chan job
+	logger  *zap.Logger
+	metrics *metrics.Metrics
+}
+
+func NewWorker(fetcher *Fetcher, urls domain.URLRepository, cache domain.CacheRepository, queueSize int, logger *zap.Logger, m *metrics.Metrics) *Worker {
+	if queueSize <= 0 {
+		queueSize = 1000
+	}
+	return &Worker{
+		fetcher: fetcher,
+		urls:    urls,
+		cache:   cache,
+		queue:   make(chan job, queueSize),
+		logger:  logger,
+		metrics: m,
+	}
+}
+
+var _ domain.MetadataQueue = (*Worker)(nil)
+
+func (w *Worker) Enqueue(link, destination string) {
+	select {
+	case w.queue <- job{link: link, destination: destination}:
+	default:
+		// Queue full - a missing preview beats a slow create
+		w.metrics.MetadataFetchesTotal.WithLabelValues("dropped").Inc()
+		w.logger.Warn("metadata queue full, dropping fetch", zap.String("link", link))
+	}
+}
+
+// Run fetches queued pages until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-w.queue:
+			w.process(ctx, j)
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, j job) {
+	prefix, shortCode := domain.SplitLinkKey(j.link)
+	ctx = domain.WithCodePrefix(ctx, prefix)
+
+	meta, err := w.fetcher.Fetch(ctx, j.destination)
+	if err != nil {
+		result := "error"
+		if errors.Is(err, ErrDisallowed) {
+			result = "disallowed"
+		}
+		w.metrics.MetadataFetchesTotal.WithLabelValues(result).Inc()
+		w.logger.Debug("metadata fetch failed", zap.Error(err), zap.String("short_code", shortCode))
+		return
+	}
+	w.metrics.MetadataFetchesTotal.WithLabelValues("success").Inc()
+
+	if meta == (domain.LinkMetadata{}) {
+		return
+	}
+	if err := w.urls.SetMetadata(ctx, shortCode, meta); err != nil {
+		w.logger.Warn("failed to store metadata", zap.Error(err), zap.String("short_code", shortCode))
+		return
+	}
+	// The cached copy predates the metadata
+	if err := w.cache.Delete(ctx, shortCode); err != nil {
+		w.logger.Warn("failed to invalidate cache after metadata fetch", zap.Error(err), zap.String("short_code", shortCode))
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/problem"
+)
+
+// AdminAuth guards operator endpoints with a static bearer token
+// The comparison is constant-time so the token can't be guessed byte by byte
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			problem.Abort(c, http.StatusUnauthorized, "unauthorized", "Admin token required")
+			return
+		}
+		c.Request = c.Request.WithContext(domain.WithAdmin(c.Request.Context()))
+		c.Next()
+	}
+}
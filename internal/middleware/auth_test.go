@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(APIKeyAuth(map[string]string{"k-alice": "alice"}, "admin-secret"))
+	router.GET("/whoami", func(c *gin.Context) {
+		user, _ := domain.CallerFrom(c.Request.Context())
+		if domain.IsAdmin(c.Request.Context()) {
+			user = "(admin)"
+		}
+		c.String(http.StatusOK, user)
+	})
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+		wantUser   string
+	}{
+		{"anonymous", "", "", http.StatusOK, ""},
+		{"api key header", APIKeyHeader, "k-alice", http.StatusOK, "alice"},
+		{"bearer token", "Authorization", "Bearer k-alice", http.StatusOK, "alice"},
+		{"unknown key", APIKeyHeader, "k-mallory", http.StatusUnauthorized, ""},
+		{"unknown bearer", "Authorization", "Bearer k-mallory", http.StatusUnauthorized, ""},
+		{"admin token", "Authorization", "Bearer admin-secret", http.StatusOK, "(admin)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && w.Body.String() != tt.wantUser {
+				t.Errorf("caller = %q, want %q", w.Body.String(), tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/problem"
+	"github.com/ugorji/go/codec"
+)
+
+func newMaintenanceRouter(m *Maintenance) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/:code", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "https://example.com")
+	})
+
+	api := router.Group("/api/v1")
+	api.Use(m.BlockWrites())
+	api.POST("/shorten", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	api.GET("/stats", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	admin := router.Group("/admin")
+	admin.Use(AdminAuth("s3cret"))
+	admin.GET("/maintenance", m.Status)
+	admin.PUT("/maintenance", m.Toggle)
+	return router
+}
+
+func serve(router *gin.Engine, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMaintenanceBlocksWritesButNotRedirects(t *testing.T) {
+	m := NewMaintenance(false, 2*time.Minute)
+	router := newMaintenanceRouter(m)
+	adminHeader := map[string]string{"Authorization": "Bearer s3cret", "Content-Type": "application/json"}
+
+	if w := serve(router, http.MethodPut, "/admin/maintenance", `{"enabled":true}`, adminHeader); w.Code != http.StatusOK {
+		t.Fatalf("toggle on: status = %d, want 200", w.Code)
+	}
+
+	w := serve(router, http.MethodPost, "/api/v1/shorten", "", nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("write during maintenance: status = %d, want 503", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "120" {
+		t.Errorf("Retry-After = %q, want 120", got)
+	}
+
+	if w := serve(router, http.MethodGet, "/abc123", "", nil); w.Code != http.StatusFound {
+		t.Errorf("redirect during maintenance: status = %d, want 302", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/api/v1/stats", "", nil); w.Code != http.StatusOK {
+		t.Errorf("read during maintenance: status = %d, want 200", w.Code)
+	}
+
+	if w := serve(router, http.MethodPut, "/admin/maintenance", `{"enabled":false}`, adminHeader); w.Code != http.StatusOK {
+		t.Fatalf("toggle off: status = %d, want 200", w.Code)
+	}
+	if w := serve(router, http.MethodPost, "/api/v1/shorten", "", nil); w.Code != http.StatusCreated {
+		t.Errorf("write after maintenance: status = %d, want 201", w.Code)
+	}
+}
+
+func TestMaintenanceToggleRequiresAdminToken(t *testing.T) {
+	m := NewMaintenance(false, 0)
+	router := newMaintenanceRouter(m)
+
+	w := serve(router, http.MethodPut, "/admin/maintenance", `{"enabled":true}`,
+		map[string]string{"Authorization": "Bearer wrong", "Content-Type": "application/json"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+	if m.Enabled() {
+		t.Error("maintenance was enabled without a valid admin token")
+	}
+}
+
+func TestMaintenanceAdminEndpointsNegotiate(t *testing.T) {
+	m := NewMaintenance(true, time.Minute)
+	router := newMaintenanceRouter(m)
+
+	w := serve(router, http.MethodGet, "/admin/maintenance", "",
+		map[string]string{"Authorization": "Bearer s3cret", "Accept": "application/msgpack"})
+	if ct := w.Header().Get("Content-Type"); w.Code != http.StatusOK || !strings.HasPrefix(ct, "application/msgpack") {
+		t.Fatalf("status = %d, Content-Type = %q; want 200 msgpack", w.Code, ct)
+	}
+	var status map[string]bool
+	var mh codec.MsgpackHandle
+	if err := codec.NewDecoderBytes(w.Body.Bytes(), &mh).Decode(&status); err != nil || !status["maintenance"] {
+		t.Errorf("msgpack body = %v, %v; want maintenance true", status, err)
+	}
+
+	w = serve(router, http.MethodPut, "/admin/maintenance", `{}`,
+		map[string]string{"Authorization": "Bearer s3cret", "Content-Type": "application/json", "Accept": problem.ContentType})
+	if ct := w.Header().Get("Content-Type"); w.Code != http.StatusBadRequest || ct != problem.ContentType {
+		t.Errorf("bad toggle: status = %d, Content-Type = %q; want 400 %s", w.Code, ct, problem.ContentType)
+	}
+	if !m.Enabled() {
+		t.Error("a bad toggle changed the mode")
+	}
+}
+
+func TestMiddlewareErrorsNegotiateProblemDetails(t *testing.T) {
+	m := NewMaintenance(true, time.Minute)
+	router := newMaintenanceRouter(m)
+	accept := map[string]string{"Accept": problem.ContentType}
+
+	tests := []struct {
+		name, method, path string
+		status             int
+		code               string
+	}{
+		{"maintenance", http.MethodPost, "/api/v1/shorten", http.StatusServiceUnavailable, "maintenance"},
+		{"admin auth", http.MethodGet, "/admin/maintenance", http.StatusUnauthorized, "unauthorized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, tt.method, tt.path, "", accept)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, problem.ContentType)
+			}
+			var got problem.Details
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode %s: %v", w.Body.String(), err)
+			}
+			if got.Type != "urn:url-shortener:problem:"+tt.code || got.Status != tt.status || got.Instance != tt.path {
+				t.Errorf("problem = %+v, want type %s for %s", got, tt.code, tt.path)
+			}
+
+			// Without the Accept header the legacy shape is unchanged
+			w = serve(router, tt.method, tt.path, "", nil)
+			var legacy map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &legacy); err != nil || legacy["error"] != tt.code || legacy["message"] == "" {
+				t.Errorf("legacy body = %s, want error %q with a message", w.Body.String(), tt.code)
+			}
+		})
+	}
+}
@@ -23,6 +23,8 @@ func MetricsMiddleware(m *metrics.Metrics) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Start tracking time for this request
 		start := time.Now()
+		// Handlers timing part of the request (e.g. redirect TTFB) start here too
+		c.Set(metrics.RequestStartKey, start)
 
 		// Increment active requests gauge
 		// Why? This shows saturation - if it keeps growing, you're overloaded
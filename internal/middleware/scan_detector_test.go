@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"go.uber.org/zap"
+)
+
+func newScanRouter(cfg ScanDetectorConfig, m *metrics.Metrics) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ScanDetector(memory.NewScanCounter(), cfg, m, zap.NewNop()))
+	router.GET("/:shortCode", func(c *gin.Context) {
+		if c.Param("shortCode") == "known" {
+			c.Redirect(http.StatusFound, "https://example.com")
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "not_found"})
+	})
+	return router
+}
+
+func get(router *gin.Engine, path, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":1234"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestScanDetectorBlocksScanningClient(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	router := newScanRouter(ScanDetectorConfig{Threshold: 3, Window: time.Minute, Action: ScanActionBlock}, m)
+
+	// Walk the keyspace until the detector trips
+	for _, code := range []string{"aaa", "aab", "aac"} {
+		if rec := get(router, "/"+code, "10.0.0.1"); rec.Code != http.StatusNotFound {
+			t.Fatalf("GET /%s = %d, want 404 before the threshold", code, rec.Code)
+		}
+	}
+
+	rec := get(router, "/aad", "10.0.0.1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429 once the threshold is reached", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("Retry-After = %q, want 60", rec.Header().Get("Retry-After"))
+	}
+
+	// Flagged clients are blocked even for real links
+	if rec := get(router, "/known", "10.0.0.1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429 for a flagged client", rec.Code)
+	}
+
+	// Other clients are unaffected
+	if rec := get(router, "/known", "10.0.0.2"); rec.Code != http.StatusFound {
+		t.Errorf("status = %d, want 302 for an unflagged client", rec.Code)
+	}
+
+	if got := testutil.ToFloat64(m.ScanAttemptsTotal.WithLabelValues(ScanActionBlock)); got != 2 {
+		t.Errorf("scan_attempts_total{action=block} = %v, want 2", got)
+	}
+}
+
+func TestScanDetectorDelaysScanningClient(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	delay := 50 * time.Millisecond
+	router := newScanRouter(ScanDetectorConfig{Threshold: 2, Window: time.Minute, Action: ScanActionDelay, DecoyDelay: delay}, m)
+
+	get(router, "/aaa", "10.0.0.1")
+	get(router, "/aab", "10.0.0.1")
+
+	start := time.Now()
+	rec := get(router, "/known", "10.0.0.1")
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("request took %v, want at least the decoy delay %v", elapsed, delay)
+	}
+	// The tarpit answers normally so the scanner can't tell it was flagged
+	if rec.Code != http.StatusFound {
+		t.Errorf("status = %d, want 302", rec.Code)
+	}
+	if got := testutil.ToFloat64(m.ScanAttemptsTotal.WithLabelValues(ScanActionDelay)); got != 1 {
+		t.Errorf("scan_attempts_total{action=delay} = %v, want 1", got)
+	}
+}
+
+func TestScanDetectorIgnoresOccasionalMisses(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	router := newScanRouter(ScanDetectorConfig{Threshold: 3, Window: time.Minute, Action: ScanActionBlock}, m)
+
+	for i := 0; i < 10; i++ {
+		if rec := get(router, "/known", "10.0.0.1"); rec.Code != http.StatusFound {
+			t.Fatalf("status = %d, want 302", rec.Code)
+		}
+	}
+	get(router, "/typo", "10.0.0.1")
+
+	if rec := get(router, "/known", "10.0.0.1"); rec.Code != http.StatusFound {
+		t.Errorf("status = %d, want 302 after a single miss", rec.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveWithHeaders(cfg SecurityHeadersConfig, overTLS bool) http.Header {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(SecurityHeaders(cfg))
+	router.GET("/:code", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "https://example.com")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	if overTLS {
+		req.TLS = &tls.ConnectionState{}
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Header()
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	full := SecurityHeadersConfig{
+		HSTS:                  true,
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		NoSniff:               true,
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		ContentSecurityPolicy: "default-src 'none'",
+	}
+
+	h := serveWithHeaders(full, true)
+	want := map[string]string{
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Content-Security-Policy":   "default-src 'none'",
+	}
+	for k, v := range want {
+		if got := h.Get(k); got != v {
+			t.Errorf("over TLS %s = %q, want %q", k, got, v)
+		}
+	}
+
+	// Plain HTTP gets everything but HSTS
+	h = serveWithHeaders(full, false)
+	if got := h.Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("plain HTTP Strict-Transport-Security = %q, want none", got)
+	}
+	if got := h.Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("plain HTTP X-Content-Type-Options = %q, want nosniff", got)
+	}
+
+	// Every header can be switched off
+	h = serveWithHeaders(SecurityHeadersConfig{HSTSMaxAge: time.Hour}, true)
+	for k := range want {
+		if got := h.Get(k); got != "" {
+			t.Errorf("disabled %s = %q, want none", k, got)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package base62
 
 import (
 	"errors"
-	"math"
 	"strings"
 )
 
@@ -11,29 +10,46 @@ const (
 	Base     = 62
 )
 
+// Decode errors are allocated once, not per failed lookup
+var (
+	errEmpty       = errors.New("empty string")
+	errInvalidChar = errors.New("invalid character in base62 string")
+)
+
 // alphabet index maps the characters to their index, it helps for the o(1) lookup
-var alphabetIndex = make(map[rune]int64)
+// Learning: a byte-indexed array instead of a map[rune] - Decode is on the
+// lookup path, and an array load is far cheaper than a map hash; -1 marks
+// characters outside the alphabet
+var alphabetIndex [256]int8
 
-// init function fills the map
+// init function fills the table
 func init(){
-	for ind, char := range Alphabet{
-		alphabetIndex[char] = int64(ind)
+	for i := range alphabetIndex {
+		alphabetIndex[i] = -1
+	}
+	for ind := 0; ind < len(Alphabet); ind++ {
+		alphabetIndex[Alphabet[ind]] = int8(ind)
 	}
 }
 
-func Encode(num uint64) string {
-	if num == 0{
-		return string(Alphabet[0])
-	}
+// maxEncodedLen is the length of math.MaxUint64 in base62, 62^10 < 2^64 < 62^11
+const maxEncodedLen = 11
 
-	var result strings.Builder
-	result.Grow(int(math.Log(float64(num))/math.Log(Base)) + 1)
-	for num > 0 {
-		reminder:= num % Base
-		result.WriteByte(Alphabet[reminder])
+func Encode(num uint64) string {
+	// Digits are written from the end of a fixed buffer, least significant
+	// first, so no capacity has to be estimated and nothing needs reversing;
+	// the returned string is the only allocation
+	var buf [maxEncodedLen]byte
+	i := len(buf)
+	for {
+		i--
+		buf[i] = Alphabet[num%Base]
 		num /= Base
+		if num == 0 {
+			break
+		}
 	}
-	return reverse(result.String())
+	return string(buf[i:])
 }
 
 func EncodePadded(num uint64, minLength int) string {
@@ -48,30 +64,19 @@ func EncodePadded(num uint64, minLength int) string {
 
 func Decode(str string) (uint64, error){
 	if len(str) == 0 {
-		return 0, errors.New("empty string")
+		return 0, errEmpty
 	}
 
 	var results uint64
-	for _, char := range str {
-		_ , exists := alphabetIndex[char]
-		if !exists{
-			return 0, errors.New("invalid character in base62 string")
+	for i := 0; i < len(str); i++ {
+		index := alphabetIndex[str[i]]
+		if index < 0 {
+			return 0, errInvalidChar
 		}
 
-		results = results*Base+uint64(Base)
+		results = results*Base + uint64(index)
 	}
 
 	return results, nil
 
 }
-
-func reverse(str string)string {
-	runes:= []rune(str)
-	for i, j := 0, len(runes)-1;i<j;i, j = i+1, j-1{
-		runes[i] , runes[j] = runes[j] , runes[i]
-	}
-
-	return string(runes)
-}
-
-
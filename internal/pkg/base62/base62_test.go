@@ -1 +1,90 @@
 package base62
+
+import (
+	"math"
+	"testing"
+)
+
+// Decode used to add the base instead of the digit's value, so every
+// decode came out wrong
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, n := range []uint64{0, 1, 61, 62, 3843, 3844, 1 << 40, math.MaxUint64} {
+		code := Encode(n)
+		got, err := Decode(code)
+		if err != nil {
+			t.Fatalf("Decode(%q) error = %v", code, err)
+		}
+		if got != n {
+			t.Errorf("Decode(Encode(%d)) = %d (code %q)", n, got, code)
+		}
+	}
+	if got, _ := Decode("10"); got != 62 {
+		t.Errorf(`Decode("10") = %d, want 62`, got)
+	}
+}
+
+func TestDecodeRejectsInvalidInput(t *testing.T) {
+	for _, bad := range []string{"", "abc-12", "héllo"} {
+		if _, err := Decode(bad); err == nil {
+			t.Errorf("Decode(%q) error = nil, want an error", bad)
+		}
+	}
+}
+
+// Encode and Decode run for every generated code; these guard against an
+// innocent-looking change adding allocations to them
+func TestEncodeDecodeAllocations(t *testing.T) {
+	// Only the returned string, for every size of input
+	for _, num := range []uint64{0, 61, 1 << 40, math.MaxUint64} {
+		if n := testing.AllocsPerRun(100, func() { _ = Encode(num) }); n > 1 {
+			t.Errorf("Encode(%d) allocations = %v, want at most 1", num, n)
+		}
+	}
+	if n := testing.AllocsPerRun(100, func() { _, _ = Decode("Zx9aB3kQ") }); n != 0 {
+		t.Errorf("Decode allocations = %v, want 0", n)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = Encode(uint64(i) + 1<<40)
+	}
+}
+
+func BenchmarkEncodePadded(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = EncodePadded(uint64(i), 8)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode("Zx9aB3kQ"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEncodeEdgeValues(t *testing.T) {
+	tests := []struct {
+		num  uint64
+		want string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{61, "z"},
+		{62, "10"},
+		{math.MaxUint64, "LygHa16AHYF"},
+	}
+	for _, tt := range tests {
+		if got := Encode(tt.num); got != tt.want {
+			t.Errorf("Encode(%d) = %q, want %q", tt.num, got, tt.want)
+		}
+	}
+	if got := len(Encode(math.MaxUint64)); got != maxEncodedLen {
+		t.Errorf("len(Encode(MaxUint64)) = %d, want maxEncodedLen %d", got, maxEncodedLen)
+	}
+}
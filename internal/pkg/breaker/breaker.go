@@ -0,0 +1,62 @@
+package breaker
+
+import (
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// Config controls when a circuit breaker trips and how long it stays open
+type Config struct {
+	// MaxFailures is the number of consecutive failures that opens the breaker
+	MaxFailures uint32
+	// OpenTimeout is how long the breaker stays open before half-opening to probe
+	OpenTimeout time.Duration
+	// HalfOpenRequests is how many probe requests are let through while half-open
+	HalfOpenRequests uint32
+}
+
+// New creates a circuit breaker that logs every state transition and
+// publishes its current state to the circuit_breaker_state gauge
+//
+// isSuccessful decides which errors count against the breaker. Expected
+// errors like a cache miss or "not found" must not trip it, otherwise a
+// burst of lookups for unknown codes would take a healthy backend offline.
+func New(name string, cfg Config, logger *zap.Logger, m *metrics.Metrics, isSuccessful func(err error) bool) *gobreaker.CircuitBreaker {
+	if cfg.MaxFailures == 0 {
+		cfg.MaxFailures = 5
+	}
+	if cfg.OpenTimeout == 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenRequests == 0 {
+		cfg.HalfOpenRequests = 1
+	}
+
+	m.CircuitBreakerState.WithLabelValues(name).Set(float64(gobreaker.StateClosed))
+
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        name,
+		MaxRequests: cfg.HalfOpenRequests,
+		Timeout:     cfg.OpenTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= cfg.MaxFailures
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			logger.Warn("circuit breaker state changed",
+				zap.String("breaker", name),
+				zap.String("from", from.String()),
+				zap.String("to", to.String()),
+			)
+			m.CircuitBreakerState.WithLabelValues(name).Set(float64(to))
+		},
+		IsSuccessful: isSuccessful,
+	})
+}
+
+// IsOpen reports whether err was returned because the breaker rejected the call
+func IsOpen(err error) bool {
+	return err == gobreaker.ErrOpenState || err == gobreaker.ErrTooManyRequests
+}
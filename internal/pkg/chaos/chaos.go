@@ -0,0 +1,30 @@
+// Package chaos injects latency and errors into the cache and database
+// layers, for rehearsing breakers, timeouts and fallbacks in staging.
+//
+// Learning: the injection itself is only compiled into binaries built with
+// -tags chaos. In any other build Inject is an empty function the compiler
+// drops, so CHAOS_* settings that leak into production do nothing.
+package chaos
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInjected is the error a Fault returns in place of the real call
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Fault is what to do to each call into one layer
+// The zero value is no fault, even in a chaos build.
+type Fault struct {
+	// Latency is added before every call
+	Latency time.Duration
+
+	// ErrorRate is the fraction of calls, 0 to 1, failed with ErrInjected
+	ErrorRate float64
+}
+
+// Configured reports whether f would do anything in a chaos build
+func (f Fault) Configured() bool {
+	return f.Latency > 0 || f.ErrorRate > 0
+}
@@ -0,0 +1,34 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Enabled reports whether this binary was built with fault injection
+const Enabled = true
+
+// Inject waits out f.Latency, then fails the call with ErrInjected at
+// f.ErrorRate. A ctx done during the wait ends it with ctx.Err(), so the
+// injected latency runs into the caller's deadline like a slow server would. Callers run it inside their circuit breaker, so injected
+// errors count against it like real ones.
+// Use case: CHAOS_CACHE_ERROR_RATE=1 shows whether redirects survive Redis
+// going away; CHAOS_DB_LATENCY_MS shows what a slow primary does to p99.
+func (f Fault) Inject(ctx context.Context) error {
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if f.ErrorRate > 0 && rand.Float64() < f.ErrorRate {
+		return ErrInjected
+	}
+	return nil
+}
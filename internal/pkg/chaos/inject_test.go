@@ -0,0 +1,43 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInjectFailsAtTheErrorRate(t *testing.T) {
+	if err := (Fault{ErrorRate: 1}).Inject(context.Background()); !errors.Is(err, ErrInjected) {
+		t.Errorf("Inject() at rate 1 error = %v, want ErrInjected", err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := (Fault{}).Inject(context.Background()); err != nil {
+			t.Fatalf("Inject() of the zero Fault error = %v", err)
+		}
+	}
+}
+
+func TestInjectAddsLatency(t *testing.T) {
+	start := time.Now()
+	if err := (Fault{Latency: 20 * time.Millisecond}).Inject(context.Background()); err != nil {
+		t.Fatalf("Inject() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Inject() took %v, want at least 20ms", elapsed)
+	}
+}
+
+func TestInjectLatencyStopsAtTheDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := (Fault{Latency: time.Second}).Inject(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Inject() error = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Inject() took %v, want it to stop at the 10ms deadline", elapsed)
+	}
+}
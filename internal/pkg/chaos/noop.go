@@ -0,0 +1,13 @@
+//go:build !chaos
+
+package chaos
+
+import "context"
+
+// Enabled reports whether this binary was built with fault injection
+const Enabled = false
+
+// Inject does nothing: this binary was built without -tags chaos
+func (f Fault) Inject(ctx context.Context) error {
+	return nil
+}
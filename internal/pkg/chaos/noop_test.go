@@ -0,0 +1,20 @@
+//go:build !chaos
+
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInjectIsANoopWithoutTheBuildTag(t *testing.T) {
+	f := Fault{Latency: time.Second, ErrorRate: 1}
+	start := time.Now()
+	if err := f.Inject(context.Background()); err != nil {
+		t.Errorf("Inject() error = %v, want nil in a build without -tags chaos", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Inject() took %v, want no added latency", elapsed)
+	}
+}
@@ -0,0 +1,25 @@
+package geo
+
+import "golang.org/x/text/language"
+
+// CountryFromAcceptLanguage infers an ISO 3166-1 alpha-2 country code from an
+// Accept-Language header, e.g. "pt-BR,pt;q=0.9" -> "BR"
+//
+// Only explicit region subtags count: a bare "en" says nothing about where
+// the visitor is, so it yields "". Entries are tried in preference (q) order.
+// This is a hint about the browser's locale, not a location - callers must
+// mark the result as inferred.
+func CountryFromAcceptLanguage(header string) string {
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return ""
+	}
+
+	for _, tag := range tags {
+		region, confidence := tag.Region()
+		if confidence == language.Exact && region.IsCountry() {
+			return region.String()
+		}
+	}
+	return ""
+}
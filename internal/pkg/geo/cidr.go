@@ -0,0 +1,83 @@
+package geo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// CIDRTable is a CountryLookup over a list of "cidr,country" lines, e.g.
+//
+//	# office and partner ranges
+//	203.0.113.0/24,DE
+//	2001:db8::/32,FR
+//
+// Learning: a full GeoIP database is a binary format with its own reader;
+// a plain table covers known networks and tests without a dependency, and
+// anything else satisfying CountryLookup can replace it.
+type CIDRTable struct {
+	entries []cidrEntry
+}
+
+type cidrEntry struct {
+	prefix  netip.Prefix
+	country string
+}
+
+// LoadCIDRTable reads a CIDRTable from the file at path
+func LoadCIDRTable(path string) (*CIDRTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseCIDRTable(f)
+}
+
+// ParseCIDRTable reads "cidr,country" lines; blank lines and # comments are skipped
+func ParseCIDRTable(r io.Reader) (*CIDRTable, error) {
+	table := &CIDRTable{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		cidr, country, ok := strings.Cut(text, ",")
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if !ok || len(country) != 2 {
+			return nil, fmt.Errorf("line %d: want \"cidr,country\", got %q", line, text)
+		}
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		table.entries = append(table.entries, cidrEntry{prefix: prefix.Masked(), country: country})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Country returns the country of the most specific range containing ip, ""
+// when none does
+func (t *CIDRTable) Country(ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", err
+	}
+	addr = addr.Unmap()
+
+	country, bits := "", -1
+	for _, entry := range t.entries {
+		if entry.prefix.Bits() > bits && entry.prefix.Contains(addr) {
+			country, bits = entry.country, entry.prefix.Bits()
+		}
+	}
+	return country, nil
+}
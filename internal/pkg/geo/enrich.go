@@ -0,0 +1,52 @@
+package geo
+
+import (
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// CountryLookup resolves an IP address to a country code, e.g. a GeoIP database
+type CountryLookup interface {
+	Country(ip string) (string, error)
+}
+
+type EnricherConfig struct {
+	// InferFromAcceptLanguage fills the country from Accept-Language when the
+	// IP lookup is unavailable or has no answer
+	InferFromAcceptLanguage bool
+}
+
+// Enricher fills the geographic fields of click events
+type Enricher struct {
+	lookup CountryLookup
+	cfg    EnricherConfig
+}
+
+// NewEnricher builds an Enricher; lookup may be nil when no GeoIP database is configured
+func NewEnricher(lookup CountryLookup, cfg EnricherConfig) *Enricher {
+	return &Enricher{lookup: lookup, cfg: cfg}
+}
+
+var _ domain.CountryResolver = (*Enricher)(nil)
+
+// Enrich sets Country and CountrySource on event
+// A lookup error is not fatal: the click is still worth recording without a country
+func (e *Enricher) Enrich(event *domain.ClickEvent, acceptLanguage string) {
+	event.Country, event.CountrySource = e.Resolve(event.IPAddress, acceptLanguage)
+}
+
+// Resolve places a visitor the way Enrich does, returning the country and
+// its CountrySource, or two empty strings
+func (e *Enricher) Resolve(ip, acceptLanguage string) (country, source string) {
+	if e.lookup != nil {
+		if country, err := e.lookup.Country(ip); err == nil && country != "" {
+			return country, domain.CountrySourceGeoIP
+		}
+	}
+
+	if e.cfg.InferFromAcceptLanguage {
+		if country := CountryFromAcceptLanguage(acceptLanguage); country != "" {
+			return country, domain.CountrySourceAcceptLanguage
+		}
+	}
+	return "", ""
+}
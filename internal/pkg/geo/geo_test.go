@@ -0,0 +1,97 @@
+package geo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+func TestCountryFromAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"en-US", "US"},
+		{"fr-FR,fr;q=0.9,en;q=0.8", "FR"},
+		{"pt-BR", "BR"},
+		{"en;q=0.5, de-AT;q=0.9", "AT"}, // highest q wins, not first listed
+		{"en", ""},                      // no region subtag
+		{"es-419", ""},                  // Latin America is not a country
+		{"", ""},
+		{"*", ""},
+		{"not a language!!", ""},
+	}
+
+	for _, tt := range tests {
+		if got := CountryFromAcceptLanguage(tt.header); got != tt.want {
+			t.Errorf("CountryFromAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+type stubLookup struct {
+	country string
+	err     error
+}
+
+func (s stubLookup) Country(ip string) (string, error) { return s.country, s.err }
+
+func TestEnricherMarksInferredCountries(t *testing.T) {
+	tests := []struct {
+		name       string
+		lookup     CountryLookup
+		infer      bool
+		wantCode   string
+		wantSource string
+	}{
+		{"geoip wins", stubLookup{country: "DE"}, true, "DE", domain.CountrySourceGeoIP},
+		{"no geoip", nil, true, "BR", domain.CountrySourceAcceptLanguage},
+		{"geoip error", stubLookup{err: errors.New("db closed")}, true, "BR", domain.CountrySourceAcceptLanguage},
+		{"inference disabled", nil, false, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &domain.ClickEvent{IPAddress: "203.0.113.7"}
+			NewEnricher(tt.lookup, EnricherConfig{InferFromAcceptLanguage: tt.infer}).Enrich(event, "pt-BR,pt;q=0.9")
+
+			if event.Country != tt.wantCode || event.CountrySource != tt.wantSource {
+				t.Errorf("got (%q, %q), want (%q, %q)", event.Country, event.CountrySource, tt.wantCode, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestCIDRTableLongestPrefixWins(t *testing.T) {
+	table, err := ParseCIDRTable(strings.NewReader(`
+# a comment
+203.0.113.0/24, de
+203.0.113.128/25,AT
+2001:db8::/32,FR
+`))
+	if err != nil {
+		t.Fatalf("ParseCIDRTable() error = %v", err)
+	}
+
+	tests := map[string]string{
+		"203.0.113.7":        "DE",
+		"203.0.113.200":      "AT",
+		"::ffff:203.0.113.7": "DE",
+		"2001:db8::1":        "FR",
+		"198.51.100.1":       "",
+	}
+	for ip, want := range tests {
+		if got, err := table.Country(ip); err != nil || got != want {
+			t.Errorf("Country(%q) = %q, %v; want %q", ip, got, err, want)
+		}
+	}
+	if _, err := table.Country("not an ip"); err == nil {
+		t.Error(`Country("not an ip") returned no error`)
+	}
+
+	if _, err := ParseCIDRTable(strings.NewReader("203.0.113.0/24")); err == nil {
+		t.Error("a line without a country was accepted")
+	}
+}
@@ -0,0 +1,99 @@
+package keygen
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultRefillRetryDelay is how long the producer backs off after the source
+// fails (e.g. ErrClockStalled) before trying again
+const DefaultRefillRetryDelay = 10 * time.Millisecond
+
+// BufferConfig sizes a BufferedGenerator
+type BufferConfig struct {
+	// Size is how many codes are kept ready
+	Size int
+	// RetryDelay is the producer's back-off after a source error,
+	// DefaultRefillRetryDelay if zero
+	RetryDelay time.Duration
+}
+
+// BufferedGenerator hands out codes a background producer generated ahead of
+// time, so a burst is a channel receive instead of a wait on the generator lock
+//
+// Use case: past 4096 IDs in one millisecond the snowflake generator sleeps
+// for the next millisecond while holding its lock, and every concurrent create
+// queues behind it. With a buffer the burst drains codes made while traffic
+// was quiet; only once it's empty do creates fall back to the source directly.
+//
+// Learning: uniqueness is the source's guarantee and is kept, every code
+// comes from the one source exactly once. Ordering is not: a buffered code was
+// made up to Size codes earlier, and fallback codes can overtake buffered
+// ones, so codes are not handed out in creation order and a snowflake code's
+// timestamp may predate its link. Codes left in the buffer at shutdown are
+// never used, which only leaves gaps.
+type BufferedGenerator struct {
+	source     Generator
+	codes      chan string
+	retryDelay time.Duration
+}
+
+var _ Generator = (*BufferedGenerator)(nil)
+
+// NewBufferedGenerator buffers source's default-length codes
+// The buffer stays empty (every call goes to source) until Run is started.
+func NewBufferedGenerator(source Generator, cfg BufferConfig) *BufferedGenerator {
+	if cfg.Size <= 0 {
+		cfg.Size = 4096
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = DefaultRefillRetryDelay
+	}
+	return &BufferedGenerator{
+		source:     source,
+		codes:      make(chan string, cfg.Size),
+		retryDelay: cfg.RetryDelay,
+	}
+}
+
+// Run keeps the buffer full until ctx is cancelled
+func (b *BufferedGenerator) Run(ctx context.Context) {
+	for {
+		code, err := b.source.Generate(ctx, 0)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(b.retryDelay):
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case b.codes <- code:
+		}
+	}
+}
+
+// Generate takes a buffered code, or asks the source when the buffer is empty
+// or a specific length is requested (only default-length codes are buffered)
+func (b *BufferedGenerator) Generate(ctx context.Context, length int) (string, error) {
+	if length == 0 {
+		select {
+		case code := <-b.codes:
+			return code, nil
+		default:
+		}
+	}
+	return b.source.Generate(ctx, length)
+}
+
+// Buffered reports how many codes are ready
+func (b *BufferedGenerator) Buffered() int {
+	return len(b.codes)
+}
@@ -0,0 +1,132 @@
+package keygen
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newBufferedSnowflake(t testing.TB, size int) *BufferedGenerator {
+	t.Helper()
+	g, err := NewSnowflakeGenerator(Config{MachineID: 1, MinLength: 6})
+	if err != nil {
+		t.Fatalf("NewSnowflakeGenerator() returned error: %v", err)
+	}
+	return NewBufferedGenerator(g, BufferConfig{Size: size})
+}
+
+// waitFull blocks until the producer has filled the buffer
+func waitFull(t testing.TB, b *BufferedGenerator) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Buffered() < cap(b.codes) {
+		if time.Now().After(deadline) {
+			t.Fatalf("buffer holds %d of %d codes", b.Buffered(), cap(b.codes))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBufferedGeneratorCodesAreUnique(t *testing.T) {
+	b := newBufferedSnowflake(t, 256)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+	waitFull(t, b)
+
+	// More codes than the buffer holds, so buffered and fallback codes mix
+	const workers, perWorker = 8, 500
+	var mu sync.Mutex
+	seen := make(map[string]bool, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				code, err := b.Generate(ctx, 0)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[code] {
+					t.Errorf("code %q issued twice", code)
+				}
+				seen[code] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBufferedGeneratorFallsBackToSource(t *testing.T) {
+	// Not running: every call must go to the source
+	b := newBufferedSnowflake(t, 4)
+	if code, err := b.Generate(context.Background(), 0); err != nil || code == "" {
+		t.Fatalf("Generate() on an empty buffer = %q, %v", code, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go b.Run(ctx)
+	waitFull(t, b)
+
+	// A requested length isn't buffered and leaves the buffer alone
+	code, err := b.Generate(ctx, 16)
+	if err != nil || len(code) != 16 {
+		t.Fatalf("Generate(16) = %q, %v", code, err)
+	}
+	if b.Buffered() != 4 {
+		t.Errorf("buffer holds %d codes after a sized request, want 4", b.Buffered())
+	}
+
+	cancel()
+	for i := 0; i < 6; i++ {
+		if _, err := b.Generate(context.Background(), 0); err != nil {
+			t.Fatalf("Generate() #%d after the producer stopped: %v", i, err)
+		}
+	}
+}
+
+// Bursts past 4096 IDs/ms make the direct generator wait out the millisecond
+// under its lock; buffered generation only pays that wait once the buffer
+// (refilled in the background) runs dry
+func BenchmarkBufferedVsDirectGenerate(b *testing.B) {
+	ctx := context.Background()
+
+	b.Run("direct", func(b *testing.B) {
+		g, err := NewSnowflakeGenerator(Config{MachineID: 1, MinLength: 6})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := g.Generate(ctx, 0); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+	b.Run("buffered", func(b *testing.B) {
+		g := newBufferedSnowflake(b, 1<<16)
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go g.Run(runCtx)
+		waitFull(b, g)
+
+		b.ResetTimer()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := g.Generate(ctx, 0); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,22 @@
+package keygen
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrLengthUnavailable is returned when a generator can't produce a code of
+// the requested length, e.g. a snowflake ID that already encodes longer
+var ErrLengthUnavailable = errors.New("keygen: requested code length is not available")
+
+// Generator produces new short codes
+// The service only depends on this interface, so generators can be swapped
+// by config and tests can inject a deterministic stub
+type Generator interface {
+	// Generate returns a new code; ctx bounds any wait inside the generator
+	// A length of 0 means the generator's configured default
+	Generate(ctx context.Context, length int) (string, error)
+}
+
+// Compile-time check that the snowflake generator satisfies Generator
+var _ Generator = (*SnowFlakeGenerator)(nil)
@@ -0,0 +1,81 @@
+package keygen
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/subhammahanty235/url-shortener/internal/pkg/base62"
+)
+
+// lowercaseAlphabet is the base36 alphabet lowercase snowflake codes use
+const lowercaseAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// RandomGenerator produces cryptographically random base62 codes
+// Unlike snowflake codes they reveal nothing about creation order or volume,
+// so recent links can't be found by walking the keyspace. Collisions are
+// possible (if rare) and are retried by the caller against the database.
+type RandomGenerator struct {
+	length   int
+	alphabet string
+	// maxUnbiasedByte is the largest multiple of len(alphabet) that fits in
+	// a byte (248 for base62). Bytes at or above it are rejected so every
+	// character is equally likely; a plain b%62 would favour the first 8
+	// characters of the alphabet
+	maxUnbiasedByte int
+	rand            io.Reader
+}
+
+func NewRandomGenerator(length int) (*RandomGenerator, error) {
+	return newRandomGenerator(length, base62.Alphabet)
+}
+
+// NewLowercaseRandomGenerator is NewRandomGenerator over digits and
+// lowercase letters only, for case-insensitive codes
+func NewLowercaseRandomGenerator(length int) (*RandomGenerator, error) {
+	return newRandomGenerator(length, lowercaseAlphabet)
+}
+
+func newRandomGenerator(length int, alphabet string) (*RandomGenerator, error) {
+	if length <= 0 {
+		return nil, errors.New("random code length must be positive")
+	}
+
+	return &RandomGenerator{
+		length:          length,
+		alphabet:        alphabet,
+		maxUnbiasedByte: 256 - 256%len(alphabet),
+		rand:            rand.Reader,
+	}, nil
+}
+
+var _ Generator = (*RandomGenerator)(nil)
+
+// Generate never blocks, ctx is accepted to satisfy Generator
+func (g *RandomGenerator) Generate(ctx context.Context, length int) (string, error) {
+	if length <= 0 {
+		length = g.length
+	}
+
+	code := make([]byte, 0, length)
+	// Over-read so one call usually covers the rejected bytes too
+	buf := make([]byte, length+length/2)
+
+	for len(code) < length {
+		if _, err := io.ReadFull(g.rand, buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= g.maxUnbiasedByte {
+				continue
+			}
+			code = append(code, g.alphabet[int(b)%len(g.alphabet)])
+			if len(code) == length {
+				break
+			}
+		}
+	}
+
+	return string(code), nil
+}
@@ -0,0 +1,54 @@
+package keygen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	s := NewSigner([]byte("test-key"))
+	signed := s.Sign("abc123")
+
+	code, ok := s.Verify(signed)
+	if !ok || code != "abc123" {
+		t.Fatalf("Verify(%q) = %q, %v; want abc123, true", signed, code, ok)
+	}
+}
+
+func TestSignerRejectsTampering(t *testing.T) {
+	s := NewSigner([]byte("test-key"))
+	signed := s.Sign("abc123")
+	_, tag, _ := SplitSigned(signed)
+
+	tests := map[string]string{
+		"edited code":   "abc124" + SignatureSeparator + tag,
+		"edited tag":    "abc123" + SignatureSeparator + "AAAAAAAA",
+		"missing tag":   "abc123",
+		"empty tag":     "abc123" + SignatureSeparator,
+		"other key tag": NewSigner([]byte("other-key")).Sign("abc123"),
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, ok := s.Verify(input); ok {
+				t.Errorf("Verify(%q) accepted a tampered code", input)
+			}
+		})
+	}
+}
+
+func TestValidShortCode(t *testing.T) {
+	signed := NewSigner([]byte("test-key")).Sign("abc123")
+	valid := []string{"abc123", "my-alias_2", "0", signed, strings.Repeat("z", MaxShortCodeLength)}
+	invalid := []string{"", "ab$cd", "abc/def", "abc 123", "abc.", ".AAAAAAAA", "abc.AAAA", "abc.AAAAAAAA.AAAAAAAA", "abc.AAAA+AAA", strings.Repeat("z", MaxShortCodeLength+1), "héllo"}
+
+	for _, code := range valid {
+		if !ValidShortCode(code) {
+			t.Errorf("ValidShortCode(%q) = false, want true", code)
+		}
+	}
+	for _, code := range invalid {
+		if ValidShortCode(code) {
+			t.Errorf("ValidShortCode(%q) = true, want false", code)
+		}
+	}
+}
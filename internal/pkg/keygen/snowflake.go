@@ -1,8 +1,12 @@
 package keygen
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,6 +26,19 @@ const (
 	MachineIDShift = SequenceBits
 )
 
+// DefaultMaxClockWait bounds how long Generate waits for the clock to move
+// forward. The wait holds the generator lock, so it must stay short.
+const DefaultMaxClockWait = 50 * time.Millisecond
+
+// ErrClockStalled is returned when the clock doesn't move past the last issued
+// timestamp within the configured wait, e.g. after a large backwards NTP step
+var ErrClockStalled = errors.New("keygen: clock did not advance in time")
+
+// ErrMaxLengthTooShort is returned by NewSnowflakeGenerator when today's IDs
+// already encode longer than MaxLength, e.g. 12 character base36 codes
+// against the default maximum of 10
+var ErrMaxLengthTooShort = errors.New("keygen: snowflake codes are longer than the maximum length")
+
 type SnowFlakeGenerator struct {
 	mu            sync.Mutex
 	machineID     int64
@@ -29,13 +46,22 @@ type SnowFlakeGenerator struct {
 	lastTimestamp int64
 	minLength     int
 	maxLength     int
+	lowercase     bool
 	customPattern *regexp.Regexp
+
+	maxClockWait time.Duration
+	now          func() time.Time // swapped in tests to simulate clock problems
 }
 
 type Config struct {
 	MachineID int64
 	MinLength int
 	MaxLength int
+	// Lowercase restricts codes to [0-9a-z] (base36) so they survive
+	// case-insensitive lookups without two codes colliding
+	Lowercase bool
+	// MaxClockWait caps the wait for the next millisecond, DefaultMaxClockWait if zero
+	MaxClockWait time.Duration
 }
 
 func NewSnowflakeGenerator(cfg Config) (*SnowFlakeGenerator, error) {
@@ -49,6 +75,17 @@ func NewSnowflakeGenerator(cfg Config) (*SnowFlakeGenerator, error) {
 	if cfg.MaxLength == 0 {
 		cfg.MaxLength = 10
 	}
+	if cfg.MaxClockWait <= 0 {
+		cfg.MaxClockWait = DefaultMaxClockWait
+	}
+	// The longest ID this millisecond can produce: IDs only grow, so codes
+	// that fit now can't be made to fit later by padding or truncating
+	g := &SnowFlakeGenerator{lowercase: cfg.Lowercase}
+	newest := ((time.Now().UnixMilli() - EPoch) << TimestampShift) | (MaxMachineID << MachineIDShift) | MaxSequence
+	if n := len(g.encode(newest, 0)); n > cfg.MaxLength {
+		return nil, fmt.Errorf("%w: %d characters, max %d", ErrMaxLengthTooShort, n, cfg.MaxLength)
+	}
+
 	pattern := regexp.MustCompile(`^[a-zA-Z0-9]{` + string(rune('0'+cfg.MinLength)) + `,` + string(rune('0'+cfg.MaxLength)) + `}$`)
 	return &SnowFlakeGenerator{
 		machineID:     cfg.MachineID,
@@ -56,43 +93,99 @@ func NewSnowflakeGenerator(cfg Config) (*SnowFlakeGenerator, error) {
 		lastTimestamp: -1,
 		minLength:     cfg.MinLength,
 		maxLength:     cfg.MaxLength,
+		lowercase:     cfg.Lowercase,
 		customPattern: pattern,
+		maxClockWait:  cfg.MaxClockWait,
+		now:           time.Now,
 	}, nil
 }
 
-func (g *SnowFlakeGenerator) Generate() (string, error) {
+// Generate pads the code to length (MinLength if 0). Snowflake IDs can't be
+// shortened, so a length below the ID's natural encoding fails with
+// ErrLengthUnavailable.
+func (g *SnowFlakeGenerator) Generate(ctx context.Context, length int) (string, error) {
+	padTo := g.minLength
+	if length > 0 {
+		padTo = length
+	}
+
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
 	timestamp := g.currentTimestamp()
+	sequence := g.sequence
 	if timestamp < g.lastTimestamp {
-		g.sequence = (g.sequence + 1) & MaxSequence
-		if g.sequence == 0 {
-			timestamp = g.waitNextMillis(g.lastTimestamp)
-		} else {
-			g.sequence = 0
+		// Clock moved backwards, wait until we are past the last issued timestamp
+		next, err := g.waitNextMillis(ctx, g.lastTimestamp)
+		if err != nil {
+			return "", err
 		}
+		timestamp = next
+		sequence = 0
+	} else if timestamp == g.lastTimestamp {
+		// Same millisecond as the last ID: only the sequence can tell them
+		// apart, so it must step rather than start over at 0
+		sequence = (sequence + 1) & MaxSequence
+		if sequence == 0 {
+			// Sequence exhausted for this millisecond
+			next, err := g.waitNextMillis(ctx, g.lastTimestamp)
+			if err != nil {
+				return "", err
+			}
+			timestamp = next
+		}
+	} else {
+		sequence = 0
 	}
 
+	// State is only committed once an ID is certain, so a failed wait
+	// can't cause a later call to reissue the same sequence number
+	g.sequence = sequence
 	g.lastTimestamp = timestamp
 	id := ((timestamp - EPoch) << TimestampShift) |
 		(g.machineID << MachineIDShift) |
 		g.sequence
 
-	shortCode := base62.EncodePadded(uint64(id), g.minLength)
+	shortCode := g.encode(id, padTo)
+	if length > 0 && len(shortCode) > length {
+		return "", ErrLengthUnavailable
+	}
 	return shortCode, nil
+}
 
+// encode is id in base62, or base36 for lowercase codes, left-padded with
+// zeros to padTo
+func (g *SnowFlakeGenerator) encode(id int64, padTo int) string {
+	if !g.lowercase {
+		return base62.EncodePadded(uint64(id), padTo)
+	}
+	code := strconv.FormatUint(uint64(id), 36)
+	if len(code) < padTo {
+		code = strings.Repeat("0", padTo-len(code)) + code
+	}
+	return code
 }
 
 func (g *SnowFlakeGenerator) currentTimestamp() int64 {
-	return time.Now().UnixNano() / int64(time.Millisecond)
+	return g.now().UnixNano() / int64(time.Millisecond)
 }
 
-func (g *SnowFlakeGenerator) waitNextMillis(lastTimestamp int64) int64 {
+// waitNextMillis spins until the clock passes lastTimestamp
+// The deadline is measured on the monotonic clock, so it still fires when the
+// wall clock is stuck or jumping around
+func (g *SnowFlakeGenerator) waitNextMillis(ctx context.Context, lastTimestamp int64) (int64, error) {
+	deadline := time.Now().Add(g.maxClockWait)
+
 	timestamp := g.currentTimestamp()
 	for timestamp <= lastTimestamp {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if time.Now().After(deadline) {
+			return 0, ErrClockStalled
+		}
 		time.Sleep(100 * time.Microsecond)
 		timestamp = g.currentTimestamp()
 	}
-	return timestamp
+	return timestamp, nil
 }
@@ -0,0 +1,255 @@
+package keygen
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/pkg/base62"
+)
+
+func TestGenerateLowercaseUsesBase36(t *testing.T) {
+	g, err := NewSnowflakeGenerator(Config{MachineID: 7, MinLength: 6, MaxLength: 13, Lowercase: true})
+	if err != nil {
+		t.Fatalf("NewSnowflakeGenerator() returned error: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		code, err := g.Generate(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("Generate() returned error: %v", err)
+		}
+		if code != strings.ToLower(code) {
+			t.Fatalf("Generate() = %q, want only lowercase characters", code)
+		}
+		if len(code) < 6 {
+			t.Fatalf("Generate() = %q, shorter than MinLength", code)
+		}
+	}
+}
+
+func TestGenerateIsUniqueWithinOneMillisecond(t *testing.T) {
+	g, err := NewSnowflakeGenerator(Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("NewSnowflakeGenerator() returned error: %v", err)
+	}
+
+	// Consecutive calls mostly land in the same millisecond, where only the
+	// sequence tells the IDs apart
+	seen := make(map[string]bool, MaxSequence+1)
+	for i := 0; i <= MaxSequence; i++ {
+		code, err := g.Generate(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("Generate() #%d returned error: %v", i, err)
+		}
+		if seen[code] {
+			t.Fatalf("Generate() #%d reissued %q", i, code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestRandomGenerator(t *testing.T) {
+	g, err := NewRandomGenerator(8)
+	if err != nil {
+		t.Fatalf("NewRandomGenerator() returned error: %v", err)
+	}
+
+	const samples = 100000
+	seen := make(map[string]struct{}, samples)
+	for i := 0; i < samples; i++ {
+		code, err := g.Generate(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("Generate() returned error: %v", err)
+		}
+		if len(code) != 8 {
+			t.Fatalf("Generate() = %q, want length 8", code)
+		}
+		for _, c := range code {
+			if !strings.ContainsRune(base62.Alphabet, c) {
+				t.Fatalf("Generate() = %q, contains non-base62 character %q", code, c)
+			}
+		}
+		if _, dup := seen[code]; dup {
+			t.Fatalf("Generate() produced duplicate %q after %d samples", code, i)
+		}
+		seen[code] = struct{}{}
+	}
+
+	if _, err := NewRandomGenerator(0); err == nil {
+		t.Error("NewRandomGenerator(0) returned nil error")
+	}
+}
+
+func TestLowercaseRandomGenerator(t *testing.T) {
+	g, err := NewLowercaseRandomGenerator(6)
+	if err != nil {
+		t.Fatalf("NewLowercaseRandomGenerator() returned error: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		code, err := g.Generate(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("Generate() returned error: %v", err)
+		}
+		if len(code) != 6 || strings.Trim(code, lowercaseAlphabet) != "" {
+			t.Fatalf("Generate() = %q, want 6 digits or lowercase letters", code)
+		}
+	}
+}
+
+func TestGenerateAbortsWhenClockIsStuck(t *testing.T) {
+	g, err := NewSnowflakeGenerator(Config{MachineID: 1, MaxClockWait: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewSnowflakeGenerator() returned error: %v", err)
+	}
+	frozen := time.Now()
+	g.now = func() time.Time { return frozen }
+
+	// Exhaust every sequence number for the frozen millisecond
+	seen := make(map[string]bool)
+	for i := 0; i <= MaxSequence; i++ {
+		code, err := g.Generate(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("Generate() #%d returned error: %v", i, err)
+		}
+		seen[code] = true
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := g.Generate(context.Background(), 0)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrClockStalled) {
+			t.Fatalf("Generate() error = %v, want ErrClockStalled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Generate() hung on a stuck clock")
+	}
+
+	// Once the clock recovers, codes are still unique
+	frozen = frozen.Add(time.Millisecond)
+	code, err := g.Generate(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Generate() after clock recovery returned error: %v", err)
+	}
+	if seen[code] {
+		t.Fatalf("Generate() reissued %q after a failed wait", code)
+	}
+}
+
+func TestGenerateWaitHonoursContext(t *testing.T) {
+	g, err := NewSnowflakeGenerator(Config{MachineID: 1, MaxClockWait: time.Minute})
+	if err != nil {
+		t.Fatalf("NewSnowflakeGenerator() returned error: %v", err)
+	}
+	now := time.Now()
+	g.now = func() time.Time { return now }
+	if _, err := g.Generate(context.Background(), 0); err != nil {
+		t.Fatalf("Generate() returned error: %v", err)
+	}
+
+	// Clock jumps backwards by an hour; only the context can end the wait
+	now = now.Add(-time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := g.Generate(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Generate() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestGenerateRequestedLength(t *testing.T) {
+	random, err := NewRandomGenerator(8)
+	if err != nil {
+		t.Fatalf("NewRandomGenerator() returned error: %v", err)
+	}
+	snowflake, err := NewSnowflakeGenerator(Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("NewSnowflakeGenerator() returned error: %v", err)
+	}
+
+	for _, length := range []int{5, 12, 16} {
+		code, err := random.Generate(context.Background(), length)
+		if err != nil {
+			t.Fatalf("random Generate(%d) returned error: %v", length, err)
+		}
+		if len(code) != length {
+			t.Errorf("random Generate(%d) = %q, want length %d", length, code, length)
+		}
+	}
+
+	code, err := snowflake.Generate(context.Background(), 16)
+	if err != nil {
+		t.Fatalf("snowflake Generate(16) returned error: %v", err)
+	}
+	if len(code) != 16 {
+		t.Errorf("snowflake Generate(16) = %q, want it padded to 16", code)
+	}
+
+	// A snowflake ID can't be squeezed below its natural length
+	if _, err := snowflake.Generate(context.Background(), 3); !errors.Is(err, ErrLengthUnavailable) {
+		t.Errorf("snowflake Generate(3) error = %v, want ErrLengthUnavailable", err)
+	}
+}
+
+func TestGenerateAllocations(t *testing.T) {
+	g, err := NewSnowflakeGenerator(Config{MachineID: 1, MinLength: 6})
+	if err != nil {
+		t.Fatalf("NewSnowflakeGenerator() returned error: %v", err)
+	}
+	ctx := context.Background()
+
+	n := testing.AllocsPerRun(100, func() {
+		if _, err := g.Generate(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if n > 1 {
+		t.Errorf("Generate allocations = %v, want at most 1", n)
+	}
+}
+
+// Throughput tops out at 4096 IDs per millisecond, after which Generate waits
+// for the clock; the parallel run shows how much the mutex costs under contention
+func BenchmarkSnowflakeGenerate(b *testing.B) {
+	g, err := NewSnowflakeGenerator(Config{MachineID: 1, MinLength: 6})
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+
+	b.Run("serial", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := g.Generate(ctx, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := g.Generate(ctx, 0); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
+
+func TestLowercaseCodesMustFitMaxLength(t *testing.T) {
+	// Base36 IDs are 12 characters today, over the default maximum of 10
+	if _, err := NewSnowflakeGenerator(Config{MachineID: 7, Lowercase: true}); !errors.Is(err, ErrMaxLengthTooShort) {
+		t.Errorf("NewSnowflakeGenerator() with the default max length error = %v, want ErrMaxLengthTooShort", err)
+	}
+	if _, err := NewSnowflakeGenerator(Config{MachineID: 7}); err != nil {
+		t.Errorf("NewSnowflakeGenerator() of base62 codes error = %v, want them to fit 10", err)
+	}
+}
@@ -0,0 +1,38 @@
+package keygen
+
+// MaxShortCodeLength bounds any code worth looking up, generated or custom
+// Well above what generators and aliases produce (aliases stop at 20), so
+// rows from older configs still resolve, while a 500-char path is rejected
+// before it costs a cache or database round trip
+const MaxShortCodeLength = 64
+
+// signatureTagLength is the base64 length of a truncated HMAC tag
+const signatureTagLength = (signatureBytes*8 + 5) / 6
+
+// ValidShortCode reports whether code could be a stored short code, optionally
+// followed by a signature tag ("abc123" or "abc123.Xy9_k2Qm")
+// It only checks shape: letters, digits, '-' and '_', the tag being exactly
+// as long as Sign makes it. Whether the code exists is the service's job.
+func ValidShortCode(code string) bool {
+	bare, tag, signed := SplitSigned(code)
+	if signed && len(tag) != signatureTagLength {
+		return false
+	}
+	if len(bare) == 0 || len(bare) > MaxShortCodeLength {
+		return false
+	}
+	return urlSafe(bare) && urlSafe(tag)
+}
+
+// urlSafe is the base64url alphabet, which covers base62 codes and aliases
+func urlSafe(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
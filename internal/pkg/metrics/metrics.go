@@ -13,31 +13,96 @@ type Metrics struct {
 	HTTPRequestDuration *prometheus.HistogramVec // Request latency by endpoint
 	HTTPRequestsActive  prometheus.Gauge         // Currently in-flight requests
 
+	RedirectTTFB prometheus.Histogram // Request receipt to Location header written, redirects only
+
 	// Business Metrics (Domain Layer)
-	URLsCreatedTotal    prometheus.Counter       // Total URLs shortened
-	URLRedirectsTotal   prometheus.Counter       // Total redirects served
-	CustomAliasTotal    prometheus.Counter       // URLs created with custom aliases
-	ExpiredURLsTotal    prometheus.Counter       // Expired URLs encountered
+	URLsCreatedTotal  *prometheus.CounterVec   // URLs shortened by type (custom, generated)
+	URLRedirectsTotal prometheus.Counter       // Total redirects served
+	CustomAliasTotal  prometheus.Counter       // Deprecated: urls_created_total{type="custom"}
+	ExpiredURLsTotal  prometheus.Counter       // Expired URLs encountered
+	ShortCodeLength   *prometheus.HistogramVec // Length of created short codes by type (custom, generated)
+
+	BusinessErrorTotal *prometheus.CounterVec // Requests refused for a domain reason, by reason
+
+	// Security Metrics
+	ScanAttemptsTotal *prometheus.CounterVec // Requests from clients flagged as scanning, by action taken
+
+	RedirectsRateLimitedTotal *prometheus.CounterVec // Redirects refused by the click rate limit, by scope
 
 	// Cache Metrics (Infrastructure Layer)
 	CacheHitsTotal   *prometheus.CounterVec // Cache hits by operation (get, set)
 	CacheMissesTotal *prometheus.CounterVec // Cache misses by operation
 	CacheErrors      *prometheus.CounterVec // Cache errors by operation
 
+	CacheL1HitsTotal   *prometheus.CounterVec // Process-local cache hits by operation
+	CacheL1MissesTotal *prometheus.CounterVec // Process-local cache misses by operation
+
+	CacheHotKeyPromotionsTotal prometheus.Counter // Cache keys replicated across shards for being hot
+	CacheDivergenceTotal       prometheus.Counter // Sampled cache hits that disagreed with the database
+
+	// Redis Pool Metrics (sampled from redis.PoolStats)
+	RedisPoolHits       prometheus.Gauge // Times a free connection was found in the pool
+	RedisPoolMisses     prometheus.Gauge // Times a new connection had to be dialed
+	RedisPoolTimeouts   prometheus.Gauge // Times waiting for a connection timed out
+	RedisPoolTotalConns prometheus.Gauge // Connections currently in the pool
+	RedisPoolIdleConns  prometheus.Gauge // Idle connections in the pool
+	RedisPoolStaleConns prometheus.Gauge // Stale connections removed from the pool
+
 	// Database Metrics (Infrastructure Layer)
-	DBQueryDuration *prometheus.HistogramVec // DB query duration by operation
-	DBConnectionsActive prometheus.Gauge      // Active DB connections from pool
-	DBErrors        *prometheus.CounterVec   // DB errors by operation
+	DBQueryDuration     *prometheus.HistogramVec // DB query duration by operation
+	DBConnectionsActive prometheus.Gauge         // Active DB connections from pool
+	DBErrors            *prometheus.CounterVec   // DB errors by operation
+	DBRetriesTotal      *prometheus.CounterVec   // Retries after transient DB errors by operation
+
+	DBReplicaReadsTotal *prometheus.CounterVec // Redirect lookups by where they were answered
+
+	// Database Pool Metrics (sampled from sql.DBStats)
+	DBConnectionsIdle         prometheus.Gauge // Idle connections in the pool
+	DBConnectionsOpen         prometheus.Gauge // Open connections (in use + idle)
+	DBConnectionsWaitCount    prometheus.Gauge // Total connections waited for
+	DBConnectionsWaitDuration prometheus.Gauge // Total time blocked waiting for a connection
+
+	// Click Counting Metrics (buffered in Redis, flushed to Postgres)
+	ClicksFlushedTotal   prometheus.Counter // Clicks written from Redis to Postgres
+	ClickFlushLagSeconds prometheus.Gauge   // Time since the last successful flush
+
+	// Tracking Metrics
+	TrackingEventsTotal *prometheus.CounterVec // Recorded click events by type (open), separate from redirects
+
+	// Click Event Pipeline Metrics (batched click_events inserts)
+	ClickEventsDroppedTotal *prometheus.CounterVec // Events lost, by reason (overflow, write_error, closed)
+	ClickEventBatchSize     prometheus.Histogram   // Events per batch insert
+
+	// Webhook Metrics (Integration Layer)
+	WebhookDeliveriesTotal *prometheus.CounterVec // Delivery attempts by result (success, failure)
+	WebhookDeadLetterTotal *prometheus.CounterVec // Events dropped after retries or on a full queue, by type
+
+	// Analytics Sink Metrics
+	AnalyticsEventsTotal *prometheus.CounterVec // Redirect events forwarded to the analytics sink, by result (sent, dropped, failed)
+
+	MetadataFetchesTotal *prometheus.CounterVec // Link preview fetches by result
+
+	// Resilience Metrics (Infrastructure Layer)
+	CircuitBreakerState *prometheus.GaugeVec // Breaker state by name (0=closed, 1=half-open, 2=open)
 }
 
 // NewMetrics creates and registers all Prometheus metrics
 // Using promauto.New* functions automatically registers metrics with Prometheus
 func NewMetrics() *Metrics {
+	return NewMetricsWithRegistry(prometheus.DefaultRegisterer)
+}
+
+// NewMetricsWithRegistry registers all metrics with the given registerer
+// Use case: Tests pass a fresh prometheus.NewRegistry() so each test gets
+// its own metrics without "duplicate metrics collector registration" panics
+func NewMetricsWithRegistry(reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
+
 	return &Metrics{
 		// HTTP Request Counter
 		// Labels: endpoint=/api/v1/shorten, method=POST, status=200
 		// Use case: Track request volume and error rates per endpoint
-		HTTPRequestsTotal: promauto.NewCounterVec(
+		HTTPRequestsTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "http_requests_total",
 				Help: "Total number of HTTP requests by endpoint, method and status code",
@@ -49,7 +114,7 @@ func NewMetrics() *Metrics {
 		// Buckets: 0.001s (1ms), 0.005s (5ms), 0.01s (10ms), ..., 10s
 		// Use case: Calculate P50, P95, P99 latency for each endpoint
 		// Why histogram? Allows Prometheus to calculate percentiles from buckets
-		HTTPRequestDuration: promauto.NewHistogramVec(
+		HTTPRequestDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name: "http_request_duration_seconds",
 				Help: "HTTP request latency in seconds (histogram for percentiles)",
@@ -73,9 +138,35 @@ func NewMetrics() *Metrics {
 			[]string{"endpoint", "method"},
 		),
 
+		// Redirect Time To First Byte Histogram
+		// No labels: one series per bucket, it only ever describes redirects
+		// Use case: http_request_duration_seconds also counts writing the
+		// response to the client; this stops at the Location header, so a gap
+		// between the two is the network or a slow client, not the app
+		// PromQL: histogram_quantile(0.99, rate(redirect_ttfb_seconds_bucket[5m]))
+		RedirectTTFB: factory.NewHistogram(
+			prometheus.HistogramOpts{
+				Name: "redirect_ttfb_seconds",
+				Help: "Time from receiving a redirect request to writing its Location header, in seconds",
+				Buckets: []float64{
+					0.0005, // 0.5ms - L1 cache hit
+					0.001,  // 1ms   - Redis hit
+					0.0025, // 2.5ms
+					0.005,  // 5ms
+					0.01,   // 10ms
+					0.025,  // 25ms  - DB lookup
+					0.05,   // 50ms
+					0.1,    // 100ms - slow DB lookup
+					0.25,   // 250ms
+					0.5,    // 500ms
+					1.0,    // 1s    - something is wrong
+				},
+			},
+		),
+
 		// Active Requests Gauge
 		// Use case: See current load, detect if requests are piling up (saturation)
-		HTTPRequestsActive: promauto.NewGauge(
+		HTTPRequestsActive: factory.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "http_requests_active",
 				Help: "Number of HTTP requests currently being processed",
@@ -83,17 +174,21 @@ func NewMetrics() *Metrics {
 		),
 
 		// URLs Created Counter
+		// Labels: type=custom|generated
 		// Use case: Business metric - how many URLs are we shortening?
-		URLsCreatedTotal: promauto.NewCounter(
+		// sum(urls_created_total) is the old unlabelled total, and the split
+		// (e.g. custom share) is a single expression without custom_alias_total
+		URLsCreatedTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "urls_created_total",
-				Help: "Total number of URLs shortened",
+				Help: "Total number of URLs shortened, by short code type",
 			},
+			[]string{"type"},
 		),
 
 		// URL Redirects Counter
 		// Use case: Business metric - how many clicks/redirects are happening?
-		URLRedirectsTotal: promauto.NewCounter(
+		URLRedirectsTotal: factory.NewCounter(
 			prometheus.CounterOpts{
 				Name: "url_redirects_total",
 				Help: "Total number of URL redirects served",
@@ -102,7 +197,8 @@ func NewMetrics() *Metrics {
 
 		// Custom Alias Counter
 		// Use case: Track how many users use custom aliases vs auto-generated
-		CustomAliasTotal: promauto.NewCounter(
+		// Deprecated: kept for existing dashboards, use urls_created_total{type="custom"}
+		CustomAliasTotal: factory.NewCounter(
 			prometheus.CounterOpts{
 				Name: "custom_alias_total",
 				Help: "Total number of URLs created with custom aliases",
@@ -111,17 +207,67 @@ func NewMetrics() *Metrics {
 
 		// Expired URLs Counter
 		// Use case: Track how often users hit expired links (user experience metric)
-		ExpiredURLsTotal: promauto.NewCounter(
+		ExpiredURLsTotal: factory.NewCounter(
 			prometheus.CounterOpts{
 				Name: "expired_urls_total",
 				Help: "Total number of expired URL access attempts",
 			},
 		),
 
+		// Short Code Length Histogram
+		// Labels: type=custom|generated (2 series + buckets, cardinality stays tiny)
+		// Use case: Keyspace planning - see when generated codes outgrow URL_MIN_CODE_LENGTH
+		// (padding stops kicking in) long before they hit URL_MAX_CODE_LENGTH
+		// PromQL: histogram_quantile(0.99, rate(short_code_length_bucket{type="generated"}[1h]))
+		ShortCodeLength: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "short_code_length",
+				Help:    "Length in characters of created short codes by type",
+				Buckets: prometheus.LinearBuckets(4, 1, 17), // 4..20 chars, one bucket per length
+			},
+			[]string{"type"},
+		),
+
+		// Scan Attempts Counter
+		// Labels: action=block|delay
+		// Use case: Detect keyspace enumeration - a spike means someone is walking short codes
+		ScanAttemptsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "scan_attempts_total",
+				Help: "Total number of requests from clients flagged as scanning short codes, by action taken",
+			},
+			[]string{"action"},
+		),
+
+		// Business Error Counter
+		// Labels: reason=not_found|expired|disabled|rate_limited|... (one per
+		// handled domain error, a fixed set)
+		// Use case: http_requests_total says "404" or "410"; this says whether
+		// that was a missing, expired or disabled link without scraping logs
+		BusinessErrorTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "business_error_total",
+				Help: "Total number of requests refused for a business reason, by reason",
+			},
+			[]string{"reason"},
+		),
+
+		// Rate Limited Redirects Counter
+		// Labels: scope=link|global (whose limit was hit)
+		// Use case: Spot click fraud or a link being hammered; these requests
+		// are not in url_redirects_total, so click counts stay honest
+		RedirectsRateLimitedTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "redirects_rate_limited_total",
+				Help: "Total number of redirects refused by the per-link click rate limit, by scope",
+			},
+			[]string{"scope"},
+		),
+
 		// Cache Hits Counter
 		// Labels: operation=get_by_short_code
 		// Use case: Calculate cache hit ratio = hits / (hits + misses)
-		CacheHitsTotal: promauto.NewCounterVec(
+		CacheHitsTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "cache_hits_total",
 				Help: "Total number of cache hits by operation",
@@ -131,7 +277,7 @@ func NewMetrics() *Metrics {
 
 		// Cache Misses Counter
 		// Use case: If misses are high, cache isn't effective (tune TTL or capacity)
-		CacheMissesTotal: promauto.NewCounterVec(
+		CacheMissesTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "cache_misses_total",
 				Help: "Total number of cache misses by operation",
@@ -139,9 +285,48 @@ func NewMetrics() *Metrics {
 			[]string{"operation"},
 		),
 
+		// L1 Cache Hits/Misses Counters
+		// Labels: operation=get|get_destination
+		// Use case: L1 hit ratio = hits / (hits + misses), per instance; a low
+		// ratio means the LRU is too small for the hot set or its TTL too short
+		CacheL1HitsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_l1_hits_total",
+				Help: "Total number of process-local cache hits by operation",
+			},
+			[]string{"operation"},
+		),
+		CacheL1MissesTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_l1_misses_total",
+				Help: "Total number of process-local cache misses by operation",
+			},
+			[]string{"operation"},
+		),
+
+		// Hot Key Promotions Counter
+		// Use case: A steady rate means a few links carry most redirects;
+		// compare with cache_hits_total to see how much load the shards take
+		CacheHotKeyPromotionsTotal: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cache_hot_key_promotions_total",
+				Help: "Total number of cache keys replicated across shard keys for being hot",
+			},
+		),
+
+		// Cache Divergence Counter
+		// Use case: Alert on any increase; a cached destination differs from
+		// the database, e.g. after a manual UPDATE that skipped invalidation
+		CacheDivergenceTotal: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cache_divergence_total",
+				Help: "Total number of sampled cache hits that disagreed with the database and were evicted",
+			},
+		),
+
 		// Cache Errors Counter
 		// Use case: Track Redis connection issues
-		CacheErrors: promauto.NewCounterVec(
+		CacheErrors: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "cache_errors_total",
 				Help: "Total number of cache errors by operation",
@@ -149,10 +334,52 @@ func NewMetrics() *Metrics {
 			[]string{"operation"},
 		),
 
+		// Redis Pool Gauges
+		// These are copied from redis.PoolStats by a background sampler
+		// Hits/misses/timeouts are cumulative in go-redis, so they are exported as
+		// gauges holding the running total. PromQL: deriv(redis_pool_timeouts[5m])
+		// Use case: Timeouts climbing while total == REDIS_POOL_SIZE means the pool is exhausted
+		RedisPoolHits: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_pool_hits",
+				Help: "Total number of times a free connection was found in the Redis pool",
+			},
+		),
+		RedisPoolMisses: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_pool_misses",
+				Help: "Total number of times a free connection was not found in the Redis pool",
+			},
+		),
+		RedisPoolTimeouts: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_pool_timeouts",
+				Help: "Total number of times waiting for a Redis pool connection timed out",
+			},
+		),
+		RedisPoolTotalConns: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_pool_connections_total",
+				Help: "Number of connections currently in the Redis pool",
+			},
+		),
+		RedisPoolIdleConns: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_pool_connections_idle",
+				Help: "Number of idle connections in the Redis pool",
+			},
+		),
+		RedisPoolStaleConns: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "redis_pool_connections_stale",
+				Help: "Total number of stale connections removed from the Redis pool",
+			},
+		),
+
 		// Database Query Duration Histogram
 		// Labels: operation=create_url, get_by_short_code, etc.
 		// Use case: Identify slow DB queries that need optimization
-		DBQueryDuration: promauto.NewHistogramVec(
+		DBQueryDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name: "db_query_duration_seconds",
 				Help: "Database query duration in seconds by operation",
@@ -167,7 +394,7 @@ func NewMetrics() *Metrics {
 		// Active DB Connections Gauge
 		// Use case: Monitor connection pool saturation
 		// If this equals max pool size, you're bottlenecked on DB connections
-		DBConnectionsActive: promauto.NewGauge(
+		DBConnectionsActive: factory.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "db_connections_active",
 				Help: "Number of active database connections in the pool",
@@ -176,13 +403,172 @@ func NewMetrics() *Metrics {
 
 		// Database Errors Counter
 		// Use case: Track DB failures (connection timeouts, query errors)
-		DBErrors: promauto.NewCounterVec(
+		DBErrors: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "db_errors_total",
 				Help: "Total number of database errors by operation",
 			},
 			[]string{"operation"},
 		),
+		// DB Retries Counter
+		// Use case: Retries hide blips from users; a steady rate here means the
+		// database is flapping even though db_errors_total looks quiet
+		DBRetriesTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "db_retries_total",
+				Help: "Total number of retries after transient database errors by operation",
+			},
+			[]string{"operation"},
+		),
+
+		// DB Replica Reads Counter
+		// Labels: result=replica|fallback_missing|fallback_error
+		// Use case: fallback_missing is replication lag showing through,
+		// fallback_error a replica that is down and pushing reads to the primary
+		DBReplicaReadsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "db_replica_reads_total",
+				Help: "Total number of lookups sent to a read replica, by result",
+			},
+			[]string{"result"},
+		),
+
+		// DB Pool Gauges
+		// These are copied from sql.DBStats by a background sampler
+		// Use case: Pool saturation shows up as wait count/duration climbing while
+		// db_connections_active sits at DB_MAX_OPEN_CONNS
+		DBConnectionsIdle: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "db_connections_idle",
+				Help: "Number of idle database connections in the pool",
+			},
+		),
+		DBConnectionsOpen: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "db_connections_open",
+				Help: "Number of established database connections, both in use and idle",
+			},
+		),
+		// WaitCount and WaitDuration are cumulative in sql.DBStats
+		// PromQL: deriv(db_connections_wait_count[5m]) shows how often requests block
+		DBConnectionsWaitCount: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "db_connections_wait_count",
+				Help: "Total number of connections waited for since the pool was opened",
+			},
+		),
+		DBConnectionsWaitDuration: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "db_connections_wait_duration_seconds",
+				Help: "Total time blocked waiting for a new connection since the pool was opened",
+			},
+		),
+
+		// Clicks Flushed Counter
+		// Use case: Compare with url_redirects_total - the gap is clicks still buffered
+		ClicksFlushedTotal: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "clicks_flushed_total",
+				Help: "Total number of buffered clicks written to the database",
+			},
+		),
+		// Click Flush Lag Gauge
+		// Learning: This keeps growing while flushes fail, so alert on it rather
+		// than on individual flush errors
+		ClickFlushLagSeconds: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "click_flush_lag_seconds",
+				Help: "Seconds since buffered click counts were last reconciled into the database",
+			},
+		),
+
+		// Tracking Events Counter
+		// Labels: type=open (pixel loads)
+		// Use case: Email open rates; opens never touch url_redirects_total
+		TrackingEventsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_events_total",
+				Help: "Total number of recorded tracking events by type",
+			},
+			[]string{"type"},
+		),
+
+		// Click Events Dropped Counter
+		// Labels: reason=overflow|write_error|closed
+		// Use case: overflow means the queue is too small or the DB too slow for the click rate
+		ClickEventsDroppedTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "click_events_dropped_total",
+				Help: "Total number of click events dropped before reaching the database, by reason",
+			},
+			[]string{"reason"},
+		),
+		// Click Event Batch Size Histogram
+		// Learning: batches stuck at 1 mean the flush interval, not the size, is
+		// triggering writes and the pipeline is saving few round trips
+		ClickEventBatchSize: factory.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "click_event_batch_size",
+				Help:    "Number of click events written per batch insert",
+				Buckets: []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
+			},
+		),
+
+		// Webhook Delivery Counter
+		// Labels: result=success|failure (every attempt, including retries)
+		// Use case: A rising failure rate means the receiver is down or rejecting signatures
+		WebhookDeliveriesTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "webhook_deliveries_total",
+				Help: "Total number of webhook delivery attempts by result",
+			},
+			[]string{"result"},
+		),
+
+		// Webhook Dead Letter Counter
+		// Labels: type=url.created|url.expired|url.deleted
+		// Use case: Alert on any increase - these events never reached the integration
+		WebhookDeadLetterTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "webhook_dead_letter_total",
+				Help: "Total number of webhook events dropped after exhausting retries or on a full queue",
+			},
+			[]string{"type"},
+		),
+
+		// Analytics Sink Events Counter
+		// Use case: failed or dropped > 0 means the external pipeline is
+		// missing redirects that click_count still has
+		AnalyticsEventsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "analytics_sink_events_total",
+				Help: "Total number of redirect events forwarded to the analytics sink, by result",
+			},
+			[]string{"result"},
+		),
+
+		// Metadata Fetches Counter
+		// Labels: result=success|error|disallowed|dropped
+		// Use case: A rise in "dropped" means the queue is too small for the create rate
+		MetadataFetchesTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "metadata_fetches_total",
+				Help: "Total number of link preview metadata fetches, by result",
+			},
+			[]string{"result"},
+		),
+
+		// Circuit Breaker State Gauge
+		// Labels: name=redis, postgres
+		// Values: 0=closed (healthy), 1=half-open (probing), 2=open (fast-failing)
+		// Use case: Alert when a dependency is being shed, e.g. circuit_breaker_state == 2
+		CircuitBreakerState: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "circuit_breaker_state",
+				Help: "Current circuit breaker state by name (0=closed, 1=half-open, 2=open)",
+			},
+			[]string{"name"},
+		),
 	}
 }
 
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// RequestStartKey is the gin context key MetricsMiddleware stores the
+// request's arrival time.Time under
+const RequestStartKey = "metrics.request_start"
+
+// Sum reads a collector's current value, adding up every series: a gauge
+// gives its value, a CounterVec its total across all label values, and
+// histograms and summaries count their observations
+// Use case: endpoints reporting a few numbers as JSON to clients that can't
+// scrape Prometheus, without keeping a second set of counters in step
+func Sum(c prometheus.Collector) float64 {
+	ch := make(chan prometheus.Metric, 16)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var total float64
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			continue
+		}
+		switch {
+		case m.Gauge != nil:
+			total += m.Gauge.GetValue()
+		case m.Counter != nil:
+			total += m.Counter.GetValue()
+		case m.Untyped != nil:
+			total += m.Untyped.GetValue()
+		case m.Histogram != nil:
+			total += float64(m.Histogram.GetSampleCount())
+		case m.Summary != nil:
+			total += float64(m.Summary.GetSampleCount())
+		}
+	}
+	return total
+}
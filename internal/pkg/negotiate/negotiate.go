@@ -0,0 +1,20 @@
+// Package negotiate writes response bodies in the format the client asked
+// for in Accept, for handlers and middleware alike
+package negotiate
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+)
+
+// Render writes obj as msgpack to clients that accept it, JSON otherwise
+// Field names are the json tags in both encodings.
+func Render(c *gin.Context, status int, obj any) {
+	switch c.NegotiateFormat(binding.MIMEJSON, binding.MIMEMSGPACK2, binding.MIMEMSGPACK) {
+	case binding.MIMEMSGPACK2, binding.MIMEMSGPACK:
+		c.Render(status, render.MsgPack{Data: obj})
+	default:
+		c.JSON(status, obj)
+	}
+}
@@ -0,0 +1,81 @@
+package netguard
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress means a connection would have reached a non-public address
+var ErrPrivateAddress = errors.New("destination resolves to a private address")
+
+// blockedPrefixes are special-purpose ranges the stdlib predicates
+// (IsPrivate, IsLoopback, ...) don't cover but that still reach internal or
+// non-routable hosts
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT, often internal
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, incl. broadcast
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// nat64Prefix embeds an IPv4 address in the last 32 bits, which is checked too
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// IsPublic reports whether ip is an ordinary internet address
+// IPv4-mapped and NAT64 IPv6 forms are judged by the IPv4 address inside,
+// so "::ffff:127.0.0.1" can't sneak past as IPv6.
+func IsPublic(ip netip.Addr) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	ip = ip.Unmap()
+	if nat64Prefix.Contains(ip) {
+		b := ip.As16()
+		ip = netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]})
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Control is a net.Dialer Control hook refusing non-public addresses
+// It runs on the resolved address at connect time, so DNS names pointing
+// inside, DNS rebinding and redirects to internal hosts are all caught.
+func Control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !IsPublic(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// NewDialer returns a dialer that only connects to public addresses unless
+// allowPrivate is set, which only tests should need
+func NewDialer(timeout time.Duration, allowPrivate bool) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = Control
+	}
+	return dialer
+}
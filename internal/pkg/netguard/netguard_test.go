@@ -0,0 +1,46 @@
+package netguard
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"255.255.255.255", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublic(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("IsPublic(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestControl(t *testing.T) {
+	if err := Control("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("public address: err = %v, want nil", err)
+	}
+	if err := Control("tcp", "[::1]:80", nil); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("loopback: err = %v, want ErrPrivateAddress", err)
+	}
+}
@@ -0,0 +1,121 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+	ErrInvalidParams = errors.New("invalid pagination parameters")
+)
+
+// Cursor marks the last row of a page in (created_at DESC, id DESC) order
+// Keyset pagination resumes strictly after it, so deep pages cost the same as
+// the first one and rows inserted meanwhile can't shift items between pages
+// the way they do with OFFSET
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"i"`
+}
+
+// Encode returns the cursor as an opaque URL-safe token
+// Clients must treat it as a black box, the format may change
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID <= 0 || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Before reports whether a row sorts after the cursor in (created_at DESC, id DESC)
+// order, i.e. whether it belongs to a later page
+func (c Cursor) Before(createdAt time.Time, id int64) bool {
+	if !createdAt.Equal(c.CreatedAt) {
+		return createdAt.Before(c.CreatedAt)
+	}
+	return id < c.ID
+}
+
+// Request is a parsed page request, either offset-based or cursor-based
+type Request struct {
+	Limit  int
+	Offset int
+	After  *Cursor // set for cursor pagination, Offset is then always 0
+}
+
+// ParseRequest reads the raw limit, offset and cursor query values
+// Empty strings mean "not given". Offset and cursor are mutually exclusive.
+func ParseRequest(limit, offset, cursor string) (Request, error) {
+	req := Request{Limit: DefaultLimit}
+
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return Request{}, ErrInvalidParams
+		}
+		req.Limit = min(n, MaxLimit)
+	}
+
+	if offset != "" && cursor != "" {
+		return Request{}, ErrInvalidParams
+	}
+
+	if offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return Request{}, ErrInvalidParams
+		}
+		req.Offset = n
+	}
+
+	if cursor != "" {
+		after, err := DecodeCursor(cursor)
+		if err != nil {
+			return Request{}, err
+		}
+		req.After = after
+	}
+
+	return req, nil
+}
+
+// Page is the standard envelope for list endpoints
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewPage builds a page from rows fetched with Limit+1, the extra row only
+// signals that another page exists. cursorOf returns the cursor for a row.
+func NewPage[T any](req Request, rows []T, cursorOf func(T) Cursor) Page[T] {
+	page := Page[T]{Items: rows, Limit: req.Limit, Offset: req.Offset}
+	if len(rows) > req.Limit {
+		page.Items = rows[:req.Limit]
+		page.NextCursor = cursorOf(page.Items[req.Limit-1]).Encode()
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page
+}
@@ -0,0 +1,80 @@
+package pagination
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := Cursor{CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 123456000, time.UTC), ID: 42}
+
+	got, err := DecodeCursor(want.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("DecodeCursor() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, token := range []string{"!!!", "bm90IGpzb24", Cursor{}.Encode()} {
+		if _, err := DecodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", token, err)
+		}
+	}
+}
+
+func TestParseRequest(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Now(), ID: 7}.Encode()
+
+	tests := []struct {
+		name                  string
+		limit, offset, cursor string
+		want                  Request
+		wantErr               error
+	}{
+		{name: "defaults", want: Request{Limit: DefaultLimit}},
+		{name: "offset", limit: "5", offset: "10", want: Request{Limit: 5, Offset: 10}},
+		{name: "limit capped", limit: "5000", want: Request{Limit: MaxLimit}},
+		{name: "zero limit", limit: "0", wantErr: ErrInvalidParams},
+		{name: "negative offset", offset: "-1", wantErr: ErrInvalidParams},
+		{name: "offset and cursor", offset: "1", cursor: cursor, wantErr: ErrInvalidParams},
+		{name: "bad cursor", cursor: "nope", wantErr: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRequest(tt.limit, tt.offset, tt.cursor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseRequest() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Limit != tt.want.Limit || got.Offset != tt.want.Offset) {
+				t.Errorf("ParseRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPageUsesExtraRowForNextCursor(t *testing.T) {
+	now := time.Now()
+	cursorOf := func(id int64) Cursor { return Cursor{CreatedAt: now, ID: id} }
+
+	page := NewPage(Request{Limit: 2}, []int64{3, 2, 1}, cursorOf)
+	if len(page.Items) != 2 {
+		t.Fatalf("items = %v, want 2 items", page.Items)
+	}
+	next, err := DecodeCursor(page.NextCursor)
+	if err != nil || next.ID != 2 {
+		t.Errorf("next cursor = %+v (%v), want it to point at the last returned item", next, err)
+	}
+
+	last := NewPage(Request{Limit: 2}, []int64{1}, cursorOf)
+	if last.NextCursor != "" {
+		t.Errorf("next cursor on the last page = %q, want empty", last.NextCursor)
+	}
+	if empty := NewPage(Request{Limit: 2}, nil, cursorOf); empty.Items == nil {
+		t.Error("items = nil, want an empty slice so JSON renders []")
+	}
+}
@@ -0,0 +1,34 @@
+// Package useragent classifies visitors by their User-Agent header
+package useragent
+
+import (
+	"strings"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// Platform returns domain.PlatformIOS, PlatformAndroid or PlatformDesktop,
+// or "" when the agent isn't a recognizable browser (bots, curl, empty)
+//
+// Learning: this only needs the OS family, so a few substring checks do;
+// full parsers exist for browser and version detection. Order matters:
+// Android agents also say "Linux", and iOS agents say "like Mac OS X".
+// iPads in desktop mode send a Mac agent and are treated as desktops.
+func Platform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return ""
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return domain.PlatformIOS
+	case strings.Contains(ua, "android"):
+		return domain.PlatformAndroid
+	case strings.Contains(ua, "windows phone"):
+		return ""
+	case strings.Contains(ua, "windows nt"), strings.Contains(ua, "macintosh"),
+		strings.Contains(ua, "cros"), strings.Contains(ua, "x11"):
+		return domain.PlatformDesktop
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,31 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+func TestPlatform(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want string
+	}{
+		{"iphone safari", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", domain.PlatformIOS},
+		{"ipad", "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1", domain.PlatformIOS},
+		{"android chrome", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36", domain.PlatformAndroid},
+		{"windows chrome", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", domain.PlatformDesktop},
+		{"mac firefox", "Mozilla/5.0 (Macintosh; Intel Mac OS X 14.4; rv:125.0) Gecko/20100101 Firefox/125.0", domain.PlatformDesktop},
+		{"linux", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", domain.PlatformDesktop},
+		{"windows phone", "Mozilla/5.0 (compatible; MSIE 10.0; Windows Phone 8.0; Trident/6.0; IEMobile/10.0; ARM; Touch; NOKIA; Lumia 920)", ""},
+		{"curl", "curl/8.5.0", ""},
+		{"bot", "Googlebot/2.1 (+http://www.google.com/bot.html)", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := Platform(tt.ua); got != tt.want {
+			t.Errorf("%s: Platform() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// AddAlias inserts the alias only while the link is live and no urls row
+// holds the code; when nothing was inserted a second query tells the two
+// apart. Aliases share one namespace whatever the link's prefix: a urls row
+// under any prefix holds the code.
+func (r *PostgresURLRepository) AddAlias(ctx context.Context, shortCode, alias string) error {
+	start := time.Now()
+	operation := "add_alias"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	query := `
+	INSERT INTO url_aliases (code, url_id, created_at)
+	SELECT $2, id, $3 FROM urls
+	WHERE short_code = $1 AND prefix = COALESCE($4, prefix) AND reserved_until IS NULL AND purge_after IS NULL
+	  AND NOT EXISTS (SELECT 1 FROM urls WHERE short_code = $2)
+	RETURNING url_id`
+
+	var urlID int64
+	err := r.execute(ctx, func() error {
+		return r.db.QueryRowContext(ctx, query, shortCode, alias, time.Now(), CodePrefix(ctx)).Scan(&urlID)
+	})
+	if isUniqueViolation(err) {
+		return domain.ErrShortCodeExists
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// Either the link isn't live or a urls row holds the alias's code
+		err = r.conflictOrNotFound(ctx, shortCode)
+		if errors.Is(err, domain.ErrShortCodeExists) || errors.Is(err, domain.ErrURLNotFound) {
+			return err
+		}
+	}
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresURLRepository) RemoveAlias(ctx context.Context, shortCode, alias string) error {
+	start := time.Now()
+	operation := "remove_alias"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	query := `
+	DELETE FROM url_aliases
+	WHERE code = $2 AND NOT retired
+	  AND url_id = (SELECT id FROM urls WHERE short_code = $1 AND prefix = COALESCE($3, prefix))`
+
+	var result sql.Result
+	err := r.execute(ctx, func() error {
+		var err error
+		result, err = r.db.ExecContext(ctx, query, shortCode, alias, CodePrefix(ctx))
+		return err
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrURLNotFound
+	}
+	return nil
+}
+
+// ListAliases left-joins the aliases so a link without any still returns
+// one (NULL) row, and an unknown code none
+func (r *PostgresURLRepository) ListAliases(ctx context.Context, shortCode string) ([]string, error) {
+	start := time.Now()
+	operation := "list_aliases"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	query := `
+	SELECT a.code FROM urls u LEFT JOIN url_aliases a ON a.url_id = u.id AND NOT a.retired
+	WHERE u.short_code = $1 AND u.prefix = COALESCE($2, u.prefix) AND u.reserved_until IS NULL
+	ORDER BY a.code`
+
+	var rows []sql.NullString
+	err := r.executeWithRetry(ctx, operation, isTransientReadError, func() error {
+		return r.db.SelectContext(ctx, &rows, query, shortCode, CodePrefix(ctx))
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+	return aliasCodes(rows)
+}
+
+func (r *PostgresURLRepository) ResolveAlias(ctx context.Context, alias string) (string, error) {
+	start := time.Now()
+	operation := "resolve_alias"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, alias)
+	}()
+
+	query := `
+	SELECT u.short_code, a.retired FROM url_aliases a JOIN urls u ON u.id = a.url_id
+	WHERE a.code = $1 AND u.prefix = COALESCE($2, u.prefix)`
+
+	var (
+		shortCode string
+		retired   bool
+	)
+	err := r.executeWithRetry(ctx, operation, isTransientReadError, func() error {
+		return r.db.QueryRowContext(ctx, query, alias, CodePrefix(ctx)).Scan(&shortCode, &retired)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", domain.ErrURLNotFound
+	}
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return "", err
+	}
+	if retired {
+		return "", domain.ErrCodeRetired
+	}
+	return shortCode, nil
+}
+
+// aliasCodes turns the rows of a ListAliases join into codes; no rows means
+// the link doesn't exist, a single NULL that it has no aliases
+func aliasCodes(rows []sql.NullString) ([]string, error) {
+	if len(rows) == 0 {
+		return nil, domain.ErrURLNotFound
+	}
+	codes := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.Valid {
+			codes = append(codes, row.String)
+		}
+	}
+	return codes, nil
+}
+
+// RenameShortCode updates the row, records the old code and moves the click
+// events in one transaction
+// Clicks still buffered under the old code when it is renamed (in a
+// RedisClickCounter or LocalClickCounter) find no row when flushed and are
+// lost; a rename is rare enough for that window not to matter.
+func (r *PostgresURLRepository) RenameShortCode(ctx context.Context, oldCode, newCode string, keepAlias bool) error {
+	start := time.Now()
+	operation := "rename_short_code"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, oldCode)
+	}()
+
+	r.replicas.pin(oldCode)
+	r.replicas.pin(newCode)
+
+	var renamed bool
+	err := r.execute(ctx, func() error {
+		tx, err := r.db.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		now := time.Now()
+		var (
+			urlID  int64
+			prefix string
+		)
+		err = tx.QueryRowContext(ctx, `
+		UPDATE urls SET short_code = $2, updated_at = $3
+		WHERE short_code = $1 AND prefix = COALESCE($4, prefix) AND reserved_until IS NULL AND purge_after IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM url_aliases WHERE code = $2)
+		RETURNING id, prefix`, oldCode, newCode, now, CodePrefix(ctx)).Scan(&urlID, &prefix)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO url_aliases (code, url_id, created_at, retired) VALUES ($1, $2, $3, $4)`,
+			oldCode, urlID, now, !keepAlias); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE click_events SET short_code = $2 WHERE short_code = $1 AND prefix = $3`, oldCode, newCode, prefix); err != nil {
+			return err
+		}
+		renamed = true
+		return tx.Commit()
+	})
+	if isUniqueViolation(err) {
+		return domain.ErrShortCodeExists
+	}
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	if !renamed {
+		// Either the link isn't live or newCode is an alias
+		err = r.conflictOrNotFound(ctx, oldCode)
+		if !errors.Is(err, domain.ErrShortCodeExists) && !errors.Is(err, domain.ErrURLNotFound) {
+			r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		}
+		return err
+	}
+	return nil
+}
+
+// conflictOrNotFound explains a guarded write that changed nothing: with
+// shortCode a live link, the code it wanted was taken (ErrShortCodeExists),
+// otherwise there was no link to change (ErrURLNotFound)
+func (r *PostgresURLRepository) conflictOrNotFound(ctx context.Context, shortCode string) error {
+	var live bool
+	err := r.execute(ctx, func() error {
+		return r.db.GetContext(ctx, &live, `
+		SELECT EXISTS (
+			SELECT 1 FROM urls
+			WHERE short_code = $1 AND prefix = COALESCE($2, prefix) AND reserved_until IS NULL AND purge_after IS NULL
+		)`, shortCode, CodePrefix(ctx))
+	})
+	switch {
+	case err != nil:
+		return err
+	case live:
+		return domain.ErrShortCodeExists
+	default:
+		return domain.ErrURLNotFound
+	}
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"fmt"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/subhammahanty235/url-shortener/internal/config"
+	"go.uber.org/zap"
+)
+
+// NewMemcachedClient creates a Memcached client and checks every server
+// answers, so a typo in MEMCACHED_SERVERS fails at startup
+func NewMemcachedClient(cfg config.MemcachedConfig, logger *zap.Logger) (*memcache.Client, error) {
+	logger.Info("connecting to Memcached", zap.Strings("servers", cfg.Servers))
+
+	client, err := newMemcachedClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = cfg.Timeout
+
+	if err := client.Ping(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping Memcached: %w", err)
+	}
+
+	logger.Info("connected to Memcached successfully")
+	return client, nil
+}
+
+// NewMemcachedReader creates the client cache reads go through. Its timeout
+// is MEMCACHED_GET_TIMEOUT so redirects fall back to the DB quickly; gomemcache
+// calls take no context to bound a single read otherwise
+func NewMemcachedReader(cfg config.MemcachedConfig) (*memcache.Client, error) {
+	client, err := newMemcachedClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = cfg.Timeout
+	if cfg.GetTimeout > 0 && (cfg.Timeout <= 0 || cfg.GetTimeout < cfg.Timeout) {
+		client.Timeout = cfg.GetTimeout
+	}
+	return client, nil
+}
+
+func newMemcachedClient(cfg config.MemcachedConfig) (*memcache.Client, error) {
+	if len(cfg.Servers) == 0 {
+		return nil, fmt.Errorf("no Memcached servers configured")
+	}
+	var servers memcache.ServerList
+	if err := servers.SetServers(cfg.Servers...); err != nil {
+		return nil, fmt.Errorf("invalid Memcached server list: %w", err)
+	}
+	client := memcache.NewFromSelector(&servers)
+	client.MaxIdleConns = cfg.MaxIdleConns
+	return client, nil
+}
+
+// CloseMemcached closes the Memcached client
+func CloseMemcached(client *memcache.Client, logger *zap.Logger) {
+	if client != nil {
+		logger.Info("closing Memcached connections")
+		client.Close()
+	}
+}
@@ -28,6 +28,10 @@ func NewRedisClient(cfg config.RedisConfig, logger *zap.Logger) (*redis.Client,
 		DialTimeout:  cfg.DialTimeout,
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
+
+		// Socket deadlines follow the caller's context, which is what
+		// bounds cache reads with REDIS_GET_TIMEOUT
+		ContextTimeoutEnabled: true,
 	})
 
 	// Verify connection
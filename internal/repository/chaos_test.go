@@ -0,0 +1,132 @@
+//go:build chaos
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/sony/gobreaker"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/breaker"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/chaos"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+func TestChaosCacheErrorsTripTheBreaker(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	ctx := context.Background()
+
+	// Unconfigured, a chaos build behaves like any other
+	healthy := NewRedisCacheRepository(client, time.Hour, m, nil, RedisCacheOptions{})
+	if err := healthy.Set(ctx, &domain.URL{ShortURL: "abc123"}, time.Minute); err != nil {
+		t.Fatalf("Set() without faults error = %v", err)
+	}
+
+	cb := breaker.New("redis-chaos", breaker.Config{MaxFailures: 3, OpenTimeout: time.Minute}, zap.NewNop(), m, IsCacheBreakerSuccess)
+	repo := NewRedisCacheRepository(client, time.Hour, m, cb, RedisCacheOptions{
+		Faults: chaos.Fault{ErrorRate: 1},
+	})
+	for i := 0; i < 3; i++ {
+		if _, err := repo.Get(ctx, "abc123"); !errors.Is(err, chaos.ErrInjected) {
+			t.Fatalf("Get() attempt %d error = %v, want ErrInjected", i+1, err)
+		}
+	}
+	if cb.State() != gobreaker.StateOpen {
+		t.Errorf("breaker state after injected errors = %v, want open", cb.State())
+	}
+}
+
+func TestChaosCacheLatencyHitsTheGetTimeout(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), ContextTimeoutEnabled: true})
+	defer client.Close()
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	repo := NewRedisCacheRepository(client, time.Hour, m, nil, RedisCacheOptions{
+		GetTimeout: 10 * time.Millisecond,
+		Faults:     chaos.Fault{Latency: time.Second},
+	})
+
+	// The injected latency is cut short by the get timeout, as a slow
+	// server would be
+	start := time.Now()
+	if _, err := repo.Get(context.Background(), "abc123"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() error = %v, want the get timeout to expire", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Get() took %v, want it to give up at the 10ms get timeout", elapsed)
+	}
+}
+
+func TestChaosDBErrorsNeverReachTheDatabase(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	cb := breaker.New("postgres-chaos", breaker.Config{MaxFailures: 3, OpenTimeout: time.Minute}, zap.NewNop(), m, IsDBBreakerSuccess)
+	repo, mock, _ := newMockPostgresRepo(t, cb)
+	repo.InjectFaults(chaos.Fault{ErrorRate: 1})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := repo.GetByShortCode(ctx, "abc123"); !errors.Is(err, chaos.ErrInjected) {
+			t.Fatalf("GetByShortCode() attempt %d error = %v, want ErrInjected", i+1, err)
+		}
+	}
+	if cb.State() != gobreaker.StateOpen {
+		t.Errorf("breaker state after injected errors = %v, want open", cb.State())
+	}
+	if _, err := repo.GetByShortCode(ctx, "abc123"); !errors.Is(err, domain.ErrServiceUnavailable) {
+		t.Errorf("GetByShortCode() with open breaker error = %v, want ErrServiceUnavailable", err)
+	}
+	// No query was expected, so any that ran would have failed the mock
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestChaosReplicaErrorsFallBackToThePrimary(t *testing.T) {
+	repo, primary, m := newMockPostgresRepo(t, nil)
+	replica, replicaMock := newMockReplica(t)
+	repo.UseReadReplicas([]*sqlx.DB{replica}, time.Minute)
+	repo.InjectFaults(chaos.Fault{ErrorRate: 1})
+
+	// The replica is failed before its query runs, and so is the primary
+	if _, err := repo.GetByShortCode(context.Background(), "abc123"); !errors.Is(err, chaos.ErrInjected) {
+		t.Fatalf("GetByShortCode() error = %v, want ErrInjected", err)
+	}
+	if got := testutil.ToFloat64(m.DBReplicaReadsTotal.WithLabelValues("fallback_error")); got != 1 {
+		t.Errorf("db_replica_reads_total{fallback_error} = %v, want 1", got)
+	}
+	for name, mock := range map[string]sqlmock.Sqlmock{"primary": primary, "replica": replicaMock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestChaosReplicaLatencyHitsTheDeadline(t *testing.T) {
+	repo, _, _ := newMockPostgresRepo(t, nil)
+	replica, _ := newMockReplica(t)
+	repo.UseReadReplicas([]*sqlx.DB{replica}, time.Minute)
+	repo.InjectFaults(chaos.Fault{Latency: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := repo.GetByShortCode(ctx, "abc123"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetByShortCode() error = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("GetByShortCode() took %v, want it to give up at the 10ms deadline", elapsed)
+	}
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// ClickEventWriter inserts many click events in one round trip
+type ClickEventWriter interface {
+	RecordClickEvents(ctx context.Context, events []*domain.ClickEvent) error
+}
+
+// OverflowPolicy decides what a full click event queue does with a new event
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest discards the oldest queued event to make room, so
+	// the request never waits and the newest events survive a DB stall
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+
+	// OverflowBlock makes the request wait for room (up to its own
+	// deadline); nothing is lost but a DB stall slows the requests down
+	OverflowBlock OverflowPolicy = "block"
+)
+
+// ParseOverflowPolicy validates a configured policy, empty is drop_oldest
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(s); p {
+	case "":
+		return OverflowDropOldest, nil
+	case OverflowDropOldest, OverflowBlock:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown click event overflow policy %q, want drop_oldest or block", s)
+	}
+}
+
+// maxClickEventBatch keeps a batch insert under Postgres' 65535 bind
+// parameters (12 per event)
+const maxClickEventBatch = 1000
+
+type ClickEventPipelineConfig struct {
+	QueueSize     int
+	Workers       int
+	BatchSize     int           // a batch is written once it holds this many events...
+	FlushInterval time.Duration // ...or this long after its first event
+	WriteTimeout  time.Duration
+	Overflow      OverflowPolicy
+}
+
+// ClickEventPipeline records click events asynchronously: RecordClickEvent
+// queues the event and a few workers write them in multi-row batches
+//
+// Learning: one INSERT per event costs a round trip and a commit each, and
+// under load the pool runs out of connections before the database runs out
+// of capacity. Batching turns N round trips into one, traded against events
+// being written up to FlushInterval late and lost if the process crashes.
+//
+// Events get no ID, the insert happens after RecordClickEvent returns.
+type ClickEventPipeline struct {
+	writer  ClickEventWriter
+	cfg     ClickEventPipelineConfig
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+
+	queue chan *domain.ClickEvent
+	wg    sync.WaitGroup
+
+	// closeMu keeps Close from closing the queue under a sender
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+var _ domain.ClickEventRepository = (*ClickEventPipeline)(nil)
+
+// NewClickEventPipeline starts the workers; Close stops them
+func NewClickEventPipeline(writer ClickEventWriter, cfg ClickEventPipelineConfig, m *metrics.Metrics, logger *zap.Logger) *ClickEventPipeline {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.BatchSize > maxClickEventBatch {
+		cfg.BatchSize = maxClickEventBatch
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 200 * time.Millisecond
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 5 * time.Second
+	}
+	if cfg.Overflow == "" {
+		cfg.Overflow = OverflowDropOldest
+	}
+
+	p := &ClickEventPipeline{
+		writer:  writer,
+		cfg:     cfg,
+		metrics: m,
+		logger:  logger,
+		queue:   make(chan *domain.ClickEvent, cfg.QueueSize),
+	}
+	p.wg.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// RecordClickEvent queues a copy of event
+// With OverflowBlock it returns ctx's error if the queue stays full until
+// ctx is done; with OverflowDropOldest it never blocks.
+func (p *ClickEventPipeline) RecordClickEvent(ctx context.Context, event *domain.ClickEvent) error {
+	queued := *event
+	if queued.Type == "" {
+		queued.Type = domain.ClickEventRedirect
+	}
+	if queued.CreatedAt.IsZero() {
+		queued.CreatedAt = time.Now()
+	}
+
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		p.metrics.ClickEventsDroppedTotal.WithLabelValues("closed").Inc()
+		return nil
+	}
+
+	if p.cfg.Overflow == OverflowBlock {
+		select {
+		case p.queue <- &queued:
+			return nil
+		case <-ctx.Done():
+			p.metrics.ClickEventsDroppedTotal.WithLabelValues("overflow").Inc()
+			return ctx.Err()
+		}
+	}
+
+	for {
+		select {
+		case p.queue <- &queued:
+			return nil
+		default:
+		}
+		// Full: evict the oldest and try again; a worker may have taken it
+		// in between, which makes room just as well
+		select {
+		case <-p.queue:
+			p.metrics.ClickEventsDroppedTotal.WithLabelValues("overflow").Inc()
+		default:
+		}
+	}
+}
+
+// Close stops accepting events and waits until the queue is written
+// Call it after the HTTP server has shut down, once nothing records events.
+func (p *ClickEventPipeline) Close(ctx context.Context) error {
+	p.closeMu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.closeMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("click events still queued: %w", ctx.Err())
+	}
+}
+
+func (p *ClickEventPipeline) worker() {
+	defer p.wg.Done()
+
+	batch := make([]*domain.ClickEvent, 0, p.cfg.BatchSize)
+	timer := time.NewTimer(p.cfg.FlushInterval)
+	timer.Stop()
+
+	flush := func() {
+		timer.Stop()
+		if len(batch) > 0 {
+			p.write(batch)
+			batch = make([]*domain.ClickEvent, 0, p.cfg.BatchSize)
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-p.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) == 1 {
+				timer.Reset(p.cfg.FlushInterval)
+			}
+			if len(batch) >= p.cfg.BatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// write has no request to inherit a deadline from, so it brings its own
+func (p *ClickEventPipeline) write(batch []*domain.ClickEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.WriteTimeout)
+	defer cancel()
+
+	p.metrics.ClickEventBatchSize.Observe(float64(len(batch)))
+	if err := p.writer.RecordClickEvents(ctx, batch); err != nil {
+		p.metrics.ClickEventsDroppedTotal.WithLabelValues("write_error").Add(float64(len(batch)))
+		p.logger.Warn("failed to write click events", zap.Int("events", len(batch)), zap.Error(err))
+	}
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// fakeClickEventWriter records each batch; while gate is set every write
+// waits for it to close, simulating a stalled database
+type fakeClickEventWriter struct {
+	mu      sync.Mutex
+	batches [][]*domain.ClickEvent
+	gate    chan struct{}
+	written chan int
+}
+
+func newFakeClickEventWriter() *fakeClickEventWriter {
+	return &fakeClickEventWriter{written: make(chan int, 100)}
+}
+
+func (w *fakeClickEventWriter) RecordClickEvents(ctx context.Context, events []*domain.ClickEvent) error {
+	if w.gate != nil {
+		<-w.gate
+	}
+	w.mu.Lock()
+	w.batches = append(w.batches, events)
+	w.mu.Unlock()
+	w.written <- len(events)
+	return nil
+}
+
+func (w *fakeClickEventWriter) codes() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var codes []string
+	for _, batch := range w.batches {
+		for _, event := range batch {
+			codes = append(codes, event.ShortCode)
+		}
+	}
+	return codes
+}
+
+func newTestPipeline(w ClickEventWriter, cfg ClickEventPipelineConfig) (*ClickEventPipeline, *metrics.Metrics) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	return NewClickEventPipeline(w, cfg, m, zap.NewNop()), m
+}
+
+func recordCodes(t *testing.T, p *ClickEventPipeline, codes ...string) {
+	t.Helper()
+	for _, code := range codes {
+		if err := p.RecordClickEvent(context.Background(), &domain.ClickEvent{ShortCode: code}); err != nil {
+			t.Fatalf("RecordClickEvent(%s) error = %v", code, err)
+		}
+	}
+}
+
+func waitForBatch(t *testing.T, w *fakeClickEventWriter) int {
+	t.Helper()
+	select {
+	case n := <-w.written:
+		return n
+	case <-time.After(2 * time.Second):
+		t.Fatal("no batch was written")
+		return 0
+	}
+}
+
+func TestClickEventPipelineFlushesBySize(t *testing.T) {
+	w := newFakeClickEventWriter()
+	// The interval never fires during the test, only a full batch is written
+	p, _ := newTestPipeline(w, ClickEventPipelineConfig{Workers: 1, BatchSize: 3, FlushInterval: time.Hour})
+	defer p.Close(context.Background())
+
+	recordCodes(t, p, "a", "b", "c", "d")
+	if n := waitForBatch(t, w); n != 3 {
+		t.Errorf("first batch = %d events, want 3", n)
+	}
+	select {
+	case n := <-w.written:
+		t.Errorf("a %d-event batch was written before it filled up", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestClickEventPipelineFlushesByTimer(t *testing.T) {
+	w := newFakeClickEventWriter()
+	p, _ := newTestPipeline(w, ClickEventPipelineConfig{Workers: 1, BatchSize: 100, FlushInterval: 20 * time.Millisecond})
+	defer p.Close(context.Background())
+
+	start := time.Now()
+	recordCodes(t, p, "a", "b")
+	if n := waitForBatch(t, w); n != 2 {
+		t.Errorf("timed batch = %d events, want 2", n)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("batch written after %v, want it to wait for the interval", elapsed)
+	}
+}
+
+func TestClickEventPipelineDropsOldestOnOverflow(t *testing.T) {
+	w := newFakeClickEventWriter()
+	w.gate = make(chan struct{})
+	p, m := newTestPipeline(w, ClickEventPipelineConfig{Workers: 1, QueueSize: 3, BatchSize: 1, Overflow: OverflowDropOldest})
+
+	// The worker takes the first event and stalls writing it; the rest of
+	// the burst overflows the queue, which keeps only the newest three
+	recordCodes(t, p, "stalled")
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		recordCodes(t, p, fmt.Sprintf("e%d", i))
+	}
+
+	if got := testutil.ToFloat64(m.ClickEventsDroppedTotal.WithLabelValues("overflow")); got != 7 {
+		t.Errorf("overflow drops = %v, want 7", got)
+	}
+
+	close(w.gate)
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	want := []string{"stalled", "e7", "e8", "e9"}
+	if got := w.codes(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("written = %v, want %v", got, want)
+	}
+}
+
+func TestClickEventPipelineBlockWaitsForRoom(t *testing.T) {
+	w := newFakeClickEventWriter()
+	w.gate = make(chan struct{})
+	defer close(w.gate)
+	p, m := newTestPipeline(w, ClickEventPipelineConfig{Workers: 1, QueueSize: 1, BatchSize: 1, Overflow: OverflowBlock})
+
+	recordCodes(t, p, "stalled")
+	time.Sleep(20 * time.Millisecond)
+	recordCodes(t, p, "queued")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := p.RecordClickEvent(ctx, &domain.ClickEvent{ShortCode: "waits"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RecordClickEvent() on a full queue error = %v, want DeadlineExceeded", err)
+	}
+	if got := testutil.ToFloat64(m.ClickEventsDroppedTotal.WithLabelValues("overflow")); got != 1 {
+		t.Errorf("overflow drops = %v, want 1", got)
+	}
+}
+
+func TestClickEventPipelineCloseDrainsQueue(t *testing.T) {
+	w := newFakeClickEventWriter()
+	p, m := newTestPipeline(w, ClickEventPipelineConfig{Workers: 2, BatchSize: 100, FlushInterval: time.Hour})
+
+	recordCodes(t, p, "a", "b", "c")
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := len(w.codes()); got != 3 {
+		t.Errorf("written after Close() = %d events, want 3", got)
+	}
+
+	// Events arriving after shutdown are dropped, not panicking on a closed channel
+	recordCodes(t, p, "late")
+	if got := testutil.ToFloat64(m.ClickEventsDroppedTotal.WithLabelValues("closed")); got != 1 {
+		t.Errorf("closed drops = %v, want 1", got)
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	if p, err := ParseOverflowPolicy(""); err != nil || p != OverflowDropOldest {
+		t.Errorf(`ParseOverflowPolicy("") = %q, %v; want drop_oldest`, p, err)
+	}
+	if p, err := ParseOverflowPolicy("block"); err != nil || p != OverflowBlock {
+		t.Errorf(`ParseOverflowPolicy("block") = %q, %v; want block`, p, err)
+	}
+	if _, err := ParseOverflowPolicy("drop_newest"); err == nil {
+		t.Error(`ParseOverflowPolicy("drop_newest") returned no error`)
+	}
+}
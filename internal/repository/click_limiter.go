@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisClickLimiter enforces redirect rate limits with fixed-window counters
+// Learning: INCR + EXPIRE NX in one MULTI is a single round trip and every
+// instance shares the count, unlike an in-process token bucket
+type RedisClickLimiter struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisClickLimiter namespaces its keys with keyPrefix, as
+// RedisCacheOptions.KeyPrefix
+func NewRedisClickLimiter(client *redis.Client, keyPrefix string) *RedisClickLimiter {
+	return &RedisClickLimiter{client: client, keyPrefix: keyPrefix}
+}
+
+func (r *RedisClickLimiter) Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, error) {
+	redisKey := r.keyPrefix + rateLimitCache + key
+
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, redisKey)
+	// NX: only the first hit in a window starts the clock
+	pipe.ExpireNX(ctx, redisKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return incr.Val() <= limit, nil
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	clickCountPrefix = "clicks:" // clicks:<short_code> -> clicks not yet in Postgres
+
+	// Bookkeeping keys use their own prefix so no short code can collide with them
+	clickPendingKey  = "clickq:pending" // set of codes with a clicks:<code> counter
+	clickBatchPrefix = "clickq:batch:"  // hash code -> count, claimed by a flush
+	clickBatchesKey  = "clickq:batches" // set of claimed batches not yet applied
+)
+
+// RedisClickCounter buffers redirect clicks in Redis so the hot path never
+// waits on a Postgres UPDATE. ClickFlusher moves the counts into the database.
+// keyPrefix namespaces its keys like RedisCacheOptions.KeyPrefix, and must
+// match the flusher's.
+type RedisClickCounter struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+func NewRedisClickCounter(client *redis.Client, keyPrefix string) *RedisClickCounter {
+	return &RedisClickCounter{client: client, keyPrefix: keyPrefix}
+}
+
+func (r *RedisClickCounter) Incr(ctx context.Context, shortCode string) error {
+	pipe := r.client.TxPipeline()
+	pipe.Incr(ctx, r.keyPrefix+clickCountPrefix+shortCode)
+	pipe.SAdd(ctx, r.keyPrefix+clickPendingKey, shortCode)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ClickSink applies a batch of click counts to durable storage
+// Applying the same batchID twice must be a no-op, that is what makes
+// replaying a batch after a crash safe
+type ClickSink interface {
+	ApplyClickBatch(ctx context.Context, batchID string, counts map[string]int64) error
+}
+
+// claimClicksScript atomically moves up to ARGV[3] pending counters into a
+// batch hash, so increments that land mid-flush go to the next batch
+// KEYS: pending set, batch hash, batches set
+// ARGV: batch id, counter prefix, max codes
+// Returns the number of codes taken from the pending set
+var claimClicksScript = redis.NewScript(`
+local codes = redis.call('SPOP', KEYS[1], ARGV[3])
+local claimed = false
+for _, code in ipairs(codes) do
+	local key = ARGV[2] .. code
+	local n = redis.call('GET', key)
+	if n then
+		redis.call('DEL', key)
+		redis.call('HSET', KEYS[2], code, n)
+		claimed = true
+	end
+end
+if claimed then
+	redis.call('SADD', KEYS[3], ARGV[1])
+end
+return #codes
+`)
+
+type ClickFlusherConfig struct {
+	Interval  time.Duration
+	BatchSize int // codes per batch
+	// KeyPrefix is the RedisClickCounter's key prefix
+	KeyPrefix string
+}
+
+// ClickFlusher periodically reconciles buffered click counts into Postgres
+//
+// Each flush claims the pending counters into a uniquely named batch, applies
+// it, and only then deletes it. If the process dies between claim and delete,
+// the batch is still listed in Redis and the next flush (on any instance)
+// replays it; the sink ignores batch IDs it already applied, so counts are
+// neither lost nor doubled.
+type ClickFlusher struct {
+	client  *redis.Client
+	sink    ClickSink
+	cfg     ClickFlusherConfig
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+
+	lastFlush time.Time
+}
+
+func NewClickFlusher(client *redis.Client, sink ClickSink, cfg ClickFlusherConfig, m *metrics.Metrics, logger *zap.Logger) *ClickFlusher {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	return &ClickFlusher{
+		client:    client,
+		sink:      sink,
+		cfg:       cfg,
+		metrics:   m,
+		logger:    logger,
+		lastFlush: time.Now(),
+	}
+}
+
+// Run flushes every interval until ctx is cancelled, then makes a final
+// attempt so a clean shutdown leaves nothing buffered
+func (f *ClickFlusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			finalCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			f.flushAndLog(finalCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			f.flushAndLog(ctx)
+		}
+	}
+}
+
+func (f *ClickFlusher) flushAndLog(ctx context.Context) {
+	if err := f.Flush(ctx); err != nil {
+		f.logger.Warn("click flush failed, counts stay buffered in Redis", zap.Error(err))
+	}
+	f.metrics.ClickFlushLagSeconds.Set(time.Since(f.lastFlush).Seconds())
+}
+
+// Flush replays unfinished batches, then claims and applies new ones until
+// nothing is pending
+func (f *ClickFlusher) Flush(ctx context.Context) error {
+	leftover, err := f.client.SMembers(ctx, f.cfg.KeyPrefix+clickBatchesKey).Result()
+	if err != nil {
+		return err
+	}
+	for _, batchID := range leftover {
+		if err := f.applyBatch(ctx, batchID); err != nil {
+			return err
+		}
+	}
+
+	for {
+		batchID, err := newBatchID()
+		if err != nil {
+			return err
+		}
+		prefix := f.cfg.KeyPrefix
+		popped, err := claimClicksScript.Run(ctx, f.client,
+			[]string{prefix + clickPendingKey, prefix + clickBatchPrefix + batchID, prefix + clickBatchesKey},
+			batchID, prefix+clickCountPrefix, f.cfg.BatchSize,
+		).Int()
+		if err != nil {
+			return err
+		}
+		if err := f.applyBatch(ctx, batchID); err != nil {
+			return err
+		}
+		if popped < f.cfg.BatchSize {
+			break
+		}
+	}
+
+	f.lastFlush = time.Now()
+	return nil
+}
+
+func (f *ClickFlusher) applyBatch(ctx context.Context, batchID string) error {
+	batchKey := f.cfg.KeyPrefix + clickBatchPrefix + batchID
+	raw, err := f.client.HGetAll(ctx, batchKey).Result()
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int64, len(raw))
+	var total int64
+	for code, value := range raw {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("click batch %s: bad count for %q: %w", batchID, code, err)
+		}
+		counts[code] = n
+		total += n
+	}
+
+	if len(counts) > 0 {
+		if err := f.sink.ApplyClickBatch(ctx, batchID, counts); err != nil {
+			return err
+		}
+	}
+
+	pipe := f.client.TxPipeline()
+	pipe.Del(ctx, batchKey)
+	pipe.SRem(ctx, f.cfg.KeyPrefix+clickBatchesKey, batchID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	f.metrics.ClicksFlushedTotal.Add(float64(total))
+	return nil
+}
+
+func newBatchID() (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + hex.EncodeToString(suffix), nil
+}
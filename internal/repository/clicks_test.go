@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// fakeClickSink mimics the Postgres sink: batches are applied at most once
+type fakeClickSink struct {
+	mu      sync.Mutex
+	applied map[string]bool
+	counts  map[string]int64
+	// failAfterApply simulates a crash after the DB commit but before Redis cleanup
+	failAfterApply bool
+	err            error
+}
+
+func newFakeClickSink() *fakeClickSink {
+	return &fakeClickSink{applied: make(map[string]bool), counts: make(map[string]int64)}
+}
+
+func (s *fakeClickSink) ApplyClickBatch(ctx context.Context, batchID string, counts map[string]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	if !s.applied[batchID] {
+		s.applied[batchID] = true
+		for code, n := range counts {
+			s.counts[code] += n
+		}
+	}
+	if s.failAfterApply {
+		s.failAfterApply = false
+		return errors.New("connection lost after commit")
+	}
+	return nil
+}
+
+func newTestClickFlusher(t *testing.T, sink ClickSink, batchSize int) (*ClickFlusher, *RedisClickCounter, *miniredis.Miniredis, *metrics.Metrics) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	flusher := NewClickFlusher(client, sink, ClickFlusherConfig{BatchSize: batchSize}, m, zap.NewNop())
+	return flusher, NewRedisClickCounter(client, ""), mr, m
+}
+
+func incrN(t *testing.T, counter *RedisClickCounter, code string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := counter.Incr(context.Background(), code); err != nil {
+			t.Fatalf("Incr(%q) error = %v", code, err)
+		}
+	}
+}
+
+func TestClickFlusherMovesCountsToSink(t *testing.T) {
+	sink := newFakeClickSink()
+	// Batch size 2 forces several claim rounds for 3 codes
+	flusher, counter, mr, m := newTestClickFlusher(t, sink, 2)
+	ctx := context.Background()
+
+	incrN(t, counter, "abc", 3)
+	incrN(t, counter, "def", 1)
+	incrN(t, counter, "ghi", 2)
+
+	if err := flusher.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := map[string]int64{"abc": 3, "def": 1, "ghi": 2}
+	for code, n := range want {
+		if sink.counts[code] != n {
+			t.Errorf("flushed count for %s = %d, want %d", code, sink.counts[code], n)
+		}
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("Redis keys after flush = %v, want none", keys)
+	}
+	if got := testutil.ToFloat64(m.ClicksFlushedTotal); got != 6 {
+		t.Errorf("clicks_flushed_total = %v, want 6", got)
+	}
+
+	// Nothing pending: a second flush is a no-op
+	if err := flusher.Flush(ctx); err != nil {
+		t.Fatalf("second Flush() error = %v", err)
+	}
+	if sink.counts["abc"] != 3 {
+		t.Errorf("count for abc after empty flush = %d, want 3", sink.counts["abc"])
+	}
+}
+
+func TestClickFlusherKeepsCountsWhenSinkFails(t *testing.T) {
+	sink := newFakeClickSink()
+	sink.err = errors.New("database is down")
+	flusher, counter, _, _ := newTestClickFlusher(t, sink, 100)
+	ctx := context.Background()
+
+	incrN(t, counter, "abc", 2)
+	if err := flusher.Flush(ctx); err == nil {
+		t.Fatal("Flush() error = nil, want the sink error")
+	}
+
+	// Clicks that arrive while the DB is down land in a new batch
+	incrN(t, counter, "abc", 1)
+
+	sink.err = nil
+	if err := flusher.Flush(ctx); err != nil {
+		t.Fatalf("Flush() after recovery error = %v", err)
+	}
+	if sink.counts["abc"] != 3 {
+		t.Errorf("count for abc = %d, want 3 (no clicks lost)", sink.counts["abc"])
+	}
+}
+
+func TestClickFlusherReplayIsIdempotent(t *testing.T) {
+	sink := newFakeClickSink()
+	sink.failAfterApply = true
+	flusher, counter, mr, _ := newTestClickFlusher(t, sink, 100)
+	ctx := context.Background()
+
+	incrN(t, counter, "abc", 5)
+
+	// The sink commits, but the flusher never hears back, so the batch stays in Redis
+	if err := flusher.Flush(ctx); err == nil {
+		t.Fatal("Flush() error = nil, want the simulated failure")
+	}
+	if members, _ := mr.Members(clickBatchesKey); len(members) != 1 {
+		t.Fatalf("unfinished batches = %v, want 1 left for replay", members)
+	}
+
+	// A fresh flusher (e.g. after a restart) replays the same batch
+	if err := flusher.Flush(ctx); err != nil {
+		t.Fatalf("Flush() replay error = %v", err)
+	}
+	if sink.counts["abc"] != 5 {
+		t.Errorf("count for abc = %d, want 5 (replay must not double count)", sink.counts["abc"])
+	}
+	if mr.Exists(clickBatchesKey) {
+		t.Error("batch list still present after a successful replay")
+	}
+}
+
+func TestPostgresApplyClickBatchSkipsAppliedBatches(t *testing.T) {
+	repo, mock, _ := newMockPostgresRepo(t, nil)
+	ctx := context.Background()
+	counts := map[string]int64{"def": 1, "abc": 3}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO click_flushes").WithArgs("batch-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	// Codes are updated in sorted order
+	mock.ExpectExec("UPDATE urls SET click_count").WithArgs("abc", "", int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE urls SET click_count").WithArgs("def", "", int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM click_flushes").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	if err := repo.ApplyClickBatch(ctx, "batch-1", counts); err != nil {
+		t.Fatalf("ApplyClickBatch() error = %v", err)
+	}
+
+	// Replaying the same batch inserts nothing, so no counts are touched
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO click_flushes").WithArgs("batch-1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := repo.ApplyClickBatch(ctx, "batch-1", counts); err != nil {
+		t.Fatalf("ApplyClickBatch() replay error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestClickFlusherReportsLag(t *testing.T) {
+	sink := newFakeClickSink()
+	sink.err = errors.New("database is down")
+	flusher, counter, _, m := newTestClickFlusher(t, sink, 100)
+	flusher.lastFlush = time.Now().Add(-time.Minute)
+
+	incrN(t, counter, "abc", 1)
+	flusher.flushAndLog(context.Background())
+
+	if lag := testutil.ToFloat64(m.ClickFlushLagSeconds); lag < 60 {
+		t.Errorf("click_flush_lag_seconds = %v, want >= 60 while flushes fail", lag)
+	}
+}
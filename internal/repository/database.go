@@ -43,8 +43,53 @@ func NewPostgresConnection(cfg config.DatabaseConfig, logger *zap.Logger) (*sqlx
 	return db, nil
 }
 
+// NewReadReplicaConnections opens one pool per replica DSN with the primary's
+// pool settings
+// DSNs carry credentials, so replicas are only ever logged by position
+func NewReadReplicaConnections(cfg config.DatabaseConfig, logger *zap.Logger) ([]*sqlx.DB, error) {
+	replicas := make([]*sqlx.DB, 0, len(cfg.ReadReplicaDSNs))
+	for i, dsn := range cfg.ReadReplicaDSNs {
+		db, err := sqlx.Open("postgres", dsn)
+		if err != nil {
+			closeAll(replicas)
+			return nil, fmt.Errorf("failed to open read replica %d: %w", i, err)
+		}
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = db.PingContext(ctx)
+		cancel()
+		if err != nil {
+			db.Close()
+			closeAll(replicas)
+			return nil, fmt.Errorf("failed to ping read replica %d: %w", i, err)
+		}
+
+		replicas = append(replicas, db)
+		logger.Info("connected to read replica", zap.Int("replica", i))
+	}
+	return replicas, nil
+}
+
+func closeAll(dbs []*sqlx.DB) {
+	for _, db := range dbs {
+		db.Close()
+	}
+}
+
+// SchemaOptions are the schema choices left to configuration
+type SchemaOptions struct {
+	// CodesPerPrefix lets links under different prefixes share a short
+	// code: short codes are unique per (short_code, prefix) instead of
+	// across the whole table (URL_ALIAS_SCOPE=prefix)
+	CodesPerPrefix bool
+}
+
 // RunMigrations runs database migrations
-func RunMigrations(db *sqlx.DB, logger *zap.Logger) error {
+func RunMigrations(db *sqlx.DB, opts SchemaOptions, logger *zap.Logger) error {
 	logger.Info("running database migrations")
 
 	migrations := []string{
@@ -76,6 +121,42 @@ func RunMigrations(db *sqlx.DB, logger *zap.Logger) error {
 		// Index on created_at for sorting
 		`CREATE INDEX IF NOT EXISTS idx_urls_created_at ON urls(created_at DESC)`,
 
+		// Private links only resolve for their creator
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS visibility VARCHAR(10) NOT NULL DEFAULT 'public'`,
+
+		// Signed links need an HMAC tag on the code to resolve
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS signed BOOLEAN NOT NULL DEFAULT false`,
+
+		// Alias reservations are rows with reserved_until set and no destination
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS reserved_until TIMESTAMP WITH TIME ZONE`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_reserved_until ON urls(reserved_until) WHERE reserved_until IS NOT NULL`,
+
+		// Per-link redirect rate limit, NULL uses the server-wide limit
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS click_rate_limit INTEGER`,
+
+		// Preview metadata fetched from the destination page
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS image_url TEXT NOT NULL DEFAULT ''`,
+
+		// Click-time query strings are appended to the destination when set
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS passthrough_query BOOLEAN NOT NULL DEFAULT false`,
+
+		// Prefixed links only resolve as /prefix/code, '' means the bare code
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS prefix TEXT NOT NULL DEFAULT ''`,
+
+		// Deleted links wait out an undo window before the cleanup worker purges them
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS purge_after TIMESTAMP WITH TIME ZONE`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_purge_after ON urls(purge_after) WHERE purge_after IS NOT NULL`,
+
+		// Per-platform destinations ({"ios": "...", "android": "..."}), '{}' routes everyone to original_url
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS platform_destinations JSONB NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS country_destinations JSONB NOT NULL DEFAULT '{}'`,
+
+		// Where visitors of the link go once it expires, '' follows the server setting
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS fallback_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS source VARCHAR(32) NOT NULL DEFAULT ''`,
+
 		// Click events table for analytics
 		`CREATE TABLE IF NOT EXISTS click_events (
 			id BIGSERIAL PRIMARY KEY,
@@ -100,11 +181,93 @@ func RunMigrations(db *sqlx.DB, logger *zap.Logger) error {
 		// Composite index for common analytics queries
 		`CREATE INDEX IF NOT EXISTS idx_click_events_short_code_created ON click_events(short_code, created_at DESC)`,
 
+		// Distinguishes GeoIP countries from ones inferred from Accept-Language
+		`ALTER TABLE click_events ADD COLUMN IF NOT EXISTS country_source VARCHAR(16)`,
+
+		// Redirects and pixel opens share the table, told apart by type
+		`ALTER TABLE click_events ADD COLUMN IF NOT EXISTS event_type VARCHAR(16) NOT NULL DEFAULT 'redirect'`,
+
+		// Click batches already added to urls.click_count, so a batch replayed
+		// after a crash is not counted twice
+		`CREATE TABLE IF NOT EXISTS click_flushes (
+			batch_id VARCHAR(64) PRIMARY KEY,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+
+		// Audit trail of mutating operations, one row per request
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id BIGSERIAL PRIMARY KEY,
+			action VARCHAR(32) NOT NULL,
+			short_codes TEXT[] NOT NULL DEFAULT '{}',
+			actor VARCHAR(255) NOT NULL DEFAULT '',
+			actor_type VARCHAR(16) NOT NULL,
+			actor_ip VARCHAR(45) NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at DESC)`,
+		// "What happened to this link" looks codes up inside the array
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_short_codes ON audit_log USING GIN (short_codes)`,
+
+		// Where each link pointed over time; rows follow the link's id, not
+		// its code, so a code reused after a purge starts with no history
+		`CREATE TABLE IF NOT EXISTS url_history (
+			id BIGSERIAL PRIMARY KEY,
+			url_id BIGINT NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			previous_url TEXT NOT NULL,
+			original_url TEXT NOT NULL,
+			actor VARCHAR(255) NOT NULL DEFAULT '',
+			actor_type VARCHAR(16) NOT NULL,
+			changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_url_history_url_id ON url_history(url_id, changed_at DESC, id DESC)`,
+
+		// Extra codes of a link; clicks, history and stats stay on the link
+		// row, an alias only leads to it
+		`CREATE TABLE IF NOT EXISTS url_aliases (
+			code VARCHAR(20) PRIMARY KEY,
+			url_id BIGINT NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_url_aliases_url_id ON url_aliases(url_id)`,
+		// A retired code is a link's former code that answers 410 Gone
+		`ALTER TABLE url_aliases ADD COLUMN IF NOT EXISTS retired BOOLEAN NOT NULL DEFAULT false`,
+
+		// A link is its (short_code, prefix) pair; lookups by code alone
+		// can use this index too. Reservations take their code with
+		// ON CONFLICT on it.
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_short_code_prefix ON urls(short_code, prefix)`,
+
+		// The expiry each expired event and reminder was sent for, so a link
+		// given a new expiry is announced again
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMP WITH TIME ZONE`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS expiry_reminded_at TIMESTAMP WITH TIME ZONE`,
+
+		// Clicks on /news/sale and /sports/sale stay apart
+		`ALTER TABLE click_events ADD COLUMN IF NOT EXISTS prefix TEXT NOT NULL DEFAULT ''`,
+
 		// Partitioning setup for click_events (for large scale)
 		// Note: In production, you'd use pg_partman or similar for automatic partition management
 		// This is a simplified example
 	}
+	if opts.CodesPerPrefix {
+		migrations = append(migrations,
+			`ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_short_code_key`,
+		)
+	} else {
+		// Skipped while the original constraint is still in place; after
+		// per-prefix codes were used, fails on codes that are now shared
+		migrations = append(migrations,
+			`CREATE UNIQUE INDEX IF NOT EXISTS urls_short_code_key ON urls(short_code)`,
+		)
+	}
+
+	return ApplyMigrations(db, migrations, logger)
+}
 
+// ApplyMigrations runs idempotent schema statements in order
+// Shared by every SQL backend; each keeps its own statement list because
+// the dialects differ (e.g. SQLite has no ADD COLUMN IF NOT EXISTS)
+func ApplyMigrations(db *sqlx.DB, migrations []string, logger *zap.Logger) error {
 	for i, migration := range migrations {
 		if _, err := db.Exec(migration); err != nil {
 			return fmt.Errorf("failed to run migration %d: %w", i+1, err)
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// ClaimExpiredEvent marks the expiry as announced in the same statement that
+// checks it wasn't, so of two instances seeing the expired link only one
+// gets the row back
+func (r *PostgresURLRepository) ClaimExpiredEvent(ctx context.Context, shortCode string, now time.Time) (*domain.URL, error) {
+	start := time.Now()
+	operation := "claim_expired_event"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	query := `
+		UPDATE urls SET expiry_notified_at = expires_at
+		WHERE short_code = $1 AND prefix = COALESCE($2, prefix) AND reserved_until IS NULL
+		  AND expires_at <= $3 AND expiry_notified_at IS DISTINCT FROM expires_at
+		RETURNING short_code, prefix, original_url, expires_at`
+
+	var url domain.URL
+	err := r.execute(ctx, func() error {
+		return r.db.GetContext(ctx, &url, query, shortCode, CodePrefix(ctx), now)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+	return &url, nil
+}
+
+// ClaimExpiryReminders claims the soonest expiries first; SKIP LOCKED lets
+// instances sweeping at the same time split the batch instead of waiting
+func (r *PostgresURLRepository) ClaimExpiryReminders(ctx context.Context, now, before time.Time, limit int) ([]domain.URL, error) {
+	start := time.Now()
+	operation := "claim_expiry_reminders"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, "")
+	}()
+
+	query := `
+		UPDATE urls SET expiry_reminded_at = expires_at
+		WHERE id IN (
+			SELECT id FROM urls
+			WHERE expires_at > $1 AND expires_at <= $2
+			  AND is_active = true AND reserved_until IS NULL AND purge_after IS NULL
+			  AND expiry_reminded_at IS DISTINCT FROM expires_at
+			ORDER BY expires_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING short_code, prefix, original_url, expires_at`
+
+	var urls []domain.URL
+	err := r.execute(ctx, func() error {
+		urls = nil
+		return r.db.SelectContext(ctx, &urls, query, now, before, limit)
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+	return urls, nil
+}
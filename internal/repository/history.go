@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+)
+
+// UpdateDestination changes the destination and writes the history row in
+// one statement
+// Learning: every part of a data-modifying WITH sees the same snapshot, so
+// "target" still holds the old destination after "updated" changed it
+func (r *PostgresURLRepository) UpdateDestination(ctx context.Context, entry *domain.URLHistoryEntry) error {
+	start := time.Now()
+	operation := "update_destination"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, entry.ShortCode)
+	}()
+
+	r.replicas.pin(entry.ShortCode)
+
+	query := `
+	WITH target AS (
+		SELECT id, original_url FROM urls
+		WHERE short_code = $1 AND prefix = COALESCE($6, prefix) AND reserved_until IS NULL AND purge_after IS NULL
+		FOR UPDATE
+	), updated AS (
+		UPDATE urls SET original_url = $2, updated_at = $3
+		FROM target WHERE urls.id = target.id
+		RETURNING urls.id
+	)
+	INSERT INTO url_history (url_id, previous_url, original_url, actor, actor_type, changed_at)
+	SELECT target.id, target.original_url, $2, $4, $5, $3
+	FROM target JOIN updated ON updated.id = target.id
+	RETURNING id, previous_url`
+
+	err := r.execute(ctx, func() error {
+		return r.db.QueryRowContext(ctx, query,
+			entry.ShortCode, entry.OriginalURL, entry.ChangedAt, entry.Actor, entry.ActorType, CodePrefix(ctx),
+		).Scan(&entry.ID, &entry.PreviousURL)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrURLNotFound
+	}
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresURLRepository) ListHistory(ctx context.Context, shortCode string, page pagination.Request) ([]domain.URLHistoryEntry, error) {
+	start := time.Now()
+	operation := "list_history"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	columns := `
+		SELECT h.id, u.short_code, h.previous_url, h.original_url, h.actor, h.actor_type, h.changed_at
+		FROM url_history h JOIN urls u ON u.id = h.url_id`
+
+	var (
+		query string
+		args  []interface{}
+	)
+	if page.After != nil {
+		query = columns + `
+		WHERE u.short_code = $1 AND u.prefix = COALESCE($5, u.prefix) AND (h.changed_at, h.id) < ($2, $3)
+		ORDER BY h.changed_at DESC, h.id DESC
+		LIMIT $4`
+		args = []interface{}{shortCode, page.After.CreatedAt, page.After.ID, page.Limit + 1, CodePrefix(ctx)}
+	} else {
+		query = columns + `
+		WHERE u.short_code = $1 AND u.prefix = COALESCE($4, u.prefix)
+		ORDER BY h.changed_at DESC, h.id DESC
+		LIMIT $2 OFFSET $3`
+		args = []interface{}{shortCode, page.Limit + 1, page.Offset, CodePrefix(ctx)}
+	}
+
+	entries := make([]domain.URLHistoryEntry, 0, page.Limit+1)
+	err := r.execute(ctx, func() error {
+		return r.db.SelectContext(ctx, &entries, query, args...)
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// HotKeyConfig replicates the cache entries of very popular links across
+// several keys, so their reads don't all land on one Redis node
+//
+// Learning: in Redis Cluster a key lives in exactly one slot, so one viral
+// link puts its whole redirect load on one node however many there are.
+// Copies under "url:abc123:0" .. "url:abc123:7" hash to different slots and
+// split that load. The keys deliberately have no {hash tag}, which would pin
+// every copy to the same slot again.
+type HotKeyConfig struct {
+	// Shards is the number of copies of a hot entry, 0 or 1 disables sharding
+	Shards int
+	// Threshold is the reads per Window, on this instance, that make a key
+	// hot; it stays hot until a whole Window passes below the threshold
+	Threshold int
+	Window    time.Duration
+}
+
+// hotKeys counts reads per short code in fixed windows
+// Counts are per instance: each instance decides from its own traffic, which
+// is what it needs to spread its own reads.
+type hotKeys struct {
+	shards    int
+	threshold int64
+	window    time.Duration
+	now       func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[string]int64
+	hot         map[string]bool
+
+	next atomic.Uint32 // spreads reads that carry no client IP
+}
+
+func newHotKeys(cfg HotKeyConfig) *hotKeys {
+	if cfg.Shards <= 1 {
+		return nil
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 1000
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	return &hotKeys{
+		shards:    cfg.Shards,
+		threshold: int64(cfg.Threshold),
+		window:    cfg.Window,
+		now:       time.Now,
+		counts:    make(map[string]int64),
+		hot:       make(map[string]bool),
+	}
+}
+
+// observe counts a read of code and reports whether it is hot, and whether
+// this read is the one that made it so
+func (h *hotKeys) observe(code string) (hot, promoted bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.roll()
+	h.counts[code]++
+	if h.hot[code] {
+		return true, false
+	}
+	if h.counts[code] >= h.threshold {
+		h.hot[code] = true
+		return true, true
+	}
+	return false, false
+}
+
+func (h *hotKeys) isHot(code string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.roll()
+	return h.hot[code]
+}
+
+// roll starts a new window once the current one is over: keys that reached
+// the threshold in it stay hot, the rest are demoted
+func (h *hotKeys) roll() {
+	now := h.now()
+	elapsed := now.Sub(h.windowStart)
+	if elapsed < h.window {
+		return
+	}
+
+	hot := make(map[string]bool)
+	if elapsed < 2*h.window {
+		for code, n := range h.counts {
+			if n >= h.threshold {
+				hot[code] = true
+			}
+		}
+	}
+	h.hot = hot
+	h.counts = make(map[string]int64)
+	h.windowStart = now
+}
+
+// shardFor picks the copy a read uses: the same client keeps hitting the
+// same copy, and different clients spread over all of them
+func (h *hotKeys) shardFor(ctx context.Context) int {
+	ip := domain.ClientIPFrom(ctx)
+	if ip == "" {
+		return int(h.next.Add(1) % uint32(h.shards))
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(ip))
+	return int(hash.Sum32() % uint32(h.shards))
+}
+
+func shardKey(key string, shard int) string {
+	return key + ":" + strconv.Itoa(shard)
+}
+
+// shardKeys lists every copy of key, for writes and evictions
+func (h *hotKeys) shardKeys(key string) []string {
+	keys := make([]string, h.shards)
+	for i := range keys {
+		keys[i] = shardKey(key, i)
+	}
+	return keys
+}
+
+// read fetches key for code, from a shard copy when code is hot
+func (r *RedisCacheRepository) read(ctx context.Context, code, key string) ([]byte, error) {
+	// Learning: a cache read sits in front of every redirect, so a
+	// struggling Redis gets a short deadline and the redirect goes to the DB
+	// instead of waiting out the client's read timeout. The deadline covers
+	// the client's retries too, it doesn't retry a context timeout.
+	if r.getTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.getTimeout)
+		defer cancel()
+	}
+
+	if r.hot == nil {
+		return r.readKey(ctx, key)
+	}
+
+	hot, promoted := r.hot.observe(code)
+	if !hot {
+		return r.readKey(ctx, key)
+	}
+	if !promoted {
+		data, err := r.readKey(ctx, shardKey(key, r.hot.shardFor(ctx)))
+		if !errors.Is(err, redis.Nil) {
+			return data, err
+		}
+		// The copy expired or was never written (another instance promoted
+		// the key first), rebuild the copies from the primary entry
+	}
+	return r.replicate(ctx, key)
+}
+
+func (r *RedisCacheRepository) readKey(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := r.execute(ctx, func() error {
+		var err error
+		data, err = r.client.Get(ctx, key).Bytes()
+		return err
+	})
+	return data, err
+}
+
+// replicate copies the entry at key to every shard with its remaining TTL
+// and returns it; redis.Nil when there is nothing to copy. A failed copy only
+// costs load spreading, so it is counted but the entry is still returned.
+func (r *RedisCacheRepository) replicate(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	var ttl time.Duration
+	err := r.execute(ctx, func() error {
+		var get *redis.StringCmd
+		var pttl *redis.DurationCmd
+		_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			get = pipe.Get(ctx, key)
+			pttl = pipe.PTTL(ctx, key)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		data, _ = get.Bytes()
+		ttl = pttl.Val()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ttl < 0 {
+		// -1 is an entry without expiry, kept as such by a 0 TTL
+		ttl = 0
+	}
+
+	err = r.execute(ctx, func() error {
+		_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, shard := range r.hot.shardKeys(key) {
+				pipe.Set(ctx, shard, data, ttl)
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("replicate_hot_key").Inc()
+	} else {
+		r.metrics.CacheHotKeyPromotionsTotal.Inc()
+	}
+	return data, nil
+}
+
+// write stores value at key, and at every shard copy when code is hot
+// For a cold code the copies are deleted instead: another instance may still
+// consider it hot, and must find no copy rather than a stale one.
+func (r *RedisCacheRepository) write(ctx context.Context, code, key string, value interface{}, ttl time.Duration) error {
+	if r.hot == nil {
+		return r.execute(ctx, func() error {
+			return r.client.Set(ctx, key, value, ttl).Err()
+		})
+	}
+
+	hot := r.hot.isHot(code)
+	return r.execute(ctx, func() error {
+		_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, value, ttl)
+			for _, shard := range r.hot.shardKeys(key) {
+				if hot {
+					pipe.Set(ctx, shard, value, ttl)
+				} else {
+					pipe.Del(ctx, shard)
+				}
+			}
+			return nil
+		})
+		return err
+	})
+}
+
+// evictionKeys are all keys holding an entry for code
+// Shard copies are always included, whether or not code is hot right now.
+func (r *RedisCacheRepository) evictionKeys(code string) []string {
+	keys := []string{r.urlKey(code), r.destKey(code)}
+	if r.hot != nil {
+		keys = append(keys, r.hot.shardKeys(r.urlKey(code))...)
+		keys = append(keys, r.hot.shardKeys(r.destKey(code))...)
+	}
+	return keys
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// DefaultClickShards is how many shards a LocalClickCounter splits codes over
+const DefaultClickShards = 32
+
+type LocalClickCounterConfig struct {
+	Interval time.Duration
+	Shards   int
+}
+
+// LocalClickCounter aggregates redirect clicks in process memory and flushes
+// the totals to a ClickSink in the background, so a burst of clicks on one
+// link is a single UPDATE per flush instead of one per click.
+//
+// Learning: codes are hashed over shards, each with its own lock, and the
+// per-code counters are atomics. Incr only takes a shard's read lock, so
+// clicks on the same shard don't queue behind each other; the write lock is
+// only needed to add a new code or to swap the shard out during a flush,
+// which waits for in-flight increments and so never loses one.
+//
+// Unlike RedisClickCounter the buffer dies with the process: counts survive a
+// graceful shutdown (Flush after the server stops) but not a crash.
+type LocalClickCounter struct {
+	shards  []*clickShard
+	sink    ClickSink
+	cfg     LocalClickCounterConfig
+	metrics *metrics.Metrics
+	logger  *zap.Logger
+
+	// flushMu serializes flushes. pending is the batch being applied, kept
+	// after a failure and retried under the same ID so a commit we never
+	// heard back about isn't counted twice; pendingMu lets Buffered read it
+	// without waiting on the sink
+	flushMu   sync.Mutex
+	pendingMu sync.Mutex
+	pending   *localClickBatch
+	lastFlush time.Time
+}
+
+type clickShard struct {
+	mu     sync.RWMutex
+	counts map[string]*atomic.Int64
+}
+
+type localClickBatch struct {
+	id     string
+	counts map[string]int64
+}
+
+func NewLocalClickCounter(sink ClickSink, cfg LocalClickCounterConfig, m *metrics.Metrics, logger *zap.Logger) *LocalClickCounter {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.Shards <= 0 {
+		cfg.Shards = DefaultClickShards
+	}
+	shards := make([]*clickShard, cfg.Shards)
+	for i := range shards {
+		shards[i] = &clickShard{counts: make(map[string]*atomic.Int64)}
+	}
+	return &LocalClickCounter{
+		shards:    shards,
+		sink:      sink,
+		cfg:       cfg,
+		metrics:   m,
+		logger:    logger,
+		lastFlush: time.Now(),
+	}
+}
+
+func (c *LocalClickCounter) shard(shortCode string) *clickShard {
+	h := fnv.New32a()
+	h.Write([]byte(shortCode))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
+func (c *LocalClickCounter) Incr(ctx context.Context, shortCode string) error {
+	c.add(shortCode, 1)
+	return nil
+}
+
+func (c *LocalClickCounter) add(shortCode string, n int64) {
+	s := c.shard(shortCode)
+
+	s.mu.RLock()
+	if counter, ok := s.counts[shortCode]; ok {
+		counter.Add(n)
+		s.mu.RUnlock()
+		return
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	counter, ok := s.counts[shortCode]
+	if !ok {
+		counter = new(atomic.Int64)
+		s.counts[shortCode] = counter
+	}
+	counter.Add(n)
+	s.mu.Unlock()
+}
+
+// Run flushes every interval until ctx is cancelled
+// It makes no final flush: clicks keep arriving until the HTTP server has
+// shut down, so the caller flushes once after that.
+func (c *LocalClickCounter) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				c.logger.Warn("click flush failed, counts stay buffered in memory", zap.Error(err))
+			}
+			c.metrics.ClickFlushLagSeconds.Set(time.Since(c.lastFlush).Seconds())
+		}
+	}
+}
+
+// Flush applies a previously failed batch, then everything counted since
+func (c *LocalClickCounter) Flush(ctx context.Context) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	if c.pending != nil {
+		if err := c.apply(ctx, c.pending); err != nil {
+			return err
+		}
+	}
+
+	counts := c.drain()
+	if len(counts) > 0 {
+		batchID, err := newBatchID()
+		if err != nil {
+			c.merge(counts)
+			return err
+		}
+		c.setPending(&localClickBatch{id: batchID, counts: counts})
+		if err := c.apply(ctx, c.pending); err != nil {
+			return err
+		}
+	}
+
+	c.lastFlush = time.Now()
+	return nil
+}
+
+func (c *LocalClickCounter) apply(ctx context.Context, batch *localClickBatch) error {
+	if err := c.sink.ApplyClickBatch(ctx, batch.id, batch.counts); err != nil {
+		return err
+	}
+	c.setPending(nil)
+
+	var total int64
+	for _, n := range batch.counts {
+		total += n
+	}
+	c.metrics.ClicksFlushedTotal.Add(float64(total))
+	return nil
+}
+
+func (c *LocalClickCounter) setPending(batch *localClickBatch) {
+	c.pendingMu.Lock()
+	c.pending = batch
+	c.pendingMu.Unlock()
+}
+
+// drain swaps every shard for an empty one and returns what they held
+func (c *LocalClickCounter) drain() map[string]int64 {
+	counts := make(map[string]int64)
+	for _, s := range c.shards {
+		s.mu.Lock()
+		held := s.counts
+		s.counts = make(map[string]*atomic.Int64, len(held))
+		s.mu.Unlock()
+
+		for code, counter := range held {
+			if n := counter.Load(); n > 0 {
+				counts[code] += n
+			}
+		}
+	}
+	return counts
+}
+
+func (c *LocalClickCounter) merge(counts map[string]int64) {
+	for code, n := range counts {
+		c.add(code, n)
+	}
+}
+
+// Buffered returns the clicks per code not yet applied to the sink,
+// including a batch being applied or waiting to be retried
+func (c *LocalClickCounter) Buffered() map[string]int64 {
+	counts := make(map[string]int64)
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for code, counter := range s.counts {
+			if n := counter.Load(); n > 0 {
+				counts[code] += n
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	c.pendingMu.Lock()
+	if c.pending != nil {
+		for code, n := range c.pending.counts {
+			counts[code] += n
+		}
+	}
+	c.pendingMu.Unlock()
+	return counts
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+func newTestLocalClickCounter(sink ClickSink, shards int) (*LocalClickCounter, *metrics.Metrics) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	return NewLocalClickCounter(sink, LocalClickCounterConfig{Shards: shards}, m, zap.NewNop()), m
+}
+
+func TestLocalClickCounterConcurrentIncrements(t *testing.T) {
+	sink := newFakeClickSink()
+	// Fewer shards than codes, so codes share shards and locks
+	counter, m := newTestLocalClickCounter(sink, 4)
+	ctx := context.Background()
+
+	const goroutines, perGoroutine, codes = 64, 2000, 10
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				counter.Incr(ctx, fmt.Sprintf("code%d", (g+i)%codes))
+			}
+		}(g)
+	}
+
+	// Flushing while increments land must not lose or double any of them
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for flushing := true; flushing; {
+		select {
+		case <-done:
+			flushing = false
+		default:
+			if err := counter.Flush(ctx); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+		}
+	}
+	if err := counter.Flush(ctx); err != nil {
+		t.Fatalf("final Flush() error = %v", err)
+	}
+
+	var total int64
+	for code, n := range sink.counts {
+		if n != goroutines*perGoroutine/codes {
+			t.Errorf("%s flushed %d clicks, want %d", code, n, goroutines*perGoroutine/codes)
+		}
+		total += n
+	}
+	if total != goroutines*perGoroutine {
+		t.Errorf("flushed %d clicks, want %d", total, goroutines*perGoroutine)
+	}
+	if got := testutil.ToFloat64(m.ClicksFlushedTotal); got != goroutines*perGoroutine {
+		t.Errorf("clicks_flushed_total = %v, want %d", got, goroutines*perGoroutine)
+	}
+	if left := counter.Buffered(); len(left) != 0 {
+		t.Errorf("Buffered() after final flush = %v, want empty", left)
+	}
+}
+
+func TestLocalClickCounterKeepsFailedBatch(t *testing.T) {
+	sink := newFakeClickSink()
+	counter, _ := newTestLocalClickCounter(sink, 0)
+	ctx := context.Background()
+
+	counter.Incr(ctx, "abc")
+	counter.Incr(ctx, "abc")
+	sink.err = errors.New("database is down")
+	if err := counter.Flush(ctx); err == nil {
+		t.Fatal("Flush() with a failing sink returned nil")
+	}
+	counter.Incr(ctx, "abc")
+	if got := counter.Buffered()["abc"]; got != 3 {
+		t.Errorf("Buffered()[abc] after a failed flush = %d, want 3", got)
+	}
+
+	// The commit goes through but the reply is lost: the retry reuses the
+	// batch ID, so the sink doesn't count it twice
+	sink.err = nil
+	sink.failAfterApply = true
+	if err := counter.Flush(ctx); err == nil {
+		t.Fatal("Flush() with a lost reply returned nil")
+	}
+	if err := counter.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := sink.counts["abc"]; got != 3 {
+		t.Errorf("sink counts[abc] = %d, want 3", got)
+	}
+	if left := counter.Buffered(); len(left) != 0 {
+		t.Errorf("Buffered() = %v, want empty", left)
+	}
+}
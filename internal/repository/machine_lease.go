@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const machineLeasePrefix = "machine:"
+
+// ErrMachineIDInUse means another running instance holds the machine ID
+var ErrMachineIDInUse = errors.New("machine id is already in use by another instance")
+
+// Only the holder (same token) may extend or drop a lease
+var (
+	refreshLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+type MachineLeaseConfig struct {
+	// TTL is how long a lease outlives a crashed holder; it is refreshed
+	// every TTL/3 while the instance runs
+	TTL time.Duration
+
+	// AutoAssign picks the next free ID in [0, MaxID] when the requested one
+	// is taken, instead of refusing to start
+	AutoAssign bool
+	MaxID      int64
+
+	// KeyPrefix namespaces the lease keys, as RedisCacheOptions.KeyPrefix:
+	// deployments sharing a Redis may then reuse each other's machine IDs
+	KeyPrefix string
+}
+
+// MachineLease holds a snowflake machine ID exclusively across the fleet
+//
+// Two instances sharing a machine ID produce identical IDs whenever they
+// generate in the same millisecond, and nothing notices until inserts start
+// colliding. Leasing "machine:{id}" in Redis at startup turns that silent
+// misconfiguration into a startup failure (or a reassignment).
+type MachineLease struct {
+	client *redis.Client
+	cfg    MachineLeaseConfig
+	id     int64
+	token  string
+	logger *zap.Logger
+}
+
+// AcquireMachineID leases preferred, or with AutoAssign the first free ID after it
+func AcquireMachineID(ctx context.Context, client *redis.Client, preferred int64, cfg MachineLeaseConfig, logger *zap.Logger) (*MachineLease, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 30 * time.Second
+	}
+	if cfg.MaxID <= 0 || cfg.MaxID < preferred {
+		cfg.MaxID = preferred
+	}
+
+	token, err := leaseToken()
+	if err != nil {
+		return nil, err
+	}
+	lease := &MachineLease{client: client, cfg: cfg, token: token, logger: logger}
+
+	candidates := cfg.MaxID + 1
+	if !cfg.AutoAssign {
+		candidates = 1
+	}
+	for i := int64(0); i < candidates; i++ {
+		id := (preferred + i) % (cfg.MaxID + 1)
+		ok, err := client.SetNX(ctx, lease.key(id), token, cfg.TTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to lease machine id %d: %w", id, err)
+		}
+		if !ok {
+			logger.Warn("machine id already leased by another instance", zap.Int64("machine_id", id))
+			continue
+		}
+
+		lease.id = id
+		if id != preferred {
+			logger.Warn("machine id reassigned, the configured one is in use",
+				zap.Int64("configured", preferred),
+				zap.Int64("machine_id", id),
+			)
+		} else {
+			logger.Info("machine id leased", zap.Int64("machine_id", id))
+		}
+		return lease, nil
+	}
+
+	if cfg.AutoAssign {
+		return nil, fmt.Errorf("%w: no free id in [0, %d]", ErrMachineIDInUse, cfg.MaxID)
+	}
+	return nil, fmt.Errorf("%w: %d", ErrMachineIDInUse, preferred)
+}
+
+// ID is the leased machine ID
+func (l *MachineLease) ID() int64 {
+	return l.id
+}
+
+// Run refreshes the lease until ctx is cancelled
+// A lost lease (e.g. Redis was down longer than TTL and someone else took the
+// ID) can't be fixed while running, so it is logged loudly for operators
+func (l *MachineLease) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.cfg.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			held, err := l.refresh(ctx)
+			if err != nil {
+				l.logger.Warn("failed to refresh machine id lease", zap.Error(err), zap.Int64("machine_id", l.id))
+				continue
+			}
+			if !held {
+				l.logger.Error("machine id lease lost, generated ids may collide with another instance",
+					zap.Int64("machine_id", l.id))
+			}
+		}
+	}
+}
+
+func (l *MachineLease) refresh(ctx context.Context) (bool, error) {
+	n, err := refreshLeaseScript.Run(ctx, l.client, []string{l.key(l.id)}, l.token, l.cfg.TTL.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// Release frees the ID for the next instance right away instead of after TTL
+func (l *MachineLease) Release(ctx context.Context) error {
+	return releaseLeaseScript.Run(ctx, l.client, []string{l.key(l.id)}, l.token).Err()
+}
+
+func (l *MachineLease) key(id int64) string {
+	return l.cfg.KeyPrefix + machineLeasePrefix + strconv.FormatInt(id, 10)
+}
+
+// leaseToken identifies this process as the holder
+func leaseToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestAcquireMachineIDRefusesHeldID(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	cfg := MachineLeaseConfig{TTL: 30 * time.Second, MaxID: 1023}
+
+	first, err := AcquireMachineID(ctx, client, 5, cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("first instance: %v", err)
+	}
+
+	// A second instance configured with the same MACHINE_ID must not start
+	if _, err := AcquireMachineID(ctx, client, 5, cfg, zap.NewNop()); !errors.Is(err, ErrMachineIDInUse) {
+		t.Fatalf("duplicate machine id: err = %v, want ErrMachineIDInUse", err)
+	}
+
+	// Unless it may pick another one
+	cfg.AutoAssign = true
+	second, err := AcquireMachineID(ctx, client, 5, cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("auto-assign: %v", err)
+	}
+	if second.ID() != 6 {
+		t.Errorf("auto-assigned id = %d, want the next free one (6)", second.ID())
+	}
+
+	// Releasing frees the id at once, and only the holder can release it
+	if err := second.Release(ctx); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if !mr.Exists("machine:5") || mr.Exists("machine:6") {
+		t.Error("release dropped the wrong lease")
+	}
+	if err := first.Release(ctx); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	cfg.AutoAssign = false
+	if _, err := AcquireMachineID(ctx, client, 5, cfg, zap.NewNop()); err != nil {
+		t.Errorf("released id could not be leased again: %v", err)
+	}
+}
+
+func TestMachineLeaseExpiresAndRefreshes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	cfg := MachineLeaseConfig{TTL: 30 * time.Second}
+
+	lease, err := AcquireMachineID(ctx, client, 3, cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("AcquireMachineID: %v", err)
+	}
+
+	mr.FastForward(20 * time.Second)
+	if held, err := lease.refresh(ctx); err != nil || !held {
+		t.Fatalf("refresh = %v, %v; want held", held, err)
+	}
+	mr.FastForward(20 * time.Second)
+	if !mr.Exists("machine:3") {
+		t.Fatal("refreshed lease expired")
+	}
+
+	// A crashed holder stops refreshing, the id frees up after TTL
+	mr.FastForward(31 * time.Second)
+	if _, err := AcquireMachineID(ctx, client, 3, cfg, zap.NewNop()); err != nil {
+		t.Fatalf("expired lease still blocks the id: %v", err)
+	}
+	if held, _ := lease.refresh(ctx); held {
+		t.Error("refresh reclaimed a lease now held by someone else")
+	}
+}
@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/sony/gobreaker"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/chaos"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+)
+
+// MemcachedCacheRepository is the CacheRepository for shops running
+// Memcached instead of Redis, selected with CACHE_BACKEND=memcached
+// Entries and keys are the ones RedisCacheRepository writes ("url:",
+// "dest:", "claim:" under the key prefix), so the two read the same way.
+// Hot key sharding is Redis-only, Memcached clients already spread keys.
+type MemcachedCacheRepository struct {
+	client     MemcachedClient
+	reads      MemcachedClient
+	defaultTTL time.Duration
+	metrics    *metrics.Metrics
+	breaker    *gobreaker.CircuitBreaker // optional, nil disables it
+
+	serializer CacheSerializer
+	keyPrefix  string
+	faults     chaos.Fault
+}
+
+// MemcachedClient is the part of *memcache.Client the cache uses
+type MemcachedClient interface {
+	Get(key string) (*memcache.Item, error)
+	Set(item *memcache.Item) error
+	Add(item *memcache.Item) error
+	Delete(key string) error
+}
+
+// maxRelativeExpiration is the longest expiry Memcached reads as "seconds
+// from now"; anything longer it reads as a unix timestamp
+const maxRelativeExpiration = 30 * 24 * time.Hour
+
+// MemcachedCacheOptions are the optional knobs of MemcachedCacheRepository
+// The zero value is JSON entries under the bare "url:" keys
+type MemcachedCacheOptions struct {
+	Serializer CacheSerializer
+
+	// KeyPrefix namespaces every cache key, as RedisCacheOptions.KeyPrefix
+	KeyPrefix string
+
+	// Faults are injected into every cache call in a -tags chaos build
+	Faults chaos.Fault
+
+	// Reads serves cache reads, nil reads through the main client.
+	// gomemcache calls take no context, so a short read timeout is a second
+	// client with a shorter Timeout (MEMCACHED_GET_TIMEOUT)
+	Reads MemcachedClient
+}
+
+func NewMemcachedCacheRepository(client MemcachedClient, defaultTTL time.Duration, m *metrics.Metrics, cb *gobreaker.CircuitBreaker, opts MemcachedCacheOptions) *MemcachedCacheRepository {
+	if opts.Serializer == nil {
+		opts.Serializer = jsonSerializer{}
+	}
+	if opts.Reads == nil {
+		opts.Reads = client
+	}
+	return &MemcachedCacheRepository{
+		client:     client,
+		reads:      opts.Reads,
+		defaultTTL: defaultTTL,
+		metrics:    m,
+		breaker:    cb,
+		serializer: opts.Serializer,
+		keyPrefix:  opts.KeyPrefix,
+		faults:     opts.Faults,
+	}
+}
+
+func (r *MemcachedCacheRepository) urlKey(shortCode string) string {
+	return r.keyPrefix + urlCachePrefix + shortCode
+}
+
+func (r *MemcachedCacheRepository) destKey(shortCode string) string {
+	return r.keyPrefix + destCachePrefix + shortCode
+}
+
+func (r *MemcachedCacheRepository) claimKey(shortCode string) string {
+	return r.keyPrefix + aliasClaimPrefix + shortCode
+}
+
+// IsMemcachedBreakerSuccess is IsCacheBreakerSuccess for Memcached: a miss
+// or a refused add means the server answered
+func IsMemcachedBreakerSuccess(err error) bool {
+	return err == nil || errors.Is(err, memcache.ErrCacheMiss) || errors.Is(err, memcache.ErrNotStored)
+}
+
+// execute runs fn through the circuit breaker when one is configured
+func (r *MemcachedCacheRepository) execute(ctx context.Context, fn func() error) error {
+	if r.breaker == nil {
+		return r.faulty(ctx, fn)
+	}
+	_, err := r.breaker.Execute(func() (interface{}, error) {
+		return nil, r.faulty(ctx, fn)
+	})
+	return err
+}
+
+func (r *MemcachedCacheRepository) faulty(ctx context.Context, fn func() error) error {
+	if err := r.faults.Inject(ctx); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// expiration converts a TTL to Memcached's exptime field
+func expiration(ttl time.Duration) int32 {
+	switch {
+	case ttl <= 0:
+		return 0
+	case ttl > maxRelativeExpiration:
+		return int32(time.Now().Add(ttl).Unix())
+	default:
+		// Rounded up, a sub-second TTL must not become "never expires"
+		return int32((ttl + time.Second - 1) / time.Second)
+	}
+}
+
+// read fetches key through the read client, memcache.ErrCacheMiss on a miss
+func (r *MemcachedCacheRepository) read(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	err := r.execute(ctx, func() error {
+		item, err := r.reads.Get(key)
+		if err != nil {
+			return err
+		}
+		data = item.Value
+		return nil
+	})
+	return data, err
+}
+
+// evict deletes key, an already missing key is what was wanted
+func (r *MemcachedCacheRepository) evict(ctx context.Context, key string) error {
+	err := r.execute(ctx, func() error {
+		return r.client.Delete(key)
+	})
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil
+	}
+	return err
+}
+
+func (r *MemcachedCacheRepository) Get(ctx context.Context, shortCode string) (*domain.URL, error) {
+	operation := "get"
+
+	data, err := r.read(ctx, r.urlKey(shortCode))
+	if err != nil {
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			r.metrics.CacheMissesTotal.WithLabelValues(operation).Inc()
+			return nil, nil
+		}
+		r.metrics.CacheErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+
+	var url domain.URL
+	if err := r.serializer.Unmarshal(data, &url); err != nil {
+		r.metrics.CacheErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+	r.metrics.CacheHitsTotal.WithLabelValues(operation).Inc()
+	return &url, nil
+}
+
+func (r *MemcachedCacheRepository) Set(ctx context.Context, url *domain.URL, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = r.defaultTTL
+	}
+
+	data, err := r.serializer.Marshal(url)
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("set").Inc()
+		return err
+	}
+	err = r.execute(ctx, func() error {
+		return r.client.Set(&memcache.Item{Key: r.urlKey(url.ShortURL), Value: data, Expiration: expiration(ttl)})
+	})
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("set").Inc()
+	}
+	return err
+}
+
+// GetDestination reads the compact redirect entry, (nil, nil) on a miss
+func (r *MemcachedCacheRepository) GetDestination(ctx context.Context, shortCode string) (*domain.Destination, error) {
+	operation := "get_destination"
+
+	data, err := r.read(ctx, r.destKey(shortCode))
+	if err != nil {
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			r.metrics.CacheMissesTotal.WithLabelValues(operation).Inc()
+			return nil, nil
+		}
+		r.metrics.CacheErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+
+	dest, err := decodeDestination(string(data))
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+	r.metrics.CacheHitsTotal.WithLabelValues(operation).Inc()
+	return dest, nil
+}
+
+func (r *MemcachedCacheRepository) SetDestination(ctx context.Context, shortCode string, dest domain.Destination, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = r.defaultTTL
+	}
+
+	err := r.execute(ctx, func() error {
+		return r.client.Set(&memcache.Item{Key: r.destKey(shortCode), Value: []byte(encodeDestination(dest)), Expiration: expiration(ttl)})
+	})
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("set_destination").Inc()
+	}
+	return err
+}
+
+// Delete evicts both the full and the compact entry for shortCode
+// Memcached has no multi-key delete, so that is two round trips.
+func (r *MemcachedCacheRepository) Delete(ctx context.Context, shortCode string) error {
+	err := r.evict(ctx, r.urlKey(shortCode))
+	if destErr := r.evict(ctx, r.destKey(shortCode)); err == nil {
+		err = destErr
+	}
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("delete").Inc()
+	}
+	return err
+}
+
+// DeleteMany evicts every code in turn; gomemcache has no pipelining, so a bulk eviction costs a round trip per key
+func (r *MemcachedCacheRepository) DeleteMany(ctx context.Context, shortCodes []string) error {
+	var failed []string
+	var firstErr error
+	for _, code := range shortCodes {
+		err := r.evict(ctx, r.urlKey(code))
+		if destErr := r.evict(ctx, r.destKey(code)); err == nil {
+			err = destErr
+		}
+		if err != nil {
+			failed = append(failed, code)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if len(failed) > 0 {
+		r.metrics.CacheErrors.WithLabelValues("delete_many").Add(float64(len(failed)))
+		return fmt.Errorf("failed to evict %d of %d cache keys %v: %w", len(failed), len(shortCodes), failed, firstErr)
+	}
+	return nil
+}
+
+// Exists is a get: Memcached has no command that only checks for a key
+func (r *MemcachedCacheRepository) Exists(ctx context.Context, shortCode string) (bool, error) {
+	_, err := r.read(ctx, r.urlKey(shortCode))
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("exists").Inc()
+		return false, err
+	}
+	return true, nil
+}
+
+// ClaimAlias is add claim:<code>, which like SET NX only stores a free key
+func (r *MemcachedCacheRepository) ClaimAlias(ctx context.Context, code string, ttl time.Duration) (bool, error) {
+	err := r.execute(ctx, func() error {
+		return r.client.Add(&memcache.Item{Key: r.claimKey(code), Value: []byte("1"), Expiration: expiration(ttl)})
+	})
+	if errors.Is(err, memcache.ErrNotStored) {
+		return false, nil
+	}
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("claim_alias").Inc()
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *MemcachedCacheRepository) ReleaseAlias(ctx context.Context, code string) error {
+	err := r.evict(ctx, r.claimKey(code))
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("release_alias").Inc()
+	}
+	return err
+}
@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"go.uber.org/zap"
+)
+
+// fakeMemcached is an in-process MemcachedClient with gomemcache's
+// semantics: relative expirations in seconds and ErrCacheMiss/ErrNotStored
+type fakeMemcached struct {
+	mu    sync.Mutex
+	now   time.Time
+	items map[string]fakeMemcachedItem
+	down  bool
+}
+
+type fakeMemcachedItem struct {
+	value     []byte
+	expiresAt time.Time // zero never expires
+}
+
+func newFakeMemcached() *fakeMemcached {
+	return &fakeMemcached{now: time.Now(), items: make(map[string]fakeMemcachedItem)}
+}
+
+var errFakeMemcachedDown = errors.New("memcache: connection refused")
+
+func (f *fakeMemcached) live(key string) (fakeMemcachedItem, bool) {
+	item, ok := f.items[key]
+	if ok && !item.expiresAt.IsZero() && !f.now.Before(item.expiresAt) {
+		delete(f.items, key)
+		return item, false
+	}
+	return item, ok
+}
+
+func (f *fakeMemcached) store(item *memcache.Item) {
+	stored := fakeMemcachedItem{value: append([]byte(nil), item.Value...)}
+	if item.Expiration > 0 {
+		stored.expiresAt = f.now.Add(time.Duration(item.Expiration) * time.Second)
+	}
+	f.items[item.Key] = stored
+}
+
+func (f *fakeMemcached) Get(key string) (*memcache.Item, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return nil, errFakeMemcachedDown
+	}
+	item, ok := f.live(key)
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	return &memcache.Item{Key: key, Value: item.value}, nil
+}
+
+func (f *fakeMemcached) Set(item *memcache.Item) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errFakeMemcachedDown
+	}
+	f.store(item)
+	return nil
+}
+
+func (f *fakeMemcached) Add(item *memcache.Item) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errFakeMemcachedDown
+	}
+	if _, ok := f.live(item.Key); ok {
+		return memcache.ErrNotStored
+	}
+	f.store(item)
+	return nil
+}
+
+func (f *fakeMemcached) Delete(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errFakeMemcachedDown
+	}
+	if _, ok := f.live(key); !ok {
+		return memcache.ErrCacheMiss
+	}
+	delete(f.items, key)
+	return nil
+}
+
+func (f *fakeMemcached) Exists(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.live(key)
+	return ok
+}
+
+// TTL is the time key has left, 0 if it is missing or never expires
+func (f *fakeMemcached) TTL(key string) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	item, ok := f.live(key)
+	if !ok || item.expiresAt.IsZero() {
+		return 0
+	}
+	return item.expiresAt.Sub(f.now)
+}
+
+func (f *fakeMemcached) FastForward(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Close takes the fake down, every later call fails as a refused connection
+func (f *fakeMemcached) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = true
+}
+
+func newMemcachedTestRepo(t *testing.T, srv *fakeMemcached, opts MemcachedCacheOptions) (*MemcachedCacheRepository, *metrics.Metrics) {
+	t.Helper()
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	return NewMemcachedCacheRepository(srv, time.Hour, m, nil, opts), m
+}
+
+func TestMemcachedCacheRepository(t *testing.T) {
+	srv := newFakeMemcached()
+	repo, m := newMemcachedTestRepo(t, srv, MemcachedCacheOptions{KeyPrefix: "tenant-a:"})
+	ctx := context.Background()
+
+	if url, err := repo.Get(ctx, "abc123"); err != nil || url != nil {
+		t.Fatalf("Get() of a missing code = %v, %v; want a miss", url, err)
+	}
+	if ok, err := repo.Exists(ctx, "abc123"); err != nil || ok {
+		t.Errorf("Exists() of a missing code = %v, %v; want false", ok, err)
+	}
+
+	if err := repo.Set(ctx, &domain.URL{ShortURL: "abc123", OriginalURL: "https://example.com"}, 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if ttl := srv.TTL("tenant-a:url:abc123"); ttl != time.Hour {
+		t.Errorf("TTL = %v, want the 1h default", ttl)
+	}
+	if url, err := repo.Get(ctx, "abc123"); err != nil || url == nil || url.OriginalURL != "https://example.com" {
+		t.Fatalf("Get() = %v, %v; want the cached link", url, err)
+	}
+	if ok, err := repo.Exists(ctx, "abc123"); err != nil || !ok {
+		t.Errorf("Exists() = %v, %v; want true", ok, err)
+	}
+
+	if err := repo.SetDestination(ctx, "abc123", domain.Destination{OriginalURL: "https://example.com"}, time.Minute); err != nil {
+		t.Fatalf("SetDestination() error = %v", err)
+	}
+	if dest, err := repo.GetDestination(ctx, "abc123"); err != nil || dest == nil || dest.OriginalURL != "https://example.com" {
+		t.Fatalf("GetDestination() = %v, %v", dest, err)
+	}
+	if err := repo.Delete(ctx, "abc123"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if srv.Exists("tenant-a:url:abc123") || srv.Exists("tenant-a:dest:abc123") {
+		t.Error("Delete() left a cache entry behind")
+	}
+	// Evicting what isn't cached is not an error
+	if err := repo.DeleteMany(ctx, []string{"abc123", "def456"}); err != nil {
+		t.Errorf("DeleteMany() of missing codes error = %v", err)
+	}
+
+	// Entries go away with their TTL
+	repo.Set(ctx, &domain.URL{ShortURL: "short1"}, time.Minute)
+	srv.FastForward(2 * time.Minute)
+	if url, _ := repo.Get(ctx, "short1"); url != nil {
+		t.Error("Get() returned an entry past its TTL")
+	}
+
+	if got := testutil.ToFloat64(m.CacheErrors.WithLabelValues("get")); got != 0 {
+		t.Errorf("cache get errors = %v, want 0 for misses", got)
+	}
+}
+
+func TestMemcachedClaimAliasIsExclusive(t *testing.T) {
+	srv := newFakeMemcached()
+	repo, _ := newMemcachedTestRepo(t, srv, MemcachedCacheOptions{})
+	ctx := context.Background()
+
+	if ok, err := repo.ClaimAlias(ctx, "promo", time.Minute); err != nil || !ok {
+		t.Fatalf("first ClaimAlias() = %v, %v; want claimed", ok, err)
+	}
+	if ok, err := repo.ClaimAlias(ctx, "promo", time.Minute); err != nil || ok {
+		t.Errorf("second ClaimAlias() = %v, %v; want refused", ok, err)
+	}
+	if err := repo.ReleaseAlias(ctx, "promo"); err != nil {
+		t.Fatalf("ReleaseAlias() error = %v", err)
+	}
+	if ok, err := repo.ClaimAlias(ctx, "promo", time.Minute); err != nil || !ok {
+		t.Errorf("ClaimAlias() after release = %v, %v; want claimed", ok, err)
+	}
+}
+
+func TestMemcachedServiceCachePath(t *testing.T) {
+	srv := newFakeMemcached()
+	cacheRepo, m := newMemcachedTestRepo(t, srv, MemcachedCacheOptions{})
+	ctx := context.Background()
+
+	urlRepo := memory.NewURLRepository()
+	if err := urlRepo.Create(ctx, &domain.URL{ShortURL: "abc123", OriginalURL: "https://example.com"}); err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+	svc := service.NewURLService(urlRepo, cacheRepo, keyGen, nil, urlRepo, zap.NewNop(), m, service.URLServiceConfig{})
+
+	// A miss reads the DB and fills the cache, the next visit is a hit
+	for i := 0; i < 2; i++ {
+		if url, err := svc.Visit(ctx, "abc123"); err != nil || url.OriginalURL != "https://example.com" {
+			t.Fatalf("Visit() #%d = %v, %v", i, url, err)
+		}
+	}
+	if !srv.Exists("url:abc123") {
+		t.Fatal("the visited link was not cached")
+	}
+	if got := testutil.ToFloat64(m.CacheHitsTotal.WithLabelValues("get")); got != 1 {
+		t.Errorf("cache hits = %v, want 1", got)
+	}
+
+	// Pausing the link evicts it, visits see the change at once
+	if err := svc.SetActive(domain.WithAdmin(ctx), "abc123", false); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	if srv.Exists("url:abc123") {
+		t.Error("pausing the link left it cached")
+	}
+	if _, err := svc.Visit(ctx, "abc123"); err == nil {
+		t.Error("Visit() of a paused link succeeded")
+	}
+
+	// With Memcached gone, redirects are served from the DB
+	svc.SetActive(domain.WithAdmin(ctx), "abc123", true)
+	srv.Close()
+	if url, err := svc.Visit(ctx, "abc123"); err != nil || url.OriginalURL != "https://example.com" {
+		t.Errorf("Visit() with Memcached down = %v, %v; want the link from the DB", url, err)
+	}
+	if got := testutil.ToFloat64(m.CacheErrors.WithLabelValues("get")); got != 1 {
+		t.Errorf("cache get errors = %v, want 1 from the downed server", got)
+	}
+}
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// alias is one row of the url_aliases table
+type alias struct {
+	urlID   int64
+	retired bool
+}
+
+func (r *URLRepository) AddAlias(ctx context.Context, shortCode, code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok || stored.ReservedUntil != nil || stored.PurgeAfter != nil {
+		return domain.ErrURLNotFound
+	}
+	if r.codeInUse(code) {
+		return domain.ErrShortCodeExists
+	}
+	if _, exists := r.aliases[code]; exists {
+		return domain.ErrShortCodeExists
+	}
+	r.aliases[code] = alias{urlID: stored.ID}
+	return nil
+}
+
+func (r *URLRepository) RemoveAlias(ctx context.Context, shortCode, code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	existing, isAlias := r.aliases[code]
+	if !ok || !isAlias || existing.retired || existing.urlID != stored.ID {
+		return domain.ErrURLNotFound
+	}
+	delete(r.aliases, code)
+	return nil
+}
+
+func (r *URLRepository) ListAliases(ctx context.Context, shortCode string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok || stored.ReservedUntil != nil {
+		return nil, domain.ErrURLNotFound
+	}
+	aliases := []string{}
+	for code, alias := range r.aliases {
+		if alias.urlID == stored.ID && !alias.retired {
+			aliases = append(aliases, code)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases, nil
+}
+
+// ResolveAlias scans for the link by id; fine for the dev store, the SQL
+// backends join on an index
+func (r *URLRepository) ResolveAlias(ctx context.Context, code string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	alias, ok := r.aliases[code]
+	if !ok {
+		return "", domain.ErrURLNotFound
+	}
+	prefix, narrowed := domain.CodePrefixFrom(ctx)
+	for _, url := range r.urls {
+		if url.ID == alias.urlID && (!narrowed || url.Prefix == prefix) {
+			if alias.retired {
+				return "", domain.ErrCodeRetired
+			}
+			return url.ShortURL, nil
+		}
+	}
+	return "", domain.ErrURLNotFound
+}
+
+// RenameShortCode moves the link in place; click events live in the
+// separate ClickEventRepository here, which keeps them under the old code
+func (r *URLRepository) RenameShortCode(ctx context.Context, oldCode, newCode string, keepAlias bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.find(ctx, oldCode)
+	if !ok || stored.ReservedUntil != nil || stored.PurgeAfter != nil {
+		return domain.ErrURLNotFound
+	}
+	if _, exists := r.urls[r.key(stored.Prefix, newCode)]; exists {
+		return domain.ErrShortCodeExists
+	}
+	if _, exists := r.aliases[newCode]; exists {
+		return domain.ErrShortCodeExists
+	}
+
+	delete(r.urls, r.key(stored.Prefix, oldCode))
+	stored.ShortURL = newCode
+	stored.UpdatedAt = time.Now()
+	r.urls[r.key(stored.Prefix, newCode)] = stored
+	r.aliases[oldCode] = alias{urlID: stored.ID, retired: !keepAlias}
+
+	// History entries carry the code, which the SQL backends join from urls
+	for i := range r.history[stored.ID] {
+		r.history[stored.ID][i].ShortCode = newCode
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// AuditLog keeps audit entries in a slice for local dev and tests
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []domain.AuditEntry
+	nextID  int64
+}
+
+func NewAuditLog() *AuditLog {
+	return &AuditLog{}
+}
+
+var _ domain.AuditLog = (*AuditLog)(nil)
+
+func (l *AuditLog) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.nextID++
+	entry.ID = l.nextID
+	stored := *entry
+	stored.ShortCodes = append([]string(nil), entry.ShortCodes...)
+	l.entries = append(l.entries, stored)
+	return nil
+}
+
+// Entries returns a copy of everything recorded so far, oldest first
+func (l *AuditLog) Entries() []domain.AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]domain.AuditEntry(nil), l.entries...)
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+type cacheEntry struct {
+	url       domain.URL
+	expiresAt time.Time // zero means no TTL
+}
+
+func (e cacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// CacheRepository is a map-backed domain.CacheRepository with per-entry TTLs
+// Expired entries are dropped lazily on access, like Redis does for keys
+// nobody touches before the active expiry cycle gets to them.
+type CacheRepository struct {
+	mu         sync.Mutex
+	entries    map[string]cacheEntry
+	defaultTTL time.Duration
+	now        func() time.Time
+
+	// claims holds alias claims by code, valued by when they lapse
+	claims map[string]time.Time
+}
+
+func NewCacheRepository(defaultTTL time.Duration) *CacheRepository {
+	return &CacheRepository{
+		entries:    make(map[string]cacheEntry),
+		defaultTTL: defaultTTL,
+		now:        time.Now,
+		claims:     make(map[string]time.Time),
+	}
+}
+
+var _ domain.CacheRepository = (*CacheRepository)(nil)
+
+func (c *CacheRepository) Get(ctx context.Context, shortCode string) (*domain.URL, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[shortCode]
+	if !ok {
+		return nil, nil
+	}
+	if entry.expired(c.now()) {
+		delete(c.entries, shortCode)
+		return nil, nil
+	}
+
+	url := entry.url
+	return &url, nil
+}
+
+func (c *CacheRepository) Set(ctx context.Context, url *domain.URL, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = c.defaultTTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := cacheEntry{url: *url}
+	if ttl > 0 {
+		entry.expiresAt = c.now().Add(ttl)
+	}
+	c.entries[url.ShortURL] = entry
+	return nil
+}
+
+func (c *CacheRepository) Delete(ctx context.Context, shortCode string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, shortCode)
+	return nil
+}
+
+func (c *CacheRepository) DeleteMany(ctx context.Context, shortCodes []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, code := range shortCodes {
+		delete(c.entries, code)
+	}
+	return nil
+}
+
+func (c *CacheRepository) Exists(ctx context.Context, shortCode string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[shortCode]
+	if !ok {
+		return false, nil
+	}
+	if entry.expired(c.now()) {
+		delete(c.entries, shortCode)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (c *CacheRepository) ClaimAlias(ctx context.Context, code string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if until, ok := c.claims[code]; ok && now.Before(until) {
+		return false, nil
+	}
+	c.claims[code] = now.Add(ttl)
+	return true, nil
+}
+
+func (c *CacheRepository) ReleaseAlias(ctx context.Context, code string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.claims, code)
+	return nil
+}
@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// ClickEventRepository keeps click events in a slice for local dev and tests
+type ClickEventRepository struct {
+	mu     sync.Mutex
+	events []domain.ClickEvent
+	nextID int64
+}
+
+func NewClickEventRepository() *ClickEventRepository {
+	return &ClickEventRepository{}
+}
+
+var _ domain.ClickEventRepository = (*ClickEventRepository)(nil)
+
+func (r *ClickEventRepository) RecordClickEvent(ctx context.Context, event *domain.ClickEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	event.ID = r.nextID
+	if event.Type == "" {
+		event.Type = domain.ClickEventRedirect
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	r.events = append(r.events, *event)
+	return nil
+}
+
+// RecordClickEvents stores each event as RecordClickEvent would
+func (r *ClickEventRepository) RecordClickEvents(ctx context.Context, events []*domain.ClickEvent) error {
+	for _, event := range events {
+		if err := r.RecordClickEvent(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Events returns a copy of everything recorded so far, oldest first
+func (r *ClickEventRepository) Events() []domain.ClickEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]domain.ClickEvent(nil), r.events...)
+}
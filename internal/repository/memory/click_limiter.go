@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ClickLimiter is the in-process equivalent of repository.RedisClickLimiter
+type ClickLimiter struct {
+	mu     sync.Mutex
+	counts map[string]windowCount
+	now    func() time.Time
+}
+
+func NewClickLimiter() *ClickLimiter {
+	return &ClickLimiter{
+		counts: make(map[string]windowCount),
+		now:    time.Now,
+	}
+}
+
+func (l *ClickLimiter) Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	entry, ok := l.counts[key]
+	if !ok || now.After(entry.expiresAt) {
+		entry = windowCount{expiresAt: now.Add(window)}
+	}
+	entry.count++
+	l.counts[key] = entry
+	return entry.count <= limit, nil
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+func (r *URLRepository) ClaimExpiredEvent(ctx context.Context, shortCode string, now time.Time) (*domain.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok || stored.ReservedUntil != nil || stored.ExpiresAt == nil || stored.ExpiresAt.After(now) {
+		return nil, nil
+	}
+	if notified, ok := r.expiryNotified[stored.ID]; ok && notified.Equal(*stored.ExpiresAt) {
+		return nil, nil
+	}
+	r.expiryNotified[stored.ID] = *stored.ExpiresAt
+	return expiryOf(stored), nil
+}
+
+func (r *URLRepository) ClaimExpiryReminders(ctx context.Context, now, before time.Time, limit int) ([]domain.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []*domain.URL
+	for _, url := range r.urls {
+		if !url.IsActive || url.ReservedUntil != nil || url.PurgeAfter != nil || url.ExpiresAt == nil {
+			continue
+		}
+		if !url.ExpiresAt.After(now) || url.ExpiresAt.After(before) {
+			continue
+		}
+		if reminded, ok := r.expiryReminded[url.ID]; ok && reminded.Equal(*url.ExpiresAt) {
+			continue
+		}
+		due = append(due, url)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ExpiresAt.Before(*due[j].ExpiresAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]domain.URL, 0, len(due))
+	for _, url := range due {
+		r.expiryReminded[url.ID] = *url.ExpiresAt
+		claimed = append(claimed, *expiryOf(url))
+	}
+	return claimed, nil
+}
+
+// expiryOf copies the fields the SQL backends return from a claim
+func expiryOf(url *domain.URL) *domain.URL {
+	expiresAt := *url.ExpiresAt
+	return &domain.URL{
+		ID:          url.ID,
+		ShortURL:    url.ShortURL,
+		Prefix:      url.Prefix,
+		OriginalURL: url.OriginalURL,
+		ExpiresAt:   &expiresAt,
+	}
+}
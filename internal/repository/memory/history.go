@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+)
+
+func (r *URLRepository) UpdateDestination(ctx context.Context, entry *domain.URLHistoryEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.find(ctx, entry.ShortCode)
+	if !ok || stored.ReservedUntil != nil || stored.PurgeAfter != nil {
+		return domain.ErrURLNotFound
+	}
+
+	r.nextHistoryID++
+	entry.ID = r.nextHistoryID
+	entry.PreviousURL = stored.OriginalURL
+	stored.OriginalURL = entry.OriginalURL
+	stored.UpdatedAt = entry.ChangedAt
+	r.history[stored.ID] = append(r.history[stored.ID], *entry)
+	return nil
+}
+
+func (r *URLRepository) ListHistory(ctx context.Context, shortCode string, page pagination.Request) ([]domain.URLHistoryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok {
+		return []domain.URLHistoryEntry{}, nil
+	}
+
+	all := make([]domain.URLHistoryEntry, 0, len(r.history[stored.ID]))
+	for _, entry := range r.history[stored.ID] {
+		if page.After == nil || page.After.Before(entry.ChangedAt, entry.ID) {
+			all = append(all, entry)
+		}
+	}
+
+	// Same ordering as the Postgres query: newest first, highest id on ties
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].ChangedAt.Equal(all[j].ChangedAt) {
+			return all[i].ChangedAt.After(all[j].ChangedAt)
+		}
+		return all[i].ID > all[j].ID
+	})
+
+	if page.Offset >= len(all) {
+		return []domain.URLHistoryEntry{}, nil
+	}
+	all = all[page.Offset:]
+	if len(all) > page.Limit+1 {
+		all = all[:page.Limit+1]
+	}
+	return all, nil
+}
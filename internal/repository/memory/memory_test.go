@@ -0,0 +1,290 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"go.uber.org/zap"
+)
+
+func newMemoryService(t *testing.T, urlRepo *URLRepository, cacheRepo *CacheRepository) *service.URLService {
+	t.Helper()
+
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+
+	return service.NewURLService(
+		urlRepo,
+		cacheRepo,
+		keyGen,
+		nil,
+		urlRepo,
+		zap.NewNop(),
+		metrics.NewMetricsWithRegistry(prometheus.NewRegistry()),
+		service.URLServiceConfig{BaseURL: "http://short.test", DefaultTTL: time.Hour},
+	)
+}
+
+func TestServiceEndToEndWithMemoryBackends(t *testing.T) {
+	urlRepo := NewURLRepository()
+	cacheRepo := NewCacheRepository(time.Hour)
+	svc := newMemoryService(t, urlRepo, cacheRepo)
+	ctx := context.Background()
+
+	resp, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/docs"})
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if resp.ShortURL != "http://short.test/"+resp.ShortCode {
+		t.Errorf("ShortURL = %q, want base URL + code", resp.ShortURL)
+	}
+
+	// Served from the cache populated on create
+	url, err := svc.GetURL(ctx, resp.ShortCode)
+	if err != nil || url.OriginalURL != "https://example.com/docs" {
+		t.Fatalf("GetURL() = (%v, %v), want example.com/docs", url, err)
+	}
+
+	// Served from the repository after the cache entry is gone
+	_ = cacheRepo.Delete(ctx, resp.ShortCode)
+	url, err = svc.GetURL(ctx, resp.ShortCode)
+	if err != nil || url.OriginalURL != "https://example.com/docs" {
+		t.Fatalf("GetURL() after cache eviction = (%v, %v), want example.com/docs", url, err)
+	}
+
+	alias := resp.ShortCode
+	if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: &alias}); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("Create() with taken alias error = %v, want ErrShortCodeExists", err)
+	}
+
+	if _, err := svc.GetURL(ctx, "missing"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("GetURL() on unknown code error = %v, want ErrURLNotFound", err)
+	}
+}
+
+func TestServiceExpiryWithMemoryBackends(t *testing.T) {
+	urlRepo := NewURLRepository()
+	cacheRepo := NewCacheRepository(time.Hour)
+	svc := newMemoryService(t, urlRepo, cacheRepo)
+	ctx := context.Background()
+
+	expiresIn := int64(1)
+	resp, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/flash", ExpiresIn: &expiresIn})
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	if _, err := svc.GetURL(ctx, resp.ShortCode); err != nil {
+		t.Fatalf("GetURL() before expiry returned error: %v", err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	// Cache path: the cached copy is expired and gets evicted
+	if _, err := svc.GetURL(ctx, resp.ShortCode); !errors.Is(err, domain.ErrURLExpired) {
+		t.Fatalf("GetURL() after expiry error = %v, want ErrURLExpired", err)
+	}
+	// Repository path: nothing cached anymore
+	if _, err := svc.GetURL(ctx, resp.ShortCode); !errors.Is(err, domain.ErrURLExpired) {
+		t.Fatalf("GetURL() after expiry (uncached) error = %v, want ErrURLExpired", err)
+	}
+}
+
+func TestCacheRepositoryTTL(t *testing.T) {
+	cacheRepo := NewCacheRepository(time.Hour)
+	now := time.Now()
+	cacheRepo.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_ = cacheRepo.Set(ctx, &domain.URL{ShortURL: "abc123"}, time.Minute)
+	if ok, _ := cacheRepo.Exists(ctx, "abc123"); !ok {
+		t.Fatal("Exists() = false right after Set()")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if url, _ := cacheRepo.Get(ctx, "abc123"); url != nil {
+		t.Errorf("Get() after TTL = %+v, want nil", url)
+	}
+}
+
+func TestAggregateStatsTotalsAndTopOrdering(t *testing.T) {
+	urlRepo := NewURLRepository()
+	svc := newMemoryService(t, urlRepo, NewCacheRepository(time.Hour))
+	ctx := context.Background()
+
+	clicks := map[string]int64{"low": 5, "top": 90, "mid": 40, "tie-a": 10, "tie-b": 10, "off": 500}
+	for _, code := range []string{"low", "top", "mid", "tie-a", "tie-b", "off"} {
+		alias := code
+		if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/" + code, CustomAlias: &alias}); err != nil {
+			t.Fatalf("Create(%s) returned error: %v", code, err)
+		}
+		urlRepo.urls[code].ClickCount = clicks[code]
+	}
+	if err := svc.SetActive(domain.WithAdmin(ctx), "off", false); err != nil {
+		t.Fatalf("SetActive() returned error: %v", err)
+	}
+
+	stats, err := svc.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats() returned error: %v", err)
+	}
+
+	if stats.TotalURLs != 6 || stats.ActiveURLs != 5 || stats.TotalClicks != 655 {
+		t.Errorf("totals = (urls %d, active %d, clicks %d), want (6, 5, 655)", stats.TotalURLs, stats.ActiveURLs, stats.TotalClicks)
+	}
+
+	want := []string{"top", "mid", "tie-a", "tie-b", "low"}
+	if len(stats.TopURLs) != len(want) {
+		t.Fatalf("top urls = %+v, want %v", stats.TopURLs, want)
+	}
+	for i, code := range want {
+		if stats.TopURLs[i].ShortCode != code {
+			t.Errorf("top[%d] = %s, want %s", i, stats.TopURLs[i].ShortCode, code)
+		}
+	}
+}
+
+func TestListOffsetVersusCursorUnderInserts(t *testing.T) {
+	repo := NewURLRepository()
+	ctx := context.Background()
+	create := func(code string) {
+		t.Helper()
+		if err := repo.Create(ctx, &domain.URL{ShortURL: code, OriginalURL: "https://example.com/" + code}); err != nil {
+			t.Fatalf("Create(%s) error = %v", code, err)
+		}
+	}
+	for _, code := range []string{"a1", "a2", "a3", "a4", "a5"} {
+		create(code)
+	}
+
+	firstReq := pagination.Request{Limit: 2}
+	rows, err := repo.List(ctx, domain.ListScope{All: true}, firstReq)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	first := pagination.NewPage(firstReq, rows, domain.CursorOf)
+	if codes(first.Items) != "a5,a4" {
+		t.Fatalf("first page = %s, want a5,a4", codes(first.Items))
+	}
+
+	// Both strategies agree while nothing changes
+	after, _ := pagination.DecodeCursor(first.NextCursor)
+	byOffset, _ := repo.List(ctx, domain.ListScope{All: true}, pagination.Request{Limit: 2, Offset: 2})
+	byCursor, _ := repo.List(ctx, domain.ListScope{All: true}, pagination.Request{Limit: 2, After: after})
+	if codes(byOffset[:2]) != codes(byCursor[:2]) {
+		t.Fatalf("offset page %s != cursor page %s", codes(byOffset[:2]), codes(byCursor[:2]))
+	}
+
+	// A new link shifts every offset by one, so the offset page repeats a4;
+	// the cursor page is anchored to a4 and is unaffected
+	create("new")
+	byOffset, _ = repo.List(ctx, domain.ListScope{All: true}, pagination.Request{Limit: 2, Offset: 2})
+	byCursor, _ = repo.List(ctx, domain.ListScope{All: true}, pagination.Request{Limit: 2, After: after})
+
+	if got := codes(byOffset[:2]); got != "a4,a3" {
+		t.Errorf("offset page after insert = %s, want a4,a3 (shifted)", got)
+	}
+	if got := codes(byCursor[:2]); got != "a3,a2" {
+		t.Errorf("cursor page after insert = %s, want a3,a2 (stable)", got)
+	}
+}
+
+func codes(urls []domain.URL) string {
+	out := make([]string, len(urls))
+	for i, url := range urls {
+		out[i] = url.ShortURL
+	}
+	return strings.Join(out, ",")
+}
+
+func TestAliasReservationClaimFlow(t *testing.T) {
+	urlRepo := NewURLRepository()
+	svc := newMemoryService(t, urlRepo, NewCacheRepository(time.Hour))
+	alice := domain.WithCaller(context.Background(), "alice")
+	bob := domain.WithCaller(context.Background(), "bob")
+
+	if _, err := svc.ReserveAlias(context.Background(), &domain.ReserveAliasRequest{Alias: "launch"}); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Fatalf("anonymous ReserveAlias() error = %v, want ErrUnauthorized", err)
+	}
+
+	resp, err := svc.ReserveAlias(alice, &domain.ReserveAliasRequest{Alias: "launch"})
+	if err != nil {
+		t.Fatalf("ReserveAlias() error = %v", err)
+	}
+	if !resp.ReservedUntil.After(time.Now()) {
+		t.Errorf("ReservedUntil = %v, want in the future", resp.ReservedUntil)
+	}
+
+	// Held: doesn't redirect, can't be reserved again or taken by someone else
+	if _, err := svc.GetURL(alice, "launch"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("GetURL() on a reservation error = %v, want ErrURLNotFound", err)
+	}
+	if _, err := svc.ReserveAlias(bob, &domain.ReserveAliasRequest{Alias: "launch"}); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("second ReserveAlias() error = %v, want ErrShortCodeExists", err)
+	}
+	alias := "launch"
+	if _, err := svc.Create(bob, &domain.CreateURLRequest{OriginalURL: "https://bob.example.com", CustomAlias: &alias}); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("Create() by another user error = %v, want ErrShortCodeExists", err)
+	}
+
+	// The owner claims it by creating with the alias
+	if _, err := svc.Create(alice, &domain.CreateURLRequest{OriginalURL: "https://alice.example.com/launch", CustomAlias: &alias}); err != nil {
+		t.Fatalf("Create() by the owner error = %v", err)
+	}
+	url, err := svc.GetURL(bob, "launch")
+	if err != nil || url.OriginalURL != "https://alice.example.com/launch" {
+		t.Fatalf("GetURL() after claim = (%v, %v), want alice's destination", url, err)
+	}
+
+	// Claimed links are ordinary links now
+	if _, err := svc.Create(alice, &domain.CreateURLRequest{OriginalURL: "https://alice.example.com/again", CustomAlias: &alias}); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("Create() over a claimed alias error = %v, want ErrShortCodeExists", err)
+	}
+}
+
+func TestAliasReservationExpiry(t *testing.T) {
+	urlRepo := NewURLRepository()
+	svc := newMemoryService(t, urlRepo, NewCacheRepository(time.Hour))
+	bob := domain.WithCaller(context.Background(), "bob")
+	ctx := context.Background()
+
+	reserve := func(code string, until time.Time) {
+		t.Helper()
+		owner := "alice"
+		if err := urlRepo.Reserve(ctx, &domain.URL{ShortURL: code, UserID: &owner, ReservedUntil: &until}); err != nil {
+			t.Fatalf("Reserve(%s) error = %v", code, err)
+		}
+	}
+	reserve("lapsed", time.Now().Add(-time.Minute))
+	reserve("swept", time.Now().Add(-time.Minute))
+	reserve("held", time.Now().Add(time.Hour))
+
+	// A lapsed reservation is up for grabs even before cleanup runs
+	alias := "lapsed"
+	if _, err := svc.Create(bob, &domain.CreateURLRequest{OriginalURL: "https://bob.example.com", CustomAlias: &alias}); err != nil {
+		t.Fatalf("Create() over a lapsed reservation error = %v", err)
+	}
+
+	service.NewCleanupWorker(urlRepo, time.Minute, zap.NewNop()).RunOnce(ctx)
+
+	if _, err := svc.ReserveAlias(bob, &domain.ReserveAliasRequest{Alias: "swept"}); err != nil {
+		t.Errorf("ReserveAlias() after cleanup error = %v", err)
+	}
+	if _, err := svc.ReserveAlias(bob, &domain.ReserveAliasRequest{Alias: "held"}); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("cleanup removed a live reservation, ReserveAlias() error = %v", err)
+	}
+	if url, err := svc.GetURL(ctx, "lapsed"); err != nil || url.OriginalURL != "https://bob.example.com" {
+		t.Errorf("cleanup touched a claimed link: GetURL() = (%v, %v)", url, err)
+	}
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type windowCount struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// ScanCounter is the in-process equivalent of repository.RedisScanCounter
+type ScanCounter struct {
+	mu     sync.Mutex
+	counts map[string]windowCount
+	now    func() time.Time
+}
+
+func NewScanCounter() *ScanCounter {
+	return &ScanCounter{
+		counts: make(map[string]windowCount),
+		now:    time.Now,
+	}
+}
+
+func (s *ScanCounter) IncrNotFound(ctx context.Context, key string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	entry, ok := s.counts[key]
+	if !ok || now.After(entry.expiresAt) {
+		entry = windowCount{expiresAt: now.Add(window)}
+	}
+	entry.count++
+	s.counts[key] = entry
+	return entry.count, nil
+}
+
+func (s *ScanCounter) NotFoundCount(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.counts[key]
+	if !ok || s.now().After(entry.expiresAt) {
+		delete(s.counts, key)
+		return 0, nil
+	}
+	return entry.count, nil
+}
@@ -0,0 +1,494 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+)
+
+// URLRepository is a map-backed domain.URLRepository for local dev and tests
+// It mirrors the Postgres repository's error semantics so the service behaves
+// the same on either backend. Data lives only as long as the process.
+type URLRepository struct {
+	mu     sync.RWMutex
+	urls   map[string]*domain.URL // by key()
+	nextID int64
+
+	// codesPerPrefix lets links under different prefixes share a code
+	codesPerPrefix bool
+
+	// history is keyed by link id, like the url_history table
+	history       map[int64][]domain.URLHistoryEntry
+	nextHistoryID int64
+
+	// aliases are a link's extra and retired codes, like the url_aliases table
+	aliases map[string]alias
+
+	// The expiry each link's expired event and reminder were claimed for,
+	// by link id, like the expiry_*_at columns
+	expiryNotified map[int64]time.Time
+	expiryReminded map[int64]time.Time
+}
+
+func NewURLRepository() *URLRepository {
+	return &URLRepository{
+		urls:           make(map[string]*domain.URL),
+		history:        make(map[int64][]domain.URLHistoryEntry),
+		aliases:        make(map[string]alias),
+		expiryNotified: make(map[int64]time.Time),
+		expiryReminded: make(map[int64]time.Time),
+	}
+}
+
+// NewPrefixScopedURLRepository is NewURLRepository with codes unique per
+// prefix, like the SQL schema with repository.SchemaOptions.CodesPerPrefix
+func NewPrefixScopedURLRepository() *URLRepository {
+	r := NewURLRepository()
+	r.codesPerPrefix = true
+	return r
+}
+
+var _ domain.URLRepository = (*URLRepository)(nil)
+
+// key is the map key of the link shortCode under prefix: the code alone
+// while codes are unique globally, the pair when they are unique per prefix
+func (r *URLRepository) key(prefix, shortCode string) string {
+	if r.codesPerPrefix {
+		return domain.LinkKey(prefix, shortCode)
+	}
+	return shortCode
+}
+
+// find returns the row shortCode names in ctx, the one under the prefix set
+// with domain.WithCodePrefix if any; rows are returned as stored
+func (r *URLRepository) find(ctx context.Context, shortCode string) (*domain.URL, bool) {
+	prefix, narrowed := domain.CodePrefixFrom(ctx)
+	if narrowed {
+		stored, ok := r.urls[r.key(prefix, shortCode)]
+		if !ok || stored.Prefix != prefix {
+			return nil, false
+		}
+		return stored, true
+	}
+	if !r.codesPerPrefix {
+		stored, ok := r.urls[shortCode]
+		return stored, ok
+	}
+	// Under any prefix, like the SQL backends
+	for _, stored := range r.urls {
+		if stored.ShortURL == shortCode {
+			return stored, true
+		}
+	}
+	return nil, false
+}
+
+// codeInUse reports whether a link under any prefix holds code, which an
+// alias can't take
+func (r *URLRepository) codeInUse(code string) bool {
+	_, ok := r.find(context.Background(), code)
+	return ok
+}
+
+func (r *URLRepository) Create(ctx context.Context, url *domain.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := r.key(url.Prefix, url.ShortURL)
+	if _, exists := r.urls[key]; exists {
+		return domain.ErrShortCodeExists
+	}
+	if _, alias := r.aliases[url.ShortURL]; alias {
+		return domain.ErrShortCodeExists
+	}
+
+	r.nextID++
+	now := time.Now()
+	url.ID = r.nextID
+	url.CreatedAt = now
+	url.UpdatedAt = now
+	url.IsActive = true
+
+	stored := *url
+	r.urls[key] = &stored
+	return nil
+}
+
+func (r *URLRepository) GetByShortCode(ctx context.Context, shortCode string) (*domain.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok || stored.ReservedUntil != nil {
+		return nil, domain.ErrURLNotFound
+	}
+	if stored.PurgeAfter != nil {
+		return nil, domain.ErrURLDeleted
+	}
+	if !stored.IsActive {
+		return nil, domain.ErrURLDisabled
+	}
+	if stored.IsExpired() {
+		return nil, domain.Expired(stored)
+	}
+
+	// Return a copy so callers can't mutate the stored row
+	url := *stored
+	return &url, nil
+}
+
+// Incr counts a click immediately; there is no buffer to reconcile in memory
+// so URLRepository doubles as the domain.ClickCounter for this backend
+// Clicks are counted by domain.LinkKey, like every ClickCounter.
+func (r *URLRepository) Incr(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prefix, shortCode := domain.SplitLinkKey(key)
+	if stored, ok := r.find(domain.WithCodePrefix(ctx, prefix), shortCode); ok {
+		stored.ClickCount++
+	}
+	return nil
+}
+
+var _ domain.ClickCounter = (*URLRepository)(nil)
+
+func (r *URLRepository) SetActive(ctx context.Context, shortCode string, active bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok {
+		return domain.ErrURLNotFound
+	}
+	stored.IsActive = active
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *URLRepository) CountActiveByUser(ctx context.Context, userID string) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, url := range r.urls {
+		if url.IsActive && url.PurgeAfter == nil && !url.IsExpired() && url.UserID != nil && *url.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *URLRepository) SetMetadata(ctx context.Context, shortCode string, meta domain.LinkMetadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok {
+		return domain.ErrURLNotFound
+	}
+	stored.Title = meta.Title
+	stored.Description = meta.Description
+	stored.ImageURL = meta.ImageURL
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *URLRepository) GetExpiry(ctx context.Context, shortCode string) (*time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok || stored.ReservedUntil != nil {
+		return nil, domain.ErrURLNotFound
+	}
+	if stored.ExpiresAt == nil {
+		return nil, nil
+	}
+	expiresAt := *stored.ExpiresAt
+	return &expiresAt, nil
+}
+
+func (r *URLRepository) GetOwner(ctx context.Context, shortCode string) (*string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok || stored.ReservedUntil != nil {
+		return nil, domain.ErrURLNotFound
+	}
+	if stored.UserID == nil {
+		return nil, nil
+	}
+	userID := *stored.UserID
+	return &userID, nil
+}
+
+func (r *URLRepository) IsShortCodeTaken(ctx context.Context, shortCode string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, alias := r.aliases[shortCode]; alias {
+		return true, nil
+	}
+	stored, ok := r.find(ctx, shortCode)
+	if !ok {
+		return false, nil
+	}
+	return stored.ReservedUntil == nil || stored.ReservedUntil.After(time.Now()), nil
+}
+
+func (r *URLRepository) SetExpiry(ctx context.Context, shortCode string, expiresAt *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok || stored.ReservedUntil != nil {
+		return domain.ErrURLNotFound
+	}
+	if expiresAt != nil {
+		t := *expiresAt
+		expiresAt = &t
+	}
+	stored.ExpiresAt = expiresAt
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *URLRepository) GetAggregateStats(ctx context.Context, topN int) (*domain.AggregateStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := &domain.AggregateStats{GeneratedAt: time.Now().UTC()}
+	active := make([]*domain.URL, 0, len(r.urls))
+	bySource := make(map[string]int64)
+	for _, url := range r.urls {
+		stats.TotalURLs++
+		stats.TotalClicks += url.ClickCount
+		if url.ReservedUntil == nil {
+			source := url.Source
+			if source == "" {
+				source = domain.SourceUnknown
+			}
+			bySource[source]++
+		}
+		if !url.IsActive {
+			continue
+		}
+		active = append(active, url)
+		if !url.IsExpired() {
+			stats.ActiveURLs++
+		}
+	}
+
+	// Same ordering as the Postgres query: most clicks first, oldest first on ties
+	sort.Slice(active, func(i, j int) bool {
+		if active[i].ClickCount != active[j].ClickCount {
+			return active[i].ClickCount > active[j].ClickCount
+		}
+		return active[i].ID < active[j].ID
+	})
+	if len(active) > topN {
+		active = active[:topN]
+	}
+
+	stats.TopURLs = make([]domain.URLStats, 0, len(active))
+	for _, url := range active {
+		stats.TopURLs = append(stats.TopURLs, domain.URLStats{
+			ShortCode:  url.ShortURL,
+			ClickCount: url.ClickCount,
+			CreatedAt:  url.CreatedAt,
+		})
+	}
+
+	// Same ordering as the SQL backends: most links first, then by name
+	stats.LinksBySource = make([]domain.SourceStats, 0, len(bySource))
+	for source, links := range bySource {
+		stats.LinksBySource = append(stats.LinksBySource, domain.SourceStats{Source: source, Links: links})
+	}
+	sort.Slice(stats.LinksBySource, func(i, j int) bool {
+		a, b := stats.LinksBySource[i], stats.LinksBySource[j]
+		if a.Links != b.Links {
+			return a.Links > b.Links
+		}
+		return a.Source < b.Source
+	})
+	return stats, nil
+}
+
+func (r *URLRepository) List(ctx context.Context, scope domain.ListScope, page pagination.Request) ([]domain.URL, error) {
+	return r.list(page, func(url *domain.URL) bool {
+		return url.ReservedUntil == nil && scope.Includes(url)
+	}), nil
+}
+
+func (r *URLRepository) ListByDestination(ctx context.Context, originalURL string, scope domain.ListScope, page pagination.Request) ([]domain.URL, error) {
+	return r.list(page, func(url *domain.URL) bool {
+		return url.OriginalURL == originalURL && url.IsActive && url.PurgeAfter == nil && !url.IsExpired() && scope.Includes(url)
+	}), nil
+}
+
+// list returns one page of the rows matching keep
+func (r *URLRepository) list(page pagination.Request, keep func(*domain.URL) bool) []domain.URL {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]domain.URL, 0, len(r.urls))
+	for _, url := range r.urls {
+		if !keep(url) {
+			continue
+		}
+		if page.After != nil && !page.After.Before(url.CreatedAt, url.ID) {
+			continue
+		}
+		all = append(all, *url)
+	}
+
+	// Same ordering as the Postgres query: newest first, highest id on ties
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].CreatedAt.Equal(all[j].CreatedAt) {
+			return all[i].CreatedAt.After(all[j].CreatedAt)
+		}
+		return all[i].ID > all[j].ID
+	})
+
+	if page.Offset >= len(all) {
+		return []domain.URL{}
+	}
+	all = all[page.Offset:]
+	if len(all) > page.Limit+1 {
+		all = all[:page.Limit+1]
+	}
+	return all
+}
+
+func (r *URLRepository) Reserve(ctx context.Context, url *domain.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	key := r.key(url.Prefix, url.ShortURL)
+	if existing, ok := r.urls[key]; ok && !lapsed(existing, now) {
+		return domain.ErrShortCodeExists
+	}
+	if _, alias := r.aliases[url.ShortURL]; alias {
+		return domain.ErrShortCodeExists
+	}
+
+	r.nextID++
+	url.ID = r.nextID
+	url.CreatedAt = now
+	url.UpdatedAt = now
+	url.IsActive = false
+
+	stored := *url
+	r.urls[key] = &stored
+	return nil
+}
+
+func (r *URLRepository) ClaimReservation(ctx context.Context, url *domain.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	key := r.key("", url.ShortURL)
+	existing, ok := r.urls[key]
+	if !ok || existing.ReservedUntil == nil {
+		return domain.ErrURLNotFound
+	}
+	if !lapsed(existing, now) && !sameOwner(existing.UserID, url.UserID) {
+		return domain.ErrURLNotFound
+	}
+
+	url.ID = existing.ID
+	url.CreatedAt = now
+	url.UpdatedAt = now
+	url.IsActive = true
+	url.ReservedUntil = nil
+
+	// Reservations are unprefixed; the claiming link may not be
+	stored := *url
+	delete(r.urls, key)
+	r.urls[r.key(url.Prefix, url.ShortURL)] = &stored
+	return nil
+}
+
+func (r *URLRepository) DeleteExpiredReservations(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for code, url := range r.urls {
+		if lapsed(url, now) {
+			delete(r.urls, code)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *URLRepository) MarkDeleted(ctx context.Context, shortCode string, purgeAfter time.Time) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok || stored.ReservedUntil != nil {
+		return time.Time{}, domain.ErrURLNotFound
+	}
+	if stored.PurgeAfter == nil {
+		stored.PurgeAfter = &purgeAfter
+		stored.UpdatedAt = time.Now()
+	}
+	return *stored.PurgeAfter, nil
+}
+
+func (r *URLRepository) RestoreDeleted(ctx context.Context, shortCode string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.find(ctx, shortCode)
+	if !ok || stored.PurgeAfter == nil || !stored.PurgeAfter.After(now) {
+		return domain.ErrURLNotFound
+	}
+	stored.PurgeAfter = nil
+	stored.UpdatedAt = now
+	return nil
+}
+
+func (r *URLRepository) PurgeDeleted(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for code, url := range r.urls {
+		if url.PurgeAfter != nil && !url.PurgeAfter.After(now) {
+			delete(r.urls, code)
+			delete(r.history, url.ID)
+			delete(r.expiryNotified, url.ID)
+			delete(r.expiryReminded, url.ID)
+			for code, alias := range r.aliases {
+				if alias.urlID == url.ID {
+					delete(r.aliases, code)
+				}
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// lapsed reports whether url is a reservation that ran out before now
+func lapsed(url *domain.URL, now time.Time) bool {
+	return url.ReservedUntil != nil && !url.ReservedUntil.After(now)
+}
+
+func sameOwner(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
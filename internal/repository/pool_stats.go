@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+)
+
+// DBStatsSource is anything that can report connection pool stats
+// *sqlx.DB and *sql.DB both satisfy it; tests pass a stub
+type DBStatsSource interface {
+	Stats() sql.DBStats
+}
+
+// RecordDBStats copies a single pool snapshot into the DB pool gauges
+func RecordDBStats(stats sql.DBStats, m *metrics.Metrics) {
+	m.DBConnectionsActive.Set(float64(stats.InUse))
+	m.DBConnectionsIdle.Set(float64(stats.Idle))
+	m.DBConnectionsOpen.Set(float64(stats.OpenConnections))
+	m.DBConnectionsWaitCount.Set(float64(stats.WaitCount))
+	m.DBConnectionsWaitDuration.Set(stats.WaitDuration.Seconds())
+}
+
+// RunDBStatsSampler publishes pool stats every interval until ctx is cancelled
+// Learning: database/sql keeps these counters internally but nothing exports
+// them, so without sampling pool exhaustion only shows up as rising latency
+func RunDBStatsSampler(ctx context.Context, src DBStatsSource, interval time.Duration, m *metrics.Metrics) {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	RecordDBStats(src.Stats(), m)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			RecordDBStats(src.Stats(), m)
+		}
+	}
+}
+
+// RedisPoolStatsSource is anything that can report Redis pool stats
+// *redis.Client and *redis.ClusterClient both satisfy it; tests pass a stub
+type RedisPoolStatsSource interface {
+	PoolStats() *redis.PoolStats
+}
+
+// RecordRedisPoolStats copies a single pool snapshot into the Redis pool gauges
+func RecordRedisPoolStats(stats *redis.PoolStats, m *metrics.Metrics) {
+	if stats == nil {
+		return
+	}
+
+	m.RedisPoolHits.Set(float64(stats.Hits))
+	m.RedisPoolMisses.Set(float64(stats.Misses))
+	m.RedisPoolTimeouts.Set(float64(stats.Timeouts))
+	m.RedisPoolTotalConns.Set(float64(stats.TotalConns))
+	m.RedisPoolIdleConns.Set(float64(stats.IdleConns))
+	m.RedisPoolStaleConns.Set(float64(stats.StaleConns))
+}
+
+// RunRedisPoolStatsSampler publishes Redis pool stats every interval until ctx is cancelled
+func RunRedisPoolStatsSampler(ctx context.Context, src RedisPoolStatsSource, interval time.Duration, m *metrics.Metrics) {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	RecordRedisPoolStats(src.PoolStats(), m)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			RecordRedisPoolStats(src.PoolStats(), m)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+)
+
+type stubDBStats struct {
+	mu    sync.Mutex
+	stats sql.DBStats
+}
+
+func (s *stubDBStats) Stats() sql.DBStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *stubDBStats) set(stats sql.DBStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = stats
+}
+
+func TestRunDBStatsSamplerUpdatesGauges(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	src := &stubDBStats{}
+	src.set(sql.DBStats{OpenConnections: 3, InUse: 1, Idle: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunDBStatsSampler(ctx, src, 10*time.Millisecond, m)
+		close(done)
+	}()
+
+	src.set(sql.DBStats{
+		OpenConnections: 25,
+		InUse:           25,
+		Idle:            0,
+		WaitCount:       42,
+		WaitDuration:    1500 * time.Millisecond,
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(m.DBConnectionsActive) != 25 {
+		if time.Now().After(deadline) {
+			t.Fatalf("db_connections_active = %v, want 25", testutil.ToFloat64(m.DBConnectionsActive))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sampler did not stop after context cancellation")
+	}
+
+	checks := map[string]struct {
+		got  float64
+		want float64
+	}{
+		"db_connections_idle":                  {testutil.ToFloat64(m.DBConnectionsIdle), 0},
+		"db_connections_open":                  {testutil.ToFloat64(m.DBConnectionsOpen), 25},
+		"db_connections_wait_count":            {testutil.ToFloat64(m.DBConnectionsWaitCount), 42},
+		"db_connections_wait_duration_seconds": {testutil.ToFloat64(m.DBConnectionsWaitDuration), 1.5},
+	}
+	for name, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", name, c.got, c.want)
+		}
+	}
+}
+
+type stubRedisPoolStats struct {
+	stats redis.PoolStats
+}
+
+func (s *stubRedisPoolStats) PoolStats() *redis.PoolStats {
+	stats := s.stats
+	return &stats
+}
+
+func TestRunRedisPoolStatsSamplerUpdatesGauges(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	src := &stubRedisPoolStats{stats: redis.PoolStats{
+		Hits:       120,
+		Misses:     7,
+		Timeouts:   3,
+		TotalConns: 10,
+		IdleConns:  4,
+		StaleConns: 2,
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The sampler records once before checking the context, so a cancelled
+	// context still publishes a snapshot and returns immediately
+	RunRedisPoolStatsSampler(ctx, src, time.Hour, m)
+
+	checks := map[string]struct {
+		got  float64
+		want float64
+	}{
+		"redis_pool_hits":              {testutil.ToFloat64(m.RedisPoolHits), 120},
+		"redis_pool_misses":            {testutil.ToFloat64(m.RedisPoolMisses), 7},
+		"redis_pool_timeouts":          {testutil.ToFloat64(m.RedisPoolTimeouts), 3},
+		"redis_pool_connections_total": {testutil.ToFloat64(m.RedisPoolTotalConns), 10},
+		"redis_pool_connections_idle":  {testutil.ToFloat64(m.RedisPoolIdleConns), 4},
+		"redis_pool_connections_stale": {testutil.ToFloat64(m.RedisPoolStaleConns), 2},
+	}
+	for name, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", name, c.got, c.want)
+		}
+	}
+}
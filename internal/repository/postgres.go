@@ -2,23 +2,144 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sony/gobreaker"
 	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/breaker"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/chaos"
 	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"go.uber.org/zap"
 )
 
 type PostgresURLRepository struct {
 	db      *sqlx.DB
-	metrics *metrics.Metrics // Added for observability
+	metrics *metrics.Metrics          // Added for observability
+	breaker *gobreaker.CircuitBreaker // optional, nil disables it
+	retry   RetryPolicy
+	slowLog SlowQueryLog
+
+	replicas *readReplicas // nil reads everything from the primary
+	faults   chaos.Fault   // staging fault injection, see InjectFaults
+}
+
+// SlowQueryLog warns about individual queries slower than Threshold
+// Learning: The duration histogram shows that p99 went up, a log line shows
+// which operation on which link did it. Zero Threshold or a nil Logger disables it.
+type SlowQueryLog struct {
+	Threshold time.Duration
+	Logger    *zap.Logger
 }
 
-func NewPostgresURLRepository(db *sqlx.DB, m *metrics.Metrics) *PostgresURLRepository {
+func NewPostgresURLRepository(db *sqlx.DB, m *metrics.Metrics, cb *gobreaker.CircuitBreaker, retry RetryPolicy, slowLog SlowQueryLog) *PostgresURLRepository {
 	return &PostgresURLRepository{
 		db:      db,
 		metrics: m,
+		breaker: cb,
+		retry:   retry.withDefaults(),
+		slowLog: slowLog,
+	}
+}
+
+// slowQueryCodePrefix is how much of a short code goes into slow query logs:
+// enough to correlate with a ticket, not enough to visit a private link
+const slowQueryCodePrefix = 4
+
+// logIfSlow is called from each method's deferred timing block
+// shortCode may be empty for queries not about a single link
+func (r *PostgresURLRepository) logIfSlow(operation string, elapsed time.Duration, shortCode string) {
+	if r.slowLog.Threshold <= 0 || r.slowLog.Logger == nil || elapsed < r.slowLog.Threshold {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("operation", operation),
+		zap.Duration("duration", elapsed),
+		zap.Duration("threshold", r.slowLog.Threshold),
+	}
+	if shortCode != "" {
+		if len(shortCode) > slowQueryCodePrefix {
+			shortCode = shortCode[:slowQueryCodePrefix] + "..."
+		}
+		fields = append(fields, zap.String("short_code", shortCode))
+	}
+	r.slowLog.Logger.Warn("slow database query", fields...)
+}
+
+// IsDBBreakerSuccess tells the breaker which errors mean Postgres is healthy
+// "No rows", constraint violations and caller cancellations are answers from a
+// working database, only connection/timeout style failures should trip it
+func IsDBBreakerSuccess(err error) bool {
+	if err == nil ||
+		errors.Is(err, sql.ErrNoRows) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, domain.ErrURLNotFound) ||
+		errors.Is(err, domain.ErrURLExpired) ||
+		errors.Is(err, domain.ErrURLDisabled) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 23 = integrity constraint violation (e.g. duplicate short code)
+		return pqErr.Code.Class() == "23"
+	}
+
+	return false
+}
+
+// CodePrefix is the query argument narrowing a lookup by short code to the
+// prefix set with domain.WithCodePrefix, NULL when there is none; queries
+// compare it as prefix = COALESCE($n, prefix)
+func CodePrefix(ctx context.Context) sql.NullString {
+	prefix, ok := domain.CodePrefixFrom(ctx)
+	return sql.NullString{String: prefix, Valid: ok}
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation (23505)
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// execute runs fn through the circuit breaker when one is configured
+// Learning: When Postgres is overloaded, piling more queries on makes recovery
+// slower. An open breaker fast-fails with ErrServiceUnavailable (HTTP 503)
+// until the timeout passes and a probe query succeeds.
+func (r *PostgresURLRepository) execute(ctx context.Context, fn func() error) error {
+	if r.breaker == nil {
+		return r.faulty(ctx, fn)
+	}
+
+	_, err := r.breaker.Execute(func() (interface{}, error) {
+		return nil, r.faulty(ctx, fn)
+	})
+	if breaker.IsOpen(err) {
+		return fmt.Errorf("%w: database circuit breaker is %s", domain.ErrServiceUnavailable, r.breaker.State())
+	}
+	return err
+}
+
+// InjectFaults slows down or fails the queries that go through execute and
+// the reads served by replicas, only in binaries built with -tags chaos (see
+// package chaos)
+func (r *PostgresURLRepository) InjectFaults(f chaos.Fault) {
+	r.faults = f
+}
+
+func (r *PostgresURLRepository) faulty(ctx context.Context, fn func() error) error {
+	if err := r.faults.Inject(ctx); err != nil {
+		return err
 	}
+	return fn()
 }
 
 func (r *PostgresURLRepository) Create(ctx context.Context, url *domain.URL) error {
@@ -29,33 +150,64 @@ func (r *PostgresURLRepository) Create(ctx context.Context, url *domain.URL) err
 
 	// Defer metrics recording so it happens even if we return early
 	defer func() {
-		duration := time.Since(start).Seconds()
-		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(duration)
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, url.ShortURL)
 	}()
 
+	// Pinned before writing: until the replicas have the change, this
+	// instance reads the code from the primary
+	r.replicas.pin(url.ShortURL)
+
+	// An alias holds its code like a link does; the guard is a check, not
+	// a constraint, but resolving tries links first, so losing a race to
+	// AddAlias still leaves the new link reachable
 	query := `
-		INSERT INTO urls (short_code, original_url, user_id, expires_at, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO urls (short_code, original_url, user_id, expires_at, is_active, created_at, updated_at, visibility, signed, click_rate_limit, passthrough_query, prefix, platform_destinations, country_destinations, fallback_url, source)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
+		WHERE NOT EXISTS (SELECT 1 FROM url_aliases WHERE code = $1)
 		RETURNING id`
 
 	now := time.Now()
 	url.CreatedAt = now
 	url.UpdatedAt = now
 	url.IsActive = true
+	if url.Visibility == "" {
+		url.Visibility = domain.VisibilityPublic
+	}
 
-	err := r.db.QueryRowContext(
-		ctx,
-		query,
-		url.ShortURL,
-		url.OriginalURL,
-		url.UserID,
-		url.ExpiresAt,
-		url.IsActive,
-		url.CreatedAt,
-		url.UpdatedAt,
-	).Scan(&url.ID)
+	err := r.executeWithRetry(ctx, operation, isTransientWriteError, func() error {
+		return r.db.QueryRowContext(
+			ctx,
+			query,
+			url.ShortURL,
+			url.OriginalURL,
+			url.UserID,
+			url.ExpiresAt,
+			url.IsActive,
+			url.CreatedAt,
+			url.UpdatedAt,
+			url.Visibility,
+			url.Signed,
+			url.ClickRateLimit,
+			url.PassthroughQuery,
+			url.Prefix,
+			url.PlatformDestinations,
+			url.CountryDestinations,
+			url.FallbackURL,
+			url.Source,
+		).Scan(&url.ID)
+	})
 
+	if errors.Is(err, sql.ErrNoRows) {
+		// The code is an alias of another link
+		return domain.ErrShortCodeExists
+	}
 	if err != nil {
+		if isUniqueViolation(err) {
+			// Expected conflict (taken alias or generated-code collision), not a DB fault
+			return domain.ErrShortCodeExists
+		}
 		// Track database errors
 		// Learning: Separate metric from duration - errors need alerting
 		r.metrics.DBErrors.WithLabelValues(operation).Inc()
@@ -72,32 +224,851 @@ func (r *PostgresURLRepository) GetByShortCode(ctx context.Context, shortCode st
 
 	// Defer metrics recording
 	defer func() {
-		duration := time.Since(start).Seconds()
-		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(duration)
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
 	}()
 
 	query := `
 	SELECT id, short_code, original_url, user_id, created_at, updated_at,
-		   expires_at, click_count, is_active
+		   expires_at, click_count, is_active, visibility, signed, click_rate_limit,
+		   title, description, image_url, passthrough_query, prefix, purge_after, platform_destinations, country_destinations, fallback_url, source
 	FROM urls
-	WHERE short_code = $1 AND is_active = true`
+	WHERE short_code = $1 AND prefix = COALESCE($2, prefix) AND reserved_until IS NULL`
 
 	var url domain.URL
-	err := r.db.GetContext(ctx, &url, query, shortCode)
+	read := func(db *sqlx.DB) error {
+		return db.GetContext(ctx, &url, query, shortCode, CodePrefix(ctx))
+	}
+	served, err := r.readFromReplica(ctx, shortCode, read)
+	if !served {
+		url = domain.URL{}
+		err = r.executeWithRetry(ctx, operation, isTransientReadError, func() error {
+			return read(r.db)
+		})
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// A missing code is an answer, not a database fault: keep it out of
+		// DBErrors so the alert only fires on real failures
+		return nil, domain.ErrURLNotFound
+	}
 	if err != nil {
-		// Track database errors (including "not found")
 		r.metrics.DBErrors.WithLabelValues(operation).Inc()
 		return nil, err
 	}
 
+	if url.PurgeAfter != nil {
+		// Deleted but restorable: gone for visitors, not yet for the owner
+		return nil, domain.ErrURLDeleted
+	}
+
+	if !url.IsActive {
+		// Disabled links are returned as an error (not "not found") so the
+		// handler can tell users the link was paused rather than never existed
+		return nil, domain.ErrURLDisabled
+	}
+
 	if url.IsExpired() {
 		// Track expired URLs separately
 		// Learning: This is a business metric - helps understand user experience
 		r.metrics.ExpiredURLsTotal.Inc()
-		return nil, domain.ErrURLExpired // Fixed: was returning generic error
+		return nil, domain.Expired(&url) // Fixed: was returning generic error
 	}
 
 	return &url, nil
 }
 
-// TODO: get short url by longurl for dedupliation
+func (r *PostgresURLRepository) SetActive(ctx context.Context, shortCode string, active bool) error {
+	start := time.Now()
+	operation := "set_active"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	r.replicas.pin(shortCode)
+
+	query := `
+	UPDATE urls
+	SET is_active = $2, updated_at = NOW()
+	WHERE short_code = $1 AND prefix = COALESCE($3, prefix)`
+
+	var result sql.Result
+	err := r.execute(ctx, func() error {
+		var err error
+		result, err = r.db.ExecContext(ctx, query, shortCode, active, CodePrefix(ctx))
+		return err
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrURLNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresURLRepository) CountActiveByUser(ctx context.Context, userID string) (int64, error) {
+	start := time.Now()
+	operation := "count_active_by_user"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, "")
+	}()
+
+	// Served by idx_urls_user_id, which only holds active rows with an owner
+	// Reservations are inactive, so they don't count against the quota
+	query := `
+	SELECT COUNT(*)
+	FROM urls
+	WHERE user_id = $1 AND is_active = true AND purge_after IS NULL
+	  AND (expires_at IS NULL OR expires_at > NOW())`
+
+	var count int64
+	err := r.executeWithRetry(ctx, operation, isTransientReadError, func() error {
+		return r.db.GetContext(ctx, &count, query, userID)
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *PostgresURLRepository) SetMetadata(ctx context.Context, shortCode string, meta domain.LinkMetadata) error {
+	start := time.Now()
+	operation := "set_metadata"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	r.replicas.pin(shortCode)
+
+	query := `
+	UPDATE urls
+	SET title = $2, description = $3, image_url = $4, updated_at = NOW()
+	WHERE short_code = $1 AND prefix = COALESCE($5, prefix)`
+
+	var result sql.Result
+	err := r.execute(ctx, func() error {
+		var err error
+		result, err = r.db.ExecContext(ctx, query, shortCode, meta.Title, meta.Description, meta.ImageURL, CodePrefix(ctx))
+		return err
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrURLNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresURLRepository) GetExpiry(ctx context.Context, shortCode string) (*time.Time, error) {
+	start := time.Now()
+	operation := "get_expiry"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	// Unlike GetByShortCode, disabled and expired links are answered too:
+	// they are exactly the ones an operator may want to revive
+	query := `
+	SELECT expires_at
+	FROM urls
+	WHERE short_code = $1 AND prefix = COALESCE($2, prefix) AND reserved_until IS NULL`
+
+	var expiresAt sql.NullTime
+	err := r.executeWithRetry(ctx, operation, isTransientReadError, func() error {
+		return r.db.GetContext(ctx, &expiresAt, query, shortCode, CodePrefix(ctx))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrURLNotFound
+	}
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+
+	if !expiresAt.Valid {
+		return nil, nil
+	}
+	return &expiresAt.Time, nil
+}
+
+func (r *PostgresURLRepository) GetOwner(ctx context.Context, shortCode string) (*string, error) {
+	start := time.Now()
+	operation := "get_owner"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	// Like GetExpiry, any link answers: disabled or deleted ones still
+	// belong to someone
+	query := `
+	SELECT user_id
+	FROM urls
+	WHERE short_code = $1 AND prefix = COALESCE($2, prefix) AND reserved_until IS NULL`
+
+	var userID sql.NullString
+	err := r.executeWithRetry(ctx, operation, isTransientReadError, func() error {
+		return r.db.GetContext(ctx, &userID, query, shortCode, CodePrefix(ctx))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrURLNotFound
+	}
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+
+	if !userID.Valid {
+		return nil, nil
+	}
+	return &userID.String, nil
+}
+
+func (r *PostgresURLRepository) IsShortCodeTaken(ctx context.Context, shortCode string) (bool, error) {
+	start := time.Now()
+	operation := "is_short_code_taken"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	// Asked of the primary: a code taken a moment ago must not look free
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM urls
+		WHERE short_code = $1 AND prefix = COALESCE($2, prefix)
+		  AND (reserved_until IS NULL OR reserved_until > NOW())
+	) OR EXISTS (
+		SELECT 1 FROM url_aliases WHERE code = $1
+	)`
+
+	var taken bool
+	err := r.executeWithRetry(ctx, operation, isTransientReadError, func() error {
+		return r.db.GetContext(ctx, &taken, query, shortCode, CodePrefix(ctx))
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return false, err
+	}
+	return taken, nil
+}
+
+func (r *PostgresURLRepository) SetExpiry(ctx context.Context, shortCode string, expiresAt *time.Time) error {
+	start := time.Now()
+	operation := "set_expiry"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	r.replicas.pin(shortCode)
+
+	query := `
+	UPDATE urls
+	SET expires_at = $2, updated_at = NOW()
+	WHERE short_code = $1 AND prefix = COALESCE($3, prefix) AND reserved_until IS NULL`
+
+	var result sql.Result
+	err := r.execute(ctx, func() error {
+		var err error
+		result, err = r.db.ExecContext(ctx, query, shortCode, expiresAt, CodePrefix(ctx))
+		return err
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrURLNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresURLRepository) GetAggregateStats(ctx context.Context, topN int) (*domain.AggregateStats, error) {
+	start := time.Now()
+	operation := "aggregate_stats"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, "")
+	}()
+
+	// All three queries scan the whole table, callers are expected to cache the result
+	totalsQuery := `
+	SELECT COUNT(*) AS total_urls,
+		   COUNT(*) FILTER (WHERE is_active = true AND (expires_at IS NULL OR expires_at > NOW())) AS active_urls,
+		   COALESCE(SUM(click_count), 0) AS total_clicks
+	FROM urls`
+
+	topQuery := `
+	SELECT short_code, click_count, created_at
+	FROM urls
+	WHERE is_active = true
+	ORDER BY click_count DESC, id ASC
+	LIMIT $1`
+
+	sourceQuery := `
+	SELECT COALESCE(NULLIF(source, ''), $1) AS source, COUNT(*) AS links
+	FROM urls
+	WHERE reserved_until IS NULL
+	GROUP BY 1
+	ORDER BY links DESC, source ASC`
+
+	var stats domain.AggregateStats
+	err := r.execute(ctx, func() error {
+		if err := r.db.GetContext(ctx, &stats, totalsQuery); err != nil {
+			return err
+		}
+		stats.TopURLs = make([]domain.URLStats, 0, topN)
+		if err := r.db.SelectContext(ctx, &stats.TopURLs, topQuery, topN); err != nil {
+			return err
+		}
+		stats.LinksBySource = []domain.SourceStats{}
+		return r.db.SelectContext(ctx, &stats.LinksBySource, sourceQuery, domain.SourceUnknown)
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+
+	stats.GeneratedAt = time.Now().UTC()
+	return &stats, nil
+}
+
+func (r *PostgresURLRepository) List(ctx context.Context, scope domain.ListScope, page pagination.Request) ([]domain.URL, error) {
+	start := time.Now()
+	operation := "list_urls"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, "")
+	}()
+
+	var (
+		query string
+		args  []interface{}
+	)
+	if page.After != nil {
+		// Keyset pagination: a range scan on idx_urls_created_at that starts
+		// right after the cursor, no matter how deep the page is
+		query = `
+		SELECT id, short_code, original_url, user_id, created_at, updated_at,
+			   expires_at, click_count, is_active, visibility, signed, click_rate_limit,
+			   title, description, image_url, passthrough_query, prefix, purge_after, platform_destinations, country_destinations, fallback_url, source
+		FROM urls
+		WHERE (created_at, id) < ($1, $2) AND reserved_until IS NULL
+		  AND ($3 OR user_id = $4)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $5`
+		args = []interface{}{page.After.CreatedAt, page.After.ID, scope.All, scope.UserID, page.Limit + 1}
+	} else {
+		// OFFSET still reads and discards every skipped row
+		query = `
+		SELECT id, short_code, original_url, user_id, created_at, updated_at,
+			   expires_at, click_count, is_active, visibility, signed, click_rate_limit,
+			   title, description, image_url, passthrough_query, prefix, purge_after, platform_destinations, country_destinations, fallback_url, source
+		FROM urls
+		WHERE reserved_until IS NULL
+		  AND ($1 OR user_id = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`
+		args = []interface{}{scope.All, scope.UserID, page.Limit + 1, page.Offset}
+	}
+
+	urls := make([]domain.URL, 0, page.Limit+1)
+	err := r.execute(ctx, func() error {
+		return r.db.SelectContext(ctx, &urls, query, args...)
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// clickFlushRetention is how long applied batch IDs are remembered
+// A batch can only be replayed while it is still listed in Redis, which is
+// minutes at most, so a day is plenty
+const clickFlushRetention = "1 day"
+
+// ApplyClickBatch adds buffered click counts to urls.click_count
+// The batch ID is recorded in the same transaction, so applying a batch that
+// was already applied (a replay after a crash) changes nothing
+func (r *PostgresURLRepository) ApplyClickBatch(ctx context.Context, batchID string, counts map[string]int64) error {
+	start := time.Now()
+	operation := "apply_click_batch"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, "")
+	}()
+
+	// Fixed update order so concurrent flushers can't deadlock on row locks
+	// Counts are keyed by domain.LinkKey, so each names a single link
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	err := r.execute(ctx, func() error {
+		tx, err := r.db.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO click_flushes (batch_id) VALUES ($1) ON CONFLICT (batch_id) DO NOTHING`, batchID)
+		if err != nil {
+			return err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if inserted == 0 {
+			// Already applied
+			return nil
+		}
+
+		for _, key := range keys {
+			prefix, code := domain.SplitLinkKey(key)
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE urls SET click_count = click_count + $3 WHERE short_code = $1 AND prefix = $2`, code, prefix, counts[key]); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM click_flushes WHERE applied_at < NOW() - INTERVAL '`+clickFlushRetention+`'`); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+
+	return nil
+}
+
+// ListByDestination is served by idx_urls_original_url, which only holds
+// active rows; a destination rarely has many codes, so sorting them is cheap
+func (r *PostgresURLRepository) ListByDestination(ctx context.Context, originalURL string, scope domain.ListScope, page pagination.Request) ([]domain.URL, error) {
+	start := time.Now()
+	operation := "list_by_destination"
+
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, "")
+	}()
+
+	var (
+		query string
+		args  []interface{}
+	)
+	if page.After != nil {
+		query = `
+		SELECT id, short_code, original_url, user_id, created_at, updated_at,
+			   expires_at, click_count, is_active, visibility, signed, click_rate_limit,
+			   title, description, image_url, passthrough_query, prefix, purge_after, platform_destinations, country_destinations, fallback_url, source
+		FROM urls
+		WHERE original_url = $1 AND is_active = true AND purge_after IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND ($2 OR user_id = $3)
+		  AND (created_at, id) < ($4, $5)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $6`
+		args = []interface{}{originalURL, scope.All, scope.UserID, page.After.CreatedAt, page.After.ID, page.Limit + 1}
+	} else {
+		query = `
+		SELECT id, short_code, original_url, user_id, created_at, updated_at,
+			   expires_at, click_count, is_active, visibility, signed, click_rate_limit,
+			   title, description, image_url, passthrough_query, prefix, purge_after, platform_destinations, country_destinations, fallback_url, source
+		FROM urls
+		WHERE original_url = $1 AND is_active = true AND purge_after IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND ($2 OR user_id = $3)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5`
+		args = []interface{}{originalURL, scope.All, scope.UserID, page.Limit + 1, page.Offset}
+	}
+
+	urls := make([]domain.URL, 0, page.Limit+1)
+	err := r.executeWithRetry(ctx, operation, isTransientReadError, func() error {
+		return r.db.SelectContext(ctx, &urls, query, args...)
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// RecordClickEvent inserts one analytics event into click_events
+func (r *PostgresURLRepository) RecordClickEvent(ctx context.Context, event *domain.ClickEvent) error {
+	start := time.Now()
+	operation := "record_click_event"
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, event.ShortCode)
+	}()
+
+	if event.Type == "" {
+		event.Type = domain.ClickEventRedirect
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO click_events (short_code, ip_address, user_agent, referrer, country, city,
+			device, browser, os, country_source, event_type, created_at, prefix)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, $12, $13)
+		RETURNING id`
+
+	err := r.execute(ctx, func() error {
+		return r.db.QueryRowContext(ctx, query,
+			event.ShortCode, event.IPAddress, event.UserAgent, event.Referrer, event.Country, event.City,
+			event.Device, event.Browser, event.OS, event.CountrySource, event.Type, event.CreatedAt, event.Prefix,
+		).Scan(&event.ID)
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	return nil
+}
+
+// RecordClickEvents inserts a batch of click events with one multi-row INSERT
+func (r *PostgresURLRepository) RecordClickEvents(ctx context.Context, events []*domain.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	operation := "record_click_events"
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, "")
+	}()
+
+	const columns = 13
+	var query strings.Builder
+	query.WriteString(`INSERT INTO click_events (short_code, ip_address, user_agent, referrer, country, city,
+			device, browser, os, country_source, event_type, created_at, prefix) VALUES `)
+	args := make([]interface{}, 0, len(events)*columns)
+	for i, event := range events {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := i * columns
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, NULLIF($%d, ''), $%d, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13)
+		args = append(args,
+			event.ShortCode, event.IPAddress, event.UserAgent, event.Referrer, event.Country, event.City,
+			event.Device, event.Browser, event.OS, event.CountrySource, event.Type, event.CreatedAt, event.Prefix,
+		)
+	}
+
+	err := r.execute(ctx, func() error {
+		_, err := r.db.ExecContext(ctx, query.String(), args...)
+		return err
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	return nil
+}
+
+// RecordAudit inserts one audit entry into audit_log
+func (r *PostgresURLRepository) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	start := time.Now()
+	operation := "record_audit"
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, "")
+	}()
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO audit_log (action, short_codes, actor, actor_type, actor_ip, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	err := r.execute(ctx, func() error {
+		return r.db.QueryRowContext(ctx, query,
+			entry.Action, pq.Array(entry.ShortCodes), entry.Actor, entry.ActorType, entry.ActorIP, entry.CreatedAt,
+		).Scan(&entry.ID)
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresURLRepository) Reserve(ctx context.Context, url *domain.URL) error {
+	start := time.Now()
+	operation := "reserve_alias"
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, url.ShortURL)
+	}()
+
+	// The upsert only fires over a lapsed reservation, so a live link,
+	// someone else's live reservation or an alias returns no row
+	query := `
+		INSERT INTO urls (short_code, original_url, user_id, is_active, created_at, updated_at, reserved_until)
+		SELECT $1, '', $2, false, $3, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM url_aliases WHERE code = $1)
+		ON CONFLICT (short_code, prefix) DO UPDATE
+		SET user_id = EXCLUDED.user_id,
+			created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at,
+			reserved_until = EXCLUDED.reserved_until
+		WHERE urls.reserved_until IS NOT NULL AND urls.reserved_until <= EXCLUDED.created_at
+		RETURNING id`
+
+	now := time.Now()
+	url.CreatedAt = now
+	url.UpdatedAt = now
+	url.IsActive = false
+
+	err := r.execute(ctx, func() error {
+		return r.db.QueryRowContext(ctx, query, url.ShortURL, url.UserID, now, url.ReservedUntil).Scan(&url.ID)
+	})
+	if errors.Is(err, sql.ErrNoRows) || isUniqueViolation(err) {
+		// A unique violation is a prefixed link holding a global code
+		return domain.ErrShortCodeExists
+	}
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresURLRepository) ClaimReservation(ctx context.Context, url *domain.URL) error {
+	start := time.Now()
+	operation := "claim_reservation"
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, url.ShortURL)
+	}()
+
+	r.replicas.pin(url.ShortURL)
+
+	query := `
+		UPDATE urls
+		SET original_url = $2, user_id = $3, expires_at = $4, is_active = true,
+			visibility = $5, signed = $6, created_at = $7, updated_at = $7, reserved_until = NULL,
+			click_rate_limit = $8, passthrough_query = $9, prefix = $10, platform_destinations = $11,
+			country_destinations = $12, fallback_url = $13, source = $14
+		WHERE short_code = $1
+		  AND reserved_until IS NOT NULL
+		  AND (reserved_until <= $7 OR user_id IS NOT DISTINCT FROM $3)
+		RETURNING id`
+
+	now := time.Now()
+	url.CreatedAt = now
+	url.UpdatedAt = now
+	url.IsActive = true
+	url.ReservedUntil = nil
+	if url.Visibility == "" {
+		url.Visibility = domain.VisibilityPublic
+	}
+
+	err := r.execute(ctx, func() error {
+		return r.db.QueryRowContext(ctx, query,
+			url.ShortURL, url.OriginalURL, url.UserID, url.ExpiresAt, url.Visibility, url.Signed, now, url.ClickRateLimit, url.PassthroughQuery, url.Prefix, url.PlatformDestinations,
+			url.CountryDestinations, url.FallbackURL, url.Source,
+		).Scan(&url.ID)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrURLNotFound
+	}
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresURLRepository) DeleteExpiredReservations(ctx context.Context, now time.Time) (int64, error) {
+	start := time.Now()
+	operation := "delete_expired_reservations"
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, "")
+	}()
+
+	query := `DELETE FROM urls WHERE reserved_until IS NOT NULL AND reserved_until <= $1`
+
+	var result sql.Result
+	err := r.execute(ctx, func() error {
+		var err error
+		result, err = r.db.ExecContext(ctx, query, now)
+		return err
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *PostgresURLRepository) MarkDeleted(ctx context.Context, shortCode string, purgeAfter time.Time) (time.Time, error) {
+	start := time.Now()
+	operation := "mark_deleted"
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	r.replicas.pin(shortCode)
+
+	// COALESCE keeps the first window when a deleted link is deleted again
+	query := `
+		UPDATE urls
+		SET purge_after = COALESCE(purge_after, $2), updated_at = NOW()
+		WHERE short_code = $1 AND prefix = COALESCE($3, prefix) AND reserved_until IS NULL
+		RETURNING purge_after`
+
+	var effective time.Time
+	err := r.execute(ctx, func() error {
+		return r.db.QueryRowContext(ctx, query, shortCode, purgeAfter, CodePrefix(ctx)).Scan(&effective)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, domain.ErrURLNotFound
+	}
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return time.Time{}, err
+	}
+	return effective, nil
+}
+
+func (r *PostgresURLRepository) RestoreDeleted(ctx context.Context, shortCode string, now time.Time) error {
+	start := time.Now()
+	operation := "restore_deleted"
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, shortCode)
+	}()
+
+	r.replicas.pin(shortCode)
+
+	query := `
+		UPDATE urls
+		SET purge_after = NULL, updated_at = $2
+		WHERE short_code = $1 AND prefix = COALESCE($3, prefix) AND purge_after > $2`
+
+	var result sql.Result
+	err := r.execute(ctx, func() error {
+		var err error
+		result, err = r.db.ExecContext(ctx, query, shortCode, now, CodePrefix(ctx))
+		return err
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrURLNotFound
+	}
+	return nil
+}
+
+func (r *PostgresURLRepository) PurgeDeleted(ctx context.Context, now time.Time) (int64, error) {
+	start := time.Now()
+	operation := "purge_deleted"
+	defer func() {
+		elapsed := time.Since(start)
+		r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+		r.logIfSlow(operation, elapsed, "")
+	}()
+
+	query := `DELETE FROM urls WHERE purge_after IS NOT NULL AND purge_after <= $1`
+
+	var result sql.Result
+	err := r.execute(ctx, func() error {
+		var err error
+		result, err = r.db.ExecContext(ctx, query, now)
+		return err
+	})
+	if err != nil {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/gobreaker"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/breaker"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var urlColumns = []string{
+	"id", "short_code", "original_url", "user_id", "created_at", "updated_at",
+	"expires_at", "click_count", "is_active", "visibility", "signed", "click_rate_limit",
+	"title", "description", "image_url", "passthrough_query", "prefix", "purge_after",
+	"platform_destinations", "country_destinations", "fallback_url", "source",
+}
+
+func newMockPostgresRepo(t *testing.T, cb *gobreaker.CircuitBreaker) (*PostgresURLRepository, sqlmock.Sqlmock, *metrics.Metrics) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	retry := RetryPolicy{BaseDelay: time.Microsecond, MaxDelay: time.Microsecond}
+	return NewPostgresURLRepository(sqlx.NewDb(mockDB, "postgres"), m, cb, retry, SlowQueryLog{}), mock, m
+}
+
+func TestPostgresBreakerOpensAndRecovers(t *testing.T) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	cb := breaker.New("postgres-test", breaker.Config{
+		MaxFailures: 3,
+		OpenTimeout: 50 * time.Millisecond,
+	}, zap.NewNop(), m, IsDBBreakerSuccess)
+	repo, mock, _ := newMockPostgresRepo(t, cb)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery("SELECT (.+) FROM urls").WillReturnError(errors.New("connection reset by peer"))
+		if _, err := repo.GetByShortCode(ctx, "abc123"); err == nil {
+			t.Fatalf("GetByShortCode() attempt %d returned nil error", i+1)
+		}
+	}
+
+	if cb.State() != gobreaker.StateOpen {
+		t.Fatalf("breaker state after failures = %v, want open", cb.State())
+	}
+
+	// Fast-fails without touching the database
+	if _, err := repo.GetByShortCode(ctx, "abc123"); !errors.Is(err, domain.ErrServiceUnavailable) {
+		t.Fatalf("GetByShortCode() with open breaker error = %v, want ErrServiceUnavailable", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT (.+) FROM urls").WillReturnRows(
+		sqlmock.NewRows(urlColumns).AddRow(1, "abc123", "https://example.com", nil, now, now, nil, 0, true, "public", false, nil, "", "", "", false, "", nil, "{}", "{}", "", ""),
+	)
+	url, err := repo.GetByShortCode(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("GetByShortCode() probe after timeout returned error: %v", err)
+	}
+	if url.OriginalURL != "https://example.com" {
+		t.Errorf("GetByShortCode() original_url = %q, want %q", url.OriginalURL, "https://example.com")
+	}
+
+	if cb.State() != gobreaker.StateClosed {
+		t.Errorf("breaker state after successful probe = %v, want closed", cb.State())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestPostgresListUsesKeysetForCursor(t *testing.T) {
+	repo, mock, _ := newMockPostgresRepo(t, nil)
+	after := &pagination.Cursor{CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), ID: 9}
+
+	mock.ExpectQuery(`WHERE \(created_at, id\) < \(\$1, \$2\)`).
+		WithArgs(after.CreatedAt, after.ID, true, "", 3).
+		WillReturnRows(sqlmock.NewRows(urlColumns))
+
+	if _, err := repo.List(context.Background(), domain.ListScope{All: true}, pagination.Request{Limit: 2, After: after}); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	mock.ExpectQuery(`LIMIT \$3 OFFSET \$4`).WithArgs(true, "", 3, 40).WillReturnRows(sqlmock.NewRows(urlColumns))
+	if _, err := repo.List(context.Background(), domain.ListScope{All: true}, pagination.Request{Limit: 2, Offset: 40}); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestPostgresRetriesTransientReadErrors(t *testing.T) {
+	repo, mock, m := newMockPostgresRepo(t, nil)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT (.+) FROM urls").WillReturnError(&pq.Error{Code: "08006"}) // connection_failure
+	mock.ExpectQuery("SELECT (.+) FROM urls").WillReturnRows(
+		sqlmock.NewRows(urlColumns).AddRow(1, "abc123", "https://example.com", nil, now, now, nil, 0, true, "public", false, nil, "", "", "", false, "", nil, "{}", "{}", "", ""),
+	)
+
+	url, err := repo.GetByShortCode(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetByShortCode() error = %v, want success on the second attempt", err)
+	}
+	if url.ShortURL != "abc123" {
+		t.Errorf("GetByShortCode() short_code = %q", url.ShortURL)
+	}
+	if got := testutil.ToFloat64(m.DBRetriesTotal.WithLabelValues("get_by_short_code")); got != 1 {
+		t.Errorf("db_retries_total = %v, want 1", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestPostgresRetriesOnlyRolledBackInserts(t *testing.T) {
+	repo, mock, m := newMockPostgresRepo(t, nil)
+	ctx := context.Background()
+
+	// A serialization failure means the INSERT was rolled back: safe to retry
+	mock.ExpectQuery("INSERT INTO urls").WillReturnError(&pq.Error{Code: "40001"})
+	mock.ExpectQuery("INSERT INTO urls").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	url := &domain.URL{ShortURL: "abc123", OriginalURL: "https://example.com"}
+	if err := repo.Create(ctx, url); err != nil {
+		t.Fatalf("Create() error = %v, want success on the second attempt", err)
+	}
+	if url.ID != 7 {
+		t.Errorf("Create() id = %d, want 7", url.ID)
+	}
+
+	// A dropped connection may have committed the row: must not retry
+	mock.ExpectQuery("INSERT INTO urls").WillReturnError(&pq.Error{Code: "08006"})
+	if err := repo.Create(ctx, &domain.URL{ShortURL: "def456", OriginalURL: "https://example.com"}); err == nil {
+		t.Fatal("Create() returned nil error for a lost connection")
+	}
+
+	if got := testutil.ToFloat64(m.DBRetriesTotal.WithLabelValues("create_url")); got != 1 {
+		t.Errorf("db_retries_total = %v, want 1", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestPostgresDoesNotRetryPermanentErrors(t *testing.T) {
+	repo, mock, m := newMockPostgresRepo(t, nil)
+
+	mock.ExpectQuery("SELECT (.+) FROM urls").WillReturnError(&pq.Error{Code: "42P01"}) // undefined_table
+	if _, err := repo.GetByShortCode(context.Background(), "abc123"); err == nil {
+		t.Fatal("GetByShortCode() returned nil error")
+	}
+	if got := testutil.ToFloat64(m.DBRetriesTotal.WithLabelValues("get_by_short_code")); got != 0 {
+		t.Errorf("db_retries_total = %v, want 0", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestPostgresMissingCodeIsNotFound(t *testing.T) {
+	repo, mock, m := newMockPostgresRepo(t, nil)
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT (.+) FROM urls").WillReturnRows(sqlmock.NewRows(urlColumns))
+	if _, err := repo.GetByShortCode(ctx, "nope99"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Fatalf("GetByShortCode() error = %v, want ErrURLNotFound", err)
+	}
+	if got := testutil.ToFloat64(m.DBErrors.WithLabelValues("get_by_short_code")); got != 0 {
+		t.Errorf("db_errors_total = %v, want 0 for a missing code", got)
+	}
+
+	// Real failures still count
+	mock.ExpectQuery("SELECT (.+) FROM urls").WillReturnError(&pq.Error{Code: "42P01"}) // undefined_table
+	if _, err := repo.GetByShortCode(ctx, "abc123"); err == nil || errors.Is(err, domain.ErrURLNotFound) {
+		t.Fatalf("GetByShortCode() error = %v, want the database error", err)
+	}
+	if got := testutil.ToFloat64(m.DBErrors.WithLabelValues("get_by_short_code")); got != 1 {
+		t.Errorf("db_errors_total = %v, want 1", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestRetryPolicyBackoffStaysWithinBounds(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond}
+	for n := 1; n <= 10; n++ {
+		ceiling := min(p.BaseDelay<<(n-1), p.MaxDelay)
+		for i := 0; i < 100; i++ {
+			if d := p.backoff(n); d <= 0 || d > ceiling {
+				t.Fatalf("backoff(%d) = %v, want within (0, %v]", n, d, ceiling)
+			}
+		}
+	}
+}
+
+func TestPostgresLogsSlowQueries(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer mockDB.Close()
+
+	core, logs := observer.New(zap.WarnLevel)
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	repo := NewPostgresURLRepository(sqlx.NewDb(mockDB, "postgres"), m, nil, RetryPolicy{}, SlowQueryLog{
+		Threshold: 20 * time.Millisecond,
+		Logger:    zap.New(core),
+	})
+	now := time.Now()
+	row := func() *sqlmock.Rows {
+		return sqlmock.NewRows(urlColumns).AddRow(1, "abc123xyz", "https://example.com", nil, now, now, nil, 0, true, "public", false, nil, "", "", "", false, "", nil, "{}", "{}", "", "")
+	}
+
+	// Fast query: no log
+	mock.ExpectQuery("SELECT (.+) FROM urls").WillReturnRows(row())
+	if _, err := repo.GetByShortCode(context.Background(), "abc123xyz"); err != nil {
+		t.Fatalf("GetByShortCode() error = %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("fast query logged %d entries, want none", logs.Len())
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM urls").WillDelayFor(30 * time.Millisecond).WillReturnRows(row())
+	if _, err := repo.GetByShortCode(context.Background(), "abc123xyz"); err != nil {
+		t.Fatalf("GetByShortCode() error = %v", err)
+	}
+
+	entries := logs.FilterMessage("slow database query").All()
+	if len(entries) != 1 {
+		t.Fatalf("slow query logged %d entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["operation"] != "get_by_short_code" {
+		t.Errorf("operation = %v, want get_by_short_code", fields["operation"])
+	}
+	if fields["short_code"] != "abc1..." {
+		t.Errorf("short_code = %v, want the truncated abc1...", fields["short_code"])
+	}
+	if d, _ := fields["duration"].(time.Duration); d < 30*time.Millisecond {
+		t.Errorf("duration = %v, want at least 30ms", fields["duration"])
+	}
+}
+
+func TestPostgresRecordClickEventsIsOneInsert(t *testing.T) {
+	repo, mock, _ := newMockPostgresRepo(t, nil)
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := []*domain.ClickEvent{
+		{ShortCode: "abc", IPAddress: "203.0.113.7", Type: domain.ClickEventOpen, CreatedAt: at},
+		{ShortCode: "def", Prefix: "news", Country: "DE", CountrySource: "geoip", Type: domain.ClickEventRedirect, CreatedAt: at},
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("NULLIF($10, ''), $11, $12, $13), ($14, $15")).
+		WithArgs(
+			"abc", "203.0.113.7", "", "", "", "", "", "", "", "", domain.ClickEventOpen, at, "",
+			"def", "", "", "", "DE", "", "", "", "", "geoip", domain.ClickEventRedirect, at, "news",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := repo.RecordClickEvents(context.Background(), events); err != nil {
+		t.Fatalf("RecordClickEvents() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestPostgresRecordAudit(t *testing.T) {
+	repo, mock, _ := newMockPostgresRepo(t, nil)
+	entry := &domain.AuditEntry{
+		Action:     domain.AuditURLDisable,
+		ShortCodes: []string{"abc", "def"},
+		Actor:      "user-1",
+		ActorType:  domain.ActorUser,
+		ActorIP:    "203.0.113.7",
+	}
+
+	mock.ExpectQuery("INSERT INTO audit_log").
+		WithArgs(entry.Action, pq.Array(entry.ShortCodes), "user-1", domain.ActorUser, "203.0.113.7", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+
+	if err := repo.RecordAudit(context.Background(), entry); err != nil {
+		t.Fatalf("RecordAudit() error = %v", err)
+	}
+	if entry.ID != 42 || entry.CreatedAt.IsZero() {
+		t.Errorf("entry = %+v, want id 42 and a timestamp", entry)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -2,39 +2,122 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/sony/gobreaker"
 	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/chaos"
 	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
 )
 
 const (
-	urlCachePrefix = "url:"
-	rateLimitCache = "rl:"
+	urlCachePrefix   = "url:"
+	destCachePrefix  = "dest:"
+	aliasClaimPrefix = "claim:"
+	rateLimitCache   = "rl:"
 )
 
 type RedisCacheRepository struct {
 	client     *redis.Client
 	defaultTTL time.Duration
 	metrics    *metrics.Metrics
+	breaker    *gobreaker.CircuitBreaker // optional, nil disables it
+
+	serializer CacheSerializer
+	keyPrefix  string
+	hot        *hotKeys // nil when hot keys aren't sharded
+	getTimeout time.Duration
+	faults     chaos.Fault
 }
 
-func NewRedisCacheRepository(client *redis.Client, defaultTTL time.Duration, m *metrics.Metrics) *RedisCacheRepository {
+// RedisCacheOptions are the optional knobs of RedisCacheRepository
+// The zero value is JSON entries under the bare "url:" keys
+type RedisCacheOptions struct {
+	Serializer CacheSerializer
+
+	// KeyPrefix namespaces every cache key, e.g. "tenant-a:" gives
+	// "tenant-a:url:abc123", so deployments sharing a Redis can't collide
+	KeyPrefix string
+
+	// HotKeys spreads the entries of the most read links over several keys
+	HotKeys HotKeyConfig
+
+	// Faults are injected into every cache call in a -tags chaos build
+	Faults chaos.Fault
+
+	// GetTimeout bounds each cache read, 0 leaves it to the client's read
+	// timeout. The client needs ContextTimeoutEnabled for it to apply.
+	GetTimeout time.Duration
+}
+
+func NewRedisCacheRepository(client *redis.Client, defaultTTL time.Duration, m *metrics.Metrics, cb *gobreaker.CircuitBreaker, opts RedisCacheOptions) *RedisCacheRepository {
+	if opts.Serializer == nil {
+		opts.Serializer = jsonSerializer{}
+	}
 	return &RedisCacheRepository{
 		client:     client,
 		defaultTTL: defaultTTL,
 		metrics:    m,
+		breaker:    cb,
+		serializer: opts.Serializer,
+		keyPrefix:  opts.KeyPrefix,
+		hot:        newHotKeys(opts.HotKeys),
+		getTimeout: opts.GetTimeout,
+		faults:     opts.Faults,
+	}
+}
+
+// urlKey and destKey build every key this repository touches, so the
+// namespace can't be forgotten on one code path
+func (r *RedisCacheRepository) urlKey(shortCode string) string {
+	return r.keyPrefix + urlCachePrefix + shortCode
+}
+
+func (r *RedisCacheRepository) destKey(shortCode string) string {
+	return r.keyPrefix + destCachePrefix + shortCode
+}
+
+func (r *RedisCacheRepository) claimKey(shortCode string) string {
+	return r.keyPrefix + aliasClaimPrefix + shortCode
+}
+
+// IsCacheBreakerSuccess tells the breaker which Redis errors are healthy responses
+// A cache miss (redis.Nil) means Redis answered, so it must not trip the breaker
+func IsCacheBreakerSuccess(err error) bool {
+	return err == nil || errors.Is(err, redis.Nil)
+}
+
+// execute runs fn through the circuit breaker when one is configured
+// Learning: While the breaker is open, calls fail instantly instead of waiting
+// on dial/read timeouts against a dead Redis, so the redirect path falls back
+// to the DB without paying the timeout on every request
+func (r *RedisCacheRepository) execute(ctx context.Context, fn func() error) error {
+	if r.breaker == nil {
+		return r.faulty(ctx, fn)
+	}
+
+	_, err := r.breaker.Execute(func() (interface{}, error) {
+		return nil, r.faulty(ctx, fn)
+	})
+	return err
+}
+
+func (r *RedisCacheRepository) faulty(ctx context.Context, fn func() error) error {
+	if err := r.faults.Inject(ctx); err != nil {
+		return err
 	}
+	return fn()
 }
 
 func (r *RedisCacheRepository) Get(ctx context.Context, shortCode string) (*domain.URL, error) {
-	key := urlCachePrefix + shortCode
 	operation := "get"
 
-	data, err := r.client.Get(ctx, key).Bytes()
+	data, err := r.read(ctx, shortCode, r.urlKey(shortCode))
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			// Cache miss - key doesn't exist
@@ -44,14 +127,14 @@ func (r *RedisCacheRepository) Get(ctx context.Context, shortCode string) (*doma
 			return nil, nil
 		}
 
-		// Actual error (connection failure, timeout, etc.)
+		// Actual error (connection failure, timeout, open breaker, etc.)
 		// Learning: Track errors separately from misses
 		r.metrics.CacheErrors.WithLabelValues(operation).Inc()
 		return nil, err
 	}
 
 	var url domain.URL
-	if err := json.Unmarshal(data, &url); err != nil {
+	if err := r.serializer.Unmarshal(data, &url); err != nil {
 		// Deserialization error - data is corrupted
 		r.metrics.CacheErrors.WithLabelValues(operation).Inc()
 		return nil, err
@@ -69,15 +152,15 @@ func (r *RedisCacheRepository) Set(ctx context.Context, url *domain.URL, ttl tim
 		ttl = r.defaultTTL
 	}
 
-	key := urlCachePrefix + url.ShortURL
-	data, err := json.Marshal(url)
+	key := r.urlKey(url.ShortURL)
+	data, err := r.serializer.Marshal(url)
 	if err != nil {
 		// Serialization error
 		r.metrics.CacheErrors.WithLabelValues("set").Inc()
 		return err // Fixed: was returning nil, should return err
 	}
 
-	err = r.client.Set(ctx, key, data, ttl).Err()
+	err = r.write(ctx, url.ShortURL, key, data, ttl)
 	if err != nil {
 		// Redis write error
 		r.metrics.CacheErrors.WithLabelValues("set").Inc()
@@ -88,15 +171,169 @@ func (r *RedisCacheRepository) Set(ctx context.Context, url *domain.URL, ttl tim
 	return nil
 }
 
+// GetDestination reads the compact redirect entry, (nil, nil) on a miss
+// Learning: A plain "original_url|expires_at_unix" string skips struct
+// decoding entirely, which is most of the CPU a cache hit costs
+func (r *RedisCacheRepository) GetDestination(ctx context.Context, shortCode string) (*domain.Destination, error) {
+	operation := "get_destination"
+
+	data, err := r.read(ctx, shortCode, r.destKey(shortCode))
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			r.metrics.CacheMissesTotal.WithLabelValues(operation).Inc()
+			return nil, nil
+		}
+		r.metrics.CacheErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+
+	dest, err := decodeDestination(string(data))
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues(operation).Inc()
+		return nil, err
+	}
+	r.metrics.CacheHitsTotal.WithLabelValues(operation).Inc()
+	return dest, nil
+}
+
+func (r *RedisCacheRepository) SetDestination(ctx context.Context, shortCode string, dest domain.Destination, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = r.defaultTTL
+	}
+
+	err := r.write(ctx, shortCode, r.destKey(shortCode), encodeDestination(dest), ttl)
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("set_destination").Inc()
+	}
+	return err
+}
+
+// encodeDestination writes "original_url|expires_at_unix", the expiry is empty
+// for links that never expire
+// It is written into one buffer sized up front (an int64 is at most 20
+// digits), so encoding costs a single allocation
+func encodeDestination(dest domain.Destination) string {
+	var b strings.Builder
+	b.Grow(len(dest.OriginalURL) + 21)
+	b.WriteString(dest.OriginalURL)
+	b.WriteByte('|')
+	if dest.ExpiresAt != nil {
+		var digits [20]byte
+		b.Write(strconv.AppendInt(digits[:0], dest.ExpiresAt.Unix(), 10))
+	}
+	return b.String()
+}
+
+// decodeDestination splits on the last '|', URLs may contain the separator
+func decodeDestination(data string) (*domain.Destination, error) {
+	i := strings.LastIndexByte(data, '|')
+	if i < 0 {
+		return nil, fmt.Errorf("malformed destination cache entry %q", data)
+	}
+
+	dest := &domain.Destination{OriginalURL: data[:i]}
+	if expires := data[i+1:]; expires != "" {
+		unix, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed destination cache expiry %q: %w", expires, err)
+		}
+		t := time.Unix(unix, 0)
+		dest.ExpiresAt = &t
+	}
+	return dest, nil
+}
+
+// Delete evicts both the full and the compact entry for shortCode, and
+// their hot key copies
 func (r *RedisCacheRepository) Delete(ctx context.Context, shortCode string) error {
-	key := urlCachePrefix + shortCode
-	return r.client.Del(ctx, key).Err()
+	err := r.execute(ctx, func() error {
+		return r.client.Del(ctx, r.evictionKeys(shortCode)...).Err()
+	})
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("delete").Inc()
+	}
+	return err
+}
+
+// ClaimAlias is SET claim:<code> NX EX ttl
+// Learning: SET NX is atomic in Redis, so of two instances creating the same
+// alias at once exactly one gets true, without either touching the database
+func (r *RedisCacheRepository) ClaimAlias(ctx context.Context, code string, ttl time.Duration) (bool, error) {
+	var claimed bool
+	err := r.execute(ctx, func() error {
+		var err error
+		claimed, err = r.client.SetNX(ctx, r.claimKey(code), 1, ttl).Result()
+		return err
+	})
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("claim_alias").Inc()
+		return false, err
+	}
+	return claimed, nil
+}
+
+func (r *RedisCacheRepository) ReleaseAlias(ctx context.Context, code string) error {
+	err := r.execute(ctx, func() error {
+		return r.client.Del(ctx, r.claimKey(code)).Err()
+	})
+	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("release_alias").Inc()
+	}
+	return err
+}
+
+// DeleteMany evicts all codes with one pipelined round trip
+// Learning: One DEL per key in a pipeline (rather than a single multi-key DEL)
+// gives a result per key, so a partial failure says exactly what is still cached
+func (r *RedisCacheRepository) DeleteMany(ctx context.Context, shortCodes []string) error {
+	if len(shortCodes) == 0 {
+		return nil
+	}
+
+	var cmds []redis.Cmder
+	err := r.execute(ctx, func() error {
+		var err error
+		cmds, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, code := range shortCodes {
+				pipe.Del(ctx, r.evictionKeys(code)...)
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil && len(cmds) == 0 {
+		// Nothing was sent (open breaker, dial failure)
+		r.metrics.CacheErrors.WithLabelValues("delete_many").Add(float64(len(shortCodes)))
+		return err
+	}
+
+	var failed []string
+	var firstErr error
+	for i, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil {
+			failed = append(failed, shortCodes[i])
+			if firstErr == nil {
+				firstErr = cmdErr
+			}
+		}
+	}
+	if len(failed) > 0 {
+		r.metrics.CacheErrors.WithLabelValues("delete_many").Add(float64(len(failed)))
+		return fmt.Errorf("failed to evict %d of %d cache keys %v: %w", len(failed), len(shortCodes), failed, firstErr)
+	}
+	return nil
 }
 
 func (r *RedisCacheRepository) Exists(ctx context.Context, shortCode string) (bool, error) {
-	key := urlCachePrefix + shortCode
-	result, err := r.client.Exists(ctx, key).Result()
+	key := r.urlKey(shortCode)
+	var result int64
+	err := r.execute(ctx, func() error {
+		var err error
+		result, err = r.client.Exists(ctx, key).Result()
+		return err
+	})
 	if err != nil {
+		r.metrics.CacheErrors.WithLabelValues("exists").Inc()
 		return false, err
 	}
 	return result > 0, nil
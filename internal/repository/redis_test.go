@@ -0,0 +1,650 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/sony/gobreaker"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/breaker"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"go.uber.org/zap"
+)
+
+func TestRedisCacheBreakerOpensWhenRedisIsDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	cb := breaker.New("redis-test", breaker.Config{MaxFailures: 3, OpenTimeout: time.Minute}, zap.NewNop(), m, IsCacheBreakerSuccess)
+	repo := NewRedisCacheRepository(client, time.Hour, m, cb, RedisCacheOptions{})
+	ctx := context.Background()
+
+	// Misses are healthy answers and must not count as failures
+	for i := 0; i < 5; i++ {
+		if url, err := repo.Get(ctx, "missing"); err != nil || url != nil {
+			t.Fatalf("Get() on a miss = (%v, %v), want (nil, RedisCacheOptions{})", url, err)
+		}
+	}
+	if cb.State() != gobreaker.StateClosed {
+		t.Fatalf("breaker state after misses = %v, want closed", cb.State())
+	}
+
+	mr.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := repo.Set(ctx, &domain.URL{ShortURL: "abc123"}, time.Minute); err == nil {
+			t.Fatalf("Set() with Redis down returned nil error")
+		}
+	}
+
+	if cb.State() != gobreaker.StateOpen {
+		t.Fatalf("breaker state after failures = %v, want open", cb.State())
+	}
+	if _, err := repo.Get(ctx, "abc123"); err != gobreaker.ErrOpenState {
+		t.Errorf("Get() with open breaker error = %v, want %v", err, gobreaker.ErrOpenState)
+	}
+}
+
+func TestRedisScanCounterWindow(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	counter := NewRedisScanCounter(client, "")
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		got, err := counter.IncrNotFound(ctx, "10.0.0.1", time.Minute)
+		if err != nil {
+			t.Fatalf("IncrNotFound: %v", err)
+		}
+		if got != want {
+			t.Errorf("IncrNotFound = %d, want %d", got, want)
+		}
+	}
+
+	// Later 404s must not push the window out
+	mr.FastForward(30 * time.Second)
+	counter.IncrNotFound(ctx, "10.0.0.1", time.Minute)
+	if ttl := mr.TTL("scan:10.0.0.1"); ttl > 30*time.Second {
+		t.Errorf("TTL = %v, want the original window to keep running", ttl)
+	}
+
+	mr.FastForward(31 * time.Second)
+	count, err := counter.NotFoundCount(ctx, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("NotFoundCount: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("NotFoundCount = %d after the window, want 0", count)
+	}
+}
+
+func TestRedisClickLimiterWindow(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	limiter := NewRedisClickLimiter(client, "")
+	ctx := context.Background()
+
+	for i := 1; i <= 4; i++ {
+		allowed, err := limiter.Allow(ctx, "click:abc123", 3, time.Minute)
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		if want := i <= 3; allowed != want {
+			t.Errorf("hit %d allowed = %v, want %v", i, allowed, want)
+		}
+	}
+
+	// A fresh window starts once the first one expires
+	mr.FastForward(61 * time.Second)
+	if allowed, _ := limiter.Allow(ctx, "click:abc123", 3, time.Minute); !allowed {
+		t.Error("first hit of a new window was refused")
+	}
+}
+
+// roundTripCounter counts commands and pipelines sent to Redis
+type roundTripCounter struct {
+	roundTrips int
+}
+
+func (h *roundTripCounter) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *roundTripCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.roundTrips++
+		return next(ctx, cmd)
+	}
+}
+
+func (h *roundTripCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		h.roundTrips++
+		return next(ctx, cmds)
+	}
+}
+
+func TestRedisCacheDeleteManyUsesOneRoundTrip(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	repo := NewRedisCacheRepository(client, time.Hour, m, nil, RedisCacheOptions{})
+	ctx := context.Background()
+
+	codes := []string{"a1", "a2", "a3", "a4"}
+	for _, code := range codes {
+		if err := repo.Set(ctx, &domain.URL{ShortURL: code}, time.Minute); err != nil {
+			t.Fatalf("Set(%s) error = %v", code, err)
+		}
+	}
+	mr.Set("unrelated", "keep")
+
+	hook := &roundTripCounter{}
+	client.AddHook(hook)
+
+	if err := repo.DeleteMany(ctx, append(codes, "never-cached")); err != nil {
+		t.Fatalf("DeleteMany() error = %v", err)
+	}
+	if hook.roundTrips != 1 {
+		t.Errorf("round trips = %d, want 1", hook.roundTrips)
+	}
+	for _, code := range codes {
+		if mr.Exists(urlCachePrefix + code) {
+			t.Errorf("%s still cached after DeleteMany", code)
+		}
+	}
+	if !mr.Exists("unrelated") {
+		t.Error("DeleteMany removed an unrelated key")
+	}
+}
+
+func TestRedisCacheDeleteManyReportsFailures(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	repo := NewRedisCacheRepository(client, time.Hour, m, nil, RedisCacheOptions{})
+
+	mr.SetError("READONLY You can't write against a read only replica")
+	err := repo.DeleteMany(context.Background(), []string{"a1", "a2", "a3"})
+	if err == nil {
+		t.Fatal("DeleteMany() error = nil, want the failed keys reported")
+	}
+	if got := testutil.ToFloat64(m.CacheErrors.WithLabelValues("delete_many")); got != 3 {
+		t.Errorf("cache_errors_total{operation=delete_many} = %v, want 3", got)
+	}
+}
+
+func TestRedisDestinationCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	repo := NewRedisCacheRepository(client, time.Hour, metrics.NewMetricsWithRegistry(prometheus.NewRegistry()), nil, RedisCacheOptions{})
+	ctx := context.Background()
+	expires := time.Unix(1900000000, 0)
+
+	tests := map[string]domain.Destination{
+		"with expiry":      {OriginalURL: "https://example.com/a|b?c=d", ExpiresAt: &expires},
+		"never expires":    {OriginalURL: "https://example.com/"},
+		"separator at end": {OriginalURL: "https://example.com/x|"},
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := repo.SetDestination(ctx, "abc123", want, time.Minute); err != nil {
+				t.Fatalf("SetDestination() error = %v", err)
+			}
+			got, err := repo.GetDestination(ctx, "abc123")
+			if err != nil || got == nil {
+				t.Fatalf("GetDestination() = (%v, %v)", got, err)
+			}
+			if got.OriginalURL != want.OriginalURL {
+				t.Errorf("OriginalURL = %q, want %q", got.OriginalURL, want.OriginalURL)
+			}
+			if (got.ExpiresAt == nil) != (want.ExpiresAt == nil) || (want.ExpiresAt != nil && !got.ExpiresAt.Equal(*want.ExpiresAt)) {
+				t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, want.ExpiresAt)
+			}
+		})
+	}
+
+	if err := repo.Set(ctx, &domain.URL{ShortURL: "abc123"}, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := repo.Delete(ctx, "abc123"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if mr.Exists(urlCachePrefix+"abc123") || mr.Exists(destCachePrefix+"abc123") {
+		t.Error("Delete() left a cache entry behind")
+	}
+}
+
+// BenchmarkRedisRedirectLookup compares a full-entry read with the compact
+// destination read that redirects use when REDIS_COMPACT_REDIRECT_CACHE is on
+func BenchmarkRedisRedirectLookup(b *testing.B) {
+	mr := miniredis.RunT(b)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	repo := NewRedisCacheRepository(client, time.Hour, metrics.NewMetricsWithRegistry(prometheus.NewRegistry()), nil, RedisCacheOptions{})
+	ctx := context.Background()
+	owner := "alice"
+	expires := time.Now().Add(time.Hour)
+	url := &domain.URL{
+		ID: 1, ShortURL: "abc123", OriginalURL: "https://example.com/landing?utm_source=newsletter",
+		UserID: &owner, CreatedAt: time.Now(), UpdatedAt: time.Now(), ExpiresAt: &expires, IsActive: true,
+	}
+	_ = repo.Set(ctx, url, time.Hour)
+	_ = repo.SetDestination(ctx, url.ShortURL, domain.Destination{OriginalURL: url.OriginalURL, ExpiresAt: url.ExpiresAt}, time.Hour)
+
+	b.Run("full", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.Get(ctx, url.ShortURL); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("compact", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetDestination(ctx, url.ShortURL); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestRedisCacheKeyPrefixIsolatesNamespaces(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	tenantA := NewRedisCacheRepository(client, time.Hour, m, nil, RedisCacheOptions{KeyPrefix: "tenant-a:"})
+	tenantB := NewRedisCacheRepository(client, time.Hour, m, nil, RedisCacheOptions{KeyPrefix: "tenant-b:"})
+	ctx := context.Background()
+
+	if err := tenantA.Set(ctx, &domain.URL{ShortURL: "abc123", OriginalURL: "https://a.example.com"}, time.Minute); err != nil {
+		t.Fatalf("tenant A Set() error = %v", err)
+	}
+	if err := tenantB.Set(ctx, &domain.URL{ShortURL: "abc123", OriginalURL: "https://b.example.com"}, time.Minute); err != nil {
+		t.Fatalf("tenant B Set() error = %v", err)
+	}
+	if err := tenantA.SetDestination(ctx, "abc123", domain.Destination{OriginalURL: "https://a.example.com"}, time.Minute); err != nil {
+		t.Fatalf("tenant A SetDestination() error = %v", err)
+	}
+
+	for _, key := range []string{"tenant-a:url:abc123", "tenant-b:url:abc123", "tenant-a:dest:abc123"} {
+		if !mr.Exists(key) {
+			t.Errorf("key %q was not written", key)
+		}
+	}
+	if mr.Exists(urlCachePrefix + "abc123") {
+		t.Error("an un-namespaced key was written")
+	}
+
+	got, err := tenantA.Get(ctx, "abc123")
+	if err != nil || got == nil || got.OriginalURL != "https://a.example.com" {
+		t.Errorf("tenant A Get() = (%+v, %v), want its own entry", got, err)
+	}
+
+	if err := tenantA.Delete(ctx, "abc123"); err != nil {
+		t.Fatalf("tenant A Delete() error = %v", err)
+	}
+	if ok, _ := tenantA.Exists(ctx, "abc123"); ok {
+		t.Error("tenant A entry survived Delete()")
+	}
+	if mr.Exists("tenant-a:dest:abc123") {
+		t.Error("tenant A destination entry survived Delete()")
+	}
+	if ok, _ := tenantB.Exists(ctx, "abc123"); !ok {
+		t.Error("tenant A Delete() removed tenant B's entry")
+	}
+
+	if err := tenantB.DeleteMany(ctx, []string{"abc123"}); err != nil {
+		t.Fatalf("tenant B DeleteMany() error = %v", err)
+	}
+	if mr.Exists("tenant-b:url:abc123") {
+		t.Error("tenant B entry survived DeleteMany()")
+	}
+}
+
+func TestRedisClaimAliasIsExclusive(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	repo := NewRedisCacheRepository(client, time.Hour, m, nil, RedisCacheOptions{KeyPrefix: "t:"})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var wins atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed, err := repo.ClaimAlias(ctx, "launch", 10*time.Second)
+			if err != nil {
+				t.Errorf("ClaimAlias() error = %v", err)
+			}
+			if claimed {
+				wins.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if wins.Load() != 1 {
+		t.Fatalf("claims won = %d, want exactly 1", wins.Load())
+	}
+	if ttl := mr.TTL("t:claim:launch"); ttl != 10*time.Second {
+		t.Errorf("claim TTL = %v, want 10s", ttl)
+	}
+
+	if err := repo.ReleaseAlias(ctx, "launch"); err != nil {
+		t.Fatalf("ReleaseAlias() error = %v", err)
+	}
+	if claimed, _ := repo.ClaimAlias(ctx, "launch", time.Second); !claimed {
+		t.Error("alias could not be claimed again after release")
+	}
+
+	mr.FastForward(2 * time.Second)
+	if claimed, _ := repo.ClaimAlias(ctx, "launch", time.Second); !claimed {
+		t.Error("lapsed claim still blocks the alias")
+	}
+}
+
+func TestDestinationCodecAllocations(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	dest := domain.Destination{OriginalURL: "https://example.com/landing?utm_source=newsletter", ExpiresAt: &expires}
+	encoded := encodeDestination(dest)
+
+	if n := testing.AllocsPerRun(100, func() { _ = encodeDestination(dest) }); n > 1 {
+		t.Errorf("encodeDestination allocations = %v, want at most 1", n)
+	}
+	// The Destination and its expiry
+	if n := testing.AllocsPerRun(100, func() { _, _ = decodeDestination(encoded) }); n > 2 {
+		t.Errorf("decodeDestination allocations = %v, want at most 2", n)
+	}
+}
+
+func BenchmarkDestinationCodec(b *testing.B) {
+	expires := time.Now().Add(time.Hour)
+	dest := domain.Destination{OriginalURL: "https://example.com/landing?utm_source=newsletter", ExpiresAt: &expires}
+	encoded := encodeDestination(dest)
+
+	b.Run("encode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = encodeDestination(dest)
+		}
+	})
+	b.Run("decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := decodeDestination(encoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func newHotKeyRepo(t *testing.T) (*RedisCacheRepository, *miniredis.Miniredis, *metrics.Metrics) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	repo := NewRedisCacheRepository(client, time.Hour, m, nil, RedisCacheOptions{
+		HotKeys: HotKeyConfig{Shards: 4, Threshold: 3, Window: time.Minute},
+	})
+	return repo, mr, m
+}
+
+func TestRedisHotKeyReadsSpreadOverShards(t *testing.T) {
+	repo, mr, m := newHotKeyRepo(t)
+	ctx := context.Background()
+
+	if err := repo.Set(ctx, &domain.URL{ShortURL: "viral", OriginalURL: "https://example.com"}, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	// Below the threshold the link stays single-keyed
+	for i := 0; i < 2; i++ {
+		if _, err := repo.Get(ctx, "viral"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	if mr.Exists("url:viral:0") {
+		t.Fatal("a cold key was replicated")
+	}
+
+	// The read reaching the threshold copies the entry to every shard, TTL included
+	if url, err := repo.Get(ctx, "viral"); err != nil || url == nil || url.OriginalURL != "https://example.com" {
+		t.Fatalf("promoting Get() = (%+v, %v)", url, err)
+	}
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("url:viral:%d", i)
+		if !mr.Exists(key) || mr.TTL(key) <= 0 || mr.TTL(key) > time.Minute {
+			t.Fatalf("shard %s exists = %v, ttl %v; want a copy expiring with the entry", key, mr.Exists(key), mr.TTL(key))
+		}
+	}
+	if got := testutil.ToFloat64(m.CacheHotKeyPromotionsTotal); got != 1 {
+		t.Errorf("promotions = %v, want 1", got)
+	}
+
+	// Mark each copy so the test can tell which one a read was served from
+	for i := 0; i < 4; i++ {
+		mr.Set(fmt.Sprintf("url:viral:%d", i), fmt.Sprintf(`{"short_code":"viral","original_url":"https://example.com/%d"}`, i))
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 64; i++ {
+		ctx := domain.WithClientIP(context.Background(), fmt.Sprintf("198.51.100.%d", i))
+		url, err := repo.Get(ctx, "viral")
+		if err != nil || url == nil {
+			t.Fatalf("Get() = (%v, %v)", url, err)
+		}
+		seen[url.OriginalURL] = true
+
+		again, _ := repo.Get(ctx, "viral")
+		if again.OriginalURL != url.OriginalURL {
+			t.Errorf("client %d read %s then %s, want the same shard", i, url.OriginalURL, again.OriginalURL)
+		}
+	}
+	if len(seen) != 4 {
+		t.Errorf("reads were served by %d shards (%v), want all 4", len(seen), seen)
+	}
+
+	// A missing copy is rebuilt from the primary entry
+	mr.Del("url:viral:0")
+	mr.Del("url:viral:1")
+	mr.Del("url:viral:2")
+	mr.Del("url:viral:3")
+	if url, err := repo.Get(ctx, "viral"); err != nil || url == nil || url.OriginalURL != "https://example.com" {
+		t.Fatalf("Get() with copies gone = (%+v, %v), want the primary entry", url, err)
+	}
+	if !mr.Exists("url:viral:0") {
+		t.Error("copies were not rebuilt")
+	}
+}
+
+func TestRedisHotKeyWritesFanOut(t *testing.T) {
+	repo, mr, _ := newHotKeyRepo(t)
+	ctx := context.Background()
+
+	repo.Set(ctx, &domain.URL{ShortURL: "viral", OriginalURL: "https://example.com/old"}, time.Minute)
+	for i := 0; i < 3; i++ {
+		repo.Get(ctx, "viral")
+	}
+
+	if err := repo.Set(ctx, &domain.URL{ShortURL: "viral", OriginalURL: "https://example.com/new"}, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if data, _ := mr.Get(fmt.Sprintf("url:viral:%d", i)); !strings.Contains(data, "/new") {
+			t.Errorf("shard %d = %s, want the new entry", i, data)
+		}
+	}
+
+	// Writes to a cold key clear any copies left by an earlier promotion
+	repo.hot.now = func() time.Time { return time.Now().Add(3 * time.Minute) }
+	repo.Set(ctx, &domain.URL{ShortURL: "viral", OriginalURL: "https://example.com/cold"}, time.Minute)
+	if mr.Exists("url:viral:0") {
+		t.Error("a write to a demoted key left its copies behind")
+	}
+
+	// Eviction always includes the copies
+	for i := 0; i < 4; i++ {
+		mr.Set(fmt.Sprintf("dest:viral:%d", i), "https://example.com/cold|")
+	}
+	if err := repo.Delete(ctx, "viral"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("keys after Delete() = %v, want none", keys)
+	}
+}
+
+// stalledRedis accepts connections and never answers, like a Redis stuck on
+// a slow command or a saturated network
+func stalledRedis(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestRedisGetTimeoutFallsBackToDB(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr:                  stalledRedis(t),
+		ReadTimeout:           10 * time.Second,
+		WriteTimeout:          10 * time.Second,
+		ContextTimeoutEnabled: true,
+	})
+	defer client.Close()
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	cacheRepo := NewRedisCacheRepository(client, time.Hour, m, nil, RedisCacheOptions{GetTimeout: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	start := time.Now()
+	if _, err := cacheRepo.Get(ctx, "abc123"); err == nil {
+		t.Fatal("Get() from a stalled Redis returned no error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Get() took %v, want about the 50ms get timeout", elapsed)
+	}
+
+	urlRepo := memory.NewURLRepository()
+	if err := urlRepo.Create(ctx, &domain.URL{ShortURL: "abc123", OriginalURL: "https://example.com"}); err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+	svc := service.NewURLService(urlRepo, cacheRepo, keyGen, nil, urlRepo, zap.NewNop(), m, service.URLServiceConfig{})
+
+	// The redirect gives up on the cache, reads the DB and doesn't wait on
+	// writing the entry back either
+	start = time.Now()
+	url, err := svc.Visit(ctx, "abc123")
+	if err != nil || url.OriginalURL != "https://example.com" {
+		t.Fatalf("Visit() = %v, %v, want the link from the DB", url, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Visit() took %v with a stalled Redis, want the DB fallback right after the get timeout", elapsed)
+	}
+	if got := testutil.ToFloat64(m.CacheErrors.WithLabelValues("get")); got != 2 {
+		t.Errorf("cache get errors = %v, want 2", got)
+	}
+}
+
+func TestRedisKeyPrefixCoversEveryKey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+
+	sinks := map[string]*fakeClickSink{}
+	for _, prefix := range []string{"a:", "b:"} {
+		cache := NewRedisCacheRepository(client, time.Hour, m, nil, RedisCacheOptions{KeyPrefix: prefix})
+		if err := cache.Set(ctx, &domain.URL{ShortURL: "abc123"}, time.Minute); err != nil {
+			t.Fatalf("%s cache Set() error = %v", prefix, err)
+		}
+		if err := NewRedisClickCounter(client, prefix).Incr(ctx, "abc123"); err != nil {
+			t.Fatalf("%s click Incr() error = %v", prefix, err)
+		}
+		// Each namespace gets its own limiter budget
+		if ok, err := NewRedisClickLimiter(client, prefix).Allow(ctx, "1.2.3.4", 1, time.Minute); err != nil || !ok {
+			t.Fatalf("%s limiter Allow() = (%v, %v), want allowed", prefix, ok, err)
+		}
+		if n, err := NewRedisScanCounter(client, prefix).IncrNotFound(ctx, "1.2.3.4", time.Minute); err != nil || n != 1 {
+			t.Fatalf("%s IncrNotFound() = (%d, %v), want 1", prefix, n, err)
+		}
+		// The same machine id is free in each namespace
+		lease, err := AcquireMachineID(ctx, client, 5, MachineLeaseConfig{TTL: time.Minute, KeyPrefix: prefix}, zap.NewNop())
+		if err != nil {
+			t.Fatalf("%s AcquireMachineID() error = %v", prefix, err)
+		}
+		defer lease.Release(ctx)
+		sinks[prefix] = newFakeClickSink()
+	}
+
+	for _, key := range mr.Keys() {
+		if !strings.HasPrefix(key, "a:") && !strings.HasPrefix(key, "b:") {
+			t.Errorf("key %q is outside REDIS_KEY_PREFIX", key)
+		}
+	}
+	for _, key := range []string{"a:clicks:abc123", "a:clickq:pending", "a:machine:5", "b:clicks:abc123", "b:machine:5"} {
+		if !mr.Exists(key) {
+			t.Errorf("key %q was not written", key)
+		}
+	}
+
+	// A flusher only drains the counters of its own namespace
+	flusher := NewClickFlusher(client, sinks["a:"], ClickFlusherConfig{KeyPrefix: "a:"}, m, zap.NewNop())
+	if err := flusher.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := sinks["a:"].counts["abc123"]; got != 1 {
+		t.Errorf("flushed count = %d, want 1", got)
+	}
+	if mr.Exists("a:clicks:abc123") || !mr.Exists("b:clicks:abc123") {
+		t.Error("flusher drained the wrong namespace")
+	}
+}
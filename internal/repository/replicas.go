@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxPinnedCodes bounds the recent-writes map; expired pins are swept once
+// it grows past this
+const maxPinnedCodes = 10000
+
+// readReplicas spreads redirect lookups over read-only copies of the database
+//
+// Learning: Replication is asynchronous, so a replica can be a moment behind
+// the primary. Two things keep that invisible:
+//   - a code written through this instance (created, disabled, re-expired) is
+//     read from the primary for lagWindow afterwards, so the service never
+//     re-caches the old row right after invalidating it
+//   - a replica that doesn't have the row yet answers "no rows", which is
+//     retried on the primary, so a brand new link created elsewhere still
+//     resolves (write-through caching usually answers before we get here)
+//
+// Writes made by other instances can still be read stale for up to the
+// replication lag; keep lagWindow above the replicas' worst lag.
+type readReplicas struct {
+	dbs       []*sqlx.DB
+	next      atomic.Uint64
+	lagWindow time.Duration
+
+	mu     sync.Mutex
+	pinned map[string]time.Time // short code -> read from the primary until
+}
+
+// UseReadReplicas routes GetByShortCode to replicas round-robin
+// Every other query, reads included, stays on the primary.
+func (r *PostgresURLRepository) UseReadReplicas(dbs []*sqlx.DB, lagWindow time.Duration) {
+	if len(dbs) == 0 {
+		r.replicas = nil
+		return
+	}
+	if lagWindow <= 0 {
+		lagWindow = 5 * time.Second
+	}
+	r.replicas = &readReplicas{dbs: dbs, lagWindow: lagWindow, pinned: make(map[string]time.Time)}
+}
+
+// pick returns the replica to read shortCode from, nil means the primary
+func (rr *readReplicas) pick(shortCode string) *sqlx.DB {
+	if rr == nil {
+		return nil
+	}
+
+	rr.mu.Lock()
+	until, ok := rr.pinned[shortCode]
+	rr.mu.Unlock()
+	if ok && time.Now().Before(until) {
+		return nil
+	}
+
+	n := rr.next.Add(1) - 1
+	return rr.dbs[n%uint64(len(rr.dbs))]
+}
+
+// pin sends reads of shortCode to the primary until the replicas caught up
+func (rr *readReplicas) pin(shortCode string) {
+	if rr == nil {
+		return
+	}
+
+	now := time.Now()
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if len(rr.pinned) >= maxPinnedCodes {
+		for code, until := range rr.pinned {
+			if now.After(until) {
+				delete(rr.pinned, code)
+			}
+		}
+	}
+	rr.pinned[shortCode] = now.Add(rr.lagWindow)
+}
+
+// readFromReplica runs a single-row read against a replica when one applies
+// served=false means the primary must answer instead: no replica, a pinned
+// code, a row the replica doesn't have yet, or a failing replica. Replica
+// errors never go through the primary's breaker or retries.
+func (r *PostgresURLRepository) readFromReplica(ctx context.Context, shortCode string, read func(db *sqlx.DB) error) (served bool, err error) {
+	db := r.replicas.pick(shortCode)
+	if db == nil {
+		return false, nil
+	}
+
+	// Injected faults hit replicas too, so a failing replica can be
+	// rehearsed; an injected error falls back to the primary like a real one
+	if err = r.faults.Inject(ctx); err == nil {
+		err = read(db)
+	}
+	switch {
+	case err == nil:
+		r.metrics.DBReplicaReadsTotal.WithLabelValues("replica").Inc()
+		return true, nil
+	case ctx.Err() != nil:
+		// The caller is gone, the primary won't do better
+		return true, err
+	case errors.Is(err, sql.ErrNoRows):
+		r.metrics.DBReplicaReadsTotal.WithLabelValues("fallback_missing").Inc()
+	default:
+		r.metrics.DBReplicaReadsTotal.WithLabelValues("fallback_error").Inc()
+	}
+	return false, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+func newMockReplica(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+	return sqlx.NewDb(mockDB, "postgres"), mock
+}
+
+func urlRow(shortCode string) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows(urlColumns).AddRow(1, shortCode, "https://example.com", nil, now, now, nil, 0, true, "public", false, nil, "", "", "", false, "", nil, "{}", "{}", "", "")
+}
+
+func TestReadReplicasServeLookupsRoundRobin(t *testing.T) {
+	repo, primary, m := newMockPostgresRepo(t, nil)
+	replicaA, mockA := newMockReplica(t)
+	replicaB, mockB := newMockReplica(t)
+	repo.UseReadReplicas([]*sqlx.DB{replicaA, replicaB}, time.Minute)
+	ctx := context.Background()
+
+	mockA.ExpectQuery("SELECT (.+) FROM urls").WithArgs("abc123", nil).WillReturnRows(urlRow("abc123"))
+	mockB.ExpectQuery("SELECT (.+) FROM urls").WithArgs("abc123", nil).WillReturnRows(urlRow("abc123"))
+	mockA.ExpectQuery("SELECT (.+) FROM urls").WithArgs("abc123", nil).WillReturnRows(urlRow("abc123"))
+	for i := 0; i < 3; i++ {
+		if _, err := repo.GetByShortCode(ctx, "abc123"); err != nil {
+			t.Fatalf("GetByShortCode() error = %v", err)
+		}
+	}
+
+	for name, mock := range map[string]sqlmock.Sqlmock{"primary": primary, "replica A": mockA, "replica B": mockB} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if got := testutil.ToFloat64(m.DBReplicaReadsTotal.WithLabelValues("replica")); got != 3 {
+		t.Errorf("db_replica_reads_total{replica} = %v, want 3", got)
+	}
+}
+
+func TestReadReplicasWritesGoToPrimaryAndPinReads(t *testing.T) {
+	repo, primary, _ := newMockPostgresRepo(t, nil)
+	replica, replicaMock := newMockReplica(t)
+	repo.UseReadReplicas([]*sqlx.DB{replica}, time.Minute)
+	ctx := context.Background()
+
+	// The write and the read right after it both hit the primary; the
+	// replica, which may not have the row yet, sees nothing
+	primary.ExpectQuery("INSERT INTO urls").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	primary.ExpectQuery("SELECT (.+) FROM urls").WithArgs("new001", nil).WillReturnRows(urlRow("new001"))
+	if err := repo.Create(ctx, &domain.URL{ShortURL: "new001", OriginalURL: "https://example.com"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := repo.GetByShortCode(ctx, "new001"); err != nil {
+		t.Fatalf("GetByShortCode() error = %v", err)
+	}
+
+	primary.ExpectExec("UPDATE urls").WillReturnResult(sqlmock.NewResult(0, 1))
+	primary.ExpectQuery("SELECT (.+) FROM urls").WithArgs("old001", nil).WillReturnRows(urlRow("old001"))
+	if err := repo.SetActive(ctx, "old001", true); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	if _, err := repo.GetByShortCode(ctx, "old001"); err != nil {
+		t.Fatalf("GetByShortCode() error = %v", err)
+	}
+
+	if err := primary.ExpectationsWereMet(); err != nil {
+		t.Errorf("primary: %v", err)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("replica: %v", err)
+	}
+}
+
+func TestReadReplicasFallBackToPrimary(t *testing.T) {
+	repo, primary, m := newMockPostgresRepo(t, nil)
+	replica, replicaMock := newMockReplica(t)
+	repo.UseReadReplicas([]*sqlx.DB{replica}, time.Minute)
+	ctx := context.Background()
+
+	// Created through another instance, not replicated yet
+	replicaMock.ExpectQuery("SELECT (.+) FROM urls").WillReturnRows(sqlmock.NewRows(urlColumns))
+	primary.ExpectQuery("SELECT (.+) FROM urls").WillReturnRows(urlRow("lag001"))
+	// Replica down
+	replicaMock.ExpectQuery("SELECT (.+) FROM urls").WillReturnError(errors.New("connection refused"))
+	primary.ExpectQuery("SELECT (.+) FROM urls").WillReturnRows(urlRow("down01"))
+
+	for _, code := range []string{"lag001", "down01"} {
+		url, err := repo.GetByShortCode(ctx, code)
+		if err != nil || url.ShortURL != code {
+			t.Fatalf("GetByShortCode(%q) = %v, %v", code, url, err)
+		}
+	}
+
+	if err := primary.ExpectationsWereMet(); err != nil {
+		t.Errorf("primary: %v", err)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("replica: %v", err)
+	}
+	for _, result := range []string{"fallback_missing", "fallback_error"} {
+		if got := testutil.ToFloat64(m.DBReplicaReadsTotal.WithLabelValues(result)); got != 1 {
+			t.Errorf("db_replica_reads_total{%s} = %v, want 1", result, got)
+		}
+	}
+}
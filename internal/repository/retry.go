@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RetryPolicy bounds how transient Postgres errors are retried
+// The zero value means DefaultRetryPolicy; MaxAttempts 1 disables retries
+type RetryPolicy struct {
+	MaxAttempts int           // total tries, including the first
+	BaseDelay   time.Duration // backoff before the second try, doubled after each
+	MaxDelay    time.Duration // cap on a single backoff
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   20 * time.Millisecond,
+	MaxDelay:    200 * time.Millisecond,
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = max(DefaultRetryPolicy.MaxDelay, p.BaseDelay)
+	}
+	return p
+}
+
+// backoff returns a full-jitter delay before retry number n (1-based)
+// Learning: Random delays keep a burst of failed requests from retrying in
+// lockstep and hitting a recovering database all at once
+func (p RetryPolicy) backoff(n int) time.Duration {
+	ceiling := p.BaseDelay << (n - 1)
+	if ceiling > p.MaxDelay || ceiling <= 0 {
+		ceiling = p.MaxDelay
+	}
+	return rand.N(ceiling) + 1
+}
+
+// isTransientReadError reports errors worth retrying for a read
+// Reads are idempotent, so a dropped connection is fine to retry too
+func isTransientReadError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code.Class() == "08" || isRolledBack(pqErr)
+}
+
+// isTransientWriteError reports errors after which the write certainly did
+// not happen. A connection lost mid-INSERT is not one of them: the row may have
+// committed, and a retry would then report our own row as a duplicate.
+func isTransientWriteError(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && isRolledBack(pqErr)
+}
+
+func isRolledBack(pqErr *pq.Error) bool {
+	switch pqErr.Code {
+	case "40001", // serialization_failure
+		"40P01", // deadlock_detected
+		"53300", // too_many_connections
+		"57P03": // cannot_connect_now, e.g. during startup or failover
+		return true
+	}
+	return false
+}
+
+// executeWithRetry is execute plus retries with backoff for the errors
+// transient accepts, until fn succeeds, the attempts run out or ctx is done
+// Every attempt goes through the breaker, so retried failures still count
+func (r *PostgresURLRepository) executeWithRetry(ctx context.Context, operation string, transient func(error) bool, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = r.execute(ctx, fn)
+		if err == nil || attempt >= r.retry.MaxAttempts || !transient(err) {
+			return err
+		}
+
+		r.metrics.DBRetriesTotal.WithLabelValues(operation).Inc()
+		timer := time.NewTimer(r.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
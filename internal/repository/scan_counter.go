@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const scanCounterPrefix = "scan:"
+
+// RedisScanCounter keeps per-client 404 counts in Redis for the scan detector
+// Each key is a fixed window: the TTL is set by the first 404 and the count
+// resets when it expires
+type RedisScanCounter struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisScanCounter namespaces its keys with keyPrefix, as
+// RedisCacheOptions.KeyPrefix
+func NewRedisScanCounter(client *redis.Client, keyPrefix string) *RedisScanCounter {
+	return &RedisScanCounter{client: client, keyPrefix: keyPrefix}
+}
+
+func (r *RedisScanCounter) IncrNotFound(ctx context.Context, key string, window time.Duration) (int64, error) {
+	redisKey := r.keyPrefix + scanCounterPrefix + key
+
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, redisKey)
+	// NX: only the first 404 in a window starts the clock
+	pipe.ExpireNX(ctx, redisKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (r *RedisScanCounter) NotFoundCount(ctx context.Context, key string) (int64, error) {
+	count, err := r.client.Get(ctx, r.keyPrefix+scanCounterPrefix+key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return count, err
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/ugorji/go/codec"
+)
+
+// Cache serialization formats, selected with REDIS_CACHE_FORMAT
+const (
+	CacheFormatJSON    = "json"
+	CacheFormatGob     = "gob"
+	CacheFormatMsgpack = "msgpack"
+)
+
+// CacheSerializer turns cached URLs into bytes and back
+// Learning: Every redirect that hits the cache pays one Unmarshal, so at high
+// volume the format matters. JSON stays the default because `redis-cli GET`
+// shows something a human can read.
+// Entries written in a different format fail to decode, which the service
+// treats as a miss, so switching formats heals itself as keys are rewritten.
+type CacheSerializer interface {
+	Format() string
+	Marshal(url *domain.URL) ([]byte, error)
+	Unmarshal(data []byte, url *domain.URL) error
+}
+
+// NewCacheSerializer returns the serializer for format, "" means JSON
+func NewCacheSerializer(format string) (CacheSerializer, error) {
+	switch format {
+	case "", CacheFormatJSON:
+		return jsonSerializer{}, nil
+	case CacheFormatGob:
+		return gobSerializer{}, nil
+	case CacheFormatMsgpack:
+		return newMsgpackSerializer(), nil
+	default:
+		return nil, fmt.Errorf("unknown cache format %q (want json, gob or msgpack)", format)
+	}
+}
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Format() string { return CacheFormatJSON }
+
+func (jsonSerializer) Marshal(url *domain.URL) ([]byte, error) {
+	return json.Marshal(url)
+}
+
+func (jsonSerializer) Unmarshal(data []byte, url *domain.URL) error {
+	return json.Unmarshal(data, url)
+}
+
+// gobSerializer needs nothing outside the stdlib
+// Learning: Each entry is encoded standalone, so every payload repeats gob's
+// type description; see BenchmarkCacheSerializers before picking it for speed
+type gobSerializer struct{}
+
+func (gobSerializer) Format() string { return CacheFormatGob }
+
+func (gobSerializer) Marshal(url *domain.URL) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(url); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobSerializer) Unmarshal(data []byte, url *domain.URL) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(url)
+}
+
+// msgpackSerializer is the smallest payload; keys follow the json tags so the
+// two formats carry the same field names
+// Learning: building a codec Encoder/Decoder allocates its internal buffers,
+// more than the payload itself, so they are pooled and reset per call
+type msgpackSerializer struct {
+	handle   *codec.MsgpackHandle
+	encoders *sync.Pool
+	decoders *sync.Pool
+}
+
+func newMsgpackSerializer() msgpackSerializer {
+	h := &codec.MsgpackHandle{}
+	h.WriteExt = true // encode time.Time as the msgpack timestamp extension
+	h.TypeInfos = codec.NewTypeInfos([]string{"json"})
+	return msgpackSerializer{
+		handle:   h,
+		encoders: &sync.Pool{New: func() any { return codec.NewEncoderBytes(nil, h) }},
+		decoders: &sync.Pool{New: func() any { return codec.NewDecoderBytes(nil, h) }},
+	}
+}
+
+func (msgpackSerializer) Format() string { return CacheFormatMsgpack }
+
+func (s msgpackSerializer) Marshal(url *domain.URL) ([]byte, error) {
+	enc := s.encoders.Get().(*codec.Encoder)
+	defer s.encoders.Put(enc)
+
+	var data []byte
+	enc.ResetBytes(&data)
+	if err := enc.Encode(url); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s msgpackSerializer) Unmarshal(data []byte, url *domain.URL) error {
+	dec := s.decoders.Get().(*codec.Decoder)
+	defer s.decoders.Put(dec)
+
+	dec.ResetBytes(data)
+	return dec.Decode(url)
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+)
+
+var cacheFormats = []string{CacheFormatJSON, CacheFormatGob, CacheFormatMsgpack}
+
+func sampleURLs() map[string]*domain.URL {
+	created := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	expires := created.Add(24 * time.Hour)
+	owner := "alice"
+
+	return map[string]*domain.URL{
+		"all fields": {
+			ID: 42, ShortURL: "abc123", OriginalURL: "https://example.com/a?b=c",
+			UserID: &owner, CreatedAt: created, UpdatedAt: created, ExpiresAt: &expires,
+			ClickCount: 7, IsActive: true, Visibility: domain.VisibilityPrivate, Signed: true,
+		},
+		"nil pointers": {
+			ID: 43, ShortURL: "def456", OriginalURL: "https://example.com/",
+			CreatedAt: created, UpdatedAt: created, IsActive: false,
+		},
+	}
+}
+
+// assertSameURL compares field by field; times by instant, since decoders
+// may hand back a different *time.Location for the same moment
+func assertSameURL(t *testing.T, got, want *domain.URL) {
+	t.Helper()
+
+	if !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("timestamps = %v/%v, want %v/%v", got.CreatedAt, got.UpdatedAt, want.CreatedAt, want.UpdatedAt)
+	}
+	switch {
+	case want.ExpiresAt == nil && got.ExpiresAt != nil:
+		t.Errorf("ExpiresAt = %v, want nil", *got.ExpiresAt)
+	case want.ExpiresAt != nil && (got.ExpiresAt == nil || !got.ExpiresAt.Equal(*want.ExpiresAt)):
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, *want.ExpiresAt)
+	}
+
+	g, w := *got, *want
+	g.CreatedAt, g.UpdatedAt, g.ExpiresAt = time.Time{}, time.Time{}, nil
+	w.CreatedAt, w.UpdatedAt, w.ExpiresAt = time.Time{}, time.Time{}, nil
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("decoded = %+v, want %+v", g, w)
+	}
+}
+
+func TestCacheSerializersRoundTrip(t *testing.T) {
+	for _, format := range cacheFormats {
+		s, err := NewCacheSerializer(format)
+		if err != nil {
+			t.Fatalf("NewCacheSerializer(%q) error = %v", format, err)
+		}
+		for name, want := range sampleURLs() {
+			t.Run(format+"/"+name, func(t *testing.T) {
+				data, err := s.Marshal(want)
+				if err != nil {
+					t.Fatalf("Marshal() error = %v", err)
+				}
+				var got domain.URL
+				if err := s.Unmarshal(data, &got); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+				assertSameURL(t, &got, want)
+			})
+		}
+	}
+}
+
+func TestNewCacheSerializerRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewCacheSerializer("xml"); err == nil {
+		t.Error("NewCacheSerializer(xml) returned nil error")
+	}
+}
+
+func TestRedisCacheUsesConfiguredSerializer(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	s, _ := NewCacheSerializer(CacheFormatMsgpack)
+	repo := NewRedisCacheRepository(client, time.Hour, metrics.NewMetricsWithRegistry(prometheus.NewRegistry()), nil, RedisCacheOptions{Serializer: s})
+	ctx := context.Background()
+
+	want := sampleURLs()["all fields"]
+	if err := repo.Set(ctx, want, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	raw, _ := mr.Get(urlCachePrefix + want.ShortURL)
+	if len(raw) > 0 && raw[0] == '{' {
+		t.Fatalf("cached payload looks like JSON, serializer was not used: %q", raw)
+	}
+
+	got, err := repo.Get(ctx, want.ShortURL)
+	if err != nil || got == nil {
+		t.Fatalf("Get() = (%v, %v), want the cached URL", got, err)
+	}
+	assertSameURL(t, got, want)
+}
+
+// Unmarshal runs on every cache hit; the bounds leave room for library
+// upgrades but catch losing the msgpack codec pooling
+func TestCacheSerializerAllocations(t *testing.T) {
+	url := sampleURLs()["all fields"]
+	limits := map[string]float64{CacheFormatJSON: 5, CacheFormatMsgpack: 8}
+
+	for format, limit := range limits {
+		s, _ := NewCacheSerializer(format)
+		data, _ := s.Marshal(url)
+		n := testing.AllocsPerRun(100, func() {
+			var out domain.URL
+			_ = s.Unmarshal(data, &out)
+		})
+		if n > limit {
+			t.Errorf("%s Unmarshal allocations = %v, want at most %v", format, n, limit)
+		}
+	}
+}
+
+func BenchmarkCacheSerializers(b *testing.B) {
+	url := sampleURLs()["all fields"]
+
+	for _, format := range cacheFormats {
+		s, _ := NewCacheSerializer(format)
+		data, _ := s.Marshal(url)
+
+		b.Run(format+"/marshal", func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(len(data)), "bytes/entry")
+			for i := 0; i < b.N; i++ {
+				if _, err := s.Marshal(url); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(format+"/unmarshal", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var out domain.URL
+				if err := s.Unmarshal(data, &out); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
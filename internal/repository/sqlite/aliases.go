@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/repository"
+)
+
+func (r *URLRepository) AddAlias(ctx context.Context, shortCode, alias string) (err error) {
+	defer func(start time.Time) { r.observe("add_alias", start, err) }(time.Now())
+
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO url_aliases (code, url_id, created_at)
+		SELECT ?, id, ? FROM urls
+		WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND reserved_until IS NULL AND purge_after IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM urls WHERE short_code = ?)`,
+		alias, utc(time.Now()), shortCode, repository.CodePrefix(ctx), alias)
+	if isUniqueViolation(err) {
+		return domain.ErrShortCodeExists
+	}
+	if err != nil {
+		return err
+	}
+	if err = rowsOrNotFound(result); !errors.Is(err, domain.ErrURLNotFound) {
+		return err
+	}
+	// Either the link isn't live or a urls row holds the alias's code
+	return r.conflictOrNotFound(ctx, shortCode)
+}
+
+// conflictOrNotFound explains a guarded write that changed nothing, as in
+// Postgres: ErrShortCodeExists when shortCode is a live link, ErrURLNotFound
+// when it isn't
+func (r *URLRepository) conflictOrNotFound(ctx context.Context, shortCode string) error {
+	var live bool
+	if err := r.db.GetContext(ctx, &live, `
+		SELECT EXISTS (
+			SELECT 1 FROM urls
+			WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND reserved_until IS NULL AND purge_after IS NULL
+		)`, shortCode, repository.CodePrefix(ctx)); err != nil {
+		return err
+	}
+	if live {
+		return domain.ErrShortCodeExists
+	}
+	return domain.ErrURLNotFound
+}
+
+func (r *URLRepository) RemoveAlias(ctx context.Context, shortCode, alias string) (err error) {
+	defer func(start time.Time) { r.observe("remove_alias", start, err) }(time.Now())
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM url_aliases
+		WHERE code = ? AND NOT retired
+		  AND url_id = (SELECT id FROM urls WHERE short_code = ? AND prefix = COALESCE(?, prefix))`,
+		alias, shortCode, repository.CodePrefix(ctx))
+	if err != nil {
+		return err
+	}
+	return rowsOrNotFound(result)
+}
+
+func (r *URLRepository) ListAliases(ctx context.Context, shortCode string) (aliases []string, err error) {
+	defer func(start time.Time) { r.observe("list_aliases", start, err) }(time.Now())
+
+	// As in Postgres, a link without aliases is one NULL row, an unknown one none
+	var rows []sql.NullString
+	if err = r.db.SelectContext(ctx, &rows, `
+		SELECT a.code FROM urls u LEFT JOIN url_aliases a ON a.url_id = u.id AND NOT a.retired
+		WHERE u.short_code = ? AND u.prefix = COALESCE(?, u.prefix) AND u.reserved_until IS NULL
+		ORDER BY a.code`, shortCode, repository.CodePrefix(ctx)); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, domain.ErrURLNotFound
+	}
+	aliases = make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.Valid {
+			aliases = append(aliases, row.String)
+		}
+	}
+	return aliases, nil
+}
+
+func (r *URLRepository) ResolveAlias(ctx context.Context, alias string) (shortCode string, err error) {
+	defer func(start time.Time) { r.observe("resolve_alias", start, err) }(time.Now())
+
+	var retired bool
+	err = r.db.QueryRowContext(ctx, `
+		SELECT u.short_code, a.retired FROM url_aliases a JOIN urls u ON u.id = a.url_id
+		WHERE a.code = ? AND u.prefix = COALESCE(?, u.prefix)`, alias, repository.CodePrefix(ctx)).Scan(&shortCode, &retired)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", domain.ErrURLNotFound
+	}
+	if err == nil && retired {
+		return "", domain.ErrCodeRetired
+	}
+	return shortCode, err
+}
+
+func (r *URLRepository) RenameShortCode(ctx context.Context, oldCode, newCode string, keepAlias bool) (err error) {
+	defer func(start time.Time) { r.observe("rename_short_code", start, err) }(time.Now())
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := utc(time.Now())
+	var (
+		urlID  int64
+		prefix string
+	)
+	err = tx.QueryRowContext(ctx, `
+		UPDATE urls SET short_code = ?, updated_at = ?
+		WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND reserved_until IS NULL AND purge_after IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM url_aliases WHERE code = ?)
+		RETURNING id, prefix`, newCode, now, oldCode, repository.CodePrefix(ctx), newCode).Scan(&urlID, &prefix)
+	if isUniqueViolation(err) {
+		return domain.ErrShortCodeExists
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// Either the link isn't live or newCode is an alias; the check runs
+		// outside the transaction, which holds the only connection
+		tx.Rollback()
+		return r.conflictOrNotFound(ctx, oldCode)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx,
+		`INSERT INTO url_aliases (code, url_id, created_at, retired) VALUES (?, ?, ?, ?)`,
+		oldCode, urlID, now, !keepAlias); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx,
+		`UPDATE click_events SET short_code = ? WHERE short_code = ? AND prefix = ?`, newCode, oldCode, prefix); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
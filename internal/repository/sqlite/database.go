@@ -0,0 +1,253 @@
+// Package sqlite stores links in a single SQLite file, for single-node
+// deploys that don't want to run Postgres and Redis next to the service
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/subhammahanty235/url-shortener/internal/repository"
+	"go.uber.org/zap"
+)
+
+// Open opens (creating if needed) the database file at path, ":memory:" for
+// a throwaway in-memory database
+//
+// Learning: SQLite allows one writer at a time, and a second connection
+// writing concurrently gets SQLITE_BUSY instead of waiting its turn. A single
+// connection makes database/sql queue every query instead, which on a
+// single node is as fast as SQLite gets anyway; it also keeps a ":memory:"
+// database alive, since each connection would otherwise get its own.
+//
+// The driver needs cgo: a CGO_ENABLED=0 build (like the Docker image) compiles
+// but fails here with an error saying so.
+func Open(path string, logger *zap.Logger) (*sqlx.DB, error) {
+	logger.Info("opening SQLite database", zap.String("path", path))
+
+	// WAL lets readers of the file (e.g. a backup) run alongside the writer
+	dsn := "file:" + path + "?_journal_mode=WAL&_busy_timeout=5000&_loc=UTC"
+	db, err := sqlx.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	return db, nil
+}
+
+// RunMigrations creates the schema
+// Tables and columns match the Postgres schema, so the domain structs' db
+// tags fit both. SQLite has no ADD COLUMN IF NOT EXISTS: a column added
+// later goes into the CREATE TABLE and into the addColumns list.
+func RunMigrations(db *sqlx.DB, opts repository.SchemaOptions, logger *zap.Logger) error {
+	logger.Info("running SQLite migrations")
+
+	if err := dropInlineUnique(db); err != nil {
+		return err
+	}
+
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS urls (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			short_code VARCHAR(20) NOT NULL,
+			original_url TEXT NOT NULL,
+			user_id VARCHAR(255),
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP,
+			click_count INTEGER NOT NULL DEFAULT 0,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+			signed BOOLEAN NOT NULL DEFAULT false,
+			reserved_until TIMESTAMP,
+			click_rate_limit INTEGER,
+			title TEXT NOT NULL DEFAULT '',
+			description TEXT NOT NULL DEFAULT '',
+			image_url TEXT NOT NULL DEFAULT '',
+			passthrough_query BOOLEAN NOT NULL DEFAULT false,
+			prefix TEXT NOT NULL DEFAULT '',
+			purge_after TIMESTAMP,
+			platform_destinations TEXT NOT NULL DEFAULT '{}',
+			country_destinations TEXT NOT NULL DEFAULT '{}',
+			fallback_url TEXT NOT NULL DEFAULT '',
+			source VARCHAR(32) NOT NULL DEFAULT '',
+			expiry_notified_at TIMESTAMP,
+			expiry_reminded_at TIMESTAMP
+		)`,
+		// A link is its (short_code, prefix) pair, see SchemaOptions
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_short_code_prefix ON urls(short_code, prefix)`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_original_url ON urls(original_url) WHERE is_active = true`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_user_id ON urls(user_id) WHERE user_id IS NOT NULL AND is_active = true`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_expires_at ON urls(expires_at) WHERE expires_at IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_created_at ON urls(created_at DESC, id DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_reserved_until ON urls(reserved_until) WHERE reserved_until IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_purge_after ON urls(purge_after) WHERE purge_after IS NOT NULL`,
+
+		`CREATE TABLE IF NOT EXISTS click_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			short_code VARCHAR(20) NOT NULL,
+			prefix TEXT NOT NULL DEFAULT '',
+			ip_address VARCHAR(45),
+			user_agent TEXT,
+			referrer TEXT,
+			country VARCHAR(2),
+			city VARCHAR(100),
+			device VARCHAR(20),
+			browser VARCHAR(50),
+			os VARCHAR(50),
+			country_source VARCHAR(16),
+			event_type VARCHAR(16) NOT NULL DEFAULT 'redirect',
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_click_events_short_code_created ON click_events(short_code, created_at DESC)`,
+
+		`CREATE TABLE IF NOT EXISTS click_flushes (
+			batch_id VARCHAR(64) PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL
+		)`,
+
+		// No arrays in SQLite: short_codes is a JSON array
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			action VARCHAR(32) NOT NULL,
+			short_codes TEXT NOT NULL DEFAULT '[]',
+			actor VARCHAR(255) NOT NULL DEFAULT '',
+			actor_type VARCHAR(16) NOT NULL,
+			actor_ip VARCHAR(45) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at DESC)`,
+
+		// Rows follow the link's id, so a code reused after a purge starts
+		// with no history; reads join urls, leftovers of purged links never show
+		`CREATE TABLE IF NOT EXISTS url_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			url_id INTEGER NOT NULL,
+			previous_url TEXT NOT NULL,
+			original_url TEXT NOT NULL,
+			actor VARCHAR(255) NOT NULL DEFAULT '',
+			actor_type VARCHAR(16) NOT NULL,
+			changed_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_url_history_url_id ON url_history(url_id, changed_at DESC, id DESC)`,
+
+		// No foreign key enforcement here: PurgeDeleted removes a purged
+		// link's aliases itself, so the codes become free again
+		`CREATE TABLE IF NOT EXISTS url_aliases (
+			code VARCHAR(20) PRIMARY KEY,
+			url_id INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			retired BOOLEAN NOT NULL DEFAULT false
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_url_aliases_url_id ON url_aliases(url_id)`,
+	}
+	if opts.CodesPerPrefix {
+		migrations = append(migrations, `DROP INDEX IF EXISTS idx_urls_short_code`)
+	} else {
+		// Fails on codes shared while per-prefix codes were on
+		migrations = append(migrations, `CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_short_code ON urls(short_code)`)
+	}
+
+	if err := repository.ApplyMigrations(db, migrations, logger); err != nil {
+		return err
+	}
+	if err := addColumns(db, "urls", []column{
+		{"platform_destinations", `TEXT NOT NULL DEFAULT '{}'`},
+		{"country_destinations", `TEXT NOT NULL DEFAULT '{}'`},
+		{"fallback_url", `TEXT NOT NULL DEFAULT ''`},
+		{"source", `VARCHAR(32) NOT NULL DEFAULT ''`},
+		{"expiry_notified_at", `TIMESTAMP`},
+		{"expiry_reminded_at", `TIMESTAMP`},
+	}); err != nil {
+		return err
+	}
+	if err := addColumns(db, "click_events", []column{
+		{"prefix", `TEXT NOT NULL DEFAULT ''`},
+	}); err != nil {
+		return err
+	}
+	return addColumns(db, "url_aliases", []column{
+		{"retired", `BOOLEAN NOT NULL DEFAULT false`},
+	})
+}
+
+// inlineUnique is how database files from before SchemaOptions declared
+// short_code
+var inlineUnique = regexp.MustCompile(`short_code VARCHAR\(\d+\) NOT NULL UNIQUE`)
+
+// dropInlineUnique rebuilds a urls table declared with short_code UNIQUE,
+// the only way to drop a column constraint in SQLite; uniqueness is an index
+// now, so it can follow SchemaOptions. The indexes go with the old table and
+// the migrations after this recreate them.
+func dropInlineUnique(db *sqlx.DB) error {
+	var create string
+	err := db.Get(&create, `SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'urls'`)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect urls: %w", err)
+	}
+	legacy := inlineUnique.FindString(create)
+	if legacy == "" {
+		return nil
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		`ALTER TABLE urls RENAME TO urls_legacy`,
+		strings.Replace(create, legacy, "short_code VARCHAR(20) NOT NULL", 1),
+		`INSERT INTO urls SELECT * FROM urls_legacy`,
+		`DROP TABLE urls_legacy`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to rebuild urls: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// column is one ADD COLUMN migration, name plus its definition
+type column struct {
+	name       string
+	definition string
+}
+
+// addColumns adds the columns table is missing, for database files created
+// before the columns were part of the CREATE TABLE above
+func addColumns(db *sqlx.DB, table string, columns []column) error {
+	for _, c := range columns {
+		var exists bool
+		if err := db.Get(&exists,
+			`SELECT EXISTS (SELECT 1 FROM pragma_table_info(?) WHERE name = ?)`, table, c.name); err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", table, err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, c.name, c.definition)); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", table, c.name, err)
+		}
+	}
+	return nil
+}
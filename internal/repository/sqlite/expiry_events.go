@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/repository"
+)
+
+func (r *URLRepository) ClaimExpiredEvent(ctx context.Context, shortCode string, now time.Time) (claimed *domain.URL, err error) {
+	defer func(start time.Time) { r.observe("claim_expired_event", start, err) }(time.Now())
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var url domain.URL
+	err = tx.GetContext(ctx, &url, `
+		SELECT id, short_code, prefix, original_url, expires_at FROM urls
+		WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND reserved_until IS NULL
+		  AND expires_at <= ? AND expiry_notified_at IS NOT expires_at`,
+		shortCode, repository.CodePrefix(ctx), utc(now))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE urls SET expiry_notified_at = expires_at WHERE id = ?`, url.ID); err != nil {
+		return nil, err
+	}
+	return &url, tx.Commit()
+}
+
+func (r *URLRepository) ClaimExpiryReminders(ctx context.Context, now, before time.Time, limit int) (urls []domain.URL, err error) {
+	defer func(start time.Time) { r.observe("claim_expiry_reminders", start, err) }(time.Now())
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.SelectContext(ctx, &urls, `
+		SELECT id, short_code, prefix, original_url, expires_at FROM urls
+		WHERE expires_at > ? AND expires_at <= ?
+		  AND is_active = true AND reserved_until IS NULL AND purge_after IS NULL
+		  AND expiry_reminded_at IS NOT expires_at
+		ORDER BY expires_at
+		LIMIT ?`, utc(now), utc(before), limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, url := range urls {
+		if _, err := tx.ExecContext(ctx, `UPDATE urls SET expiry_reminded_at = expires_at WHERE id = ?`, url.ID); err != nil {
+			return nil, err
+		}
+	}
+	return urls, tx.Commit()
+}
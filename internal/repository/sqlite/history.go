@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"github.com/subhammahanty235/url-shortener/internal/repository"
+)
+
+func (r *URLRepository) UpdateDestination(ctx context.Context, entry *domain.URLHistoryEntry) (err error) {
+	defer func(start time.Time) { r.observe("update_destination", start, err) }(time.Now())
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var urlID int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, original_url FROM urls
+		WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND reserved_until IS NULL AND purge_after IS NULL`,
+		entry.ShortCode, repository.CodePrefix(ctx)).Scan(&urlID, &entry.PreviousURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrURLNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	changedAt := utc(entry.ChangedAt)
+	if _, err = tx.ExecContext(ctx, `UPDATE urls SET original_url = ?, updated_at = ? WHERE id = ?`,
+		entry.OriginalURL, changedAt, urlID); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO url_history (url_id, previous_url, original_url, actor, actor_type, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		urlID, entry.PreviousURL, entry.OriginalURL, entry.Actor, entry.ActorType, changedAt,
+	).Scan(&entry.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *URLRepository) ListHistory(ctx context.Context, shortCode string, page pagination.Request) (entries []domain.URLHistoryEntry, err error) {
+	defer func(start time.Time) { r.observe("list_history", start, err) }(time.Now())
+
+	columns := `
+		SELECT h.id, u.short_code, h.previous_url, h.original_url, h.actor, h.actor_type, h.changed_at
+		FROM url_history h JOIN urls u ON u.id = h.url_id`
+
+	entries = make([]domain.URLHistoryEntry, 0, page.Limit+1)
+	if page.After != nil {
+		err = r.db.SelectContext(ctx, &entries, columns+`
+			WHERE u.short_code = ? AND u.prefix = COALESCE(?, u.prefix) AND (h.changed_at, h.id) < (?, ?)
+			ORDER BY h.changed_at DESC, h.id DESC
+			LIMIT ?`,
+			shortCode, repository.CodePrefix(ctx), utc(page.After.CreatedAt), page.After.ID, page.Limit+1)
+	} else {
+		err = r.db.SelectContext(ctx, &entries, columns+`
+			WHERE u.short_code = ? AND u.prefix = COALESCE(?, u.prefix)
+			ORDER BY h.changed_at DESC, h.id DESC
+			LIMIT ? OFFSET ?`,
+			shortCode, repository.CodePrefix(ctx), page.Limit+1, page.Offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
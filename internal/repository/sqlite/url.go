@@ -0,0 +1,605 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"github.com/subhammahanty235/url-shortener/internal/repository"
+)
+
+// urlColumns is every urls column domain.URL maps, in SELECT order
+const urlColumns = `id, short_code, original_url, user_id, created_at, updated_at,
+	expires_at, click_count, is_active, visibility, signed, click_rate_limit,
+	title, description, image_url, passthrough_query, prefix, purge_after,
+	platform_destinations, country_destinations, fallback_url, source`
+
+// clickFlushRetention is how long applied click batch IDs are remembered,
+// as in the Postgres repository
+const clickFlushRetention = 24 * time.Hour
+
+// URLRepository is a domain.URLRepository on SQLite with the Postgres
+// repository's error semantics
+//
+// Learning: timestamps are stored as text, so comparisons in SQL are string
+// comparisons. They are only correct when every stored and bound time is in
+// the same zone, so all of them go through utc() and "now" is always bound
+// from Go rather than taken from SQLite's own clock.
+type URLRepository struct {
+	db      *sqlx.DB
+	metrics *metrics.Metrics
+}
+
+func NewURLRepository(db *sqlx.DB, m *metrics.Metrics) *URLRepository {
+	return &URLRepository{db: db, metrics: m}
+}
+
+var (
+	_ domain.URLRepository        = (*URLRepository)(nil)
+	_ domain.ClickEventRepository = (*URLRepository)(nil)
+	_ domain.AuditLog             = (*URLRepository)(nil)
+)
+
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// observe records a query's duration, and its error unless err is an answer
+// about the link (not found, disabled, taken...) rather than a failure
+func (r *URLRepository) observe(operation string, start time.Time, err error) {
+	r.metrics.DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil && !isAnswer(err) {
+		r.metrics.DBErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func isAnswer(err error) bool {
+	return errors.Is(err, sql.ErrNoRows) ||
+		errors.Is(err, domain.ErrURLNotFound) ||
+		errors.Is(err, domain.ErrURLDeleted) ||
+		errors.Is(err, domain.ErrURLDisabled) ||
+		errors.Is(err, domain.ErrURLExpired) ||
+		errors.Is(err, domain.ErrShortCodeExists)
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
+
+// rowsOrNotFound turns an UPDATE that matched nothing into ErrURLNotFound
+func rowsOrNotFound(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrURLNotFound
+	}
+	return nil
+}
+
+func (r *URLRepository) Create(ctx context.Context, url *domain.URL) (err error) {
+	defer func(start time.Time) { r.observe("create_url", start, err) }(time.Now())
+
+	now := utc(time.Now())
+	url.CreatedAt = now
+	url.UpdatedAt = now
+	url.IsActive = true
+	if url.Visibility == "" {
+		url.Visibility = domain.VisibilityPublic
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO urls (short_code, original_url, user_id, expires_at, is_active, created_at, updated_at,
+			visibility, signed, click_rate_limit, passthrough_query, prefix, platform_destinations, country_destinations,
+			fallback_url, source)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM url_aliases WHERE code = ?)`,
+		url.ShortURL, url.OriginalURL, url.UserID, utcPtr(url.ExpiresAt), url.IsActive, now, now,
+		url.Visibility, url.Signed, url.ClickRateLimit, url.PassthroughQuery, url.Prefix, url.PlatformDestinations, url.CountryDestinations,
+		url.FallbackURL, url.Source, url.ShortURL,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrShortCodeExists
+		}
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		// No row: the code is an alias of another link
+		if err == nil {
+			err = domain.ErrShortCodeExists
+		}
+		return err
+	}
+	url.ID, err = result.LastInsertId()
+	return err
+}
+
+func (r *URLRepository) GetByShortCode(ctx context.Context, shortCode string) (url *domain.URL, err error) {
+	defer func(start time.Time) { r.observe("get_by_short_code", start, err) }(time.Now())
+
+	var stored domain.URL
+	err = r.db.GetContext(ctx, &stored,
+		`SELECT `+urlColumns+` FROM urls WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND reserved_until IS NULL`,
+		shortCode, repository.CodePrefix(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrURLNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.PurgeAfter != nil {
+		return nil, domain.ErrURLDeleted
+	}
+	if !stored.IsActive {
+		return nil, domain.ErrURLDisabled
+	}
+	if stored.IsExpired() {
+		r.metrics.ExpiredURLsTotal.Inc()
+		return nil, domain.Expired(&stored)
+	}
+	return &stored, nil
+}
+
+func (r *URLRepository) SetActive(ctx context.Context, shortCode string, active bool) (err error) {
+	defer func(start time.Time) { r.observe("set_active", start, err) }(time.Now())
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE urls SET is_active = ?, updated_at = ? WHERE short_code = ? AND prefix = COALESCE(?, prefix)`,
+		active, utc(time.Now()), shortCode, repository.CodePrefix(ctx))
+	if err != nil {
+		return err
+	}
+	return rowsOrNotFound(result)
+}
+
+func (r *URLRepository) CountActiveByUser(ctx context.Context, userID string) (count int64, err error) {
+	defer func(start time.Time) { r.observe("count_active_by_user", start, err) }(time.Now())
+
+	err = r.db.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM urls
+		WHERE user_id = ? AND is_active = true AND purge_after IS NULL
+		  AND (expires_at IS NULL OR expires_at > ?)`,
+		userID, utc(time.Now()))
+	return count, err
+}
+
+func (r *URLRepository) SetMetadata(ctx context.Context, shortCode string, meta domain.LinkMetadata) (err error) {
+	defer func(start time.Time) { r.observe("set_metadata", start, err) }(time.Now())
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE urls SET title = ?, description = ?, image_url = ?, updated_at = ? WHERE short_code = ? AND prefix = COALESCE(?, prefix)`,
+		meta.Title, meta.Description, meta.ImageURL, utc(time.Now()), shortCode, repository.CodePrefix(ctx))
+	if err != nil {
+		return err
+	}
+	return rowsOrNotFound(result)
+}
+
+func (r *URLRepository) GetExpiry(ctx context.Context, shortCode string) (expiresAt *time.Time, err error) {
+	defer func(start time.Time) { r.observe("get_expiry", start, err) }(time.Now())
+
+	var stored sql.NullTime
+	err = r.db.GetContext(ctx, &stored,
+		`SELECT expires_at FROM urls WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND reserved_until IS NULL`,
+		shortCode, repository.CodePrefix(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrURLNotFound
+	}
+	if err != nil || !stored.Valid {
+		return nil, err
+	}
+	return &stored.Time, nil
+}
+
+func (r *URLRepository) GetOwner(ctx context.Context, shortCode string) (userID *string, err error) {
+	defer func(start time.Time) { r.observe("get_owner", start, err) }(time.Now())
+
+	var stored sql.NullString
+	err = r.db.GetContext(ctx, &stored,
+		`SELECT user_id FROM urls WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND reserved_until IS NULL`,
+		shortCode, repository.CodePrefix(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrURLNotFound
+	}
+	if err != nil || !stored.Valid {
+		return nil, err
+	}
+	return &stored.String, nil
+}
+
+func (r *URLRepository) IsShortCodeTaken(ctx context.Context, shortCode string) (taken bool, err error) {
+	defer func(start time.Time) { r.observe("is_short_code_taken", start, err) }(time.Now())
+
+	err = r.db.GetContext(ctx, &taken, `
+		SELECT EXISTS (
+			SELECT 1 FROM urls
+			WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND (reserved_until IS NULL OR reserved_until > ?)
+		) OR EXISTS (
+			SELECT 1 FROM url_aliases WHERE code = ?
+		)`, shortCode, repository.CodePrefix(ctx), utc(time.Now()), shortCode)
+	return taken, err
+}
+
+func (r *URLRepository) SetExpiry(ctx context.Context, shortCode string, expiresAt *time.Time) (err error) {
+	defer func(start time.Time) { r.observe("set_expiry", start, err) }(time.Now())
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE urls SET expires_at = ?, updated_at = ? WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND reserved_until IS NULL`,
+		utcPtr(expiresAt), utc(time.Now()), shortCode, repository.CodePrefix(ctx))
+	if err != nil {
+		return err
+	}
+	return rowsOrNotFound(result)
+}
+
+func (r *URLRepository) GetAggregateStats(ctx context.Context, topN int) (stats *domain.AggregateStats, err error) {
+	defer func(start time.Time) { r.observe("aggregate_stats", start, err) }(time.Now())
+
+	stats = &domain.AggregateStats{TopURLs: make([]domain.URLStats, 0, topN)}
+	err = r.db.GetContext(ctx, stats, `
+		SELECT COUNT(*) AS total_urls,
+			   COUNT(*) FILTER (WHERE is_active = true AND (expires_at IS NULL OR expires_at > ?)) AS active_urls,
+			   COALESCE(SUM(click_count), 0) AS total_clicks
+		FROM urls`, utc(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.SelectContext(ctx, &stats.TopURLs, `
+		SELECT short_code, click_count, created_at FROM urls
+		WHERE is_active = true
+		ORDER BY click_count DESC, id ASC
+		LIMIT ?`, topN)
+	if err != nil {
+		return nil, err
+	}
+	stats.LinksBySource = []domain.SourceStats{}
+	err = r.db.SelectContext(ctx, &stats.LinksBySource, `
+		SELECT COALESCE(NULLIF(source, ''), ?) AS source, COUNT(*) AS links FROM urls
+		WHERE reserved_until IS NULL
+		GROUP BY 1
+		ORDER BY links DESC, source ASC`, domain.SourceUnknown)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.GeneratedAt = time.Now().UTC()
+	return stats, nil
+}
+
+// inScope filters a listing to a domain.ListScope, bound as (All, UserID)
+const inScope = `(? OR user_id = ?)`
+
+func (r *URLRepository) List(ctx context.Context, scope domain.ListScope, page pagination.Request) (urls []domain.URL, err error) {
+	defer func(start time.Time) { r.observe("list_urls", start, err) }(time.Now())
+
+	urls = make([]domain.URL, 0, page.Limit+1)
+	if page.After != nil {
+		err = r.db.SelectContext(ctx, &urls, `
+			SELECT `+urlColumns+` FROM urls
+			WHERE (created_at, id) < (?, ?) AND reserved_until IS NULL AND `+inScope+`
+			ORDER BY created_at DESC, id DESC
+			LIMIT ?`,
+			utc(page.After.CreatedAt), page.After.ID, scope.All, scope.UserID, page.Limit+1)
+	} else {
+		err = r.db.SelectContext(ctx, &urls, `
+			SELECT `+urlColumns+` FROM urls
+			WHERE reserved_until IS NULL AND `+inScope+`
+			ORDER BY created_at DESC, id DESC
+			LIMIT ? OFFSET ?`,
+			scope.All, scope.UserID, page.Limit+1, page.Offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+func (r *URLRepository) ListByDestination(ctx context.Context, originalURL string, scope domain.ListScope, page pagination.Request) (urls []domain.URL, err error) {
+	defer func(start time.Time) { r.observe("list_by_destination", start, err) }(time.Now())
+
+	live := `original_url = ? AND is_active = true AND purge_after IS NULL AND (expires_at IS NULL OR expires_at > ?) AND ` + inScope
+	now := utc(time.Now())
+
+	urls = make([]domain.URL, 0, page.Limit+1)
+	if page.After != nil {
+		err = r.db.SelectContext(ctx, &urls, `
+			SELECT `+urlColumns+` FROM urls
+			WHERE `+live+` AND (created_at, id) < (?, ?)
+			ORDER BY created_at DESC, id DESC
+			LIMIT ?`,
+			originalURL, now, scope.All, scope.UserID, utc(page.After.CreatedAt), page.After.ID, page.Limit+1)
+	} else {
+		err = r.db.SelectContext(ctx, &urls, `
+			SELECT `+urlColumns+` FROM urls
+			WHERE `+live+`
+			ORDER BY created_at DESC, id DESC
+			LIMIT ? OFFSET ?`,
+			originalURL, now, scope.All, scope.UserID, page.Limit+1, page.Offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+func (r *URLRepository) Reserve(ctx context.Context, url *domain.URL) (err error) {
+	defer func(start time.Time) { r.observe("reserve_alias", start, err) }(time.Now())
+
+	now := utc(time.Now())
+	url.CreatedAt = now
+	url.UpdatedAt = now
+	url.IsActive = false
+
+	// As in Postgres, the upsert only replaces a lapsed reservation and
+	// never takes an alias's code
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO urls (short_code, original_url, user_id, is_active, created_at, updated_at, reserved_until)
+		SELECT ?, '', ?, false, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM url_aliases WHERE code = ?)
+		ON CONFLICT (short_code, prefix) DO UPDATE
+		SET user_id = excluded.user_id,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at,
+			reserved_until = excluded.reserved_until
+		WHERE urls.reserved_until IS NOT NULL AND urls.reserved_until <= excluded.created_at
+		RETURNING id`,
+		url.ShortURL, url.UserID, now, now, utcPtr(url.ReservedUntil), url.ShortURL,
+	).Scan(&url.ID)
+	if errors.Is(err, sql.ErrNoRows) || isUniqueViolation(err) {
+		// A unique violation is a prefixed link holding a global code
+		return domain.ErrShortCodeExists
+	}
+	return err
+}
+
+func (r *URLRepository) ClaimReservation(ctx context.Context, url *domain.URL) (err error) {
+	defer func(start time.Time) { r.observe("claim_reservation", start, err) }(time.Now())
+
+	now := utc(time.Now())
+	url.CreatedAt = now
+	url.UpdatedAt = now
+	url.IsActive = true
+	url.ReservedUntil = nil
+	if url.Visibility == "" {
+		url.Visibility = domain.VisibilityPublic
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		UPDATE urls
+		SET original_url = ?, user_id = ?, expires_at = ?, is_active = true,
+			visibility = ?, signed = ?, created_at = ?, updated_at = ?, reserved_until = NULL,
+			click_rate_limit = ?, passthrough_query = ?, prefix = ?, platform_destinations = ?, country_destinations = ?,
+			fallback_url = ?, source = ?
+		WHERE short_code = ?
+		  AND reserved_until IS NOT NULL
+		  AND (reserved_until <= ? OR user_id IS ?)
+		RETURNING id`,
+		url.OriginalURL, url.UserID, utcPtr(url.ExpiresAt),
+		url.Visibility, url.Signed, now, now,
+		url.ClickRateLimit, url.PassthroughQuery, url.Prefix, url.PlatformDestinations, url.CountryDestinations,
+		url.FallbackURL, url.Source, url.ShortURL, now, url.UserID,
+	).Scan(&url.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrURLNotFound
+	}
+	return err
+}
+
+func (r *URLRepository) DeleteExpiredReservations(ctx context.Context, now time.Time) (n int64, err error) {
+	defer func(start time.Time) { r.observe("delete_expired_reservations", start, err) }(time.Now())
+
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM urls WHERE reserved_until IS NOT NULL AND reserved_until <= ?`, utc(now))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MarkDeleted reads the window back in the same transaction: RETURNING an
+// expression loses the column's TIMESTAMP type, so it wouldn't scan as a time
+func (r *URLRepository) MarkDeleted(ctx context.Context, shortCode string, purgeAfter time.Time) (effective time.Time, err error) {
+	defer func(start time.Time) { r.observe("mark_deleted", start, err) }(time.Now())
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE urls SET purge_after = COALESCE(purge_after, ?), updated_at = ?
+		WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND reserved_until IS NULL`,
+		utc(purgeAfter), utc(time.Now()), shortCode, repository.CodePrefix(ctx))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := rowsOrNotFound(result); err != nil {
+		return time.Time{}, err
+	}
+	if err := tx.GetContext(ctx, &effective,
+		`SELECT purge_after FROM urls WHERE short_code = ? AND prefix = COALESCE(?, prefix)`, shortCode, repository.CodePrefix(ctx)); err != nil {
+		return time.Time{}, err
+	}
+	return effective, tx.Commit()
+}
+
+func (r *URLRepository) RestoreDeleted(ctx context.Context, shortCode string, now time.Time) (err error) {
+	defer func(start time.Time) { r.observe("restore_deleted", start, err) }(time.Now())
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE urls SET purge_after = NULL, updated_at = ? WHERE short_code = ? AND prefix = COALESCE(?, prefix) AND purge_after > ?`,
+		utc(now), shortCode, repository.CodePrefix(ctx), utc(now))
+	if err != nil {
+		return err
+	}
+	return rowsOrNotFound(result)
+}
+
+func (r *URLRepository) PurgeDeleted(ctx context.Context, now time.Time) (n int64, err error) {
+	defer func(start time.Time) { r.observe("purge_deleted", start, err) }(time.Now())
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Aliases first, while their links can still be found
+	if _, err = tx.ExecContext(ctx, `
+		DELETE FROM url_aliases WHERE url_id IN (
+			SELECT id FROM urls WHERE purge_after IS NOT NULL AND purge_after <= ?
+		)`, utc(now)); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx,
+		`DELETE FROM urls WHERE purge_after IS NOT NULL AND purge_after <= ?`, utc(now))
+	if err != nil {
+		return 0, err
+	}
+	if n, err = result.RowsAffected(); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// ApplyClickBatch makes URLRepository a repository.ClickSink for the
+// in-process click buffer; replayed batch IDs are ignored as in Postgres
+func (r *URLRepository) ApplyClickBatch(ctx context.Context, batchID string, counts map[string]int64) (err error) {
+	defer func(start time.Time) { r.observe("apply_click_batch", start, err) }(time.Now())
+
+	// Keyed by domain.LinkKey, as in Postgres
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := utc(time.Now())
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO click_flushes (batch_id, applied_at) VALUES (?, ?) ON CONFLICT (batch_id) DO NOTHING`, batchID, now)
+	if err != nil {
+		return err
+	}
+	if inserted, err := result.RowsAffected(); err != nil || inserted == 0 {
+		return err
+	}
+
+	for _, key := range keys {
+		prefix, code := domain.SplitLinkKey(key)
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE urls SET click_count = click_count + ? WHERE short_code = ? AND prefix = ?`, counts[key], code, prefix); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM click_flushes WHERE applied_at < ?`, now.Add(-clickFlushRetention)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *URLRepository) RecordClickEvent(ctx context.Context, event *domain.ClickEvent) (err error) {
+	defer func(start time.Time) { r.observe("record_click_event", start, err) }(time.Now())
+
+	if event.Type == "" {
+		event.Type = domain.ClickEventRedirect
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO click_events (short_code, ip_address, user_agent, referrer, country, city,
+			device, browser, os, country_source, event_type, created_at, prefix)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?)`,
+		event.ShortCode, event.IPAddress, event.UserAgent, event.Referrer, event.Country, event.City,
+		event.Device, event.Browser, event.OS, event.CountrySource, event.Type, utc(event.CreatedAt), event.Prefix,
+	)
+	if err != nil {
+		return err
+	}
+	event.ID, err = result.LastInsertId()
+	return err
+}
+
+// RecordClickEvents inserts a batch of click events with one multi-row INSERT
+func (r *URLRepository) RecordClickEvents(ctx context.Context, events []*domain.ClickEvent) (err error) {
+	if len(events) == 0 {
+		return nil
+	}
+	defer func(start time.Time) { r.observe("record_click_events", start, err) }(time.Now())
+
+	var query strings.Builder
+	query.WriteString(`INSERT INTO click_events (short_code, ip_address, user_agent, referrer, country, city,
+			device, browser, os, country_source, event_type, created_at, prefix) VALUES `)
+	args := make([]interface{}, 0, len(events)*13)
+	for i, event := range events {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?)")
+		args = append(args,
+			event.ShortCode, event.IPAddress, event.UserAgent, event.Referrer, event.Country, event.City,
+			event.Device, event.Browser, event.OS, event.CountrySource, event.Type, utc(event.CreatedAt), event.Prefix,
+		)
+	}
+
+	_, err = r.db.ExecContext(ctx, query.String(), args...)
+	return err
+}
+
+func (r *URLRepository) RecordAudit(ctx context.Context, entry *domain.AuditEntry) (err error) {
+	defer func(start time.Time) { r.observe("record_audit", start, err) }(time.Now())
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	codes := entry.ShortCodes
+	if codes == nil {
+		codes = []string{}
+	}
+	shortCodes, err := json.Marshal(codes)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO audit_log (action, short_codes, actor, actor_type, actor_ip, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.Action, string(shortCodes), entry.Actor, entry.ActorType, entry.ActorIP, utc(entry.CreatedAt),
+	)
+	if err != nil {
+		return err
+	}
+	entry.ID, err = result.LastInsertId()
+	return err
+}
@@ -0,0 +1,557 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"github.com/subhammahanty235/url-shortener/internal/repository"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"github.com/subhammahanty235/url-shortener/internal/service"
+	"go.uber.org/zap"
+)
+
+// newTestRepo opens a migrated database file in a temp dir, so the test
+// covers the same file-backed setup production uses
+func newTestRepo(t *testing.T) *URLRepository {
+	t.Helper()
+	return newTestRepoWith(t, repository.SchemaOptions{})
+}
+
+func newTestRepoWith(t *testing.T, opts repository.SchemaOptions) *URLRepository {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "links.db"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := RunMigrations(db, opts, zap.NewNop()); err != nil {
+		t.Fatalf("RunMigrations() returned error: %v", err)
+	}
+	// Migrations are idempotent, a restart runs them again
+	if err := RunMigrations(db, opts, zap.NewNop()); err != nil {
+		t.Fatalf("second RunMigrations() returned error: %v", err)
+	}
+	return NewURLRepository(db, metrics.NewMetricsWithRegistry(prometheus.NewRegistry()))
+}
+
+func newSQLiteService(t *testing.T, urlRepo *URLRepository, cacheRepo domain.CacheRepository, clicks domain.ClickCounter) *service.URLService {
+	t.Helper()
+
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+
+	return service.NewURLService(
+		urlRepo,
+		cacheRepo,
+		keyGen,
+		nil,
+		clicks,
+		zap.NewNop(),
+		metrics.NewMetricsWithRegistry(prometheus.NewRegistry()),
+		service.URLServiceConfig{BaseURL: "http://short.test", DefaultTTL: time.Hour},
+	)
+}
+
+func TestServiceEndToEndWithSQLite(t *testing.T) {
+	urlRepo := newTestRepo(t)
+	cacheRepo := memory.NewCacheRepository(time.Hour)
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clicks := repository.NewLocalClickCounter(urlRepo, repository.LocalClickCounterConfig{}, m, zap.NewNop())
+	svc := newSQLiteService(t, urlRepo, cacheRepo, clicks)
+	ctx := context.Background()
+
+	resp, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/docs"})
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	// Resolved from the database, not the cache populated on create
+	_ = cacheRepo.Delete(ctx, resp.ShortCode)
+	url, err := svc.Visit(ctx, resp.ShortCode)
+	if err != nil || url.OriginalURL != "https://example.com/docs" {
+		t.Fatalf("Visit() = (%v, %v), want example.com/docs", url, err)
+	}
+	if url.ExpiresAt == nil || time.Until(*url.ExpiresAt) < 59*time.Minute {
+		t.Errorf("ExpiresAt = %v, want the 1h default TTL", url.ExpiresAt)
+	}
+
+	// Clicks reach click_count once the buffer is flushed
+	if err := clicks.Flush(ctx); err != nil {
+		t.Fatalf("Flush() returned error: %v", err)
+	}
+	stats, err := urlRepo.GetAggregateStats(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetAggregateStats() returned error: %v", err)
+	}
+	if stats.TotalURLs != 1 || stats.ActiveURLs != 1 || stats.TotalClicks != 1 {
+		t.Errorf("stats = %+v, want 1 link, 1 active, 1 click", stats)
+	}
+
+	// Expiry is compared in SQL and in Go, both must agree the link is gone
+	past := time.Now().Add(-time.Minute)
+	if err := urlRepo.SetExpiry(ctx, resp.ShortCode, &past); err != nil {
+		t.Fatalf("SetExpiry() returned error: %v", err)
+	}
+	_ = cacheRepo.Delete(ctx, resp.ShortCode)
+	if _, err := svc.Visit(ctx, resp.ShortCode); !errors.Is(err, domain.ErrURLExpired) {
+		t.Errorf("Visit() after expiry error = %v, want ErrURLExpired", err)
+	}
+	stats, _ = urlRepo.GetAggregateStats(ctx, 10)
+	if stats.ActiveURLs != 0 {
+		t.Errorf("active_urls after expiry = %d, want 0", stats.ActiveURLs)
+	}
+	live, _ := urlRepo.ListByDestination(ctx, "https://example.com/docs", domain.ListScope{All: true}, pagination.Request{Limit: 10})
+	if len(live) != 0 {
+		t.Errorf("ListByDestination() after expiry = %d links, want 0", len(live))
+	}
+
+	if _, err := svc.Visit(ctx, "missing"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("Visit(missing) error = %v, want ErrURLNotFound", err)
+	}
+}
+
+func TestSQLiteCreateRejectsDuplicateCode(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	if err := repo.Create(ctx, &domain.URL{ShortURL: "taken", OriginalURL: "https://example.com/a"}); err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	err := repo.Create(ctx, &domain.URL{ShortURL: "taken", OriginalURL: "https://example.com/b"})
+	if !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("duplicate Create() error = %v, want ErrShortCodeExists", err)
+	}
+}
+
+func TestSQLiteCodesPerPrefix(t *testing.T) {
+	repo := newTestRepoWith(t, repository.SchemaOptions{CodesPerPrefix: true})
+	ctx := context.Background()
+	news := domain.WithCodePrefix(ctx, "news")
+
+	for _, url := range []*domain.URL{
+		{ShortURL: "sale", Prefix: "news", OriginalURL: "https://example.com/news"},
+		{ShortURL: "sale", Prefix: "sports", OriginalURL: "https://example.com/sports"},
+		{ShortURL: "sale", OriginalURL: "https://example.com/bare"},
+	} {
+		if err := repo.Create(ctx, url); err != nil {
+			t.Fatalf("Create(%s/%s) returned error: %v", url.Prefix, url.ShortURL, err)
+		}
+	}
+	err := repo.Create(ctx, &domain.URL{ShortURL: "sale", Prefix: "news", OriginalURL: "https://example.com/again"})
+	if !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("duplicate Create() under a prefix error = %v, want ErrShortCodeExists", err)
+	}
+
+	if url, err := repo.GetByShortCode(news, "sale"); err != nil || url.OriginalURL != "https://example.com/news" {
+		t.Errorf("GetByShortCode(news/sale) = %v, %v; want the news link", url, err)
+	}
+	if url, err := repo.GetByShortCode(domain.WithCodePrefix(ctx, ""), "sale"); err != nil || url.Prefix != "" {
+		t.Errorf("GetByShortCode(sale) = %v, %v; want the unprefixed link", url, err)
+	}
+	if _, err := repo.GetByShortCode(domain.WithCodePrefix(ctx, "blog"), "sale"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("GetByShortCode(blog/sale) error = %v, want ErrURLNotFound", err)
+	}
+
+	if err := repo.ApplyClickBatch(ctx, "batch-1", map[string]int64{domain.LinkKey("news", "sale"): 2}); err != nil {
+		t.Fatalf("ApplyClickBatch() returned error: %v", err)
+	}
+	for prefix, want := range map[string]int64{"news": 2, "sports": 0} {
+		url, err := repo.GetByShortCode(domain.WithCodePrefix(ctx, prefix), "sale")
+		if err != nil || url.ClickCount != want {
+			t.Errorf("%s/sale = %v, %v; want %d clicks", prefix, url, err, want)
+		}
+	}
+	if err := repo.SetActive(news, "sale", false); err != nil {
+		t.Fatalf("SetActive() returned error: %v", err)
+	}
+	if _, err := repo.GetByShortCode(news, "sale"); !errors.Is(err, domain.ErrURLDisabled) {
+		t.Errorf("GetByShortCode(news/sale) error = %v, want ErrURLDisabled", err)
+	}
+	if _, err := repo.GetByShortCode(domain.WithCodePrefix(ctx, "sports"), "sale"); err != nil {
+		t.Errorf("GetByShortCode(sports/sale) returned error: %v; want it left active", err)
+	}
+
+	// Back to global codes with a code shared: the unique index can't be built
+	if err := RunMigrations(repo.db, repository.SchemaOptions{}, zap.NewNop()); err == nil {
+		t.Error("RunMigrations() back to global codes succeeded with a shared code")
+	}
+}
+
+func TestSQLiteMigratesInlineUniqueCode(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "links.db"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Open() returned error: %v", err)
+	}
+	defer db.Close()
+
+	// A database file from when short_code was UNIQUE on its own
+	for _, stmt := range []string{
+		`CREATE TABLE urls (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			short_code VARCHAR(64) NOT NULL UNIQUE,
+			original_url TEXT NOT NULL,
+			user_id VARCHAR(255),
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP,
+			click_count INTEGER NOT NULL DEFAULT 0,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+			signed BOOLEAN NOT NULL DEFAULT false,
+			reserved_until TIMESTAMP,
+			click_rate_limit INTEGER,
+			title TEXT NOT NULL DEFAULT '',
+			description TEXT NOT NULL DEFAULT '',
+			image_url TEXT NOT NULL DEFAULT '',
+			passthrough_query BOOLEAN NOT NULL DEFAULT false,
+			prefix TEXT NOT NULL DEFAULT '',
+			purge_after TIMESTAMP
+		)`,
+		`CREATE TABLE click_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			short_code VARCHAR(64) NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`INSERT INTO urls (short_code, original_url, created_at, updated_at, prefix)
+			VALUES ('sale', 'https://example.com/news', '2024-01-01', '2024-01-01', 'news')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("setting up the old schema: %v", err)
+		}
+	}
+	if err := RunMigrations(db, repository.SchemaOptions{CodesPerPrefix: true}, zap.NewNop()); err != nil {
+		t.Fatalf("RunMigrations() returned error: %v", err)
+	}
+	repo := NewURLRepository(db, metrics.NewMetricsWithRegistry(prometheus.NewRegistry()))
+	ctx := context.Background()
+
+	if url, err := repo.GetByShortCode(domain.WithCodePrefix(ctx, "news"), "sale"); err != nil || url.OriginalURL != "https://example.com/news" {
+		t.Errorf("GetByShortCode(news/sale) = %v, %v; want the migrated link", url, err)
+	}
+	if err := repo.Create(ctx, &domain.URL{ShortURL: "sale", OriginalURL: "https://example.com/bare"}); err != nil {
+		t.Errorf("Create(sale) next to news/sale returned error: %v", err)
+	}
+}
+
+func TestSQLiteListPagesWithCursor(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	for _, code := range []string{"first", "second", "third"} {
+		if err := repo.Create(ctx, &domain.URL{ShortURL: code, OriginalURL: "https://example.com/" + code}); err != nil {
+			t.Fatalf("Create(%s) returned error: %v", code, err)
+		}
+	}
+
+	page, err := repo.List(ctx, domain.ListScope{All: true}, pagination.Request{Limit: 2})
+	if err != nil || len(page) != 3 || page[0].ShortURL != "third" {
+		t.Fatalf("List() = %d rows, %v; want newest first plus one extra row", len(page), err)
+	}
+	next, err := repo.List(ctx, domain.ListScope{All: true}, pagination.Request{Limit: 2, After: &pagination.Cursor{CreatedAt: page[1].CreatedAt, ID: page[1].ID}})
+	if err != nil || len(next) != 1 || next[0].ShortURL != "first" {
+		t.Errorf("List(after second) = %+v, %v; want only first", next, err)
+	}
+}
+
+func TestSQLiteListScope(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	alice, bob := "alice", "bob"
+	for _, url := range []*domain.URL{
+		{ShortURL: "open", OriginalURL: "https://example.com/"},
+		{ShortURL: "mine", OriginalURL: "https://example.com/", UserID: &alice, Visibility: domain.VisibilityPrivate},
+		{ShortURL: "theirs", OriginalURL: "https://example.com/", UserID: &bob, Visibility: domain.VisibilityPrivate},
+	} {
+		if err := repo.Create(ctx, url); err != nil {
+			t.Fatalf("Create(%s) returned error: %v", url.ShortURL, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		scope domain.ListScope
+		want  int
+	}{
+		{domain.ListScope{}, 0},
+		{domain.ListScope{UserID: alice}, 1},
+		{domain.ListScope{All: true}, 3},
+	} {
+		listed, err := repo.List(ctx, tt.scope, pagination.Request{Limit: 10})
+		if err != nil || len(listed) != tt.want {
+			t.Errorf("List(%+v) = %d rows, %v; want %d", tt.scope, len(listed), err, tt.want)
+		}
+		byDest, err := repo.ListByDestination(ctx, "https://example.com/", tt.scope, pagination.Request{Limit: 10})
+		if err != nil || len(byDest) != tt.want {
+			t.Errorf("ListByDestination(%+v) = %d rows, %v; want %d", tt.scope, len(byDest), err, tt.want)
+		}
+	}
+}
+
+func TestSQLiteReservationAndDeleteLifecycle(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	owner := "alice"
+
+	until := time.Now().Add(time.Hour)
+	if err := repo.Reserve(ctx, &domain.URL{ShortURL: "launch", UserID: &owner, ReservedUntil: &until}); err != nil {
+		t.Fatalf("Reserve() returned error: %v", err)
+	}
+	if err := repo.Reserve(ctx, &domain.URL{ShortURL: "launch", ReservedUntil: &until}); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("Reserve() over a live reservation error = %v, want ErrShortCodeExists", err)
+	}
+	if _, err := repo.GetByShortCode(ctx, "launch"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("GetByShortCode() of a reservation error = %v, want ErrURLNotFound", err)
+	}
+	if taken, err := repo.IsShortCodeTaken(ctx, "launch"); err != nil || !taken {
+		t.Errorf("IsShortCodeTaken() of a live reservation = %v, %v; want taken", taken, err)
+	}
+	if taken, err := repo.IsShortCodeTaken(ctx, "free"); err != nil || taken {
+		t.Errorf("IsShortCodeTaken() of an unused code = %v, %v; want free", taken, err)
+	}
+	claim := &domain.URL{ShortURL: "launch", OriginalURL: "https://example.com/launch", UserID: &owner}
+	if err := repo.ClaimReservation(ctx, claim); err != nil {
+		t.Fatalf("ClaimReservation() by the owner returned error: %v", err)
+	}
+
+	purgeAfter, err := repo.MarkDeleted(ctx, "launch", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("MarkDeleted() returned error: %v", err)
+	}
+	// A second delete keeps the first window
+	again, err := repo.MarkDeleted(ctx, "launch", time.Now().Add(48*time.Hour))
+	if err != nil || !again.Equal(purgeAfter) {
+		t.Errorf("second MarkDeleted() = %v, %v; want the first window %v", again, err, purgeAfter)
+	}
+	if _, err := repo.GetByShortCode(ctx, "launch"); !errors.Is(err, domain.ErrURLDeleted) {
+		t.Errorf("GetByShortCode() of a deleted link error = %v, want ErrURLDeleted", err)
+	}
+	if err := repo.RestoreDeleted(ctx, "launch", time.Now()); err != nil {
+		t.Fatalf("RestoreDeleted() returned error: %v", err)
+	}
+	if _, err := repo.GetByShortCode(ctx, "launch"); err != nil {
+		t.Errorf("GetByShortCode() after restore error = %v", err)
+	}
+
+	repo.MarkDeleted(ctx, "launch", time.Now().Add(time.Hour))
+	purged, err := repo.PurgeDeleted(ctx, time.Now().Add(2*time.Hour))
+	if err != nil || purged != 1 {
+		t.Errorf("PurgeDeleted() = %d, %v; want 1 row", purged, err)
+	}
+}
+
+func TestSQLiteApplyClickBatchIsIdempotent(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	repo.Create(ctx, &domain.URL{ShortURL: "abc", OriginalURL: "https://example.com"})
+
+	for i := 0; i < 2; i++ {
+		if err := repo.ApplyClickBatch(ctx, "batch-1", map[string]int64{"abc": 5}); err != nil {
+			t.Fatalf("ApplyClickBatch() returned error: %v", err)
+		}
+	}
+	url, err := repo.GetByShortCode(ctx, "abc")
+	if err != nil || url.ClickCount != 5 {
+		t.Errorf("click_count = %v, %v; want 5 after a replayed batch", url, err)
+	}
+}
+
+func TestSQLiteDestinationHistory(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	if err := repo.Create(ctx, &domain.URL{ShortURL: "moved", OriginalURL: "https://example.com/v1"}); err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	base := time.Now().UTC()
+	for i, dest := range []string{"https://example.com/v2", "https://example.com/v3"} {
+		entry := &domain.URLHistoryEntry{
+			ShortCode: "moved", OriginalURL: dest, Actor: "alice", ActorType: domain.ActorUser,
+			ChangedAt: base.Add(time.Duration(i) * time.Second),
+		}
+		if err := repo.UpdateDestination(ctx, entry); err != nil {
+			t.Fatalf("UpdateDestination(%s) returned error: %v", dest, err)
+		}
+	}
+
+	url, err := repo.GetByShortCode(ctx, "moved")
+	if err != nil || url.OriginalURL != "https://example.com/v3" {
+		t.Fatalf("GetByShortCode() = %+v, %v; want the last destination", url, err)
+	}
+
+	first, err := repo.ListHistory(ctx, "moved", pagination.Request{Limit: 1})
+	if err != nil || len(first) != 2 {
+		t.Fatalf("ListHistory() = %d rows, %v; want 1 plus the look-ahead row", len(first), err)
+	}
+	if first[0].PreviousURL != "https://example.com/v2" || first[0].OriginalURL != "https://example.com/v3" || first[0].Actor != "alice" {
+		t.Errorf("newest entry = %+v", first[0])
+	}
+	rest, err := repo.ListHistory(ctx, "moved", pagination.Request{Limit: 1, After: &pagination.Cursor{CreatedAt: first[0].ChangedAt, ID: first[0].ID}})
+	if err != nil || len(rest) != 1 || rest[0].PreviousURL != "https://example.com/v1" {
+		t.Errorf("ListHistory() after the cursor = %+v, %v; want the first change", rest, err)
+	}
+
+	// A purged code reused by a new link starts without history
+	repo.MarkDeleted(ctx, "moved", time.Now())
+	if err := repo.UpdateDestination(ctx, &domain.URLHistoryEntry{ShortCode: "moved", OriginalURL: "https://example.com/v4"}); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("UpdateDestination() of a deleted link error = %v, want ErrURLNotFound", err)
+	}
+	repo.PurgeDeleted(ctx, time.Now().Add(time.Second))
+	repo.Create(ctx, &domain.URL{ShortURL: "moved", OriginalURL: "https://example.com/new"})
+	if entries, err := repo.ListHistory(ctx, "moved", pagination.Request{Limit: 10}); err != nil || len(entries) != 0 {
+		t.Errorf("ListHistory() of a reused code = %d rows, %v; want none", len(entries), err)
+	}
+}
+
+func TestSQLiteAliases(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	for _, code := range []string{"canon", "other"} {
+		if err := repo.Create(ctx, &domain.URL{ShortURL: code, OriginalURL: "https://example.com/" + code}); err != nil {
+			t.Fatalf("Create(%s) returned error: %v", code, err)
+		}
+	}
+
+	if aliases, err := repo.ListAliases(ctx, "canon"); err != nil || len(aliases) != 0 {
+		t.Errorf("ListAliases() without aliases = %v, %v; want none", aliases, err)
+	}
+	if err := repo.AddAlias(ctx, "canon", "promo"); err != nil {
+		t.Fatalf("AddAlias() returned error: %v", err)
+	}
+	if err := repo.AddAlias(ctx, "other", "promo"); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("AddAlias() of a taken alias error = %v, want ErrShortCodeExists", err)
+	}
+	if err := repo.AddAlias(ctx, "canon", "other"); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("AddAlias() of a link's code error = %v, want ErrShortCodeExists", err)
+	}
+	if err := repo.AddAlias(ctx, "missing", "fresh"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("AddAlias() to an unknown link error = %v, want ErrURLNotFound", err)
+	}
+	if _, err := repo.ListAliases(ctx, "missing"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("ListAliases() of an unknown link error = %v, want ErrURLNotFound", err)
+	}
+
+	if code, err := repo.ResolveAlias(ctx, "promo"); err != nil || code != "canon" {
+		t.Errorf("ResolveAlias() = %q, %v; want canon", code, err)
+	}
+	if taken, err := repo.IsShortCodeTaken(ctx, "promo"); err != nil || !taken {
+		t.Errorf("IsShortCodeTaken() of an alias = %v, %v; want taken", taken, err)
+	}
+	if err := repo.Create(ctx, &domain.URL{ShortURL: "promo", OriginalURL: "https://example.com/x"}); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("Create() over an alias error = %v, want ErrShortCodeExists", err)
+	}
+	until := time.Now().Add(time.Hour)
+	if err := repo.Reserve(ctx, &domain.URL{ShortURL: "promo", ReservedUntil: &until}); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("Reserve() over an alias error = %v, want ErrShortCodeExists", err)
+	}
+
+	// Purging the link frees its aliases
+	repo.MarkDeleted(ctx, "canon", time.Now().Add(time.Hour))
+	if _, err := repo.PurgeDeleted(ctx, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("PurgeDeleted() returned error: %v", err)
+	}
+	if _, err := repo.ResolveAlias(ctx, "promo"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("ResolveAlias() after purge error = %v, want ErrURLNotFound", err)
+	}
+	if err := repo.AddAlias(ctx, "other", "promo"); err != nil {
+		t.Errorf("AddAlias() of a purged link's alias returned error: %v", err)
+	}
+	if err := repo.RemoveAlias(ctx, "other", "promo"); err != nil {
+		t.Errorf("RemoveAlias() returned error: %v", err)
+	}
+	if err := repo.RemoveAlias(ctx, "other", "promo"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("second RemoveAlias() error = %v, want ErrURLNotFound", err)
+	}
+}
+
+func TestSQLiteRenameShortCode(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	for _, code := range []string{"old", "other"} {
+		if err := repo.Create(ctx, &domain.URL{ShortURL: code, OriginalURL: "https://example.com/" + code}); err != nil {
+			t.Fatalf("Create(%s) returned error: %v", code, err)
+		}
+	}
+	if err := repo.RecordClickEvent(ctx, &domain.ClickEvent{ShortCode: "old"}); err != nil {
+		t.Fatalf("RecordClickEvent() returned error: %v", err)
+	}
+
+	if err := repo.RenameShortCode(ctx, "old", "other", false); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Errorf("RenameShortCode() onto a link's code error = %v, want ErrShortCodeExists", err)
+	}
+	if err := repo.RenameShortCode(ctx, "missing", "fresh", false); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("RenameShortCode() of an unknown link error = %v, want ErrURLNotFound", err)
+	}
+	if err := repo.RenameShortCode(ctx, "old", "new", false); err != nil {
+		t.Fatalf("RenameShortCode() returned error: %v", err)
+	}
+
+	if url, err := repo.GetByShortCode(ctx, "new"); err != nil || url.OriginalURL != "https://example.com/old" {
+		t.Errorf("GetByShortCode(new) = %v, %v; want the renamed link", url, err)
+	}
+	var events int
+	if err := repo.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM click_events WHERE short_code = 'new'`).Scan(&events); err != nil || events != 1 {
+		t.Errorf("click events under the new code = %d, %v; want 1", events, err)
+	}
+	if _, err := repo.ResolveAlias(ctx, "old"); !errors.Is(err, domain.ErrCodeRetired) {
+		t.Errorf("ResolveAlias() of a retired code error = %v, want ErrCodeRetired", err)
+	}
+	if taken, err := repo.IsShortCodeTaken(ctx, "old"); err != nil || !taken {
+		t.Errorf("IsShortCodeTaken() of a retired code = %v, %v; want taken", taken, err)
+	}
+	if aliases, err := repo.ListAliases(ctx, "new"); err != nil || len(aliases) != 0 {
+		t.Errorf("ListAliases() = %v, %v; want retired codes left out", aliases, err)
+	}
+
+	if err := repo.RenameShortCode(ctx, "new", "newer", true); err != nil {
+		t.Fatalf("RenameShortCode() keeping an alias returned error: %v", err)
+	}
+	if code, err := repo.ResolveAlias(ctx, "new"); err != nil || code != "newer" {
+		t.Errorf("ResolveAlias(new) = %q, %v; want newer", code, err)
+	}
+}
+
+func TestSQLiteExpiryClaims(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	past := now.Add(-time.Minute)
+	soon := now.Add(time.Hour)
+	repo.Create(ctx, &domain.URL{ShortURL: "gone01", OriginalURL: "https://example.com/gone", ExpiresAt: &past})
+	repo.Create(ctx, &domain.URL{ShortURL: "soon01", OriginalURL: "https://example.com/soon", ExpiresAt: &soon})
+
+	url, err := repo.ClaimExpiredEvent(ctx, "gone01", now)
+	if err != nil || url == nil || url.OriginalURL != "https://example.com/gone" {
+		t.Fatalf("ClaimExpiredEvent() = %v, %v; want the expired link", url, err)
+	}
+	if url, err := repo.ClaimExpiredEvent(ctx, "gone01", now); err != nil || url != nil {
+		t.Errorf("second ClaimExpiredEvent() = %v, %v; want nothing left to claim", url, err)
+	}
+	if url, err := repo.ClaimExpiredEvent(ctx, "soon01", now); err != nil || url != nil {
+		t.Errorf("ClaimExpiredEvent() of a live link = %v, %v; want nil", url, err)
+	}
+
+	due, err := repo.ClaimExpiryReminders(ctx, now, now.Add(24*time.Hour), 10)
+	if err != nil || len(due) != 1 || due[0].ShortURL != "soon01" {
+		t.Fatalf("ClaimExpiryReminders() = %v, %v; want soon01", due, err)
+	}
+	if due, err := repo.ClaimExpiryReminders(ctx, now, now.Add(24*time.Hour), 10); err != nil || len(due) != 0 {
+		t.Errorf("second ClaimExpiryReminders() = %v, %v; want none", due, err)
+	}
+
+	extended := now.Add(2 * time.Hour)
+	if err := repo.SetExpiry(ctx, "soon01", &extended); err != nil {
+		t.Fatalf("SetExpiry() returned error: %v", err)
+	}
+	if due, err := repo.ClaimExpiryReminders(ctx, now, now.Add(24*time.Hour), 10); err != nil || len(due) != 1 {
+		t.Errorf("ClaimExpiryReminders() after a new expiry = %v, %v; want soon01 again", due, err)
+	}
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+)
+
+// TieredCacheConfig sizes the process-local L1 in front of the shared cache
+type TieredCacheConfig struct {
+	Size int           // entries kept, least recently used evicted first
+	TTL  time.Duration // longest an entry is served without asking L2 again
+}
+
+// l1Kind separates full URLs from compact destinations under one code
+type l1Kind uint8
+
+const (
+	l1URL l1Kind = iota
+	l1Destination
+)
+
+type l1Key struct {
+	code string
+	kind l1Kind
+}
+
+type l1Entry struct {
+	key       l1Key
+	url       domain.URL
+	dest      domain.Destination
+	expiresAt time.Time
+}
+
+// TieredCache is a small in-process LRU (L1) in front of a shared cache (L2)
+//
+// Use case: the hottest links are redirected thousands of times a second and
+// every one of those is a Redis round trip; L1 answers them from memory.
+// Learning: L1 is per instance, so a Delete only evicts it here. Other
+// instances keep serving their copy until it expires, which is why the TTL
+// should stay short (seconds): it bounds how stale a disabled or edited
+// link can be elsewhere. Entries never outlive the link's own expiry.
+type TieredCache struct {
+	l2      domain.CacheRepository
+	ttl     time.Duration
+	size    int
+	metrics *metrics.Metrics
+	now     func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[l1Key]*list.Element
+}
+
+// tieredDestinationCache is returned when L2 also serves compact destinations,
+// so the service still finds domain.DestinationCache on the wrapped cache
+type tieredDestinationCache struct {
+	*TieredCache
+	l2 domain.DestinationCache
+}
+
+// NewTieredCache wraps l2 with an L1 of cfg.Size entries
+// The result implements domain.DestinationCache exactly when l2 does.
+func NewTieredCache(l2 domain.CacheRepository, cfg TieredCacheConfig, m *metrics.Metrics) domain.CacheRepository {
+	if cfg.Size <= 0 {
+		cfg.Size = 10000
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 5 * time.Second
+	}
+
+	c := &TieredCache{
+		l2:      l2,
+		ttl:     cfg.TTL,
+		size:    cfg.Size,
+		metrics: m,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[l1Key]*list.Element, cfg.Size),
+	}
+	if dests, ok := l2.(domain.DestinationCache); ok {
+		return &tieredDestinationCache{TieredCache: c, l2: dests}
+	}
+	return c
+}
+
+var (
+	_ domain.CacheRepository  = (*TieredCache)(nil)
+	_ domain.DestinationCache = (*tieredDestinationCache)(nil)
+)
+
+func (c *TieredCache) Get(ctx context.Context, shortCode string) (*domain.URL, error) {
+	operation := "get"
+	if entry, ok := c.lookup(l1Key{shortCode, l1URL}); ok {
+		c.metrics.CacheL1HitsTotal.WithLabelValues(operation).Inc()
+		url := entry.url
+		return &url, nil
+	}
+	c.metrics.CacheL1MissesTotal.WithLabelValues(operation).Inc()
+
+	url, err := c.l2.Get(ctx, shortCode)
+	if err != nil || url == nil {
+		return url, err
+	}
+	c.store(&l1Entry{key: l1Key{shortCode, l1URL}, url: *url}, 0, url.ExpiresAt)
+	return url, nil
+}
+
+// Set writes L2 first, L1 only keeps what L2 accepted
+func (c *TieredCache) Set(ctx context.Context, url *domain.URL, ttl time.Duration) error {
+	if err := c.l2.Set(ctx, url, ttl); err != nil {
+		c.evict(url.ShortURL)
+		return err
+	}
+	c.store(&l1Entry{key: l1Key{url.ShortURL, l1URL}, url: *url}, ttl, url.ExpiresAt)
+	return nil
+}
+
+// Delete evicts L1 before L2 so a failing L2 can't leave this instance
+// serving the old entry
+func (c *TieredCache) Delete(ctx context.Context, shortCode string) error {
+	c.evict(shortCode)
+	return c.l2.Delete(ctx, shortCode)
+}
+
+func (c *TieredCache) DeleteMany(ctx context.Context, shortCodes []string) error {
+	for _, code := range shortCodes {
+		c.evict(code)
+	}
+	return c.l2.DeleteMany(ctx, shortCodes)
+}
+
+func (c *TieredCache) Exists(ctx context.Context, shortCode string) (bool, error) {
+	if _, ok := c.lookup(l1Key{shortCode, l1URL}); ok {
+		return true, nil
+	}
+	return c.l2.Exists(ctx, shortCode)
+}
+
+// Claims must be seen by every instance, so they always go to L2
+func (c *TieredCache) ClaimAlias(ctx context.Context, code string, ttl time.Duration) (bool, error) {
+	return c.l2.ClaimAlias(ctx, code, ttl)
+}
+
+func (c *TieredCache) ReleaseAlias(ctx context.Context, code string) error {
+	return c.l2.ReleaseAlias(ctx, code)
+}
+
+func (c *tieredDestinationCache) GetDestination(ctx context.Context, shortCode string) (*domain.Destination, error) {
+	operation := "get_destination"
+	if entry, ok := c.lookup(l1Key{shortCode, l1Destination}); ok {
+		c.metrics.CacheL1HitsTotal.WithLabelValues(operation).Inc()
+		dest := entry.dest
+		return &dest, nil
+	}
+	c.metrics.CacheL1MissesTotal.WithLabelValues(operation).Inc()
+
+	dest, err := c.l2.GetDestination(ctx, shortCode)
+	if err != nil || dest == nil {
+		return dest, err
+	}
+	c.store(&l1Entry{key: l1Key{shortCode, l1Destination}, dest: *dest}, 0, dest.ExpiresAt)
+	return dest, nil
+}
+
+func (c *tieredDestinationCache) SetDestination(ctx context.Context, shortCode string, dest domain.Destination, ttl time.Duration) error {
+	if err := c.l2.SetDestination(ctx, shortCode, dest, ttl); err != nil {
+		c.evict(shortCode)
+		return err
+	}
+	c.store(&l1Entry{key: l1Key{shortCode, l1Destination}, dest: dest}, ttl, dest.ExpiresAt)
+	return nil
+}
+
+// lookup returns a live entry and marks it recently used
+func (c *TieredCache) lookup(key l1Key) (*l1Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*l1Entry)
+	if !c.now().Before(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry, true
+}
+
+// store keeps entry for the L1 TTL, cut short by the L2 TTL and the link's
+// own expiry; an already expired link isn't stored at all
+func (c *TieredCache) store(entry *l1Entry, ttl time.Duration, linkExpiry *time.Time) {
+	now := c.now()
+	entry.expiresAt = now.Add(c.ttl)
+	if ttl > 0 && ttl < c.ttl {
+		entry.expiresAt = now.Add(ttl)
+	}
+	if linkExpiry != nil && linkExpiry.Before(entry.expiresAt) {
+		entry.expiresAt = *linkExpiry
+	}
+	if !now.Before(entry.expiresAt) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*l1Entry).key)
+	}
+}
+
+// evict drops both kinds of entry for a code
+func (c *TieredCache) evict(shortCode string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, kind := range []l1Kind{l1URL, l1Destination} {
+		key := l1Key{shortCode, kind}
+		if elem, ok := c.entries[key]; ok {
+			c.order.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+)
+
+// countingCache is an L2 that records how often it is read
+type countingCache struct {
+	*memory.CacheRepository
+	gets, destGets int
+	dests          map[string]domain.Destination
+}
+
+func newCountingCache() *countingCache {
+	return &countingCache{CacheRepository: memory.NewCacheRepository(time.Hour), dests: map[string]domain.Destination{}}
+}
+
+func (c *countingCache) Get(ctx context.Context, shortCode string) (*domain.URL, error) {
+	c.gets++
+	return c.CacheRepository.Get(ctx, shortCode)
+}
+
+func (c *countingCache) GetDestination(ctx context.Context, shortCode string) (*domain.Destination, error) {
+	c.destGets++
+	dest, ok := c.dests[shortCode]
+	if !ok {
+		return nil, nil
+	}
+	return &dest, nil
+}
+
+func (c *countingCache) SetDestination(ctx context.Context, shortCode string, dest domain.Destination, ttl time.Duration) error {
+	c.dests[shortCode] = dest
+	return nil
+}
+
+func (c *countingCache) Delete(ctx context.Context, shortCode string) error {
+	delete(c.dests, shortCode)
+	return c.CacheRepository.Delete(ctx, shortCode)
+}
+
+func newTestTieredCache(l2 domain.CacheRepository, size int) (*TieredCache, *metrics.Metrics) {
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	c := NewTieredCache(l2, TieredCacheConfig{Size: size, TTL: time.Minute}, m)
+	if tiered, ok := c.(*tieredDestinationCache); ok {
+		return tiered.TieredCache, m
+	}
+	return c.(*TieredCache), m
+}
+
+func TestTieredCacheServesL1BeforeL2(t *testing.T) {
+	ctx := context.Background()
+	l2 := newCountingCache()
+	l2.Set(ctx, &domain.URL{ShortURL: "abc", OriginalURL: "https://example.com/a"}, 0)
+	c, m := newTestTieredCache(l2, 10)
+
+	for i := 0; i < 3; i++ {
+		url, err := c.Get(ctx, "abc")
+		if err != nil || url == nil || url.OriginalURL != "https://example.com/a" {
+			t.Fatalf("Get #%d = %+v, %v", i, url, err)
+		}
+	}
+	if l2.gets != 1 {
+		t.Errorf("L2 reads = %d, want 1 (later reads from L1)", l2.gets)
+	}
+	if hits := testutil.ToFloat64(m.CacheL1HitsTotal.WithLabelValues("get")); hits != 2 {
+		t.Errorf("L1 hits = %v, want 2", hits)
+	}
+	if misses := testutil.ToFloat64(m.CacheL1MissesTotal.WithLabelValues("get")); misses != 1 {
+		t.Errorf("L1 misses = %v, want 1", misses)
+	}
+
+	// Unknown codes are never remembered, the database may create them
+	c.Get(ctx, "nope")
+	c.Get(ctx, "nope")
+	if l2.gets != 3 {
+		t.Errorf("L2 reads = %d, want misses to reach L2 every time", l2.gets)
+	}
+}
+
+func TestTieredCacheUpdatesAndDeletesEvictL1(t *testing.T) {
+	ctx := context.Background()
+	l2 := newCountingCache()
+	c, _ := newTestTieredCache(l2, 10)
+
+	c.Set(ctx, &domain.URL{ShortURL: "abc", OriginalURL: "https://example.com/old"}, 0)
+	c.Set(ctx, &domain.URL{ShortURL: "abc", OriginalURL: "https://example.com/new"}, 0)
+	if url, _ := c.Get(ctx, "abc"); url == nil || url.OriginalURL != "https://example.com/new" {
+		t.Errorf("after update Get = %+v, want new destination", url)
+	}
+
+	if err := c.Delete(ctx, "abc"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if url, _ := c.Get(ctx, "abc"); url != nil {
+		t.Errorf("after delete Get = %+v, want miss", url)
+	}
+
+	c.Set(ctx, &domain.URL{ShortURL: "x1", OriginalURL: "https://example.com/1"}, 0)
+	c.Set(ctx, &domain.URL{ShortURL: "x2", OriginalURL: "https://example.com/2"}, 0)
+	c.DeleteMany(ctx, []string{"x1", "x2"})
+	if ok, _ := c.Exists(ctx, "x1"); ok {
+		t.Error("x1 still cached after DeleteMany")
+	}
+}
+
+func TestTieredCacheBoundsEntries(t *testing.T) {
+	ctx := context.Background()
+	l2 := newCountingCache()
+	c, _ := newTestTieredCache(l2, 2)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	expiring := now.Add(time.Second)
+	c.Set(ctx, &domain.URL{ShortURL: "soon", OriginalURL: "https://example.com/s", ExpiresAt: &expiring}, 0)
+	c.Set(ctx, &domain.URL{ShortURL: "b", OriginalURL: "https://example.com/b"}, 0)
+
+	// Past the link's own expiry, long before the L1 TTL
+	now = now.Add(2 * time.Second)
+	l2.gets = 0
+	c.Get(ctx, "soon")
+	if l2.gets != 1 {
+		t.Errorf("expired link served from L1, L2 reads = %d", l2.gets)
+	}
+
+	// "b" was used least recently once "c" and "d" arrive
+	c.Set(ctx, &domain.URL{ShortURL: "c", OriginalURL: "https://example.com/c"}, 0)
+	c.Set(ctx, &domain.URL{ShortURL: "d", OriginalURL: "https://example.com/d"}, 0)
+	if len(c.entries) != 2 {
+		t.Errorf("L1 holds %d entries, want 2", len(c.entries))
+	}
+	if _, ok := c.lookup(l1Key{"b", l1URL}); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+}
+
+func TestTieredCacheDestinations(t *testing.T) {
+	ctx := context.Background()
+	l2 := newCountingCache()
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	c := NewTieredCache(l2, TieredCacheConfig{Size: 10, TTL: time.Minute}, m)
+
+	dests, ok := c.(domain.DestinationCache)
+	if !ok {
+		t.Fatal("tiered cache over a DestinationCache doesn't implement it")
+	}
+	dests.SetDestination(ctx, "abc", domain.Destination{OriginalURL: "https://example.com"}, 0)
+	for i := 0; i < 2; i++ {
+		if dest, _ := dests.GetDestination(ctx, "abc"); dest == nil {
+			t.Fatalf("GetDestination #%d missed", i)
+		}
+	}
+	if l2.destGets != 0 {
+		t.Errorf("L2 destination reads = %d, want 0", l2.destGets)
+	}
+
+	c.Delete(ctx, "abc")
+	if dest, _ := dests.GetDestination(ctx, "abc"); dest != nil {
+		t.Error("destination still served after Delete")
+	}
+
+	plain := NewTieredCache(memory.NewCacheRepository(time.Hour), TieredCacheConfig{}, m)
+	if _, ok := plain.(domain.DestinationCache); ok {
+		t.Error("tiered cache claims DestinationCache its L2 doesn't have")
+	}
+}
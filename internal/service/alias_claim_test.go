@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// slowURLRepo holds every insert open for a moment so racing creates overlap
+type slowURLRepo struct {
+	*fakeURLRepo
+	creates atomic.Int32
+	fail    error
+}
+
+func (r *slowURLRepo) Create(ctx context.Context, url *domain.URL) error {
+	r.creates.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	if r.fail != nil {
+		return r.fail
+	}
+	return r.fakeURLRepo.Create(ctx, url)
+}
+
+func TestCustomAliasRaceHasOneWinner(t *testing.T) {
+	repo := &slowURLRepo{fakeURLRepo: newFakeURLRepo()}
+	svc := newTestService(t, repo, newFakeCache(), URLServiceConfig{AllowCustom: true, AliasClaimTTL: time.Minute})
+
+	alias := "contested"
+	start := make(chan struct{})
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: &alias})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	wins, lost := 0, 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			wins++
+		case errors.Is(err, domain.ErrShortCodeExists):
+			lost++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if wins != 1 || lost != 1 {
+		t.Errorf("wins = %d, lost = %d, want exactly one winner", wins, lost)
+	}
+	// The loser was stopped by the claim, not by the unique constraint
+	if n := repo.creates.Load(); n != 1 {
+		t.Errorf("database inserts = %d, want 1", n)
+	}
+}
+
+func TestAliasClaimReleasedOnInsertFailure(t *testing.T) {
+	repo := &slowURLRepo{fakeURLRepo: newFakeURLRepo(), fail: errors.New("connection reset")}
+	cache := newFakeCache()
+	svc := newTestService(t, repo, cache, URLServiceConfig{AllowCustom: true, AliasClaimTTL: time.Minute})
+	ctx := context.Background()
+
+	alias := "retryme"
+	if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: &alias}); err == nil {
+		t.Fatal("Create succeeded against a failing database")
+	}
+	if cache.claims[alias] {
+		t.Fatal("claim still held after the insert failed")
+	}
+
+	repo.fail = nil
+	if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: &alias}); err != nil {
+		t.Fatalf("retry after failure: %v", err)
+	}
+}
+
+func TestAliasClaimFailsOpenWhenCacheIsDown(t *testing.T) {
+	cache := newFakeCache()
+	cache.err = errRedisDown
+	svc := newTestService(t, newFakeURLRepo(), cache, URLServiceConfig{AllowCustom: true, AliasClaimTTL: time.Minute})
+
+	alias := "nocache"
+	if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: &alias}); err != nil {
+		t.Fatalf("Create with cache down: %v", err)
+	}
+}
+
+func TestReservationReleasesClaimForOwnCreate(t *testing.T) {
+	cache := newFakeCache()
+	svc := newTestService(t, newFakeURLRepo(), cache, URLServiceConfig{AllowCustom: true, AliasClaimTTL: time.Minute})
+	ctx := domain.WithCaller(context.Background(), "user-1")
+
+	if _, err := svc.ReserveAlias(ctx, &domain.ReserveAliasRequest{Alias: "launch"}); err != nil {
+		t.Fatalf("ReserveAlias: %v", err)
+	}
+	if cache.claims["launch"] {
+		t.Error("reservation kept its claim, the owner's create would be refused")
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+// AddAlias gives the link shortCode the extra code alias
+// An alias redirects like the link's own code, and its clicks are counted
+// on the link, so stats stay per link however many codes lead to it. Only
+// the link's owner or the admin may add or remove its aliases.
+//
+// Use case: a memorable code for a campaign next to the generated one
+// already printed on older material.
+func (s *URLService) AddAlias(ctx context.Context, shortCode, alias string) (*domain.AliasResponse, error) {
+	shortCode = s.normalizeCode(shortCode)
+	alias = s.normalizeCode(alias)
+	if err := s.checkOwner(ctx, shortCode); err != nil {
+		return nil, err
+	}
+	if s.IsReservedCode(alias) {
+		return nil, domain.ErrShortCodeReserved
+	}
+	if err := s.urlRepo.AddAlias(ctx, shortCode, alias); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("alias added", zap.String("short_code", shortCode), zap.String("alias", alias))
+	s.audit(ctx, domain.AuditURLAliasAdd, shortCode, alias)
+	return &domain.AliasResponse{Alias: alias, ShortCode: shortCode}, nil
+}
+
+// RemoveAlias drops alias from the link shortCode; the code is free to use
+// again right away, nothing caches an alias
+func (s *URLService) RemoveAlias(ctx context.Context, shortCode, alias string) (*domain.AliasResponse, error) {
+	shortCode = s.normalizeCode(shortCode)
+	alias = s.normalizeCode(alias)
+	if err := s.checkOwner(ctx, shortCode); err != nil {
+		return nil, err
+	}
+	if err := s.urlRepo.RemoveAlias(ctx, shortCode, alias); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("alias removed", zap.String("short_code", shortCode), zap.String("alias", alias))
+	s.audit(ctx, domain.AuditURLAliasRemove, shortCode, alias)
+	return &domain.AliasResponse{Alias: alias, ShortCode: shortCode}, nil
+}
+
+// Aliases returns the extra codes of the link shortCode
+func (s *URLService) Aliases(ctx context.Context, shortCode string) (*domain.LinkAliases, error) {
+	shortCode = s.normalizeCode(shortCode)
+	aliases, err := s.urlRepo.ListAliases(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.LinkAliases{ShortCode: shortCode, Aliases: aliases}, nil
+}
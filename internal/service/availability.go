@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+// CheckAlias reports whether a create with custom_alias alias would succeed
+// right now, for alias pickers that check as the user types
+// The answer is advisory: someone may take the alias between the check and
+// the create, which then fails with the usual conflict.
+//
+// Learning: a free "is this code taken?" oracle is also an enumeration tool,
+// so checks are rate limited per client IP, and the cache is asked first so
+// the popular (cached) codes never reach the database.
+func (s *URLService) CheckAlias(ctx context.Context, alias string) (*domain.AliasAvailabilityResponse, error) {
+	if err := s.checkAliasRate(ctx); err != nil {
+		return nil, err
+	}
+
+	code := s.normalizeCode(alias)
+	resp := &domain.AliasAvailabilityResponse{Alias: code}
+	if s.IsReservedCode(code) {
+		resp.Reason = domain.AliasReserved
+		return resp, nil
+	}
+
+	if cached, err := s.cacheRepo.Get(ctx, code); err != nil {
+		s.logger.Warn("cache error", zap.Error(err), zap.String("short_code", code))
+	} else if cached != nil && inScope(ctx, cached) {
+		resp.Reason = domain.AliasTaken
+		return resp, nil
+	}
+
+	taken, err := s.urlRepo.IsShortCodeTaken(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		resp.Reason = domain.AliasTaken
+		return resp, nil
+	}
+	resp.Available = true
+	return resp, nil
+}
+
+// checkAliasRate fails open like checkClickRate
+func (s *URLService) checkAliasRate(ctx context.Context) error {
+	if s.aliasCheckLimiter == nil || s.aliasCheckRateLimit <= 0 {
+		return nil
+	}
+	allowed, err := s.aliasCheckLimiter.Allow(ctx, "alias-check:"+domain.ClientIPFrom(ctx), int64(s.aliasCheckRateLimit), time.Minute)
+	if err != nil {
+		s.logger.Warn("alias check rate limiter unavailable", zap.Error(err))
+		return nil
+	}
+	if !allowed {
+		return domain.ErrRateLimitExceeded
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+// CacheWritePolicy decides when a newly created link enters the cache
+//
+// Whatever the policy, the database is the source of truth and a cache write
+// failure never fails a create: reads fall back to the DB and repopulate.
+type CacheWritePolicy string
+
+const (
+	// CacheWriteThrough caches the link inside Create. The first redirect is a
+	// guaranteed hit, at the cost of a Redis round trip on every create and
+	// cache space for links that may never be clicked.
+	CacheWriteThrough CacheWritePolicy = "write-through"
+
+	// CacheWriteAround skips the cache on create and lets the first read
+	// populate it. Creates are as fast as the DB insert and only clicked links
+	// use cache memory; the first redirect of each link pays a DB read.
+	// Good for bulk imports and links that are mostly never visited.
+	CacheWriteAround CacheWritePolicy = "write-around"
+
+	// CacheWriteLazy caches the link in the background after Create returns.
+	// Creates don't wait on Redis but links are still warm shortly after;
+	// a redirect racing the write just misses and reads the DB.
+	CacheWriteLazy CacheWritePolicy = "lazy"
+)
+
+// lazyCacheWriteTimeout bounds a background cache write, which has no
+// request deadline to inherit
+const lazyCacheWriteTimeout = 2 * time.Second
+
+// ParseCacheWritePolicy validates a configured policy, empty is write-through
+func ParseCacheWritePolicy(s string) (CacheWritePolicy, error) {
+	switch p := CacheWritePolicy(s); p {
+	case "":
+		return CacheWriteThrough, nil
+	case CacheWriteThrough, CacheWriteAround, CacheWriteLazy:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown cache write policy %q, want write-through, write-around or lazy", s)
+	}
+}
+
+// cacheOnCreate applies the cache write policy to a newly created link
+func (s *URLService) cacheOnCreate(ctx context.Context, url *domain.URL) {
+	switch s.cacheWritePolicy {
+	case CacheWriteAround:
+		return
+
+	case CacheWriteLazy:
+		// The request context is cancelled as soon as the response is sent
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lazyCacheWriteTimeout)
+		entry := *url
+		go func() {
+			defer cancel()
+			s.warmCacheOrLog(ctx, &entry)
+		}()
+
+	default:
+		s.warmCacheOrLog(ctx, url)
+	}
+}
+
+// warmCacheOrLog caches url and only logs failures
+// Cache failures are non-fatal: the row is already in the DB, so the link
+// works and the first redirect will repopulate the cache. Failing here would
+// take URL creation down whenever Redis is down.
+func (s *URLService) warmCacheOrLog(ctx context.Context, url *domain.URL) {
+	if err := s.warmCache(ctx, url); err != nil {
+		s.logger.Warn("failed to set url entry in cache, continuing without cache",
+			zap.Error(err),
+			zap.String("short_code", url.ShortURL),
+		)
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+func TestCacheWritePolicies(t *testing.T) {
+	cached := func(c *fakeCache, code string) bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		_, ok := c.urls[code]
+		return ok
+	}
+
+	tests := []struct {
+		policy CacheWritePolicy
+		// whether the link is in the cache once Create has returned (lazy
+		// writes are awaited) and before anything reads it
+		wantCached bool
+	}{
+		{"", true},
+		{CacheWriteThrough, true},
+		{CacheWriteAround, false},
+		{CacheWriteLazy, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			cache := newFakeCache()
+			svc := newTestService(t, newFakeURLRepo(), cache, URLServiceConfig{CacheWritePolicy: tt.policy})
+			ctx := context.Background()
+
+			resp, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com"})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			got := cached(cache, resp.ShortCode)
+			if tt.policy == CacheWriteLazy {
+				deadline := time.Now().Add(time.Second)
+				for !got && time.Now().Before(deadline) {
+					time.Sleep(5 * time.Millisecond)
+					got = cached(cache, resp.ShortCode)
+				}
+			}
+			if got != tt.wantCached {
+				t.Fatalf("cached after create = %v, want %v", got, tt.wantCached)
+			}
+
+			// Every policy leaves the link cached after its first read
+			if _, err := svc.GetURL(ctx, resp.ShortCode); err != nil {
+				t.Fatalf("GetURL: %v", err)
+			}
+			if !cached(cache, resp.ShortCode) {
+				t.Error("link not cached after first read")
+			}
+		})
+	}
+}
+
+func TestParseCacheWritePolicy(t *testing.T) {
+	for in, want := range map[string]CacheWritePolicy{
+		"":              CacheWriteThrough,
+		"write-through": CacheWriteThrough,
+		"write-around":  CacheWriteAround,
+		"lazy":          CacheWriteLazy,
+	} {
+		if got, err := ParseCacheWritePolicy(in); err != nil || got != want {
+			t.Errorf("ParseCacheWritePolicy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseCacheWritePolicy("write-back"); err == nil {
+		t.Error("ParseCacheWritePolicy accepted an unknown policy")
+	}
+}
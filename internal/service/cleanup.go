@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+// CleanupWorker periodically removes data nobody can use any more: alias
+// reservations that lapsed without being claimed, and deleted links whose
+// undo window is over
+type CleanupWorker struct {
+	urlRepo  domain.URLRepository
+	interval time.Duration
+	logger   *zap.Logger
+}
+
+func NewCleanupWorker(urlRepo domain.URLRepository, interval time.Duration, logger *zap.Logger) *CleanupWorker {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return &CleanupWorker{
+		urlRepo:  urlRepo,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run cleans up every interval until ctx is cancelled
+func (w *CleanupWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.RunOnce(ctx)
+		}
+	}
+}
+
+// RunOnce does a single cleanup pass; failures are logged and retried next tick
+func (w *CleanupWorker) RunOnce(ctx context.Context) {
+	now := time.Now()
+
+	deleted, err := w.urlRepo.DeleteExpiredReservations(ctx, now)
+	if err != nil {
+		w.logger.Warn("failed to delete expired alias reservations", zap.Error(err))
+	} else if deleted > 0 {
+		w.logger.Info("deleted expired alias reservations", zap.Int64("count", deleted))
+	}
+
+	purged, err := w.urlRepo.PurgeDeleted(ctx, now)
+	if err != nil {
+		w.logger.Warn("failed to purge deleted links", zap.Error(err))
+	} else if purged > 0 {
+		w.logger.Info("purged deleted links", zap.Int64("count", purged))
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math/rand/v2"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+// consistencyCheckTimeout bounds one sampled comparison with the database
+const consistencyCheckTimeout = 2 * time.Second
+
+// sampleConsistency compares a cache hit with the database for a
+// consistencyCheckRate fraction of reads
+//
+// Learning: invalidation keeps the cache right as long as every write goes
+// through the service. A manual UPDATE or a restored backup doesn't, and the
+// cache then serves the old destination until the entry's TTL runs out.
+// Sampling a few hits finds that without a DB read per redirect, and the
+// comparison runs in the background so the sampled visitor doesn't wait.
+func (s *URLService) sampleConsistency(ctx context.Context, cached *domain.URL) {
+	if s.consistencyCheckRate <= 0 || rand.Float64() >= s.consistencyCheckRate {
+		return
+	}
+
+	// The request context is cancelled as soon as the response is sent
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), consistencyCheckTimeout)
+	entry := *cached
+	go func() {
+		defer cancel()
+		s.checkConsistency(ctx, &entry)
+	}()
+}
+
+// checkConsistency evicts the cached entry when the database disagrees
+// A replica that lags behind can cause a false alarm, which costs one cache
+// miss and nothing else.
+func (s *URLService) checkConsistency(ctx context.Context, cached *domain.URL) {
+	stored, err := s.urlRepo.GetByShortCode(domain.WithCodePrefix(ctx, cached.Prefix), cached.ShortURL)
+
+	var field string
+	switch {
+	case errors.Is(err, domain.ErrURLNotFound), errors.Is(err, domain.ErrURLDeleted),
+		errors.Is(err, domain.ErrURLDisabled), errors.Is(err, domain.ErrURLExpired):
+		// The cache served a link the database no longer resolves
+		field = "status"
+	case err != nil:
+		// A failing database says nothing about the cache
+		s.logger.Debug("consistency check skipped", zap.Error(err), zap.String("short_code", cached.ShortURL))
+		return
+	case stored.OriginalURL != cached.OriginalURL:
+		field = "original_url"
+	case !sameInstant(stored.ExpiresAt, cached.ExpiresAt):
+		field = "expires_at"
+	case !maps.Equal(stored.PlatformDestinations, cached.PlatformDestinations),
+		!maps.Equal(stored.CountryDestinations, cached.CountryDestinations):
+		field = "destinations"
+	default:
+		return
+	}
+
+	s.metrics.CacheDivergenceTotal.Inc()
+	s.logger.Warn("cache entry diverged from the database, evicting it",
+		zap.String("short_code", cached.ShortURL),
+		zap.String("field", field),
+	)
+	if err := s.cacheRepo.Delete(ctx, cached.ShortURL); err != nil {
+		s.logger.Warn("failed to evict diverged cache entry", zap.Error(err), zap.String("short_code", cached.ShortURL))
+	}
+}
+
+// sameInstant reports whether two optional timestamps are the same moment;
+// cache encodings keep expiry at second precision
+func sameInstant(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// waitForDivergence waits for the background check to count n divergences
+func waitForDivergence(t *testing.T, svc *URLService, n float64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for testutil.ToFloat64(svc.metrics.CacheDivergenceTotal) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("cache_divergence_total = %v, want %v", testutil.ToFloat64(svc.metrics.CacheDivergenceTotal), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConsistencyCheckEvictsStaleEntry(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		repo := newFakeURLRepo()
+		cache := newFakeCache()
+		svc := newTestService(t, repo, cache, URLServiceConfig{ConsistencyCheckRate: 1, CompactRedirectCache: compact})
+		ctx := context.Background()
+
+		resp, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/old"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+
+		// An out-of-band UPDATE: the database changes, the cache doesn't hear of it
+		repo.mu.Lock()
+		repo.urls[resp.ShortCode].OriginalURL = "https://example.com/new"
+		repo.mu.Unlock()
+
+		url, err := svc.Visit(ctx, resp.ShortCode)
+		if err != nil || url.OriginalURL != "https://example.com/old" {
+			t.Fatalf("compact=%v: Visit() = %v, %v, want the stale cached destination", compact, url, err)
+		}
+		waitForDivergence(t, svc, 1)
+
+		cache.mu.Lock()
+		_, full := cache.urls[resp.ShortCode]
+		_, dest := cache.dests[resp.ShortCode]
+		cache.mu.Unlock()
+		if full || dest {
+			t.Fatalf("compact=%v: diverged entry still cached (full %v, destination %v)", compact, full, dest)
+		}
+		if url, err := svc.Visit(ctx, resp.ShortCode); err != nil || url.OriginalURL != "https://example.com/new" {
+			t.Errorf("compact=%v: Visit() after eviction = %v, %v, want the database destination", compact, url, err)
+		}
+	}
+}
+
+func TestConsistencyCheckIgnoresMatchingEntries(t *testing.T) {
+	repo := newFakeURLRepo()
+	cache := newFakeCache()
+	svc := newTestService(t, repo, cache, URLServiceConfig{ConsistencyCheckRate: 1})
+	ctx := context.Background()
+
+	resp, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/same"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	cached, _ := cache.Get(ctx, resp.ShortCode)
+
+	// Run synchronously so there is nothing to wait for
+	svc.checkConsistency(ctx, cached)
+	if got := testutil.ToFloat64(svc.metrics.CacheDivergenceTotal); got != 0 {
+		t.Errorf("cache_divergence_total = %v for a matching entry, want 0", got)
+	}
+	if ok, _ := cache.Exists(ctx, resp.ShortCode); !ok {
+		t.Error("matching entry was evicted")
+	}
+}
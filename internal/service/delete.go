@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+// DefaultDeleteGracePeriod is how long a deleted link can be restored
+const DefaultDeleteGracePeriod = 24 * time.Hour
+
+// DeleteURL deletes a link in two phases: it resolves as gone (410) right
+// away, and the cleanup worker purges the row once the grace period is over.
+// Until then RestoreURL brings it back unchanged. Both are for the link's
+// owner or the admin only.
+//
+// Use case: a mistyped code in a bulk cleanup script would otherwise take a
+// printed or widely shared link down for good.
+func (s *URLService) DeleteURL(ctx context.Context, shortCode string) (*domain.DeleteResponse, error) {
+	shortCode = s.normalizeCode(shortCode)
+	if err := s.checkOwner(ctx, shortCode); err != nil {
+		return nil, err
+	}
+	purgeAfter, err := s.urlRepo.MarkDeleted(ctx, shortCode, time.Now().Add(s.deleteGracePeriod))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cacheRepo.Delete(ctx, shortCode); err != nil {
+		s.logger.Warn("failed to invalidate cache after delete",
+			zap.Error(err),
+			zap.String("short_code", shortCode),
+		)
+	}
+
+	s.logger.Info("URL deleted", zap.String("short_code", shortCode), zap.Time("purge_after", purgeAfter))
+	s.audit(ctx, domain.AuditURLDelete, shortCode)
+	s.events.Publish(ctx, domain.LinkEvent{
+		Type:       domain.EventURLDeleted,
+		ShortCode:  shortCode,
+		OccurredAt: time.Now(),
+	})
+
+	return &domain.DeleteResponse{
+		ShortCode:  shortCode,
+		Deleted:    true,
+		PurgeAfter: &purgeAfter,
+	}, nil
+}
+
+// RestoreURL cancels a pending deletion
+// Past the grace period the link may already be purged, so it is
+// ErrURLNotFound whether or not the worker got to it yet.
+func (s *URLService) RestoreURL(ctx context.Context, shortCode string) (*domain.DeleteResponse, error) {
+	shortCode = s.normalizeCode(shortCode)
+	if err := s.checkOwner(ctx, shortCode); err != nil {
+		return nil, err
+	}
+	if err := s.urlRepo.RestoreDeleted(ctx, shortCode, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// Nothing to evict: deleted links are never cached
+	s.logger.Info("URL restored", zap.String("short_code", shortCode))
+	s.audit(ctx, domain.AuditURLRestore, shortCode)
+
+	return &domain.DeleteResponse{ShortCode: shortCode}, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ExpiredBehavior is what a visitor of an expired link gets
+type ExpiredBehavior string
+
+const (
+	// ExpiredGone answers 410 Gone, telling the visitor the link existed
+	ExpiredGone ExpiredBehavior = "gone"
+
+	// ExpiredNotFound answers 404 like an unknown code, so a visitor can't
+	// tell an expired link from one that never existed
+	ExpiredNotFound ExpiredBehavior = "not_found"
+
+	// ExpiredRedirect sends the visitor to a fallback page, e.g. a "this
+	// link has expired" landing page
+	ExpiredRedirect ExpiredBehavior = "redirect"
+)
+
+// ParseExpiredBehavior validates a configured behavior, empty is gone
+// The redirect mode needs an absolute http(s) fallback URL.
+func ParseExpiredBehavior(s, redirectURL string) (ExpiredBehavior, error) {
+	switch b := ExpiredBehavior(s); b {
+	case "":
+		return ExpiredGone, nil
+	case ExpiredGone, ExpiredNotFound:
+		return b, nil
+	case ExpiredRedirect:
+		u, err := url.Parse(redirectURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("expired behavior redirect needs an absolute http(s) fallback URL, got %q", redirectURL)
+		}
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown expired behavior %q, want gone, not_found or redirect", s)
+	}
+}
+
+// ExpiredResponse returns how redirects of expired links are answered,
+// with the fallback URL of the redirect mode
+func (s *URLService) ExpiredResponse() (ExpiredBehavior, string) {
+	return s.expiredBehavior, s.expiredRedirectURL
+}
@@ -0,0 +1,20 @@
+package service
+
+import "testing"
+
+func TestParseExpiredBehavior(t *testing.T) {
+	if b, err := ParseExpiredBehavior("", ""); err != nil || b != ExpiredGone {
+		t.Errorf(`ParseExpiredBehavior("") = %q, %v`, b, err)
+	}
+	if b, err := ParseExpiredBehavior("redirect", "https://example.com/expired"); err != nil || b != ExpiredRedirect {
+		t.Errorf("ParseExpiredBehavior(redirect) = %q, %v", b, err)
+	}
+	for _, fallback := range []string{"", "/expired", "javascript:alert(1)"} {
+		if _, err := ParseExpiredBehavior("redirect", fallback); err == nil {
+			t.Errorf("ParseExpiredBehavior(redirect, %q) error = nil, want an error", fallback)
+		}
+	}
+	if _, err := ParseExpiredBehavior("teapot", ""); err == nil {
+		t.Error(`ParseExpiredBehavior("teapot") error = nil, want an error`)
+	}
+}
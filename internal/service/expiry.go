@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+// createExpiry decides a new link's expiry, nil meaning it never expires
+//
+// Precedence: an explicit never_expires (or expires_in: -1), then an explicit
+// expires_in, capped at MaxTTL, then DefaultTTL. config.Load and
+// UpdateSettings refuse a DefaultTTL over MaxTTL, so the default needs no cap.
+// Asking for a permanent link needs AllowPermanent, unless DefaultTTL is 0:
+// then every link is permanent by default and asking changes nothing.
+func (s *URLService) createExpiry(req *domain.CreateURLRequest) (*time.Time, error) {
+	defaultTTL := s.currentSettings().DefaultTTL
+	ttl := defaultTTL
+	switch {
+	case req.WantsPermanent():
+		if !s.allowPermanent && defaultTTL > 0 {
+			return nil, domain.ErrPermanentDisabled
+		}
+		return nil, nil
+	case req.ExpiresIn != nil && *req.ExpiresIn > 0:
+		ttl = time.Duration(*req.ExpiresIn) * time.Second
+		if s.maxTTL > 0 && ttl > s.maxTTL {
+			ttl = s.maxTTL
+		}
+	}
+
+	if ttl <= 0 {
+		return nil, nil
+	}
+	exp := time.Now().Add(ttl)
+	return &exp, nil
+}
+
+// GetExpiry reports a link's stored expiry, including for links that are
+// disabled or already expired
+func (s *URLService) GetExpiry(ctx context.Context, shortCode string) (*domain.ExpiryResponse, error) {
+	shortCode = s.normalizeCode(shortCode)
+	expiresAt, err := s.urlRepo.GetExpiry(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return expiryResponse(shortCode, expiresAt), nil
+}
+
+// UpdateExpiry sets, extends/shortens or removes a link's expiry
+//
+// The new expiry must be in the future and within MaxTTL from now; removing
+// it needs AllowPermanent, like never_expires on create. Extending reads the
+// current expiry first, so two concurrent extensions can lose one - fine for
+// an operator endpoint. An expired link can be revived by setting expires_at.
+func (s *URLService) UpdateExpiry(ctx context.Context, shortCode string, req *domain.ExpiryUpdateRequest) (*domain.ExpiryResponse, error) {
+	shortCode = s.normalizeCode(shortCode)
+
+	set := 0
+	if req.ExpiresAt != nil {
+		set++
+	}
+	if req.ExtendBySeconds != nil {
+		set++
+	}
+	if req.NeverExpires {
+		set++
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("%w: give exactly one of expires_at, extend_by_seconds or never_expires", domain.ErrInvalidExpiry)
+	}
+
+	var expiresAt *time.Time
+	switch {
+	case req.NeverExpires:
+		if !s.allowPermanent {
+			return nil, domain.ErrPermanentDisabled
+		}
+
+	case req.ExpiresAt != nil:
+		exp := *req.ExpiresAt
+		expiresAt = &exp
+
+	default:
+		current, err := s.urlRepo.GetExpiry(ctx, shortCode)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil {
+			return nil, fmt.Errorf("%w: the link never expires, set expires_at instead", domain.ErrInvalidExpiry)
+		}
+		exp := current.Add(time.Duration(*req.ExtendBySeconds) * time.Second)
+		expiresAt = &exp
+	}
+
+	if expiresAt != nil {
+		now := time.Now()
+		if !expiresAt.After(now) {
+			return nil, fmt.Errorf("%w: the new expiry is in the past, disable the link instead", domain.ErrInvalidExpiry)
+		}
+		if s.maxTTL > 0 && expiresAt.Sub(now) > s.maxTTL {
+			return nil, fmt.Errorf("%w: the new expiry is more than %s away", domain.ErrInvalidExpiry, s.maxTTL)
+		}
+	}
+
+	if err := s.urlRepo.SetExpiry(ctx, shortCode, expiresAt); err != nil {
+		return nil, err
+	}
+
+	// Cached entries carry the old expiry, which redirects check against;
+	// dropping them makes the next read cache the new one
+	if err := s.cacheRepo.Delete(ctx, shortCode); err != nil {
+		s.logger.Warn("failed to invalidate cache after expiry change",
+			zap.Error(err),
+			zap.String("short_code", shortCode),
+		)
+	}
+
+	s.logger.Info("URL expiry changed", zap.String("short_code", shortCode), zap.Timep("expires_at", expiresAt))
+	s.audit(ctx, domain.AuditURLExpiry, shortCode)
+	return expiryResponse(shortCode, expiresAt), nil
+}
+
+func expiryResponse(shortCode string, expiresAt *time.Time) *domain.ExpiryResponse {
+	return &domain.ExpiryResponse{
+		ShortCode:    shortCode,
+		ExpiresAt:    expiresAt,
+		NeverExpires: expiresAt == nil,
+		Expired:      expiresAt != nil && time.Now().After(*expiresAt),
+	}
+}
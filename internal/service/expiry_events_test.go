@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/repository/memory"
+	"go.uber.org/zap"
+)
+
+// recordingPublisher keeps every published event
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []domain.LinkEvent
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event domain.LinkEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+}
+
+func (p *recordingPublisher) ofType(t domain.EventType) []domain.LinkEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var matching []domain.LinkEvent
+	for _, event := range p.events {
+		if event.Type == t {
+			matching = append(matching, event)
+		}
+	}
+	return matching
+}
+
+func newPublishingService(t *testing.T, repo domain.URLRepository, cache domain.CacheRepository, events domain.EventPublisher) *URLService {
+	t.Helper()
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	return NewURLService(repo, cache, keyGen, events, nil, zap.NewNop(), m, URLServiceConfig{BaseURL: "http://short.test"})
+}
+
+func TestExpiredEventPublishedOncePerExpiry(t *testing.T) {
+	repo := memory.NewURLRepository()
+	cache := memory.NewCacheRepository(time.Hour)
+	events := &recordingPublisher{}
+	svc := newPublishingService(t, repo, cache, events)
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	if err := repo.Create(ctx, &domain.URL{ShortURL: "gone01", OriginalURL: "https://example.com/a", ExpiresAt: &past}); err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	// A stale cache entry sends the first visit down the cache path, the
+	// rest go to the database
+	cached := domain.URL{ShortURL: "gone01", OriginalURL: "https://example.com/a", ExpiresAt: &past, IsActive: true}
+	if err := cache.Set(ctx, &cached, time.Hour); err != nil {
+		t.Fatalf("cache Set() returned error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Visit(ctx, "gone01"); !errors.Is(err, domain.ErrURLExpired) {
+			t.Fatalf("Visit() #%d error = %v, want ErrURLExpired", i+1, err)
+		}
+	}
+	expired := events.ofType(domain.EventURLExpired)
+	if len(expired) != 1 || expired[0].ShortCode != "gone01" || expired[0].OriginalURL != "https://example.com/a" {
+		t.Fatalf("expired events = %+v, want one for gone01", expired)
+	}
+
+	// A new expiry that passes is announced again
+	again := time.Now().Add(-time.Second)
+	if err := repo.SetExpiry(ctx, "gone01", &again); err != nil {
+		t.Fatalf("SetExpiry() returned error: %v", err)
+	}
+	svc.Visit(ctx, "gone01")
+	svc.Visit(ctx, "gone01")
+	if n := len(events.ofType(domain.EventURLExpired)); n != 2 {
+		t.Errorf("expired events after a second expiry = %d, want 2", n)
+	}
+}
+
+func TestExpiryReminderWorker(t *testing.T) {
+	repo := memory.NewURLRepository()
+	events := &recordingPublisher{}
+	ctx := context.Background()
+
+	soon := time.Now().Add(time.Hour)
+	later := time.Now().Add(48 * time.Hour)
+	past := time.Now().Add(-time.Hour)
+	for code, expiresAt := range map[string]*time.Time{"soon01": &soon, "later1": &later, "past01": &past, "never1": nil} {
+		if err := repo.Create(ctx, &domain.URL{ShortURL: code, OriginalURL: "https://example.com/" + code, ExpiresAt: expiresAt}); err != nil {
+			t.Fatalf("Create(%s) returned error: %v", code, err)
+		}
+	}
+
+	worker := NewExpiryReminderWorker(repo, events, 24*time.Hour, time.Minute, zap.NewNop())
+	worker.RunOnce(ctx)
+	worker.RunOnce(ctx)
+
+	reminders := events.ofType(domain.EventURLExpiring)
+	if len(reminders) != 1 || reminders[0].ShortCode != "soon01" {
+		t.Fatalf("reminders = %+v, want one for soon01", reminders)
+	}
+	if got := reminders[0].ExpiresAt; got == nil || !got.Equal(soon) {
+		t.Errorf("reminder expires_at = %v, want %v", got, soon)
+	}
+
+	// Extending the link re-arms its reminder
+	extended := time.Now().Add(2 * time.Hour)
+	if err := repo.SetExpiry(ctx, "soon01", &extended); err != nil {
+		t.Fatalf("SetExpiry() returned error: %v", err)
+	}
+	worker.RunOnce(ctx)
+	if n := len(events.ofType(domain.EventURLExpiring)); n != 2 {
+		t.Errorf("reminders after extending soon01 = %d, want 2", n)
+	}
+}
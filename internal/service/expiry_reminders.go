@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+// reminderBatchSize bounds the links claimed per query; a pass keeps
+// claiming until a batch comes back short
+const reminderBatchSize = 100
+
+// ExpiryReminderWorker publishes url.expiring events lead ahead of links'
+// expiry, so integrations can warn owners while the link still works
+// Each expiry is reminded once across instances, see
+// domain.URLRepository.ClaimExpiryReminders. A link created with less than
+// lead to live is reminded on the next pass.
+type ExpiryReminderWorker struct {
+	urlRepo  domain.URLRepository
+	events   domain.EventPublisher
+	lead     time.Duration
+	interval time.Duration
+	logger   *zap.Logger
+}
+
+func NewExpiryReminderWorker(urlRepo domain.URLRepository, events domain.EventPublisher, lead, interval time.Duration, logger *zap.Logger) *ExpiryReminderWorker {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &ExpiryReminderWorker{
+		urlRepo:  urlRepo,
+		events:   events,
+		lead:     lead,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run sends reminders every interval until ctx is cancelled
+func (w *ExpiryReminderWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.RunOnce(ctx)
+		}
+	}
+}
+
+// RunOnce sends the reminders due now; failures are logged and retried next tick
+func (w *ExpiryReminderWorker) RunOnce(ctx context.Context) {
+	now := time.Now()
+	for {
+		due, err := w.urlRepo.ClaimExpiryReminders(ctx, now, now.Add(w.lead), reminderBatchSize)
+		if err != nil {
+			w.logger.Warn("failed to claim expiry reminders", zap.Error(err))
+			return
+		}
+		for _, url := range due {
+			w.events.Publish(ctx, domain.LinkEvent{
+				Type:        domain.EventURLExpiring,
+				ShortCode:   url.ShortURL,
+				Prefix:      url.Prefix,
+				OriginalURL: url.OriginalURL,
+				ExpiresAt:   url.ExpiresAt,
+				OccurredAt:  now.UTC(),
+			})
+		}
+		if len(due) < reminderBatchSize {
+			return
+		}
+	}
+}
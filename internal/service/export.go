@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+)
+
+// exportPageSize is how many links an export reads per query
+const exportPageSize = pagination.MaxLimit
+
+// ExportURLs calls emit with every link, newest first. Paused, expired and
+// deleted links are skipped unless includeInactive is set; alias
+// reservations never have a destination and are never exported.
+func (s *URLService) ExportURLs(ctx context.Context, includeInactive bool, emit func(domain.ExportedURL) error) error {
+	return s.eachURL(ctx, nil, func(url domain.URL) error {
+		if !includeInactive && !exportable(&url) {
+			return nil
+		}
+		return emit(domain.ExportOf(url))
+	})
+}
+
+// StreamURLs calls emit with every link ListURLs would page through, newest
+// first, starting after the cursor if one is given
+// Use case: admin tooling pulling many links in one response (NDJSON)
+// instead of walking next_cursor page by page, so only the admin may: an
+// anonymous caller gets ErrUnauthorized, a user's key ErrForbidden.
+func (s *URLService) StreamURLs(ctx context.Context, after *pagination.Cursor, emit func(domain.URL) error) error {
+	if !domain.IsAdmin(ctx) {
+		if _, ok := domain.CallerFrom(ctx); ok {
+			return domain.ErrForbidden
+		}
+		return domain.ErrUnauthorized
+	}
+	return s.eachURL(ctx, after, emit)
+}
+
+// eachURL calls emit with every link after the cursor (all with nil)
+//
+// Learning: the links are read a page at a time with the keyset cursor, so
+// walking the whole table holds one page in memory and never pays for a
+// deep OFFSET. An error from emit (the client went away) stops the walk.
+func (s *URLService) eachURL(ctx context.Context, after *pagination.Cursor, emit func(domain.URL) error) error {
+	page := pagination.Request{Limit: exportPageSize, After: after}
+	for {
+		urls, err := s.urlRepo.List(ctx, domain.ListScope{All: true}, page)
+		if err != nil {
+			return err
+		}
+
+		more := len(urls) > page.Limit
+		if more {
+			urls = urls[:page.Limit]
+		}
+		for _, url := range urls {
+			if err := emit(url); err != nil {
+				return err
+			}
+		}
+		if !more {
+			return nil
+		}
+
+		cursor := domain.CursorOf(urls[len(urls)-1])
+		page.After = &cursor
+	}
+}
+
+// exportable reports whether a link is live: active, not expired and not
+// deleted
+func exportable(url *domain.URL) bool {
+	return url.IsActive && url.PurgeAfter == nil && !url.IsExpired()
+}
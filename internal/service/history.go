@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"go.uber.org/zap"
+)
+
+// UpdateDestination points a link somewhere else and records the change in
+// its history, with the actor the audit log would name
+// The new destination passes the same checks as on create, and only the
+// link's owner or the admin may change it.
+func (s *URLService) UpdateDestination(ctx context.Context, shortCode string, req *domain.UpdateDestinationRequest) (*domain.URLHistoryEntry, error) {
+	shortCode = s.normalizeCode(shortCode)
+	originalURL, err := s.validateDestination(req.OriginalURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkOwner(ctx, shortCode); err != nil {
+		return nil, err
+	}
+
+	actor := domain.NewAuditEntry(ctx, domain.AuditURLDestination, []string{shortCode})
+	entry := &domain.URLHistoryEntry{
+		ShortCode:   shortCode,
+		OriginalURL: originalURL,
+		Actor:       actor.Actor,
+		ActorType:   actor.ActorType,
+		ChangedAt:   time.Now().UTC(),
+	}
+	if err := s.urlRepo.UpdateDestination(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	// Both cache entries hold the old destination
+	if err := s.cacheRepo.Delete(ctx, shortCode); err != nil {
+		s.logger.Warn("failed to invalidate cache after destination change",
+			zap.Error(err),
+			zap.String("short_code", shortCode),
+		)
+	}
+
+	s.logger.Info("URL destination changed", zap.String("short_code", shortCode))
+	s.audit(ctx, domain.AuditURLDestination, shortCode)
+	return entry, nil
+}
+
+// History returns one page of a link's destination changes, newest first
+// Unknown codes have no history rather than being an error. A private link's
+// history is for whoever may resolve it (see checkAccess) and the admin; a
+// link that doesn't resolve right now, so whose visibility can't be read,
+// only shows its history to its owner and the admin.
+func (s *URLService) History(ctx context.Context, shortCode string, page pagination.Request) (pagination.Page[domain.URLHistoryEntry], error) {
+	shortCode = s.normalizeCode(shortCode)
+	if !domain.IsAdmin(ctx) {
+		url, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+		switch {
+		case err == nil:
+			err = checkAccess(ctx, url)
+		case errors.Is(err, domain.ErrURLNotFound):
+			err = nil
+		default:
+			err = s.checkOwner(ctx, shortCode)
+		}
+		if err != nil {
+			return pagination.Page[domain.URLHistoryEntry]{}, err
+		}
+	}
+
+	entries, err := s.urlRepo.ListHistory(ctx, shortCode, page)
+	if err != nil {
+		return pagination.Page[domain.URLHistoryEntry]{}, err
+	}
+	return pagination.NewPage(page, entries, domain.HistoryCursorOf), nil
+}
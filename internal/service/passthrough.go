@@ -0,0 +1,92 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// QueryPrecedence decides which value wins when a click's query string and a
+// passthrough link's destination both carry the same parameter
+type QueryPrecedence string
+
+const (
+	// QueryPrecedenceStored keeps the destination's own values, so a visitor
+	// can't rewrite e.g. utm_campaign by editing the short link
+	QueryPrecedenceStored QueryPrecedence = "stored"
+
+	// QueryPrecedenceIncoming lets click-time values replace stored ones
+	QueryPrecedenceIncoming QueryPrecedence = "incoming"
+)
+
+// ParseQueryPrecedence validates a configured precedence, empty is stored
+func ParseQueryPrecedence(s string) (QueryPrecedence, error) {
+	switch p := QueryPrecedence(s); p {
+	case "":
+		return QueryPrecedenceStored, nil
+	case QueryPrecedenceStored, QueryPrecedenceIncoming:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown query precedence %q, want stored or incoming", s)
+	}
+}
+
+// RedirectTarget is where a click on url goes: the destination, plus the
+// click's query parameters for passthrough links
+//
+// Only http(s) destinations take parameters; a merge that can't be parsed or
+// would exceed MaxURLLength falls back to the plain destination rather than
+// failing the redirect.
+func (s *URLService) RedirectTarget(link *domain.URL, rawQuery string) string {
+	return s.RedirectTargetFor(link, "", "", rawQuery)
+}
+
+// RedirectTargetFor is RedirectTarget for a visitor on platform (see
+// useragent.Platform) in country (see VisitorCountry): the link's
+// destination for them, with the click's query merged in the same way
+func (s *URLService) RedirectTargetFor(link *domain.URL, platform, country, rawQuery string) string {
+	base := link.DestinationFor(platform, country)
+	if !link.PassthroughQuery || rawQuery == "" {
+		return base
+	}
+
+	dest, err := url.Parse(base)
+	if err != nil || (dest.Scheme != "http" && dest.Scheme != "https") {
+		return base
+	}
+	// Malformed pairs are dropped, the rest still pass through
+	incoming, _ := url.ParseQuery(rawQuery)
+	if len(incoming) == 0 {
+		return base
+	}
+
+	stored := dest.Query()
+	switch s.queryPrecedence {
+	case QueryPrecedenceIncoming:
+		for key, values := range incoming {
+			stored[key] = values
+		}
+		dest.RawQuery = stored.Encode()
+
+	default:
+		// Appending leaves the stored query byte for byte as the owner wrote it
+		extra := url.Values{}
+		for key, values := range incoming {
+			if _, taken := stored[key]; !taken {
+				extra[key] = values
+			}
+		}
+		if len(extra) == 0 {
+			return base
+		}
+		dest.RawQuery = strings.TrimPrefix(dest.RawQuery+"&"+extra.Encode(), "&")
+	}
+
+	target := dest.String()
+	if s.maxURLLength > 0 && len(target) > s.maxURLLength {
+		return base
+	}
+	return target
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+func TestRedirectTarget(t *testing.T) {
+	tests := []struct {
+		name       string
+		precedence QueryPrecedence
+		dest       string
+		passthru   bool
+		query      string
+		want       string
+	}{
+		{"disabled strips the query", "", "https://example.com/p?a=1", false, "ref=twitter", "https://example.com/p?a=1"},
+		{"no incoming query", "", "https://example.com/p?a=1", true, "", "https://example.com/p?a=1"},
+		{"appended to no query", "", "https://example.com/p", true, "ref=twitter", "https://example.com/p?ref=twitter"},
+		{"appended after stored", "", "https://example.com/p?a=1#top", true, "ref=twitter", "https://example.com/p?a=1&ref=twitter#top"},
+		{"stored wins conflicts", QueryPrecedenceStored, "https://example.com/p?utm_source=mail", true, "utm_source=evil&ref=x", "https://example.com/p?utm_source=mail&ref=x"},
+		{"only conflicts, stored wins", QueryPrecedenceStored, "https://example.com/p?a=1", true, "a=2", "https://example.com/p?a=1"},
+		{"incoming wins conflicts", QueryPrecedenceIncoming, "https://example.com/p?utm_source=mail&b=2", true, "utm_source=tw", "https://example.com/p?b=2&utm_source=tw"},
+		{"values are re-encoded", "", "https://example.com/p", true, "q=a%20b&x=<script>", "https://example.com/p?q=a+b&x=%3Cscript%3E"},
+		{"non-web destinations untouched", "", "mailto:sales@acme.com", true, "ref=twitter", "mailto:sales@acme.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{QueryPrecedence: tt.precedence})
+			link := &domain.URL{OriginalURL: tt.dest, PassthroughQuery: tt.passthru}
+			if got := svc.RedirectTarget(link, tt.query); got != tt.want {
+				t.Errorf("RedirectTarget() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirectTargetRespectsMaxURLLength(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{MaxURLLength: 64})
+	link := &domain.URL{OriginalURL: "https://example.com/p", PassthroughQuery: true}
+
+	if got := svc.RedirectTarget(link, "pad="+strings.Repeat("x", 100)); got != link.OriginalURL {
+		t.Errorf("oversized merge = %q, want the plain destination", got)
+	}
+}
+
+func TestParseQueryPrecedence(t *testing.T) {
+	if p, err := ParseQueryPrecedence(""); err != nil || p != QueryPrecedenceStored {
+		t.Errorf(`ParseQueryPrecedence("") = %q, %v`, p, err)
+	}
+	if _, err := ParseQueryPrecedence("newest"); err == nil {
+		t.Error(`ParseQueryPrecedence("newest") error = nil, want an error`)
+	}
+}
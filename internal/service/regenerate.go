@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+// OldCodeBehavior is what a link's previous code does once RegenerateCode
+// gave the link a new one
+type OldCodeBehavior string
+
+const (
+	// OldCodeRetire answers the old code with 410 Gone and never hands it
+	// out again, for a code that leaked
+	OldCodeRetire OldCodeBehavior = "retire"
+
+	// OldCodeAlias keeps the old code redirecting as an alias of the link,
+	// for a code that is only being rebranded
+	OldCodeAlias OldCodeBehavior = "alias"
+)
+
+// ParseOldCodeBehavior validates a configured behavior, empty is retire
+func ParseOldCodeBehavior(s string) (OldCodeBehavior, error) {
+	switch b := OldCodeBehavior(s); b {
+	case "":
+		return OldCodeRetire, nil
+	case OldCodeRetire, OldCodeAlias:
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown old code behavior %q, want retire or alias", s)
+	}
+}
+
+// RegenerateCode moves a link to a freshly generated code
+// The link keeps its row, so destination, click count, history and aliases
+// carry over, and stored click events move to the new code with it. Only
+// the link's owner or the admin may do it.
+//
+// Use case: a code leaked, or spells something unfortunate, and the link
+// itself should stay as it is.
+func (s *URLService) RegenerateCode(ctx context.Context, shortCode string) (*domain.RegenerateResponse, error) {
+	shortCode = s.normalizeCode(shortCode)
+	if err := s.checkOwner(ctx, shortCode); err != nil {
+		return nil, err
+	}
+	keepAlias := s.oldCodeBehavior == OldCodeAlias
+
+	newCode, err := s.withGeneratedCode(ctx, 0, func(code string) error {
+		// The link keeps its prefix
+		return s.urlRepo.RenameShortCode(ctx, shortCode, code, keepAlias)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The old code must stop serving from the cache right away; as an alias
+	// it resolves through the new code from now on
+	if err := s.cacheRepo.Delete(ctx, shortCode); err != nil {
+		s.logger.Warn("failed to invalidate cache after regenerate",
+			zap.Error(err),
+			zap.String("short_code", shortCode),
+		)
+	}
+
+	resp := &domain.RegenerateResponse{
+		ShortCode:          newCode,
+		PreviousCode:       shortCode,
+		PreviousCodeStatus: string(s.oldCodeBehavior),
+	}
+	// Links that don't resolve right now (disabled, expired) get no short
+	// URL and aren't cached; their prefix can't be read back
+	if url, err := s.urlRepo.GetByShortCode(ctx, newCode); err == nil {
+		resp.ShortURL = s.shortURL(url.Prefix, newCode)
+		if err := s.warmCache(ctx, url); err != nil {
+			s.logger.Warn("failed to cache regenerated link", zap.Error(err), zap.String("short_code", newCode))
+		}
+	}
+
+	s.logger.Info("short code regenerated",
+		zap.String("short_code", newCode),
+		zap.String("previous_code", shortCode),
+		zap.String("previous_code_status", resp.PreviousCodeStatus),
+	)
+	s.audit(ctx, domain.AuditURLRegenerate, shortCode, newCode)
+	return resp, nil
+}
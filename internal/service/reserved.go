@@ -0,0 +1,36 @@
+package service
+
+import "strings"
+
+// DefaultReservedCodes are the server's own top-level path segments
+// They are reserved even while their routes are switched off (e.g. /admin
+// without an admin token), so turning a feature on later can't find a link
+// already sitting on its path.
+var DefaultReservedCodes = []string{"api", "admin", "health", "metrics", "version", "p"}
+
+// ReserveCodes adds route segments no short code may take
+// Call it once every route is mounted, before serving: main passes the first
+// segment of each registered path, so a new route is protected without
+// anyone remembering to list it.
+//
+// Learning: redirects live at /:shortCode, and a static route like /metrics
+// wins over the wildcard, so a link with code "metrics" could be created
+// but never visited. Refusing the code up front is the only fix that doesn't
+// break the route or the link.
+func (s *URLService) ReserveCodes(codes ...string) {
+	s.reservedMu.Lock()
+	defer s.reservedMu.Unlock()
+	for _, code := range codes {
+		s.reservedCodes[strings.ToLower(code)] = struct{}{}
+	}
+}
+
+// IsReservedCode reports whether shortCode is a route segment
+// Compared case-insensitively: "API" wouldn't shadow /api, but a link that
+// differs from a route only by case is a phishing look-alike anyway.
+func (s *URLService) IsReservedCode(shortCode string) bool {
+	s.reservedMu.RLock()
+	defer s.reservedMu.RUnlock()
+	_, reserved := s.reservedCodes[strings.ToLower(shortCode)]
+	return reserved
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// AliasScope decides which links a short code has to be unique among
+type AliasScope string
+
+const (
+	// AliasScopeGlobal makes every code unique across the whole service
+	AliasScopeGlobal AliasScope = "global"
+
+	// AliasScopePrefix makes codes unique per prefix, so /news/summer-sale
+	// and /sports/summer-sale can be different links
+	AliasScopePrefix AliasScope = "prefix"
+)
+
+// ParseAliasScope validates a configured scope, empty is global
+// Per-user scopes aren't offered: a redirect carries no user, so /code
+// couldn't tell two users' links apart.
+func ParseAliasScope(s string) (AliasScope, error) {
+	switch scope := AliasScope(s); scope {
+	case "":
+		return AliasScopeGlobal, nil
+	case AliasScopeGlobal, AliasScopePrefix:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("unknown alias scope %q, want global or prefix", s)
+	}
+}
+
+// LinkContext narrows the link lookups of a request naming a link by code,
+// e.g. a management call, to the link under prefix
+// Only with prefix scope: codes are unique globally otherwise, and a code
+// names its link whatever the prefix.
+func (s *URLService) LinkContext(ctx context.Context, prefix string) context.Context {
+	if s.aliasScope != AliasScopePrefix {
+		return ctx
+	}
+	return domain.WithCodePrefix(ctx, prefix)
+}
+
+// inScope reports whether url is the link the lookups in ctx are narrowed
+// to; links sharing a code share its cache entries
+func inScope(ctx context.Context, url *domain.URL) bool {
+	prefix, narrowed := domain.CodePrefixFrom(ctx)
+	return !narrowed || url.Prefix == prefix
+}
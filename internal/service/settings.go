@@ -0,0 +1,68 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+// Settings are the URLService options that can change while it serves
+// traffic; everything else in URLServiceConfig is fixed at construction
+type Settings struct {
+	DefaultTTL time.Duration
+	CacheTTL   time.Duration
+
+	ClickRateLimit      int
+	ClickRateWindow     time.Duration
+	ClickRateLimitPerIP bool
+}
+
+func (s Settings) withDefaults() Settings {
+	if s.ClickRateWindow <= 0 {
+		s.ClickRateWindow = time.Minute
+	}
+	return s
+}
+
+// Validate rejects values a running service can't use
+func (s Settings) Validate() error {
+	if s.DefaultTTL < 0 {
+		return fmt.Errorf("default TTL must not be negative, got %s", s.DefaultTTL)
+	}
+	if s.CacheTTL < 0 {
+		return fmt.Errorf("cache TTL must not be negative, got %s", s.CacheTTL)
+	}
+	if s.ClickRateLimit < 0 {
+		return fmt.Errorf("click rate limit must not be negative, got %d", s.ClickRateLimit)
+	}
+	return nil
+}
+
+// CurrentSettings returns the settings in effect
+func (s *URLService) CurrentSettings() Settings {
+	return *s.currentSettings()
+}
+
+func (s *URLService) currentSettings() *Settings {
+	return s.settings.Load()
+}
+
+// UpdateSettings swaps in new settings for every request that starts after it
+// returns; nothing changes when they don't validate. MaxTTL is fixed at
+// construction, so a DefaultTTL over the running MaxTTL is refused here even
+// when the reloaded config raised both.
+//
+// Learning: the settings are one immutable value behind an atomic pointer,
+// so a request reads them without a lock and never sees half an update, e.g.
+// the new limit with the old window. Requests already running keep the
+// pointer they loaded.
+func (s *URLService) UpdateSettings(settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	if s.maxTTL > 0 && settings.DefaultTTL > s.maxTTL {
+		return fmt.Errorf("default TTL %s is longer than the max TTL %s", settings.DefaultTTL, s.maxTTL)
+	}
+	settings = settings.withDefaults()
+	s.settings.Store(&settings)
+	return nil
+}
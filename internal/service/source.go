@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+// DefaultSources are the surfaces links may be attributed to when none are configured
+var DefaultSources = []string{"web", "cli", domain.SourceAPI, "extension"}
+
+func newSourceSet(sources []string) map[string]struct{} {
+	if len(sources) == 0 {
+		sources = DefaultSources
+	}
+	set := make(map[string]struct{}, len(sources))
+	for _, source := range sources {
+		set[strings.ToLower(source)] = struct{}{}
+	}
+	return set
+}
+
+// createSource is the surface a new link is attributed to: the source the
+// client named, api for API key callers that named none, nothing for
+// anonymous ones
+//
+// Learning: the client says where it comes from, so the value is a hint for
+// product stats, not a security boundary. The allowed list only keeps typos
+// and made-up values from splitting the stats into useless buckets.
+func (s *URLService) createSource(ctx context.Context) (string, error) {
+	source := strings.ToLower(strings.TrimSpace(domain.SourceFrom(ctx)))
+	if source == "" {
+		if _, authenticated := domain.CallerFrom(ctx); authenticated {
+			return domain.SourceAPI, nil
+		}
+		return "", nil
+	}
+	if _, ok := s.allowedSources[source]; !ok {
+		return "", domain.ErrSourceNotAllowed
+	}
+	return source, nil
+}
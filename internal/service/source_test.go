@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+func TestCreateRecordsSource(t *testing.T) {
+	repo := newFakeURLRepo()
+	svc := newTestService(t, repo, newFakeCache(), URLServiceConfig{AllowedSources: []string{"web", "cli"}})
+	req := func() *domain.CreateURLRequest {
+		return &domain.CreateURLRequest{OriginalURL: "https://example.com"}
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"named source", domain.WithSource(context.Background(), " CLI "), "cli"},
+		{"api key caller", domain.WithCaller(context.Background(), "alice"), domain.SourceAPI},
+		{"anonymous", context.Background(), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.Create(tt.ctx, req())
+			if err != nil {
+				t.Fatalf("Create() returned error: %v", err)
+			}
+			if got := repo.urls[resp.ShortCode].Source; got != tt.want {
+				t.Errorf("source = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, err := svc.Create(domain.WithSource(context.Background(), "extension"), req())
+	if !errors.Is(err, domain.ErrSourceNotAllowed) {
+		t.Errorf("Create() from an unlisted source error = %v, want ErrSourceNotAllowed", err)
+	}
+}
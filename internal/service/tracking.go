@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/geo"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// TrackingService records non-redirect events such as tracking pixel loads
+// Events land in click_events with their own type, so they never inflate
+// click_count or url_redirects_total
+type TrackingService struct {
+	urls    *URLService
+	events  domain.ClickEventRepository
+	geo     *geo.Enricher
+	logger  *zap.Logger
+	metrics *metrics.Metrics
+}
+
+// NewTrackingService builds a TrackingService; enricher may be nil when
+// there is no way to place a visitor, events then have no country
+func NewTrackingService(urls *URLService, events domain.ClickEventRepository, enricher *geo.Enricher, logger *zap.Logger, m *metrics.Metrics) *TrackingService {
+	return &TrackingService{
+		urls:    urls,
+		events:  events,
+		geo:     enricher,
+		logger:  logger,
+		metrics: m,
+	}
+}
+
+// LinkContext is URLService.LinkContext
+func (s *TrackingService) LinkContext(ctx context.Context, prefix string) context.Context {
+	return s.urls.LinkContext(ctx, prefix)
+}
+
+// RecordOpen stores an "open" event for shortCode
+// Only links that would currently resolve are tracked, so random codes can't
+// fill the table. Errors are returned for logging; callers serve the pixel anyway.
+// The country comes from event.IPAddress, or the Accept-Language of the
+// visitor in ctx (domain.WithVisitor).
+func (s *TrackingService) RecordOpen(ctx context.Context, shortCode string, event domain.ClickEvent) error {
+	url, err := s.urls.GetURL(ctx, shortCode)
+	if err != nil {
+		return err
+	}
+
+	event.ShortCode = url.ShortURL
+	event.Prefix = url.Prefix
+	event.Type = domain.ClickEventOpen
+	if s.geo != nil {
+		s.geo.Enrich(&event, domain.VisitorFrom(ctx).AcceptLanguage)
+	}
+	if err := s.events.RecordClickEvent(ctx, &event); err != nil {
+		return err
+	}
+
+	s.metrics.TrackingEventsTotal.WithLabelValues(domain.ClickEventOpen).Inc()
+	return nil
+}
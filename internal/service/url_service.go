@@ -2,40 +2,263 @@ package service
 
 import (
 	"context"
+	"errors"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/subhammahanty235/url-shortener/internal/domain"
 	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
 	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
 	"go.uber.org/zap"
 )
 
 type URLService struct {
 	urlRepo     domain.URLRepository
 	cacheRepo   domain.CacheRepository
-	keyGen      *keygen.SnowFlakeGenerator
+	keyGen      keygen.Generator
+	events      domain.EventPublisher
+	clicks      domain.ClickCounter
 	logger      *zap.Logger
 	metrics     *metrics.Metrics
 	baseURL     string
-	defaultTTL  time.Duration
 	maxTTL      time.Duration
-	cacheTTL    time.Duration
 	allowCustom bool
+
+	// settings holds the options a config reload may change, see Settings
+	settings atomic.Pointer[Settings]
+
+	cacheWritePolicy CacheWritePolicy
+
+	allowPermanent bool
+	maxURLLength   int
+	minCodeLength  int
+	maxCodeLength  int
+
+	allowedDomains domainList
+	blockedDomains domainList
+	allowedSchemes map[string]struct{}
+
+	reservationTTL    time.Duration
+	maxReservationTTL time.Duration
+
+	deleteGracePeriod time.Duration
+
+	// aliasClaimTTL is 0 when custom aliases go straight to the database
+	aliasClaimTTL time.Duration
+
+	aliasCheckLimiter   domain.ClickLimiter
+	aliasCheckRateLimit int
+
+	maxLinksPerUser int
+	userLinkQuotas  map[string]int
+
+	caseInsensitiveCodes bool
+
+	// shortCodes makes codes of requested lengths keyGen can't produce,
+	// e.g. shorter than a snowflake ID encodes
+	shortCodes keygen.Generator
+
+	// trailingChars are cut off requested codes, empty when lenient parsing is off
+	trailingChars string
+
+	// signer is nil when no signing key is configured
+	signer *keygen.Signer
+
+	// destinations is the compact redirect cache, nil when disabled or when
+	// the cache backend doesn't implement it
+	destinations domain.DestinationCache
+
+	// clickLimiter is nil when redirect rate limiting is disabled
+	clickLimiter domain.ClickLimiter
+
+	// metadata is nil when preview fetching is disabled
+	metadata domain.MetadataQueue
+
+	// auditLog is nil when mutations aren't audited
+	auditLog domain.AuditLog
+
+	// analytics is nil when redirects aren't forwarded anywhere
+	analytics domain.AnalyticsSink
+	// countries is nil when there is nothing to locate visitors with
+	countries domain.CountryResolver
+
+	queryPrecedence QueryPrecedence
+
+	// consistencyCheckRate is the fraction of cache hits compared with the
+	// database, 0 disables the check
+	consistencyCheckRate float64
+
+	// expiredBehavior answers redirects of expired links, redirecting to
+	// expiredRedirectURL in the redirect mode
+	expiredBehavior    ExpiredBehavior
+	expiredRedirectURL string
+
+	// allowedPrefixes are the path segments links may be created under
+	allowedPrefixes map[string]struct{}
+
+	// allowedSources are the surfaces a create may name
+	allowedSources map[string]struct{}
+
+	// oldCodeBehavior decides between aliasing and retiring regenerated codes
+	oldCodeBehavior OldCodeBehavior
+
+	// aliasScope decides whether codes are unique globally or per prefix
+	aliasScope AliasScope
+
+	// canonicalLink adds a rel="canonical" Link header to redirects
+	canonicalLink bool
+
+	// reservedCodes are top-level route segments no code may take
+	reservedMu    sync.RWMutex
+	reservedCodes map[string]struct{}
+
+	// Aggregate stats are expensive (full table scans), so one result is
+	// shared by all callers for statsCacheTTL
+	statsMu       sync.Mutex
+	statsCache    *domain.AggregateStats
+	statsCachedAt time.Time
+	statsCacheTTL time.Duration
 }
 
 type URLServiceConfig struct {
 	BaseURL     string
-	DefaultTTL  time.Duration
+	DefaultTTL  time.Duration // 0 makes links permanent unless expires_in is set
 	MaxTTL      time.Duration
 	AllowCustom bool
 	CacheTTL    time.Duration
+
+	// CacheWritePolicy decides when created links are cached, write-through
+	// if empty (see CacheWritePolicy for the tradeoffs)
+	CacheWritePolicy CacheWritePolicy
+
+	AllowPermanent bool
+	// MaxURLLength caps original_url in bytes, DefaultMaxURLLength if zero
+	MaxURLLength int
+	// Bounds for a caller-requested code_length
+	MinCodeLength int
+	MaxCodeLength int
+
+	AllowedDestinationDomains []string
+	BlockedDestinationDomains []string
+
+	// AllowedSchemes destinations may use, http and https if empty
+	// e.g. add mailto, tel and sms for business-card links
+	AllowedSchemes []string
+
+	// How long a reserved alias is held by default and at most
+	ReservationTTL    time.Duration
+	MaxReservationTTL time.Duration
+
+	// DeleteGracePeriod is how long a deleted link can be restored before
+	// the cleanup worker purges it, DefaultDeleteGracePeriod if zero
+	DeleteGracePeriod time.Duration
+
+	// AliasClaimTTL makes custom aliases and reservations claim the alias in
+	// the cache (SET NX) before the insert, 0 disables claiming. It only
+	// needs to outlive the insert it guards.
+	AliasClaimTTL time.Duration
+
+	// AliasCheckLimiter caps CheckAlias calls at AliasCheckRateLimit per
+	// client IP per minute; nil or 0 leaves them unlimited
+	AliasCheckLimiter   domain.ClickLimiter
+	AliasCheckRateLimit int
+
+	// MaxLinksPerUser caps each API key owner's live links, 0 is unlimited
+	// UserLinkQuotas overrides it per user (e.g. for bigger plans), where 0
+	// lifts the cap for that user
+	MaxLinksPerUser int
+	UserLinkQuotas  map[string]int
+
+	StatsCacheTTL time.Duration
+
+	// CaseInsensitiveCodes lowercases codes on store and lookup
+	// Pair it with a lowercase key generator so generated codes can't collide
+	CaseInsensitiveCodes bool
+
+	// TrailingChars are stripped from the end of a requested code before
+	// lookup, so "abc123." or "abc123)" left by auto-linking still resolve.
+	// Empty disables it; never include characters codes may end with.
+	TrailingChars string
+
+	// SigningKey enables signed links, nil or empty disables them
+	SigningKey []byte
+
+	// CompactRedirectCache makes redirects read and write a destination-only
+	// cache entry; the full URL is cached only when metadata is read
+	CompactRedirectCache bool
+
+	// ClickLimiter enforces redirect rate limits, nil disables them
+	ClickLimiter domain.ClickLimiter
+	// ClickRateLimit is the default number of redirects per link per
+	// ClickRateWindow (one minute if zero); links may override it and 0 leaves
+	// only links with their own limit rate limited
+	ClickRateLimit  int
+	ClickRateWindow time.Duration
+	// ClickRateLimitPerIP counts every visitor IP separately, so one client
+	// can't exhaust a link's budget for everybody else
+	ClickRateLimitPerIP bool
+
+	// MetadataQueue fetches link previews for fetch_metadata requests, nil
+	// makes the flag a no-op
+	MetadataQueue domain.MetadataQueue
+
+	// AuditLog records every mutating operation, nil disables auditing
+	AuditLog domain.AuditLog
+
+	// AnalyticsSink receives every redirect for an external analytics
+	// pipeline, nil disables forwarding
+	AnalyticsSink domain.AnalyticsSink
+
+	// Countries locates visitors of links with country destinations, nil
+	// sends every visitor to the default destination
+	Countries domain.CountryResolver
+
+	// QueryPrecedence settles parameters present both in a passthrough
+	// link's destination and in the click, stored if empty
+	QueryPrecedence QueryPrecedence
+
+	// ConsistencyCheckRate compares this fraction of cache hits with the
+	// database in the background and evicts entries that diverged, 0 (the
+	// default) disables it. Keep it low, each check is a database read.
+	ConsistencyCheckRate float64
+
+	// ExpiredBehavior is how redirects of expired links are answered, gone
+	// (410) if empty; ExpiredRedirectURL is the fallback page of the
+	// redirect mode. The management API reports expiry either way.
+	ExpiredBehavior    ExpiredBehavior
+	ExpiredRedirectURL string
+
+	// AllowedPrefixes are the segments a link may require before its code
+	// ("/news/abc123"); empty refuses every prefix. The router must mount
+	// the same list, see URLHandler.RedirectPrefixed.
+	AllowedPrefixes []string
+
+	// AllowedSources are the surfaces a create may be attributed to,
+	// DefaultSources if empty
+	AllowedSources []string
+
+	// OldCodeBehavior is what a link's old code does after RegenerateCode,
+	// OldCodeRetire if empty
+	OldCodeBehavior OldCodeBehavior
+
+	// AliasScope is what a code must be unique among, AliasScopeGlobal if
+	// empty. Switching to prefix scope leaves existing links as they are.
+	AliasScope AliasScope
+
+	// CanonicalLinkHeader names the destination as the canonical URL on
+	// every redirect, see CanonicalLinkHeader
+	CanonicalLinkHeader bool
 }
 
 func NewURLService(
 	urlRepo domain.URLRepository,
 	cacheRepo domain.CacheRepository,
-	keyGen *keygen.SnowFlakeGenerator,
+	keyGen keygen.Generator,
+	events domain.EventPublisher,
+	clicks domain.ClickCounter,
 	logger *zap.Logger,
 	m *metrics.Metrics,
 	cfg URLServiceConfig,
@@ -43,129 +266,1079 @@ func NewURLService(
 	if cfg.CacheTTL == 0 {
 		cfg.CacheTTL = 24 * time.Hour
 	}
+	if events == nil {
+		events = noopPublisher{}
+	}
+	if clicks == nil {
+		clicks = noopClickCounter{}
+	}
+	if cfg.MaxURLLength <= 0 {
+		cfg.MaxURLLength = DefaultMaxURLLength
+	}
+	if cfg.MinCodeLength <= 0 {
+		cfg.MinCodeLength = 6
+	}
+	if cfg.MaxCodeLength <= 0 {
+		cfg.MaxCodeLength = 10
+	}
+	if cfg.StatsCacheTTL == 0 {
+		cfg.StatsCacheTTL = 30 * time.Second
+	}
+	if cfg.ReservationTTL <= 0 {
+		cfg.ReservationTTL = 24 * time.Hour
+	}
+	if cfg.MaxReservationTTL < cfg.ReservationTTL {
+		cfg.MaxReservationTTL = cfg.ReservationTTL
+	}
+	if cfg.DeleteGracePeriod <= 0 {
+		cfg.DeleteGracePeriod = DefaultDeleteGracePeriod
+	}
+	if cfg.ExpiredBehavior == "" || (cfg.ExpiredBehavior == ExpiredRedirect && cfg.ExpiredRedirectURL == "") {
+		cfg.ExpiredBehavior = ExpiredGone
+	}
+	if cfg.OldCodeBehavior == "" {
+		cfg.OldCodeBehavior = OldCodeRetire
+	}
+	if cfg.AliasScope == "" {
+		cfg.AliasScope = AliasScopeGlobal
+	}
+	allowedSchemes := newSchemeSet(cfg.AllowedSchemes)
+	for scheme := range allowedSchemes {
+		if _, dangerous := dangerousSchemes[scheme]; dangerous {
+			logger.Warn("DANGEROUS destination scheme enabled: links can run code in visitors' browsers or read local files, only enable this for fully trusted creators",
+				zap.String("scheme", scheme))
+		}
+	}
+	allowedPrefixes := make(map[string]struct{}, len(cfg.AllowedPrefixes))
+	for _, prefix := range cfg.AllowedPrefixes {
+		allowedPrefixes[prefix] = struct{}{}
+	}
+	reservedCodes := make(map[string]struct{}, len(DefaultReservedCodes)+len(cfg.AllowedPrefixes))
+	for _, code := range DefaultReservedCodes {
+		reservedCodes[code] = struct{}{}
+	}
+	for _, prefix := range cfg.AllowedPrefixes {
+		reservedCodes[strings.ToLower(prefix)] = struct{}{}
+	}
+	var signer *keygen.Signer
+	if len(cfg.SigningKey) > 0 {
+		signer = keygen.NewSigner(cfg.SigningKey)
+	}
+	var destinations domain.DestinationCache
+	if cfg.CompactRedirectCache {
+		destinations, _ = cacheRepo.(domain.DestinationCache)
+	}
 
-	return &URLService{
+	s := &URLService{
 		urlRepo:     urlRepo,
 		cacheRepo:   cacheRepo,
 		keyGen:      keyGen,
+		events:      events,
+		clicks:      clicks,
 		logger:      logger,
 		metrics:     m,
 		baseURL:     strings.TrimSuffix(cfg.BaseURL, "/"),
-		defaultTTL:  cfg.DefaultTTL,
 		maxTTL:      cfg.MaxTTL,
 		allowCustom: cfg.AllowCustom,
-		cacheTTL:    cfg.CacheTTL,
+
+		cacheWritePolicy: cfg.CacheWritePolicy,
+
+		allowPermanent: cfg.AllowPermanent,
+		maxURLLength:   cfg.MaxURLLength,
+		minCodeLength:  cfg.MinCodeLength,
+		maxCodeLength:  cfg.MaxCodeLength,
+		allowedDomains: newDomainList(cfg.AllowedDestinationDomains),
+		blockedDomains: newDomainList(cfg.BlockedDestinationDomains),
+		allowedSchemes: allowedSchemes,
+		statsCacheTTL:  cfg.StatsCacheTTL,
+
+		reservationTTL:    cfg.ReservationTTL,
+		maxReservationTTL: cfg.MaxReservationTTL,
+
+		deleteGracePeriod: cfg.DeleteGracePeriod,
+
+		aliasClaimTTL: cfg.AliasClaimTTL,
+
+		aliasCheckLimiter:   cfg.AliasCheckLimiter,
+		aliasCheckRateLimit: cfg.AliasCheckRateLimit,
+
+		maxLinksPerUser: cfg.MaxLinksPerUser,
+		userLinkQuotas:  cfg.UserLinkQuotas,
+
+		caseInsensitiveCodes: cfg.CaseInsensitiveCodes,
+		shortCodes:           newShortCodeGenerator(cfg),
+		trailingChars:        cfg.TrailingChars,
+		signer:               signer,
+		destinations:         destinations,
+
+		clickLimiter: cfg.ClickLimiter,
+
+		metadata: cfg.MetadataQueue,
+		auditLog: cfg.AuditLog,
+
+		analytics: cfg.AnalyticsSink,
+		countries: cfg.Countries,
+
+		queryPrecedence: cfg.QueryPrecedence,
+
+		consistencyCheckRate: cfg.ConsistencyCheckRate,
+
+		expiredBehavior:    cfg.ExpiredBehavior,
+		expiredRedirectURL: cfg.ExpiredRedirectURL,
+
+		oldCodeBehavior: cfg.OldCodeBehavior,
+		aliasScope:      cfg.AliasScope,
+
+		canonicalLink: cfg.CanonicalLinkHeader,
+
+		allowedPrefixes: allowedPrefixes,
+		allowedSources:  newSourceSet(cfg.AllowedSources),
+		reservedCodes:   reservedCodes,
 	}
+	settings := Settings{
+		DefaultTTL:          cfg.DefaultTTL,
+		CacheTTL:            cfg.CacheTTL,
+		ClickRateLimit:      cfg.ClickRateLimit,
+		ClickRateWindow:     cfg.ClickRateWindow,
+		ClickRateLimitPerIP: cfg.ClickRateLimitPerIP,
+	}.withDefaults()
+	s.settings.Store(&settings)
+	return s
 }
 
-func (s *URLService) Create(ctx context.Context, req *domain.CreateURLRequest) (*domain.CreateURLResponse, error) {
+// CanonicalLinkHeader reports whether redirects carry a Link header naming
+// the destination as canonical
+// Learning: search engines follow the 301 anyway; the header makes the
+// intent explicit, so link equity goes to the destination and not the code.
+func (s *URLService) CanonicalLinkHeader() bool {
+	return s.canonicalLink
+}
 
-	var shortCode string
-	var err error
-	isCustomAlias := false
+// TrimTrailing strips copy-paste artifacts (e.g. the "." ending a sentence)
+// from a requested code when lenient parsing is on
+func (s *URLService) TrimTrailing(shortCode string) string {
+	if s.trailingChars == "" {
+		return shortCode
+	}
+	return strings.TrimRight(shortCode, s.trailingChars)
+}
 
-	if req.CustomAlias != nil && *req.CustomAlias != "" {
-		shortCode = *req.CustomAlias
-		isCustomAlias = true
-		// TODO: check if the custom short code already exists
-	} else {
-		shortCode, err = s.keyGen.Generate()
-		if err != nil {
-			s.logger.Error("failed to generate short code", zap.Error(err))
-			return nil, err
+// normalizeCode applies the configured case policy to a short code
+func (s *URLService) normalizeCode(shortCode string) string {
+	if s.caseInsensitiveCodes {
+		return strings.ToLower(shortCode)
+	}
+	return shortCode
+}
 
-		}
+func (s *URLService) Create(ctx context.Context, req *domain.CreateURLRequest) (*domain.CreateURLResponse, error) {
+	// Stored in normalized form (punycode hosts), so redirects and lookups
+	// by destination see one spelling of each URL
+	originalURL, err := s.validateDestination(req.OriginalURL)
+	if err != nil {
+		return nil, err
 	}
 
-	var expiresAt *time.Time
-	if req.ExpiresIn != nil && *req.ExpiresIn > 0 {
-		ttl := time.Duration(*req.ExpiresIn) * time.Second
-		if s.maxTTL > 0 && ttl > s.maxTTL {
-			ttl = s.maxTTL
-		}
-		exp := time.Now().Add(ttl)
-		expiresAt = &exp
-	} else if s.defaultTTL > 0 {
-		exp := time.Now().Add(s.defaultTTL)
-		expiresAt = &exp
+	expiresAt, err := s.createExpiry(req)
+	if err != nil {
+		return nil, err
 	}
 
 	urlEntry := &domain.URL{
-		ShortURL:    shortCode,
-		OriginalURL: req.OriginalURL,
+		OriginalURL: originalURL,
 		ExpiresAt:   expiresAt,
 		IsActive:    true,
+		Visibility:  domain.VisibilityPublic,
 	}
 
-	if err := s.urlRepo.Create(ctx, urlEntry); err != nil {
-		s.logger.Error("failed to create url entry", zap.Error(err))
+	// Links belong to the API key owner that created them
+	caller, authenticated := domain.CallerFrom(ctx)
+	if authenticated {
+		urlEntry.UserID = &caller
+		if err := s.checkQuota(ctx, caller); err != nil {
+			return nil, err
+		}
+	}
+	if req.Visibility == domain.VisibilityPrivate {
+		if !authenticated {
+			// A private link without an owner could never be resolved
+			return nil, domain.ErrUnauthorized
+		}
+		urlEntry.Visibility = domain.VisibilityPrivate
+	}
+	if req.Signed {
+		if s.signer == nil {
+			return nil, domain.ErrSigningDisabled
+		}
+		urlEntry.Signed = true
+	}
+	if urlEntry.Source, err = s.createSource(ctx); err != nil {
 		return nil, err
 	}
+	urlEntry.ClickRateLimit = req.ClickRateLimit
+	urlEntry.PassthroughQuery = req.PassthroughQuery
+	if urlEntry.PlatformDestinations, err = s.validateDestinationMap(req.PlatformDestinations); err != nil {
+		return nil, err
+	}
+	if urlEntry.CountryDestinations, err = s.validateDestinationMap(req.CountryDestinations); err != nil {
+		return nil, err
+	}
+	if req.FallbackURL != "" {
+		if urlEntry.FallbackURL, err = s.validateDestination(req.FallbackURL); err != nil {
+			return nil, err
+		}
+	}
+	if req.Prefix != "" {
+		if _, ok := s.allowedPrefixes[req.Prefix]; !ok {
+			return nil, domain.ErrPrefixNotAllowed
+		}
+		urlEntry.Prefix = req.Prefix
+	}
 
-	if err := s.cacheRepo.Set(ctx, urlEntry, s.cacheTTL); err != nil {
-		s.logger.Error("failed to set url entry in cache", zap.Error(err))
+	isCustomAlias := false
+	if req.CustomAlias != nil && *req.CustomAlias != "" {
+		urlEntry.ShortURL = s.normalizeCode(*req.CustomAlias)
+		if s.IsReservedCode(urlEntry.ShortURL) {
+			return nil, domain.ErrShortCodeReserved
+		}
+		isCustomAlias = true
+		err = s.createWithAlias(ctx, urlEntry)
+		if errors.Is(err, domain.ErrShortCodeExists) && req.ReturnExisting {
+			if existing := s.sameLink(domain.WithCodePrefix(ctx, urlEntry.Prefix), urlEntry); existing != nil {
+				return s.existingResponse(existing), nil
+			}
+		}
+	} else {
+		length := 0
+		if req.CodeLength != nil {
+			length = *req.CodeLength
+			if length < s.minCodeLength || length > s.maxCodeLength {
+				return nil, domain.ErrInvalidShortCode
+			}
+		}
+		err = s.createWithGeneratedCode(ctx, urlEntry, length)
+	}
+	if err != nil {
+		s.logger.Error("failed to create url entry", zap.Error(err))
 		return nil, err
 	}
+	shortCode := urlEntry.ShortURL
+
+	s.cacheOnCreate(ctx, urlEntry)
 
 	// Track business metrics
 	// Learning: These metrics answer "how is our product being used?"
-	s.metrics.URLsCreatedTotal.Inc()
+	codeType := "generated"
 	if isCustomAlias {
-		// Track custom alias usage separately
 		// Use case: Understand feature adoption - are users using custom aliases?
 		s.metrics.CustomAliasTotal.Inc()
+		codeType = "custom"
 	}
+	s.metrics.URLsCreatedTotal.WithLabelValues(codeType).Inc()
+	s.metrics.ShortCodeLength.WithLabelValues(codeType).Observe(float64(len(shortCode)))
+
+	s.logger.Info("URL created successfully", zap.String("short_code", shortCode), zap.String("original_url", originalURL))
+	s.audit(ctx, domain.AuditURLCreate, shortCode)
+
+	s.events.Publish(ctx, domain.LinkEvent{
+		Type:        domain.EventURLCreated,
+		ShortCode:   shortCode,
+		Prefix:      urlEntry.Prefix,
+		OriginalURL: originalURL,
+		OccurredAt:  urlEntry.CreatedAt,
+	})
 
-	s.logger.Info("URL created successfully", zap.String("short_code", shortCode), zap.String("original_url", req.OriginalURL))
+	// Fetched in the background, the preview shows up on the link later
+	if req.FetchMetadata && s.metadata != nil {
+		s.metadata.Enqueue(domain.LinkKey(urlEntry.Prefix, urlEntry.ShortURL), urlEntry.OriginalURL)
+	}
+
+	// The tag is never stored, the caller gets the only shareable form
+	if urlEntry.Signed {
+		shortCode = s.signer.Sign(shortCode)
+	}
 
 	return &domain.CreateURLResponse{
 		ShortCode:   shortCode,
-		ShortURL:    s.baseURL + "/" + shortCode,
-		OriginalURL: req.OriginalURL,
+		ShortURL:    s.shortURL(urlEntry.Prefix, shortCode),
+		OriginalURL: originalURL,
 		ExpiresAt:   expiresAt,
 		CreatedAt:   urlEntry.CreatedAt,
 	}, nil
 }
 
+// shortURL is the shareable address of a code, under its prefix if any
+func (s *URLService) shortURL(prefix, shortCode string) string {
+	if prefix != "" {
+		return s.baseURL + "/" + prefix + "/" + shortCode
+	}
+	return s.baseURL + "/" + shortCode
+}
+
+// PixelURL is the open-tracking pixel address for a short code
+// With prefix scope the pixel of a prefixed link names its prefix, see
+// LinkContext.
+func (s *URLService) PixelURL(prefix, shortCode string) string {
+	if prefix != "" && s.aliasScope == AliasScopePrefix {
+		return s.baseURL + "/p/" + shortCode + ".gif?prefix=" + prefix
+	}
+	return s.baseURL + "/p/" + shortCode + ".gif"
+}
+
+// createWithAlias inserts urlEntry under its custom alias, claiming the
+// alias if the caller reserved it (or a reservation of it has lapsed)
+// The claim is kept after a successful insert: until it lapses, anyone else
+// racing for the alias is turned away without a database round trip.
+func (s *URLService) createWithAlias(ctx context.Context, urlEntry *domain.URL) error {
+	// With prefix scope, links under other prefixes may hold the code
+	claimKey := urlEntry.ShortURL
+	if s.aliasScope == AliasScopePrefix {
+		claimKey = domain.LinkKey(urlEntry.Prefix, urlEntry.ShortURL)
+	}
+	claimed, err := s.claimAlias(ctx, claimKey)
+	if err != nil {
+		return err
+	}
+
+	err = s.urlRepo.Create(ctx, urlEntry)
+	// Reservations are unprefixed, with prefix scope they only ever hold
+	// the code of an unprefixed link
+	if errors.Is(err, domain.ErrShortCodeExists) && (s.aliasScope != AliasScopePrefix || urlEntry.Prefix == "") {
+		claimErr := s.urlRepo.ClaimReservation(ctx, urlEntry)
+		if !errors.Is(claimErr, domain.ErrURLNotFound) {
+			err = claimErr
+		}
+		// Otherwise taken by a live link or by someone else's reservation
+	}
+	if err != nil && claimed {
+		s.releaseAlias(ctx, claimKey)
+	}
+	return err
+}
+
+// claimAlias takes the cache-side claim on code before it is inserted
+// Use case: two requests for the same alias at once; the loser gets
+// ErrShortCodeExists straight from Redis. claimed is false when claiming is
+// off or the cache failed: the insert then goes ahead unguarded, which is
+// still correct, since the unique constraint has the final say.
+func (s *URLService) claimAlias(ctx context.Context, code string) (claimed bool, err error) {
+	if s.aliasClaimTTL <= 0 {
+		return false, nil
+	}
+	claimed, err = s.cacheRepo.ClaimAlias(ctx, code, s.aliasClaimTTL)
+	if err != nil {
+		s.logger.Warn("alias claim failed, relying on the database", zap.Error(err), zap.String("short_code", code))
+		return false, nil
+	}
+	if !claimed {
+		return false, domain.ErrShortCodeExists
+	}
+	return true, nil
+}
+
+func (s *URLService) releaseAlias(ctx context.Context, code string) {
+	if err := s.cacheRepo.ReleaseAlias(ctx, code); err != nil {
+		// It lapses on its own after aliasClaimTTL
+		s.logger.Warn("failed to release alias claim", zap.Error(err), zap.String("short_code", code))
+	}
+}
+
+// sameLink returns the live link holding want.ShortURL when it is
+// effectively the link being created: same normalized destination and
+// resolvable by the caller. Signed links never match, their tag can't be
+// handed out again.
+func (s *URLService) sameLink(ctx context.Context, want *domain.URL) *domain.URL {
+	existing, err := s.urlRepo.GetByShortCode(ctx, want.ShortURL)
+	if err != nil {
+		return nil
+	}
+	if existing.OriginalURL != want.OriginalURL || !existing.IsActive || existing.IsExpired() || existing.Signed {
+		return nil
+	}
+	if checkAccess(ctx, existing) != nil {
+		return nil
+	}
+	return existing
+}
+
+func (s *URLService) existingResponse(url *domain.URL) *domain.CreateURLResponse {
+	return &domain.CreateURLResponse{
+		ShortCode:   url.ShortURL,
+		ShortURL:    s.shortURL(url.Prefix, url.ShortURL),
+		OriginalURL: url.OriginalURL,
+		ExpiresAt:   url.ExpiresAt,
+		CreatedAt:   url.CreatedAt,
+		Existing:    true,
+	}
+}
+
+// ReserveAlias holds a custom alias for the caller until the destination is
+// ready; creating a link with that alias and the same API key claims it
+// Reservations need an owner, otherwise anyone could claim them
+func (s *URLService) ReserveAlias(ctx context.Context, req *domain.ReserveAliasRequest) (*domain.ReserveAliasResponse, error) {
+	caller, ok := domain.CallerFrom(ctx)
+	if !ok {
+		return nil, domain.ErrUnauthorized
+	}
+
+	ttl := s.reservationTTL
+	if req.ReserveFor != nil {
+		ttl = time.Duration(*req.ReserveFor) * time.Second
+		if ttl > s.maxReservationTTL {
+			ttl = s.maxReservationTTL
+		}
+	}
+	reservedUntil := time.Now().Add(ttl)
+
+	reservation := &domain.URL{
+		ShortURL:      s.normalizeCode(req.Alias),
+		UserID:        &caller,
+		ReservedUntil: &reservedUntil,
+	}
+	if s.IsReservedCode(reservation.ShortURL) {
+		return nil, domain.ErrShortCodeReserved
+	}
+	claimed, err := s.claimAlias(ctx, reservation.ShortURL)
+	if err != nil {
+		return nil, err
+	}
+	err = s.urlRepo.Reserve(ctx, reservation)
+	// Released either way: once reserved, the row is the guard, and the
+	// caller's own create of the alias must not trip over the claim
+	if claimed {
+		s.releaseAlias(ctx, reservation.ShortURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("alias reserved",
+		zap.String("short_code", reservation.ShortURL),
+		zap.Time("reserved_until", reservedUntil),
+	)
+	s.audit(ctx, domain.AuditAliasReserve, reservation.ShortURL)
+	return &domain.ReserveAliasResponse{
+		Alias:         reservation.ShortURL,
+		ShortURL:      s.baseURL + "/" + reservation.ShortURL,
+		ReservedUntil: reservedUntil,
+	}, nil
+}
+
+// maxGenerateAttempts bounds how many fresh codes are tried when a generated
+// code is already taken. Snowflake codes never collide; random codes rarely do.
+const maxGenerateAttempts = 5
+
+// createWithGeneratedCode inserts urlEntry under a freshly generated code,
+// retrying with a new code when the database reports a collision
+// length 0 leaves the code length to the generator
+func (s *URLService) createWithGeneratedCode(ctx context.Context, urlEntry *domain.URL, length int) error {
+	_, err := s.withGeneratedCode(ctx, length, func(code string) error {
+		urlEntry.ShortURL = code
+		return s.urlRepo.Create(ctx, urlEntry)
+	})
+	return err
+}
+
+// newShortCodeGenerator is the random generator behind requested lengths
+// the configured generator can't produce, in the same case as its codes
+func newShortCodeGenerator(cfg URLServiceConfig) keygen.Generator {
+	newRandom := keygen.NewRandomGenerator
+	if cfg.CaseInsensitiveCodes {
+		newRandom = keygen.NewLowercaseRandomGenerator
+	}
+	// Only fails for a non-positive length, and MinCodeLength has a
+	// positive default by now
+	gen, _ := newRandom(cfg.MinCodeLength)
+	return gen
+}
+
+// withGeneratedCode hands fresh codes to store until one doesn't collide
+// (store returns ErrShortCodeExists) and returns the code that was stored
+// A requested length the generator can't produce gets a random code.
+func (s *URLService) withGeneratedCode(ctx context.Context, length int, store func(code string) error) (string, error) {
+	var err error
+	for attempt := 1; attempt <= maxGenerateAttempts; attempt++ {
+		var code string
+		code, err = s.keyGen.Generate(ctx, length)
+		if errors.Is(err, keygen.ErrLengthUnavailable) {
+			// Within the configured bounds, but too short for this generator
+			code, err = s.shortCodes.Generate(ctx, length)
+		}
+		if err != nil {
+			s.logger.Error("failed to generate short code", zap.Error(err))
+			return "", err
+		}
+		if s.IsReservedCode(code) {
+			// Only short codes can spell a route; treated like a collision
+			err = domain.ErrShortCodeReserved
+			continue
+		}
+
+		err = store(code)
+		if !errors.Is(err, domain.ErrShortCodeExists) {
+			return code, err
+		}
+		s.logger.Warn("generated short code collided, retrying",
+			zap.String("short_code", code),
+			zap.Int("attempt", attempt),
+		)
+	}
+	return "", err
+}
+
+// GetURL returns a link's full record, enforcing expiry, status and access
 func (s *URLService) GetURL(ctx context.Context, shortCode string) (*domain.URL, error) {
+	return s.resolve(ctx, shortCode, false)
+}
+
+// resolve looks a code up through the full cache and then the DB
+// forRedirect counts the lookup as a redirect and, with the compact cache on,
+// caches only the destination for links that allow it
+func (s *URLService) resolve(ctx context.Context, shortCode string, forRedirect bool) (*domain.URL, error) {
+	shortCode, verified, err := s.verifySignature(shortCode)
+	if err != nil {
+		return nil, err
+	}
+	requested := shortCode
+	shortCode = s.normalizeCode(shortCode)
+
 	// query the cache first
 	url, err := s.cacheRepo.Get(ctx, shortCode)
-	if err != nil {
+	cacheFailed := err != nil
+	if cacheFailed {
 		s.logger.Warn("cache error", zap.Error(err), zap.String("short_code", shortCode))
 	}
+	if url != nil && !inScope(ctx, url) {
+		// Another prefix's link with the same code
+		url = nil
+	}
 
 	if url != nil {
 		// Cache hit!
 		s.logger.Debug("cache hit", zap.String("short_code", shortCode))
 
+		if url.Signed && !verified {
+			return nil, domain.ErrURLNotFound
+		}
+
+		if !url.IsActive {
+			return nil, domain.ErrURLDisabled
+		}
+
 		if url.IsExpired() {
 			_ = s.cacheRepo.Delete(ctx, shortCode)
 			// Track expired URL attempts (important user experience metric)
 			s.metrics.ExpiredURLsTotal.Inc()
-			return nil, domain.ErrURLExpired
+			s.publishExpired(domain.WithCodePrefix(ctx, url.Prefix), url.ShortURL)
+			return nil, domain.Expired(url)
+		}
+
+		if err := checkAccess(ctx, url); err != nil {
+			return nil, err
 		}
+		s.sampleConsistency(ctx, url)
 
-		// Track redirect for cache hit
-		// Learning: Most redirects should be cache hits for good performance
-		s.metrics.URLRedirectsTotal.Inc()
+		if forRedirect {
+			// Metadata was read before; later redirects can skip decoding it
+			s.cacheDestination(ctx, url)
+		}
 		return url, nil
 	}
 
 	// Cache miss - need to query database
 	s.logger.Debug("cache miss", zap.String("short_code", shortCode))
 	url, err = s.urlRepo.GetByShortCode(ctx, shortCode)
+	stored := shortCode
+	if errors.Is(err, domain.ErrURLNotFound) && requested != shortCode {
+		// Links created before case-insensitive mode was turned on may be
+		// stored mixed-case; they still resolve with their exact original casing
+		url, err = s.urlRepo.GetByShortCode(ctx, requested)
+		stored = requested
+	}
+	if errors.Is(err, domain.ErrURLExpired) {
+		s.publishExpired(ctx, stored)
+	}
+	if errors.Is(err, domain.ErrURLNotFound) && !verified {
+		// Maybe an alias: resolving the link's own code keeps the cache
+		// entry, the click count and the stats on the link
+		canonical, aliasErr := s.urlRepo.ResolveAlias(ctx, shortCode)
+		if aliasErr == nil && canonical != shortCode {
+			return s.resolve(ctx, canonical, forRedirect)
+		}
+		if errors.Is(aliasErr, domain.ErrCodeRetired) {
+			return nil, aliasErr
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	if url.Signed && !verified {
+		return nil, domain.ErrURLNotFound
+	}
 
 	// Try to cache for next time
-	if err := s.cacheRepo.Set(ctx, url, s.cacheTTL); err != nil {
-		s.logger.Warn("failed to cache URL", zap.Error(err))
+	// Private links are cached too, access is checked on every read. A
+	// cache that just failed a read (down, or too slow for the get timeout)
+	// isn't written to, the redirect would wait on it after all.
+	switch {
+	case cacheFailed:
+	case forRedirect && s.cacheDestination(ctx, url):
+		// The full record waits until someone asks for metadata
+	default:
+		if err := s.cacheRepo.Set(ctx, url, s.currentSettings().CacheTTL); err != nil {
+			s.logger.Warn("failed to cache URL", zap.Error(err))
+		}
+	}
+
+	if err := checkAccess(ctx, url); err != nil {
+		return nil, err
 	}
 
-	// Track redirect for cache miss
-	// Learning: Cache misses are slower (hit DB), but still count as redirects
+	return url, nil
+}
+
+// compactable reports whether url may live in the destination-only cache,
+// which has nothing to enforce visibility, signatures, status, a per-link
+// rate limit, query passthrough, a prefix or per-visitor routing with
+func compactable(url *domain.URL) bool {
+	return url.IsActive && !url.IsPrivate() && !url.Signed && url.ClickRateLimit == nil && !url.PassthroughQuery && url.Prefix == "" &&
+		len(url.PlatformDestinations) == 0 && len(url.CountryDestinations) == 0
+}
+
+// cacheDestination stores the compact redirect entry when enabled and allowed
+// and reports whether it did
+func (s *URLService) cacheDestination(ctx context.Context, url *domain.URL) bool {
+	if s.destinations == nil || !compactable(url) {
+		return false
+	}
+
+	dest := domain.Destination{OriginalURL: url.OriginalURL, ExpiresAt: url.ExpiresAt}
+	if err := s.destinations.SetDestination(ctx, url.ShortURL, dest, s.currentSettings().CacheTTL); err != nil {
+		s.logger.Warn("failed to cache destination", zap.Error(err), zap.String("short_code", url.ShortURL))
+		return false
+	}
+	return true
+}
+
+// warmCache caches a newly created link in the form redirects will read
+func (s *URLService) warmCache(ctx context.Context, url *domain.URL) error {
+	if s.cacheDestination(ctx, url) {
+		return nil
+	}
+	return s.cacheRepo.Set(ctx, url, s.currentSettings().CacheTTL)
+}
+
+// visitFast serves a redirect from the compact cache entry
+// Anything unusual (a miss, an error, a signed code, an expired entry) falls
+// back to resolve, which owns expiry events and error reporting
+func (s *URLService) visitFast(ctx context.Context, shortCode string) (*domain.URL, bool) {
+	if s.destinations == nil {
+		return nil, false
+	}
+	if _, _, signed := keygen.SplitSigned(shortCode); signed {
+		return nil, false
+	}
+
+	shortCode = s.normalizeCode(shortCode)
+	dest, err := s.destinations.GetDestination(ctx, shortCode)
+	if err != nil {
+		s.logger.Warn("destination cache error", zap.Error(err), zap.String("short_code", shortCode))
+		return nil, false
+	}
+	if dest == nil {
+		return nil, false
+	}
+
+	url := &domain.URL{
+		ShortURL:    shortCode,
+		OriginalURL: dest.OriginalURL,
+		ExpiresAt:   dest.ExpiresAt,
+		IsActive:    true,
+		Visibility:  domain.VisibilityPublic,
+	}
+	if url.IsExpired() {
+		return nil, false
+	}
+	s.sampleConsistency(ctx, url)
+	return url, true
+}
+
+// verifySignature strips and checks an HMAC tag ("code.tag")
+// It reports whether a valid tag was present; a bad tag looks exactly like an
+// unknown code so the response doesn't help anyone forging tags
+func (s *URLService) verifySignature(shortCode string) (string, bool, error) {
+	code, tag, hasTag := keygen.SplitSigned(shortCode)
+	if !hasTag {
+		return shortCode, false, nil
+	}
+	if s.signer == nil {
+		return "", false, domain.ErrURLNotFound
+	}
+
+	// Links are signed after case normalization, so verify the same form
+	code, ok := s.signer.Verify(s.normalizeCode(code) + keygen.SignatureSeparator + tag)
+	if !ok {
+		return "", false, domain.ErrURLNotFound
+	}
+	return code, true, nil
+}
+
+// checkAccess enforces link visibility for the caller attached to ctx
+// Anonymous callers get ErrUnauthorized (they may retry with a key), callers
+// with someone else's key get ErrForbidden
+func checkAccess(ctx context.Context, url *domain.URL) error {
+	if !url.IsPrivate() {
+		return nil
+	}
+
+	caller, ok := domain.CallerFrom(ctx)
+	if !ok {
+		return domain.ErrUnauthorized
+	}
+	if url.UserID == nil || *url.UserID != caller {
+		return domain.ErrForbidden
+	}
+	return nil
+}
+
+// checkOwner lets only a link's owner, or the admin, change it
+// Anonymous callers get ErrUnauthorized, anyone else (including every key
+// for an anonymously created link) ErrForbidden. Unknown codes are
+// ErrURLNotFound whoever asks.
+func (s *URLService) checkOwner(ctx context.Context, shortCode string) error {
+	owner, err := s.urlRepo.GetOwner(ctx, shortCode)
+	if err != nil {
+		return err
+	}
+	if domain.IsAdmin(ctx) {
+		return nil
+	}
+
+	caller, ok := domain.CallerFrom(ctx)
+	if !ok {
+		return domain.ErrUnauthorized
+	}
+	if owner == nil || *owner != caller {
+		return domain.ErrForbidden
+	}
+	return nil
+}
+
+// Visit resolves a short code for a redirect and counts the click
+// Click counting is best-effort: a failure is logged, the redirect still happens
+// With the compact cache on, the URL returned from a fast-path hit only has
+// ShortURL, OriginalURL and ExpiresAt filled in
+func (s *URLService) Visit(ctx context.Context, shortCode string) (*domain.URL, error) {
+	return s.VisitPrefixed(ctx, "", shortCode)
+}
+
+// visitLookup finds the link behind a redirect, compact cache first
+// Compact entries are only ever unprefixed links, see compactable
+func (s *URLService) visitLookup(ctx context.Context, prefix, shortCode string) (*domain.URL, error) {
+	if prefix == "" {
+		if url, ok := s.visitFast(ctx, shortCode); ok {
+			return url, nil
+		}
+	}
+	return s.resolve(ctx, shortCode, true)
+}
+
+// VisitPrefixed resolves a redirect requested as /prefix/code; prefix is
+// empty for /code. A link only resolves under its own prefix, anything else
+// is ErrURLNotFound so a wrong category looks like an unknown code.
+func (s *URLService) VisitPrefixed(ctx context.Context, prefix, shortCode string) (*domain.URL, error) {
+	url, err := s.visitLookup(s.LinkContext(ctx, prefix), prefix, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if url.Prefix != prefix {
+		return nil, domain.ErrURLNotFound
+	}
+
+	if err := s.checkClickRate(ctx, url); err != nil {
+		return nil, err
+	}
+	// Track the redirect only once it's allowed, so throttled bots don't
+	// inflate redirect or click counts
+	// Learning: Most redirects should be cache hits for good performance
 	s.metrics.URLRedirectsTotal.Inc()
 
+	if err := s.clicks.Incr(ctx, domain.LinkKey(url.Prefix, url.ShortURL)); err != nil {
+		s.logger.Warn("failed to count click", zap.Error(err), zap.String("short_code", url.ShortURL))
+	}
+	if s.analytics != nil {
+		visitor := domain.VisitorFrom(ctx)
+		s.analytics.Track(ctx, domain.AnalyticsEvent{
+			Event:       domain.AnalyticsEventRedirect,
+			ShortCode:   url.ShortURL,
+			Prefix:      url.Prefix,
+			Destination: url.OriginalURL,
+			IPAddress:   domain.ClientIPFrom(ctx),
+			UserAgent:   visitor.UserAgent,
+			Referrer:    visitor.Referrer,
+			Timestamp:   time.Now().UTC(),
+		})
+	}
 	return url, nil
 }
+
+// VisitorCountry is the country of the visitor in ctx for routing link, ""
+// when the link has no country destinations or the visitor can't be placed
+// The lookup is skipped for links that wouldn't use the answer.
+func (s *URLService) VisitorCountry(ctx context.Context, link *domain.URL) string {
+	if s.countries == nil || len(link.CountryDestinations) == 0 {
+		return ""
+	}
+	country, _ := s.countries.Resolve(domain.ClientIPFrom(ctx), domain.VisitorFrom(ctx).AcceptLanguage)
+	return country
+}
+
+// checkQuota refuses a new link once userID holds their quota of live links
+// Two concurrent creates can both pass the count, so a user may briefly end
+// up one or two links over; that's fine for abuse prevention and avoids
+// serializing every create of a user on a lock
+func (s *URLService) checkQuota(ctx context.Context, userID string) error {
+	quota, ok := s.userLinkQuotas[userID]
+	if !ok {
+		quota = s.maxLinksPerUser
+	}
+	if quota <= 0 {
+		return nil
+	}
+
+	count, err := s.urlRepo.CountActiveByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if count >= int64(quota) {
+		return domain.ErrQuotaExceeded
+	}
+	return nil
+}
+
+// checkClickRate applies the link's own redirect limit, or the server-wide
+// one, and fails open: a limiter outage must never take redirects down
+func (s *URLService) checkClickRate(ctx context.Context, url *domain.URL) error {
+	if s.clickLimiter == nil {
+		return nil
+	}
+
+	settings := s.currentSettings()
+	limit, scope := settings.ClickRateLimit, "global"
+	if url.ClickRateLimit != nil {
+		limit, scope = *url.ClickRateLimit, "link"
+	}
+	if limit <= 0 {
+		return nil
+	}
+
+	key := "click:" + domain.LinkKey(url.Prefix, url.ShortURL)
+	if settings.ClickRateLimitPerIP {
+		if ip := domain.ClientIPFrom(ctx); ip != "" {
+			key += ":" + ip
+		}
+	}
+
+	allowed, err := s.clickLimiter.Allow(ctx, key, int64(limit), settings.ClickRateWindow)
+	if err != nil {
+		s.logger.Warn("click rate limiter unavailable", zap.Error(err), zap.String("short_code", url.ShortURL))
+		return nil
+	}
+	if !allowed {
+		s.metrics.RedirectsRateLimitedTotal.WithLabelValues(scope).Inc()
+		return domain.ErrRateLimitExceeded
+	}
+	return nil
+}
+
+// SetActive pauses or resumes a link without deleting it
+// The cache entry is dropped so the change takes effect on the next redirect
+// instead of after the cache TTL. Only the link's owner or the admin may.
+func (s *URLService) SetActive(ctx context.Context, shortCode string, active bool) error {
+	shortCode = s.normalizeCode(shortCode)
+	if err := s.checkOwner(ctx, shortCode); err != nil {
+		return err
+	}
+	if err := s.urlRepo.SetActive(ctx, shortCode, active); err != nil {
+		return err
+	}
+
+	if err := s.cacheRepo.Delete(ctx, shortCode); err != nil {
+		s.logger.Warn("failed to invalidate cache after status change",
+			zap.Error(err),
+			zap.String("short_code", shortCode),
+		)
+	}
+
+	s.logger.Info("URL status changed", zap.String("short_code", shortCode), zap.Bool("active", active))
+	s.audit(ctx, statusAction(active), shortCode)
+	return nil
+}
+
+func statusAction(active bool) domain.AuditAction {
+	if active {
+		return domain.AuditURLEnable
+	}
+	return domain.AuditURLDisable
+}
+
+// audit records a successful mutation of shortCodes
+// Best-effort like the cache: the change is already committed, so a failing
+// sink is logged loudly rather than turned into an error for the caller
+func (s *URLService) audit(ctx context.Context, action domain.AuditAction, shortCodes ...string) {
+	if s.auditLog == nil {
+		return
+	}
+	entry := domain.NewAuditEntry(ctx, action, shortCodes)
+	if err := s.auditLog.RecordAudit(ctx, entry); err != nil {
+		s.logger.Error("failed to write audit record",
+			zap.Error(err),
+			zap.String("action", string(action)),
+			zap.Strings("short_codes", shortCodes),
+			zap.String("actor", entry.Actor),
+		)
+	}
+}
+
+// ListURLs returns one page of links, newest first
+// A caller lists only their own links, the admin lists every link; an
+// anonymous caller gets ErrUnauthorized so the codes can't be enumerated.
+func (s *URLService) ListURLs(ctx context.Context, page pagination.Request) (pagination.Page[domain.URL], error) {
+	if _, ok := domain.CallerFrom(ctx); !ok && !domain.IsAdmin(ctx) {
+		return pagination.Page[domain.URL]{}, domain.ErrUnauthorized
+	}
+	urls, err := s.urlRepo.List(ctx, domain.ListScopeFor(ctx), page)
+	if err != nil {
+		return pagination.Page[domain.URL]{}, err
+	}
+	return pagination.NewPage(page, urls, domain.CursorOf), nil
+}
+
+// ListByDestination returns one page of the live links pointing at
+// originalURL, which is normalized the way Create stores it. It is scoped
+// as in ListURLs, so it can't reveal who else shortened a destination.
+func (s *URLService) ListByDestination(ctx context.Context, originalURL string, page pagination.Request) (pagination.Page[domain.URL], error) {
+	if _, ok := domain.CallerFrom(ctx); !ok && !domain.IsAdmin(ctx) {
+		return pagination.Page[domain.URL]{}, domain.ErrUnauthorized
+	}
+	normalized, _, err := normalizeDestination(originalURL)
+	if err != nil {
+		return pagination.Page[domain.URL]{}, err
+	}
+	urls, err := s.urlRepo.ListByDestination(ctx, normalized, domain.ListScopeFor(ctx), page)
+	if err != nil {
+		return pagination.Page[domain.URL]{}, err
+	}
+	return pagination.NewPage(page, urls, domain.CursorOf), nil
+}
+
+// SetActiveMany pauses or resumes many links, then evicts them from the cache
+// in one round trip. Unknown codes, and links the caller may not change (see
+// SetActive), are reported rather than failing the batch; an anonymous
+// caller owns nothing, so gets ErrUnauthorized outright.
+func (s *URLService) SetActiveMany(ctx context.Context, shortCodes []string, active bool) (*domain.BulkStatusResponse, error) {
+	if _, ok := domain.CallerFrom(ctx); !ok && !domain.IsAdmin(ctx) {
+		return nil, domain.ErrUnauthorized
+	}
+	resp := &domain.BulkStatusResponse{
+		Updated:   make([]string, 0, len(shortCodes)),
+		NotFound:  []string{},
+		Forbidden: []string{},
+		IsActive:  active,
+	}
+
+	for _, code := range shortCodes {
+		code = s.normalizeCode(code)
+		err := s.checkOwner(ctx, code)
+		if err == nil {
+			err = s.urlRepo.SetActive(ctx, code, active)
+		}
+		switch {
+		case errors.Is(err, domain.ErrURLNotFound):
+			resp.NotFound = append(resp.NotFound, code)
+		case errors.Is(err, domain.ErrForbidden):
+			resp.Forbidden = append(resp.Forbidden, code)
+		case err != nil:
+			// Links updated so far still need evicting
+			s.evictMany(ctx, resp.Updated)
+			return nil, err
+		default:
+			resp.Updated = append(resp.Updated, code)
+		}
+	}
+
+	s.evictMany(ctx, resp.Updated)
+	s.logger.Info("bulk URL status change", zap.Int("updated", len(resp.Updated)), zap.Bool("active", active))
+	if len(resp.Updated) > 0 {
+		s.audit(ctx, statusAction(active), resp.Updated...)
+	}
+	return resp, nil
+}
+
+// evictMany drops cache entries after a bulk change
+// Like SetActive, a failed eviction is logged, not returned: the DB change
+// already happened and stale entries still age out with the cache TTL
+func (s *URLService) evictMany(ctx context.Context, shortCodes []string) {
+	if err := s.cacheRepo.DeleteMany(ctx, shortCodes); err != nil {
+		s.logger.Warn("failed to invalidate cache after bulk change", zap.Error(err))
+	}
+}
+
+// topURLsLimit is how many links the stats endpoint ranks
+const topURLsLimit = 10
+
+// GetStats returns service-wide totals, served from an in-process cache
+// Learning: The mutex is held across the DB call on purpose - concurrent
+// requests after expiry wait for one refresh instead of all hitting Postgres
+func (s *URLService) GetStats(ctx context.Context) (*domain.AggregateStats, error) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	if s.statsCache != nil && time.Since(s.statsCachedAt) < s.statsCacheTTL {
+		return s.statsCache, nil
+	}
+
+	stats, err := s.urlRepo.GetAggregateStats(ctx, topURLsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	s.statsCache = stats
+	s.statsCachedAt = time.Now()
+	return stats, nil
+}
+
+// publishExpired announces the expiry of the link shortCode names in ctx
+// Every visit of an expired link, from the cache or the database, ends up
+// here; the claim in the database makes it one event per expiry.
+func (s *URLService) publishExpired(ctx context.Context, shortCode string) {
+	if _, off := s.events.(noopPublisher); off {
+		return
+	}
+	url, err := s.urlRepo.ClaimExpiredEvent(ctx, shortCode, time.Now())
+	if err != nil {
+		s.logger.Warn("failed to claim expired event", zap.Error(err), zap.String("short_code", shortCode))
+		return
+	}
+	if url == nil {
+		return
+	}
+	s.events.Publish(ctx, domain.LinkEvent{
+		Type:        domain.EventURLExpired,
+		ShortCode:   url.ShortURL,
+		Prefix:      url.Prefix,
+		OriginalURL: url.OriginalURL,
+		ExpiresAt:   url.ExpiresAt,
+		OccurredAt:  time.Now().UTC(),
+	})
+}
+
+// noopPublisher is used when no EventPublisher is wired in
+type noopPublisher struct{}
+
+func (noopPublisher) Publish(ctx context.Context, event domain.LinkEvent) {}
+
+// noopClickCounter is used when no ClickCounter is wired in
+type noopClickCounter struct{}
+
+func (noopClickCounter) Incr(ctx context.Context, shortCode string) error { return nil }
@@ -0,0 +1,1054 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/keygen"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/metrics"
+	"github.com/subhammahanty235/url-shortener/internal/pkg/pagination"
+	"go.uber.org/zap"
+)
+
+var errRedisDown = errors.New("dial tcp 127.0.0.1:6379: connect: connection refused")
+
+// fakeURLRepo is a map-backed URLRepository for service tests
+type fakeURLRepo struct {
+	mu   sync.Mutex
+	urls map[string]*domain.URL
+}
+
+func newFakeURLRepo() *fakeURLRepo {
+	return &fakeURLRepo{urls: make(map[string]*domain.URL)}
+}
+
+func (r *fakeURLRepo) Create(ctx context.Context, url *domain.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.urls[url.ShortURL]; ok {
+		return domain.ErrShortCodeExists
+	}
+	url.ID = int64(len(r.urls) + 1)
+	url.CreatedAt = time.Now()
+	url.UpdatedAt = url.CreatedAt
+	stored := *url
+	r.urls[url.ShortURL] = &stored
+	return nil
+}
+
+func (r *fakeURLRepo) GetByShortCode(ctx context.Context, shortCode string) (*domain.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	url, ok := r.urls[shortCode]
+	if !ok {
+		return nil, domain.ErrURLNotFound
+	}
+	found := *url
+	if !found.IsActive {
+		return nil, domain.ErrURLDisabled
+	}
+	return &found, nil
+}
+
+func (r *fakeURLRepo) SetActive(ctx context.Context, shortCode string, active bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	url, ok := r.urls[shortCode]
+	if !ok {
+		return domain.ErrURLNotFound
+	}
+	url.IsActive = active
+	return nil
+}
+
+func (r *fakeURLRepo) GetAggregateStats(ctx context.Context, topN int) (*domain.AggregateStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &domain.AggregateStats{TotalURLs: int64(len(r.urls)), GeneratedAt: time.Now()}, nil
+}
+
+func (r *fakeURLRepo) List(ctx context.Context, scope domain.ListScope, page pagination.Request) ([]domain.URL, error) {
+	return nil, nil
+}
+
+func (r *fakeURLRepo) ListByDestination(ctx context.Context, originalURL string, scope domain.ListScope, page pagination.Request) ([]domain.URL, error) {
+	return nil, nil
+}
+
+func (r *fakeURLRepo) Reserve(ctx context.Context, url *domain.URL) error {
+	return r.Create(ctx, url)
+}
+
+func (r *fakeURLRepo) ClaimReservation(ctx context.Context, url *domain.URL) error {
+	return domain.ErrURLNotFound
+}
+
+func (r *fakeURLRepo) DeleteExpiredReservations(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeURLRepo) MarkDeleted(ctx context.Context, shortCode string, purgeAfter time.Time) (time.Time, error) {
+	return time.Time{}, domain.ErrURLNotFound
+}
+
+func (r *fakeURLRepo) RestoreDeleted(ctx context.Context, shortCode string, now time.Time) error {
+	return domain.ErrURLNotFound
+}
+
+func (r *fakeURLRepo) PurgeDeleted(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeURLRepo) CountActiveByUser(ctx context.Context, userID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, url := range r.urls {
+		if url.IsActive && url.UserID != nil && *url.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeURLRepo) SetMetadata(ctx context.Context, shortCode string, meta domain.LinkMetadata) error {
+	return nil
+}
+
+func (r *fakeURLRepo) GetExpiry(ctx context.Context, shortCode string) (*time.Time, error) {
+	return nil, domain.ErrURLNotFound
+}
+
+func (r *fakeURLRepo) GetOwner(ctx context.Context, shortCode string) (*string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	url, ok := r.urls[shortCode]
+	if !ok {
+		return nil, domain.ErrURLNotFound
+	}
+	return url.UserID, nil
+}
+
+func (r *fakeURLRepo) IsShortCodeTaken(ctx context.Context, shortCode string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.urls[shortCode]
+	return ok, nil
+}
+
+func (r *fakeURLRepo) SetExpiry(ctx context.Context, shortCode string, expiresAt *time.Time) error {
+	return domain.ErrURLNotFound
+}
+
+func (r *fakeURLRepo) UpdateDestination(ctx context.Context, entry *domain.URLHistoryEntry) error {
+	return domain.ErrURLNotFound
+}
+
+func (r *fakeURLRepo) ListHistory(ctx context.Context, shortCode string, page pagination.Request) ([]domain.URLHistoryEntry, error) {
+	return nil, nil
+}
+
+func (r *fakeURLRepo) AddAlias(ctx context.Context, shortCode, alias string) error {
+	return nil
+}
+
+func (r *fakeURLRepo) RemoveAlias(ctx context.Context, shortCode, alias string) error {
+	return nil
+}
+
+func (r *fakeURLRepo) ListAliases(ctx context.Context, shortCode string) ([]string, error) {
+	return nil, nil
+}
+
+func (r *fakeURLRepo) ResolveAlias(ctx context.Context, alias string) (string, error) {
+	return "", domain.ErrURLNotFound
+}
+
+func (r *fakeURLRepo) RenameShortCode(ctx context.Context, oldCode, newCode string, keepAlias bool) error {
+	return nil
+}
+
+func (r *fakeURLRepo) ClaimExpiredEvent(ctx context.Context, shortCode string, now time.Time) (*domain.URL, error) {
+	return nil, nil
+}
+
+func (r *fakeURLRepo) ClaimExpiryReminders(ctx context.Context, now, before time.Time, limit int) ([]domain.URL, error) {
+	return nil, nil
+}
+
+// fakeCache is a CacheRepository that can be switched into a failing state
+// It also implements domain.DestinationCache and counts full-entry reads
+type fakeCache struct {
+	mu       sync.Mutex
+	urls     map[string]*domain.URL
+	dests    map[string]domain.Destination
+	claims   map[string]bool
+	fullGets int
+	err      error
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{urls: make(map[string]*domain.URL), dests: make(map[string]domain.Destination), claims: make(map[string]bool)}
+}
+
+func (c *fakeCache) Get(ctx context.Context, shortCode string) (*domain.URL, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.fullGets++
+	return c.urls[shortCode], nil
+}
+
+func (c *fakeCache) GetDestination(ctx context.Context, shortCode string) (*domain.Destination, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	dest, ok := c.dests[shortCode]
+	if !ok {
+		return nil, nil
+	}
+	return &dest, nil
+}
+
+func (c *fakeCache) SetDestination(ctx context.Context, shortCode string, dest domain.Destination, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.dests[shortCode] = dest
+	return nil
+}
+
+func (c *fakeCache) Set(ctx context.Context, url *domain.URL, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	cached := *url
+	c.urls[url.ShortURL] = &cached
+	return nil
+}
+
+func (c *fakeCache) Delete(ctx context.Context, shortCode string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	delete(c.urls, shortCode)
+	delete(c.dests, shortCode)
+	return nil
+}
+
+func (c *fakeCache) DeleteMany(ctx context.Context, shortCodes []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	for _, code := range shortCodes {
+		delete(c.urls, code)
+		delete(c.dests, code)
+	}
+	return nil
+}
+
+func (c *fakeCache) Exists(ctx context.Context, shortCode string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return false, c.err
+	}
+	_, ok := c.urls[shortCode]
+	return ok, nil
+}
+
+func (c *fakeCache) ClaimAlias(ctx context.Context, code string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return false, c.err
+	}
+	if c.claims[code] {
+		return false, nil
+	}
+	c.claims[code] = true
+	return true, nil
+}
+
+func (c *fakeCache) ReleaseAlias(ctx context.Context, code string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	delete(c.claims, code)
+	return nil
+}
+
+func newTestService(t *testing.T, repo domain.URLRepository, cache domain.CacheRepository, cfg URLServiceConfig) *URLService {
+	t.Helper()
+
+	keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1})
+	if err != nil {
+		t.Fatalf("failed to create key generator: %v", err)
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://short.test"
+	}
+
+	m := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	return NewURLService(repo, cache, keyGen, nil, nil, zap.NewNop(), m, cfg)
+}
+
+func TestCreateSucceedsWhenCacheIsDown(t *testing.T) {
+	repo := newFakeURLRepo()
+	cache := newFakeCache()
+	cache.err = errRedisDown
+	svc := newTestService(t, repo, cache, URLServiceConfig{})
+
+	resp, err := svc.Create(context.Background(), &domain.CreateURLRequest{
+		OriginalURL: "https://example.com/landing",
+	})
+	if err != nil {
+		t.Fatalf("Create() with Redis down returned error: %v", err)
+	}
+
+	if _, err := repo.GetByShortCode(context.Background(), resp.ShortCode); err != nil {
+		t.Fatalf("URL was not persisted to the DB: %v", err)
+	}
+}
+
+func TestGetURLFallsBackToDBWhenCacheIsDown(t *testing.T) {
+	repo := newFakeURLRepo()
+	cache := newFakeCache()
+	svc := newTestService(t, repo, cache, URLServiceConfig{})
+
+	resp, err := svc.Create(context.Background(), &domain.CreateURLRequest{
+		OriginalURL: "https://example.com/landing",
+	})
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	cache.err = errRedisDown
+
+	url, err := svc.GetURL(context.Background(), resp.ShortCode)
+	if err != nil {
+		t.Fatalf("GetURL() with Redis down returned error: %v", err)
+	}
+	if url.OriginalURL != "https://example.com/landing" {
+		t.Errorf("GetURL() original_url = %q, want %q", url.OriginalURL, "https://example.com/landing")
+	}
+}
+
+func histogramSnapshot(t *testing.T, h prometheus.Observer) (count uint64, sum float64) {
+	t.Helper()
+
+	var pb dto.Metric
+	if err := h.(prometheus.Metric).Write(&pb); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	return pb.GetHistogram().GetSampleCount(), pb.GetHistogram().GetSampleSum()
+}
+
+func TestCreateObservesShortCodeLength(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{})
+	ctx := context.Background()
+
+	generated, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/a"})
+	if err != nil {
+		t.Fatalf("Create() generated returned error: %v", err)
+	}
+	alias := "spring-sale"
+	if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/b", CustomAlias: &alias}); err != nil {
+		t.Fatalf("Create() custom returned error: %v", err)
+	}
+
+	count, sum := histogramSnapshot(t, svc.metrics.ShortCodeLength.WithLabelValues("generated"))
+	if count != 1 || sum != float64(len(generated.ShortCode)) {
+		t.Errorf("generated length histogram = (count %d, sum %v), want (1, %d)", count, sum, len(generated.ShortCode))
+	}
+
+	count, sum = histogramSnapshot(t, svc.metrics.ShortCodeLength.WithLabelValues("custom"))
+	if count != 1 || sum != float64(len(alias)) {
+		t.Errorf("custom length histogram = (count %d, sum %v), want (1, %d)", count, sum, len(alias))
+	}
+}
+
+func TestSetActiveTogglesRedirects(t *testing.T) {
+	cache := newFakeCache()
+	svc := newTestService(t, newFakeURLRepo(), cache, URLServiceConfig{})
+	ctx := domain.WithCaller(context.Background(), "alice")
+
+	resp, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/paused"})
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	// Only the owner (or the admin) may pause it
+	if err := svc.SetActive(context.Background(), resp.ShortCode, false); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("anonymous SetActive() error = %v, want ErrUnauthorized", err)
+	}
+	if err := svc.SetActive(domain.WithCaller(context.Background(), "bob"), resp.ShortCode, false); !errors.Is(err, domain.ErrForbidden) {
+		t.Errorf("non-owner SetActive() error = %v, want ErrForbidden", err)
+	}
+	if _, err := svc.GetURL(ctx, resp.ShortCode); err != nil {
+		t.Fatalf("GetURL() after refused changes returned error: %v", err)
+	}
+
+	if err := svc.SetActive(ctx, resp.ShortCode, false); err != nil {
+		t.Fatalf("SetActive(false) returned error: %v", err)
+	}
+	if exists, _ := cache.Exists(ctx, resp.ShortCode); exists {
+		t.Error("cache entry still present after disabling")
+	}
+	if _, err := svc.GetURL(ctx, resp.ShortCode); !errors.Is(err, domain.ErrURLDisabled) {
+		t.Fatalf("GetURL() on disabled link error = %v, want ErrURLDisabled", err)
+	}
+
+	if err := svc.SetActive(ctx, resp.ShortCode, true); err != nil {
+		t.Fatalf("SetActive(true) returned error: %v", err)
+	}
+	if _, err := svc.GetURL(ctx, resp.ShortCode); err != nil {
+		t.Fatalf("GetURL() on re-enabled link returned error: %v", err)
+	}
+
+	if err := svc.SetActive(ctx, "missing", false); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("SetActive() on unknown code error = %v, want ErrURLNotFound", err)
+	}
+}
+
+func TestGetStatsIsCached(t *testing.T) {
+	repo := newFakeURLRepo()
+	svc := newTestService(t, repo, newFakeCache(), URLServiceConfig{StatsCacheTTL: time.Hour})
+	ctx := context.Background()
+
+	if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/1"}); err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	first, err := svc.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats() returned error: %v", err)
+	}
+
+	if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/2"}); err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	second, _ := svc.GetStats(ctx)
+	if second.TotalURLs != first.TotalURLs {
+		t.Errorf("GetStats() within TTL total = %d, want cached %d", second.TotalURLs, first.TotalURLs)
+	}
+
+	svc.statsCachedAt = time.Now().Add(-2 * time.Hour)
+	third, _ := svc.GetStats(ctx)
+	if third.TotalURLs != 2 {
+		t.Errorf("GetStats() after TTL total = %d, want 2", third.TotalURLs)
+	}
+}
+
+func TestCaseInsensitiveCodes(t *testing.T) {
+	for _, insensitive := range []bool{false, true} {
+		svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{CaseInsensitiveCodes: insensitive})
+		ctx := context.Background()
+
+		alias := "AbC"
+		if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: &alias}); err != nil {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+
+		_, err := svc.GetURL(ctx, "abc")
+		if insensitive && err != nil {
+			t.Errorf("case-insensitive GetURL(abc) error = %v, want nil", err)
+		}
+		if !insensitive && !errors.Is(err, domain.ErrURLNotFound) {
+			t.Errorf("case-sensitive GetURL(abc) error = %v, want ErrURLNotFound", err)
+		}
+
+		if _, err := svc.GetURL(ctx, "ABC"); insensitive != (err == nil) {
+			t.Errorf("GetURL(ABC) with insensitive=%v error = %v", insensitive, err)
+		}
+	}
+}
+
+func TestCaseInsensitiveResolvesLegacyMixedCaseCodes(t *testing.T) {
+	repo := newFakeURLRepo()
+	// Stored before the flag was enabled
+	_ = repo.Create(context.Background(), &domain.URL{ShortURL: "LeGacY1", OriginalURL: "https://example.com", IsActive: true})
+
+	svc := newTestService(t, repo, newFakeCache(), URLServiceConfig{CaseInsensitiveCodes: true})
+	if _, err := svc.GetURL(context.Background(), "LeGacY1"); err != nil {
+		t.Errorf("GetURL() for legacy mixed-case code error = %v, want nil", err)
+	}
+}
+
+// stubGenerator returns a fixed sequence of codes
+type stubGenerator struct {
+	codes []string
+	err   error
+}
+
+func (g *stubGenerator) Generate(ctx context.Context, length int) (string, error) {
+	if g.err != nil {
+		return "", g.err
+	}
+	code := g.codes[0]
+	g.codes = g.codes[1:]
+	return code, nil
+}
+
+var _ keygen.Generator = (*stubGenerator)(nil)
+
+func TestCreateUsesInjectedGenerator(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{})
+	svc.keyGen = &stubGenerator{codes: []string{"fixed1"}}
+
+	resp, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if resp.ShortCode != "fixed1" || resp.ShortURL != "http://short.test/fixed1" {
+		t.Errorf("Create() = (%q, %q), want stub code fixed1", resp.ShortCode, resp.ShortURL)
+	}
+
+	svc.keyGen = &stubGenerator{err: errors.New("generator exhausted")}
+	if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://example.com"}); err == nil {
+		t.Error("Create() with failing generator returned nil error")
+	}
+}
+
+func TestCreateRetriesGeneratedCodeCollisions(t *testing.T) {
+	repo := newFakeURLRepo()
+	_ = repo.Create(context.Background(), &domain.URL{ShortURL: "taken1", OriginalURL: "https://example.com/old", IsActive: true})
+
+	svc := newTestService(t, repo, newFakeCache(), URLServiceConfig{})
+	svc.keyGen = &stubGenerator{codes: []string{"taken1", "fresh1"}}
+
+	resp, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://example.com/new"})
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if resp.ShortCode != "fresh1" {
+		t.Errorf("Create() short code = %q, want retry to use fresh1", resp.ShortCode)
+	}
+}
+
+func TestCreateSkipsReservedGeneratedCodes(t *testing.T) {
+	repo := newFakeURLRepo()
+	svc := newTestService(t, repo, newFakeCache(), URLServiceConfig{})
+	svc.ReserveCodes("status")
+	svc.keyGen = &stubGenerator{codes: []string{"metrics", "Status", "fresh1"}}
+
+	resp, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if resp.ShortCode != "fresh1" {
+		t.Errorf("Create() short code = %q, want the first unreserved code fresh1", resp.ShortCode)
+	}
+	if _, err := repo.GetByShortCode(context.Background(), "metrics"); !errors.Is(err, domain.ErrURLNotFound) {
+		t.Errorf("reserved code was stored, GetByShortCode() error = %v", err)
+	}
+}
+
+func TestCreatePermanentLinks(t *testing.T) {
+	minusOne := int64(domain.NeverExpiresSentinel)
+	requests := map[string]*domain.CreateURLRequest{
+		"never_expires": {OriginalURL: "https://example.com", NeverExpires: true},
+		"expires_in -1": {OriginalURL: "https://example.com", ExpiresIn: &minusOne},
+	}
+
+	for name, req := range requests {
+		t.Run(name, func(t *testing.T) {
+			repo := newFakeURLRepo()
+			svc := newTestService(t, repo, newFakeCache(), URLServiceConfig{
+				DefaultTTL:     time.Hour,
+				AllowPermanent: true,
+			})
+
+			resp, err := svc.Create(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if resp.ExpiresAt != nil {
+				t.Errorf("ExpiresAt = %v, want nil despite the default TTL", resp.ExpiresAt)
+			}
+			stored, _ := repo.GetByShortCode(context.Background(), resp.ShortCode)
+			if stored.ExpiresAt != nil {
+				t.Errorf("stored ExpiresAt = %v, want nil", stored.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestCreatePermanentRejectedWhenNotAllowed(t *testing.T) {
+	repo := newFakeURLRepo()
+	svc := newTestService(t, repo, newFakeCache(), URLServiceConfig{DefaultTTL: time.Hour})
+
+	_, err := svc.Create(context.Background(), &domain.CreateURLRequest{
+		OriginalURL:  "https://example.com",
+		NeverExpires: true,
+	})
+	if !errors.Is(err, domain.ErrPermanentDisabled) {
+		t.Fatalf("Create() error = %v, want ErrPermanentDisabled", err)
+	}
+	if len(repo.urls) != 0 {
+		t.Errorf("repo has %d urls, want nothing stored", len(repo.urls))
+	}
+
+	// Without the opt-out the default TTL still applies
+	resp, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if resp.ExpiresAt == nil {
+		t.Error("ExpiresAt = nil, want the default TTL")
+	}
+}
+
+func TestCreateWithoutDefaultTTL(t *testing.T) {
+	int64p := func(n int64) *int64 { return &n }
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{MaxTTL: 24 * time.Hour})
+
+	tests := []struct {
+		name    string
+		req     domain.CreateURLRequest
+		wantTTL time.Duration // 0 = no expiry
+	}{
+		{"unspecified is permanent", domain.CreateURLRequest{}, 0},
+		{"expires_in 0 falls back to the default", domain.CreateURLRequest{ExpiresIn: int64p(0)}, 0},
+		{"explicit expires_in still applies", domain.CreateURLRequest{ExpiresIn: int64p(3600)}, time.Hour},
+		{"explicit expires_in is capped", domain.CreateURLRequest{ExpiresIn: int64p(7 * 24 * 3600)}, 24 * time.Hour},
+		// Asking for what the server does anyway needs no AllowPermanent
+		{"never_expires", domain.CreateURLRequest{NeverExpires: true}, 0},
+		{"expires_in -1", domain.CreateURLRequest{ExpiresIn: int64p(-1)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			req.OriginalURL = "https://example.com/" + strings.ReplaceAll(tt.name, " ", "-")
+			before := time.Now()
+			resp, err := svc.Create(context.Background(), &req)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if tt.wantTTL == 0 {
+				if resp.ExpiresAt != nil {
+					t.Errorf("ExpiresAt = %v, want nil", resp.ExpiresAt)
+				}
+				return
+			}
+			if resp.ExpiresAt == nil {
+				t.Fatalf("ExpiresAt = nil, want %v from now", tt.wantTTL)
+			}
+			if got := resp.ExpiresAt.Sub(before); got < tt.wantTTL || got > tt.wantTTL+time.Minute {
+				t.Errorf("ExpiresAt is %v from now, want %v", got, tt.wantTTL)
+			}
+		})
+	}
+}
+
+type fakeClickCounter struct {
+	clicks map[string]int
+	err    error
+}
+
+func (c *fakeClickCounter) Incr(ctx context.Context, shortCode string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.clicks[shortCode]++
+	return nil
+}
+
+func TestVisitCountsClicks(t *testing.T) {
+	repo := newFakeURLRepo()
+	_ = repo.Create(context.Background(), &domain.URL{ShortURL: "abc123", OriginalURL: "https://example.com", IsActive: true})
+	svc := newTestService(t, repo, newFakeCache(), URLServiceConfig{})
+	counter := &fakeClickCounter{clicks: make(map[string]int)}
+	svc.clicks = counter
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Visit(context.Background(), "abc123"); err != nil {
+			t.Fatalf("Visit() error = %v", err)
+		}
+	}
+	if counter.clicks["abc123"] != 2 {
+		t.Errorf("clicks = %d, want 2", counter.clicks["abc123"])
+	}
+
+	// Unknown codes are not counted
+	if _, err := svc.Visit(context.Background(), "nope"); err == nil {
+		t.Error("Visit() for unknown code error = nil")
+	}
+	if len(counter.clicks) != 1 {
+		t.Errorf("counted codes = %v, want only abc123", counter.clicks)
+	}
+
+	// A broken counter must not break redirects
+	counter.err = errRedisDown
+	if _, err := svc.Visit(context.Background(), "abc123"); err != nil {
+		t.Errorf("Visit() with failing counter error = %v, want nil", err)
+	}
+}
+
+func TestCreateWithRequestedCodeLength(t *testing.T) {
+	random, err := keygen.NewRandomGenerator(8)
+	if err != nil {
+		t.Fatalf("NewRandomGenerator() error = %v", err)
+	}
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{MinCodeLength: 6, MaxCodeLength: 10})
+	svc.keyGen = random
+
+	for _, length := range []int{6, 7, 10} {
+		resp, err := svc.Create(context.Background(), &domain.CreateURLRequest{
+			OriginalURL: "https://example.com",
+			CodeLength:  &length,
+		})
+		if err != nil {
+			t.Fatalf("Create(code_length=%d) error = %v", length, err)
+		}
+		if len(resp.ShortCode) != length {
+			t.Errorf("Create(code_length=%d) code = %q, want length %d", length, resp.ShortCode, length)
+		}
+	}
+
+	for _, length := range []int{0, 5, 11} {
+		_, err := svc.Create(context.Background(), &domain.CreateURLRequest{
+			OriginalURL: "https://example.com",
+			CodeLength:  &length,
+		})
+		if !errors.Is(err, domain.ErrInvalidShortCode) {
+			t.Errorf("Create(code_length=%d) error = %v, want ErrInvalidShortCode", length, err)
+		}
+	}
+}
+
+func TestCreateRequestedLengthTooShortForGenerator(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{MinCodeLength: 4, MaxCodeLength: 12})
+	svc.keyGen = &stubGenerator{err: keygen.ErrLengthUnavailable}
+
+	// The generator can't go that short, a random code stands in
+	length := 4
+	resp, err := svc.Create(context.Background(), &domain.CreateURLRequest{
+		OriginalURL: "https://example.com",
+		CodeLength:  &length,
+	})
+	if err != nil || len(resp.ShortCode) != length {
+		t.Fatalf("Create() = %+v, %v; want a %d-character code", resp, err, length)
+	}
+}
+
+func TestCreateEveryConfiguredLengthWithSnowflake(t *testing.T) {
+	for _, lowercase := range []bool{false, true} {
+		svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{
+			MinCodeLength:        6,
+			MaxCodeLength:        14,
+			CaseInsensitiveCodes: lowercase,
+		})
+		keyGen, err := keygen.NewSnowflakeGenerator(keygen.Config{MachineID: 1, MaxLength: 14, Lowercase: lowercase})
+		if err != nil {
+			t.Fatalf("NewSnowflakeGenerator() error = %v", err)
+		}
+		svc.keyGen = keyGen
+
+		// Natural snowflake codes are 10 (base62) or 12 (base36) characters,
+		// every length on either side of that must still work
+		for length := 6; length <= 14; length++ {
+			resp, err := svc.Create(context.Background(), &domain.CreateURLRequest{
+				OriginalURL: "https://example.com",
+				CodeLength:  &length,
+			})
+			if err != nil {
+				t.Fatalf("lowercase=%v Create(code_length=%d) error = %v", lowercase, length, err)
+			}
+			if len(resp.ShortCode) != length {
+				t.Errorf("lowercase=%v Create(code_length=%d) code = %q", lowercase, length, resp.ShortCode)
+			}
+			if lowercase && resp.ShortCode != strings.ToLower(resp.ShortCode) {
+				t.Errorf("Create(code_length=%d) code = %q, want lowercase", length, resp.ShortCode)
+			}
+		}
+	}
+}
+
+func TestPrivateLinksResolveOnlyForOwner(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{})
+	alice := domain.WithCaller(context.Background(), "alice")
+	bob := domain.WithCaller(context.Background(), "bob")
+	anonymous := context.Background()
+
+	private, err := svc.Create(alice, &domain.CreateURLRequest{
+		OriginalURL: "https://example.com/secret",
+		Visibility:  domain.VisibilityPrivate,
+	})
+	if err != nil {
+		t.Fatalf("Create(private) error = %v", err)
+	}
+	public, err := svc.Create(alice, &domain.CreateURLRequest{OriginalURL: "https://example.com/open"})
+	if err != nil {
+		t.Fatalf("Create(public) error = %v", err)
+	}
+
+	// Run twice: the first read comes from the repo, the second from the cache
+	for round := 1; round <= 2; round++ {
+		if _, err := svc.GetURL(alice, private.ShortCode); err != nil {
+			t.Errorf("round %d: owner GetURL(private) error = %v, want nil", round, err)
+		}
+		if _, err := svc.GetURL(bob, private.ShortCode); !errors.Is(err, domain.ErrForbidden) {
+			t.Errorf("round %d: stranger GetURL(private) error = %v, want ErrForbidden", round, err)
+		}
+		if _, err := svc.GetURL(anonymous, private.ShortCode); !errors.Is(err, domain.ErrUnauthorized) {
+			t.Errorf("round %d: anonymous GetURL(private) error = %v, want ErrUnauthorized", round, err)
+		}
+		for _, ctx := range []context.Context{alice, bob, anonymous} {
+			if _, err := svc.GetURL(ctx, public.ShortCode); err != nil {
+				t.Errorf("round %d: GetURL(public) error = %v, want nil", round, err)
+			}
+		}
+	}
+}
+
+func TestCreatePrivateRequiresAPIKey(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{})
+
+	_, err := svc.Create(context.Background(), &domain.CreateURLRequest{
+		OriginalURL: "https://example.com/secret",
+		Visibility:  domain.VisibilityPrivate,
+	})
+	if !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("anonymous Create(private) error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestSignedLinksRejectTampering(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{SigningKey: []byte("test-key")})
+	ctx := context.Background()
+
+	signed, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/signed", Signed: true})
+	if err != nil {
+		t.Fatalf("Create(signed) error = %v", err)
+	}
+	code, tag, ok := keygen.SplitSigned(signed.ShortCode)
+	if !ok {
+		t.Fatalf("signed short code %q has no tag", signed.ShortCode)
+	}
+	plain, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/plain"})
+	if err != nil {
+		t.Fatalf("Create(plain) error = %v", err)
+	}
+
+	// Run twice: the first read comes from the repo, the second from the cache
+	for round := 1; round <= 2; round++ {
+		if _, err := svc.GetURL(ctx, signed.ShortCode); err != nil {
+			t.Errorf("round %d: GetURL(signed) error = %v, want nil", round, err)
+		}
+		for _, tampered := range []string{code, code + keygen.SignatureSeparator + "AAAAAAAA", plain.ShortCode + keygen.SignatureSeparator + tag} {
+			if _, err := svc.GetURL(ctx, tampered); !errors.Is(err, domain.ErrURLNotFound) {
+				t.Errorf("round %d: GetURL(%q) error = %v, want ErrURLNotFound", round, tampered, err)
+			}
+		}
+		if _, err := svc.GetURL(ctx, plain.ShortCode); err != nil {
+			t.Errorf("round %d: GetURL(plain) error = %v, want nil", round, err)
+		}
+	}
+}
+
+func TestCreateSignedRequiresSigningKey(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{})
+
+	_, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://example.com/signed", Signed: true})
+	if !errors.Is(err, domain.ErrSigningDisabled) {
+		t.Errorf("Create(signed) without a key error = %v, want ErrSigningDisabled", err)
+	}
+}
+
+func TestVisitUsesCompactCacheEntry(t *testing.T) {
+	repo := newFakeURLRepo()
+	cache := newFakeCache()
+	svc := newTestService(t, repo, cache, URLServiceConfig{CompactRedirectCache: true})
+	ctx := context.Background()
+
+	resp, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/fast"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, ok := cache.dests[resp.ShortCode]; !ok {
+		t.Fatal("Create() did not write the compact entry")
+	}
+	if _, ok := cache.urls[resp.ShortCode]; ok {
+		t.Error("Create() cached the full URL although only redirects have been served")
+	}
+
+	for i := 0; i < 3; i++ {
+		url, err := svc.Visit(ctx, resp.ShortCode)
+		if err != nil {
+			t.Fatalf("Visit() error = %v", err)
+		}
+		if url.OriginalURL != "https://example.com/fast" {
+			t.Errorf("Visit() destination = %q", url.OriginalURL)
+		}
+	}
+	if cache.fullGets != 0 {
+		t.Errorf("redirects read the full cache entry %d times, want 0", cache.fullGets)
+	}
+
+	// Metadata still comes back complete and is cached lazily
+	info, err := svc.GetURL(ctx, resp.ShortCode)
+	if err != nil {
+		t.Fatalf("GetURL() error = %v", err)
+	}
+	if info.ID == 0 || info.CreatedAt.IsZero() {
+		t.Errorf("GetURL() = %+v, want the full record", info)
+	}
+	if _, ok := cache.urls[resp.ShortCode]; !ok {
+		t.Error("GetURL() did not cache the full URL")
+	}
+
+	// Disabling must evict the compact entry as well
+	if err := svc.SetActive(domain.WithAdmin(ctx), resp.ShortCode, false); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	if _, err := svc.Visit(ctx, resp.ShortCode); !errors.Is(err, domain.ErrURLDisabled) {
+		t.Errorf("Visit() after disable error = %v, want ErrURLDisabled", err)
+	}
+}
+
+func TestCompactCacheSkipsPrivateLinks(t *testing.T) {
+	cache := newFakeCache()
+	svc := newTestService(t, newFakeURLRepo(), cache, URLServiceConfig{CompactRedirectCache: true})
+	alice := domain.WithCaller(context.Background(), "alice")
+
+	resp, err := svc.Create(alice, &domain.CreateURLRequest{
+		OriginalURL: "https://example.com/secret",
+		Visibility:  domain.VisibilityPrivate,
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := svc.Visit(alice, resp.ShortCode); err != nil {
+		t.Fatalf("owner Visit() error = %v", err)
+	}
+	if len(cache.dests) != 0 {
+		t.Fatalf("private link got a compact entry: %v", cache.dests)
+	}
+	if _, err := svc.Visit(context.Background(), resp.ShortCode); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("anonymous Visit() error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestSetActiveManyEvictsCache(t *testing.T) {
+	repo := newFakeURLRepo()
+	cache := newFakeCache()
+	svc := newTestService(t, repo, cache, URLServiceConfig{})
+	ctx := domain.WithCaller(context.Background(), "alice")
+	bob := domain.WithCaller(context.Background(), "bob")
+
+	for code, owner := range map[string]context.Context{"aaa111": ctx, "bbb222": ctx, "ccc333": bob} {
+		if _, err := svc.Create(owner, &domain.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: &code}); err != nil {
+			t.Fatalf("Create(%s) error = %v", code, err)
+		}
+	}
+	if _, err := svc.GetURL(bob, "ccc333"); err != nil {
+		t.Fatalf("GetURL() error = %v", err)
+	}
+
+	resp, err := svc.SetActiveMany(ctx, []string{"aaa111", "missing", "ccc333", "bbb222"}, false)
+	if err != nil {
+		t.Fatalf("SetActiveMany() error = %v", err)
+	}
+	if len(resp.Updated) != 2 || !slices.Equal(resp.NotFound, []string{"missing"}) || !slices.Equal(resp.Forbidden, []string{"ccc333"}) {
+		t.Errorf("SetActiveMany() = %+v, want 2 updated, missing not found and bob's link forbidden", resp)
+	}
+	if len(cache.urls) != 1 {
+		t.Errorf("cache holds %d entries, want only bob's untouched link", len(cache.urls))
+	}
+	if _, err := svc.GetURL(ctx, "aaa111"); !errors.Is(err, domain.ErrURLDisabled) {
+		t.Errorf("GetURL() after bulk disable error = %v, want ErrURLDisabled", err)
+	}
+	if _, err := svc.GetURL(bob, "ccc333"); err != nil {
+		t.Errorf("GetURL() of a link the batch wasn't allowed to touch error = %v", err)
+	}
+
+	if _, err := svc.SetActiveMany(context.Background(), []string{"aaa111"}, true); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("anonymous SetActiveMany() error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCreateEnforcesLinkQuota(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{
+		MaxLinksPerUser: 2,
+		UserLinkQuotas:  map[string]int{"pro": 3, "staff": 0},
+	})
+
+	create := func(user string) error {
+		ctx := context.Background()
+		if user != "" {
+			ctx = domain.WithCaller(ctx, user)
+		}
+		_, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com"})
+		return err
+	}
+
+	for user, quota := range map[string]int{"alice": 2, "pro": 3} {
+		for i := 0; i < quota; i++ {
+			if err := create(user); err != nil {
+				t.Fatalf("%s create %d: %v", user, i+1, err)
+			}
+		}
+		if err := create(user); !errors.Is(err, domain.ErrQuotaExceeded) {
+			t.Errorf("%s create %d: err = %v, want ErrQuotaExceeded", user, quota+1, err)
+		}
+	}
+
+	// Other users, uncapped users and anonymous callers are unaffected
+	for _, user := range []string{"bob", "staff", "staff", "staff", ""} {
+		if err := create(user); err != nil {
+			t.Errorf("create as %q: %v", user, err)
+		}
+	}
+}
+
+func TestCreateLabelsURLsCreatedByType(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/gen"}); err != nil {
+			t.Fatalf("Create() generated: %v", err)
+		}
+	}
+	alias := "summer-sale"
+	if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/custom", CustomAlias: &alias}); err != nil {
+		t.Fatalf("Create() custom: %v", err)
+	}
+	// A taken alias creates nothing and must not be counted
+	if _, err := svc.Create(ctx, &domain.CreateURLRequest{OriginalURL: "https://example.com/again", CustomAlias: &alias}); !errors.Is(err, domain.ErrShortCodeExists) {
+		t.Fatalf("Create() duplicate alias error = %v, want ErrShortCodeExists", err)
+	}
+
+	if got := testutil.ToFloat64(svc.metrics.URLsCreatedTotal.WithLabelValues("generated")); got != 2 {
+		t.Errorf(`urls_created_total{type="generated"} = %v, want 2`, got)
+	}
+	if got := testutil.ToFloat64(svc.metrics.URLsCreatedTotal.WithLabelValues("custom")); got != 1 {
+		t.Errorf(`urls_created_total{type="custom"} = %v, want 1`, got)
+	}
+	if got := testutil.ToFloat64(svc.metrics.CustomAliasTotal); got != 1 {
+		t.Errorf("custom_alias_total = %v, want 1", got)
+	}
+}
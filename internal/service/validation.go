@@ -0,0 +1,212 @@
+package service
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+	"golang.org/x/net/idna"
+)
+
+// domainList matches hostnames against exact entries ("acme.com") and
+// wildcard entries ("*.acme.com", which matches any subdomain of acme.com
+// but not acme.com itself - list both to allow the apex too)
+type domainList struct {
+	exact    map[string]struct{}
+	suffixes []string
+}
+
+func newDomainList(entries []string) domainList {
+	list := domainList{exact: make(map[string]struct{})}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if strings.HasPrefix(entry, "*.") {
+			// Hosts are compared in punycode, so "*.münchen.de" must be too
+			list.suffixes = append(list.suffixes, "."+asciiHost(entry[2:])) // keep the leading dot
+			continue
+		}
+		list.exact[asciiHost(entry)] = struct{}{}
+	}
+	return list
+}
+
+func (l domainList) empty() bool {
+	return len(l.exact) == 0 && len(l.suffixes) == 0
+}
+
+func (l domainList) matches(host string) bool {
+	if _, ok := l.exact[host]; ok {
+		return true
+	}
+	for _, suffix := range l.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultMaxURLLength is the longest destination accepted when none is configured
+// 2048 is the practical limit most browsers and CDNs agree on
+const DefaultMaxURLLength = 2048
+
+// asciiHost returns the punycode form of a list entry, or the entry itself
+// when it isn't a valid IDN (it then simply never matches)
+func asciiHost(host string) string {
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		return ascii
+	}
+	return host
+}
+
+// normalizeHost converts an internationalized hostname to its lowercase
+// punycode (xn--) form. Plain ASCII names and IP literals are left alone;
+// hosts that aren't valid IDNA (including malformed xn-- labels) are rejected.
+func normalizeHost(host string) (string, error) {
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	if !needsIDNA(host) {
+		return host, nil
+	}
+
+	root := strings.HasSuffix(host, ".")
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil || ascii == "" {
+		return "", domain.ErrInvalidURL
+	}
+	if root {
+		ascii += "."
+	}
+	return ascii, nil
+}
+
+// needsIDNA reports whether host has non-ASCII or punycode labels
+func needsIDNA(host string) bool {
+	for i := 0; i < len(host); i++ {
+		if host[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	for _, label := range strings.Split(host, ".") {
+		if strings.HasPrefix(strings.ToLower(label), "xn--") {
+			return true
+		}
+	}
+	return false
+}
+
+// Schemes destinations may use when none are configured
+var defaultAllowedSchemes = []string{"http", "https"}
+
+// dangerousSchemes run code or read local files when followed by a browser
+// They can be enabled, but never by accident: startup logs a warning
+var dangerousSchemes = map[string]struct{}{
+	"javascript": {},
+	"vbscript":   {},
+	"data":       {},
+	"file":       {},
+}
+
+// AllowsScheme reports whether destinations may use scheme
+func (s *URLService) AllowsScheme(scheme string) bool {
+	_, ok := s.allowedSchemes[strings.ToLower(scheme)]
+	return ok
+}
+
+func newSchemeSet(schemes []string) map[string]struct{} {
+	if len(schemes) == 0 {
+		schemes = defaultAllowedSchemes
+	}
+	set := make(map[string]struct{}, len(schemes))
+	for _, scheme := range schemes {
+		if scheme = strings.ToLower(strings.TrimSpace(scheme)); scheme != "" {
+			set[scheme] = struct{}{}
+		}
+	}
+	return set
+}
+
+// normalizeDestination returns the form a destination is stored in, with
+// internationalized hosts in punycode, and the parsed normalized URL.
+// Web URLs need a host; other schemes (mailto:, tel:) need a non-empty body.
+func normalizeDestination(originalURL string) (string, *url.URL, error) {
+	parsed, err := url.Parse(originalURL)
+	if err != nil || parsed.Scheme == "" {
+		return "", nil, domain.ErrInvalidURL
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	if parsed.Host == "" {
+		if parsed.Scheme == "http" || parsed.Scheme == "https" || (parsed.Opaque == "" && parsed.Path == "") {
+			return "", nil, domain.ErrInvalidURL
+		}
+		return originalURL, parsed, nil
+	}
+
+	host, err := normalizeHost(parsed.Hostname())
+	if err != nil {
+		return "", nil, err
+	}
+	if host != parsed.Hostname() {
+		if port := parsed.Port(); port != "" {
+			parsed.Host = net.JoinHostPort(host, port)
+		} else {
+			parsed.Host = host
+		}
+		originalURL = parsed.String()
+	}
+	return originalURL, parsed, nil
+}
+
+// validateDestinationMap holds every per-platform or per-country destination
+// to the same rules as original_url; keys are checked by request binding
+func (s *URLService) validateDestinationMap(destinations map[string]string) (map[string]string, error) {
+	if len(destinations) == 0 {
+		return nil, nil
+	}
+	validated := make(map[string]string, len(destinations))
+	for platform, dest := range destinations {
+		normalized, err := s.validateDestination(dest)
+		if err != nil {
+			return nil, err
+		}
+		validated[platform] = normalized
+	}
+	return validated, nil
+}
+
+// validateDestination normalizes the destination and checks its scheme,
+// length and host against the configured lists, returning the normalized URL.
+// The denylist always wins, so a domain that is both allowed by a wildcard
+// and explicitly blocked is rejected.
+func (s *URLService) validateDestination(originalURL string) (string, error) {
+	originalURL, parsed, err := normalizeDestination(originalURL)
+	if err != nil {
+		return "", err
+	}
+	if !s.AllowsScheme(parsed.Scheme) {
+		return "", domain.ErrSchemeNotAllowed
+	}
+
+	// Checked after normalization, punycode is longer than the Unicode form
+	if len(originalURL) > s.maxURLLength {
+		return "", domain.ErrInvalidURL
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host != "" && s.blockedDomains.matches(host) {
+		return "", domain.ErrForbiddenDomain
+	}
+	// With an allowlist, hostless destinations (mailto:, tel:) have no domain
+	// that could be on it
+	if !s.allowedDomains.empty() && (host == "" || !s.allowedDomains.matches(host)) {
+		return "", domain.ErrForbiddenDomain
+	}
+
+	return originalURL, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/subhammahanty235/url-shortener/internal/domain"
+)
+
+func TestCreateEnforcesDestinationDomainLists(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{
+		AllowedDestinationDomains: []string{"acme.com", "*.acme.com", "partner.io"},
+		BlockedDestinationDomains: []string{"evil.acme.com"},
+	})
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{"exact allowed", "https://acme.com/promo", nil},
+		{"wildcard subdomain", "https://shop.acme.com/cart", nil},
+		{"nested wildcard subdomain", "https://eu.shop.acme.com/cart", nil},
+		{"case insensitive host", "https://Partner.IO/x", nil},
+		{"blocked wins over wildcard", "https://evil.acme.com/login", domain.ErrForbiddenDomain},
+		{"not on allowlist", "https://example.com/", domain.ErrForbiddenDomain},
+		{"suffix without dot boundary", "https://notacme.com/", domain.ErrForbiddenDomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: tt.url})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create(%q) error = %v, want %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateBlocklistWithoutAllowlist(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{
+		BlockedDestinationDomains: []string{"*.phish.example"},
+	})
+
+	if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://login.phish.example/"}); !errors.Is(err, domain.ErrForbiddenDomain) {
+		t.Errorf("Create() blocked domain error = %v, want ErrForbiddenDomain", err)
+	}
+	if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://example.com/"}); err != nil {
+		t.Errorf("Create() unlisted domain error = %v, want nil", err)
+	}
+}
+
+func TestCreateEnforcesMaxURLLength(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{MaxURLLength: 64})
+
+	prefix := "https://example.com/"
+	atLimit := prefix + strings.Repeat("a", 64-len(prefix))
+
+	if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: atLimit}); err != nil {
+		t.Errorf("Create() at the limit error = %v, want nil", err)
+	}
+	if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: atLimit + "a"}); !errors.Is(err, domain.ErrInvalidURL) {
+		t.Errorf("Create() one byte over the limit error = %v, want ErrInvalidURL", err)
+	}
+}
+
+func TestMaxURLLengthDefault(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{})
+
+	long := "https://example.com/" + strings.Repeat("a", DefaultMaxURLLength)
+	if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: long}); !errors.Is(err, domain.ErrInvalidURL) {
+		t.Errorf("Create() over the default limit error = %v, want ErrInvalidURL", err)
+	}
+}
+
+func TestCreateNormalizesIDNDestinations(t *testing.T) {
+	repo := newFakeURLRepo()
+	svc := newTestService(t, repo, newFakeCache(), URLServiceConfig{})
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://münchen.de/karte", "https://xn--mnchen-3ya.de/karte"},
+		{"https://MÜNCHEN.de:8443/", "https://xn--mnchen-3ya.de:8443/"},
+		{"http://例え.テスト/", "http://xn--r8jz45g.xn--zckzah/"},
+		{"https://xn--mnchen-3ya.de/", "https://xn--mnchen-3ya.de/"},
+		{"https://example.com/ü", "https://example.com/ü"},
+	}
+	for _, tt := range tests {
+		resp, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: tt.url})
+		if err != nil {
+			t.Errorf("Create(%q): %v", tt.url, err)
+			continue
+		}
+		if resp.OriginalURL != tt.want {
+			t.Errorf("Create(%q) original_url = %q, want %q", tt.url, resp.OriginalURL, tt.want)
+		}
+		stored, _ := repo.GetByShortCode(context.Background(), resp.ShortCode)
+		if stored == nil || stored.OriginalURL != tt.want {
+			t.Errorf("Create(%q) stored a different form than %q", tt.url, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"https://xn--a.com/", "https://a‍b.com/"} {
+		if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: bad}); !errors.Is(err, domain.ErrInvalidURL) {
+			t.Errorf("Create(%q) error = %v, want ErrInvalidURL", bad, err)
+		}
+	}
+}
+
+func TestDomainListsMatchIDNInEitherForm(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{
+		AllowedDestinationDomains: []string{"münchen.de", "*.bücher.example"},
+		BlockedDestinationDomains: []string{"xn--bse-sna.example"},
+	})
+
+	for _, ok := range []string{"https://münchen.de/", "https://xn--mnchen-3ya.de/", "https://shop.bücher.example/"} {
+		if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: ok}); err != nil {
+			t.Errorf("Create(%q): %v", ok, err)
+		}
+	}
+	if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "https://böse.example/"}); !errors.Is(err, domain.ErrForbiddenDomain) {
+		t.Errorf("blocked IDN error = %v, want ErrForbiddenDomain", err)
+	}
+}
+
+func TestCreateEnforcesAllowedSchemes(t *testing.T) {
+	tests := []struct {
+		name    string
+		schemes []string
+		url     string
+		wantErr error
+	}{
+		{"default http", nil, "http://example.com/", nil},
+		{"default rejects mailto", nil, "mailto:sales@acme.com", domain.ErrSchemeNotAllowed},
+		{"default rejects ftp", nil, "ftp://files.example.com/a.zip", domain.ErrSchemeNotAllowed},
+		{"mailto enabled", []string{"http", "https", "mailto", "tel"}, "mailto:sales@acme.com?subject=Hi", nil},
+		{"tel enabled", []string{"http", "https", "mailto", "tel"}, "tel:+15551234567", nil},
+		{"scheme match is case insensitive", []string{"HTTPS", "MailTo"}, "MAILTO:sales@acme.com", nil},
+		{"sms not enabled", []string{"http", "https", "mailto", "tel"}, "sms:+15551234567", domain.ErrSchemeNotAllowed},
+		{"javascript stays blocked", []string{"http", "https", "mailto"}, "javascript:alert(1)", domain.ErrSchemeNotAllowed},
+		{"data stays blocked", []string{"http", "https", "mailto"}, "data:text/html,<script>alert(1)</script>", domain.ErrSchemeNotAllowed},
+		{"data explicitly enabled", []string{"https", "data"}, "data:text/plain,hello", nil},
+		{"empty mailto", []string{"mailto"}, "mailto:", domain.ErrInvalidURL},
+		{"http needs a host", []string{"http"}, "http:/just/a/path", domain.ErrInvalidURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{AllowedSchemes: tt.schemes})
+			_, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: tt.url})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create(%q) error = %v, want %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHostlessSchemesFailDomainAllowlist(t *testing.T) {
+	svc := newTestService(t, newFakeURLRepo(), newFakeCache(), URLServiceConfig{
+		AllowedSchemes:            []string{"https", "mailto"},
+		AllowedDestinationDomains: []string{"acme.com"},
+	})
+	if _, err := svc.Create(context.Background(), &domain.CreateURLRequest{OriginalURL: "mailto:sales@acme.com"}); !errors.Is(err, domain.ErrForbiddenDomain) {
+		t.Errorf("mailto with an allowlist error = %v, want ErrForbiddenDomain", err)
+	}
+}